@@ -0,0 +1,163 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/jtaleric/k8s-io/pkg/anonymize"
+	"github.com/jtaleric/k8s-io/pkg/bundle"
+	"github.com/jtaleric/k8s-io/pkg/config"
+	"github.com/jtaleric/k8s-io/pkg/history"
+	"github.com/jtaleric/k8s-io/pkg/kubernetes"
+	"github.com/jtaleric/k8s-io/pkg/plan"
+	"github.com/jtaleric/k8s-io/pkg/workloads"
+)
+
+// recordRunEnvironment writes the plan of the run, without credentials, and the k8s-io build
+// and cluster it runs on to the artifacts, so a bundle can reproduce or audit it. Failures
+// only lose information, so they are warnings.
+func recordRunEnvironment(ctx context.Context, k8sClient *kubernetes.Client, cfg *config.Config, workload workloads.Workload) {
+	dir := cfg.RunArtifactsDir()
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		log.Printf("Warning: failed to create artifacts directory: %v", err)
+		return
+	}
+
+	if p, err := runPlan(cfg, workload); err != nil {
+		log.Printf("Warning: failed to record the plan of the run: %v", err)
+	} else if err := p.Save(filepath.Join(dir, bundle.PlanFile)); err != nil {
+		log.Printf("Warning: %v", err)
+	}
+
+	env := bundle.Environment{Tool: bundle.ToolVersion()}
+	if info, err := k8sClient.ClusterInfo(ctx); err != nil {
+		log.Printf("Warning: failed to record the cluster of the run: %v", err)
+	} else {
+		env.Cluster = info
+	}
+	if err := env.Write(dir); err != nil {
+		log.Printf("Warning: %v", err)
+	}
+}
+
+// runPlan returns the plan of the run with the redacted configuration. Credentials rendered
+// into the manifests are redacted the same way, so apply-plan still verifies them.
+func runPlan(cfg *config.Config, workload workloads.Workload) (*plan.Plan, error) {
+	manifests, err := workload.GenerateManifests()
+	if err != nil {
+		return nil, err
+	}
+	for _, secret := range cfg.Secrets() {
+		for name, content := range manifests {
+			manifests[name] = strings.ReplaceAll(content, secret, "redacted")
+		}
+	}
+	est, err := workload.Estimate()
+	if err != nil {
+		return nil, err
+	}
+	fingerprint, err := workload.Fingerprint()
+	if err != nil {
+		return nil, err
+	}
+	return plan.New(cfg.Redacted(), fingerprint, manifests, withHookPhases(cfg, workload.Phases()), est)
+}
+
+// runBundleCommand packs the plan, environment, artifacts, results and history record of a
+// run into a tarball
+func runBundleCommand(args []string) {
+	fs := flag.NewFlagSet("bundle", flag.ExitOnError)
+	uuid := fs.String("uuid", "", "UUID of the run")
+	configFile := fs.String("config", "", "Take the history file and artifacts directory from this configuration file")
+	historyFile := fs.String("file", "", "Path to the history file (defaults to history.path from -config)")
+	artifactsDir := fs.String("artifacts", "", "Directory with the artifacts of every run (defaults to artifacts_dir from -config)")
+	resultsDir := fs.String("results", ".", "Directory with the CSV exports of the run")
+	output := fs.String("o", "", "Bundle file to write (default k8s-io-<uuid>.tar.gz)")
+	anonymizeBundle := fs.Bool("anonymize", false, "Replace node, host and cluster names and IPs with pseudonyms (default anonymize from -config)")
+	fs.Parse(args)
+
+	if *uuid == "" {
+		log.Fatalf("The run to bundle must be given with -uuid")
+	}
+
+	if *configFile != "" {
+		cfg, err := config.LoadConfig(*configFile)
+		if err != nil {
+			log.Fatalf("Failed to load configuration: %v", err)
+		}
+		if *historyFile == "" && cfg.History != nil {
+			*historyFile = cfg.History.Path
+		}
+		if *artifactsDir == "" {
+			*artifactsDir = cfg.ArtifactsDir
+		}
+		if cfg.Anonymize {
+			*anonymizeBundle = true
+		}
+	}
+	if *artifactsDir == "" {
+		*artifactsDir = "artifacts"
+	}
+	if *output == "" {
+		*output = "k8s-io-" + *uuid + ".tar.gz"
+	}
+
+	runDir := filepath.Join(*artifactsDir, *uuid)
+	if _, err := os.Stat(runDir); err != nil {
+		log.Fatalf("No artifacts of run %s: %v", *uuid, err)
+	}
+	for _, name := range []string{bundle.PlanFile, bundle.EnvironmentFile} {
+		if _, err := os.Stat(filepath.Join(runDir, name)); err != nil {
+			log.Printf("Warning: run %s has no %s, it ran with an older k8s-io", *uuid, name)
+		}
+	}
+
+	var record *history.Record
+	if *historyFile != "" {
+		records, err := history.NewStore(*historyFile).Load()
+		if err != nil {
+			log.Fatalf("%v", err)
+		}
+		if r, ok := history.Find(records, *uuid); ok {
+			record = &r
+		}
+	}
+	if record == nil {
+		log.Printf("Warning: run %s is not in the history, the bundle has no history record", *uuid)
+	}
+
+	// The CSV exports are named after the truncated UUID
+	truncated := *uuid
+	if len(truncated) > 8 {
+		truncated = truncated[:8]
+	}
+	extra, _ := filepath.Glob(filepath.Join(*resultsDir, "fio-results-"+truncated+"_*.csv"))
+
+	out, err := os.Create(*output)
+	if err != nil {
+		log.Fatalf("Failed to create bundle: %v", err)
+	}
+	opts := bundle.Options{UUID: *uuid, Record: record, ArtifactsDir: runDir, Extra: extra}
+	if *anonymizeBundle {
+		opts.Anonymizer = anonymize.New(*uuid)
+	}
+	m, err := bundle.Create(out, opts)
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(*output)
+		log.Fatalf("Failed to create bundle: %v", err)
+	}
+
+	fmt.Printf("Wrote %s with %d files\n", *output, len(m.Files))
+	if m.Anonymized {
+		fmt.Println("Node, host and cluster names and IPs are replaced with pseudonyms")
+	}
+	fmt.Printf("Reproduce the run with: tar xzf %s && k8s-io apply-plan k8s-io-%s/artifacts/%s\n", *output, *uuid, bundle.PlanFile)
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/jtaleric/k8s-io/pkg/config"
+	"github.com/jtaleric/k8s-io/pkg/status"
+	"github.com/jtaleric/k8s-io/pkg/timeline"
+	"github.com/jtaleric/k8s-io/pkg/units"
+	"github.com/jtaleric/k8s-io/pkg/workloads/fio"
+)
+
+// runCacheStudyCommand runs the FIO read profile of a configuration with O_DIRECT, buffered,
+// and buffered under memory limits of the server pods, and reports the effect of the page cache
+func runCacheStudyCommand(args []string) {
+	fs := flag.NewFlagSet("cache-study", flag.ExitOnError)
+	configFile := fs.String("config", "config.yaml", "Path to a FIO configuration file")
+	memory := fs.String("memory", "", "Comma-separated memory limits of the server pods for additional buffered runs, e.g. 512Mi,2Gi")
+	cpu := fs.String("cpu", "2", "CPU of the server pods of the memory-limited runs, unless the configuration sets server_cpu")
+	namespace := fs.String("namespace", "", "Namespace of the benchmark (overrides the configuration)")
+	kubeconfig := fs.String("kubeconfig", "", "Path to the kubeconfig file")
+	kubeContext := fs.String("context", "", "Kubeconfig context to use")
+	unitsMode := fs.String("units", "", "Units of the comparison: raw or human (overrides the configuration)")
+	follow := fs.Bool("follow", false, "Stream the logs of all benchmark pods, prefixed with the pod name")
+	fs.Parse(args)
+
+	cfg, err := config.LoadConfig(*configFile)
+	if err != nil {
+		exit(nil, status.Errorf(status.ReasonConfig, "failed to load configuration: %w", err))
+	}
+	if *namespace != "" {
+		cfg.Namespace = *namespace
+	}
+	if *unitsMode != "" {
+		cfg.Units = *unitsMode
+	}
+	mode, err := units.ParseMode(cfg.Units)
+	if err != nil {
+		exit(cfg, status.Errorf(status.ReasonConfig, "%w", err))
+	}
+	units.SetFormat(cfg.NumberFormat())
+	if cfg.Workload.Name != "fio" {
+		exit(cfg, status.Errorf(status.ReasonConfig, "cache-study compares FIO runs, the configuration runs %s", cfg.Workload.Name))
+	}
+
+	var limits []string
+	for _, limit := range strings.Split(*memory, ",") {
+		if limit = strings.TrimSpace(limit); limit != "" {
+			limits = append(limits, limit)
+		}
+	}
+	arms, err := fio.CacheStudyArgs(cfg.Workload.Args, cfg.JobParams, limits, *cpu)
+	if err != nil {
+		exit(cfg, status.Errorf(status.ReasonConfig, "invalid page cache study: %w", err))
+	}
+
+	cfg.Clock = timeline.NewClock()
+	log.Printf("Studying the page cache effect over %d runs as run %s", len(arms), cfg.UUID)
+
+	metrics := make(map[string]map[string]float64, len(arms))
+	for i := range arms {
+		arms[i].UUID = cfg.UUID + "-" + arms[i].Name
+		metrics[arms[i].Name] = runComparisonArm(cfg, arms[i].Name, arms[i].UUID, arms[i].Args, *kubeconfig, *kubeContext, *follow)
+	}
+	report := fio.CacheStudyReport{UUID: cfg.UUID, Arms: arms, Effects: fio.CacheEffects(arms, metrics)}
+
+	fmt.Println("\n=== Page Cache Effect ===")
+	fio.PrintCacheEffectTable(os.Stdout, arms, report.Effects, mode)
+	fmt.Println("\nThe gain of a buffered run is the throughput gained or latency saved over the direct run.")
+
+	if err := report.Write(cfg.RunArtifactsDir()); err != nil {
+		log.Printf("Warning: %v", err)
+		warnings = append(warnings, err.Error())
+	}
+	exit(cfg, nil)
+}
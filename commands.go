@@ -0,0 +1,337 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/jtaleric/k8s-io/pkg/config"
+	"github.com/jtaleric/k8s-io/pkg/history"
+	"github.com/jtaleric/k8s-io/pkg/kubernetes"
+	"github.com/jtaleric/k8s-io/pkg/logmux"
+	"github.com/jtaleric/k8s-io/pkg/timeline"
+	"github.com/jtaleric/k8s-io/pkg/tui"
+	"github.com/jtaleric/k8s-io/pkg/units"
+	"github.com/jtaleric/k8s-io/pkg/workloads"
+	"github.com/jtaleric/k8s-io/pkg/workloads/fio"
+)
+
+// runHistoryCommand lists the runs recorded in the history file
+func runHistoryCommand(args []string) {
+	fs := flag.NewFlagSet("history", flag.ExitOnError)
+	configFile := fs.String("config", "", "Only list runs comparable with this configuration file")
+	historyFile := fs.String("file", "", "Path to the history file (defaults to history.path from -config)")
+	force := fs.Bool("force", false, "List runs with any config fingerprint")
+	fs.Parse(args)
+
+	path, fingerprint := resolveHistory(*configFile, *historyFile)
+
+	records, err := history.NewStore(path).Load()
+	if err != nil {
+		log.Fatalf("Failed to load history: %v", err)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "UUID\tStarted (UTC)\tWorkload\tFingerprint\tMetrics\n")
+	for _, record := range records {
+		if fingerprint != "" && record.ConfigHash != fingerprint && !*force {
+			continue
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%d\n",
+			record.UUID,
+			timeline.FormatUTC(record.Timestamp),
+			record.Workload,
+			record.ConfigHash,
+			len(record.Metrics),
+		)
+	}
+	w.Flush()
+}
+
+// runCompareCommand compares the metrics of two runs from the history file
+func runCompareCommand(args []string) {
+	fs := flag.NewFlagSet("compare", flag.ExitOnError)
+	configFile := fs.String("config", "", "Configuration file providing history.path")
+	historyFile := fs.String("file", "", "Path to the history file (defaults to history.path from -config)")
+	force := fs.Bool("force", false, "Compare runs even if their config fingerprints differ")
+	unitsMode := fs.String("units", "raw", "Units of the metrics: raw or human")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: k8s-io compare [flags] <baseline-uuid> <uuid>\n")
+		fmt.Fprintf(fs.Output(), "       k8s-io compare [flags] <baseline.csv> <results.csv>\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if fs.NArg() != 2 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	mode, err := units.ParseMode(*unitsMode)
+	if err != nil {
+		log.Fatalf("Invalid -units: %v", err)
+	}
+
+	var baseline, current history.Record
+	if strings.HasSuffix(fs.Arg(0), ".csv") && strings.HasSuffix(fs.Arg(1), ".csv") {
+		baseline = loadCSVRecord(fs.Arg(0))
+		current = loadCSVRecord(fs.Arg(1))
+	} else {
+		path, _ := resolveHistory(*configFile, *historyFile)
+
+		records, err := history.NewStore(path).Load()
+		if err != nil {
+			log.Fatalf("Failed to load history: %v", err)
+		}
+
+		var ok bool
+		if baseline, ok = history.Find(records, fs.Arg(0)); !ok {
+			log.Fatalf("Run %s not found in %s", fs.Arg(0), path)
+		}
+		if current, ok = history.Find(records, fs.Arg(1)); !ok {
+			log.Fatalf("Run %s not found in %s", fs.Arg(1), path)
+		}
+	}
+
+	if baseline.ConfigHash != current.ConfigHash {
+		if !*force {
+			log.Fatalf("Runs have different config fingerprints (%s vs %s), use -force to compare anyway",
+				baseline.ConfigHash, current.ConfigHash)
+		}
+		log.Printf("Warning: comparing runs with different config fingerprints (%s vs %s)",
+			baseline.ConfigHash, current.ConfigHash)
+	}
+
+	metrics := make([]string, 0, len(current.Metrics))
+	for metric := range current.Metrics {
+		if _, ok := baseline.Metrics[metric]; ok {
+			metrics = append(metrics, metric)
+		}
+	}
+	sort.Strings(metrics)
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "Metric\t%s\t%s\tChange (%%)\n", baseline.UUID, current.UUID)
+	for _, metric := range metrics {
+		before := baseline.Metrics[metric]
+		after := current.Metrics[metric]
+		change := 0.0
+		if before != 0 {
+			change = (after - before) / before * 100
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%+.1f\n", metric, units.Metric(metric, before, mode), units.Metric(metric, after, mode), change)
+	}
+	w.Flush()
+}
+
+// loadCSVRecord reads a FIO results CSV of any schema version as a history record
+func loadCSVRecord(filename string) history.Record {
+	summaries, version, err := fio.ReadResultsCSV(filename)
+	if err != nil {
+		log.Fatalf("Failed to read results: %v", err)
+	}
+
+	if version < fio.CSVSchemaVersion {
+		log.Printf("Converted %s from results schema v%d to v%d", filename, version, fio.CSVSchemaVersion)
+	}
+
+	record := history.Record{
+		UUID:     filename,
+		Workload: "fio",
+		Metrics:  fio.SummarizeMetrics(summaries),
+	}
+	if len(summaries) > 0 {
+		record.ConfigHash = summaries[0].Fingerprint
+	}
+
+	return record
+}
+
+// resolveHistory returns the history file and, when a configuration file is given,
+// the fingerprint of its effective workload configuration
+func resolveHistory(configFile, historyFile string) (string, string) {
+	var fingerprint string
+
+	if configFile != "" {
+		cfg, err := config.LoadConfig(configFile)
+		if err != nil {
+			log.Fatalf("Failed to load configuration: %v", err)
+		}
+
+		workload, err := workloads.NewFactory(nil, cfg).CreateWorkload()
+		if err != nil {
+			log.Fatalf("Failed to create workload: %v", err)
+		}
+
+		fingerprint, err = workload.Fingerprint()
+		if err != nil {
+			log.Fatalf("Failed to compute config fingerprint: %v", err)
+		}
+
+		if historyFile == "" && cfg.History != nil {
+			historyFile = cfg.History.Path
+		}
+	}
+
+	if historyFile == "" {
+		log.Fatalf("A history file must be given with -file or history.path in -config")
+	}
+
+	return historyFile, fingerprint
+}
+
+// runStatusCommand lists the benchmark pods in the namespace with their phase, grouped by run,
+// and with -follow streams their logs until interrupted
+func runStatusCommand(args []string) {
+	fs := flag.NewFlagSet("status", flag.ExitOnError)
+	configFile := fs.String("config", "", "Take the namespace from this configuration file")
+	namespace := fs.String("namespace", "", "Namespace of the benchmark, or of the queue with -queue (overrides -config)")
+	uuid := fs.String("uuid", "", "Only show this run")
+	follow := fs.Bool("follow", false, "Stream the logs of the benchmark pods, prefixed with the pod name, until interrupted")
+	queue := fs.Bool("queue", false, "Show the run queue instead of the benchmark pods")
+	kubeconfig := fs.String("kubeconfig", "", "Path to the kubeconfig file")
+	kubeContext := fs.String("context", "", "Kubeconfig context to use")
+	fs.Parse(args)
+
+	ns := "default"
+	queueNS := "k8s-io-queue"
+	if *configFile != "" {
+		cfg, err := config.LoadConfig(*configFile)
+		if err != nil {
+			log.Fatalf("Failed to load configuration: %v", err)
+		}
+		ns = cfg.Namespace
+		if cfg.Queue != nil {
+			queueNS = cfg.Queue.Namespace
+		}
+	}
+	if *namespace != "" {
+		ns = *namespace
+		queueNS = *namespace
+	}
+
+	k8sClient, err := kubernetes.NewClientForContext(*kubeconfig, *kubeContext)
+	if err != nil {
+		log.Fatalf("Failed to create Kubernetes client: %v", err)
+	}
+
+	if *queue {
+		tickets, err := k8sClient.ListQueue(context.Background(), queueNS)
+		if err != nil {
+			log.Fatalf("%v", err)
+		}
+		if len(tickets) == 0 {
+			fmt.Printf("No queued or running benchmarks in namespace %s\n", queueNS)
+			return
+		}
+		printQueue(os.Stdout, tickets)
+		return
+	}
+
+	selector := "benchmark-uuid"
+	if *uuid != "" {
+		selector = "benchmark-uuid=" + *uuid
+	}
+	pods, err := k8sClient.ListPods(context.Background(), ns, selector)
+	if err != nil {
+		log.Fatalf("Failed to list benchmark pods: %v", err)
+	}
+	if len(pods.Items) == 0 {
+		fmt.Printf("No benchmark pods found in namespace %s\n", ns)
+		return
+	}
+
+	sort.Slice(pods.Items, func(i, j int) bool {
+		a, b := pods.Items[i], pods.Items[j]
+		if a.Labels["benchmark-uuid"] != b.Labels["benchmark-uuid"] {
+			return a.Labels["benchmark-uuid"] < b.Labels["benchmark-uuid"]
+		}
+		return a.Name < b.Name
+	})
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "UUID\tPod\tPhase\tNode\tAge\tETA\tReason\n")
+	for _, pod := range pods.Items {
+		reason := ""
+		if failure := kubernetes.ClassifyPod(pod); failure.Environmental || pod.Status.Phase == corev1.PodFailed {
+			reason = failure.Reason
+		}
+		eta := ""
+		if jobName := pod.Labels["job-name"]; jobName != "" && pod.Status.Phase == corev1.PodRunning {
+			if job, err := k8sClient.GetJob(context.Background(), jobName, ns); err == nil {
+				if t, ok := kubernetes.JobETA(job); ok {
+					eta = kubernetes.FormatETA(t)
+				}
+			}
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			pod.Labels["benchmark-uuid"],
+			pod.Name,
+			pod.Status.Phase,
+			pod.Spec.NodeName,
+			time.Since(pod.CreationTimestamp.Time).Round(time.Second),
+			eta,
+			reason,
+		)
+	}
+	w.Flush()
+
+	if *follow {
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		fmt.Println()
+		logmux.New(k8sClient, ns, selector, os.Stdout).Run(ctx)
+	}
+}
+
+// runTUICommand shows the live phase, pods, client log and results of the active run
+func runTUICommand(args []string) {
+	fs := flag.NewFlagSet("tui", flag.ExitOnError)
+	configFile := fs.String("config", "", "Take the namespace from this configuration file")
+	namespace := fs.String("namespace", "", "Namespace of the benchmark (overrides -config)")
+	uuid := fs.String("uuid", "", "Run to show (defaults to the newest run in the namespace)")
+	interval := fs.Duration("interval", 5*time.Second, "Refresh interval")
+	lines := fs.Int64("lines", 15, "Number of client log lines shown")
+	unitsMode := fs.String("units", "raw", "Units of the results: raw (MiB/s, usec) or human")
+	kubeconfig := fs.String("kubeconfig", "", "Path to the kubeconfig file")
+	kubeContext := fs.String("context", "", "Kubeconfig context to use")
+	fs.Parse(args)
+
+	ns := "default"
+	if *configFile != "" {
+		cfg, err := config.LoadConfig(*configFile)
+		if err != nil {
+			log.Fatalf("Failed to load configuration: %v", err)
+		}
+		ns = cfg.Namespace
+	}
+	if *namespace != "" {
+		ns = *namespace
+	}
+
+	mode, err := units.ParseMode(*unitsMode)
+	if err != nil {
+		log.Fatalf("Invalid -units: %v", err)
+	}
+
+	k8sClient, err := kubernetes.NewClientForContext(*kubeconfig, *kubeContext)
+	if err != nil {
+		log.Fatalf("Failed to create Kubernetes client: %v", err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if err := tui.Run(ctx, k8sClient, tui.Options{Namespace: ns, UUID: *uuid, Interval: *interval, LogLines: *lines, Units: mode}); err != nil {
+		log.Fatalf("TUI failed: %v", err)
+	}
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/jtaleric/k8s-io/pkg/compat"
+	"github.com/jtaleric/k8s-io/pkg/config"
+	"github.com/jtaleric/k8s-io/pkg/status"
+)
+
+// runCompatCommand checks the manifests of a configuration for parts of the API that
+// Kubernetes versions deprecated or removed, and for fields the built-in API types do not
+// know. It fails when a version removed a part of the API they use or a field is unknown.
+func runCompatCommand(args []string) {
+	fs := flag.NewFlagSet("compat", flag.ExitOnError)
+	configFile := fs.String("config", "config.yaml", "Path to configuration file")
+	versionList := fs.String("versions", compat.DefaultVersions, "Kubernetes versions to check for deprecated and removed APIs, as ranges or a comma-separated list")
+	kubeconfig := fs.String("kubeconfig", "", "Path to the kubeconfig file")
+	kubeContext := fs.String("context", "", "Kubeconfig context to use")
+	fs.Parse(args)
+
+	cfg, err := config.LoadConfig(*configFile)
+	if err != nil {
+		exit(nil, status.Errorf(status.ReasonConfig, "failed to load configuration: %w", err))
+	}
+	versions, err := compat.ParseVersions(*versionList)
+	if err != nil {
+		exit(cfg, status.Errorf(status.ReasonConfig, "%w", err))
+	}
+
+	_, workload := createWorkload(cfg, *kubeconfig, *kubeContext)
+	manifests, err := workload.GenerateManifests()
+	if err != nil {
+		exit(cfg, status.Errorf(status.ReasonConfig, "failed to generate manifests: %w", err))
+	}
+
+	findings, err := compat.Check(manifests, versions)
+	if err != nil {
+		exit(cfg, status.Errorf(status.ReasonConfig, "%w", err))
+	}
+	if len(findings) == 0 {
+		fmt.Printf("The %d manifests use no deprecated or removed APIs in Kubernetes %s\n", len(manifests), *versionList)
+		return
+	}
+
+	failed := 0
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "Version\tManifest\tResource\tField\tStatus\tMessage\n")
+	for _, f := range findings {
+		version := f.Version.String()
+		if f.Status() == "unknown" {
+			version = compat.SchemaVersion.String()
+		}
+		if f.Status() != "deprecated" {
+			failed++
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", version, f.Manifest, f.Resource, f.Path, f.Status(), f.Message)
+	}
+	w.Flush()
+
+	if failed > 0 {
+		exit(cfg, status.Errorf(status.ReasonConfig, "%d uses of removed APIs or unknown fields in the manifests", failed))
+	}
+}
@@ -0,0 +1,220 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"reflect"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/jtaleric/k8s-io/pkg/config"
+	"github.com/jtaleric/k8s-io/pkg/explain"
+	"github.com/jtaleric/k8s-io/pkg/kubernetes"
+	"github.com/jtaleric/k8s-io/pkg/workloads/fio"
+	"github.com/jtaleric/k8s-io/pkg/workloads/hammerdb"
+	"github.com/jtaleric/k8s-io/pkg/workloads/provision"
+)
+
+// configFields returns the documented configuration fields. Workload fields are prefixed
+// with the workload name and are set under workload.args.
+func configFields() []explain.Field {
+	var fields []explain.Field
+	for _, root := range []struct {
+		t      reflect.Type
+		prefix string
+		source string
+	}{
+		{reflect.TypeOf(config.Config{}), "", config.Source},
+		{reflect.TypeOf(fio.FIOConfig{}), "fio", fio.ConfigSource},
+		{reflect.TypeOf(hammerdb.HammerDBConfig{}), "hammerdb", hammerdb.ConfigSource},
+		{reflect.TypeOf(provision.ProvisionConfig{}), "provision", provision.ConfigSource},
+	} {
+		f, err := explain.Fields(root.t, root.prefix, root.source)
+		if err != nil {
+			log.Fatalf("Failed to document configuration: %v", err)
+		}
+		fields = append(fields, f...)
+	}
+	return fields
+}
+
+// runExplainCommand prints the documentation of a configuration field and the fields below it
+func runExplainCommand(args []string) {
+	fs := flag.NewFlagSet("explain", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: k8s-io explain [field]\n\n")
+		fmt.Fprintf(fs.Output(), "Fields are YAML paths such as prometheus.step, fio.iodepth or hammerdb.warehouses.\n")
+		fmt.Fprintf(fs.Output(), "fio, hammerdb and provision fields are set under workload.args.\n")
+	}
+	fs.Parse(args)
+
+	path := strings.Trim(fs.Arg(0), ".")
+	field, children := explain.Lookup(configFields(), path)
+	if field == nil && len(children) == 0 {
+		fmt.Fprintf(os.Stderr, "Unknown field %q\n", path)
+		os.Exit(2)
+	}
+
+	if field != nil {
+		fmt.Printf("FIELD: %s\nTYPE:  %s\n", field.Path, field.Type)
+		if workload, _, _ := strings.Cut(field.Path, "."); workload == "fio" || workload == "hammerdb" || workload == "provision" {
+			fmt.Printf("SET UNDER: workload.args (workload.name: %s)\n", workload)
+		}
+		if field.Doc != "" {
+			fmt.Printf("\n%s\n", field.Doc)
+		}
+		if strings.HasSuffix(field.Path, "storageclass") {
+			printStorageClasses()
+		}
+	}
+
+	if len(children) > 0 {
+		if field != nil {
+			fmt.Println()
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "FIELD\tTYPE\tDESCRIPTION")
+		for _, f := range children {
+			fmt.Fprintf(w, "%s\t%s\t%s\n", f.Path, f.Type, f.Doc)
+		}
+		w.Flush()
+	}
+}
+
+// printStorageClasses lists the storage classes of the current cluster, if it is reachable
+func printStorageClasses() {
+	k8sClient, err := kubernetes.NewClient()
+	if err != nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	classes, err := k8sClient.ListStorageClasses(ctx)
+	if err != nil || len(classes) == 0 {
+		return
+	}
+	fmt.Printf("\nStorage classes in the current cluster: %s\n", strings.Join(classes, ", "))
+}
+
+// runCompleteCommand prints the candidates the completion scripts offer for a kind of argument
+func runCompleteCommand(args []string) {
+	if len(args) != 1 {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var candidates []string
+	switch args[0] {
+	case "fields":
+		candidates = explain.Paths(configFields())
+	case "contexts":
+		candidates, _ = kubernetes.KubeconfigContexts()
+	case "namespaces":
+		if k8sClient, err := kubernetes.NewClient(); err == nil {
+			candidates, _ = k8sClient.ListNamespaces(ctx)
+		}
+	}
+
+	for _, c := range candidates {
+		fmt.Println(c)
+	}
+}
+
+// runCompletionCommand prints the completion script for a shell
+func runCompletionCommand(args []string) {
+	if len(args) != 1 {
+		fmt.Fprintf(os.Stderr, "Usage: k8s-io completion bash|zsh|fish\n")
+		os.Exit(2)
+	}
+
+	switch args[0] {
+	case "bash":
+		fmt.Print(bashCompletion)
+	case "zsh":
+		fmt.Print("autoload -U +X bashcompinit && bashcompinit\n" + bashCompletion)
+	case "fish":
+		fmt.Print(fishCompletion)
+	default:
+		fmt.Fprintf(os.Stderr, "Unsupported shell %q, expected bash, zsh or fish\n", args[0])
+		os.Exit(2)
+	}
+}
+
+// bashCompletion completes subcommands, flags (from the -h output of the command), config
+// files, explain fields and live namespaces and contexts
+const bashCompletion = `_k8s_io() {
+    local cur prev sub
+    cur="${COMP_WORDS[COMP_CWORD]}"
+    prev="${COMP_WORDS[COMP_CWORD-1]}"
+    sub=""
+    if [[ ${COMP_CWORD} -gt 1 && ${COMP_WORDS[1]} != -* ]]; then
+        sub="${COMP_WORDS[1]}"
+    fi
+
+    case "${prev}" in
+        -config|--config)
+            COMPREPLY=($(compgen -f -X '!*.y*ml' -- "${cur}") $(compgen -d -S / -- "${cur}"))
+            compopt -o nospace 2>/dev/null
+            return ;;
+        -file|--file|-junit|--junit|-kubeconfig|--kubeconfig|-o)
+            COMPREPLY=($(compgen -f -- "${cur}"))
+            return ;;
+        -namespace|--namespace|-n)
+            COMPREPLY=($(compgen -W "$(k8s-io __complete namespaces 2>/dev/null)" -- "${cur}"))
+            return ;;
+        -context|--context)
+            COMPREPLY=($(compgen -W "$(k8s-io __complete contexts 2>/dev/null)" -- "${cur}"))
+            return ;;
+        -units|--units)
+            COMPREPLY=($(compgen -W "raw human" -- "${cur}"))
+            return ;;
+    esac
+
+    if [[ ${cur} == -* ]]; then
+        COMPREPLY=($(compgen -W "$(k8s-io ${sub} -h 2>&1 | sed -n 's/^  \(-[a-z-]*\).*/\1/p')" -- "${cur}"))
+        return
+    fi
+
+    case "${sub}" in
+        "")
+            COMPREPLY=($(compgen -W "history compare status tui plan apply-plan vm-overhead cache-study soak results-server upload bundle import generate compat explain timeline purge-auth flush-results completion" -- "${cur}")) ;;
+        explain)
+            COMPREPLY=($(compgen -W "$(k8s-io __complete fields 2>/dev/null)" -- "${cur}")) ;;
+        apply-plan)
+            COMPREPLY=($(compgen -f -- "${cur}")) ;;
+        completion)
+            COMPREPLY=($(compgen -W "bash zsh fish" -- "${cur}")) ;;
+    esac
+}
+complete -F _k8s_io k8s-io
+`
+
+// fishCompletion completes the same arguments as the bash script
+const fishCompletion = `set -l k8s_io_commands history compare status tui plan apply-plan vm-overhead cache-study soak results-server upload bundle import generate compat explain timeline purge-auth flush-results completion
+complete -c k8s-io -f
+complete -c k8s-io -n "not __fish_seen_subcommand_from $k8s_io_commands" -a "$k8s_io_commands"
+complete -c k8s-io -n "__fish_seen_subcommand_from explain" -a "(k8s-io __complete fields 2>/dev/null)"
+complete -c k8s-io -n "__fish_seen_subcommand_from completion" -a "bash zsh fish"
+complete -c k8s-io -n "__fish_seen_subcommand_from apply-plan" -F
+complete -c k8s-io -o config -r -F -d "Configuration file"
+complete -c k8s-io -o file -r -F -d "History file"
+complete -c k8s-io -o junit -r -F -d "JUnit report file"
+complete -c k8s-io -o kubeconfig -r -F -d "Kubeconfig file"
+complete -c k8s-io -o namespace -x -a "(k8s-io __complete namespaces 2>/dev/null)" -d "Namespace"
+complete -c k8s-io -o context -x -a "(k8s-io __complete contexts 2>/dev/null)" -d "Kubeconfig context"
+complete -c k8s-io -o units -x -a "raw human" -d "Units of the results tables"
+complete -c k8s-io -o dry-run -d "Generate manifests without applying them"
+complete -c k8s-io -o cleanup -d "Cleanup resources and exit"
+complete -c k8s-io -o follow -d "Stream the logs of all benchmark pods"
+complete -c k8s-io -o no-overwrite -d "Fail instead of updating existing resources"
+complete -c k8s-io -o anonymize -d "Replace hostnames, node names and IPs with pseudonyms"
+complete -c k8s-io -o debug-templates -d "Dump template contexts and rendered manifests"
+complete -c k8s-io -o debug-addr -x -d "Serve pprof and runtime diagnostics"
+`
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"text/tabwriter"
+
+	"github.com/jtaleric/k8s-io/pkg/config"
+	"github.com/jtaleric/k8s-io/pkg/sinks"
+)
+
+// runFlushResultsCommand sends the documents that sinks did not receive during runs, and
+// were spooled to their artifacts, to the sinks again
+func runFlushResultsCommand(args []string) {
+	fs := flag.NewFlagSet("flush-results", flag.ExitOnError)
+	configFile := fs.String("config", "config.yaml", "Configuration file with the sinks, and artifacts directory, of the runs")
+	artifactsDir := fs.String("artifacts", "", "Directory with the artifacts of every run (defaults to artifacts_dir from -config)")
+	uuid := fs.String("uuid", "", "Only flush this run")
+	dryRun := fs.Bool("dry-run", false, "List the spooled documents without sending them")
+	fs.Parse(args)
+
+	cfg, err := config.LoadConfig(*configFile)
+	if err != nil {
+		log.Fatalf("Failed to load configuration: %v", err)
+	}
+	if *artifactsDir == "" {
+		*artifactsDir = cfg.ArtifactsDir
+	}
+
+	batches, err := sinks.ListSpool(*artifactsDir, *uuid)
+	if err != nil {
+		log.Fatalf("Failed to list the spooled documents: %v", err)
+	}
+	if len(batches) == 0 {
+		fmt.Printf("No spooled documents in %s\n", *artifactsDir)
+		return
+	}
+
+	ctx := context.Background()
+	failed := 0
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "Run\tSink\tIndex\tDocuments\tResult\n")
+	for _, b := range batches {
+		var count int
+		result := "sent"
+		if *dryRun {
+			docs, err := b.Documents()
+			count = len(docs)
+			result = "spooled"
+			if err != nil {
+				result = "unreadable"
+				log.Printf("Warning: %v", err)
+			}
+		} else if count, err = sinks.Flush(ctx, cfg, b); err != nil {
+			log.Printf("Warning: %s of run %s to sink %s: %v", b.Index, b.Run, b.Sink, err)
+			result = "failed, still spooled"
+			failed++
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%s\n", b.Run, b.Sink, b.Index, count, result)
+	}
+	w.Flush()
+
+	if failed > 0 {
+		log.Fatalf("%d of %d spooled batches could not be sent", failed, len(batches))
+	}
+}
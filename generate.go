@@ -0,0 +1,109 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"sigs.k8s.io/yaml"
+
+	"github.com/jtaleric/k8s-io/pkg/config"
+	"github.com/jtaleric/k8s-io/pkg/status"
+)
+
+// runtimeKinds are created by k8s-io during every run, since they are one-shot or depend on
+// the servers and nodes of the run, and are left out of the generated manifests
+var runtimeKinds = map[string]bool{"Job": true, "DaemonSet": true}
+
+// runGenerateCommand writes the long-lived resources of a gitops configuration to a
+// directory with a kustomization, for a GitOps controller to reconcile. Runs with the same
+// configuration adopt them, since the names are derived from the configuration.
+func runGenerateCommand(args []string) {
+	fs := flag.NewFlagSet("generate", flag.ExitOnError)
+	configFile := fs.String("config", "config.yaml", "Path to configuration file")
+	output := fs.String("o", "manifests", "Directory to write the manifests to")
+	namespace := fs.String("namespace", "", "Namespace of the benchmark (overrides the configuration)")
+	kubeconfig := fs.String("kubeconfig", "", "Path to the kubeconfig file")
+	kubeContext := fs.String("context", "", "Kubeconfig context to use")
+	fs.Parse(args)
+
+	cfg, err := config.LoadConfig(*configFile)
+	if err != nil {
+		exit(nil, status.Errorf(status.ReasonConfig, "failed to load configuration: %w", err))
+	}
+	if !cfg.GitOps {
+		exit(cfg, status.Errorf(status.ReasonConfig, "generate needs gitops: true in the configuration, so runs use the names of the generated manifests"))
+	}
+	if *namespace != "" {
+		cfg.Namespace = *namespace
+	}
+
+	k8sClient, workload := createWorkload(cfg, *kubeconfig, *kubeContext)
+
+	manifests, err := workload.GenerateManifests()
+	if err != nil {
+		exit(cfg, status.Errorf(status.ReasonConfig, "failed to generate manifests: %w", err))
+	}
+	if err := os.MkdirAll(*output, 0755); err != nil {
+		exit(cfg, status.Errorf(status.ReasonConfig, "failed to create output directory: %w", err))
+	}
+
+	files := make(map[string]string)
+	labels, annotations := cfg.NamespaceMetadata()
+	for _, ns := range workload.Namespaces() {
+		data, err := yaml.Marshal(map[string]interface{}{
+			"apiVersion": "v1",
+			"kind":       "Namespace",
+			"metadata":   map[string]interface{}{"name": ns, "labels": labels, "annotations": annotations},
+		})
+		if err != nil {
+			exit(cfg, status.Errorf(status.ReasonConfig, "failed to encode namespace %s: %w", ns, err))
+		}
+		files["namespace-"+ns+".yaml"] = string(data)
+	}
+
+	for name, manifest := range manifests {
+		var meta struct {
+			Kind string `json:"kind"`
+		}
+		if err := yaml.Unmarshal([]byte(manifest), &meta); err != nil {
+			exit(cfg, status.Errorf(status.ReasonConfig, "failed to decode manifest %s: %w", name, err))
+		}
+		if runtimeKinds[meta.Kind] {
+			log.Printf("Leaving out %s (%s), k8s-io creates it during the run", name, meta.Kind)
+			continue
+		}
+
+		rendered, err := k8sClient.RenderManifest(manifest, cfg.Namespace)
+		if err != nil {
+			exit(cfg, status.Errorf(status.ReasonConfig, "failed to render manifest %s: %w", name, err))
+		}
+		files[name+".yaml"] = rendered
+	}
+
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	kustomization := "apiVersion: kustomize.config.k8s.io/v1beta1\nkind: Kustomization\nresources:\n"
+	for _, name := range names {
+		kustomization += "- " + name + "\n"
+	}
+	files["kustomization.yaml"] = kustomization
+
+	for name, content := range files {
+		filename := filepath.Join(*output, name)
+		if err := os.WriteFile(filename, []byte(content), 0644); err != nil {
+			exit(cfg, status.Errorf(status.ReasonConfig, "failed to write %s: %w", filename, err))
+		}
+	}
+
+	fmt.Printf("Wrote %d manifests of run %s to %s: %s\n", len(names), cfg.UUID, *output, strings.Join(names, ", "))
+	fmt.Printf("Once they are reconciled, run the benchmark with: k8s-io -config %s\n", *configFile)
+}
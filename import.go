@@ -0,0 +1,144 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/jtaleric/k8s-io/pkg/history"
+	"github.com/jtaleric/k8s-io/pkg/workloads/fio"
+)
+
+// runImportCommand adds externally produced fio results to the run history, so they can be
+// listed and compared like the runs of k8s-io
+func runImportCommand(args []string) {
+	fs := flag.NewFlagSet("import", flag.ExitOnError)
+	configFile := fs.String("config", "", "Take the history file from this configuration file")
+	historyFile := fs.String("file", "", "Path to the history file (defaults to history.path from -config)")
+	format := fs.String("format", "auto", "Format of the results: fio (fio JSON output), ripsaw (benchmark-operator Elasticsearch documents) or auto")
+	uuid := fs.String("uuid", "", "UUID of the run of fio JSON output (defaults to one derived from the file content)")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: k8s-io import [flags] <fio.json|ripsaw-es-dump.json>...\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if fs.NArg() == 0 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	if *uuid != "" && fs.NArg() > 1 {
+		log.Fatalf("-uuid can only name the run of a single file")
+	}
+
+	historyPath, _ := resolveHistory(*configFile, *historyFile)
+	store := history.NewStore(historyPath)
+	existing, err := store.Load()
+	if err != nil {
+		log.Fatalf("%v", err)
+	}
+
+	imported, skipped := 0, 0
+	for _, filename := range fs.Args() {
+		runs, err := importRuns(filename, *format, *uuid)
+		if err != nil {
+			log.Fatalf("Failed to import %s: %v", filename, err)
+		}
+
+		for _, run := range runs {
+			if _, ok := history.Find(existing, run.UUID); ok {
+				log.Printf("Run %s from %s is already in the history", run.UUID, filename)
+				skipped++
+				continue
+			}
+
+			summaries := run.Summaries()
+			if len(summaries) == 0 {
+				log.Printf("Warning: run %s from %s has no job results, skipping it", run.UUID, filename)
+				continue
+			}
+			record := history.Record{
+				UUID:      run.UUID,
+				Workload:  "fio",
+				Timestamp: run.Timestamp,
+				Metrics:   fio.SummarizeMetrics(summaries),
+				Tags:      map[string]string{"imported-from": importSource(filename, *format)},
+				Owner:     run.User,
+			}
+			if err := store.Append(record); err != nil {
+				log.Fatalf("%v", err)
+			}
+			existing = append(existing, record)
+			imported++
+			fmt.Printf("Imported run %s from %s (%s, %d results)\n", run.UUID, filename, timestampOrUnknown(run.Timestamp), len(summaries))
+		}
+	}
+
+	fmt.Printf("Imported %d run(s) into %s", imported, historyPath)
+	if skipped > 0 {
+		fmt.Printf(", %d already present", skipped)
+	}
+	fmt.Println()
+}
+
+// importRuns reads the runs of a results file in the given or detected format
+func importRuns(filename, format, uuid string) ([]*fio.ImportedRun, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	if format == "auto" {
+		if format, err = fio.DetectImportFormat(data); err != nil {
+			return nil, err
+		}
+	}
+
+	switch format {
+	case fio.ImportFIOJSON:
+		results, err := fio.ParseFIOJSON(data)
+		if err != nil {
+			return nil, err
+		}
+		run := &fio.ImportedRun{UUID: uuid, Results: results}
+		// The same file always gets the same UUID, so importing it again is noticed
+		if run.UUID == "" {
+			sum := sha256.Sum256(data)
+			run.UUID = "import-" + hex.EncodeToString(sum[:])[:8]
+		}
+		if results[0].Timestamp > 0 {
+			run.Timestamp = time.Unix(results[0].Timestamp, 0).UTC()
+		}
+		return []*fio.ImportedRun{run}, nil
+	case fio.ImportRipsaw:
+		return fio.ParseRipsawDocuments(data)
+	default:
+		return nil, fmt.Errorf("unknown format %q, use fio, ripsaw or auto", format)
+	}
+}
+
+// importSource returns the imported-from tag of a file, the format it was read as
+func importSource(filename, format string) string {
+	if format != "auto" {
+		return format
+	}
+	if data, err := os.ReadFile(filename); err == nil {
+		if detected, err := fio.DetectImportFormat(data); err == nil {
+			return detected
+		}
+	}
+	return filepath.Ext(filename)
+}
+
+// timestampOrUnknown formats the start of an imported run
+func timestampOrUnknown(t time.Time) string {
+	if t.IsZero() {
+		return "start unknown"
+	}
+	return t.Format(time.RFC3339)
+}
@@ -2,20 +2,121 @@ package main
 
 import (
 	"context"
+	"errors"
 	"flag"
 	"fmt"
 	"log"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/jtaleric/k8s-io/pkg/config"
+	"github.com/jtaleric/k8s-io/pkg/diagnostics"
+	"github.com/jtaleric/k8s-io/pkg/forensics"
+	"github.com/jtaleric/k8s-io/pkg/hooks"
 	"github.com/jtaleric/k8s-io/pkg/kubernetes"
+	"github.com/jtaleric/k8s-io/pkg/logmux"
+	"github.com/jtaleric/k8s-io/pkg/metrics"
+	"github.com/jtaleric/k8s-io/pkg/preflight"
+	"github.com/jtaleric/k8s-io/pkg/prometheus"
+	"github.com/jtaleric/k8s-io/pkg/sinks"
+	"github.com/jtaleric/k8s-io/pkg/status"
+	"github.com/jtaleric/k8s-io/pkg/timeline"
+	"github.com/jtaleric/k8s-io/pkg/units"
 	"github.com/jtaleric/k8s-io/pkg/workloads"
 )
 
 func main() {
+	// Installed as kubectl-k8sio, the arguments follow the kubectl plugin conventions
+	if isKubectlPlugin(os.Args[0]) {
+		os.Args = translatePluginArgs(os.Args)
+	}
+
+	// Subcommands that only inspect previous runs
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "history":
+			runHistoryCommand(os.Args[2:])
+			return
+		case "compare":
+			runCompareCommand(os.Args[2:])
+			return
+		case "status":
+			runStatusCommand(os.Args[2:])
+			return
+		case "tui":
+			runTUICommand(os.Args[2:])
+			return
+		case "plan":
+			runPlanCommand(os.Args[2:])
+			return
+		case "apply-plan":
+			runApplyPlanCommand(os.Args[2:])
+			return
+		case "vm-overhead":
+			runVMOverheadCommand(os.Args[2:])
+			return
+		case "cache-study":
+			runCacheStudyCommand(os.Args[2:])
+			return
+		case "soak":
+			runSoakCommand(os.Args[2:])
+			return
+		case "results-server":
+			runResultsServerCommand(os.Args[2:])
+			return
+		case "upload":
+			runUploadCommand(os.Args[2:])
+			return
+		case "bundle":
+			runBundleCommand(os.Args[2:])
+			return
+		case "import":
+			runImportCommand(os.Args[2:])
+			return
+		case "generate":
+			runGenerateCommand(os.Args[2:])
+			return
+		case "compat":
+			runCompatCommand(os.Args[2:])
+			return
+		case "explain":
+			runExplainCommand(os.Args[2:])
+			return
+		case "timeline":
+			runTimelineCommand(os.Args[2:])
+			return
+		case "purge-auth":
+			runPurgeAuthCommand(os.Args[2:])
+			return
+		case "flush-results":
+			runFlushResultsCommand(os.Args[2:])
+			return
+		case "completion":
+			runCompletionCommand(os.Args[2:])
+			return
+		case "__complete":
+			runCompleteCommand(os.Args[2:])
+			return
+		}
+	}
+
 	var (
-		configFile = flag.String("config", "config.yaml", "Path to configuration file")
-		cleanup    = flag.Bool("cleanup", false, "Cleanup resources and exit")
-		dryRun     = flag.Bool("dry-run", false, "Generate manifests without applying them")
+		configFile     = flag.String("config", "config.yaml", "Path to configuration file")
+		cleanup        = flag.Bool("cleanup", false, "Cleanup resources and exit")
+		dryRun         = flag.Bool("dry-run", false, "Generate manifests without applying them")
+		junitFile      = flag.String("junit", "", "Write a JUnit XML report to this file")
+		noOverwrite    = flag.Bool("no-overwrite", false, "Fail instead of updating existing resources that differ from the manifests")
+		debugTemplates = flag.Bool("debug-templates", false, "Write template contexts and rendered manifests to the artifacts directory")
+		debugAddr      = flag.String("debug-addr", "", "Serve pprof and runtime diagnostics on this address, e.g. localhost:6060")
+		kubeconfig     = flag.String("kubeconfig", "", "Path to the kubeconfig file (defaults to KUBECONFIG or ~/.kube/config)")
+		kubeContext    = flag.String("context", "", "Kubeconfig context to use")
+		namespace      = flag.String("namespace", "", "Namespace of the benchmark (overrides the configuration)")
+		unitsMode      = flag.String("units", "", "Units of the results tables: raw (MiB/s, usec) or human (overrides the configuration)")
+		follow         = flag.Bool("follow", false, "Stream the logs of all benchmark pods, prefixed with the pod name")
+		anonymize      = flag.Bool("anonymize", false, "Replace hostnames with pseudonyms in the results tables and reports")
 	)
 	flag.Parse()
 
@@ -24,32 +125,48 @@ func main() {
 	// Load configuration
 	cfg, err := config.LoadConfig(*configFile)
 	if err != nil {
-		log.Fatalf("Failed to load configuration: %v", err)
+		exit(nil, status.Errorf(status.ReasonConfig, "failed to load configuration: %w", err))
 	}
 
 	log.Printf("Loaded configuration for workload: %s", cfg.Workload.Name)
 
-	// Create Kubernetes client
-	k8sClient, err := kubernetes.NewClient()
-	if err != nil {
-		log.Fatalf("Failed to create Kubernetes client: %v", err)
+	if *junitFile != "" {
+		cfg.JUnitFile = *junitFile
 	}
-
-	// Create workload factory and workload
-	factory := workloads.NewFactory(k8sClient, cfg)
-	workload, err := factory.CreateWorkload()
-	if err != nil {
-		log.Fatalf("Failed to create workload: %v", err)
+	if *noOverwrite {
+		cfg.NoOverwrite = true
+	}
+	if *debugTemplates {
+		cfg.DebugTemplates = true
+	}
+	if *anonymize {
+		cfg.Anonymize = true
+	}
+	if *namespace != "" {
+		cfg.Namespace = *namespace
 	}
+	if *unitsMode != "" {
+		if _, err := units.ParseMode(*unitsMode); err != nil {
+			exit(cfg, status.Errorf(status.ReasonConfig, "%w", err))
+		}
+		cfg.Units = *unitsMode
+	}
+	units.SetFormat(cfg.NumberFormat())
 
-	log.Printf("Created %s workload", workload.GetName())
+	k8sClient, workload := createWorkload(cfg, *kubeconfig, *kubeContext)
+
+	if *debugAddr != "" {
+		if err := diagnostics.Start(*debugAddr, cfg, k8sClient); err != nil {
+			exit(cfg, status.Errorf(status.ReasonConfig, "failed to start diagnostics server: %w", err))
+		}
+	}
 
 	// Handle cleanup
 	if *cleanup {
 		log.Println("Cleaning up resources...")
 		ctx := context.Background()
 		if err := workload.Cleanup(ctx); err != nil {
-			log.Fatalf("Cleanup failed: %v", err)
+			exit(cfg, fmt.Errorf("cleanup failed: %w", err))
 		}
 		log.Println("Cleanup completed successfully!")
 		return
@@ -60,7 +177,7 @@ func main() {
 		log.Println("Generating manifests (dry-run mode)...")
 		manifests, err := workload.GenerateManifests()
 		if err != nil {
-			log.Fatalf("Failed to generate manifests: %v", err)
+			exit(cfg, status.Errorf(status.ReasonConfig, "failed to generate manifests: %w", err))
 		}
 
 		fmt.Println("\n=== Generated Manifests ===")
@@ -68,28 +185,379 @@ func main() {
 			fmt.Printf("\n--- %s ---\n", name)
 			fmt.Println(manifest)
 		}
+
+		est, err := workload.Estimate()
+		if err != nil {
+			exit(cfg, status.Errorf(status.ReasonConfig, "failed to estimate the run: %w", err))
+		}
+		est.Print(os.Stdout)
 		return
 	}
 
-	// Ensure namespace exists (only for actual benchmark runs)
-	ctx := context.Background()
-	exists, err := k8sClient.NamespaceExists(ctx, cfg.Namespace)
+	runWorkload(cfg, k8sClient, workload, *follow)
+}
+
+// createWorkload creates the Kubernetes client and the validated workload of a configuration
+func createWorkload(cfg *config.Config, kubeconfig, kubeContext string) (*kubernetes.Client, workloads.Workload) {
+	// Create Kubernetes client
+	k8sClient, err := kubernetes.NewClientForContext(kubeconfig, kubeContext)
+	if err != nil {
+		exit(cfg, status.Errorf(status.ReasonPreflight, "failed to create Kubernetes client: %w", err))
+	}
+	k8sClient.SetNoOverwrite(cfg.NoOverwrite)
+	k8sClient.SetLabels(cfg.ResourceLabels())
+	k8sClient.SetAnnotations(cfg.ExtraAnnotations)
+	k8sClient.SetRunUUID(cfg.UUID)
+
+	// Metrics are only collected after the benchmark, so catch mistakes such as invalid
+	// profiles before it starts
+	if _, err := metrics.New(cfg, k8sClient); err != nil {
+		exit(cfg, status.Errorf(status.ReasonConfig, "invalid metrics capture: %w", err))
+	}
+	if _, err := sinks.New(cfg); err != nil {
+		exit(cfg, status.Errorf(status.ReasonConfig, "invalid sinks: %w", err))
+	}
+
+	// Create workload factory and workload
+	factory := workloads.NewFactory(k8sClient, cfg)
+	workload, err := factory.CreateWorkload()
 	if err != nil {
-		log.Fatalf("Failed to check if namespace exists: %v", err)
+		exit(cfg, status.Errorf(status.ReasonConfig, "failed to create workload: %w", err))
 	}
 
-	if !exists {
-		log.Printf("Creating namespace: %s", cfg.Namespace)
-		if err := k8sClient.CreateNamespace(ctx, cfg.Namespace); err != nil {
-			log.Fatalf("Failed to create namespace: %v", err)
+	if err := workload.Validate(); err != nil {
+		exit(cfg, status.Errorf(status.ReasonConfig, "invalid workload configuration: %w", err))
+	}
+
+	log.Printf("Created %s workload", workload.GetName())
+	return k8sClient, workload
+}
+
+// runWorkload prepares the namespace, runs the benchmark and exits with its status
+func runWorkload(cfg *config.Config, k8sClient *kubernetes.Client, workload workloads.Workload, follow bool) {
+	err := executeWorkload(cfg, k8sClient, workload, follow)
+	collectResults(context.Background(), workload)
+	if err != nil {
+		exit(cfg, err)
+	}
+
+	log.Println("Benchmark completed successfully!")
+	exit(cfg, nil)
+}
+
+// executeWorkload prepares the namespace and runs the benchmark with its hooks
+func executeWorkload(cfg *config.Config, k8sClient *kubernetes.Client, workload workloads.Workload, follow bool) error {
+	ctx := context.Background()
+	recordOwner(ctx, k8sClient, cfg)
+
+	// The time spent queued or waiting for the start gate is not part of the run
+	if cfg.Queue != nil {
+		leaveQueue, err := waitInQueue(ctx, k8sClient, cfg, workload)
+		if err != nil {
+			return err
+		}
+		defer leaveQueue()
+	}
+	if cfg.StartGate != nil {
+		if err := waitForStartGate(ctx, k8sClient, cfg); err != nil {
+			return err
+		}
+	}
+
+	cfg.Clock = timeline.NewClock()
+	log.Printf("Run %s started at %s (local timezone %s)", cfg.UUID, timeline.FormatUTC(cfg.Clock.Start), cfg.Clock.LocalTimezone)
+	if err := timeline.OpenEvents(cfg.RunArtifactsDir()); err != nil {
+		log.Printf("Warning: the run has no event log: %v", err)
+	}
+
+	// Ensure the namespaces exist (only for actual benchmark runs)
+	for _, ns := range workload.Namespaces() {
+		exists, err := k8sClient.NamespaceExists(ctx, ns)
+		if err != nil {
+			return status.Errorf(status.ReasonPreflight, "failed to check if namespace exists: %w", err)
+		}
+
+		if !exists {
+			log.Printf("Creating namespace: %s", ns)
+			labels, annotations := cfg.NamespaceMetadata()
+			if err := k8sClient.CreateNamespace(ctx, ns, labels, annotations); err != nil {
+				return status.Errorf(status.ReasonPreflight, "failed to create namespace: %w", err)
+			}
+		}
+	}
+
+	if cfg.AdmissionCheck == nil || *cfg.AdmissionCheck {
+		if err := checkAdmission(ctx, k8sClient, cfg, workload); err != nil {
+			return err
+		}
+	}
+	if cfg.ClockSkew != nil {
+		checkClockSkew(ctx, k8sClient, cfg)
+	}
+	recordNodeTimezones(ctx, k8sClient, cfg)
+	recordRunEnvironment(ctx, k8sClient, cfg, workload)
+
+	stopFollowing := func() {}
+	if follow {
+		stopFollowing = followLogs(ctx, k8sClient, cfg, workload.Namespaces())
+	}
+
+	runner := hooks.NewRunner(k8sClient, cfg)
+	if cfg.Hooks != nil {
+		if err := runner.Run(ctx, hooks.StagePreRun, cfg.Hooks.PreRun, nil); err != nil {
+			stopFollowing()
+			return status.Errorf(status.ReasonPreflight, "%w", err)
 		}
 	}
 
 	// Run the benchmark
 	log.Printf("Starting %s benchmark...", workload.GetName())
-	if err := workload.RunBenchmark(ctx); err != nil {
-		log.Fatalf("Benchmark failed: %v", err)
+	stopProgress := logProgress(ctx, workload)
+	err := workload.RunBenchmark(ctx)
+	stopProgress()
+	if err != nil {
+		// Name the pods that were OOM killed or evicted instead of a generic job failure
+		for _, report := range forensics.Capture(ctx, k8sClient, cfg, workload.Namespaces()) {
+			err = fmt.Errorf("%w; pod %s was %s", err, report.Pod, report.Reason)
+		}
 	}
 
-	log.Println("Benchmark completed successfully!")
+	// post_run hooks clean up after failed runs too, so they only add warnings
+	if cfg.Hooks != nil {
+		result := "success"
+		if err != nil {
+			result = "failure"
+		}
+		if herr := runner.Run(ctx, hooks.StagePostRun, cfg.Hooks.PostRun, map[string]string{"K8SIO_RESULT": result}); herr != nil {
+			log.Printf("Warning: %v", herr)
+			warnings = append(warnings, herr.Error())
+		}
+	}
+	stopFollowing()
+
+	if err != nil {
+		return fmt.Errorf("benchmark failed: %w", err)
+	}
+	return nil
+}
+
+// followLogs streams the logs of the pods of the run in its namespaces, including hook jobs,
+// until the returned function is called
+func followLogs(ctx context.Context, k8sClient *kubernetes.Client, cfg *config.Config, namespaces []string) func() {
+	ctx, cancel := context.WithCancel(ctx)
+	var wg sync.WaitGroup
+	for _, ns := range namespaces {
+		wg.Add(1)
+		go func(ns string) {
+			defer wg.Done()
+			logmux.New(k8sClient, ns, "benchmark-uuid="+cfg.UUID, os.Stdout).Run(ctx)
+		}(ns)
+	}
+
+	return func() {
+		cancel()
+		wg.Wait()
+	}
+}
+
+// warnings are reported in the final status line of the run
+var warnings []string
+
+// runMetrics are the metrics of the run reported in the final status line
+var runMetrics map[string]float64
+
+// progressInterval is how often the progress of a running benchmark is logged
+const progressInterval = 5 * time.Minute
+
+// logProgress logs the phase and progress of the benchmark every progressInterval until the
+// returned function is called
+func logProgress(ctx context.Context, workload workloads.Workload) func() {
+	ctx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(progressInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				phase, percent := workload.Progress(ctx)
+				log.Printf("Benchmark progress: %s phase, %.0f%% of the expected duration", phase, percent)
+			}
+		}
+	}()
+
+	return func() {
+		cancel()
+		<-done
+	}
+}
+
+// collectResults logs the results of the run, also of a failed run, and reports its metrics
+// in the final status line
+func collectResults(ctx context.Context, workload workloads.Workload) {
+	run, err := workload.CollectResults(ctx)
+	if err != nil {
+		log.Printf("Warning: failed to collect the results: %v", err)
+		warnings = append(warnings, err.Error())
+		return
+	}
+	if run.Samples == 0 {
+		return
+	}
+
+	log.Printf("Results of run %s: %d sample(s), %d threshold violation(s)", run.UUID, run.Samples, len(run.Violations))
+	for _, name := range run.MetricNames() {
+		log.Printf("  %-40s %.2f", name, run.Metrics[name])
+	}
+	runMetrics = run.Metrics
+}
+
+// checkAdmission server-side dry-runs the first pod of the manifests, so a run that admission
+// webhook policies reject fails with the violated policies before anything is deployed.
+// Dry-runs that fail for other reasons, such as webhooks without dry-run support, only warn.
+func checkAdmission(ctx context.Context, k8sClient *kubernetes.Client, cfg *config.Config, workload workloads.Workload) error {
+	manifests, err := workload.GenerateManifests()
+	if err != nil {
+		return status.Errorf(status.ReasonConfig, "failed to generate manifests: %w", err)
+	}
+	names := make([]string, 0, len(manifests))
+	for name := range manifests {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		found, err := k8sClient.DryRunPod(ctx, manifests[name], cfg.Namespace)
+		if !found && err == nil {
+			continue
+		}
+
+		var rejection *kubernetes.AdmissionRejection
+		if errors.As(err, &rejection) {
+			log.Printf("Admission rejected the pod of %s:", name)
+			for _, line := range strings.Split(strings.TrimSpace(rejection.Message), "\n") {
+				if line = strings.TrimSpace(line); line != "" {
+					log.Printf("  %s", line)
+				}
+			}
+			return status.Errorf(status.ReasonPreflight, "%w", err)
+		}
+		if err != nil {
+			log.Printf("Warning: skipping admission check: %v", err)
+			warnings = append(warnings, "admission check skipped: "+err.Error())
+			return nil
+		}
+		log.Printf("Admission check passed for the pod of %s", name)
+		return nil
+	}
+
+	log.Printf("Skipping admission check, the manifests of the run have no pods")
+	return nil
+}
+
+// checkClockSkew warns when node clocks are skewed, since cross-pod latency
+// correlation and Prometheus windows depend on synchronized clocks
+func checkClockSkew(ctx context.Context, k8sClient *kubernetes.Client, cfg *config.Config) {
+	promInfo, err := k8sClient.DiscoverPrometheusWithConfig(ctx, cfg.Prometheus)
+	if err != nil || !promInfo.Found {
+		log.Printf("Warning: skipping clock skew check, Prometheus not found")
+		return
+	}
+
+	skew, err := preflight.CheckClockSkew(ctx, prometheus.NewClient(promInfo.URL, promInfo.Token), cfg.ClockSkew.MaxOffsetMS)
+	if err != nil {
+		log.Printf("Warning: clock skew check failed: %v", err)
+		return
+	}
+
+	for _, w := range skew {
+		log.Printf("Warning: %s", w)
+	}
+	if len(skew) == 0 {
+		log.Printf("Node clocks are within %.1fms", cfg.ClockSkew.MaxOffsetMS)
+	}
+	warnings = append(warnings, skew...)
+}
+
+// recordOwner records the user requesting the run with its results. Clusters older than
+// Kubernetes 1.28 may not serve the review, so the run continues without an owner.
+func recordOwner(ctx context.Context, k8sClient *kubernetes.Client, cfg *config.Config) {
+	owner, err := k8sClient.WhoAmI(ctx)
+	if err != nil {
+		log.Printf("Warning: the run has no owner: %v", err)
+		return
+	}
+
+	cfg.Owner = owner
+	if cfg.Metadata == nil {
+		cfg.Metadata = make(map[string]string)
+	}
+	cfg.Metadata["owner"] = owner
+	log.Printf("Run %s is owned by %s", cfg.UUID, owner)
+}
+
+// recordNodeTimezones adds the node timezones reported by node-exporter to the run clock.
+// They are only informational, so a cluster without Prometheus just leaves them out.
+func recordNodeTimezones(ctx context.Context, k8sClient *kubernetes.Client, cfg *config.Config) {
+	promInfo, err := k8sClient.DiscoverPrometheusWithConfig(ctx, cfg.Prometheus)
+	if err != nil || !promInfo.Found {
+		return
+	}
+
+	timezones, err := preflight.NodeTimezones(ctx, prometheus.NewClient(promInfo.URL, promInfo.Token))
+	if err != nil {
+		log.Printf("Warning: failed to get node timezones: %v", err)
+		return
+	}
+	cfg.Clock.NodeTimezones = timezones
+}
+
+// uploadResults uploads the run to the results server configured in history.server
+func uploadResults(cfg *config.Config) {
+	if cfg.History == nil || cfg.History.Server == "" {
+		return
+	}
+	if err := uploadRun(cfg.History.Server, os.Getenv(resultsTokenEnv), cfg.History.Path, cfg.UUID, cfg.RunArtifactsDir()); err != nil {
+		log.Printf("Warning: %v", err)
+		warnings = append(warnings, err.Error())
+	}
+}
+
+// exit logs the error, prints the final JSON status line and exits with the matching exit code
+func exit(cfg *config.Config, err error) {
+	if err != nil {
+		log.Printf("Error: %v", err)
+		timeline.Record(timeline.EventFailure, "run", "%v", err)
+	}
+	timeline.CloseEvents()
+
+	s := status.New(err)
+	if cfg != nil {
+		s.UUID = cfg.UUID
+		s.Workload = cfg.Workload.Name
+		s.Owner = cfg.Owner
+	}
+	if cfg != nil && cfg.Clock != nil {
+		cfg.Clock.Stop()
+		s.Start = timeline.FormatUTC(cfg.Clock.Start)
+		s.End = timeline.FormatUTC(cfg.Clock.End)
+		if werr := cfg.Clock.Write(cfg.RunArtifactsDir()); werr != nil {
+			log.Printf("Warning: %v", werr)
+		}
+	}
+	// Upload once every artifact of the run, including the clock, is written
+	if cfg != nil && cfg.Clock != nil {
+		uploadResults(cfg)
+	}
+	s.Metrics = runMetrics
+	s.Warnings = warnings
+
+	if werr := s.Write(os.Stdout); werr != nil {
+		log.Printf("Warning: %v", werr)
+	}
+
+	os.Exit(s.ExitCode)
 }
@@ -0,0 +1,173 @@
+package anonymize
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// KeyEnv is the environment variable with the key of the pseudonyms. With a key, the same
+// name gets the same pseudonym in every run; without, the pseudonyms are keyed by the run.
+const KeyEnv = "K8SIO_ANONYMIZE_KEY"
+
+// Kinds of names, used as the prefix of their pseudonyms
+const (
+	KindNode    = "node"
+	KindHost    = "host"
+	KindCluster = "cluster"
+	KindIP      = "ip"
+)
+
+var (
+	ipv4Pattern = regexp.MustCompile(`\b(?:\d{1,3}\.){3}\d{1,3}\b`)
+	ipv6Pattern = regexp.MustCompile(`[0-9A-Fa-f]{0,4}(?::[0-9A-Fa-f]{0,4}){2,7}`)
+
+	// JSON fields naming nodes and hosts, learned from the documents being anonymized
+	nodeFieldPattern = regexp.MustCompile(`"(?:nodeName|node_name|node)"\s*:\s*"([^"]+)"`)
+	hostFieldPattern = regexp.MustCompile(`"(?:hostname|hostName|host_name)"\s*:\s*"([^"]+)"`)
+)
+
+// Anonymizer replaces hostnames, node names, cluster names and IP addresses with stable
+// pseudonyms, for results shared outside the team that ran them
+type Anonymizer struct {
+	key []byte
+
+	mu       sync.Mutex
+	names    map[string]string // Known name to pseudonym
+	replacer *strings.Replacer // Replaces the known names, rebuilt when a name is added
+}
+
+// New creates an anonymizer keyed by the K8SIO_ANONYMIZE_KEY environment variable, or by
+// the given run UUID when it is not set
+func New(uuid string) *Anonymizer {
+	key := os.Getenv(KeyEnv)
+	if key == "" {
+		key = uuid
+	}
+	return &Anonymizer{key: []byte(key), names: make(map[string]string)}
+}
+
+// Pseudonym returns the pseudonym of a name, such as node-1a2b3c4d
+func (a *Anonymizer) Pseudonym(kind, name string) string {
+	if name == "" {
+		return ""
+	}
+	mac := hmac.New(sha256.New, a.key)
+	mac.Write([]byte(kind + "/" + name))
+	return kind + "-" + hex.EncodeToString(mac.Sum(nil))[:8]
+}
+
+// Add registers names, so String replaces them wherever they appear
+func (a *Anonymizer) Add(kind string, names ...string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for _, name := range names {
+		if name == "" || a.names[name] != "" {
+			continue
+		}
+		a.names[name] = a.Pseudonym(kind, name)
+		a.replacer = nil
+	}
+}
+
+// Only returns an anonymizer with the same key that only replaces the registered names of
+// the given kinds, and IP addresses
+func (a *Anonymizer) Only(kinds ...string) *Anonymizer {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	only := &Anonymizer{key: a.key, names: make(map[string]string)}
+	for name, pseudonym := range a.names {
+		for _, kind := range kinds {
+			if strings.HasPrefix(pseudonym, kind+"-") {
+				only.names[name] = pseudonym
+			}
+		}
+	}
+	return only
+}
+
+// AddServer registers the host of a Kubernetes API server URL as the cluster name. OpenShift
+// API servers are api.<cluster>.<domain>, so the cluster domain of routes is covered too.
+func (a *Anonymizer) AddServer(server string) {
+	host := server
+	if i := strings.Index(host, "://"); i >= 0 {
+		host = host[i+3:]
+	}
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.TrimSuffix(host, "/")
+	if host == "" || net.ParseIP(host) != nil {
+		return
+	}
+	a.Add(KindCluster, host)
+	if domain := strings.TrimPrefix(host, "api."); domain != host {
+		a.Add(KindCluster, domain)
+	}
+}
+
+// Learn registers the node and host names found in the JSON fields of a document
+func (a *Anonymizer) Learn(data []byte) {
+	for _, m := range nodeFieldPattern.FindAllSubmatch(data, -1) {
+		a.Add(KindNode, string(m[1]))
+	}
+	for _, m := range hostFieldPattern.FindAllSubmatch(data, -1) {
+		a.Add(KindHost, string(m[1]))
+	}
+}
+
+// String replaces the registered names and every IP address in a text. Loopback and
+// unspecified addresses reveal nothing and are kept.
+func (a *Anonymizer) String(s string) string {
+	s = a.namesReplacer().Replace(s)
+	s = ipv4Pattern.ReplaceAllStringFunc(s, a.ip)
+	return ipv6Pattern.ReplaceAllStringFunc(s, a.ip)
+}
+
+// Bytes is String for file contents
+func (a *Anonymizer) Bytes(data []byte) []byte {
+	return []byte(a.String(string(data)))
+}
+
+// ip returns the pseudonym of an address, or the match itself when it is not one
+func (a *Anonymizer) ip(match string) string {
+	ip := net.ParseIP(match)
+	if ip == nil || ip.IsLoopback() || ip.IsUnspecified() {
+		return match
+	}
+	return a.Pseudonym(KindIP, ip.String())
+}
+
+// namesReplacer returns the replacer of the registered names. The longest names come first,
+// so a name is replaced before any shorter name it contains.
+func (a *Anonymizer) namesReplacer() *strings.Replacer {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.replacer != nil {
+		return a.replacer
+	}
+
+	names := make([]string, 0, len(a.names))
+	for name := range a.names {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if len(names[i]) != len(names[j]) {
+			return len(names[i]) > len(names[j])
+		}
+		return names[i] < names[j]
+	})
+
+	pairs := make([]string, 0, 2*len(names))
+	for _, name := range names {
+		pairs = append(pairs, name, a.names[name])
+	}
+	a.replacer = strings.NewReplacer(pairs...)
+	return a.replacer
+}
@@ -0,0 +1,291 @@
+package bundle
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+	"runtime/debug"
+	"sort"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/jtaleric/k8s-io/pkg/anonymize"
+	"github.com/jtaleric/k8s-io/pkg/config"
+	"github.com/jtaleric/k8s-io/pkg/history"
+	"github.com/jtaleric/k8s-io/pkg/kubernetes"
+	"github.com/jtaleric/k8s-io/pkg/plan"
+)
+
+// Files every run writes to its artifacts directory for the bundle
+const (
+	PlanFile        = "plan.yaml"        // Effective configuration and rendered manifests, see apply-plan
+	EnvironmentFile = "environment.json" // k8s-io build and cluster the run ran on
+)
+
+// Version is the build of k8s-io
+type Version struct {
+	Module    string `json:"module"`             // Module version, "(devel)" for local builds
+	Revision  string `json:"revision,omitempty"` // VCS commit the binary was built from
+	Time      string `json:"time,omitempty"`     // Commit time
+	Modified  bool   `json:"modified,omitempty"` // Built from a tree with uncommitted changes
+	GoVersion string `json:"go_version"`
+}
+
+// ToolVersion returns the version of the running k8s-io binary from its build info
+func ToolVersion() Version {
+	v := Version{Module: "unknown", GoVersion: runtime.Version()}
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return v
+	}
+	v.Module = info.Main.Version
+	for _, setting := range info.Settings {
+		switch setting.Key {
+		case "vcs.revision":
+			v.Revision = setting.Value
+		case "vcs.time":
+			v.Time = setting.Value
+		case "vcs.modified":
+			v.Modified = setting.Value == "true"
+		}
+	}
+	return v
+}
+
+// Environment is the k8s-io build and the cluster of a run
+type Environment struct {
+	Tool    Version                 `json:"tool"`
+	Cluster *kubernetes.ClusterInfo `json:"cluster,omitempty"`
+}
+
+// Write writes the environment to environment.json in the directory
+func (e Environment) Write(dir string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create artifacts directory: %w", err)
+	}
+
+	data, err := json.MarshalIndent(e, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal environment: %w", err)
+	}
+
+	filename := filepath.Join(dir, EnvironmentFile)
+	if err := os.WriteFile(filename, data, 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", filename, err)
+	}
+	return nil
+}
+
+// Manifest describes the content of a bundle, stored as bundle.json at its root
+type Manifest struct {
+	UUID       string    `json:"uuid"`
+	Created    time.Time `json:"created"`
+	Tool       Version   `json:"tool"`                 // k8s-io that created the bundle
+	Anonymized bool      `json:"anonymized,omitempty"` // Names and IPs replaced with pseudonyms
+	Files      []string  `json:"files"`
+}
+
+// Options select what goes into a bundle
+type Options struct {
+	UUID         string
+	Record       *history.Record // History record of the run, nil when it was not recorded
+	ArtifactsDir string          // Artifacts directory of the run
+	Extra        []string        // Additional files, such as CSV exports, stored under results/
+
+	// Replaces the node, host and cluster names and IPs in the names and contents of every
+	// file, nil to keep them
+	Anonymizer *anonymize.Anonymizer
+}
+
+// Create writes a gzipped tarball with the artifacts of a run, its history record and the
+// extra files, all under a k8s-io-<uuid>/ directory. It returns the bundle manifest.
+func Create(w io.Writer, opts Options) (*Manifest, error) {
+	m := &Manifest{UUID: opts.UUID, Created: time.Now().UTC(), Tool: ToolVersion(), Anonymized: opts.Anonymizer != nil}
+
+	type entry struct {
+		name string
+		data []byte
+	}
+	var entries []entry
+	addFile := func(name, filename string) error {
+		data, err := os.ReadFile(filename)
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", filename, err)
+		}
+		entries = append(entries, entry{name, data})
+		return nil
+	}
+
+	var files []string
+	err := filepath.WalkDir(opts.ArtifactsDir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() {
+			files = append(files, p)
+		}
+		return nil
+	})
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to list the artifacts of run %s: %w", opts.UUID, err)
+	}
+	sort.Strings(files)
+	for _, filename := range files {
+		// Names in binary Parquet files cannot be replaced without rewriting them
+		if opts.Anonymizer != nil && filepath.Ext(filename) == ".parquet" {
+			continue
+		}
+		rel, err := filepath.Rel(opts.ArtifactsDir, filename)
+		if err != nil {
+			return nil, err
+		}
+		if err := addFile(path.Join("artifacts", filepath.ToSlash(rel)), filename); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, filename := range opts.Extra {
+		if err := addFile(path.Join("results", filepath.Base(filename)), filename); err != nil {
+			return nil, err
+		}
+	}
+
+	if opts.Record != nil {
+		data, err := json.MarshalIndent(opts.Record, "", "  ")
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal history record: %w", err)
+		}
+		entries = append(entries, entry{"history.json", data})
+	}
+
+	// Every name has to be known before any file is anonymized
+	if a := opts.Anonymizer; a != nil {
+		for _, e := range entries {
+			learn(a, e.name, e.data)
+		}
+		for i, e := range entries {
+			entries[i].name = a.String(e.name)
+			if path.Base(e.name) == PlanFile {
+				data, err := anonymizePlan(a, e.data)
+				if err != nil {
+					return nil, err
+				}
+				entries[i].data = data
+				continue
+			}
+			entries[i].data = a.Bytes(e.data)
+		}
+	}
+
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+	root := "k8s-io-" + opts.UUID
+	add := func(name string, data []byte) error {
+		hdr := &tar.Header{
+			Name:    path.Join(root, name),
+			Mode:    0644,
+			Size:    int64(len(data)),
+			ModTime: m.Created,
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if _, err := tw.Write(data); err != nil {
+			return err
+		}
+		m.Files = append(m.Files, name)
+		return nil
+	}
+
+	for _, e := range entries {
+		if err := add(e.name, e.data); err != nil {
+			return nil, err
+		}
+	}
+
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal bundle manifest: %w", err)
+	}
+	if err := add("bundle.json", data); err != nil {
+		return nil, err
+	}
+
+	if err := tw.Close(); err != nil {
+		return nil, err
+	}
+	if err := gz.Close(); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// learn registers the names a bundle file reveals: the nodes and API server of the
+// environment, the cluster name of the plan and the hostnames of JSON documents and CSV
+// exports
+func learn(a *anonymize.Anonymizer, name string, data []byte) {
+	a.Learn(data)
+
+	switch {
+	case path.Base(name) == EnvironmentFile:
+		var env Environment
+		if json.Unmarshal(data, &env) != nil || env.Cluster == nil {
+			return
+		}
+		a.AddServer(env.Cluster.Server)
+		for _, node := range env.Cluster.Nodes {
+			a.Add(anonymize.KindNode, node.Name)
+		}
+	case path.Base(name) == PlanFile:
+		p, err := plan.Parse(data)
+		if err != nil {
+			return
+		}
+		var cfg config.Config
+		if yaml.Unmarshal([]byte(p.Config), &cfg) == nil && cfg.ClusterName != "default-cluster" {
+			a.Add(anonymize.KindCluster, cfg.ClusterName)
+		}
+	case strings.HasSuffix(name, ".csv"):
+		// Exports are delimited by csv_delimiter or the default of their locale
+		reader := csv.NewReader(bytes.NewReader(data))
+		header, _, _ := bytes.Cut(data, []byte("\n"))
+		reader.Comma = config.SniffCSVDelimiter(string(header))
+		rows, err := reader.ReadAll()
+		if err != nil || len(rows) == 0 {
+			return
+		}
+		for col, header := range rows[0] {
+			if header != "Hostname" {
+				continue
+			}
+			for _, row := range rows[1:] {
+				if col < len(row) {
+					a.Add(anonymize.KindHost, row[col])
+				}
+			}
+		}
+	}
+}
+
+// anonymizePlan anonymizes the configuration and manifests of a plan with updated digests.
+// Hostnames are pod names the templates generate, not names from the configuration, so
+// they are kept and apply-plan still verifies the anonymized plan.
+func anonymizePlan(a *anonymize.Anonymizer, data []byte) ([]byte, error) {
+	p, err := plan.Parse(data)
+	if err != nil {
+		return a.Bytes(data), nil
+	}
+	p.Rewrite(a.Only(anonymize.KindNode, anonymize.KindCluster).String)
+	return p.Marshal()
+}
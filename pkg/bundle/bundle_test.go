@@ -0,0 +1,22 @@
+package bundle
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/jtaleric/k8s-io/pkg/anonymize"
+)
+
+// TestLearnCSV checks that the hostnames of CSV exports are learned with every csv_delimiter
+func TestLearnCSV(t *testing.T) {
+	for _, delimiter := range []string{",", ";", "\t", "|"} {
+		a := anonymize.New("0123abcd-0000-4000-8000-000000000000")
+		header := strings.Join([]string{"Test ID", "Sample", "Hostname", "Read IOPS"}, delimiter)
+		row := strings.Join([]string{"t", "1", "fio-server-1-benchmark", "1000"}, delimiter)
+		learn(a, "fio-results.csv", []byte(header+"\n"+row+"\n"))
+
+		if got := a.String("host fio-server-1-benchmark"); strings.Contains(got, "fio-server-1-benchmark") {
+			t.Errorf("hostname of a CSV delimited by %q was not learned: %s", delimiter, got)
+		}
+	}
+}
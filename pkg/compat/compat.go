@@ -0,0 +1,335 @@
+package compat
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/runtime/serializer/json"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/yaml"
+)
+
+// DefaultVersions are the Kubernetes versions manifests are checked against by default
+const DefaultVersions = "1.25-1.31"
+
+// SchemaVersion is the Kubernetes version of the API types k8s-io is built with, which
+// manifests are decoded with to find unknown fields
+var SchemaVersion = Version{1, 28}
+
+// Version is a Kubernetes minor version, such as 1.29
+type Version struct {
+	Major int
+	Minor int
+}
+
+func (v Version) String() string {
+	return fmt.Sprintf("%d.%d", v.Major, v.Minor)
+}
+
+// atLeast reports whether v is o or a later version
+func (v Version) atLeast(o Version) bool {
+	return v.Major > o.Major || (v.Major == o.Major && v.Minor >= o.Minor)
+}
+
+// ParseVersion parses a version such as 1.29, ignoring a leading v and a patch version
+func ParseVersion(s string) (Version, error) {
+	parts := strings.Split(strings.TrimPrefix(strings.TrimSpace(s), "v"), ".")
+	if len(parts) < 2 {
+		return Version{}, fmt.Errorf("invalid Kubernetes version %q, expected a version such as 1.29", s)
+	}
+	major, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return Version{}, fmt.Errorf("invalid Kubernetes version %q, expected a version such as 1.29", s)
+	}
+	minor, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return Version{}, fmt.Errorf("invalid Kubernetes version %q, expected a version such as 1.29", s)
+	}
+	return Version{major, minor}, nil
+}
+
+// ParseVersions parses a comma-separated list of versions and ranges, such as
+// "1.25-1.31" or "1.27,1.30"
+func ParseVersions(s string) ([]Version, error) {
+	var versions []Version
+	for _, item := range strings.Split(s, ",") {
+		first, last, isRange := strings.Cut(item, "-")
+		from, err := ParseVersion(first)
+		if err != nil {
+			return nil, err
+		}
+		to := from
+		if isRange {
+			if to, err = ParseVersion(last); err != nil {
+				return nil, err
+			}
+			if to.Major != from.Major || to.Minor < from.Minor {
+				return nil, fmt.Errorf("invalid Kubernetes version range %q", item)
+			}
+		}
+		for minor := from.Minor; minor <= to.Minor; minor++ {
+			versions = append(versions, Version{from.Major, minor})
+		}
+	}
+	return versions, nil
+}
+
+// rule is a part of the API that was deprecated in one Kubernetes version and removed, or is
+// no longer honored, in a later one. A zero version means it never was.
+type rule struct {
+	what        string
+	deprecated  Version
+	removed     Version
+	replacement string
+	// match returns the paths in a manifest that use the part of the API
+	match func(obj *unstructured.Unstructured) []string
+}
+
+// apiVersion matches resources of a kind, any kind when empty, served by a group version
+func apiVersion(groupVersion, kind string) func(*unstructured.Unstructured) []string {
+	return func(obj *unstructured.Unstructured) []string {
+		if obj.GetAPIVersion() == groupVersion && (kind == "" || obj.GetKind() == kind) {
+			return []string{"apiVersion"}
+		}
+		return nil
+	}
+}
+
+// annotation matches annotations of the resource and its pod template that are key, or start
+// with key when it ends with a slash
+func annotation(key string) func(*unstructured.Unstructured) []string {
+	return func(obj *unstructured.Unstructured) []string {
+		var paths []string
+		for _, prefix := range [][]string{{"metadata"}, {"spec", "template", "metadata"}} {
+			annotations, _, _ := unstructured.NestedStringMap(obj.Object, append(prefix, "annotations")...)
+			for name := range annotations {
+				if name == key || (strings.HasSuffix(key, "/") && strings.HasPrefix(name, key)) {
+					paths = append(paths, fmt.Sprintf("%s.annotations[%s]", strings.Join(prefix, "."), name))
+				}
+			}
+		}
+		return paths
+	}
+}
+
+// label matches a label key wherever a manifest uses it: labels, node selectors, affinity
+// expressions and topology keys
+func label(key string) func(*unstructured.Unstructured) []string {
+	return func(obj *unstructured.Unstructured) []string {
+		var paths []string
+		walk(obj.Object, "", func(path, field string, value interface{}) {
+			if field == key || ((field == "key" || field == "topologyKey") && value == key) {
+				paths = append(paths, path)
+			}
+		})
+		return paths
+	}
+}
+
+// volumeIndex matches the path of an item of a volumes list
+var volumeIndex = regexp.MustCompile(`(^|\.)volumes\[\d+\]$`)
+
+// volume matches volumes of an in-tree volume type
+func volume(volumeType string) func(*unstructured.Unstructured) []string {
+	return func(obj *unstructured.Unstructured) []string {
+		var paths []string
+		walk(obj.Object, "", func(path, field string, value interface{}) {
+			if field == volumeType && volumeIndex.MatchString(strings.TrimSuffix(path, "."+field)) {
+				paths = append(paths, path)
+			}
+		})
+		return paths
+	}
+}
+
+// walk calls fn for every field of a manifest with its path, such as
+// spec.template.spec.volumes[0].rbd
+func walk(value interface{}, path string, fn func(path, field string, value interface{})) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for field, child := range v {
+			childPath := field
+			if path != "" {
+				childPath = path + "." + field
+			}
+			fn(childPath, field, child)
+			walk(child, childPath, fn)
+		}
+	case []interface{}:
+		for i, child := range v {
+			walk(child, fmt.Sprintf("%s[%d]", path, i), fn)
+		}
+	}
+}
+
+// rules are the deprecations and removals that affect benchmark manifests: workload API
+// versions, pod annotations, node labels and in-tree volume types
+var rules = []rule{
+	{what: "extensions/v1beta1", removed: Version{1, 16}, replacement: "apps/v1, networking.k8s.io/v1 or policy/v1", match: apiVersion("extensions/v1beta1", "")},
+	{what: "apps/v1beta1", removed: Version{1, 16}, replacement: "apps/v1", match: apiVersion("apps/v1beta1", "")},
+	{what: "apps/v1beta2", removed: Version{1, 16}, replacement: "apps/v1", match: apiVersion("apps/v1beta2", "")},
+	{what: "batch/v1beta1 CronJob", deprecated: Version{1, 21}, removed: Version{1, 25}, replacement: "batch/v1", match: apiVersion("batch/v1beta1", "CronJob")},
+	{what: "policy/v1beta1 PodDisruptionBudget", deprecated: Version{1, 21}, removed: Version{1, 25}, replacement: "policy/v1", match: apiVersion("policy/v1beta1", "PodDisruptionBudget")},
+	{what: "policy/v1beta1 PodSecurityPolicy", deprecated: Version{1, 21}, removed: Version{1, 25}, replacement: "Pod Security admission", match: apiVersion("policy/v1beta1", "PodSecurityPolicy")},
+	{what: "autoscaling/v2beta1", deprecated: Version{1, 22}, removed: Version{1, 25}, replacement: "autoscaling/v2", match: apiVersion("autoscaling/v2beta1", "")},
+	{what: "autoscaling/v2beta2", deprecated: Version{1, 23}, removed: Version{1, 26}, replacement: "autoscaling/v2", match: apiVersion("autoscaling/v2beta2", "")},
+	{what: "node.k8s.io/v1beta1 RuntimeClass", deprecated: Version{1, 22}, removed: Version{1, 25}, replacement: "node.k8s.io/v1", match: apiVersion("node.k8s.io/v1beta1", "RuntimeClass")},
+	{what: "storage.k8s.io/v1beta1 StorageClass", removed: Version{1, 22}, replacement: "storage.k8s.io/v1", match: apiVersion("storage.k8s.io/v1beta1", "StorageClass")},
+	{what: "storage.k8s.io/v1beta1 CSIDriver", removed: Version{1, 22}, replacement: "storage.k8s.io/v1", match: apiVersion("storage.k8s.io/v1beta1", "CSIDriver")},
+	{what: "storage.k8s.io/v1beta1 CSIStorageCapacity", deprecated: Version{1, 24}, removed: Version{1, 27}, replacement: "storage.k8s.io/v1", match: apiVersion("storage.k8s.io/v1beta1", "CSIStorageCapacity")},
+	{what: "scheduling.k8s.io/v1beta1 PriorityClass", removed: Version{1, 22}, replacement: "scheduling.k8s.io/v1", match: apiVersion("scheduling.k8s.io/v1beta1", "PriorityClass")},
+	{what: "rbac.authorization.k8s.io/v1beta1", removed: Version{1, 22}, replacement: "rbac.authorization.k8s.io/v1", match: apiVersion("rbac.authorization.k8s.io/v1beta1", "")},
+
+	{what: "volume.beta.kubernetes.io/storage-class annotation", deprecated: Version{1, 6}, replacement: "spec.storageClassName", match: annotation("volume.beta.kubernetes.io/storage-class")},
+	{what: "seccomp.security.alpha.kubernetes.io/pod annotation", deprecated: Version{1, 19}, removed: Version{1, 27}, replacement: "securityContext.seccompProfile", match: annotation("seccomp.security.alpha.kubernetes.io/pod")},
+	{what: "container.seccomp.security.alpha.kubernetes.io annotation", deprecated: Version{1, 19}, removed: Version{1, 27}, replacement: "securityContext.seccompProfile", match: annotation("container.seccomp.security.alpha.kubernetes.io/")},
+	{what: "container.apparmor.security.beta.kubernetes.io annotation", deprecated: Version{1, 30}, replacement: "securityContext.appArmorProfile", match: annotation("container.apparmor.security.beta.kubernetes.io/")},
+	{what: "scheduler.alpha.kubernetes.io/critical-pod annotation", removed: Version{1, 16}, replacement: "priorityClassName", match: annotation("scheduler.alpha.kubernetes.io/critical-pod")},
+
+	{what: "beta.kubernetes.io/arch label", deprecated: Version{1, 14}, replacement: "kubernetes.io/arch", match: label("beta.kubernetes.io/arch")},
+	{what: "beta.kubernetes.io/os label", deprecated: Version{1, 14}, replacement: "kubernetes.io/os", match: label("beta.kubernetes.io/os")},
+	{what: "beta.kubernetes.io/instance-type label", deprecated: Version{1, 17}, replacement: "node.kubernetes.io/instance-type", match: label("beta.kubernetes.io/instance-type")},
+	{what: "failure-domain.beta.kubernetes.io/zone label", deprecated: Version{1, 17}, replacement: "topology.kubernetes.io/zone", match: label("failure-domain.beta.kubernetes.io/zone")},
+	{what: "failure-domain.beta.kubernetes.io/region label", deprecated: Version{1, 17}, replacement: "topology.kubernetes.io/region", match: label("failure-domain.beta.kubernetes.io/region")},
+
+	{what: "in-tree flocker volume", deprecated: Version{1, 22}, removed: Version{1, 25}, replacement: "a CSI driver", match: volume("flocker")},
+	{what: "in-tree quobyte volume", deprecated: Version{1, 22}, removed: Version{1, 25}, replacement: "a CSI driver", match: volume("quobyte")},
+	{what: "in-tree storageos volume", deprecated: Version{1, 22}, removed: Version{1, 25}, replacement: "a CSI driver", match: volume("storageos")},
+	{what: "in-tree glusterfs volume", deprecated: Version{1, 25}, removed: Version{1, 26}, replacement: "a CSI driver", match: volume("glusterfs")},
+	{what: "in-tree rbd volume", deprecated: Version{1, 28}, removed: Version{1, 31}, replacement: "the Ceph CSI driver", match: volume("rbd")},
+	{what: "in-tree cephfs volume", deprecated: Version{1, 28}, removed: Version{1, 31}, replacement: "the Ceph CSI driver", match: volume("cephfs")},
+}
+
+// Finding is a use of a deprecated or removed part of the API, or an unknown field
+type Finding struct {
+	Version  Version // Zero for unknown fields, which are found with SchemaVersion
+	Manifest string
+	Resource string // kind/name
+	Path     string
+	Removed  bool // Removed or no longer honored in Version, deprecated otherwise
+	Message  string
+}
+
+// Status returns removed, deprecated or unknown
+func (f Finding) Status() string {
+	switch {
+	case f.Version == Version{}:
+		return "unknown"
+	case f.Removed:
+		return "removed"
+	default:
+		return "deprecated"
+	}
+}
+
+// Check checks rendered manifests against Kubernetes versions. It returns, per version, the
+// deprecated and removed parts of the API the manifests use from a table of rules, and the
+// fields the API types of SchemaVersion do not know, sorted by version, manifest and path.
+// Manifests are not validated against the schemas of the other versions.
+func Check(manifests map[string]string, versions []Version) ([]Finding, error) {
+	strict := json.NewSerializerWithOptions(json.DefaultMetaFactory, scheme.Scheme, scheme.Scheme,
+		json.SerializerOptions{Yaml: true, Strict: true})
+
+	var findings []Finding
+	for name, manifest := range manifests {
+		for _, doc := range strings.Split(manifest, "\n---") {
+			if strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(doc), "---")) == "" {
+				continue
+			}
+			docFindings, err := checkDocument(strict, name, doc, versions)
+			if err != nil {
+				return nil, err
+			}
+			findings = append(findings, docFindings...)
+		}
+	}
+
+	sort.Slice(findings, func(i, j int) bool {
+		a, b := findings[i], findings[j]
+		if a.Version != b.Version {
+			return !a.Version.atLeast(b.Version)
+		}
+		if a.Manifest != b.Manifest {
+			return a.Manifest < b.Manifest
+		}
+		if a.Resource != b.Resource {
+			return a.Resource < b.Resource
+		}
+		return a.Path < b.Path
+	})
+	return findings, nil
+}
+
+// checkDocument checks a single object of a manifest
+func checkDocument(strict runtime.Decoder, name, doc string, versions []Version) ([]Finding, error) {
+	data, err := yaml.YAMLToJSON([]byte(doc))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode manifest %s: %w", name, err)
+	}
+	obj := &unstructured.Unstructured{}
+	if err := obj.UnmarshalJSON(data); err != nil {
+		return nil, fmt.Errorf("failed to decode manifest %s: %w", name, err)
+	}
+	resource := obj.GetKind() + "/" + obj.GetName()
+
+	var findings []Finding
+	for _, r := range rules {
+		paths := r.match(obj)
+		for _, v := range versions {
+			removed := r.removed != Version{} && v.atLeast(r.removed)
+			if !removed && !v.atLeast(r.deprecated) {
+				continue
+			}
+			for _, path := range paths {
+				findings = append(findings, Finding{
+					Version: v, Manifest: name, Resource: resource, Path: path, Removed: removed,
+					Message: message(r, removed),
+				})
+			}
+		}
+	}
+
+	// Only built-in kinds have types to decode with, custom resources such as KubeVirt VMs
+	// are left to their own validation
+	if !scheme.Scheme.Recognizes(schema.FromAPIVersionAndKind(obj.GetAPIVersion(), obj.GetKind())) {
+		return findings, nil
+	}
+	if _, _, err := strict.Decode([]byte(doc), nil, nil); err != nil {
+		strictErr, ok := runtime.AsStrictDecodingError(err)
+		if !ok {
+			return nil, fmt.Errorf("failed to decode manifest %s: %w", name, err)
+		}
+		for _, e := range strictErr.Errors() {
+			// The errors name the field in quotes, such as unknown field "spec.foo"
+			path := e.Error()
+			if _, quoted, ok := strings.Cut(path, `"`); ok {
+				path = strings.TrimSuffix(quoted, `"`)
+			}
+			findings = append(findings, Finding{
+				Manifest: name, Resource: resource, Path: path,
+				Message: fmt.Sprintf("%s of %s in Kubernetes %s", e, obj.GetKind(), SchemaVersion),
+			})
+		}
+	}
+	return findings, nil
+}
+
+// message describes a rule as it applies to a version
+func message(r rule, removed bool) string {
+	var msg string
+	switch {
+	case removed:
+		msg = fmt.Sprintf("%s removed in %s", r.what, r.removed)
+	case r.removed != Version{}:
+		msg = fmt.Sprintf("%s deprecated in %s, removed in %s", r.what, r.deprecated, r.removed)
+	default:
+		msg = fmt.Sprintf("%s deprecated in %s", r.what, r.deprecated)
+	}
+	return msg + ", use " + r.replacement
+}
@@ -0,0 +1,58 @@
+package compat
+
+import (
+	"testing"
+)
+
+// servers is a manifest of two documents, as the FIO server templates render them
+const servers = `---
+apiVersion: v1
+kind: Service
+metadata:
+  name: fio-server-1
+spec:
+  ports:
+  - port: 8765
+---
+apiVersion: v1
+kind: Pod
+metadata:
+  name: fio-server-1
+spec:
+  nodeSelector:
+    beta.kubernetes.io/arch: amd64
+  containers:
+  - name: fio
+    image: quay.io/cloud-bulldozer/fio
+    imagePullPolcy: Always
+`
+
+func TestCheckDocuments(t *testing.T) {
+	findings, err := Check(map[string]string{"servers": servers}, []Version{{1, 25}})
+	if err != nil {
+		t.Fatalf("Check: %v", err)
+	}
+
+	want := []struct {
+		status, resource, path string
+	}{
+		{"unknown", "Pod/fio-server-1", "spec.containers[0].imagePullPolcy"},
+		{"deprecated", "Pod/fio-server-1", "spec.nodeSelector.beta.kubernetes.io/arch"},
+	}
+	if len(findings) != len(want) {
+		t.Fatalf("got %d findings, want %d: %+v", len(findings), len(want), findings)
+	}
+	for i, w := range want {
+		f := findings[i]
+		if f.Status() != w.status || f.Manifest != "servers" || f.Resource != w.resource || f.Path != w.path {
+			t.Errorf("finding %d is %s %s %s %s, want %s servers %s %s", i, f.Status(), f.Manifest, f.Resource, f.Path, w.status, w.resource, w.path)
+		}
+	}
+}
+
+func TestCheckInvalidDocument(t *testing.T) {
+	manifest := "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: hosts\n---\nkind: [\n"
+	if _, err := Check(map[string]string{"hosts": manifest}, []Version{{1, 25}}); err == nil {
+		t.Fatal("Check accepted a manifest with an invalid second document")
+	}
+}
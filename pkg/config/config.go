@@ -1,32 +1,91 @@
 package config
 
 import (
+	"crypto/sha256"
+	_ "embed"
+	"encoding/hex"
 	"fmt"
 	"io/ioutil"
+	"net/url"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
 	"time"
 
 	"gopkg.in/yaml.v3"
+	apivalidation "k8s.io/apimachinery/pkg/api/validation"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+
+	"github.com/jtaleric/k8s-io/pkg/timeline"
+	"github.com/jtaleric/k8s-io/pkg/units"
 )
 
+// Source is the source of this file, used to document the configuration fields
+//
+//go:embed config.go
+var Source string
+
 // Config represents the main benchmark configuration
 type Config struct {
 	// Kubernetes settings
-	Namespace string `yaml:"namespace"`
+	Namespace         string           `yaml:"namespace"`
+	NamespaceSettings *NamespaceConfig `yaml:"namespace_settings,omitempty"` // Applied when the namespace is created
+	ServiceMesh       string           `yaml:"service_mesh,omitempty"`       // "disabled" or "enabled" when sidecar injection is detected
+	CollisionPolicy   string           `yaml:"collision_policy,omitempty"`   // "fail", "adopt" or "replace" when resources of this UUID already exist
 
 	// Benchmark identification
 	UUID        string `yaml:"uuid,omitempty"`
 	TestUser    string `yaml:"test_user"`
 	ClusterName string `yaml:"clustername"`
 
+	// Key/value tags added as labels to every resource and as fields to every result
+	Tags map[string]string `yaml:"tags,omitempty"`
+
+	// Labels and annotations added to every generated resource and pod, and to the namespaces
+	// the tool creates, for admission policies that require them such as a cost-center label
+	ExtraLabels      map[string]string `yaml:"extra_labels,omitempty"`
+	ExtraAnnotations map[string]string `yaml:"extra_annotations,omitempty"`
+
 	// Workload selection
 	Workload WorkloadConfig `yaml:"workload"`
 
+	// Spec controls applied to every generated Job
+	Job JobConfig `yaml:"job,omitempty"`
+
 	// Elasticsearch configuration (optional)
 	Elasticsearch *ElasticsearchConfig `yaml:"elasticsearch,omitempty"`
 
 	// Prometheus configuration (optional)
 	Prometheus *PrometheusConfig `yaml:"prometheus,omitempty"`
 
+	// Cluster metrics captured over the run (optional)
+	MetricsCapture *MetricsCaptureConfig `yaml:"metrics_capture,omitempty"`
+
+	// Destinations the metrics and result documents of the run are sent to, each on its own so
+	// one that is down loses nothing in the others (default the elasticsearch section)
+	Sinks []SinkConfig `yaml:"sinks,omitempty"`
+
+	// Grafana annotations for the benchmark phases (optional)
+	Grafana *GrafanaConfig `yaml:"grafana,omitempty"`
+
+	// Node clock skew preflight check (optional, requires Prometheus)
+	ClockSkew *ClockSkewConfig `yaml:"clock_skew,omitempty"`
+
+	// Run history and anomaly detection (optional)
+	History *HistoryConfig `yaml:"history,omitempty"`
+
+	// Cluster conditions a run waits for before it starts (optional)
+	StartGate *StartGateConfig `yaml:"start_gate,omitempty"`
+
+	// Queue limiting the benchmarks running at the same time in the cluster (optional)
+	Queue *QueueConfig `yaml:"queue,omitempty"`
+
+	// User commands run before the benchmark, after every sample and after the benchmark (optional)
+	Hooks *HooksConfig `yaml:"hooks,omitempty"`
+
 	// Cache drop settings
 	KCacheDropPodIPs       string `yaml:"kcache_drop_pod_ips,omitempty"`
 	KernelCacheDropSvcPort int    `yaml:"kernel_cache_drop_svc_port,omitempty"`
@@ -36,26 +95,203 @@ type Config struct {
 
 	// FIO job parameters
 	JobParams []JobParam `yaml:"job_params,omitempty"`
+
+	// Pass/fail thresholds checked after the results are parsed
+	Thresholds []Threshold `yaml:"thresholds,omitempty"`
+
+	// Directory for run artifacts such as node capture output, one subdirectory per run
+	ArtifactsDir string `yaml:"artifacts_dir,omitempty"`
+
+	// Fail instead of updating existing resources whose fields differ from the rendered manifests
+	NoOverwrite bool `yaml:"no_overwrite,omitempty"`
+
+	// Server-side dry-run a pod of the run before deploying it, so admission webhook policies
+	// such as Gatekeeper or Kyverno constraints that reject it fail the run early (default true)
+	AdmissionCheck *bool `yaml:"admission_check,omitempty"`
+
+	// Derive the UUID, and with it every resource name, from the configuration instead of
+	// generating one, so the manifests of `k8s-io generate` can live in Git and be reconciled
+	// by Argo CD or Flux. Resources that already exist are adopted by default.
+	GitOps bool `yaml:"gitops,omitempty"`
+
+	// Write the context and template text of every rendered manifest to the artifacts directory
+	DebugTemplates bool `yaml:"debug_templates,omitempty"`
+
+	// JUnit XML report written after the results are parsed (optional)
+	JUnitFile string `yaml:"junit_file,omitempty"`
+
+	// Units of the results tables: "raw" for plain numbers in MiB/s and usec, or "human"
+	Units string `yaml:"units,omitempty"`
+
+	// Rounding of the values in the results tables and reports; the CSV export, history and
+	// result sinks keep the full precision (optional)
+	Format *FormatConfig `yaml:"format,omitempty"`
+
+	// Delimiter and decimal separator of the results CSV, and an Excel workbook next to it (optional)
+	Export *ExportConfig `yaml:"export,omitempty"`
+
+	// Replace hostnames with pseudonyms in the results tables, CSV exports and JUnit reports,
+	// and anonymize the bundles of the run, for sharing the results outside the team
+	Anonymize bool `yaml:"anonymize,omitempty"`
+
+	// Pull/merge request comment reporter (optional)
+	PRComment *PRCommentConfig `yaml:"pr_comment,omitempty"`
+
+	// Clock of the run, started when a benchmark run begins; not part of the configuration file
+	Clock *timeline.Clock `yaml:"-"`
+
+	// Run metadata the workload adds, such as the guest tunings of VMs, recorded with every
+	// metrics document; not part of the configuration file
+	Metadata map[string]string `yaml:"-"`
+
+	// User requesting the run, as the API server authenticates the credentials of the run;
+	// not part of the configuration file
+	Owner string `yaml:"-"`
 }
 
 // WorkloadConfig represents the workload selection and configuration
 type WorkloadConfig struct {
-	Name string      `yaml:"name"` // "fio" or "hammerdb"
-	Args interface{} `yaml:"args"` // Will be unmarshaled to specific workload config
+	Name      string      `yaml:"name"`                // "fio", "hammerdb" or "provision"
+	Namespace string      `yaml:"namespace,omitempty"` // Overrides the global namespace for this workload
+	Args      interface{} `yaml:"args"`                // Will be unmarshaled to specific workload config
 }
 
-// ElasticsearchConfig represents Elasticsearch settings
+// NamespaceConfig represents the metadata of the namespace created for the benchmark
+type NamespaceConfig struct {
+	Labels               map[string]string `yaml:"labels,omitempty"`
+	Annotations          map[string]string `yaml:"annotations,omitempty"`
+	PodSecurity          string            `yaml:"pod_security,omitempty"`           // Pod Security Admission level: privileged, baseline or restricted
+	DisableMeshInjection *bool             `yaml:"disable_mesh_injection,omitempty"` // Disable Istio/Linkerd sidecar injection (default true)
+}
+
+// ElasticsearchConfig represents Elasticsearch settings. The fields follow the
+// benchmark-operator (ripsaw) CR so snafu indexes the results under the same names.
 type ElasticsearchConfig struct {
 	URL        string `yaml:"url"`
-	IndexName  string `yaml:"index_name,omitempty"`
-	VerifyCert bool   `yaml:"verify_cert,omitempty"`
+	IndexName  string `yaml:"index_name,omitempty"`  // Index prefix, snafu appends -results, -analyzed-result and -log (default ripsaw-<workload>)
+	VerifyCert *bool  `yaml:"verify_cert,omitempty"` // Verify the Elasticsearch certificate (default true)
 	Parallel   bool   `yaml:"parallel,omitempty"`
 }
 
 // PrometheusConfig represents Prometheus settings
 type PrometheusConfig struct {
-	URL   string `yaml:"url"`
-	Token string `yaml:"token,omitempty"`
+	URL             string   `yaml:"url"`
+	Token           string   `yaml:"token,omitempty"`
+	MetricsProfiles []string `yaml:"metrics_profiles,omitempty"` // kube-burner metrics profile files captured over the run
+	Step            string   `yaml:"step,omitempty"`             // Resolution of the captured range queries (default 30s)
+	IndexName       string   `yaml:"index_name,omitempty"`       // Elasticsearch index of the captured metrics (default k8s-io-metrics)
+	VerifyCert      *bool    `yaml:"verify_cert,omitempty"`      // Verify the certificate when checking the endpoint before the run (default true)
+	VerifyTimeout   string   `yaml:"verify_timeout,omitempty"`   // Timeout of the query checking the endpoint before the run (default 10s)
+}
+
+// DefaultPrometheusVerifyTimeout is the timeout of the query checking the Prometheus
+// endpoint before it is passed to the pods
+const DefaultPrometheusVerifyTimeout = 10 * time.Second
+
+// VerifyOptions returns the timeout and whether to verify the certificate of the query
+// checking the Prometheus endpoint, the defaults when Prometheus is discovered
+func (p *PrometheusConfig) VerifyOptions() (time.Duration, bool) {
+	if p == nil {
+		return DefaultPrometheusVerifyTimeout, true
+	}
+	timeout, err := time.ParseDuration(p.VerifyTimeout)
+	if err != nil || timeout <= 0 {
+		timeout = DefaultPrometheusVerifyTimeout
+	}
+	return timeout, p.VerifyCert == nil || *p.VerifyCert
+}
+
+// MetricsCaptureConfig selects how cluster metrics are captured over the run
+type MetricsCaptureConfig struct {
+	Capturer  string `yaml:"capturer,omitempty"`   // "prometheus", "metrics-server" or "none" (default prometheus when metrics profiles are set)
+	Interval  string `yaml:"interval,omitempty"`   // metrics-server polling interval (default 15s)
+	IndexName string `yaml:"index_name,omitempty"` // Elasticsearch index of the captured metrics (default prometheus index_name)
+}
+
+// Sink types
+const (
+	SinkElasticsearch = "elasticsearch"
+	SinkS3            = "s3"
+	SinkStdout        = "stdout"
+)
+
+// SinkConfig is a destination of the metrics and result documents of a run
+type SinkConfig struct {
+	Type       string `yaml:"type"`                  // "elasticsearch", "s3", "stdout" or a registered type
+	Name       string `yaml:"name,omitempty"`        // Name in the logs and warnings (default the type)
+	URL        string `yaml:"url,omitempty"`         // Elasticsearch URL (default elasticsearch url), or S3 endpoint (default AWS S3 of the region)
+	VerifyCert *bool  `yaml:"verify_cert,omitempty"` // Verify the certificate of the sink (default true)
+	Bucket     string `yaml:"bucket,omitempty"`      // S3 bucket, credentials come from AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY
+	Prefix     string `yaml:"prefix,omitempty"`      // S3 key prefix, objects are <prefix>/<uuid>/<index>.json
+	Region     string `yaml:"region,omitempty"`      // S3 region (default us-east-1)
+	Timeout    string `yaml:"timeout,omitempty"`     // Time one send may take (default 60s)
+}
+
+// GrafanaConfig represents the Grafana annotation settings
+type GrafanaConfig struct {
+	URL          string   `yaml:"url"`
+	Token        string   `yaml:"token,omitempty"`         // API token or service account token
+	TokenEnv     string   `yaml:"token_env,omitempty"`     // Environment variable holding the token if not set
+	DashboardUID string   `yaml:"dashboard_uid,omitempty"` // Limit the annotations to one dashboard
+	Tags         []string `yaml:"tags,omitempty"`          // Additional annotation tags
+}
+
+// JobConfig represents the spec controls of the generated Jobs
+type JobConfig struct {
+	BackoffLimit            int    `yaml:"backoff_limit,omitempty"`              // Pod retries before the Job fails, 0 so failed clients never rerun
+	ActiveDeadlineSeconds   int    `yaml:"active_deadline_seconds,omitempty"`    // Defaults to the workload job_timeout
+	TTLSecondsAfterFinished int    `yaml:"ttl_seconds_after_finished,omitempty"` // Delete finished Jobs after this long, 0 keeps them
+	RestartPolicy           string `yaml:"restart_policy,omitempty"`             // "Never" (default) or "OnFailure"
+}
+
+// ClockSkewConfig represents the node clock skew preflight check settings
+type ClockSkewConfig struct {
+	MaxOffsetMS float64 `yaml:"max_offset_ms,omitempty"` // Warn when a node clock is off by more than this
+}
+
+// HistoryConfig represents run history and anomaly detection settings
+type HistoryConfig struct {
+	Path    string  `yaml:"path"`              // History file, one JSON record per run
+	Window  int     `yaml:"window,omitempty"`  // Number of previous runs in the rolling baseline
+	StdDevs float64 `yaml:"stddevs,omitempty"` // Deviation from the baseline that triggers a warning
+	Webhook string  `yaml:"webhook,omitempty"` // Optional URL notified when anomalies are found
+	Server  string  `yaml:"server,omitempty"`  // Optional results server the record and artifacts of every run are uploaded to
+}
+
+// StartGateConfig represents the cluster conditions checked before a run starts, so results
+// are not skewed by a busy or degraded cluster
+type StartGateConfig struct {
+	MaxNodeCPUPercent float64 `yaml:"max_node_cpu_percent,omitempty"` // Busiest node CPU utilization, requires Prometheus (0 skips the check)
+	NoUpgrades        bool    `yaml:"no_upgrades,omitempty"`          // No cordoned or not ready nodes, cluster version or machine config pool updates
+	CephHealthOK      bool    `yaml:"ceph_health_ok,omitempty"`       // Every Ceph cluster is HEALTH_OK, from Prometheus or Rook
+	Action            string  `yaml:"action,omitempty"`               // "wait" (default) until the conditions hold, "abort" or "warn"
+	Interval          string  `yaml:"interval,omitempty"`             // Time between checks while waiting (default 30s)
+	Timeout           string  `yaml:"timeout,omitempty"`              // Fail the run after waiting this long (default 30m)
+}
+
+// QueueConfig represents the run queue shared by every run using the same queue namespace
+type QueueConfig struct {
+	Namespace          string `yaml:"namespace,omitempty"`             // Namespace of the queue tickets (default k8s-io-queue)
+	MaxConcurrent      int    `yaml:"max_concurrent,omitempty"`        // Runs at the same time in the cluster, 0 for no limit
+	MaxPerStorageClass int    `yaml:"max_per_storage_class,omitempty"` // Runs at the same time using one storage class, 0 for no limit
+	MaxWait            string `yaml:"max_wait,omitempty"`              // Fail the run after waiting this long, e.g. 2h (default no limit)
+}
+
+// HooksConfig represents the user commands run between the phases of the benchmark
+type HooksConfig struct {
+	PreRun     []Hook `yaml:"pre_run,omitempty"`     // After the namespace is ready, before anything is deployed
+	PostSample []Hook `yaml:"post_sample,omitempty"` // After every FIO sample, requires sample_barrier
+	PostRun    []Hook `yaml:"post_run,omitempty"`    // After the benchmark, whether it succeeded or not
+}
+
+// Hook represents a user command, run on the machine running k8s-io or as a Job in the
+// benchmark namespace when an image is set
+type Hook struct {
+	Name    string            `yaml:"name"`
+	Command []string          `yaml:"command"`           // Command and arguments, not run through a shell
+	Image   string            `yaml:"image,omitempty"`   // Run the command in a Job with this image
+	Timeout string            `yaml:"timeout,omitempty"` // Fail the hook after this long (default 10m)
+	Env     map[string]string `yaml:"env,omitempty"`     // Additional environment variables
 }
 
 // JobParam represents FIO job parameters
@@ -64,6 +300,133 @@ type JobParam struct {
 	Params       []string `yaml:"params"`
 }
 
+// PRCommentConfig represents the pull/merge request comment reporter settings
+type PRCommentConfig struct {
+	Provider   string `yaml:"provider"`            // "github" or "gitlab"
+	APIURL     string `yaml:"api_url,omitempty"`   // API base URL (defaults to the public service)
+	Repository string `yaml:"repository"`          // owner/repo for GitHub, project ID or path for GitLab
+	Number     int    `yaml:"number"`              // Pull request or merge request number
+	TokenEnv   string `yaml:"token_env,omitempty"` // Environment variable holding the API token
+}
+
+// Threshold represents pass/fail limits for a job. Throughput limits apply to the
+// total across all servers, latency limits to the slowest server.
+type Threshold struct {
+	JobnameMatch   string  `yaml:"jobname_match,omitempty"`     // FIO job the limits apply to (all jobs if empty)
+	MinReadIOPS    float64 `yaml:"min_read_iops,omitempty"`     // Minimum read IOPS
+	MinWriteIOPS   float64 `yaml:"min_write_iops,omitempty"`    // Minimum write IOPS
+	MinReadBW      int     `yaml:"min_read_bw,omitempty"`       // Minimum read bandwidth (KiB/s)
+	MinWriteBW     int     `yaml:"min_write_bw,omitempty"`      // Minimum write bandwidth (KiB/s)
+	MaxReadLatP95  float64 `yaml:"max_read_lat_p95,omitempty"`  // Maximum read P95 latency (usec)
+	MaxWriteLatP95 float64 `yaml:"max_write_lat_p95,omitempty"` // Maximum write P95 latency (usec)
+	MinTPM         int     `yaml:"min_tpm,omitempty"`           // Minimum HammerDB TPM
+	MinNOPM        int     `yaml:"min_nopm,omitempty"`          // Minimum HammerDB NOPM
+}
+
+// FormatConfig is the rounding policy of the values in the results tables and reports
+type FormatConfig struct {
+	SignificantFigures int    `yaml:"significant_figures,omitempty"` // Round to this many significant figures, 1 to 15, instead of fixed decimals; 0 keeps them
+	ThousandsSeparator string `yaml:"thousands_separator,omitempty"` // Between groups of three digits: ",", "'", "_" or " "
+}
+
+// NumberFormat returns the rounding policy of the results tables and reports
+func (c *Config) NumberFormat() units.Format {
+	if c.Format == nil {
+		return units.Format{}
+	}
+	return units.Format{SignificantFigures: c.Format.SignificantFigures, ThousandsSeparator: c.Format.ThousandsSeparator}
+}
+
+// ExportConfig controls the files the results are exported to besides the table
+type ExportConfig struct {
+	CSVDelimiter string `yaml:"csv_delimiter,omitempty"` // ",", ";", "tab" or "|", by csv_locale when empty
+	CSVLocale    string `yaml:"csv_locale,omitempty"`    // Language tag such as de-DE, whose decimal comma is written with a ";" delimiter
+	XLSX         bool   `yaml:"xlsx,omitempty"`          // Also write an Excel workbook with a sheet per sample and a summary sheet
+	Parquet      bool   `yaml:"parquet,omitempty"`       // Also write the results, interval logs and metrics as Parquet files to the run artifacts
+	Notebook     bool   `yaml:"notebook,omitempty"`      // Also write analysis.ipynb, which plots the Parquet files, to the run artifacts; implies parquet
+}
+
+// CSVFormat is the field delimiter and decimal separator of a results CSV
+type CSVFormat struct {
+	Delimiter    rune
+	DecimalComma bool
+}
+
+// csvDelimiters are the supported values of csv_delimiter
+var csvDelimiters = map[string]rune{",": ',', ";": ';', "tab": '\t', "|": '|'}
+
+// decimalCommaLanguages are the languages whose numbers have a decimal comma, by their
+// ISO 639-1 code. Spreadsheets in these locales read "1.5" as a date or text.
+var decimalCommaLanguages = map[string]bool{
+	"bg": true, "ca": true, "cs": true, "da": true, "de": true, "el": true, "es": true, "et": true,
+	"fi": true, "fr": true, "hr": true, "hu": true, "id": true, "is": true, "it": true, "lt": true,
+	"lv": true, "nb": true, "nl": true, "nn": true, "no": true, "pl": true, "pt": true, "ro": true,
+	"ru": true, "sk": true, "sl": true, "sr": true, "sv": true, "tr": true, "uk": true, "vi": true,
+}
+
+// CSVFormat returns the delimiter and decimal separator of the results CSV. A locale with a
+// decimal comma defaults the delimiter to ";", like spreadsheets in that locale expect.
+func (c *Config) CSVFormat() CSVFormat {
+	format := CSVFormat{Delimiter: ','}
+	if c.Export == nil {
+		return format
+	}
+	if decimalCommaLanguages[localeLanguage(c.Export.CSVLocale)] {
+		format = CSVFormat{Delimiter: ';', DecimalComma: true}
+	}
+	if delimiter, ok := csvDelimiters[c.Export.CSVDelimiter]; ok {
+		format.Delimiter = delimiter
+	}
+	return format
+}
+
+// SniffCSVDelimiter returns the delimiter of a results CSV from its header line, the one of
+// ",", ";", tab and "|" it has most of, so exports of any csv_delimiter and locale read back
+func SniffCSVDelimiter(header string) rune {
+	delimiter, most := ',', 0
+	for _, candidate := range []rune{',', ';', '\t', '|'} {
+		if n := strings.Count(header, string(candidate)); n > most {
+			delimiter, most = candidate, n
+		}
+	}
+	return delimiter
+}
+
+// localePattern matches a locale such as en, en-US or de_DE.UTF-8
+var localePattern = regexp.MustCompile(`^[A-Za-z]{2,3}([-_][A-Za-z0-9]+)*(\.[A-Za-z0-9-]+)?$`)
+
+// localeLanguage returns the language of a locale such as de-DE, de_DE.UTF-8 or de
+func localeLanguage(locale string) string {
+	language, _, _ := strings.Cut(strings.ToLower(locale), "-")
+	language, _, _ = strings.Cut(language, "_")
+	return language
+}
+
+// WritesParquet reports whether the run writes Parquet files, for themselves or the notebook
+func (c *Config) WritesParquet() bool {
+	return c.Export != nil && (c.Export.Parquet || c.Export.Notebook)
+}
+
+// validateExport checks the CSV delimiter and locale
+func (c *Config) validateExport() error {
+	if c.Export == nil {
+		return nil
+	}
+	if c.Export.CSVDelimiter != "" {
+		if _, ok := csvDelimiters[c.Export.CSVDelimiter]; !ok {
+			return fmt.Errorf("export.csv_delimiter must be one of \",\", \";\", \"tab\" or \"|\", got %q", c.Export.CSVDelimiter)
+		}
+	}
+	if c.Export.CSVLocale != "" && !localePattern.MatchString(c.Export.CSVLocale) {
+		return fmt.Errorf("export.csv_locale must be a locale such as en-US or de_DE, got %q", c.Export.CSVLocale)
+	}
+	format := c.CSVFormat()
+	if format.DecimalComma && format.Delimiter == ',' {
+		return fmt.Errorf("export.csv_delimiter cannot be \",\" with the decimal comma of csv_locale %s", c.Export.CSVLocale)
+	}
+	return nil
+}
+
 // LoadConfig loads configuration from a YAML file
 func LoadConfig(filename string) (*Config, error) {
 	data, err := ioutil.ReadFile(filename)
@@ -71,6 +434,11 @@ func LoadConfig(filename string) (*Config, error) {
 		return nil, fmt.Errorf("failed to read config file %s: %w", filename, err)
 	}
 
+	return Parse(data, filename)
+}
+
+// Parse parses, defaults and validates a configuration read from the named file
+func Parse(data []byte, filename string) (*Config, error) {
 	var config Config
 	if err := yaml.Unmarshal(data, &config); err != nil {
 		return nil, fmt.Errorf("failed to parse config file %s: %w", filename, err)
@@ -97,29 +465,576 @@ func (c *Config) setDefaults() {
 		c.ClusterName = "default-cluster"
 	}
 
+	// The workload namespace is folded into the global one, so -namespace still overrides it
+	// and saved plans load with the same namespace
+	if c.Workload.Namespace != "" {
+		c.Namespace = c.Workload.Namespace
+		c.Workload.Namespace = ""
+	}
 	if c.Namespace == "" {
 		c.Namespace = "default"
 	}
 
+	if c.ArtifactsDir == "" {
+		c.ArtifactsDir = "artifacts"
+	}
+
+	if c.NamespaceSettings == nil {
+		c.NamespaceSettings = &NamespaceConfig{}
+	}
+	if c.NamespaceSettings.DisableMeshInjection == nil {
+		disable := true
+		c.NamespaceSettings.DisableMeshInjection = &disable
+	}
+
+	if c.ServiceMesh == "" {
+		c.ServiceMesh = "disabled"
+	}
+
+	if c.Job.RestartPolicy == "" {
+		c.Job.RestartPolicy = "Never"
+	}
+
+	if c.CollisionPolicy == "" {
+		c.CollisionPolicy = "fail"
+		// The resources reconciled from Git exist before the run
+		if c.GitOps {
+			c.CollisionPolicy = "adopt"
+		}
+	}
+
+	if c.Units == "" {
+		c.Units = string(units.Raw)
+	}
+
+	if c.Elasticsearch != nil && c.Elasticsearch.VerifyCert == nil {
+		verify := true
+		c.Elasticsearch.VerifyCert = &verify
+	}
+
+	if c.Prometheus != nil {
+		if c.Prometheus.Step == "" {
+			c.Prometheus.Step = "30s"
+		}
+		if c.Prometheus.IndexName == "" {
+			c.Prometheus.IndexName = "k8s-io-metrics"
+		}
+		if c.Prometheus.VerifyTimeout == "" {
+			c.Prometheus.VerifyTimeout = DefaultPrometheusVerifyTimeout.String()
+		}
+	}
+
+	for i := range c.Sinks {
+		if c.Sinks[i].Name == "" {
+			c.Sinks[i].Name = c.Sinks[i].Type
+		}
+	}
+
+	if c.MetricsCapture == nil {
+		c.MetricsCapture = &MetricsCaptureConfig{}
+	}
+	if c.MetricsCapture.Capturer == "" {
+		c.MetricsCapture.Capturer = "none"
+		if c.Prometheus != nil && len(c.Prometheus.MetricsProfiles) > 0 {
+			c.MetricsCapture.Capturer = "prometheus"
+		}
+	}
+	if c.MetricsCapture.Interval == "" {
+		c.MetricsCapture.Interval = "15s"
+	}
+	if c.MetricsCapture.IndexName == "" {
+		c.MetricsCapture.IndexName = "k8s-io-metrics"
+		if c.Prometheus != nil {
+			c.MetricsCapture.IndexName = c.Prometheus.IndexName
+		}
+	}
+
+	if c.Grafana != nil && c.Grafana.TokenEnv == "" {
+		c.Grafana.TokenEnv = "GRAFANA_TOKEN"
+	}
+
+	if c.ClockSkew != nil && c.ClockSkew.MaxOffsetMS == 0 {
+		c.ClockSkew.MaxOffsetMS = 100
+	}
+
+	if c.History != nil {
+		if c.History.Window == 0 {
+			c.History.Window = 10
+		}
+		if c.History.StdDevs == 0 {
+			c.History.StdDevs = 3
+		}
+	}
+
+	if c.StartGate != nil {
+		if c.StartGate.Action == "" {
+			c.StartGate.Action = "wait"
+		}
+		if c.StartGate.Interval == "" {
+			c.StartGate.Interval = "30s"
+		}
+		if c.StartGate.Timeout == "" {
+			c.StartGate.Timeout = "30m"
+		}
+	}
+
+	if c.Queue != nil && c.Queue.Namespace == "" {
+		c.Queue.Namespace = "k8s-io-queue"
+	}
+
+	if c.Hooks != nil {
+		for _, hooks := range [][]Hook{c.Hooks.PreRun, c.Hooks.PostSample, c.Hooks.PostRun} {
+			for i := range hooks {
+				if hooks[i].Timeout == "" {
+					hooks[i].Timeout = "10m"
+				}
+			}
+		}
+	}
+
+	if c.PRComment != nil {
+		switch c.PRComment.Provider {
+		case "github":
+			if c.PRComment.APIURL == "" {
+				c.PRComment.APIURL = "https://api.github.com"
+			}
+			if c.PRComment.TokenEnv == "" {
+				c.PRComment.TokenEnv = "GITHUB_TOKEN"
+			}
+		case "gitlab":
+			if c.PRComment.APIURL == "" {
+				c.PRComment.APIURL = "https://gitlab.com/api/v4"
+			}
+			if c.PRComment.TokenEnv == "" {
+				c.PRComment.TokenEnv = "GITLAB_TOKEN"
+			}
+		}
+	}
+
 	// Generate UUID if not provided
+	if c.UUID == "" && c.GitOps {
+		c.UUID = c.configUUID()
+	}
 	if c.UUID == "" {
 		c.UUID = generateUUID()
 	}
 }
 
+// configUUID returns a UUID derived from the defaulted configuration, the same for every
+// load of an unchanged configuration
+func (c *Config) configUUID() string {
+	data, err := yaml.Marshal(c)
+	if err != nil {
+		return ""
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])[:8]
+}
+
 // validate validates the configuration
 func (c *Config) validate() error {
 	if c.Workload.Name == "" {
 		return fmt.Errorf("workload name must be specified")
 	}
 
-	if c.Workload.Name != "fio" && c.Workload.Name != "hammerdb" {
-		return fmt.Errorf("workload name must be either 'fio' or 'hammerdb'")
+	if c.Workload.Name != "fio" && c.Workload.Name != "hammerdb" && c.Workload.Name != "provision" {
+		return fmt.Errorf("workload name must be one of 'fio', 'hammerdb' or 'provision'")
+	}
+
+	switch c.NamespaceSettings.PodSecurity {
+	case "", "privileged", "baseline", "restricted":
+	default:
+		return fmt.Errorf("namespace_settings pod_security must be one of 'privileged', 'baseline' or 'restricted'")
+	}
+
+	if c.ServiceMesh != "disabled" && c.ServiceMesh != "enabled" {
+		return fmt.Errorf("service_mesh must be either 'disabled' or 'enabled'")
+	}
+
+	if c.Job.BackoffLimit < 0 || c.Job.ActiveDeadlineSeconds < 0 || c.Job.TTLSecondsAfterFinished < 0 {
+		return fmt.Errorf("job backoff_limit, active_deadline_seconds and ttl_seconds_after_finished must not be negative")
+	}
+
+	if c.Job.RestartPolicy != "Never" && c.Job.RestartPolicy != "OnFailure" {
+		return fmt.Errorf("job restart_policy must be either 'Never' or 'OnFailure'")
+	}
+
+	for key, value := range c.Tags {
+		if errs := validation.IsQualifiedName(key); len(errs) > 0 {
+			return fmt.Errorf("tag %q is not a valid label key: %s", key, strings.Join(errs, ", "))
+		}
+		if errs := validation.IsValidLabelValue(value); len(errs) > 0 {
+			return fmt.Errorf("tag %s value %q is not a valid label value: %s", key, value, strings.Join(errs, ", "))
+		}
+	}
+
+	for key, value := range c.ExtraLabels {
+		if errs := validation.IsQualifiedName(key); len(errs) > 0 {
+			return fmt.Errorf("extra_labels key %q is not a valid label key: %s", key, strings.Join(errs, ", "))
+		}
+		if errs := validation.IsValidLabelValue(value); len(errs) > 0 {
+			return fmt.Errorf("extra_labels %s value %q is not a valid label value: %s", key, value, strings.Join(errs, ", "))
+		}
+		if tag, ok := c.Tags[key]; ok && tag != value {
+			return fmt.Errorf("extra_labels %s is also a tag with a different value", key)
+		}
+	}
+
+	if errs := apivalidation.ValidateAnnotations(c.ExtraAnnotations, field.NewPath("extra_annotations")); len(errs) > 0 {
+		return errs.ToAggregate()
+	}
+
+	if _, err := units.ParseMode(c.Units); err != nil {
+		return err
+	}
+	if err := units.ValidateFormat(c.NumberFormat()); err != nil {
+		return fmt.Errorf("format: %w", err)
+	}
+
+	switch c.CollisionPolicy {
+	case "fail", "adopt", "replace":
+	default:
+		return fmt.Errorf("collision_policy must be one of 'fail', 'adopt' or 'replace'")
+	}
+
+	if c.Elasticsearch != nil {
+		if c.Elasticsearch.URL == "" {
+			return fmt.Errorf("elasticsearch url must be specified")
+		}
+		for _, suffix := range []string{"-results", "-analyzed-result", "-log"} {
+			if strings.HasSuffix(c.Elasticsearch.IndexName, suffix) {
+				return fmt.Errorf("elasticsearch index_name is a prefix, snafu appends %s itself", suffix)
+			}
+		}
+	}
+
+	if c.Prometheus != nil {
+		if step, err := time.ParseDuration(c.Prometheus.Step); err != nil || step <= 0 {
+			return fmt.Errorf("prometheus step must be a positive duration such as 30s")
+		}
+		if timeout, err := time.ParseDuration(c.Prometheus.VerifyTimeout); err != nil || timeout <= 0 {
+			return fmt.Errorf("prometheus verify_timeout must be a positive duration such as 10s")
+		}
+	}
+
+	if interval, err := time.ParseDuration(c.MetricsCapture.Interval); err != nil || interval <= 0 {
+		return fmt.Errorf("metrics_capture interval must be a positive duration such as 15s")
+	}
+
+	if c.Grafana != nil && c.Grafana.URL == "" {
+		return fmt.Errorf("grafana url must be specified")
+	}
+
+	if err := c.validateExport(); err != nil {
+		return err
+	}
+
+	if err := c.validateSinks(); err != nil {
+		return err
+	}
+
+	if c.ClockSkew != nil && c.ClockSkew.MaxOffsetMS < 0 {
+		return fmt.Errorf("clock_skew max_offset_ms must not be negative")
+	}
+
+	for i, t := range c.Thresholds {
+		if t.MinReadIOPS < 0 || t.MinWriteIOPS < 0 || t.MinReadBW < 0 || t.MinWriteBW < 0 ||
+			t.MaxReadLatP95 < 0 || t.MaxWriteLatP95 < 0 || t.MinTPM < 0 || t.MinNOPM < 0 {
+			return fmt.Errorf("threshold %d must not have negative limits", i+1)
+		}
+	}
+
+	if c.PRComment != nil {
+		if c.PRComment.Provider != "github" && c.PRComment.Provider != "gitlab" {
+			return fmt.Errorf("pr_comment provider must be either 'github' or 'gitlab'")
+		}
+		if c.PRComment.Repository == "" || c.PRComment.Number <= 0 {
+			return fmt.Errorf("pr_comment repository and number must be specified")
+		}
+		if c.History == nil {
+			return fmt.Errorf("pr_comment requires history to be configured for the baseline")
+		}
+	}
+
+	if c.Hooks != nil {
+		stages := map[string][]Hook{"pre_run": c.Hooks.PreRun, "post_sample": c.Hooks.PostSample, "post_run": c.Hooks.PostRun}
+		for _, stage := range []string{"pre_run", "post_sample", "post_run"} {
+			for _, h := range stages[stage] {
+				if errs := validation.IsDNS1123Label(h.Name); len(errs) > 0 || len(h.Name) > 30 {
+					return fmt.Errorf("%s hook name %q must be a DNS label of at most 30 characters", stage, h.Name)
+				}
+				if len(h.Command) == 0 {
+					return fmt.Errorf("%s hook %s must have a command", stage, h.Name)
+				}
+				if timeout, err := time.ParseDuration(h.Timeout); err != nil || timeout <= 0 {
+					return fmt.Errorf("%s hook %s timeout must be a positive duration such as 10m", stage, h.Name)
+				}
+			}
+		}
+	}
+
+	if c.StartGate != nil {
+		g := c.StartGate
+		if g.MaxNodeCPUPercent < 0 || g.MaxNodeCPUPercent > 100 {
+			return fmt.Errorf("start_gate max_node_cpu_percent must be between 0 and 100")
+		}
+		if g.MaxNodeCPUPercent == 0 && !g.NoUpgrades && !g.CephHealthOK {
+			return fmt.Errorf("start_gate requires max_node_cpu_percent, no_upgrades or ceph_health_ok")
+		}
+		switch g.Action {
+		case "wait", "abort", "warn":
+		default:
+			return fmt.Errorf("start_gate action must be one of 'wait', 'abort' or 'warn'")
+		}
+		if interval, err := time.ParseDuration(g.Interval); err != nil || interval <= 0 {
+			return fmt.Errorf("start_gate interval must be a positive duration such as 30s")
+		}
+		if timeout, err := time.ParseDuration(g.Timeout); err != nil || timeout <= 0 {
+			return fmt.Errorf("start_gate timeout must be a positive duration such as 30m")
+		}
+	}
+
+	if c.Queue != nil {
+		if c.Queue.MaxConcurrent < 0 || c.Queue.MaxPerStorageClass < 0 {
+			return fmt.Errorf("queue max_concurrent and max_per_storage_class must not be negative")
+		}
+		if c.Queue.MaxConcurrent == 0 && c.Queue.MaxPerStorageClass == 0 {
+			return fmt.Errorf("queue requires max_concurrent or max_per_storage_class")
+		}
+		if c.Queue.MaxWait != "" {
+			if wait, err := time.ParseDuration(c.Queue.MaxWait); err != nil || wait <= 0 {
+				return fmt.Errorf("queue max_wait must be a positive duration such as 2h")
+			}
+		}
+	}
+
+	if c.History != nil {
+		if c.History.Path == "" {
+			return fmt.Errorf("history path must be specified")
+		}
+		if c.History.Window < 0 || c.History.StdDevs < 0 {
+			return fmt.Errorf("history window and stddevs must not be negative")
+		}
 	}
 
 	return nil
 }
 
+// NamespaceMetadata returns the labels and annotations for the namespace created for the benchmark
+func (c *Config) NamespaceMetadata() (map[string]string, map[string]string) {
+	labels := map[string]string{}
+	annotations := map[string]string{}
+	for k, v := range c.ExtraLabels {
+		labels[k] = v
+	}
+	for k, v := range c.ExtraAnnotations {
+		annotations[k] = v
+	}
+
+	ns := c.NamespaceSettings
+	if ns == nil {
+		return labels, annotations
+	}
+
+	if ns.DisableMeshInjection != nil && *ns.DisableMeshInjection {
+		labels["istio-injection"] = "disabled"
+		annotations["linkerd.io/inject"] = "disabled"
+	}
+
+	if ns.PodSecurity != "" {
+		for _, mode := range []string{"enforce", "audit", "warn"} {
+			labels["pod-security.kubernetes.io/"+mode] = ns.PodSecurity
+		}
+	}
+
+	// Explicit settings take precedence over the generated ones
+	for k, v := range ns.Labels {
+		labels[k] = v
+	}
+	for k, v := range ns.Annotations {
+		annotations[k] = v
+	}
+
+	return labels, annotations
+}
+
+// ResourceLabels returns the labels added to every generated resource, the tags and extra_labels
+func (c *Config) ResourceLabels() map[string]string {
+	labels := make(map[string]string, len(c.Tags)+len(c.ExtraLabels))
+	for k, v := range c.Tags {
+		labels[k] = v
+	}
+	for k, v := range c.ExtraLabels {
+		labels[k] = v
+	}
+	return labels
+}
+
+// TagString returns the tags as sorted key=value pairs separated by semicolons
+func (c *Config) TagString() string {
+	pairs := make([]string, 0, len(c.Tags))
+	for key, value := range c.Tags {
+		pairs = append(pairs, key+"="+value)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ";")
+}
+
+// IndexPrefix returns the index_name prefix, ripsaw-<workload> by default
+func (e *ElasticsearchConfig) IndexPrefix(workload string) string {
+	if e == nil || e.IndexName == "" {
+		return "ripsaw-" + workload
+	}
+	return e.IndexName
+}
+
+// TemplateContext returns the Elasticsearch settings under the names used by the
+// benchmark-operator templates. Booleans are lower case strings because snafu
+// compares the environment variables against "true" and "false".
+func (e *ElasticsearchConfig) TemplateContext(workload string) map[string]interface{} {
+	if e == nil || e.URL == "" {
+		return map[string]interface{}{}
+	}
+
+	verify := e.VerifyCert == nil || *e.VerifyCert
+
+	return map[string]interface{}{
+		"url":         e.URL,
+		"index_name":  e.IndexPrefix(workload),
+		"verify_cert": strconv.FormatBool(verify),
+		"parallel":    strconv.FormatBool(e.Parallel),
+	}
+}
+
+// validateSinks checks that every sink has a unique name and the settings of its type. The
+// sinks package rejects unknown types, since more can be registered.
+func (c *Config) validateSinks() error {
+	names := make(map[string]bool)
+	for i, sink := range c.Sinks {
+		if names[sink.Name] {
+			return fmt.Errorf("sink %d: name %q is used by another sink, set name to tell them apart", i+1, sink.Name)
+		}
+		names[sink.Name] = true
+
+		switch sink.Type {
+		case SinkElasticsearch:
+			if sink.URL == "" && (c.Elasticsearch == nil || c.Elasticsearch.URL == "") {
+				return fmt.Errorf("sink %s: elasticsearch sinks need a url or the elasticsearch section", sink.Name)
+			}
+		case SinkS3:
+			if sink.Bucket == "" {
+				return fmt.Errorf("sink %s: s3 sinks need a bucket", sink.Name)
+			}
+		case "":
+			return fmt.Errorf("sink %d: type must be specified", i+1)
+		}
+		if sink.Timeout != "" {
+			if timeout, err := time.ParseDuration(sink.Timeout); err != nil || timeout <= 0 {
+				return fmt.Errorf("sink %s: timeout must be a positive duration such as 60s", sink.Name)
+			}
+		}
+	}
+	return nil
+}
+
+// Redacted returns a copy of the configuration with "redacted" for the tokens and URL
+// passwords, for artifacts shared with others
+func (c *Config) Redacted() *Config {
+	r := *c
+	if c.Elasticsearch != nil {
+		es := *c.Elasticsearch
+		es.URL = redactURL(es.URL)
+		r.Elasticsearch = &es
+	}
+	if c.Prometheus != nil {
+		prom := *c.Prometheus
+		prom.URL = redactURL(prom.URL)
+		prom.Token = redact(prom.Token)
+		r.Prometheus = &prom
+	}
+	if c.Grafana != nil {
+		grafana := *c.Grafana
+		grafana.URL = redactURL(grafana.URL)
+		grafana.Token = redact(grafana.Token)
+		r.Grafana = &grafana
+	}
+	if c.History != nil {
+		history := *c.History
+		history.Webhook = redactURL(history.Webhook)
+		history.Server = redactURL(history.Server)
+		r.History = &history
+	}
+	if len(c.Sinks) > 0 {
+		r.Sinks = make([]SinkConfig, len(c.Sinks))
+		for i, sink := range c.Sinks {
+			sink.URL = redactURL(sink.URL)
+			r.Sinks[i] = sink
+		}
+	}
+	return &r
+}
+
+// Secrets returns the tokens and URL passwords of the configuration, which Redacted removes
+func (c *Config) Secrets() []string {
+	var secrets []string
+	add := func(secret string) {
+		if secret != "" {
+			secrets = append(secrets, secret)
+		}
+	}
+	addURL := func(raw string) {
+		if u, err := url.Parse(raw); err == nil && u.User != nil {
+			password, _ := u.User.Password()
+			add(password)
+		}
+	}
+
+	if c.Elasticsearch != nil {
+		addURL(c.Elasticsearch.URL)
+	}
+	if c.Prometheus != nil {
+		addURL(c.Prometheus.URL)
+		add(c.Prometheus.Token)
+	}
+	if c.Grafana != nil {
+		addURL(c.Grafana.URL)
+		add(c.Grafana.Token)
+	}
+	if c.History != nil {
+		addURL(c.History.Webhook)
+		addURL(c.History.Server)
+	}
+	for _, sink := range c.Sinks {
+		addURL(sink.URL)
+	}
+	return secrets
+}
+
+// redact replaces a secret that is set
+func redact(secret string) string {
+	if secret == "" {
+		return ""
+	}
+	return "redacted"
+}
+
+// redactURL replaces the password of a URL
+func redactURL(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil || u.User == nil {
+		return raw
+	}
+	if _, ok := u.User.Password(); ok {
+		u.User = url.UserPassword(u.User.Username(), "redacted")
+	}
+	return u.String()
+}
+
+// RunArtifactsDir returns the artifacts directory of this run
+func (c *Config) RunArtifactsDir() string {
+	return filepath.Join(c.ArtifactsDir, c.UUID)
+}
+
 // GetTruncatedUUID returns the first 8 characters of the UUID
 func (c *Config) GetTruncatedUUID() string {
 	if len(c.UUID) >= 8 {
@@ -0,0 +1,55 @@
+package config
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+)
+
+// fingerprintVersion is bumped whenever the canonical form changes
+const fingerprintVersion = "v1"
+
+// fingerprintIgnoredArgs lists workload args that do not affect benchmark results
+var fingerprintIgnoredArgs = []string{
+	"Annotations",
+	"ServerAnnotations",
+	"ClientAnnotations",
+	"Debug",
+	"FioJSONToLog",
+	"IndexRawResults",
+	"JobTimeout",
+	"PodDisruptionBudget",
+}
+
+// Fingerprint returns a canonical hash of the effective workload configuration.
+// Runs with identical fingerprints are comparable with each other.
+func (c *Config) Fingerprint(workloadArgs interface{}) (string, error) {
+	data, err := json.Marshal(workloadArgs)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal workload configuration: %w", err)
+	}
+
+	// Round-trip through a map so fields are ordered canonically
+	var args map[string]interface{}
+	if err := json.Unmarshal(data, &args); err != nil {
+		return "", fmt.Errorf("failed to canonicalize workload configuration: %w", err)
+	}
+
+	for _, name := range fingerprintIgnoredArgs {
+		delete(args, name)
+	}
+
+	canonical, err := json.Marshal(map[string]interface{}{
+		"version":    fingerprintVersion,
+		"workload":   c.Workload.Name,
+		"args":       args,
+		"job_params": c.JobParams,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal canonical configuration: %w", err)
+	}
+
+	sum := sha256.Sum256(canonical)
+	return hex.EncodeToString(sum[:])[:16], nil
+}
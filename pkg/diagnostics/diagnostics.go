@@ -0,0 +1,78 @@
+package diagnostics
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"runtime"
+	"time"
+
+	"github.com/jtaleric/k8s-io/pkg/config"
+	"github.com/jtaleric/k8s-io/pkg/kubernetes"
+)
+
+// Dump is the runtime state served on /debug/runtime
+type Dump struct {
+	UUID       string                 `json:"uuid"`
+	Workload   string                 `json:"workload"`
+	Started    time.Time              `json:"started"`
+	Uptime     string                 `json:"uptime"`
+	Goroutines int                    `json:"goroutines"`
+	HeapAlloc  uint64                 `json:"heap_alloc_bytes"`
+	Sys        uint64                 `json:"sys_bytes"`
+	NumGC      uint32                 `json:"num_gc"`
+	InFlight   []kubernetes.Operation `json:"in_flight"` // Waits and log streams the run is blocked on
+}
+
+// Start serves pprof under /debug/pprof/ and the runtime dump under /debug/runtime on addr
+// in the background. Full goroutine stacks are available at /debug/pprof/goroutine?debug=2.
+func Start(addr string, cfg *config.Config, k8sClient *kubernetes.Client) error {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", addr, err)
+	}
+
+	started := time.Now().UTC()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	mux.HandleFunc("/debug/runtime", func(w http.ResponseWriter, r *http.Request) {
+		var mem runtime.MemStats
+		runtime.ReadMemStats(&mem)
+
+		dump := Dump{
+			UUID:       cfg.UUID,
+			Workload:   cfg.Workload.Name,
+			Started:    started,
+			Uptime:     time.Since(started).Round(time.Second).String(),
+			Goroutines: runtime.NumGoroutine(),
+			HeapAlloc:  mem.HeapAlloc,
+			Sys:        mem.Sys,
+			NumGC:      mem.NumGC,
+			InFlight:   k8sClient.InFlight(),
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(dump); err != nil {
+			log.Printf("Warning: failed to write runtime dump: %v", err)
+		}
+	})
+
+	go func() {
+		if err := http.Serve(listener, mux); err != nil {
+			log.Printf("Warning: diagnostics server stopped: %v", err)
+		}
+	}()
+
+	log.Printf("Serving pprof and runtime diagnostics on http://%s/debug/", listener.Addr())
+	return nil
+}
@@ -0,0 +1,239 @@
+package estimate
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/serializer/yaml"
+)
+
+// minTimeoutMargin is the least time a job timeout must leave on top of the planned
+// duration for scheduling, image pulls and slow samples
+const minTimeoutMargin = 5 * time.Minute
+
+// Estimate is the cluster footprint and expected duration of a run
+type Estimate struct {
+	Pods     int
+	VMs      int
+	PVCs     int
+	Storage  resource.Quantity // Total requested PVC capacity
+	CPU      resource.Quantity // Aggregate CPU requests
+	Memory   resource.Quantity // Aggregate memory requests
+	Duration time.Duration
+	Notes    []string // Parts that could not be estimated
+
+	// Storage classes of the PVCs, ephemeral volumes and data volumes, sorted, with DefaultStorageClass for
+	// the claims of the cluster default class
+	StorageClasses []string
+}
+
+// DefaultStorageClass stands for the cluster default class in StorageClasses
+const DefaultStorageClass = "(default)"
+
+// FromManifests adds up the pods, VMs, PVC capacity and resource requests of rendered manifests
+func FromManifests(manifests map[string]string) (*Estimate, error) {
+	e := &Estimate{}
+
+	names := make([]string, 0, len(manifests))
+	for name := range manifests {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	dec := yaml.NewDecodingSerializer(unstructured.UnstructuredJSONScheme)
+	for _, name := range names {
+		for _, doc := range strings.Split(manifests[name], "\n---") {
+			if strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(doc), "---")) == "" {
+				continue
+			}
+			obj := &unstructured.Unstructured{}
+			if _, _, err := dec.Decode([]byte(doc), nil, obj); err != nil {
+				return nil, fmt.Errorf("failed to decode manifest %s: %w", name, err)
+			}
+			if err := e.add(obj); err != nil {
+				return nil, fmt.Errorf("failed to estimate manifest %s: %w", name, err)
+			}
+		}
+	}
+
+	return e, nil
+}
+
+// add accounts for a single object
+func (e *Estimate) add(obj *unstructured.Unstructured) error {
+	switch obj.GetKind() {
+	case "Pod":
+		var pod corev1.Pod
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, &pod); err != nil {
+			return err
+		}
+		e.addPods(pod.Spec, 1)
+	case "Job":
+		var job batchv1.Job
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, &job); err != nil {
+			return err
+		}
+		parallelism := 1
+		if job.Spec.Parallelism != nil {
+			parallelism = int(*job.Spec.Parallelism)
+		}
+		e.addPods(job.Spec.Template.Spec, parallelism)
+	case "DaemonSet":
+		var ds appsv1.DaemonSet
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, &ds); err != nil {
+			return err
+		}
+		e.Notes = append(e.Notes, fmt.Sprintf("DaemonSet %s adds one pod per selected node, not included", ds.Name))
+	case "PersistentVolumeClaim":
+		var pvc corev1.PersistentVolumeClaim
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, &pvc); err != nil {
+			return err
+		}
+		e.PVCs++
+		if storage, ok := pvc.Spec.Resources.Requests[corev1.ResourceStorage]; ok {
+			e.Storage.Add(storage)
+		}
+		class := pvc.Annotations["volume.beta.kubernetes.io/storage-class"]
+		if pvc.Spec.StorageClassName != nil {
+			class = *pvc.Spec.StorageClassName
+		}
+		e.addStorageClass(class)
+	case "VirtualMachineInstance", "VirtualMachine":
+		e.addVM(obj)
+	}
+	return nil
+}
+
+// addStorageClass adds the storage class of a claim, empty for the cluster default
+func (e *Estimate) addStorageClass(class string) {
+	if class == "" {
+		class = DefaultStorageClass
+	}
+	i := sort.SearchStrings(e.StorageClasses, class)
+	if i < len(e.StorageClasses) && e.StorageClasses[i] == class {
+		return
+	}
+	e.StorageClasses = append(e.StorageClasses, "")
+	copy(e.StorageClasses[i+1:], e.StorageClasses[i:])
+	e.StorageClasses[i] = class
+}
+
+// addPods adds count pods of the given spec. Sidecar init containers run next to the
+// containers, other init containers only before them.
+func (e *Estimate) addPods(spec corev1.PodSpec, count int) {
+	var cpu, memory resource.Quantity
+	for _, c := range spec.Containers {
+		cpu.Add(c.Resources.Requests[corev1.ResourceCPU])
+		memory.Add(c.Resources.Requests[corev1.ResourceMemory])
+	}
+	for _, c := range spec.InitContainers {
+		if c.RestartPolicy != nil && *c.RestartPolicy == corev1.ContainerRestartPolicyAlways {
+			cpu.Add(c.Resources.Requests[corev1.ResourceCPU])
+			memory.Add(c.Resources.Requests[corev1.ResourceMemory])
+		}
+	}
+
+	for _, v := range spec.Volumes {
+		if v.Ephemeral != nil && v.Ephemeral.VolumeClaimTemplate != nil {
+			class := ""
+			if v.Ephemeral.VolumeClaimTemplate.Spec.StorageClassName != nil {
+				class = *v.Ephemeral.VolumeClaimTemplate.Spec.StorageClassName
+			}
+			e.addStorageClass(class)
+		}
+	}
+
+	for i := 0; i < count; i++ {
+		e.Pods++
+		e.CPU.Add(cpu)
+		e.Memory.Add(memory)
+	}
+}
+
+// addVM adds the guest CPU cores, memory and data volumes of a KubeVirt VM or VMI
+func (e *Estimate) addVM(obj *unstructured.Unstructured) {
+	spec := obj.Object
+	if obj.GetKind() == "VirtualMachine" {
+		template, _, _ := unstructured.NestedMap(obj.Object, "spec", "template")
+		spec = template
+
+		dataVolumes, _, _ := unstructured.NestedSlice(obj.Object, "spec", "dataVolumeTemplates")
+		for _, dv := range dataVolumes {
+			dvMap, ok := dv.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			for _, field := range []string{"pvc", "storage"} {
+				if size, found, _ := unstructured.NestedString(dvMap, "spec", field, "resources", "requests", "storage"); found {
+					if q, err := resource.ParseQuantity(size); err == nil {
+						e.PVCs++
+						e.Storage.Add(q)
+						class, _, _ := unstructured.NestedString(dvMap, "spec", field, "storageClassName")
+						e.addStorageClass(class)
+					}
+				}
+			}
+		}
+	}
+
+	e.VMs++
+	e.Pods++ // virt-launcher
+
+	if cores, found, _ := unstructured.NestedFieldNoCopy(spec, "spec", "domain", "cpu", "cores"); found {
+		if q, err := resource.ParseQuantity(fmt.Sprint(cores)); err == nil {
+			e.CPU.Add(q)
+		}
+	}
+	if memory, found, _ := unstructured.NestedString(spec, "spec", "domain", "resources", "requests", "memory"); found {
+		if q, err := resource.ParseQuantity(memory); err == nil {
+			e.Memory.Add(q)
+		}
+	}
+}
+
+// Print writes the estimate in a human readable form
+func (e *Estimate) Print(w io.Writer) {
+	fmt.Fprintln(w, "\n=== Estimate ===")
+	fmt.Fprintf(w, "Pods:              %d\n", e.Pods)
+	if e.VMs > 0 {
+		fmt.Fprintf(w, "VMs:               %d\n", e.VMs)
+	}
+	fmt.Fprintf(w, "PVCs:              %d (%s total)\n", e.PVCs, e.Storage.String())
+	if len(e.StorageClasses) > 0 {
+		fmt.Fprintf(w, "Storage classes:   %s\n", strings.Join(e.StorageClasses, ", "))
+	}
+	fmt.Fprintf(w, "CPU requests:      %s\n", e.CPU.String())
+	fmt.Fprintf(w, "Memory requests:   %s\n", e.Memory.String())
+	fmt.Fprintf(w, "Expected duration: %s\n", e.Duration.Round(time.Second))
+	for _, note := range e.Notes {
+		fmt.Fprintf(w, "Note: %s\n", note)
+	}
+}
+
+// MinTimeout returns the smallest job timeout for a job planned to run for the given
+// duration: a quarter on top, and at least minTimeoutMargin
+func MinTimeout(planned time.Duration) time.Duration {
+	margin := planned / 4
+	if margin < minTimeoutMargin {
+		margin = minTimeoutMargin
+	}
+	return planned + margin
+}
+
+// CheckTimeout returns an error if a job timeout leaves too little room for the planned duration
+func CheckTimeout(job string, planned, timeout time.Duration) error {
+	if minimum := MinTimeout(planned); timeout < minimum {
+		return fmt.Errorf("job_timeout of %s is too short for the %s job, which is planned to run for %s; set job_timeout to at least %d",
+			timeout, job, planned.Round(time.Second), int(minimum.Round(time.Second).Seconds()))
+	}
+	return nil
+}
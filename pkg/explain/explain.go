@@ -0,0 +1,171 @@
+package explain
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Field is a configuration field with its YAML path, type and documentation
+type Field struct {
+	Path string
+	Type string
+	Doc  string
+}
+
+// Fields returns the fields of a configuration struct by YAML path, taking the names from
+// the yaml struct tags and the documentation from the field comments in the package source
+func Fields(t reflect.Type, prefix, source string) ([]Field, error) {
+	docs, err := fieldDocs(source)
+	if err != nil {
+		return nil, err
+	}
+
+	var fields []Field
+	walk(t, prefix, docs, &fields)
+	return fields, nil
+}
+
+// Lookup returns the field at path and the fields below it
+func Lookup(fields []Field, path string) (*Field, []Field) {
+	var field *Field
+	var children []Field
+	for i, f := range fields {
+		switch {
+		case f.Path == path:
+			field = &fields[i]
+		case path == "" || strings.HasPrefix(f.Path, path+"."):
+			children = append(children, f)
+		}
+	}
+	return field, children
+}
+
+// walk appends the fields of a struct type and recurses into nested structs
+func walk(t reflect.Type, prefix string, docs map[string]map[string]string, fields *[]Field) {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		name := strings.Split(sf.Tag.Get("yaml"), ",")[0]
+		if name == "" || name == "-" {
+			continue
+		}
+
+		path := name
+		if prefix != "" {
+			path = prefix + "." + name
+		}
+		*fields = append(*fields, Field{Path: path, Type: typeName(sf.Type), Doc: docs[t.Name()][name]})
+
+		if nested := structType(sf.Type); nested != nil {
+			walk(nested, path, docs, fields)
+		}
+	}
+}
+
+// structType returns the struct type of a struct, pointer or slice field, nil for other fields
+func structType(t reflect.Type) reflect.Type {
+	for t.Kind() == reflect.Ptr || t.Kind() == reflect.Slice {
+		t = t.Elem()
+	}
+	if t.Kind() == reflect.Struct {
+		return t
+	}
+	return nil
+}
+
+// typeName describes a field type in YAML terms
+func typeName(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.Ptr:
+		return typeName(t.Elem())
+	case reflect.Slice:
+		return "list of " + typeName(t.Elem())
+	case reflect.Map:
+		return "map of " + typeName(t.Elem())
+	case reflect.Struct:
+		return "object"
+	case reflect.Interface:
+		return "any"
+	case reflect.Int, reflect.Int32, reflect.Int64:
+		return "integer"
+	case reflect.Float32, reflect.Float64:
+		return "number"
+	case reflect.Bool:
+		return "boolean"
+	default:
+		return t.Kind().String()
+	}
+}
+
+// fieldDocs parses Go source and returns the field comments by struct type and YAML name.
+// The trailing comment of a field is preferred over the comment above it, which is skipped
+// when it heads a group of fields.
+func fieldDocs(source string) (map[string]map[string]string, error) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "", source, parser.ParseComments)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse configuration source: %w", err)
+	}
+
+	docs := make(map[string]map[string]string)
+	ast.Inspect(file, func(n ast.Node) bool {
+		spec, ok := n.(*ast.TypeSpec)
+		if !ok {
+			return true
+		}
+		st, ok := spec.Type.(*ast.StructType)
+		if !ok {
+			return false
+		}
+
+		byName := make(map[string]string)
+		for i, f := range st.Fields.List {
+			if f.Tag == nil {
+				continue
+			}
+			tag, err := strconv.Unquote(f.Tag.Value)
+			if err != nil {
+				continue
+			}
+			name := strings.Split(reflect.StructTag(tag).Get("yaml"), ",")[0]
+
+			doc := f.Comment.Text()
+			if doc == "" && !headsGroup(fset, st.Fields.List, i) {
+				doc = f.Doc.Text()
+			}
+			byName[name] = strings.Join(strings.Fields(doc), " ")
+		}
+		docs[spec.Name.Name] = byName
+		return false
+	})
+
+	return docs, nil
+}
+
+// headsGroup reports whether the field is directly followed by another field without a
+// comment of its own, so the comment above it describes the group rather than the field
+func headsGroup(fset *token.FileSet, fields []*ast.Field, i int) bool {
+	if i+1 >= len(fields) || fields[i+1].Doc != nil {
+		return false
+	}
+	return fset.Position(fields[i+1].Pos()).Line == fset.Position(fields[i].End()).Line+1
+}
+
+// Paths returns the sorted paths of the fields, used for shell completion
+func Paths(fields []Field) []string {
+	paths := make([]string, 0, len(fields))
+	for _, f := range fields {
+		paths = append(paths, f.Path)
+	}
+	sort.Strings(paths)
+	return paths
+}
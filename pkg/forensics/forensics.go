@@ -0,0 +1,289 @@
+package forensics
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+
+	"github.com/jtaleric/k8s-io/pkg/config"
+	"github.com/jtaleric/k8s-io/pkg/kubernetes"
+	"github.com/jtaleric/k8s-io/pkg/prometheus"
+)
+
+// headroom is the factor applied to the observed peak when suggesting resources
+const headroom = 1.5
+
+// Report is the forensic record of a benchmark pod that was OOM killed or evicted
+type Report struct {
+	Pod            string            `json:"pod"`
+	Node           string            `json:"node"`
+	Reason         string            `json:"reason"`
+	Message        string            `json:"message,omitempty"`
+	Containers     []ContainerReport `json:"containers"`
+	NodeConditions []NodeCondition   `json:"node_conditions,omitempty"`
+	Events         []string          `json:"events,omitempty"`
+	Suggestions    []string          `json:"suggestions,omitempty"`
+	CapturedAt     time.Time         `json:"captured_at"`
+}
+
+// ContainerReport is the last state and resources of a container
+type ContainerReport struct {
+	Name            string            `json:"name"`
+	Reason          string            `json:"reason,omitempty"`
+	ExitCode        int32             `json:"exit_code,omitempty"`
+	StartedAt       *time.Time        `json:"started_at,omitempty"`
+	FinishedAt      *time.Time        `json:"finished_at,omitempty"`
+	Requests        map[string]string `json:"requests,omitempty"`
+	Limits          map[string]string `json:"limits,omitempty"`
+	PeakMemoryBytes float64           `json:"peak_memory_bytes,omitempty"` // From Prometheus when available
+	memoryLimit     int64
+	memoryRequest   int64
+}
+
+// NodeCondition is a pressure condition of the node the pod ran on
+type NodeCondition struct {
+	Type    string `json:"type"`
+	Status  string `json:"status"`
+	Reason  string `json:"reason,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+// Capture writes a report for every pod of the run in the namespaces that was OOM killed or
+// evicted to <artifacts>/forensics/<pod>.json and logs its suggestions. Pods already reported
+// are skipped.
+func Capture(ctx context.Context, k8sClient *kubernetes.Client, cfg *config.Config, namespaces []string) []Report {
+	var pods []corev1.Pod
+	for _, ns := range namespaces {
+		list, err := k8sClient.ListPods(ctx, ns, fmt.Sprintf("benchmark-uuid=%s", cfg.UUID))
+		if err != nil {
+			log.Printf("Warning: failed to list pods for forensics: %v", err)
+			continue
+		}
+		pods = append(pods, list.Items...)
+	}
+
+	dir := filepath.Join(cfg.RunArtifactsDir(), "forensics")
+	var prom *prometheus.Client
+	var reports []Report
+
+	for _, pod := range pods {
+		failure := kubernetes.ClassifyPod(pod)
+		if !isResourceFailure(failure.Reason) {
+			continue
+		}
+
+		filename := filepath.Join(dir, pod.Name+".json")
+		if _, err := os.Stat(filename); err == nil {
+			continue
+		}
+
+		if prom == nil && cfg.Prometheus != nil {
+			if promInfo, err := k8sClient.DiscoverPrometheusWithConfig(ctx, cfg.Prometheus); err == nil && promInfo.Found {
+				prom = prometheus.NewClient(promInfo.URL, promInfo.Token)
+			}
+		}
+
+		report := buildReport(ctx, k8sClient, prom, pod, failure)
+		for _, suggestion := range report.Suggestions {
+			log.Printf("Pod %s was %s: %s", report.Pod, report.Reason, suggestion)
+		}
+
+		if err := write(filename, report); err != nil {
+			log.Printf("Warning: %v", err)
+		} else {
+			log.Printf("Forensics for pod %s written to %s", report.Pod, filename)
+		}
+		reports = append(reports, report)
+	}
+
+	return reports
+}
+
+// isResourceFailure reports whether a pod failure reason is an OOM kill or an eviction
+func isResourceFailure(reason string) bool {
+	return reason == "OOMKilled" || reason == "Evicted" || reason == "TerminationByKubelet"
+}
+
+// buildReport collects the last state, usage, node conditions and events of a pod
+func buildReport(ctx context.Context, k8sClient *kubernetes.Client, prom *prometheus.Client, pod corev1.Pod, failure kubernetes.JobFailure) Report {
+	report := Report{
+		Pod:        pod.Name,
+		Node:       pod.Spec.NodeName,
+		Reason:     failure.Reason,
+		Message:    failure.Message,
+		CapturedAt: time.Now().UTC(),
+	}
+
+	statuses := make(map[string]corev1.ContainerStatus)
+	for _, status := range pod.Status.ContainerStatuses {
+		statuses[status.Name] = status
+	}
+
+	for _, container := range pod.Spec.Containers {
+		c := ContainerReport{
+			Name:          container.Name,
+			Requests:      resourceStrings(container.Resources.Requests),
+			Limits:        resourceStrings(container.Resources.Limits),
+			memoryLimit:   container.Resources.Limits.Memory().Value(),
+			memoryRequest: container.Resources.Requests.Memory().Value(),
+		}
+
+		status := statuses[container.Name]
+		for _, state := range []corev1.ContainerState{status.State, status.LastTerminationState} {
+			if state.Terminated != nil {
+				c.Reason = state.Terminated.Reason
+				c.ExitCode = state.Terminated.ExitCode
+				started, finished := state.Terminated.StartedAt.Time, state.Terminated.FinishedAt.Time
+				c.StartedAt, c.FinishedAt = &started, &finished
+				break
+			}
+		}
+
+		if prom != nil {
+			var end time.Time
+			if c.FinishedAt != nil {
+				end = *c.FinishedAt
+			}
+			c.PeakMemoryBytes = peakMemory(ctx, prom, pod, container.Name, end)
+		}
+
+		report.Containers = append(report.Containers, c)
+	}
+
+	if pod.Spec.NodeName != "" {
+		if node, err := k8sClient.GetNode(ctx, pod.Spec.NodeName); err == nil {
+			for _, condition := range node.Status.Conditions {
+				if condition.Type == corev1.NodeReady {
+					continue
+				}
+				report.NodeConditions = append(report.NodeConditions, NodeCondition{
+					Type:    string(condition.Type),
+					Status:  string(condition.Status),
+					Reason:  condition.Reason,
+					Message: condition.Message,
+				})
+			}
+		} else {
+			log.Printf("Warning: failed to get node %s: %v", pod.Spec.NodeName, err)
+		}
+	}
+
+	if events, err := k8sClient.ListPodEvents(ctx, pod.Namespace, pod.Name); err == nil {
+		sort.Slice(events.Items, func(i, j int) bool {
+			return events.Items[i].LastTimestamp.Before(&events.Items[j].LastTimestamp)
+		})
+		for _, event := range events.Items {
+			report.Events = append(report.Events, fmt.Sprintf("%s %s %s: %s",
+				event.LastTimestamp.UTC().Format(time.RFC3339), event.Type, event.Reason, event.Message))
+		}
+	}
+
+	report.Suggestions = suggest(report)
+	return report
+}
+
+// peakMemory returns the highest working set of a container over the hour before it ended, or before now
+func peakMemory(ctx context.Context, prom *prometheus.Client, pod corev1.Pod, container string, end time.Time) float64 {
+	query := fmt.Sprintf(`max(max_over_time(container_memory_working_set_bytes{namespace="%s",pod="%s",container="%s"}[1h]))`,
+		pod.Namespace, pod.Name, container)
+	samples, err := prom.QueryAt(ctx, query, end)
+	if err != nil || len(samples) == 0 || math.IsNaN(samples[0].Value) {
+		return 0
+	}
+	return samples[0].Value
+}
+
+// suggest derives resource adjustments from the failure
+func suggest(report Report) []string {
+	var suggestions []string
+
+	for _, c := range report.Containers {
+		peak := int64(c.PeakMemoryBytes)
+
+		switch {
+		case report.Reason == "OOMKilled" && c.Reason == "OOMKilled" && c.memoryLimit > 0:
+			target := int64(float64(max(peak, c.memoryLimit)) * headroom)
+			suggestions = append(suggestions, fmt.Sprintf("container %s hit its %s memory limit, raise it to at least %s",
+				c.Name, formatBytes(c.memoryLimit), formatBytes(target)))
+		case report.Reason == "OOMKilled" && c.Reason == "OOMKilled":
+			suggestions = append(suggestions, fmt.Sprintf("container %s was killed by the node OOM killer without a memory limit, set a memory request%s so the scheduler reserves it",
+				c.Name, peakHint(peak)))
+		case report.Reason != "OOMKilled" && peak > c.memoryRequest:
+			suggestions = append(suggestions, fmt.Sprintf("container %s used %s but requested %s, set its memory request to at least %s to avoid eviction",
+				c.Name, formatBytes(peak), formatBytes(c.memoryRequest), formatBytes(int64(float64(peak)*headroom))))
+		}
+	}
+
+	for _, condition := range report.NodeConditions {
+		if condition.Status != string(corev1.ConditionTrue) {
+			continue
+		}
+		switch condition.Type {
+		case string(corev1.NodeDiskPressure):
+			suggestions = append(suggestions, fmt.Sprintf("node %s is under disk pressure, place the fio data on a PVC or request ephemeral-storage", report.Node))
+		case string(corev1.NodeMemoryPressure):
+			suggestions = append(suggestions, fmt.Sprintf("node %s is under memory pressure, give the benchmark pods memory requests equal to their limits or run on a less loaded node", report.Node))
+		case string(corev1.NodePIDPressure):
+			suggestions = append(suggestions, fmt.Sprintf("node %s is under PID pressure, reduce numjobs or the number of servers per node", report.Node))
+		}
+	}
+
+	if len(suggestions) == 0 {
+		suggestions = append(suggestions, "no resource data explains the failure, check the events and node conditions in the report")
+	}
+	return suggestions
+}
+
+// peakHint describes the observed peak for a suggestion
+func peakHint(peak int64) string {
+	if peak <= 0 {
+		return ""
+	}
+	return fmt.Sprintf(" of at least %s", formatBytes(int64(float64(peak)*headroom)))
+}
+
+// formatBytes formats a byte count as a Kubernetes quantity
+func formatBytes(bytes int64) string {
+	// Round up to whole MiB so the quantity reads like a typical resource setting
+	const mib = 1024 * 1024
+	bytes = (bytes + mib - 1) / mib * mib
+	return resource.NewQuantity(bytes, resource.BinarySI).String()
+}
+
+// resourceStrings converts a resource list to strings
+func resourceStrings(resources corev1.ResourceList) map[string]string {
+	if len(resources) == 0 {
+		return nil
+	}
+	result := make(map[string]string, len(resources))
+	for name, quantity := range resources {
+		result[string(name)] = quantity.String()
+	}
+	return result
+}
+
+// write writes a report as indented JSON
+func write(filename string, report Report) error {
+	if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+		return fmt.Errorf("failed to create forensics directory: %w", err)
+	}
+
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal forensics report: %w", err)
+	}
+
+	if err := os.WriteFile(filename, data, 0644); err != nil {
+		return fmt.Errorf("failed to write forensics report: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,89 @@
+package grafana
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/jtaleric/k8s-io/pkg/config"
+	"github.com/jtaleric/k8s-io/pkg/timeline"
+)
+
+// annotation is a Grafana region annotation
+type annotation struct {
+	DashboardUID string   `json:"dashboardUID,omitempty"`
+	Time         int64    `json:"time"`
+	TimeEnd      int64    `json:"timeEnd"`
+	Tags         []string `json:"tags"`
+	Text         string   `json:"text"`
+}
+
+// AnnotatePhases creates a Grafana region annotation for every phase of the run, so the
+// exact benchmark window is visible on infrastructure dashboards
+func AnnotatePhases(ctx context.Context, cfg *config.Config, phases []timeline.Phase) error {
+	token := cfg.Grafana.Token
+	if token == "" {
+		token = os.Getenv(cfg.Grafana.TokenEnv)
+	}
+
+	tags := append([]string{"k8s-io", cfg.Workload.Name, cfg.UUID}, cfg.Grafana.Tags...)
+	for key, value := range cfg.Tags {
+		tags = append(tags, key+":"+value)
+	}
+
+	for _, phase := range phases {
+		a := annotation{
+			DashboardUID: cfg.Grafana.DashboardUID,
+			Time:         phase.Start.UnixMilli(),
+			TimeEnd:      phase.End.UnixMilli(),
+			Tags:         append(append([]string{}, tags...), phase.Name),
+			Text:         fmt.Sprintf("k8s-io %s %s: %s", cfg.Workload.Name, cfg.UUID, phase.Name),
+		}
+		if phase.Error != "" {
+			a.Text += " (failed: " + phase.Error + ")"
+		}
+
+		if err := post(ctx, cfg.Grafana.URL, token, a); err != nil {
+			return fmt.Errorf("failed to annotate phase %s: %w", phase.Name, err)
+		}
+	}
+
+	return nil
+}
+
+// post sends a single annotation to the Grafana API
+func post(ctx context.Context, url, token string, a annotation) error {
+	payload, err := json.Marshal(a)
+	if err != nil {
+		return fmt.Errorf("failed to marshal annotation: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimSuffix(url, "/")+"/api/annotations", bytes.NewReader(payload))
+	if err != nil {
+		return fmt.Errorf("failed to create annotation request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to post annotation: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("grafana returned status %s", resp.Status)
+	}
+
+	return nil
+}
@@ -0,0 +1,154 @@
+package guest
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/jtaleric/k8s-io/pkg/config"
+)
+
+// Tuning is the guest OS tuning applied by cloud-init before a VM benchmark starts
+type Tuning struct {
+	Scheduler    string `yaml:"scheduler,omitempty" json:"scheduler,omitempty"`        // I/O scheduler of every disk, such as "none" (elevator=none) or "mq-deadline"
+	MountOptions string `yaml:"mount_options,omitempty" json:"mountOptions,omitempty"` // Mount options of the data filesystem, such as "noatime,nodiratime"
+	HugePages    int    `yaml:"hugepages,omitempty" json:"hugepages,omitempty"`        // 2Mi huge pages to reserve
+	CPUGovernor  string `yaml:"cpu_governor,omitempty" json:"cpuGovernor,omitempty"`   // cpufreq governor of every vCPU, such as "performance"
+}
+
+var (
+	// schedulerNames are the blk-mq schedulers of current kernels
+	schedulerNames = map[string]bool{"none": true, "mq-deadline": true, "bfq": true, "kyber": true}
+
+	// governorNames are the cpufreq governors of current kernels
+	governorNames = map[string]bool{"performance": true, "powersave": true, "ondemand": true, "conservative": true, "schedutil": true, "userspace": true}
+
+	// mountOptionsPattern matches comma separated mount options, which are rendered into a
+	// quoted cloud-init command
+	mountOptionsPattern = regexp.MustCompile(`^[A-Za-z0-9_=.]+(,[A-Za-z0-9_=.]+)*$`)
+)
+
+// Validate checks the tunings
+func (t *Tuning) Validate() error {
+	if t == nil {
+		return nil
+	}
+	if t.Scheduler != "" && !schedulerNames[t.Scheduler] {
+		return fmt.Errorf("guest_tuning scheduler must be one of none, mq-deadline, bfq or kyber")
+	}
+	if t.MountOptions != "" && !mountOptionsPattern.MatchString(t.MountOptions) {
+		return fmt.Errorf("guest_tuning mount_options must be comma separated options such as noatime,nodiratime")
+	}
+	if t.HugePages < 0 {
+		return fmt.Errorf("guest_tuning hugepages must not be negative")
+	}
+	if t.CPUGovernor != "" && !governorNames[t.CPUGovernor] {
+		return fmt.Errorf("guest_tuning cpu_governor must be one of performance, powersave, ondemand, conservative, schedutil or userspace")
+	}
+	return nil
+}
+
+// MountFlag returns the mount arguments for the mount options, empty without options
+func (t *Tuning) MountFlag() string {
+	if t == nil || t.MountOptions == "" {
+		return ""
+	}
+	return "-o " + t.MountOptions
+}
+
+// Commands returns the shell commands applying the scheduler, huge pages and CPU governor,
+// for the bootcmd of cloud-init so they run before the benchmark. Every command prints what
+// it applied, or that it could not, to the console.
+func (t *Tuning) Commands() []string {
+	if t == nil {
+		return nil
+	}
+
+	var cmds []string
+	if t.Scheduler != "" {
+		cmds = append(cmds, fmt.Sprintf("for q in /sys/block/*/queue/scheduler; do echo %s > $q && echo GUEST_TUNING scheduler $q $(cat $q) || echo GUEST_TUNING scheduler $q failed; done > /dev/console", t.Scheduler))
+	}
+	if t.HugePages > 0 {
+		cmds = append(cmds, fmt.Sprintf("sysctl -w vm.nr_hugepages=%d; echo GUEST_TUNING hugepages $(cat /proc/sys/vm/nr_hugepages) > /dev/console", t.HugePages))
+	}
+	if t.CPUGovernor != "" {
+		cmds = append(cmds, fmt.Sprintf("for g in /sys/devices/system/cpu/cpu*/cpufreq/scaling_governor; do echo %s > $g && echo GUEST_TUNING cpu_governor $g %s || echo GUEST_TUNING cpu_governor $g failed; done > /dev/console", t.CPUGovernor, t.CPUGovernor))
+	}
+	return cmds
+}
+
+// Metadata returns the tunings as run metadata, empty without tunings
+func (t *Tuning) Metadata() map[string]string {
+	if t == nil {
+		return nil
+	}
+
+	metadata := make(map[string]string)
+	if t.Scheduler != "" {
+		metadata["guestScheduler"] = t.Scheduler
+	}
+	if t.MountOptions != "" {
+		metadata["guestMountOptions"] = t.MountOptions
+	}
+	if t.HugePages > 0 {
+		metadata["guestHugePages"] = strconv.Itoa(t.HugePages)
+	}
+	if t.CPUGovernor != "" {
+		metadata["guestCPUGovernor"] = t.CPUGovernor
+	}
+	return metadata
+}
+
+// Record adds the tunings to the run metadata and writes them with the commands applying
+// them to guest-tuning.json in the run artifacts directory
+func (t *Tuning) Record(cfg *config.Config) error {
+	if t == nil {
+		return nil
+	}
+	if cfg.Metadata == nil {
+		cfg.Metadata = make(map[string]string)
+	}
+	for k, v := range t.Metadata() {
+		cfg.Metadata[k] = v
+	}
+	log.Printf("Guest tuning: %s", t)
+
+	dir := cfg.RunArtifactsDir()
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create artifacts directory: %w", err)
+	}
+
+	record := struct {
+		*Tuning
+		Commands []string `json:"commands"`
+	}{t, t.Commands()}
+	if flag := t.MountFlag(); flag != "" {
+		record.Commands = append(record.Commands, "mount "+flag+" <data disk> <data path>")
+	}
+
+	data, err := json.MarshalIndent(record, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode guest tuning: %w", err)
+	}
+	filename := filepath.Join(dir, "guest-tuning.json")
+	if err := os.WriteFile(filename, data, 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", filename, err)
+	}
+	return nil
+}
+
+// String returns the tunings as sorted key=value pairs
+func (t *Tuning) String() string {
+	var pairs []string
+	for key, value := range t.Metadata() {
+		pairs = append(pairs, key+"="+value)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ", ")
+}
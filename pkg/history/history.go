@@ -0,0 +1,266 @@
+package history
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/jtaleric/k8s-io/pkg/config"
+)
+
+// SchemaVersion is the version of the history record schema written by Append.
+// Version 1 records predate versioning and have no schema_version field.
+const SchemaVersion = 4
+
+// Record represents the key metrics of a single benchmark run
+type Record struct {
+	SchemaVersion int                `json:"schema_version"`
+	UUID          string             `json:"uuid"`
+	Workload      string             `json:"workload"`
+	ConfigHash    string             `json:"config_hash"` // Config fingerprint of the run
+	Timestamp     time.Time          `json:"timestamp"`
+	Metrics       map[string]float64 `json:"metrics"`
+	Tags          map[string]string  `json:"tags,omitempty"`      // Configured tags of the run
+	Owner         string             `json:"owner,omitempty"`     // User requesting the run
+	Namespace     string             `json:"namespace,omitempty"` // Namespace of the benchmark
+}
+
+// Anomaly represents a metric that deviates from the rolling baseline
+type Anomaly struct {
+	Metric  string  `json:"metric"`
+	Value   float64 `json:"value"`
+	Mean    float64 `json:"mean"`
+	Stddev  float64 `json:"stddev"`
+	Sigmas  float64 `json:"sigmas"`
+	Samples int     `json:"samples"`
+}
+
+// String returns a human readable description of the anomaly
+func (a Anomaly) String() string {
+	return fmt.Sprintf("%s = %.2f deviates %.1f stddev from baseline mean %.2f (stddev %.2f, %d runs)",
+		a.Metric, a.Value, a.Sigmas, a.Mean, a.Stddev, a.Samples)
+}
+
+// minBaselineRuns is the minimum number of previous runs needed to compute a baseline
+const minBaselineRuns = 3
+
+// Store is a file-backed history of benchmark runs, one JSON record per line
+type Store struct {
+	path string
+}
+
+// NewStore creates a new history store backed by the given file
+func NewStore(path string) *Store {
+	return &Store{path: path}
+}
+
+// Load loads all records from the store, oldest first
+func (s *Store) Load() ([]Record, error) {
+	file, err := os.Open(s.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to open history file %s: %w", s.path, err)
+	}
+	defer file.Close()
+
+	var records []Record
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+
+		var record Record
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			return nil, fmt.Errorf("failed to parse history file %s: %w", s.path, err)
+		}
+
+		record, err := upgradeRecord(record)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse history file %s: %w", s.path, err)
+		}
+		records = append(records, record)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read history file %s: %w", s.path, err)
+	}
+
+	return records, nil
+}
+
+// Append adds a record to the store
+func (s *Store) Append(record Record) error {
+	record.SchemaVersion = SchemaVersion
+
+	data, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("failed to marshal history record: %w", err)
+	}
+
+	file, err := os.OpenFile(s.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open history file %s: %w", s.path, err)
+	}
+	defer file.Close()
+
+	if _, err := file.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to write history file %s: %w", s.path, err)
+	}
+
+	return nil
+}
+
+// upgradeRecord converts a record of an older schema version to the current one
+func upgradeRecord(record Record) (Record, error) {
+	if record.SchemaVersion == 0 {
+		record.SchemaVersion = 1
+	}
+
+	if record.SchemaVersion > SchemaVersion {
+		return record, fmt.Errorf("record %s has unsupported schema version %d", record.UUID, record.SchemaVersion)
+	}
+
+	// v1 -> v2: records gained the schema_version field, the metrics are unchanged
+	if record.SchemaVersion == 1 {
+		record.SchemaVersion = 2
+	}
+
+	// v2 -> v3: records gained the optional tags field
+	if record.SchemaVersion == 2 {
+		record.SchemaVersion = 3
+	}
+
+	// v3 -> v4: records gained the optional owner and namespace fields
+	if record.SchemaVersion == 3 {
+		record.SchemaVersion = 4
+	}
+
+	return record, nil
+}
+
+// Find returns the record with the given UUID
+func Find(records []Record, uuid string) (Record, bool) {
+	for _, r := range records {
+		if r.UUID == uuid {
+			return r, true
+		}
+	}
+	return Record{}, false
+}
+
+// Baseline returns the most recent records for the same workload and config hash, oldest first
+func Baseline(records []Record, record Record, window int) []Record {
+	var baseline []Record
+	for _, r := range records {
+		if r.UUID == record.UUID || r.Workload != record.Workload || r.ConfigHash != record.ConfigHash {
+			continue
+		}
+		baseline = append(baseline, r)
+	}
+
+	if window > 0 && len(baseline) > window {
+		baseline = baseline[len(baseline)-window:]
+	}
+
+	return baseline
+}
+
+// DetectAnomalies compares a record against its baseline and returns the metrics
+// that deviate by more than the given number of standard deviations
+func DetectAnomalies(record Record, baseline []Record, stdDevs float64) []Anomaly {
+	var anomalies []Anomaly
+
+	metrics := make([]string, 0, len(record.Metrics))
+	for metric := range record.Metrics {
+		metrics = append(metrics, metric)
+	}
+	sort.Strings(metrics)
+
+	for _, metric := range metrics {
+		var values []float64
+		for _, r := range baseline {
+			if value, ok := r.Metrics[metric]; ok {
+				values = append(values, value)
+			}
+		}
+
+		if len(values) < minBaselineRuns {
+			continue
+		}
+
+		mean, stddev := meanStddev(values)
+		if stddev == 0 {
+			continue
+		}
+
+		value := record.Metrics[metric]
+		sigmas := math.Abs(value-mean) / stddev
+		if sigmas > stdDevs {
+			anomalies = append(anomalies, Anomaly{
+				Metric:  metric,
+				Value:   value,
+				Mean:    mean,
+				Stddev:  stddev,
+				Sigmas:  sigmas,
+				Samples: len(values),
+			})
+		}
+	}
+
+	return anomalies
+}
+
+// meanStddev returns the mean and sample standard deviation of the values
+func meanStddev(values []float64) (float64, float64) {
+	var sum float64
+	for _, v := range values {
+		sum += v
+	}
+	mean := sum / float64(len(values))
+
+	var sq float64
+	for _, v := range values {
+		sq += (v - mean) * (v - mean)
+	}
+
+	return mean, math.Sqrt(sq / float64(len(values)-1))
+}
+
+// Check compares a record against the rolling baseline in the configured history,
+// logs and reports any anomalies, and then appends the record to the history
+func Check(ctx context.Context, cfg *config.HistoryConfig, record Record) ([]Anomaly, error) {
+	store := NewStore(cfg.Path)
+
+	records, err := store.Load()
+	if err != nil {
+		return nil, err
+	}
+
+	baseline := Baseline(records, record, cfg.Window)
+	anomalies := DetectAnomalies(record, baseline, cfg.StdDevs)
+	for _, anomaly := range anomalies {
+		log.Printf("Warning: anomaly detected for config %s: %s", record.ConfigHash, anomaly)
+	}
+
+	if len(anomalies) > 0 && cfg.Webhook != "" {
+		if err := NotifyWebhook(ctx, cfg.Webhook, record, anomalies); err != nil {
+			log.Printf("Warning: failed to notify anomaly webhook: %v", err)
+		}
+	}
+
+	if err := store.Append(record); err != nil {
+		return anomalies, err
+	}
+
+	return anomalies, nil
+}
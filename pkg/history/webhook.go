@@ -0,0 +1,52 @@
+package history
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// webhookPayload is the JSON body posted to the anomaly webhook
+type webhookPayload struct {
+	UUID       string    `json:"uuid"`
+	Workload   string    `json:"workload"`
+	ConfigHash string    `json:"config_hash"`
+	Anomalies  []Anomaly `json:"anomalies"`
+}
+
+// NotifyWebhook posts the detected anomalies to the given URL
+func NotifyWebhook(ctx context.Context, url string, record Record, anomalies []Anomaly) error {
+	body, err := json.Marshal(webhookPayload{
+		UUID:       record.UUID,
+		Workload:   record.Workload,
+		ConfigHash: record.ConfigHash,
+		Anomalies:  anomalies,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal webhook payload: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create webhook request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to post webhook: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned status %s", resp.Status)
+	}
+
+	return nil
+}
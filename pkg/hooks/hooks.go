@@ -0,0 +1,187 @@
+package hooks
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+	"time"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+
+	"github.com/jtaleric/k8s-io/pkg/config"
+	"github.com/jtaleric/k8s-io/pkg/kubernetes"
+	"github.com/jtaleric/k8s-io/pkg/timeline"
+)
+
+// Stages of the run hooks are attached to, used in the job names and K8SIO_STAGE
+const (
+	StagePreRun     = "pre-run"
+	StagePostSample = "post-sample"
+	StagePostRun    = "post-run"
+)
+
+// Runner runs the hooks of a run
+type Runner struct {
+	k8sClient *kubernetes.Client
+	cfg       *config.Config
+	jobs      int // Hook jobs created so far, numbers the job names
+}
+
+// NewRunner creates a runner for the hooks of a run
+func NewRunner(k8sClient *kubernetes.Client, cfg *config.Config) *Runner {
+	return &Runner{k8sClient: k8sClient, cfg: cfg}
+}
+
+// Run runs the hooks of a stage in order and stops at the first failure. The hooks get the
+// run UUID, namespace, workload and stage in K8SIO_* environment variables, followed by env
+// and their own variables.
+func (r *Runner) Run(ctx context.Context, stage string, hooks []config.Hook, env map[string]string) error {
+	for _, h := range hooks {
+		vars := map[string]string{
+			"K8SIO_UUID":      r.cfg.UUID,
+			"K8SIO_NAMESPACE": r.cfg.Namespace,
+			"K8SIO_WORKLOAD":  r.cfg.Workload.Name,
+			"K8SIO_STAGE":     stage,
+		}
+		for k, v := range env {
+			vars[k] = v
+		}
+		for k, v := range h.Env {
+			vars[k] = v
+		}
+
+		// The timeout was validated with the configuration
+		timeout, _ := time.ParseDuration(h.Timeout)
+
+		log.Printf("Running %s hook %s", stage, h.Name)
+		start := time.Now()
+
+		var err error
+		if h.Image != "" {
+			err = r.runJob(ctx, stage, h, vars, timeout)
+		} else {
+			err = runLocal(ctx, h, vars, timeout)
+		}
+		if err != nil {
+			return fmt.Errorf("%s hook %s failed: %w", stage, h.Name, err)
+		}
+		log.Printf("Hook %s completed in %s", h.Name, time.Since(start).Round(time.Second))
+	}
+	return nil
+}
+
+// runLocal runs a hook command on this machine
+func runLocal(ctx context.Context, h config.Hook, vars map[string]string, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, h.Command[0], h.Command[1:]...)
+	cmd.Env = append(os.Environ(), envList(vars)...)
+
+	output, err := cmd.CombinedOutput()
+	logOutput(h.Name, string(output))
+
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return fmt.Errorf("timed out after %s", timeout)
+	}
+	return err
+}
+
+// runJob runs a hook command in a Job in the benchmark namespace and waits for it
+func (r *Runner) runJob(ctx context.Context, stage string, h config.Hook, vars map[string]string, timeout time.Duration) error {
+	r.jobs++
+	name := fmt.Sprintf("%s-%s-%s-%d", stage, h.Name, r.cfg.GetTruncatedUUID(), r.jobs)
+	labels := map[string]string{
+		"app":            "k8s-io-hook-" + r.cfg.GetTruncatedUUID(),
+		"benchmark-uuid": r.cfg.UUID,
+	}
+
+	env := make([]corev1.EnvVar, 0, len(vars))
+	for _, kv := range envList(vars) {
+		k, v, _ := strings.Cut(kv, "=")
+		env = append(env, corev1.EnvVar{Name: k, Value: v})
+	}
+
+	backoffLimit := int32(0)
+	deadline := int64(timeout.Seconds())
+	job := &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: r.cfg.Namespace, Labels: labels},
+		Spec: batchv1.JobSpec{
+			BackoffLimit:          &backoffLimit,
+			ActiveDeadlineSeconds: &deadline,
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{Labels: labels},
+				Spec: corev1.PodSpec{
+					RestartPolicy: corev1.RestartPolicyNever,
+					Containers: []corev1.Container{{
+						Name:    "hook",
+						Image:   h.Image,
+						Command: h.Command,
+						Env:     env,
+					}},
+				},
+			},
+		},
+	}
+
+	if _, err := r.k8sClient.CreateJob(ctx, job); err != nil {
+		return err
+	}
+
+	// Hooks are short, so poll more often than WaitForJobCompletion to keep the gaps between
+	// samples small. The deadline fails the job itself, the margin covers scheduling.
+	err := wait.PollImmediate(2*time.Second, timeout+time.Minute, func() (bool, error) {
+		job, err := r.k8sClient.GetJob(ctx, name, r.cfg.Namespace)
+		if err != nil {
+			if !kubernetes.Retryable(err) {
+				return false, fmt.Errorf("failed to get hook job %s status: %w", name, err)
+			}
+			log.Printf("Warning: failed to get hook job %s status (will retry): %v", name, err)
+			timeline.Record(timeline.EventRetry, "Job/"+name, "transient error: %v", err)
+			return false, nil
+		}
+		if job.Status.Succeeded > 0 {
+			return true, nil
+		}
+		if job.Status.Failed > 0 {
+			return false, fmt.Errorf("job %s failed", name)
+		}
+		return false, nil
+	})
+
+	if logs, lerr := r.k8sClient.GetJobPodLogs(ctx, name, r.cfg.Namespace); lerr == nil {
+		logOutput(h.Name, logs)
+	}
+
+	if errors.Is(err, wait.ErrWaitTimeout) {
+		return fmt.Errorf("job %s timed out after %s", name, timeout)
+	}
+	return err
+}
+
+// envList returns variables as sorted KEY=value pairs
+func envList(vars map[string]string) []string {
+	list := make([]string, 0, len(vars))
+	for k, v := range vars {
+		list = append(list, k+"="+v)
+	}
+	sort.Strings(list)
+	return list
+}
+
+// logOutput logs every line of the output of a hook, prefixed with its name
+func logOutput(name, output string) {
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	for scanner.Scan() {
+		log.Printf("[%s] %s", name, scanner.Text())
+	}
+}
@@ -0,0 +1,61 @@
+package junit
+
+import (
+	"encoding/xml"
+	"fmt"
+	"os"
+)
+
+// TestSuites is the root element of a JUnit XML report
+type TestSuites struct {
+	XMLName xml.Name    `xml:"testsuites"`
+	Suites  []TestSuite `xml:"testsuite"`
+}
+
+// TestSuite groups the test cases of one benchmark run
+type TestSuite struct {
+	Name      string     `xml:"name,attr"`
+	Tests     int        `xml:"tests,attr"`
+	Failures  int        `xml:"failures,attr"`
+	Time      float64    `xml:"time,attr"`
+	TestCases []TestCase `xml:"testcase"`
+}
+
+// TestCase represents one job sample
+type TestCase struct {
+	Name      string   `xml:"name,attr"`
+	ClassName string   `xml:"classname,attr"`
+	Time      float64  `xml:"time,attr"`
+	Failure   *Failure `xml:"failure,omitempty"`
+}
+
+// Failure describes why a test case failed
+type Failure struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// AddTestCase adds a test case to the suite and updates the totals
+func (s *TestSuite) AddTestCase(tc TestCase) {
+	s.TestCases = append(s.TestCases, tc)
+	s.Tests++
+	s.Time += tc.Time
+	if tc.Failure != nil {
+		s.Failures++
+	}
+}
+
+// Write writes the suite as a JUnit XML report
+func Write(filename string, suite TestSuite) error {
+	data, err := xml.MarshalIndent(TestSuites{Suites: []TestSuite{suite}}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal JUnit report: %w", err)
+	}
+
+	if err := os.WriteFile(filename, append([]byte(xml.Header), append(data, '\n')...), 0644); err != nil {
+		return fmt.Errorf("failed to write JUnit report %s: %w", filename, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,131 @@
+package kubernetes
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/runtime/serializer/yaml"
+)
+
+var (
+	webhookDenialPattern   = regexp.MustCompile(`admission webhook "([^"]+)" denied the request`)
+	podSecurityPattern     = regexp.MustCompile(`violates PodSecurity "([^"]+)"`)
+	admissionPolicyPattern = regexp.MustCompile(`ValidatingAdmissionPolicy '([^']+)'`)
+
+	// Gatekeeper prefixes every violation with [constraint-name], Kyverno lists the
+	// policies as "policy-name:" lines followed by their indented rules
+	gatekeeperPattern = regexp.MustCompile(`(?:^|\s)\[([a-z0-9][a-z0-9.-]*)\]\s`)
+	kyvernoPattern    = regexp.MustCompile(`(?m)^([a-z0-9][a-z0-9.-]*):\s*$`)
+)
+
+// AdmissionRejection is a resource the admission chain of the API server refused
+type AdmissionRejection struct {
+	Kind     string
+	Name     string
+	Webhook  string   // Webhook that denied the resource, empty for built-in admission such as PodSecurity
+	Policies []string // Policies or constraints the message names as violated
+	Message  string
+}
+
+func (r *AdmissionRejection) Error() string {
+	by := "admission"
+	if r.Webhook != "" {
+		by = "admission webhook " + r.Webhook
+	}
+	if len(r.Policies) > 0 {
+		return fmt.Sprintf("%s rejected %s/%s, violated policies: %s", by, r.Kind, r.Name, strings.Join(r.Policies, ", "))
+	}
+	return fmt.Sprintf("%s rejected %s/%s: %s", by, r.Kind, r.Name, r.Message)
+}
+
+// DryRunPod server-side dry-runs the creation of the pod of a manifest: the manifest itself
+// when it is a Pod, or the pod template of a Job, DaemonSet or Deployment, since admission
+// judges the pods controllers create and not only the controllers. The pod carries the
+// labels and annotations of the client, and nothing is persisted. It returns an
+// *AdmissionRejection when admission refuses the pod, and found false when the manifest has
+// no pod.
+func (c *Client) DryRunPod(ctx context.Context, manifestYAML string, namespace string) (found bool, err error) {
+	obj := &unstructured.Unstructured{}
+	dec := yaml.NewDecodingSerializer(unstructured.UnstructuredJSONScheme)
+	if _, _, err := dec.Decode([]byte(manifestYAML), nil, obj); err != nil {
+		return false, fmt.Errorf("failed to decode manifest: %w", err)
+	}
+	if obj.GetNamespace() == "" && namespace != "" {
+		obj.SetNamespace(namespace)
+	}
+	addLabels(obj, c.labels)
+	addAnnotations(obj, c.annotations)
+
+	pod := obj
+	if obj.GetKind() != "Pod" {
+		template, hasTemplate, _ := unstructured.NestedMap(obj.Object, "spec", "template")
+		if !hasTemplate {
+			return false, nil
+		}
+		metadata, _, _ := unstructured.NestedMap(template, "metadata")
+		spec, _, _ := unstructured.NestedMap(template, "spec")
+		if spec == nil {
+			return false, nil
+		}
+		pod = &unstructured.Unstructured{Object: map[string]interface{}{
+			"apiVersion": "v1",
+			"kind":       "Pod",
+			"metadata":   metadata,
+			"spec":       spec,
+		}}
+		pod.SetName(strings.ToLower(obj.GetKind()) + "-" + obj.GetName())
+		pod.SetNamespace(obj.GetNamespace())
+	}
+
+	gvr := schema.GroupVersionResource{Version: "v1", Resource: "pods"}
+	_, err = c.dynamicClient.Resource(gvr).Namespace(pod.GetNamespace()).Create(ctx, pod, metav1.CreateOptions{
+		DryRun: []string{metav1.DryRunAll},
+	})
+	if err == nil {
+		return true, nil
+	}
+	if rejection := parseAdmissionRejection(obj.GetKind(), obj.GetName(), err); rejection != nil {
+		return true, rejection
+	}
+	return true, fmt.Errorf("failed to dry-run the pod of %s/%s: %w", obj.GetKind(), obj.GetName(), err)
+}
+
+// parseAdmissionRejection returns the rejection of an admission error, nil for other errors
+// such as missing RBAC permissions or webhooks that do not support dry-run
+func parseAdmissionRejection(kind, name string, err error) *AdmissionRejection {
+	message := err.Error()
+	rejection := &AdmissionRejection{Kind: kind, Name: name, Message: message}
+
+	switch {
+	case webhookDenialPattern.MatchString(message):
+		rejection.Webhook = webhookDenialPattern.FindStringSubmatch(message)[1]
+		denial := message[webhookDenialPattern.FindStringIndex(message)[1]:]
+		for _, pattern := range []*regexp.Regexp{gatekeeperPattern, kyvernoPattern} {
+			for _, m := range pattern.FindAllStringSubmatch(denial, -1) {
+				rejection.Policies = append(rejection.Policies, m[1])
+			}
+		}
+	case podSecurityPattern.MatchString(message):
+		rejection.Policies = []string{"PodSecurity " + podSecurityPattern.FindStringSubmatch(message)[1]}
+	case admissionPolicyPattern.MatchString(message):
+		rejection.Policies = []string{admissionPolicyPattern.FindStringSubmatch(message)[1]}
+	default:
+		return nil
+	}
+
+	sort.Strings(rejection.Policies)
+	policies := rejection.Policies[:0]
+	for i, policy := range rejection.Policies {
+		if i == 0 || policy != rejection.Policies[i-1] {
+			policies = append(policies, policy)
+		}
+	}
+	rejection.Policies = policies
+	return rejection
+}
@@ -0,0 +1,84 @@
+package kubernetes
+
+import (
+	"context"
+	"fmt"
+
+	authenticationv1 "k8s.io/api/authentication/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// WhoAmI returns the user the client authenticates as, like `kubectl auth whoami`
+func (c *Client) WhoAmI(ctx context.Context) (string, error) {
+	review, err := c.clientset.AuthenticationV1().SelfSubjectReviews().Create(ctx, &authenticationv1.SelfSubjectReview{}, metav1.CreateOptions{})
+	if err != nil {
+		return "", fmt.Errorf("failed to review the identity of the client: %w", err)
+	}
+	return review.Status.UserInfo.Username, nil
+}
+
+// Authenticated reports whether the API server accepts the credentials of the client, false
+// when it rejects them as expired or invalid. Every authenticated user may review its own
+// access, so unlike WhoAmI this works on clusters of any version.
+func (c *Client) Authenticated(ctx context.Context) (bool, error) {
+	_, err := c.clientset.AuthorizationV1().SelfSubjectAccessReviews().Create(ctx, &authorizationv1.SelfSubjectAccessReview{
+		Spec: authorizationv1.SelfSubjectAccessReviewSpec{
+			ResourceAttributes: &authorizationv1.ResourceAttributes{Verb: "get", Resource: "namespaces"},
+		},
+	}, metav1.CreateOptions{})
+	if apierrors.IsUnauthorized(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to review access of the client: %w", classify(err))
+	}
+	return true, nil
+}
+
+// AuthenticateToken returns the user of a bearer token, failing when the API server
+// does not accept the token
+func (c *Client) AuthenticateToken(ctx context.Context, token string) (authenticationv1.UserInfo, error) {
+	review, err := c.clientset.AuthenticationV1().TokenReviews().Create(ctx, &authenticationv1.TokenReview{
+		Spec: authenticationv1.TokenReviewSpec{Token: token},
+	}, metav1.CreateOptions{})
+	if err != nil {
+		return authenticationv1.UserInfo{}, fmt.Errorf("failed to review token: %w", err)
+	}
+	if !review.Status.Authenticated {
+		if review.Status.Error != "" {
+			return authenticationv1.UserInfo{}, fmt.Errorf("token not authenticated: %s", review.Status.Error)
+		}
+		return authenticationv1.UserInfo{}, fmt.Errorf("token not authenticated")
+	}
+	return review.Status.User, nil
+}
+
+// CanAccess reports whether RBAC allows the user the verb on the resource of the API group
+// in the namespace, or in every namespace for an empty namespace
+func (c *Client) CanAccess(ctx context.Context, user authenticationv1.UserInfo, namespace, verb, group, resource string) (bool, error) {
+	extra := make(map[string]authorizationv1.ExtraValue, len(user.Extra))
+	for k, v := range user.Extra {
+		extra[k] = authorizationv1.ExtraValue(v)
+	}
+
+	review, err := c.clientset.AuthorizationV1().SubjectAccessReviews().Create(ctx, &authorizationv1.SubjectAccessReview{
+		Spec: authorizationv1.SubjectAccessReviewSpec{
+			User:   user.Username,
+			UID:    user.UID,
+			Groups: user.Groups,
+			Extra:  extra,
+			ResourceAttributes: &authorizationv1.ResourceAttributes{
+				Namespace: namespace,
+				Verb:      verb,
+				Group:     group,
+				Resource:  resource,
+			},
+		},
+	}, metav1.CreateOptions{})
+	if err != nil {
+		return false, fmt.Errorf("failed to review access of %s: %w", user.Username, err)
+	}
+	return review.Status.Allowed, nil
+}
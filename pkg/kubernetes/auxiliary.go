@@ -0,0 +1,204 @@
+package kubernetes
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Names and labels of the service account and token secret created for Prometheus access.
+// They are created in the namespace of Prometheus, outside the namespaces of the run, so
+// the run cleanup does not find them by namespace. Runs add their truncated UUID to the
+// names, versions before that shared the plain names.
+const (
+	prometheusSAName     = "k8s-io-prometheus"
+	prometheusSecretName = "k8s-io-prometheus-token"
+	auxiliaryLabel       = "k8s-io/auxiliary"
+	auxiliaryRunLabel    = "benchmark-uuid"
+)
+
+// AuxiliaryResource is a resource the client created outside the manifests of a run, such
+// as the service account for Prometheus access
+type AuxiliaryResource struct {
+	Kind      string
+	Namespace string
+	Name      string
+	Run       string    // UUID of the run that created it, empty for resources of older versions
+	Created   time.Time // Only set when listed
+}
+
+// auxiliary tracks the auxiliary resources a client created, the zero value is ready to use
+type auxiliary struct {
+	mu        sync.Mutex
+	run       string
+	resources []AuxiliaryResource
+}
+
+// track records a resource the client created, once
+func (a *auxiliary) track(kind, namespace, name string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for _, r := range a.resources {
+		if r.Kind == kind && r.Namespace == namespace && r.Name == name {
+			return
+		}
+	}
+	a.resources = append(a.resources, AuxiliaryResource{Kind: kind, Namespace: namespace, Name: name, Run: a.run})
+}
+
+// SetRunUUID sets the run that the auxiliary resources created by the client belong to, so
+// the cleanup of the run finds them later
+func (c *Client) SetRunUUID(uuid string) {
+	c.auxiliary.mu.Lock()
+	defer c.auxiliary.mu.Unlock()
+	c.auxiliary.run = uuid
+}
+
+// prometheusNames returns the names of the service account and token secret for Prometheus
+// access of the run. Every run has its own, so the cleanup of one run does not revoke the
+// token of another run using the same Prometheus. Clients without a run use the plain names.
+func (c *Client) prometheusNames() (saName, secretName string, perRun bool) {
+	c.auxiliary.mu.Lock()
+	run := c.auxiliary.run
+	c.auxiliary.mu.Unlock()
+	if run == "" {
+		return prometheusSAName, prometheusSecretName, false
+	}
+	if len(run) > 8 {
+		run = run[:8]
+	}
+	saName = prometheusSAName + "-" + strings.ToLower(run)
+	return saName, saName + "-token", true
+}
+
+// auxiliaryLabels returns the labels of an auxiliary resource of the given purpose
+func (c *Client) auxiliaryLabels(purpose string) map[string]string {
+	labels := map[string]string{
+		"app":          "k8s-io",
+		auxiliaryLabel: purpose,
+	}
+	c.auxiliary.mu.Lock()
+	if c.auxiliary.run != "" {
+		labels[auxiliaryRunLabel] = c.auxiliary.run
+	}
+	c.auxiliary.mu.Unlock()
+	return mergeLabels(labels, c.labels)
+}
+
+// AuxiliaryResources returns the auxiliary resources the client created
+func (c *Client) AuxiliaryResources() []AuxiliaryResource {
+	c.auxiliary.mu.Lock()
+	defer c.auxiliary.mu.Unlock()
+	return append([]AuxiliaryResource(nil), c.auxiliary.resources...)
+}
+
+// CleanupAuxiliary deletes the auxiliary resources of a run: those the client created and
+// those labeled with the run in any namespace, which a previous process of the run created
+func (c *Client) CleanupAuxiliary(ctx context.Context, uuid string) error {
+	resources := c.AuxiliaryResources()
+	listed, err := c.listAuxiliary(ctx, auxiliaryLabel+","+auxiliaryRunLabel+"="+uuid)
+	if err != nil && !errors.Is(err, ErrForbidden) {
+		return err
+	}
+	resources = append(resources, listed...)
+
+	var errs []error
+	deleted := make(map[AuxiliaryResource]bool)
+	for _, r := range resources {
+		key := AuxiliaryResource{Kind: r.Kind, Namespace: r.Namespace, Name: r.Name}
+		if deleted[key] {
+			continue
+		}
+		deleted[key] = true
+		if err := c.DeleteAuxiliary(ctx, r); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	c.auxiliary.mu.Lock()
+	c.auxiliary.resources = nil
+	c.auxiliary.mu.Unlock()
+	return errors.Join(errs...)
+}
+
+// ListAuxiliary lists the auxiliary resources in every namespace, including the Prometheus
+// service accounts and secrets that versions before the auxiliary label created, oldest first
+func (c *Client) ListAuxiliary(ctx context.Context) ([]AuxiliaryResource, error) {
+	resources, err := c.listAuxiliary(ctx, auxiliaryLabel)
+	if err != nil {
+		return nil, err
+	}
+	legacy, err := c.listAuxiliary(ctx, "app=k8s-io,!"+auxiliaryLabel)
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range legacy {
+		if r.Name == prometheusSAName || r.Name == prometheusSecretName {
+			resources = append(resources, r)
+		}
+	}
+
+	sort.SliceStable(resources, func(i, j int) bool { return resources[i].Created.Before(resources[j].Created) })
+	return resources, nil
+}
+
+// listAuxiliary lists the service accounts and secrets matching a label selector in every
+// namespace
+func (c *Client) listAuxiliary(ctx context.Context, labelSelector string) ([]AuxiliaryResource, error) {
+	opts := metav1.ListOptions{LabelSelector: labelSelector}
+	var resources []AuxiliaryResource
+
+	accounts, err := c.clientset.CoreV1().ServiceAccounts(metav1.NamespaceAll).List(ctx, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list service accounts: %w", classify(err))
+	}
+	for _, sa := range accounts.Items {
+		resources = append(resources, AuxiliaryResource{
+			Kind:      "ServiceAccount",
+			Namespace: sa.Namespace,
+			Name:      sa.Name,
+			Run:       sa.Labels[auxiliaryRunLabel],
+			Created:   sa.CreationTimestamp.Time,
+		})
+	}
+
+	secrets, err := c.clientset.CoreV1().Secrets(metav1.NamespaceAll).List(ctx, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list secrets: %w", classify(err))
+	}
+	for _, secret := range secrets.Items {
+		resources = append(resources, AuxiliaryResource{
+			Kind:      "Secret",
+			Namespace: secret.Namespace,
+			Name:      secret.Name,
+			Run:       secret.Labels[auxiliaryRunLabel],
+			Created:   secret.CreationTimestamp.Time,
+		})
+	}
+	return resources, nil
+}
+
+// DeleteAuxiliary deletes an auxiliary resource, resources that are already gone are not an
+// error
+func (c *Client) DeleteAuxiliary(ctx context.Context, r AuxiliaryResource) error {
+	var err error
+	switch r.Kind {
+	case "ServiceAccount":
+		err = c.clientset.CoreV1().ServiceAccounts(r.Namespace).Delete(ctx, r.Name, metav1.DeleteOptions{})
+	case "Secret":
+		err = c.clientset.CoreV1().Secrets(r.Namespace).Delete(ctx, r.Name, metav1.DeleteOptions{})
+	default:
+		return fmt.Errorf("unknown auxiliary resource kind %s", r.Kind)
+	}
+	if err != nil && !apierrors.IsNotFound(err) {
+		return fmt.Errorf("failed to delete %s %s/%s: %w", r.Kind, r.Namespace, r.Name, classify(err))
+	}
+	return nil
+}
@@ -0,0 +1,79 @@
+package kubernetes_test
+
+import (
+	"context"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/jtaleric/k8s-io/pkg/kubernetes"
+	"github.com/jtaleric/k8s-io/pkg/kubernetes/kubetest"
+)
+
+// TestCleanupAuxiliaryConcurrentRuns runs two benchmarks against the same Prometheus, as the
+// queue allows, and cleans up the first while the second still scrapes it
+func TestCleanupAuxiliaryConcurrentRuns(t *testing.T) {
+	ctx := context.Background()
+	cluster := kubetest.New(&corev1.Service{ObjectMeta: metav1.ObjectMeta{Name: "prometheus-server", Namespace: "monitoring"}})
+
+	runs := []struct {
+		uuid   string
+		client *kubernetes.Client
+		sa     string
+		secret string
+	}{
+		{"1f2e3d4c-0000-4000-8000-000000000001", cluster.Client, "k8s-io-prometheus-1f2e3d4c", "k8s-io-prometheus-1f2e3d4c-token"},
+		{"5a6b7c8d-0000-4000-8000-000000000002", cluster.NewClient(), "k8s-io-prometheus-5a6b7c8d", "k8s-io-prometheus-5a6b7c8d-token"},
+	}
+	tokens := make(map[string]bool)
+	for _, run := range runs {
+		run.client.SetRunUUID(run.uuid)
+		// Every capture of a run discovers Prometheus again
+		for i := 0; i < 2; i++ {
+			info, err := run.client.DiscoverPrometheus(ctx)
+			if err != nil {
+				t.Fatalf("DiscoverPrometheus of run %s: %v", run.uuid, err)
+			}
+			if !info.Found || info.Token == "" {
+				t.Fatalf("run %s found Prometheus %v with token %q", run.uuid, info.Found, info.Token)
+			}
+			tokens[info.Token] = true
+		}
+		if got := len(run.client.AuxiliaryResources()); got != 2 {
+			t.Errorf("run %s tracks %d auxiliary resources, want its service account and secret", run.uuid, got)
+		}
+	}
+	if len(tokens) != len(runs) {
+		t.Errorf("the runs used %d tokens, want one per run", len(tokens))
+	}
+
+	first, second := runs[0], runs[1]
+	if err := first.client.CleanupAuxiliary(ctx, first.uuid); err != nil {
+		t.Fatalf("CleanupAuxiliary: %v", err)
+	}
+	for _, name := range []struct{ resource, name string }{{"serviceaccounts", first.sa}, {"secrets", first.secret}} {
+		if _, err := cluster.Get(name.resource, "monitoring", name.name); !apierrors.IsNotFound(err) {
+			t.Errorf("%s %s of the cleaned up run: got %v, want not found", name.resource, name.name, err)
+		}
+	}
+	for _, name := range []struct{ resource, name string }{{"serviceaccounts", second.sa}, {"secrets", second.secret}} {
+		if _, err := cluster.Get(name.resource, "monitoring", name.name); err != nil {
+			t.Errorf("%s %s of the other run: %v", name.resource, name.name, err)
+		}
+	}
+
+	// A later process of the second run, such as -cleanup, finds them by their label
+	later := cluster.NewClient()
+	if err := later.CleanupAuxiliary(ctx, second.uuid); err != nil {
+		t.Fatalf("CleanupAuxiliary: %v", err)
+	}
+	resources, err := later.ListAuxiliary(ctx)
+	if err != nil {
+		t.Fatalf("ListAuxiliary: %v", err)
+	}
+	if len(resources) != 0 {
+		t.Errorf("auxiliary resources left after both cleanups: %+v", resources)
+	}
+}
@@ -6,14 +6,16 @@ import (
 	"fmt"
 	"io"
 	"log"
-	"net"
+	"net/http"
+	"net/url"
 	"os"
-	"path/filepath"
+	"sort"
 	"strings"
 	"time"
 
 	batchv1 "k8s.io/api/batch/v1"
 	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
@@ -24,9 +26,9 @@ import (
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
-	"k8s.io/client-go/util/homedir"
 
 	"github.com/jtaleric/k8s-io/pkg/config"
+	"github.com/jtaleric/k8s-io/pkg/timeline"
 )
 
 // PrometheusInfo holds discovered Prometheus configuration
@@ -36,49 +38,28 @@ type PrometheusInfo struct {
 	Found bool
 }
 
-// isTransientError checks if an error is likely transient and should be retried
-func isTransientError(err error) bool {
-	if err == nil {
-		return false
-	}
-
-	errStr := err.Error()
-	// Network-related errors that are often transient
-	transientErrors := []string{
-		"client connection lost",
-		"connection reset by peer",
-		"timeout",
-		"temporary failure",
-		"network is unreachable",
-		"no route to host",
-		"connection refused",
-		"i/o timeout",
-	}
-
-	for _, transientErr := range transientErrors {
-		if strings.Contains(strings.ToLower(errStr), transientErr) {
-			return true
-		}
-	}
-
-	// Check for network-related error types
-	if _, ok := err.(net.Error); ok {
-		return true
-	}
-
-	return false
-}
-
 // Client wraps Kubernetes client functionality
 type Client struct {
 	clientset     kubernetes.Interface
 	dynamicClient dynamic.Interface
 	config        *rest.Config
+	noOverwrite   bool
+	labels        map[string]string
+	annotations   map[string]string
+	operations    operations
+	auxiliary     auxiliary
+	fromConfig    bool // The clients were created from config, so they can be recreated
 }
 
-// NewClient creates a new Kubernetes client
+// NewClient creates a new Kubernetes client from the in-cluster config or the default kubeconfig
 func NewClient() (*Client, error) {
-	config, err := getKubeConfig()
+	return NewClientForContext("", "")
+}
+
+// NewClientForContext creates a new Kubernetes client from the given kubeconfig file and
+// context, the defaults are used for empty values
+func NewClientForContext(kubeconfig, kubeContext string) (*Client, error) {
+	config, err := getKubeConfig(kubeconfig, kubeContext)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get kubeconfig: %w", err)
 	}
@@ -93,32 +74,82 @@ func NewClient() (*Client, error) {
 		return nil, fmt.Errorf("failed to create dynamic client: %w", err)
 	}
 
+	client := NewClientFromInterfaces(clientset, dynamicClient, config)
+	client.fromConfig = true
+	return client, nil
+}
+
+// NewClientFromInterfaces creates a Kubernetes client from existing clients, such as the
+// client-go fakes to exercise workloads without a cluster. The config only provides the API
+// server address and token, WithRateLimit keeps the clients.
+func NewClientFromInterfaces(clientset kubernetes.Interface, dynamicClient dynamic.Interface, config *rest.Config) *Client {
 	return &Client{
 		clientset:     clientset,
 		dynamicClient: dynamicClient,
 		config:        config,
-	}, nil
+	}
 }
 
-// getKubeConfig gets the Kubernetes configuration
-func getKubeConfig() (*rest.Config, error) {
-	// Try in-cluster config first
-	if config, err := rest.InClusterConfig(); err == nil {
-		return config, nil
+// WithRateLimit returns a client with the labels, annotations and overwrite setting of c
+// that sends up to qps requests per second with bursts of burst requests, instead of the
+// client-go default of 5 and 10 that would limit workloads creating many objects at once
+func (c *Client) WithRateLimit(qps float32, burst int) (*Client, error) {
+	clientset, dynamicClient, config := c.clientset, c.dynamicClient, c.config
+	if c.fromConfig {
+		config = rest.CopyConfig(c.config)
+		config.QPS = qps
+		config.Burst = burst
+		config.RateLimiter = nil
+
+		var err error
+		clientset, err = kubernetes.NewForConfig(config)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create Kubernetes clientset: %w", err)
+		}
+		dynamicClient, err = dynamic.NewForConfig(config)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create dynamic client: %w", err)
+		}
 	}
 
-	// Fall back to kubeconfig file
-	var kubeconfig string
-	if home := homedir.HomeDir(); home != "" {
-		kubeconfig = filepath.Join(home, ".kube", "config")
-	}
+	limited := NewClientFromInterfaces(clientset, dynamicClient, config)
+	limited.fromConfig = c.fromConfig
+	limited.noOverwrite = c.noOverwrite
+	limited.labels = c.labels
+	limited.annotations = c.annotations
+	return limited, nil
+}
+
+// SetNoOverwrite makes ApplyManifest fail instead of updating a resource that differs from its manifest
+func (c *Client) SetNoOverwrite(noOverwrite bool) {
+	c.noOverwrite = noOverwrite
+}
+
+// SetLabels sets labels that ApplyManifest adds to every resource and pod template
+func (c *Client) SetLabels(labels map[string]string) {
+	c.labels = labels
+}
 
-	// Override with KUBECONFIG env var if set
-	if kubeconfigEnv := os.Getenv("KUBECONFIG"); kubeconfigEnv != "" {
-		kubeconfig = kubeconfigEnv
+// SetAnnotations sets annotations that ApplyManifest adds to every resource and pod template
+func (c *Client) SetAnnotations(annotations map[string]string) {
+	c.annotations = annotations
+}
+
+// getKubeConfig gets the Kubernetes configuration
+func getKubeConfig(kubeconfig, kubeContext string) (*rest.Config, error) {
+	// Try in-cluster config first, unless a kubeconfig or context was requested
+	if kubeconfig == "" && kubeContext == "" {
+		if config, err := rest.InClusterConfig(); err == nil {
+			return config, nil
+		}
 	}
 
-	config, err := clientcmd.BuildConfigFromFlags("", kubeconfig)
+	// Fall back to the kubeconfig file, from KUBECONFIG or ~/.kube/config by default
+	loadingRules := clientcmd.NewDefaultClientConfigLoadingRules()
+	loadingRules.ExplicitPath = kubeconfig
+	overrides := &clientcmd.ConfigOverrides{CurrentContext: kubeContext}
+
+	config, err := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(loadingRules, overrides).ClientConfig()
 	if err != nil {
 		return nil, fmt.Errorf("failed to build kubeconfig: %w", err)
 	}
@@ -142,6 +173,9 @@ func (c *Client) ApplyManifest(ctx context.Context, manifestYAML string, namespa
 		obj.SetNamespace(namespace)
 	}
 
+	addLabels(obj, c.labels)
+	addAnnotations(obj, c.annotations)
+
 	// Get the appropriate resource interface
 	gvr := schema.GroupVersionResource{
 		Group:    gvk.Group,
@@ -162,20 +196,94 @@ func (c *Client) ApplyManifest(ctx context.Context, manifestYAML string, namespa
 		// Resource doesn't exist, create it
 		_, err = resourceClient.Create(ctx, obj, metav1.CreateOptions{})
 		if err != nil {
-			return fmt.Errorf("failed to create resource %s/%s: %w", obj.GetKind(), obj.GetName(), err)
+			return fmt.Errorf("failed to create resource %s/%s: %w", obj.GetKind(), obj.GetName(), classify(err))
 		}
+		timeline.Record(timeline.EventApply, resourceName(obj), "created")
 	} else {
-		// Resource exists, update it
+		// Resource exists, log what an update would change
+		changes := DiffObjects(existing.Object, obj.Object)
+		if len(changes) == 0 {
+			log.Printf("%s/%s is unchanged", obj.GetKind(), obj.GetName())
+			return nil
+		}
+		if c.noOverwrite {
+			return fmt.Errorf("%s/%s already exists with different fields (%s), refusing to overwrite",
+				obj.GetKind(), obj.GetName(), strings.Join(changes, "; "))
+		}
+		log.Printf("Updating existing %s/%s:", obj.GetKind(), obj.GetName())
+		for _, change := range changes {
+			log.Printf("  %s", change)
+		}
+
 		obj.SetResourceVersion(existing.GetResourceVersion())
 		_, err = resourceClient.Update(ctx, obj, metav1.UpdateOptions{})
 		if err != nil {
-			return fmt.Errorf("failed to update resource %s/%s: %w", obj.GetKind(), obj.GetName(), err)
+			return fmt.Errorf("failed to update resource %s/%s: %w", obj.GetKind(), obj.GetName(), classify(err))
 		}
+		timeline.Record(timeline.EventApply, resourceName(obj), "updated: %s", strings.Join(changes, "; "))
 	}
 
 	return nil
 }
 
+// resourceName returns the kind and name of a resource, prefixed with its namespace
+func resourceName(obj *unstructured.Unstructured) string {
+	name := obj.GetKind() + "/" + obj.GetName()
+	if obj.GetNamespace() != "" {
+		name = obj.GetNamespace() + "/" + name
+	}
+	return name
+}
+
+// addLabels adds labels to a resource and its pod template without overriding labels set in the manifest
+func addLabels(obj *unstructured.Unstructured, labels map[string]string) {
+	if len(labels) == 0 {
+		return
+	}
+
+	obj.SetLabels(mergeLabels(obj.GetLabels(), labels))
+
+	// Label the pods of Jobs and DaemonSets as well
+	if _, hasTemplate, _ := unstructured.NestedMap(obj.Object, "spec", "template"); !hasTemplate {
+		return
+	}
+	templateLabels, _, _ := unstructured.NestedStringMap(obj.Object, "spec", "template", "metadata", "labels")
+	if err := unstructured.SetNestedStringMap(obj.Object, mergeLabels(templateLabels, labels), "spec", "template", "metadata", "labels"); err != nil {
+		log.Printf("Warning: failed to label the pod template of %s/%s: %v", obj.GetKind(), obj.GetName(), err)
+	}
+}
+
+// addAnnotations adds annotations to a resource and its pod template without overriding
+// annotations set in the manifest
+func addAnnotations(obj *unstructured.Unstructured, annotations map[string]string) {
+	if len(annotations) == 0 {
+		return
+	}
+
+	obj.SetAnnotations(mergeLabels(obj.GetAnnotations(), annotations))
+
+	if _, hasTemplate, _ := unstructured.NestedMap(obj.Object, "spec", "template"); !hasTemplate {
+		return
+	}
+	templateAnnotations, _, _ := unstructured.NestedStringMap(obj.Object, "spec", "template", "metadata", "annotations")
+	if err := unstructured.SetNestedStringMap(obj.Object, mergeLabels(templateAnnotations, annotations), "spec", "template", "metadata", "annotations"); err != nil {
+		log.Printf("Warning: failed to annotate the pod template of %s/%s: %v", obj.GetKind(), obj.GetName(), err)
+	}
+}
+
+// mergeLabels adds labels to existing ones, keeping existing values. It merges annotations
+// the same way.
+func mergeLabels(existing, labels map[string]string) map[string]string {
+	merged := make(map[string]string, len(existing)+len(labels))
+	for key, value := range labels {
+		merged[key] = value
+	}
+	for key, value := range existing {
+		merged[key] = value
+	}
+	return merged
+}
+
 // DeleteResource deletes a resource by name, kind, and namespace
 func (c *Client) DeleteResource(ctx context.Context, kind, name, namespace string) error {
 	gvk := getGVKForKind(kind)
@@ -194,7 +302,7 @@ func (c *Client) DeleteResource(ctx context.Context, kind, name, namespace strin
 
 	err := resourceClient.Delete(ctx, name, metav1.DeleteOptions{})
 	if err != nil {
-		return fmt.Errorf("failed to delete %s/%s: %w", kind, name, err)
+		return fmt.Errorf("failed to delete %s/%s: %w", kind, name, classify(err))
 	}
 
 	return nil
@@ -207,43 +315,174 @@ func (c *Client) NamespaceExists(ctx context.Context, namespace string) (bool, e
 		if apierrors.IsNotFound(err) {
 			return false, nil
 		}
-		return false, fmt.Errorf("failed to get namespace %s: %w", namespace, err)
+		return false, fmt.Errorf("failed to get namespace %s: %w", namespace, classify(err))
 	}
 	return true, nil
 }
 
-// CreateNamespace creates a namespace
-func (c *Client) CreateNamespace(ctx context.Context, namespace string) error {
+// CreateNamespace creates a namespace with the given labels and annotations
+func (c *Client) CreateNamespace(ctx context.Context, namespace string, labels, annotations map[string]string) error {
 	_, err := c.clientset.CoreV1().Namespaces().Create(ctx, &corev1.Namespace{
-		ObjectMeta: metav1.ObjectMeta{Name: namespace},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        namespace,
+			Labels:      labels,
+			Annotations: annotations,
+		},
 	}, metav1.CreateOptions{})
 	if err != nil {
-		return fmt.Errorf("failed to create namespace %s: %w", namespace, err)
+		return fmt.Errorf("failed to create namespace %s: %w", namespace, classify(err))
 	}
 
 	return nil
 }
 
+// DeleteNamespace deletes a namespace and everything in it, without waiting for the
+// namespace to be gone. A namespace that does not exist is not an error.
+func (c *Client) DeleteNamespace(ctx context.Context, namespace string) error {
+	err := c.clientset.CoreV1().Namespaces().Delete(ctx, namespace, metav1.DeleteOptions{})
+	if err != nil && !apierrors.IsNotFound(err) {
+		return fmt.Errorf("failed to delete namespace %s: %w", namespace, classify(err))
+	}
+	return nil
+}
+
+// ListStorageClasses returns the names of the storage classes, marking the default class
+func (c *Client) ListStorageClasses(ctx context.Context) ([]string, error) {
+	classes, err := c.clientset.StorageV1().StorageClasses().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list storage classes: %w", err)
+	}
+
+	names := make([]string, 0, len(classes.Items))
+	for _, sc := range classes.Items {
+		name := sc.Name
+		if sc.Annotations["storageclass.kubernetes.io/is-default-class"] == "true" {
+			name += " (default)"
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// DefaultStorageClass returns the name of the default storage class, which PVCs without a
+// storageClassName are provisioned from. With several defaults, the newest one wins, like
+// the API server picks it.
+func (c *Client) DefaultStorageClass(ctx context.Context) (string, error) {
+	classes, err := c.clientset.StorageV1().StorageClasses().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return "", fmt.Errorf("failed to list storage classes: %w", classify(err))
+	}
+
+	var newest *storagev1.StorageClass
+	for i, sc := range classes.Items {
+		if sc.Annotations["storageclass.kubernetes.io/is-default-class"] != "true" {
+			continue
+		}
+		if newest == nil || newest.CreationTimestamp.Before(&sc.CreationTimestamp) {
+			newest = &classes.Items[i]
+		}
+	}
+	if newest == nil {
+		return "", &classError{class: ErrNotFound, err: fmt.Errorf("the cluster has no default storage class")}
+	}
+	return newest.Name, nil
+}
+
+// ListNamespaces returns the names of the namespaces
+func (c *Client) ListNamespaces(ctx context.Context) ([]string, error) {
+	namespaces, err := c.clientset.CoreV1().Namespaces().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list namespaces: %w", err)
+	}
+
+	names := make([]string, 0, len(namespaces.Items))
+	for _, ns := range namespaces.Items {
+		names = append(names, ns.Name)
+	}
+	return names, nil
+}
+
+// KubeconfigContexts returns the context names of the default kubeconfig
+func KubeconfigContexts() ([]string, error) {
+	config, err := clientcmd.NewDefaultClientConfigLoadingRules().Load()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load kubeconfig: %w", err)
+	}
+
+	names := make([]string, 0, len(config.Contexts))
+	for name := range config.Contexts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
 // ListPods lists pods with the given label selector
 func (c *Client) ListPods(ctx context.Context, namespace string, labelSelector string) (*corev1.PodList, error) {
-	return c.clientset.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{
+	pods, err := c.clientset.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{
 		LabelSelector: labelSelector,
 	})
+	return pods, classify(err)
 }
 
 // GetJob gets a job by name and namespace
 func (c *Client) GetJob(ctx context.Context, name, namespace string) (*batchv1.Job, error) {
-	return c.clientset.BatchV1().Jobs(namespace).Get(ctx, name, metav1.GetOptions{})
+	job, err := c.clientset.BatchV1().Jobs(namespace).Get(ctx, name, metav1.GetOptions{})
+	return job, classify(err)
+}
+
+// CreateJob creates a job built in code, with the labels of SetLabels and annotations of
+// SetAnnotations on the job and its pods
+func (c *Client) CreateJob(ctx context.Context, job *batchv1.Job) (*batchv1.Job, error) {
+	job.Labels = mergeLabels(job.Labels, c.labels)
+	job.Spec.Template.Labels = mergeLabels(job.Spec.Template.Labels, c.labels)
+	job.Annotations = mergeLabels(job.Annotations, c.annotations)
+	job.Spec.Template.Annotations = mergeLabels(job.Spec.Template.Annotations, c.annotations)
+
+	created, err := c.clientset.BatchV1().Jobs(job.Namespace).Create(ctx, job, metav1.CreateOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create job %s: %w", job.Name, classify(err))
+	}
+	return created, nil
+}
+
+// GetNode gets a node by name
+func (c *Client) GetNode(ctx context.Context, name string) (*corev1.Node, error) {
+	node, err := c.clientset.CoreV1().Nodes().Get(ctx, name, metav1.GetOptions{})
+	return node, classify(err)
+}
+
+// ListPodEvents lists the events recorded for a pod
+func (c *Client) ListPodEvents(ctx context.Context, namespace, podName string) (*corev1.EventList, error) {
+	events, err := c.clientset.CoreV1().Events(namespace).List(ctx, metav1.ListOptions{
+		FieldSelector: fmt.Sprintf("involvedObject.kind=Pod,involvedObject.name=%s", podName),
+	})
+	return events, classify(err)
+}
+
+// ListWarningEvents lists the warning events recorded in a namespace, such as failed
+// provisioning, scheduling and mounts
+func (c *Client) ListWarningEvents(ctx context.Context, namespace string) (*corev1.EventList, error) {
+	events, err := c.clientset.CoreV1().Events(namespace).List(ctx, metav1.ListOptions{
+		FieldSelector: "type=Warning",
+	})
+	return events, classify(err)
 }
 
 // WaitForPodsReady waits for pods to be ready with retry logic for network resilience
 func (c *Client) WaitForPodsReady(ctx context.Context, namespace string, labelSelector string, expectedCount int, timeout time.Duration) error {
-	return wait.PollImmediate(5*time.Second, timeout, func() (bool, error) {
+	target := fmt.Sprintf("%s/%s (%d pods)", namespace, labelSelector, expectedCount)
+	defer c.operations.begin("wait-pods", target)()
+	start := time.Now()
+
+	err := classify(wait.PollImmediate(5*time.Second, timeout, func() (bool, error) {
 		pods, err := c.ListPods(ctx, namespace, labelSelector)
 		if err != nil {
-			if isTransientError(err) {
+			if Retryable(err) {
 				// Log transient errors but continue retrying
 				log.Printf("Warning: Transient error listing pods with selector %s (will retry): %v", labelSelector, err)
+				timeline.Record(timeline.EventRetry, target, "transient error: %v", err)
 				return false, nil
 			} else {
 				// Non-transient errors should fail immediately
@@ -263,17 +502,23 @@ func (c *Client) WaitForPodsReady(ctx context.Context, namespace string, labelSe
 		}
 
 		return readyCount >= expectedCount, nil
-	})
+	}))
+	return waited("wait-pods", target, start, err)
 }
 
 // WaitForJobCompletion waits for a job to complete with retry logic for network resilience
 func (c *Client) WaitForJobCompletion(ctx context.Context, name, namespace string, timeout time.Duration) error {
-	return wait.PollImmediate(60*time.Second, timeout, func() (bool, error) {
+	target := namespace + "/" + name
+	defer c.operations.begin("wait-job", target)()
+	start := time.Now()
+
+	err := classify(wait.PollImmediate(60*time.Second, timeout, func() (bool, error) {
 		job, err := c.GetJob(ctx, name, namespace)
 		if err != nil {
-			if isTransientError(err) {
+			if Retryable(err) {
 				// Log transient errors but continue retrying
 				log.Printf("Warning: Transient error getting job %s status (will retry): %v", name, err)
+				timeline.Record(timeline.EventRetry, target, "transient error: %v", err)
 				return false, nil
 			} else {
 				// Non-transient errors should fail immediately
@@ -296,7 +541,8 @@ func (c *Client) WaitForJobCompletion(ctx context.Context, name, namespace strin
 		log.Printf("Job %s still running (succeeded: %d, failed: %d, active: %d)",
 			name, job.Status.Succeeded, job.Status.Failed, job.Status.Active)
 		return false, nil
-	})
+	}))
+	return waited("wait-job", target, start, err)
 }
 
 // CleanupResources deletes resources with the given label selector
@@ -333,6 +579,22 @@ func (c *Client) CleanupResources(ctx context.Context, namespace string, labelSe
 		return fmt.Errorf("failed to delete PVCs: %w", err)
 	}
 
+	// Delete daemonsets
+	err = c.clientset.AppsV1().DaemonSets(namespace).DeleteCollection(ctx, metav1.DeleteOptions{}, metav1.ListOptions{
+		LabelSelector: labelSelector,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to delete daemonsets: %w", err)
+	}
+
+	// Delete pod disruption budgets
+	err = c.clientset.PolicyV1().PodDisruptionBudgets(namespace).DeleteCollection(ctx, metav1.DeleteOptions{}, metav1.ListOptions{
+		LabelSelector: labelSelector,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to delete pod disruption budgets: %w", err)
+	}
+
 	return nil
 }
 
@@ -385,6 +647,10 @@ func getGVKForKind(kind string) schema.GroupVersionKind {
 		return schema.GroupVersionKind{Group: "", Version: "v1", Kind: "PersistentVolumeClaim"}
 	case "VirtualMachineInstance":
 		return schema.GroupVersionKind{Group: "kubevirt.io", Version: "v1", Kind: "VirtualMachineInstance"}
+	case "PodDisruptionBudget":
+		return schema.GroupVersionKind{Group: "policy", Version: "v1", Kind: "PodDisruptionBudget"}
+	case "DaemonSet":
+		return schema.GroupVersionKind{Group: "apps", Version: "v1", Kind: "DaemonSet"}
 	default:
 		return schema.GroupVersionKind{Group: "", Version: "v1", Kind: kind}
 	}
@@ -490,19 +756,19 @@ func (c *Client) getPrometheusToken(ctx context.Context, namespace string) (stri
 	return "", fmt.Errorf("no service account token found for Prometheus")
 }
 
-// createK8sIOToken creates a dedicated service account and token for k8s-io
+// createK8sIOToken creates a dedicated service account and token for the run. They are
+// tracked as auxiliary resources of the run, so its cleanup deletes them. Those of the run
+// that already exist, from an earlier discovery or process of the run, are tracked as well.
 func (c *Client) createK8sIOToken(ctx context.Context, namespace string) (string, error) {
-	saName := "k8s-io-prometheus"
-	secretName := "k8s-io-prometheus-token"
+	saName, secretName, perRun := c.prometheusNames()
 
 	// Create service account
 	sa := &corev1.ServiceAccount{
 		ObjectMeta: metav1.ObjectMeta{
-			Name:      saName,
-			Namespace: namespace,
-			Labels: map[string]string{
-				"app": "k8s-io",
-			},
+			Name:        saName,
+			Namespace:   namespace,
+			Labels:      c.auxiliaryLabels("prometheus-token"),
+			Annotations: c.annotations,
 		},
 	}
 
@@ -511,18 +777,19 @@ func (c *Client) createK8sIOToken(ctx context.Context, namespace string) (string
 	if err != nil && !apierrors.IsAlreadyExists(err) {
 		return "", fmt.Errorf("failed to create service account: %w", err)
 	}
+	if err == nil || perRun {
+		c.auxiliary.track("ServiceAccount", namespace, saName)
+	}
 
 	// Create token secret for the service account
 	secret := &corev1.Secret{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      secretName,
 			Namespace: namespace,
-			Labels: map[string]string{
-				"app": "k8s-io",
-			},
-			Annotations: map[string]string{
+			Labels:    c.auxiliaryLabels("prometheus-token"),
+			Annotations: mergeLabels(map[string]string{
 				"kubernetes.io/service-account.name": saName,
-			},
+			}, c.annotations),
 		},
 		Type: corev1.SecretTypeServiceAccountToken,
 	}
@@ -531,6 +798,9 @@ func (c *Client) createK8sIOToken(ctx context.Context, namespace string) (string
 	if err != nil && !apierrors.IsAlreadyExists(err) {
 		return "", fmt.Errorf("failed to create token secret: %w", err)
 	}
+	if err == nil || perRun {
+		c.auxiliary.track("Secret", namespace, secretName)
+	}
 
 	// Wait for the token to be populated (Kubernetes automatically populates it)
 	var token string
@@ -619,7 +889,20 @@ func (c *Client) GetPodLogs(ctx context.Context, namespace, podName, containerNa
 		Follow:    false,
 	})
 
-	return req.Stream(ctx)
+	stream, err := req.Stream(ctx)
+	return stream, classify(err)
+}
+
+// GetPodLogTail gets the last lines of the logs of a pod
+func (c *Client) GetPodLogTail(ctx context.Context, namespace, podName, containerName string, lines int64) (string, error) {
+	data, err := c.clientset.CoreV1().Pods(namespace).GetLogs(podName, &corev1.PodLogOptions{
+		Container: containerName,
+		TailLines: &lines,
+	}).DoRaw(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to get logs for pod %s: %w", podName, classify(err))
+	}
+	return string(data), nil
 }
 
 // GetPodLogsStream gets logs from a pod with streaming support
@@ -629,7 +912,11 @@ func (c *Client) GetPodLogsStream(ctx context.Context, namespace, podName, conta
 		Follow:    follow,
 	})
 
-	return req.Stream(ctx)
+	stream, err := req.Stream(ctx)
+	if err != nil || !follow {
+		return stream, classify(err)
+	}
+	return &trackedStream{ReadCloser: stream, done: c.operations.begin("log-stream", namespace+"/"+podName)}, nil
 }
 
 // GetJobPodLogs gets logs from the first pod of a completed job
@@ -642,7 +929,7 @@ func (c *Client) GetJobPodLogs(ctx context.Context, jobName, namespace string) (
 	}
 
 	if len(pods.Items) == 0 {
-		return "", fmt.Errorf("no pods found for job %s", jobName)
+		return "", &classError{class: ErrNotFound, err: fmt.Errorf("no pods found for job %s", jobName)}
 	}
 
 	// Get logs from the first pod
@@ -667,3 +954,32 @@ func (c *Client) GetJobPodLogs(ctx context.Context, jobName, namespace string) (
 
 	return logs.String(), nil
 }
+
+// ServiceProxy returns the API server proxy URL of an in-cluster service URL, such as
+// http://prometheus-k8s.openshift-monitoring.svc:9091, with the transport authenticating
+// against the API server. It lets k8s-io reach cluster services from outside the cluster.
+func (c *Client) ServiceProxy(serviceURL string) (string, http.RoundTripper, error) {
+	u, err := url.Parse(serviceURL)
+	if err != nil {
+		return "", nil, fmt.Errorf("invalid service URL %s: %w", serviceURL, err)
+	}
+	parts := strings.Split(u.Hostname(), ".")
+	if len(parts) < 3 || parts[2] != "svc" {
+		return "", nil, fmt.Errorf("%s is not the URL of a cluster service", serviceURL)
+	}
+	port := u.Port()
+	if port == "" {
+		port = "80"
+		if u.Scheme == "https" {
+			port = "443"
+		}
+	}
+
+	transport, err := rest.TransportFor(c.config)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to create API server transport: %w", err)
+	}
+	proxy := fmt.Sprintf("%s/api/v1/namespaces/%s/services/%s:%s:%s/proxy%s",
+		strings.TrimSuffix(c.config.Host, "/"), parts[1], u.Scheme, parts[0], port, strings.TrimSuffix(u.Path, "/"))
+	return proxy, transport, nil
+}
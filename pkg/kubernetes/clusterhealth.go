@@ -0,0 +1,110 @@
+package kubernetes
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+var (
+	clusterVersionGVR    = schema.GroupVersionResource{Group: "config.openshift.io", Version: "v1", Resource: "clusterversions"}
+	machineConfigPoolGVR = schema.GroupVersionResource{Group: "machineconfiguration.openshift.io", Version: "v1", Resource: "machineconfigpools"}
+	cephClusterGVR       = schema.GroupVersionResource{Group: "ceph.rook.io", Version: "v1", Resource: "cephclusters"}
+)
+
+// UpgradesInProgress describes the signs of an ongoing upgrade or maintenance: cordoned or
+// not ready nodes, and on OpenShift a progressing cluster version or updating machine config
+// pools. APIs the cluster does not serve are skipped.
+func (c *Client) UpgradesInProgress(ctx context.Context) ([]string, error) {
+	nodes, err := c.clientset.CoreV1().Nodes().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list nodes: %w", err)
+	}
+
+	var found []string
+	for _, node := range nodes.Items {
+		if node.Spec.Unschedulable {
+			found = append(found, fmt.Sprintf("node %s is cordoned", node.Name))
+			continue
+		}
+		for _, cond := range node.Status.Conditions {
+			if cond.Type == corev1.NodeReady && cond.Status != corev1.ConditionTrue {
+				found = append(found, fmt.Sprintf("node %s is not ready", node.Name))
+			}
+		}
+	}
+
+	versions, err := c.dynamicClient.Resource(clusterVersionGVR).List(ctx, metav1.ListOptions{})
+	if err != nil && !isUnservedAPI(err) {
+		return nil, fmt.Errorf("failed to list cluster versions: %w", err)
+	}
+	if err == nil {
+		for _, cv := range versions.Items {
+			if conditionTrue(&cv, "Progressing") {
+				desired, _, _ := unstructured.NestedString(cv.Object, "status", "desired", "version")
+				found = append(found, fmt.Sprintf("cluster version is progressing to %s", desired))
+			}
+		}
+	}
+
+	pools, err := c.dynamicClient.Resource(machineConfigPoolGVR).List(ctx, metav1.ListOptions{})
+	if err != nil && !isUnservedAPI(err) {
+		return nil, fmt.Errorf("failed to list machine config pools: %w", err)
+	}
+	if err == nil {
+		for _, pool := range pools.Items {
+			if conditionTrue(&pool, "Updating") {
+				found = append(found, fmt.Sprintf("machine config pool %s is updating", pool.GetName()))
+			}
+		}
+	}
+
+	sort.Strings(found)
+	return found, nil
+}
+
+// CephHealth returns the health of every Rook CephCluster by namespace/name, such as
+// HEALTH_OK or HEALTH_WARN. It is empty when Rook is not installed.
+func (c *Client) CephHealth(ctx context.Context) (map[string]string, error) {
+	clusters, err := c.dynamicClient.Resource(cephClusterGVR).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		if isUnservedAPI(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to list Ceph clusters: %w", err)
+	}
+
+	health := make(map[string]string, len(clusters.Items))
+	for _, cluster := range clusters.Items {
+		status, _, _ := unstructured.NestedString(cluster.Object, "status", "ceph", "health")
+		if status == "" {
+			status = "unknown"
+		}
+		health[cluster.GetNamespace()+"/"+cluster.GetName()] = status
+	}
+	return health, nil
+}
+
+// conditionTrue reports whether the status condition of the given type is True
+func conditionTrue(obj *unstructured.Unstructured, conditionType string) bool {
+	conditions, _, _ := unstructured.NestedSlice(obj.Object, "status", "conditions")
+	for _, raw := range conditions {
+		cond, ok := raw.(map[string]interface{})
+		if ok && cond["type"] == conditionType && cond["status"] == "True" {
+			return true
+		}
+	}
+	return false
+}
+
+// isUnservedAPI reports whether an error means the cluster does not serve the resource
+func isUnservedAPI(err error) bool {
+	return apierrors.IsNotFound(err) || meta.IsNoMatchError(err)
+}
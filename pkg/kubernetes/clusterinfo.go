@@ -0,0 +1,121 @@
+package kubernetes
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ClusterInfo describes the cluster a run ran on, recorded to reproduce or audit the run
+type ClusterInfo struct {
+	Server         string             `json:"server"`   // API server URL
+	Version        string             `json:"version"`  // Kubernetes server version
+	Platform       string             `json:"platform"` // Server OS/architecture
+	Nodes          []NodeInfo         `json:"nodes"`
+	StorageClasses []StorageClassInfo `json:"storage_classes"`
+}
+
+// NodeInfo is the software and placement of a node
+type NodeInfo struct {
+	Name             string            `json:"name"`
+	Roles            []string          `json:"roles,omitempty"`
+	KubeletVersion   string            `json:"kubelet_version"`
+	OSImage          string            `json:"os_image"`
+	KernelVersion    string            `json:"kernel_version"`
+	ContainerRuntime string            `json:"container_runtime"`
+	Architecture     string            `json:"architecture"`
+	CPU              string            `json:"cpu"`
+	Memory           string            `json:"memory"`
+	Labels           map[string]string `json:"labels,omitempty"` // Instance type and topology labels
+}
+
+// StorageClassInfo is the provisioner and parameters of a storage class
+type StorageClassInfo struct {
+	Name                 string            `json:"name"`
+	Provisioner          string            `json:"provisioner"`
+	Parameters           map[string]string `json:"parameters,omitempty"`
+	ReclaimPolicy        string            `json:"reclaim_policy,omitempty"`
+	VolumeBindingMode    string            `json:"volume_binding_mode,omitempty"`
+	AllowVolumeExpansion bool              `json:"allow_volume_expansion,omitempty"`
+	MountOptions         []string          `json:"mount_options,omitempty"`
+	Default              bool              `json:"default,omitempty"`
+}
+
+// nodeInfoLabels are the node labels recorded with the cluster info
+var nodeInfoLabels = []string{
+	"node.kubernetes.io/instance-type",
+	"topology.kubernetes.io/region",
+	"topology.kubernetes.io/zone",
+}
+
+// ClusterInfo returns the version, nodes and storage classes of the cluster
+func (c *Client) ClusterInfo(ctx context.Context) (*ClusterInfo, error) {
+	version, err := c.clientset.Discovery().ServerVersion()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get the server version: %w", err)
+	}
+	info := &ClusterInfo{Server: c.config.Host, Version: version.GitVersion, Platform: version.Platform}
+
+	nodes, err := c.clientset.CoreV1().Nodes().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list nodes: %w", err)
+	}
+	for _, node := range nodes.Items {
+		n := NodeInfo{
+			Name:             node.Name,
+			KubeletVersion:   node.Status.NodeInfo.KubeletVersion,
+			OSImage:          node.Status.NodeInfo.OSImage,
+			KernelVersion:    node.Status.NodeInfo.KernelVersion,
+			ContainerRuntime: node.Status.NodeInfo.ContainerRuntimeVersion,
+			Architecture:     node.Status.NodeInfo.Architecture,
+			CPU:              node.Status.Capacity.Cpu().String(),
+			Memory:           node.Status.Capacity.Memory().String(),
+		}
+		for label := range node.Labels {
+			if role := strings.TrimPrefix(label, "node-role.kubernetes.io/"); role != label && role != "" {
+				n.Roles = append(n.Roles, role)
+			}
+		}
+		sort.Strings(n.Roles)
+		for _, label := range nodeInfoLabels {
+			if value, ok := node.Labels[label]; ok {
+				if n.Labels == nil {
+					n.Labels = make(map[string]string)
+				}
+				n.Labels[label] = value
+			}
+		}
+		info.Nodes = append(info.Nodes, n)
+	}
+	sort.Slice(info.Nodes, func(i, j int) bool { return info.Nodes[i].Name < info.Nodes[j].Name })
+
+	classes, err := c.clientset.StorageV1().StorageClasses().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list storage classes: %w", err)
+	}
+	for _, sc := range classes.Items {
+		s := StorageClassInfo{
+			Name:         sc.Name,
+			Provisioner:  sc.Provisioner,
+			Parameters:   sc.Parameters,
+			MountOptions: sc.MountOptions,
+			Default:      sc.Annotations["storageclass.kubernetes.io/is-default-class"] == "true",
+		}
+		if sc.ReclaimPolicy != nil {
+			s.ReclaimPolicy = string(*sc.ReclaimPolicy)
+		}
+		if sc.VolumeBindingMode != nil {
+			s.VolumeBindingMode = string(*sc.VolumeBindingMode)
+		}
+		if sc.AllowVolumeExpansion != nil {
+			s.AllowVolumeExpansion = *sc.AllowVolumeExpansion
+		}
+		info.StorageClasses = append(info.StorageClasses, s)
+	}
+	sort.Slice(info.StorageClasses, func(i, j int) bool { return info.StorageClasses[i].Name < info.StorageClasses[j].Name })
+
+	return info, nil
+}
@@ -0,0 +1,132 @@
+package kubernetes
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+// Collision policies for resources left over from a run with the same UUID
+const (
+	CollisionAdopt   = "adopt"
+	CollisionFail    = "fail"
+	CollisionReplace = "replace"
+)
+
+// FindResources lists the resources matching the label selector as kind/name
+func (c *Client) FindResources(ctx context.Context, namespace string, labelSelector string) ([]string, error) {
+	opts := metav1.ListOptions{LabelSelector: labelSelector}
+	var found []string
+
+	pods, err := c.clientset.CoreV1().Pods(namespace).List(ctx, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list pods: %w", err)
+	}
+	for _, item := range pods.Items {
+		found = append(found, "Pod/"+item.Name)
+	}
+
+	jobs, err := c.clientset.BatchV1().Jobs(namespace).List(ctx, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list jobs: %w", err)
+	}
+	for _, item := range jobs.Items {
+		found = append(found, "Job/"+item.Name)
+	}
+
+	configMaps, err := c.clientset.CoreV1().ConfigMaps(namespace).List(ctx, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list configmaps: %w", err)
+	}
+	for _, item := range configMaps.Items {
+		found = append(found, "ConfigMap/"+item.Name)
+	}
+
+	pvcs, err := c.clientset.CoreV1().PersistentVolumeClaims(namespace).List(ctx, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list PVCs: %w", err)
+	}
+	for _, item := range pvcs.Items {
+		found = append(found, "PersistentVolumeClaim/"+item.Name)
+	}
+
+	daemonSets, err := c.clientset.AppsV1().DaemonSets(namespace).List(ctx, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list daemonsets: %w", err)
+	}
+	for _, item := range daemonSets.Items {
+		found = append(found, "DaemonSet/"+item.Name)
+	}
+
+	pdbs, err := c.clientset.PolicyV1().PodDisruptionBudgets(namespace).List(ctx, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list pod disruption budgets: %w", err)
+	}
+	for _, item := range pdbs.Items {
+		found = append(found, "PodDisruptionBudget/"+item.Name)
+	}
+
+	return found, nil
+}
+
+// ResolveCollisions applies the collision policy to existing resources matching any of the label selectors.
+// Adopted resources are reused by the run, failing reports them, and replacing deletes them and waits until they are gone.
+func (c *Client) ResolveCollisions(ctx context.Context, namespace, policy string, labelSelectors ...string) error {
+	existing, err := c.findAll(ctx, namespace, labelSelectors)
+	if err != nil {
+		return err
+	}
+	if len(existing) == 0 {
+		return nil
+	}
+
+	switch policy {
+	case CollisionAdopt:
+		log.Printf("Adopting %d existing resources from a previous run: %s", len(existing), strings.Join(existing, ", "))
+		return nil
+	case CollisionReplace:
+		log.Printf("Replacing %d existing resources from a previous run: %s", len(existing), strings.Join(existing, ", "))
+		for _, selector := range labelSelectors {
+			if err := c.CleanupResources(ctx, namespace, selector); err != nil {
+				return fmt.Errorf("failed to delete existing resources: %w", err)
+			}
+		}
+		return wait.PollImmediate(2*time.Second, 5*time.Minute, func() (bool, error) {
+			remaining, err := c.findAll(ctx, namespace, labelSelectors)
+			if err != nil {
+				return false, err
+			}
+			if len(remaining) > 0 {
+				log.Printf("Waiting for %d resources to be deleted", len(remaining))
+			}
+			return len(remaining) == 0, nil
+		})
+	default:
+		return fmt.Errorf("resources from a previous run with this UUID already exist (%s); run with -cleanup, use a new UUID or set collision_policy to 'adopt' or 'replace'",
+			strings.Join(existing, ", "))
+	}
+}
+
+// findAll lists the resources matching any of the label selectors without duplicates
+func (c *Client) findAll(ctx context.Context, namespace string, labelSelectors []string) ([]string, error) {
+	seen := make(map[string]bool)
+	var all []string
+	for _, selector := range labelSelectors {
+		found, err := c.FindResources(ctx, namespace, selector)
+		if err != nil {
+			return nil, err
+		}
+		for _, name := range found {
+			if !seen[name] {
+				seen[name] = true
+				all = append(all, name)
+			}
+		}
+	}
+	return all, nil
+}
@@ -0,0 +1,59 @@
+package kubernetes
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/serializer/yaml"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// ConfigMapChecksum returns the SHA-256 of the data values of a ConfigMap manifest concatenated
+// in key order, which matches `cat <mount>/* | sha256sum` in a pod mounting the ConfigMap
+// with LC_ALL=C. Pods compare both to wait until the mounted files are up to date.
+func ConfigMapChecksum(manifestYAML string) (string, error) {
+	obj := &unstructured.Unstructured{}
+	dec := yaml.NewDecodingSerializer(unstructured.UnstructuredJSONScheme)
+	if _, _, err := dec.Decode([]byte(manifestYAML), nil, obj); err != nil {
+		return "", fmt.Errorf("failed to decode configmap: %w", err)
+	}
+
+	data, _, err := unstructured.NestedStringMap(obj.Object, "data")
+	if err != nil {
+		return "", fmt.Errorf("failed to read configmap data: %w", err)
+	}
+
+	keys := make([]string, 0, len(data))
+	for key := range data {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	hash := sha256.New()
+	for _, key := range keys {
+		hash.Write([]byte(data[key]))
+	}
+
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// SetConfigMapKey adds or updates one key of a ConfigMap, leaving the other keys alone
+func (c *Client) SetConfigMapKey(ctx context.Context, name, namespace, key, value string) error {
+	patch, err := json.Marshal(map[string]interface{}{
+		"data": map[string]string{key: value},
+	})
+	if err != nil {
+		return err
+	}
+
+	if _, err := c.clientset.CoreV1().ConfigMaps(namespace).Patch(ctx, name, types.MergePatchType, patch, metav1.PatchOptions{}); err != nil {
+		return fmt.Errorf("failed to update configmap %s: %w", name, err)
+	}
+	return nil
+}
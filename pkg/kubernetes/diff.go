@@ -0,0 +1,86 @@
+package kubernetes
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// serverManagedMetadata lists metadata fields set by the API server, which are ignored in diffs
+var serverManagedMetadata = map[string]bool{
+	"resourceVersion":   true,
+	"uid":               true,
+	"creationTimestamp": true,
+	"generation":        true,
+	"managedFields":     true,
+	"selfLink":          true,
+}
+
+// DiffObjects returns the fields set in desired whose value differs in existing, as
+// "path: old -> new" lines sorted by path. Fields only present in existing, such as
+// server-side defaults and status, are not reported.
+func DiffObjects(existing, desired map[string]interface{}) []string {
+	var changes []string
+	diffValue("", existing, desired, &changes)
+	sort.Strings(changes)
+	return changes
+}
+
+// diffValue compares one value at path and appends the differences
+func diffValue(path string, existing, desired interface{}, changes *[]string) {
+	switch d := desired.(type) {
+	case map[string]interface{}:
+		e, ok := existing.(map[string]interface{})
+		if !ok {
+			break
+		}
+		for key, value := range d {
+			if path == "" && key == "status" {
+				continue
+			}
+			if path == "metadata" && serverManagedMetadata[key] {
+				continue
+			}
+			childPath := key
+			if path != "" {
+				childPath = path + "." + key
+			}
+			diffValue(childPath, e[key], value, changes)
+		}
+		return
+	case []interface{}:
+		e, ok := existing.([]interface{})
+		if !ok || len(e) != len(d) {
+			break
+		}
+		for i := range d {
+			diffValue(fmt.Sprintf("%s[%d]", path, i), e[i], d[i], changes)
+		}
+		return
+	}
+
+	if !equalValues(existing, desired) {
+		*changes = append(*changes, fmt.Sprintf("%s: %s -> %s", path, formatValue(existing), formatValue(desired)))
+	}
+}
+
+// equalValues compares scalars, treating numbers of different types as equal
+func equalValues(a, b interface{}) bool {
+	if reflect.DeepEqual(a, b) {
+		return true
+	}
+	return a != nil && b != nil && fmt.Sprint(a) == fmt.Sprint(b)
+}
+
+// formatValue renders a value for a diff line
+func formatValue(v interface{}) string {
+	if v == nil {
+		return "<unset>"
+	}
+	s := fmt.Sprintf("%v", v)
+	if len(s) > 80 {
+		s = s[:77] + "..."
+	}
+	return strings.ReplaceAll(s, "\n", "\\n")
+}
@@ -0,0 +1,119 @@
+package kubernetes
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+
+	"github.com/jtaleric/k8s-io/pkg/timeline"
+)
+
+// CordonNode marks a node unschedulable, or schedulable again
+func (c *Client) CordonNode(ctx context.Context, name string, unschedulable bool) error {
+	patch, err := json.Marshal(map[string]interface{}{
+		"spec": map[string]interface{}{"unschedulable": unschedulable},
+	})
+	if err != nil {
+		return err
+	}
+
+	if _, err := c.clientset.CoreV1().Nodes().Patch(ctx, name, types.MergePatchType, patch, metav1.PatchOptions{}); err != nil {
+		return fmt.Errorf("failed to update node %s: %w", name, classify(err))
+	}
+	return nil
+}
+
+// DeletePod deletes a pod with its default grace period
+func (c *Client) DeletePod(ctx context.Context, namespace, name string) error {
+	if err := c.clientset.CoreV1().Pods(namespace).Delete(ctx, name, metav1.DeleteOptions{}); err != nil {
+		return fmt.Errorf("failed to delete pod %s/%s: %w", namespace, name, classify(err))
+	}
+	return nil
+}
+
+// DrainNode evicts the pods of a node like kubectl drain, honoring pod disruption budgets.
+// DaemonSet and static pods stay, as do the pods keep returns true for. It returns the
+// evicted pods as namespace/name; pods whose eviction failed are logged and left running.
+func (c *Client) DrainNode(ctx context.Context, name string, keep func(corev1.Pod) bool) ([]string, error) {
+	pods, err := c.clientset.CoreV1().Pods("").List(ctx, metav1.ListOptions{
+		FieldSelector: "spec.nodeName=" + name,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list the pods of node %s: %w", name, classify(err))
+	}
+
+	var evicted []string
+	for _, pod := range pods.Items {
+		if isDaemonOrStaticPod(pod) || (keep != nil && keep(pod)) {
+			continue
+		}
+		if pod.Status.Phase == corev1.PodSucceeded || pod.Status.Phase == corev1.PodFailed {
+			continue
+		}
+
+		eviction := &policyv1.Eviction{ObjectMeta: metav1.ObjectMeta{Name: pod.Name, Namespace: pod.Namespace}}
+		if err := c.clientset.PolicyV1().Evictions(pod.Namespace).Evict(ctx, eviction); err != nil {
+			log.Printf("Warning: failed to evict %s/%s: %v", pod.Namespace, pod.Name, err)
+			continue
+		}
+		evicted = append(evicted, pod.Namespace+"/"+pod.Name)
+	}
+	return evicted, nil
+}
+
+// WaitForReplacementPods waits until expectedCount pods of a selector are running and ready
+// without counting the pods whose UID is in replaced, which were deleted or evicted
+func (c *Client) WaitForReplacementPods(ctx context.Context, namespace, labelSelector string, replaced map[types.UID]bool, expectedCount int, timeout time.Duration) error {
+	target := fmt.Sprintf("%s/%s (%d replaced)", namespace, labelSelector, len(replaced))
+	defer c.operations.begin("wait-pods", target)()
+	start := time.Now()
+
+	err := classify(wait.PollImmediate(2*time.Second, timeout, func() (bool, error) {
+		pods, err := c.ListPods(ctx, namespace, labelSelector)
+		if err != nil {
+			if Retryable(err) {
+				log.Printf("Warning: Transient error listing pods with selector %s (will retry): %v", labelSelector, err)
+				timeline.Record(timeline.EventRetry, target, "transient error: %v", err)
+				return false, nil
+			}
+			return false, fmt.Errorf("failed to list pods: %w", err)
+		}
+
+		readyCount := 0
+		for _, pod := range pods.Items {
+			if replaced[pod.UID] || pod.DeletionTimestamp != nil || pod.Status.Phase != corev1.PodRunning {
+				continue
+			}
+			for _, condition := range pod.Status.Conditions {
+				if condition.Type == corev1.PodReady && condition.Status == corev1.ConditionTrue {
+					readyCount++
+					break
+				}
+			}
+		}
+		return readyCount >= expectedCount, nil
+	}))
+	return waited("wait-pods", target, start, err)
+}
+
+// isDaemonOrStaticPod reports whether a pod is recreated on the same node, so draining
+// leaves it alone
+func isDaemonOrStaticPod(pod corev1.Pod) bool {
+	if _, ok := pod.Annotations[corev1.MirrorPodAnnotationKey]; ok {
+		return true
+	}
+	for _, owner := range pod.OwnerReferences {
+		if owner.Kind == "DaemonSet" {
+			return true
+		}
+	}
+	return false
+}
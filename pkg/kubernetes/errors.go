@@ -0,0 +1,105 @@
+package kubernetes
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+// Classes of the errors client methods return, for callers to branch on with errors.Is
+// instead of matching error messages. The underlying API errors stay in the chain, so
+// apierrors functions keep working.
+var (
+	ErrNotFound  = errors.New("not found")
+	ErrForbidden = errors.New("forbidden")
+	ErrTimeout   = errors.New("timed out") // A wait ran out of time
+	ErrTransient = errors.New("transient") // Network failures and overloaded API servers, worth retrying
+)
+
+// classError is an error tagged with its class, with the message of the error
+type classError struct {
+	class error
+	err   error
+}
+
+func (e *classError) Error() string {
+	return e.err.Error()
+}
+
+func (e *classError) Unwrap() []error {
+	return []error{e.class, e.err}
+}
+
+// classify returns err tagged with its class; errors without a class or with one already
+// are returned as they are
+func classify(err error) error {
+	if err == nil {
+		return nil
+	}
+	for _, class := range []error{ErrNotFound, ErrForbidden, ErrTimeout, ErrTransient} {
+		if errors.Is(err, class) {
+			return err
+		}
+	}
+
+	var class error
+	switch {
+	case apierrors.IsNotFound(err):
+		class = ErrNotFound
+	case apierrors.IsForbidden(err) || apierrors.IsUnauthorized(err):
+		class = ErrForbidden
+	case errors.Is(err, wait.ErrWaitTimeout) || errors.Is(err, context.DeadlineExceeded):
+		class = ErrTimeout
+	case apierrors.IsServerTimeout(err) || apierrors.IsTimeout(err) || apierrors.IsTooManyRequests(err) ||
+		apierrors.IsServiceUnavailable(err) || apierrors.IsInternalError(err) || isTransientError(err):
+		class = ErrTransient
+	default:
+		return err
+	}
+	return &classError{class: class, err: err}
+}
+
+// Retryable reports whether an operation that failed with err is worth retrying. It is the
+// retry policy of every wait of the client: transient errors are retried, missing or
+// forbidden resources and exhausted timeouts are not.
+func Retryable(err error) bool {
+	return errors.Is(classify(err), ErrTransient)
+}
+
+// isTransientError checks if an error is likely transient and should be retried, for errors
+// that do not come from the API server
+func isTransientError(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	errStr := err.Error()
+	// Network-related errors that are often transient
+	transientErrors := []string{
+		"client connection lost",
+		"connection reset by peer",
+		"timeout",
+		"temporary failure",
+		"network is unreachable",
+		"no route to host",
+		"connection refused",
+		"i/o timeout",
+	}
+
+	for _, transientErr := range transientErrors {
+		if strings.Contains(strings.ToLower(errStr), transientErr) {
+			return true
+		}
+	}
+
+	// Check for network-related error types
+	if _, ok := err.(net.Error); ok {
+		return true
+	}
+
+	return false
+}
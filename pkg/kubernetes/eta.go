@@ -0,0 +1,48 @@
+package kubernetes
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	batchv1 "k8s.io/api/batch/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// ETAAnnotation records on a job when it is expected to complete, read by the status and tui commands
+const ETAAnnotation = "k8s-io/eta"
+
+// SetJobETA annotates a job with its expected completion time
+func (c *Client) SetJobETA(ctx context.Context, name, namespace string, eta time.Time) error {
+	patch, err := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"annotations": map[string]string{ETAAnnotation: eta.UTC().Format(time.RFC3339)},
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = c.clientset.BatchV1().Jobs(namespace).Patch(ctx, name, types.MergePatchType, patch, metav1.PatchOptions{})
+	return err
+}
+
+// JobETA returns the expected completion time recorded on a job, if any
+func JobETA(job *batchv1.Job) (time.Time, bool) {
+	eta, err := time.Parse(time.RFC3339, job.Annotations[ETAAnnotation])
+	if err != nil {
+		return time.Time{}, false
+	}
+	return eta, true
+}
+
+// FormatETA describes an expected completion time relative to now
+func FormatETA(eta time.Time) string {
+	remaining := time.Until(eta).Round(time.Second)
+	if remaining < 0 {
+		return fmt.Sprintf("%s (overdue by %s)", eta.Local().Format(time.Kitchen), -remaining)
+	}
+	return fmt.Sprintf("%s (in %s)", eta.Local().Format(time.Kitchen), remaining)
+}
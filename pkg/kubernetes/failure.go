@@ -0,0 +1,130 @@
+package kubernetes
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+// JobFailure describes why the pod of a failed job terminated
+type JobFailure struct {
+	Pod           string
+	Node          string
+	Reason        string // Evicted, OOMKilled, a disruption reason such as EvictionByEvictionAPI, or Error
+	Message       string
+	Environmental bool // The failure was caused by the node or cluster rather than the benchmark
+}
+
+// String returns a one-line description of the failure
+func (f JobFailure) String() string {
+	s := f.Reason
+	if f.Pod != "" {
+		s = fmt.Sprintf("pod %s: %s", f.Pod, f.Reason)
+	}
+	if f.Message != "" {
+		s += " (" + f.Message + ")"
+	}
+	return s
+}
+
+// environmentalPodReasons are pod status reasons set when the node, not the workload, ended the pod
+var environmentalPodReasons = map[string]bool{
+	"Evicted":    true,
+	"NodeLost":   true,
+	"Shutdown":   true,
+	"Terminated": true,
+}
+
+// ClassifyJobFailure inspects the pods of a failed job and reports whether they were
+// evicted, OOM killed or disrupted by a node drain, which are worth retrying
+func (c *Client) ClassifyJobFailure(ctx context.Context, jobName, namespace string) (JobFailure, error) {
+	pods, err := c.ListPods(ctx, namespace, fmt.Sprintf("job-name=%s", jobName))
+	if err != nil {
+		return JobFailure{}, fmt.Errorf("failed to list pods for job %s: %w", jobName, err)
+	}
+
+	// Pods removed together with their node leave nothing to inspect
+	if len(pods.Items) == 0 {
+		return JobFailure{Reason: "PodDeleted", Message: "the job pod no longer exists", Environmental: true}, nil
+	}
+
+	var failure JobFailure
+	for _, pod := range pods.Items {
+		failure = ClassifyPod(pod)
+		if failure.Environmental {
+			return failure, nil
+		}
+	}
+
+	return failure, nil
+}
+
+// ClassifyPod classifies the termination of a single pod
+func ClassifyPod(pod corev1.Pod) JobFailure {
+	failure := JobFailure{Pod: pod.Name, Node: pod.Spec.NodeName, Reason: pod.Status.Reason, Message: pod.Status.Message}
+
+	if environmentalPodReasons[pod.Status.Reason] {
+		failure.Environmental = true
+		return failure
+	}
+
+	for _, condition := range pod.Status.Conditions {
+		if condition.Type == corev1.DisruptionTarget && condition.Status == corev1.ConditionTrue {
+			failure.Reason = condition.Reason
+			failure.Message = condition.Message
+			failure.Environmental = true
+			return failure
+		}
+	}
+
+	for _, status := range pod.Status.ContainerStatuses {
+		for _, state := range []corev1.ContainerState{status.State, status.LastTerminationState} {
+			if state.Terminated == nil {
+				continue
+			}
+			if state.Terminated.Reason == "OOMKilled" {
+				failure.Reason = "OOMKilled"
+				failure.Message = fmt.Sprintf("container %s exceeded its memory limit", status.Name)
+				failure.Environmental = true
+				return failure
+			}
+			if failure.Reason == "" {
+				failure.Reason = state.Terminated.Reason
+				failure.Message = fmt.Sprintf("container %s exited with code %d", status.Name, state.Terminated.ExitCode)
+			}
+		}
+	}
+
+	if failure.Reason == "" {
+		failure.Reason = string(pod.Status.Phase)
+	}
+	return failure
+}
+
+// DeleteJob deletes a job and its pods and waits until the job is gone, so it can be recreated with the same name
+func (c *Client) DeleteJob(ctx context.Context, name, namespace string) error {
+	propagation := metav1.DeletePropagationBackground
+	err := c.clientset.BatchV1().Jobs(namespace).Delete(ctx, name, metav1.DeleteOptions{PropagationPolicy: &propagation})
+	if err != nil && !apierrors.IsNotFound(err) {
+		return fmt.Errorf("failed to delete job %s: %w", name, classify(err))
+	}
+
+	return classify(wait.PollImmediate(2*time.Second, 2*time.Minute, func() (bool, error) {
+		_, err := c.GetJob(ctx, name, namespace)
+		if errors.Is(err, ErrNotFound) {
+			return true, nil
+		}
+		if err != nil && !Retryable(err) {
+			return false, fmt.Errorf("failed to get job: %w", err)
+		}
+		log.Printf("Waiting for job %s to be deleted", name)
+		return false, nil
+	}))
+}
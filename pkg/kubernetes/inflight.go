@@ -0,0 +1,80 @@
+package kubernetes
+
+import (
+	"io"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/jtaleric/k8s-io/pkg/timeline"
+)
+
+// Operation is a wait or a followed log stream the client is currently blocked on
+type Operation struct {
+	Kind   string    `json:"kind"`
+	Target string    `json:"target"`
+	Since  time.Time `json:"since"`
+}
+
+// operations tracks the in-flight operations of a client, the zero value is ready to use
+type operations struct {
+	mu   sync.Mutex
+	next int
+	ops  map[int]Operation
+}
+
+// begin records an operation and returns the function that ends it
+func (o *operations) begin(kind, target string) func() {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if o.ops == nil {
+		o.ops = make(map[int]Operation)
+	}
+	id := o.next
+	o.next++
+	o.ops[id] = Operation{Kind: kind, Target: target, Since: time.Now().UTC()}
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			o.mu.Lock()
+			delete(o.ops, id)
+			o.mu.Unlock()
+		})
+	}
+}
+
+// InFlight returns the operations the client is blocked on, oldest first
+func (c *Client) InFlight() []Operation {
+	c.operations.mu.Lock()
+	defer c.operations.mu.Unlock()
+
+	ops := make([]Operation, 0, len(c.operations.ops))
+	for _, op := range c.operations.ops {
+		ops = append(ops, op)
+	}
+	sort.Slice(ops, func(i, j int) bool { return ops[i].Since.Before(ops[j].Since) })
+	return ops
+}
+
+// trackedStream ends its operation when the stream is closed
+type trackedStream struct {
+	io.ReadCloser
+	done func()
+}
+
+// Close closes the stream and ends the operation
+func (s *trackedStream) Close() error {
+	s.done()
+	return s.ReadCloser.Close()
+}
+
+// waited records a wait that was satisfied in the run event log and returns the error of
+// the wait
+func waited(kind, target string, start time.Time, err error) error {
+	if err == nil {
+		timeline.Record(timeline.EventWait, target, "%s satisfied after %s", kind, time.Since(start).Round(time.Second))
+	}
+	return err
+}
@@ -0,0 +1,93 @@
+package kubetest
+
+import (
+	"context"
+	"net/http"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/kubernetes/scheme"
+	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/rest"
+	fakerest "k8s.io/client-go/rest/fake"
+)
+
+// clientset is the fake clientset with pod logs served from the cluster, the fake clientset
+// returns "fake logs" for every pod
+type clientset struct {
+	*fake.Clientset
+	cluster *Cluster
+}
+
+func (c *clientset) CoreV1() typedcorev1.CoreV1Interface {
+	return &coreV1{CoreV1Interface: c.Clientset.CoreV1(), cluster: c.cluster}
+}
+
+type coreV1 struct {
+	typedcorev1.CoreV1Interface
+	cluster *Cluster
+}
+
+func (c *coreV1) Pods(namespace string) typedcorev1.PodInterface {
+	return &pods{PodInterface: c.CoreV1Interface.Pods(namespace), cluster: c.cluster}
+}
+
+type pods struct {
+	typedcorev1.PodInterface
+	cluster *Cluster
+}
+
+func (p *pods) GetLogs(name string, opts *corev1.PodLogOptions) *rest.Request {
+	logs := p.cluster.podLogs(name)
+	client := &fakerest.RESTClient{
+		Client: fakerest.CreateHTTPClient(func(*http.Request) (*http.Response, error) {
+			return logsResponse(logs), nil
+		}),
+		NegotiatedSerializer: scheme.Codecs.WithoutConversion(),
+		GroupVersion:         corev1.SchemeGroupVersion,
+		VersionedAPIPath:     "/api/v1",
+	}
+	return client.Request()
+}
+
+// dynamicClient is the fake dynamic client that fails to list the custom resources the
+// cluster does not have, the fake dynamic client panics for them
+type dynamicClient struct {
+	*dynamicfake.FakeDynamicClient
+}
+
+func (d *dynamicClient) Resource(gvr schema.GroupVersionResource) dynamic.NamespaceableResourceInterface {
+	resource := d.FakeDynamicClient.Resource(gvr)
+	if _, ok := customListKinds[gvr]; ok || scheme.Scheme.IsGroupRegistered(gvr.Group) {
+		return resource
+	}
+	return &missingResource{NamespaceableResourceInterface: resource, gvr: gvr}
+}
+
+type missingResource struct {
+	dynamic.NamespaceableResourceInterface
+	gvr schema.GroupVersionResource
+}
+
+func (r *missingResource) Namespace(namespace string) dynamic.ResourceInterface {
+	return &missingNamespacedResource{ResourceInterface: r.NamespaceableResourceInterface.Namespace(namespace), gvr: r.gvr}
+}
+
+func (r *missingResource) List(context.Context, metav1.ListOptions) (*unstructured.UnstructuredList, error) {
+	return nil, apierrors.NewNotFound(r.gvr.GroupResource(), "")
+}
+
+type missingNamespacedResource struct {
+	dynamic.ResourceInterface
+	gvr schema.GroupVersionResource
+}
+
+func (r *missingNamespacedResource) List(context.Context, metav1.ListOptions) (*unstructured.UnstructuredList, error) {
+	return nil, apierrors.NewNotFound(r.gvr.GroupResource(), "")
+}
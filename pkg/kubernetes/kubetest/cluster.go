@@ -0,0 +1,370 @@
+// Package kubetest provides a fake cluster for exercising workloads without a Kubernetes API
+// server. Its client is backed by the client-go fake clientset and dynamic fake client, which
+// share one object tracker, so manifests applied through the dynamic client are seen by the
+// typed waits. The cluster plays the part of the scheduler, the kubelet, the job controller,
+// the token controller and the volume provisioner: pods run as soon as they are created, jobs
+// complete with a pod whose logs the test provides, claims bind to a volume created for
+// them and service account token secrets get a token.
+package kubetest
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
+	k8stesting "k8s.io/client-go/testing"
+
+	"github.com/jtaleric/k8s-io/pkg/kubernetes"
+)
+
+// Cluster is a fake cluster and the k8s-io client talking to it
+type Cluster struct {
+	Client    *kubernetes.Client
+	Clientset *fake.Clientset
+	Dynamic   *dynamicfake.FakeDynamicClient
+
+	mu      sync.Mutex
+	typed   map[schema.GroupVersionResource]bool
+	nodes   []string
+	nextIP  int
+	nextPod int
+	logs    map[string]string
+	applied []string
+}
+
+// New returns a fake cluster holding objects, such as nodes and storage classes. Pods are
+// scheduled round-robin to the nodes among the objects, or to node-1 without any.
+func New(objects ...runtime.Object) *Cluster {
+	c := &Cluster{
+		Clientset: fake.NewSimpleClientset(objects...),
+		typed:     make(map[schema.GroupVersionResource]bool),
+		logs:      make(map[string]string),
+	}
+	for _, obj := range objects {
+		if node, ok := obj.(*corev1.Node); ok {
+			c.nodes = append(c.nodes, node.Name)
+		}
+	}
+	if len(c.nodes) == 0 {
+		c.nodes = []string{"node-1"}
+	}
+
+	// The dynamic client converts the objects of the typed tracker with its own scheme
+	dynamicScheme := runtime.NewScheme()
+	if err := scheme.AddToScheme(dynamicScheme); err != nil {
+		panic(err)
+	}
+	for gvk := range scheme.Scheme.AllKnownTypes() {
+		if !strings.HasSuffix(gvk.Kind, "List") {
+			gvr, _ := meta.UnsafeGuessKindToResource(gvk)
+			c.typed[gvr] = true
+		}
+	}
+	c.Dynamic = dynamicfake.NewSimpleDynamicClientWithCustomListKinds(dynamicScheme, customListKinds)
+	c.Dynamic.PrependReactor("*", "*", c.reactDynamic)
+	c.Clientset.PrependReactor("create", "*", c.reactCreate)
+
+	c.Client = c.NewClient()
+	return c
+}
+
+// NewClient returns another k8s-io client of the cluster, such as the client of a second
+// run sharing it
+func (c *Cluster) NewClient() *kubernetes.Client {
+	return kubernetes.NewClientFromInterfaces(&clientset{Clientset: c.Clientset, cluster: c},
+		&dynamicClient{FakeDynamicClient: c.Dynamic}, &rest.Config{Host: "https://api.fake.invalid:6443"})
+}
+
+// SetLogs sets the logs of the pods whose names start with prefix, such as the name of a
+// job. Pods without logs return an empty log.
+func (c *Cluster) SetLogs(prefix, logs string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.logs[prefix] = logs
+}
+
+// podLogs returns the logs of a pod, from the longest prefix matching its name
+func (c *Cluster) podLogs(name string) string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	match := ""
+	for prefix := range c.logs {
+		if strings.HasPrefix(name, prefix) && len(prefix) > len(match) {
+			match = prefix
+		}
+	}
+	return c.logs[match]
+}
+
+// Applied returns the resources created through the client, as namespace/Kind/name in the
+// order they were created
+func (c *Cluster) Applied() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]string(nil), c.applied...)
+}
+
+// Get returns an object of the cluster as unstructured, from its resource such as "pods" or
+// "jobs.batch"
+func (c *Cluster) Get(resource, namespace, name string) (*unstructured.Unstructured, error) {
+	gvr, err := c.resource(resource)
+	if err != nil {
+		return nil, err
+	}
+	var obj runtime.Object
+	if c.typed[gvr] {
+		obj, err = c.Clientset.Tracker().Get(gvr, namespace, name)
+	} else {
+		obj, err = c.Dynamic.Tracker().Get(gvr, namespace, name)
+	}
+	if err != nil {
+		return nil, err
+	}
+	content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+	if err != nil {
+		return nil, err
+	}
+	return &unstructured.Unstructured{Object: content}, nil
+}
+
+// resource returns the version of a resource the clients use
+func (c *Cluster) resource(resource string) (schema.GroupVersionResource, error) {
+	name, group, _ := strings.Cut(resource, ".")
+	for gvr := range c.typed {
+		if gvr.Resource == name && gvr.Group == group {
+			return gvr, nil
+		}
+	}
+	for gvr := range customListKinds {
+		if gvr.Resource == name && gvr.Group == group {
+			return gvr, nil
+		}
+	}
+	return schema.GroupVersionResource{}, fmt.Errorf("unknown resource %s", resource)
+}
+
+// reactCreate stores objects created through the typed clientset after running them
+func (c *Cluster) reactCreate(action k8stesting.Action) (bool, runtime.Object, error) {
+	create, ok := action.(k8stesting.CreateAction)
+	if !ok || action.GetSubresource() != "" {
+		return false, nil, nil
+	}
+	obj, err := c.create(action.GetResource(), create.GetObject().DeepCopyObject(), action.GetNamespace())
+	return true, obj, err
+}
+
+// reactDynamic passes the requests of the dynamic client for built-in resources to the
+// typed tracker, so both clients see the same objects. Custom resources stay in the tracker
+// of the dynamic client.
+func (c *Cluster) reactDynamic(action k8stesting.Action) (bool, runtime.Object, error) {
+	gvr := action.GetResource()
+	if !c.typed[gvr] {
+		if create, ok := action.(k8stesting.CreateAction); ok && action.GetSubresource() == "" {
+			c.record(create.GetObject())
+		}
+		return false, nil, nil
+	}
+
+	switch a := action.(type) {
+	case k8stesting.CreateAction:
+		if action.GetSubresource() != "" {
+			break
+		}
+		obj, err := typedObject(a.GetObject())
+		if err != nil {
+			return true, nil, err
+		}
+		obj, err = c.create(gvr, obj, action.GetNamespace())
+		return true, obj, err
+	case k8stesting.UpdateAction:
+		obj, err := typedObject(a.GetObject())
+		if err != nil {
+			return true, nil, err
+		}
+		if err := c.Clientset.Tracker().Update(gvr, obj, action.GetNamespace()); err != nil {
+			return true, nil, err
+		}
+		return true, obj, nil
+	}
+	return k8stesting.ObjectReaction(c.Clientset.Tracker())(action)
+}
+
+// create runs an object the way the cluster would and adds it to the typed tracker
+func (c *Cluster) create(gvr schema.GroupVersionResource, obj runtime.Object, namespace string) (runtime.Object, error) {
+	if accessor, err := meta.Accessor(obj); err == nil && accessor.GetCreationTimestamp().Time.IsZero() {
+		accessor.SetCreationTimestamp(metav1.Now())
+	}
+
+	var created []runtime.Object
+	switch o := obj.(type) {
+	case *corev1.Pod:
+		c.run(o)
+	case *corev1.PersistentVolumeClaim:
+		created = append(created, c.bind(o))
+	case *batchv1.Job:
+		created = append(created, c.complete(o, namespace))
+	case *corev1.Secret:
+		issueToken(o)
+	}
+
+	if err := c.Clientset.Tracker().Create(gvr, obj, namespace); err != nil {
+		return nil, err
+	}
+	c.record(obj)
+	for _, o := range created {
+		accessor, _ := meta.Accessor(o)
+		if err := c.Clientset.Tracker().Add(o); err != nil {
+			return nil, fmt.Errorf("failed to add %s: %w", accessor.GetName(), err)
+		}
+	}
+	return obj, nil
+}
+
+// record adds an object to the applied resources
+func (c *Cluster) record(obj runtime.Object) {
+	accessor, err := meta.Accessor(obj)
+	if err != nil {
+		return
+	}
+	kind := obj.GetObjectKind().GroupVersionKind().Kind
+	if kind == "" {
+		if gvks, _, err := scheme.Scheme.ObjectKinds(obj); err == nil {
+			kind = gvks[0].Kind
+		}
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.applied = append(c.applied, accessor.GetNamespace()+"/"+kind+"/"+accessor.GetName())
+}
+
+// issueToken fills in the token of a service account token secret, as the token controller
+// would
+func issueToken(secret *corev1.Secret) {
+	if secret.Type != corev1.SecretTypeServiceAccountToken || len(secret.Data["token"]) > 0 {
+		return
+	}
+	if secret.Data == nil {
+		secret.Data = make(map[string][]byte)
+	}
+	secret.Data["token"] = []byte("token-" + secret.Namespace + "-" + secret.Name)
+}
+
+// run schedules a pod to a node and starts it
+func (c *Cluster) run(pod *corev1.Pod) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if pod.Spec.NodeName == "" {
+		pod.Spec.NodeName = c.nodes[c.nextPod%len(c.nodes)]
+	}
+	c.nextPod++
+	if pod.Status.Phase == "" {
+		now := metav1.Now()
+		pod.Status.Phase = corev1.PodRunning
+		pod.Status.Conditions = []corev1.PodCondition{
+			{Type: corev1.PodScheduled, Status: corev1.ConditionTrue, LastTransitionTime: now},
+			{Type: corev1.PodReady, Status: corev1.ConditionTrue, LastTransitionTime: now},
+		}
+	}
+	if pod.Status.PodIP == "" {
+		c.nextIP++
+		pod.Status.PodIP = fmt.Sprintf("10.128.0.%d", c.nextIP)
+	}
+}
+
+// bind binds a claim and returns the volume provisioned for it
+func (c *Cluster) bind(pvc *corev1.PersistentVolumeClaim) *corev1.PersistentVolume {
+	if pvc.Spec.VolumeName == "" {
+		pvc.Spec.VolumeName = fmt.Sprintf("pvc-%s-%s", pvc.Namespace, pvc.Name)
+	}
+	pvc.Status.Phase = corev1.ClaimBound
+	pvc.Status.AccessModes = pvc.Spec.AccessModes
+	pvc.Status.Capacity = pvc.Spec.Resources.Requests
+
+	return &corev1.PersistentVolume{
+		ObjectMeta: metav1.ObjectMeta{Name: pvc.Spec.VolumeName, CreationTimestamp: metav1.Now()},
+		Spec: corev1.PersistentVolumeSpec{
+			AccessModes:      pvc.Spec.AccessModes,
+			Capacity:         pvc.Spec.Resources.Requests,
+			ClaimRef:         &corev1.ObjectReference{Kind: "PersistentVolumeClaim", Namespace: pvc.Namespace, Name: pvc.Name},
+			StorageClassName: stringValue(pvc.Spec.StorageClassName),
+			VolumeMode:       pvc.Spec.VolumeMode,
+		},
+		Status: corev1.PersistentVolumeStatus{Phase: corev1.VolumeBound},
+	}
+}
+
+// complete marks a job as succeeded and returns the pod that ran it
+func (c *Cluster) complete(job *batchv1.Job, namespace string) *corev1.Pod {
+	now := metav1.Now()
+	job.Status.Succeeded = 1
+	job.Status.StartTime = &now
+	job.Status.CompletionTime = &now
+	job.Status.Conditions = []batchv1.JobCondition{{Type: batchv1.JobComplete, Status: corev1.ConditionTrue}}
+
+	labels := map[string]string{"job-name": job.Name}
+	for k, v := range job.Spec.Template.Labels {
+		labels[k] = v
+	}
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              job.Name + "-" + fmt.Sprintf("%05d", len(c.Applied())),
+			Namespace:         namespace,
+			CreationTimestamp: now,
+			Labels:            labels,
+			Annotations:       job.Spec.Template.Annotations,
+		},
+		Spec: *job.Spec.Template.Spec.DeepCopy(),
+	}
+	c.run(pod)
+	pod.Status.Phase = corev1.PodSucceeded
+	return pod
+}
+
+// stringValue returns the string s points to, empty for nil
+func stringValue(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
+
+// typedObject converts an unstructured object of a built-in kind to its Go type
+func typedObject(obj runtime.Object) (runtime.Object, error) {
+	u, ok := obj.(*unstructured.Unstructured)
+	if !ok {
+		return obj, nil
+	}
+	typed, err := scheme.Scheme.New(u.GroupVersionKind())
+	if err != nil {
+		return nil, err
+	}
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(u.Object, typed); err != nil {
+		return nil, fmt.Errorf("failed to convert %s %s: %w", u.GetKind(), u.GetName(), err)
+	}
+	return typed, nil
+}
+
+// customListKinds are the custom resources of the fake cluster, those of KubeVirt. Listing
+// any other custom resource fails as not found, like on a cluster without its CRD.
+var customListKinds = map[schema.GroupVersionResource]string{
+	{Group: "kubevirt.io", Version: "v1", Resource: "virtualmachineinstances"}: "VirtualMachineInstanceList",
+	{Group: "kubevirt.io", Version: "v1", Resource: "virtualmachines"}:         "VirtualMachineList",
+}
+
+// logsResponse returns an HTTP response with a log as its body
+func logsResponse(logs string) *http.Response {
+	return &http.Response{StatusCode: http.StatusOK, Header: http.Header{}, Body: io.NopCloser(strings.NewReader(logs))}
+}
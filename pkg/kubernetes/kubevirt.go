@@ -0,0 +1,403 @@
+package kubernetes
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/wait"
+
+	"github.com/jtaleric/k8s-io/pkg/timeline"
+)
+
+// vmiGVR is the resource of KubeVirt VirtualMachineInstances
+var vmiGVR = schema.GroupVersionResource{Group: "kubevirt.io", Version: "v1", Resource: "virtualmachineinstances"}
+
+// GuestConsoleContainer is the virt-launcher container that logs the serial console of a VMI
+// with spec.domain.devices.logSerialConsole enabled
+const GuestConsoleContainer = "guest-console-log"
+
+// VMI phases reported in status.phase
+const (
+	VMIPhaseScheduled = "Scheduled"
+	VMIPhaseRunning   = "Running"
+	VMIPhaseSucceeded = "Succeeded"
+	VMIPhaseFailed    = "Failed"
+)
+
+// GetVMI gets a VirtualMachineInstance
+func (c *Client) GetVMI(ctx context.Context, namespace, name string) (*unstructured.Unstructured, error) {
+	vmi, err := c.dynamicClient.Resource(vmiGVR).Namespace(namespace).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get VMI %s: %w", name, classify(err))
+	}
+	return vmi, nil
+}
+
+// VMIPhase returns the phase of a VMI, empty before KubeVirt reported one
+func VMIPhase(vmi unstructured.Unstructured) string {
+	phase, _, _ := unstructured.NestedString(vmi.Object, "status", "phase")
+	return phase
+}
+
+// WaitForVMIsPhase waits until expectedCount VMIs matching a label selector reached phase.
+// A VMI that failed ends the wait, unless Failed is the phase waited for.
+func (c *Client) WaitForVMIsPhase(ctx context.Context, namespace, labelSelector, phase string, expectedCount int, timeout time.Duration) error {
+	target := fmt.Sprintf("%s/%s (%d %s)", namespace, labelSelector, expectedCount, phase)
+	defer c.operations.begin("wait-vmis", target)()
+	start := time.Now()
+
+	err := classify(wait.PollImmediate(5*time.Second, timeout, func() (bool, error) {
+		vmis, err := c.ListVMIs(ctx, namespace, labelSelector)
+		if err != nil {
+			if Retryable(err) {
+				log.Printf("Warning: Transient error listing VMIs with selector %s (will retry): %v", labelSelector, err)
+				timeline.Record(timeline.EventRetry, namespace+"/"+labelSelector, "transient error: %v", err)
+				return false, nil
+			}
+			return false, err
+		}
+
+		count := 0
+		for _, vmi := range vmis {
+			switch VMIPhase(vmi) {
+			case phase:
+				count++
+			case VMIPhaseFailed:
+				return false, fmt.Errorf("VMI %s failed", vmi.GetName())
+			}
+		}
+
+		if count < expectedCount {
+			log.Printf("Waiting for VMIs: %d/%d %s (selector: %s)", count, expectedCount, phase, labelSelector)
+		}
+		return count >= expectedCount, nil
+	}))
+	return waited("wait-vmis", target, start, err)
+}
+
+// GuestInfo is what KubeVirt and the guest agent report about a running VMI
+type GuestInfo struct {
+	Name         string
+	Node         string
+	IPs          []string // Addresses of the first interface, reported by the guest agent or KubeVirt
+	AgentVersion string   // Empty when the guest agent is not connected
+	Hostname     string
+	OS           string
+	Kernel       string
+}
+
+// GetVMIGuestInfo returns the node and IPs of a VMI, and the hostname and OS the guest agent
+// reports when it is connected
+func (c *Client) GetVMIGuestInfo(ctx context.Context, vmi unstructured.Unstructured) (GuestInfo, error) {
+	info := GuestInfo{Name: vmi.GetName()}
+	info.Node, _, _ = unstructured.NestedString(vmi.Object, "status", "nodeName")
+
+	info.IPs = vmiIPs(vmi)
+
+	if !vmiAgentConnected(vmi) {
+		return info, nil
+	}
+
+	data, err := c.clientset.Discovery().RESTClient().Get().
+		AbsPath("/apis/subresources.kubevirt.io/v1/namespaces", vmi.GetNamespace(), "virtualmachineinstances", vmi.GetName(), "guestosinfo").
+		DoRaw(ctx)
+	if err != nil {
+		return info, fmt.Errorf("failed to get guest OS info of VMI %s: %w", vmi.GetName(), err)
+	}
+
+	var agent struct {
+		GuestAgentVersion string `json:"guestAgentVersion"`
+		Hostname          string `json:"hostname"`
+		OS                struct {
+			PrettyName    string `json:"prettyName"`
+			KernelRelease string `json:"kernelRelease"`
+		} `json:"os"`
+	}
+	if err := json.Unmarshal(data, &agent); err != nil {
+		return info, fmt.Errorf("failed to decode guest OS info of VMI %s: %w", vmi.GetName(), err)
+	}
+	info.AgentVersion = agent.GuestAgentVersion
+	info.Hostname = agent.Hostname
+	info.OS = agent.OS.PrettyName
+	info.Kernel = agent.OS.KernelRelease
+	return info, nil
+}
+
+// WaitForVMIGuestInfo waits until expectedCount VMIs matching a label selector are running
+// and report an IP address, and returns their guest info
+func (c *Client) WaitForVMIGuestInfo(ctx context.Context, namespace, labelSelector string, expectedCount int, timeout time.Duration) ([]GuestInfo, error) {
+	if err := c.WaitForVMIsPhase(ctx, namespace, labelSelector, VMIPhaseRunning, expectedCount, timeout); err != nil {
+		return nil, err
+	}
+
+	var running []unstructured.Unstructured
+	err := wait.PollImmediate(2*time.Second, timeout, func() (bool, error) {
+		vmis, err := c.ListVMIs(ctx, namespace, labelSelector)
+		if err != nil {
+			if Retryable(err) {
+				log.Printf("Warning: Transient error listing VMIs with selector %s (will retry): %v", labelSelector, err)
+				timeline.Record(timeline.EventRetry, namespace+"/"+labelSelector, "transient error: %v", err)
+				return false, nil
+			}
+			return false, err
+		}
+
+		running = running[:0]
+		for _, vmi := range vmis {
+			if VMIPhase(vmi) == VMIPhaseRunning && len(vmiIPs(vmi)) > 0 {
+				running = append(running, vmi)
+			}
+		}
+		return len(running) >= expectedCount, nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("VMIs did not report their addresses: %w", classify(err))
+	}
+
+	var infos []GuestInfo
+	for _, vmi := range running {
+		info, err := c.GetVMIGuestInfo(ctx, vmi)
+		if err != nil {
+			log.Printf("Warning: %v", err)
+		}
+		infos = append(infos, info)
+	}
+	return infos, nil
+}
+
+// vmiIPs returns the addresses of the first interface of a VMI
+func vmiIPs(vmi unstructured.Unstructured) []string {
+	interfaces, _, _ := unstructured.NestedSlice(vmi.Object, "status", "interfaces")
+	if len(interfaces) == 0 {
+		return nil
+	}
+	iface, ok := interfaces[0].(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	if ips, _, _ := unstructured.NestedStringSlice(iface, "ipAddresses"); len(ips) > 0 {
+		return ips
+	}
+	if ip, _, _ := unstructured.NestedString(iface, "ipAddress"); ip != "" {
+		return []string{ip}
+	}
+	return nil
+}
+
+// vmiAgentConnected reports whether the guest agent of a VMI is connected
+func vmiAgentConnected(vmi unstructured.Unstructured) bool {
+	conditions, _, _ := unstructured.NestedSlice(vmi.Object, "status", "conditions")
+	for _, c := range conditions {
+		condition, ok := c.(map[string]interface{})
+		if ok && condition["type"] == "AgentConnected" && condition["status"] == "True" {
+			return true
+		}
+	}
+	return false
+}
+
+// StartVM starts a VirtualMachine like virtctl start
+func (c *Client) StartVM(ctx context.Context, namespace, name string) error {
+	return c.putSubresource(ctx, namespace, "virtualmachines", name, "start")
+}
+
+// StopVM stops a VirtualMachine like virtctl stop, which deletes its VMI
+func (c *Client) StopVM(ctx context.Context, namespace, name string) error {
+	return c.putSubresource(ctx, namespace, "virtualmachines", name, "stop")
+}
+
+// RestartVM restarts a VirtualMachine like virtctl restart, which replaces its VMI
+func (c *Client) RestartVM(ctx context.Context, namespace, name string) error {
+	return c.putSubresource(ctx, namespace, "virtualmachines", name, "restart")
+}
+
+// SoftRebootVMI reboots the guest of a VMI through the guest agent, keeping the VMI and its pod
+func (c *Client) SoftRebootVMI(ctx context.Context, namespace, name string) error {
+	return c.putSubresource(ctx, namespace, "virtualmachineinstances", name, "softreboot")
+}
+
+// StopVMI stops a VirtualMachineInstance, which has no owner to start it again
+func (c *Client) StopVMI(ctx context.Context, namespace, name string) error {
+	if err := c.dynamicClient.Resource(vmiGVR).Namespace(namespace).Delete(ctx, name, metav1.DeleteOptions{}); err != nil {
+		return fmt.Errorf("failed to stop VMI %s: %w", name, err)
+	}
+	return nil
+}
+
+// putSubresource calls a KubeVirt subresource API, which the dynamic client cannot reach
+func (c *Client) putSubresource(ctx context.Context, namespace, resource, name, action string) error {
+	err := c.clientset.Discovery().RESTClient().Put().
+		AbsPath("/apis/subresources.kubevirt.io/v1/namespaces", namespace, resource, name, action).
+		Body([]byte("{}")).
+		Do(ctx).Error()
+	if err != nil {
+		return fmt.Errorf("failed to %s %s %s: %w", action, resource, name, classify(err))
+	}
+	return nil
+}
+
+// ListVMIs lists the VirtualMachineInstances of a namespace matching a label selector
+func (c *Client) ListVMIs(ctx context.Context, namespace, labelSelector string) ([]unstructured.Unstructured, error) {
+	list, err := c.dynamicClient.Resource(vmiGVR).Namespace(namespace).List(ctx, metav1.ListOptions{
+		LabelSelector: labelSelector,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list VMIs: %w", classify(err))
+	}
+	return list.Items, nil
+}
+
+// GetVirtLauncherPod returns the virt-launcher pod running a VMI, the most recent one if the
+// VMI was migrated
+func (c *Client) GetVirtLauncherPod(ctx context.Context, vmi unstructured.Unstructured) (*corev1.Pod, error) {
+	pods, err := c.ListPods(ctx, vmi.GetNamespace(), fmt.Sprintf("kubevirt.io/created-by=%s", vmi.GetUID()))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list virt-launcher pods of VMI %s: %w", vmi.GetName(), err)
+	}
+	if len(pods.Items) == 0 {
+		return nil, fmt.Errorf("no virt-launcher pod found for VMI %s", vmi.GetName())
+	}
+
+	latest := pods.Items[0]
+	for _, pod := range pods.Items[1:] {
+		if pod.CreationTimestamp.After(latest.CreationTimestamp.Time) {
+			latest = pod
+		}
+	}
+	return &latest, nil
+}
+
+// GetContainerLogs gets the complete logs of a container of a pod
+func (c *Client) GetContainerLogs(ctx context.Context, namespace, podName, containerName string) (string, error) {
+	data, err := c.clientset.CoreV1().Pods(namespace).GetLogs(podName, &corev1.PodLogOptions{
+		Container: containerName,
+	}).DoRaw(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to get logs of %s/%s: %w", podName, containerName, err)
+	}
+	return string(data), nil
+}
+
+// HasContainer reports whether a pod has a container with the given name
+func HasContainer(pod *corev1.Pod, name string) bool {
+	for _, container := range pod.Spec.Containers {
+		if container.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// instancetypeGroup is the API group of KubeVirt instancetypes and preferences
+const instancetypeGroup = "instancetype.kubevirt.io"
+
+// Lowercase kinds of instancetype and preference references
+const (
+	clusterInstancetypeKind = "virtualmachineclusterinstancetype"
+	instancetypeKind        = "virtualmachineinstancetype"
+	clusterPreferenceKind   = "virtualmachineclusterpreference"
+	preferenceKind          = "virtualmachinepreference"
+)
+
+// VMResources are the guest resources an instancetype and a preference define
+type VMResources struct {
+	CPUs    int    // Guest vCPUs, 0 without an instancetype
+	Memory  string // Guest memory, empty without an instancetype
+	DiskBus string // Preferred disk bus, empty when the preference sets none
+}
+
+// ParseVMResourceRef parses an instancetype or preference reference the way virtctl does:
+// "<name>" or "<kind>/<name>", where kind is the lowercase kind and a bare name refers to
+// the cluster-wide kind. It returns the resource to get, namespaced or not.
+func ParseVMResourceRef(ref, clusterKind, namespacedKind string) (resource string, namespaced bool, name string, err error) {
+	kind, name, found := strings.Cut(ref, "/")
+	if !found {
+		kind, name = clusterKind, ref
+	}
+	if name == "" {
+		return "", false, "", fmt.Errorf("invalid reference %q, use <name> or <kind>/<name>", ref)
+	}
+
+	switch strings.ToLower(kind) {
+	case clusterKind:
+		return clusterKind + "s", false, name, nil
+	case namespacedKind:
+		return namespacedKind + "s", true, name, nil
+	}
+	return "", false, "", fmt.Errorf("invalid kind %q in %q, use %s or %s", kind, ref, clusterKind, namespacedKind)
+}
+
+// ValidateVMResourceRefs checks an instancetype and a preference reference, either may be empty
+func ValidateVMResourceRefs(instancetype, preference string) error {
+	if instancetype != "" {
+		if _, _, _, err := ParseVMResourceRef(instancetype, clusterInstancetypeKind, instancetypeKind); err != nil {
+			return fmt.Errorf("vm_instancetype: %w", err)
+		}
+	}
+	if preference != "" {
+		if _, _, _, err := ParseVMResourceRef(preference, clusterPreferenceKind, preferenceKind); err != nil {
+			return fmt.Errorf("vm_preference: %w", err)
+		}
+	}
+	return nil
+}
+
+// ResolveVMResources gets the guest vCPUs and memory of an instancetype and the disk bus of a
+// preference, so VMIs can be sized like a VirtualMachine referencing them. Either reference
+// may be empty.
+func (c *Client) ResolveVMResources(ctx context.Context, namespace, instancetype, preference string) (VMResources, error) {
+	var res VMResources
+
+	if instancetype != "" {
+		spec, err := c.getInstancetypeSpec(ctx, namespace, instancetype, clusterInstancetypeKind, instancetypeKind)
+		if err != nil {
+			return res, err
+		}
+		cpus, _, _ := unstructured.NestedInt64(spec, "cpu", "guest")
+		res.CPUs = int(cpus)
+		res.Memory, _, _ = unstructured.NestedString(spec, "memory", "guest")
+		if res.CPUs == 0 || res.Memory == "" {
+			return res, fmt.Errorf("instancetype %s does not define cpu.guest and memory.guest", instancetype)
+		}
+	}
+
+	if preference != "" {
+		spec, err := c.getInstancetypeSpec(ctx, namespace, preference, clusterPreferenceKind, preferenceKind)
+		if err != nil {
+			return res, err
+		}
+		res.DiskBus, _, _ = unstructured.NestedString(spec, "devices", "preferredDiskBus")
+	}
+
+	return res, nil
+}
+
+// getInstancetypeSpec gets the spec of an instancetype or preference reference
+func (c *Client) getInstancetypeSpec(ctx context.Context, namespace, ref, clusterKind, namespacedKind string) (map[string]interface{}, error) {
+	resource, namespaced, name, err := ParseVMResourceRef(ref, clusterKind, namespacedKind)
+	if err != nil {
+		return nil, err
+	}
+
+	gvr := schema.GroupVersionResource{Group: instancetypeGroup, Version: "v1beta1", Resource: resource}
+	var obj *unstructured.Unstructured
+	if namespaced {
+		obj, err = c.dynamicClient.Resource(gvr).Namespace(namespace).Get(ctx, name, metav1.GetOptions{})
+	} else {
+		obj, err = c.dynamicClient.Resource(gvr).Get(ctx, name, metav1.GetOptions{})
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get %s %s: %w", strings.TrimSuffix(resource, "s"), name, err)
+	}
+
+	spec, _, _ := unstructured.NestedMap(obj.Object, "spec")
+	return spec, nil
+}
@@ -0,0 +1,53 @@
+package kubernetes
+
+import (
+	"context"
+	"fmt"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Supported service meshes
+const (
+	MeshNone    = ""
+	MeshIstio   = "istio"
+	MeshLinkerd = "linkerd"
+)
+
+// DetectServiceMesh reports which service mesh injects sidecars into pods in the namespace
+func (c *Client) DetectServiceMesh(ctx context.Context, namespace string) (string, error) {
+	ns, err := c.clientset.CoreV1().Namespaces().Get(ctx, namespace, metav1.GetOptions{})
+	if err != nil {
+		return MeshNone, fmt.Errorf("failed to get namespace %s: %w", namespace, err)
+	}
+
+	if ns.Labels["istio-injection"] == "enabled" || ns.Labels["istio.io/rev"] != "" {
+		return MeshIstio, nil
+	}
+
+	if ns.Annotations["linkerd.io/inject"] == "enabled" {
+		return MeshLinkerd, nil
+	}
+
+	return MeshNone, nil
+}
+
+// MeshPodAnnotations returns the pod annotations for a service mesh. When enabled is false
+// sidecar injection is disabled, otherwise the application containers are held until the
+// sidecar proxy is ready.
+func MeshPodAnnotations(mesh string, enabled bool) map[string]string {
+	switch mesh {
+	case MeshIstio:
+		if enabled {
+			return map[string]string{"proxy.istio.io/config": "holdApplicationUntilProxyStarts: true"}
+		}
+		return map[string]string{"sidecar.istio.io/inject": "false"}
+	case MeshLinkerd:
+		if enabled {
+			return map[string]string{"config.linkerd.io/proxy-await": "enabled"}
+		}
+		return map[string]string{"linkerd.io/inject": "disabled"}
+	}
+
+	return nil
+}
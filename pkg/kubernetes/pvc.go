@@ -0,0 +1,231 @@
+package kubernetes
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+
+	"github.com/jtaleric/k8s-io/pkg/timeline"
+)
+
+// StorageClassAllowsExpansion reports whether the PVCs of a storage class can be expanded
+func (c *Client) StorageClassAllowsExpansion(ctx context.Context, name string) (bool, error) {
+	class, err := c.clientset.StorageV1().StorageClasses().Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return false, fmt.Errorf("failed to get storage class %s: %w", name, classify(err))
+	}
+	return class.AllowVolumeExpansion != nil && *class.AllowVolumeExpansion, nil
+}
+
+// ListPVCs lists the PVCs matching a label selector, in every namespace for an empty namespace
+func (c *Client) ListPVCs(ctx context.Context, namespace, labelSelector string) (*corev1.PersistentVolumeClaimList, error) {
+	pvcs, err := c.clientset.CoreV1().PersistentVolumeClaims(namespace).List(ctx, metav1.ListOptions{
+		LabelSelector: labelSelector,
+	})
+	return pvcs, classify(err)
+}
+
+// ListPVs lists the persistent volumes of the cluster
+func (c *Client) ListPVs(ctx context.Context) (*corev1.PersistentVolumeList, error) {
+	pvs, err := c.clientset.CoreV1().PersistentVolumes().List(ctx, metav1.ListOptions{})
+	return pvs, classify(err)
+}
+
+// ExpandPVC requests a larger size for a bound PVC, which the CSI driver expands online
+func (c *Client) ExpandPVC(ctx context.Context, name, namespace string, size resource.Quantity) error {
+	patch, err := json.Marshal(map[string]interface{}{
+		"spec": map[string]interface{}{
+			"resources": map[string]interface{}{
+				"requests": map[string]string{"storage": size.String()},
+			},
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	if _, err := c.clientset.CoreV1().PersistentVolumeClaims(namespace).Patch(ctx, name, types.MergePatchType, patch, metav1.PatchOptions{}); err != nil {
+		return fmt.Errorf("failed to expand PVC %s: %w", name, classify(err))
+	}
+	return nil
+}
+
+// PVCExpanded reports whether a PVC has reached size, including the resize of its
+// filesystem on the node
+func PVCExpanded(pvc *corev1.PersistentVolumeClaim, size resource.Quantity) bool {
+	capacity, ok := pvc.Status.Capacity[corev1.ResourceStorage]
+	if !ok || capacity.Cmp(size) < 0 {
+		return false
+	}
+	for _, condition := range pvc.Status.Conditions {
+		if condition.Type == corev1.PersistentVolumeClaimResizing || condition.Type == corev1.PersistentVolumeClaimFileSystemResizePending {
+			return false
+		}
+	}
+	return true
+}
+
+// WaitForPVCExpansion waits until a PVC has been expanded to at least size
+func (c *Client) WaitForPVCExpansion(ctx context.Context, name, namespace string, size resource.Quantity, timeout time.Duration) error {
+	target := namespace + "/" + name
+	defer c.operations.begin("wait-pvc", target)()
+	start := time.Now()
+
+	err := classify(wait.PollImmediate(2*time.Second, timeout, func() (bool, error) {
+		pvc, err := c.clientset.CoreV1().PersistentVolumeClaims(namespace).Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			if Retryable(err) {
+				log.Printf("Warning: Transient error getting PVC %s (will retry): %v", name, err)
+				timeline.Record(timeline.EventRetry, target, "transient error: %v", err)
+				return false, nil
+			}
+			return false, fmt.Errorf("failed to get PVC: %w", classify(err))
+		}
+		return PVCExpanded(pvc, size), nil
+	}))
+	return waited("wait-pvc", target, start, err)
+}
+
+// PodVolume is a volume of a pod with the storage behind it
+type PodVolume struct {
+	Name   string // Name of the volume in the pod spec
+	Claim  string // PVC of the volume, including the claim of a generic ephemeral volume
+	Device string // Bound PV of the claim, host path, or emptyDir medium backing the volume
+}
+
+// ListPodVolumes returns the PVC, hostPath and emptyDir volumes of the pods matching a label
+// selector by pod IP. Claims that are not bound yet have no device.
+func (c *Client) ListPodVolumes(ctx context.Context, namespace, labelSelector string) (map[string][]PodVolume, error) {
+	pods, err := c.ListPods(ctx, namespace, labelSelector)
+	if err != nil {
+		return nil, err
+	}
+
+	volumes := make(map[string][]PodVolume)
+	for _, pod := range pods.Items {
+		if pod.Status.PodIP == "" {
+			continue
+		}
+		for _, v := range pod.Spec.Volumes {
+			volume := PodVolume{Name: v.Name}
+			switch {
+			case v.PersistentVolumeClaim != nil:
+				volume.Claim = v.PersistentVolumeClaim.ClaimName
+			case v.Ephemeral != nil:
+				volume.Claim = pod.Name + "-" + v.Name
+			case v.HostPath != nil:
+				volume.Device = v.HostPath.Path
+			case v.EmptyDir != nil && v.EmptyDir.Medium == corev1.StorageMediumMemory:
+				volume.Device = "emptyDir (Memory)"
+			case v.EmptyDir != nil:
+				volume.Device = "emptyDir"
+			default:
+				continue
+			}
+
+			if volume.Claim != "" {
+				pvc, err := c.clientset.CoreV1().PersistentVolumeClaims(namespace).Get(ctx, volume.Claim, metav1.GetOptions{})
+				if err != nil {
+					return nil, fmt.Errorf("failed to get PVC %s: %w", volume.Claim, classify(err))
+				}
+				volume.Device = pvc.Spec.VolumeName
+			}
+			volumes[pod.Status.PodIP] = append(volumes[pod.Status.PodIP], volume)
+		}
+	}
+	return volumes, nil
+}
+
+// VolumeAttributes are the characteristics of the PV bound to a PVC and of its storage class
+type VolumeAttributes struct {
+	Claim        string            `json:"claim"`
+	PV           string            `json:"pv,omitempty"`
+	StorageClass string            `json:"storageClass,omitempty"`
+	Provisioner  string            `json:"provisioner,omitempty"`
+	CSIDriver    string            `json:"csiDriver,omitempty"`
+	VolumeHandle string            `json:"volumeHandle,omitempty"`
+	AccessModes  []string          `json:"accessModes,omitempty"`
+	Capacity     string            `json:"capacity,omitempty"`
+	Topology     []string          `json:"topology,omitempty"`   // Node affinity of the PV as key=value terms
+	Parameters   map[string]string `json:"parameters,omitempty"` // Parameters of the storage class
+}
+
+// ListVolumeAttributes returns the attributes of the PVs bound to the PVCs matching a label
+// selector, sorted by claim. Unbound claims only have their name and storage class.
+func (c *Client) ListVolumeAttributes(ctx context.Context, namespace, labelSelector string) ([]VolumeAttributes, error) {
+	pvcs, err := c.clientset.CoreV1().PersistentVolumeClaims(namespace).List(ctx, metav1.ListOptions{LabelSelector: labelSelector})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list PVCs: %w", classify(err))
+	}
+
+	classes := make(map[string]*storagev1.StorageClass)
+	var volumes []VolumeAttributes
+	for _, pvc := range pvcs.Items {
+		attrs := VolumeAttributes{Claim: pvc.Name, PV: pvc.Spec.VolumeName}
+		if pvc.Spec.StorageClassName != nil {
+			attrs.StorageClass = *pvc.Spec.StorageClassName
+		}
+
+		if attrs.PV != "" {
+			pv, err := c.clientset.CoreV1().PersistentVolumes().Get(ctx, attrs.PV, metav1.GetOptions{})
+			if err != nil {
+				return nil, fmt.Errorf("failed to get PV %s: %w", attrs.PV, classify(err))
+			}
+			attrs.StorageClass = pv.Spec.StorageClassName
+			if pv.Spec.CSI != nil {
+				attrs.CSIDriver = pv.Spec.CSI.Driver
+				attrs.VolumeHandle = pv.Spec.CSI.VolumeHandle
+			}
+			for _, mode := range pv.Spec.AccessModes {
+				attrs.AccessModes = append(attrs.AccessModes, string(mode))
+			}
+			if capacity, ok := pv.Spec.Capacity[corev1.ResourceStorage]; ok {
+				attrs.Capacity = capacity.String()
+			}
+			attrs.Topology = nodeAffinityTerms(pv.Spec.NodeAffinity)
+		}
+
+		if attrs.StorageClass != "" {
+			class, ok := classes[attrs.StorageClass]
+			if !ok {
+				class, err = c.clientset.StorageV1().StorageClasses().Get(ctx, attrs.StorageClass, metav1.GetOptions{})
+				if err != nil {
+					return nil, fmt.Errorf("failed to get storage class %s: %w", attrs.StorageClass, classify(err))
+				}
+				classes[attrs.StorageClass] = class
+			}
+			attrs.Provisioner = class.Provisioner
+			attrs.Parameters = class.Parameters
+		}
+		volumes = append(volumes, attrs)
+	}
+
+	sort.Slice(volumes, func(i, j int) bool { return volumes[i].Claim < volumes[j].Claim })
+	return volumes, nil
+}
+
+// nodeAffinityTerms returns the required node affinity of a PV as key=value terms, with the
+// values of a term separated by |
+func nodeAffinityTerms(affinity *corev1.VolumeNodeAffinity) []string {
+	if affinity == nil || affinity.Required == nil {
+		return nil
+	}
+	var terms []string
+	for _, term := range affinity.Required.NodeSelectorTerms {
+		for _, expr := range term.MatchExpressions {
+			terms = append(terms, expr.Key+"="+strings.Join(expr.Values, "|"))
+		}
+	}
+	return terms
+}
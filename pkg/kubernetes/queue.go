@@ -0,0 +1,224 @@
+package kubernetes
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	coordinationv1 "k8s.io/api/coordination/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Queue ticket states
+const (
+	TicketQueued  = "queued"
+	TicketRunning = "running"
+)
+
+// Labels and annotations of the Leases holding the queue tickets
+const (
+	queueLabel            = "k8s-io-queue"
+	queueStateAnnotation  = "k8s-io/queue-state"
+	queueClassAnnotation  = "k8s-io/storage-classes"
+	queueOwnerAnnotation  = "k8s-io/owner"
+	queueNSAnnotation     = "k8s-io/namespace"
+	queuePosAnnotation    = "k8s-io/queue-position"
+	queueLeaseDurationSec = 120
+)
+
+// QueueLimits are the runs the queue lets run at the same time, 0 for no limit
+type QueueLimits struct {
+	MaxConcurrent      int // Runs in the whole cluster
+	MaxPerStorageClass int // Runs using the same storage class
+}
+
+// QueueTicket is the place of a run in the queue, held by a Lease that the run renews
+// while it waits and runs. A run that stops renewing it, because it crashed, leaves the
+// queue once the lease expires.
+type QueueTicket struct {
+	UUID           string
+	Owner          string
+	Namespace      string   // Namespace of the benchmark
+	StorageClasses []string // Storage classes the run uses
+	State          string
+	Position       int // Place among the queued runs, starting at 1, 0 once running
+	Created        time.Time
+	Renewed        time.Time
+}
+
+// Expired reports whether the run stopped renewing its ticket
+func (t QueueTicket) Expired(now time.Time) bool {
+	return now.Sub(t.Renewed) > queueLeaseDurationSec*time.Second
+}
+
+// QueueTicketName returns the name of the Lease holding the ticket of a run
+func QueueTicketName(uuid string) string {
+	return "k8s-io-run-" + uuid
+}
+
+// Enqueue creates the ticket of a run at the end of the queue
+func (c *Client) Enqueue(ctx context.Context, queueNamespace string, ticket QueueTicket) error {
+	classes, err := json.Marshal(ticket.StorageClasses)
+	if err != nil {
+		return err
+	}
+	now := metav1.NowMicro()
+	holder := ticket.UUID
+	duration := int32(queueLeaseDurationSec)
+
+	lease := &coordinationv1.Lease{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   QueueTicketName(ticket.UUID),
+			Labels: map[string]string{"app": queueLabel, "benchmark-uuid": ticket.UUID},
+			Annotations: map[string]string{
+				queueStateAnnotation: TicketQueued,
+				queueClassAnnotation: string(classes),
+				queueOwnerAnnotation: ticket.Owner,
+				queueNSAnnotation:    ticket.Namespace,
+			},
+		},
+		Spec: coordinationv1.LeaseSpec{
+			HolderIdentity:       &holder,
+			LeaseDurationSeconds: &duration,
+			AcquireTime:          &now,
+			RenewTime:            &now,
+		},
+	}
+	lease.Labels = mergeLabels(lease.Labels, c.labels)
+	lease.Annotations = mergeLabels(lease.Annotations, c.annotations)
+	if _, err := c.clientset.CoordinationV1().Leases(queueNamespace).Create(ctx, lease, metav1.CreateOptions{}); err != nil {
+		if apierrors.IsAlreadyExists(err) {
+			return fmt.Errorf("run %s is already in the queue of namespace %s", ticket.UUID, queueNamespace)
+		}
+		return fmt.Errorf("failed to create the queue ticket of run %s: %w", ticket.UUID, err)
+	}
+	return nil
+}
+
+// RenewTicket keeps the ticket of a run from expiring and records its state and position
+func (c *Client) RenewTicket(ctx context.Context, queueNamespace, uuid, state string, position int) error {
+	leases := c.clientset.CoordinationV1().Leases(queueNamespace)
+	lease, err := leases.Get(ctx, QueueTicketName(uuid), metav1.GetOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to get the queue ticket of run %s: %w", uuid, err)
+	}
+
+	now := metav1.NowMicro()
+	lease.Spec.RenewTime = &now
+	if lease.Annotations == nil {
+		lease.Annotations = make(map[string]string)
+	}
+	lease.Annotations[queueStateAnnotation] = state
+	lease.Annotations[queuePosAnnotation] = strconv.Itoa(position)
+
+	if _, err := leases.Update(ctx, lease, metav1.UpdateOptions{}); err != nil {
+		return fmt.Errorf("failed to renew the queue ticket of run %s: %w", uuid, err)
+	}
+	return nil
+}
+
+// Dequeue removes the ticket of a run, letting the next queued run start
+func (c *Client) Dequeue(ctx context.Context, queueNamespace, uuid string) error {
+	err := c.clientset.CoordinationV1().Leases(queueNamespace).Delete(ctx, QueueTicketName(uuid), metav1.DeleteOptions{})
+	if err != nil && !apierrors.IsNotFound(err) {
+		return fmt.Errorf("failed to delete the queue ticket of run %s: %w", uuid, err)
+	}
+	return nil
+}
+
+// ListQueue returns the tickets of the queue in queue order: by creation, then by name. An
+// empty queue namespace returns the tickets of every queue.
+func (c *Client) ListQueue(ctx context.Context, queueNamespace string) ([]QueueTicket, error) {
+	leases, err := c.clientset.CoordinationV1().Leases(queueNamespace).List(ctx, metav1.ListOptions{LabelSelector: "app=" + queueLabel})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list the queue tickets: %w", err)
+	}
+
+	sort.Slice(leases.Items, func(i, j int) bool {
+		a, b := leases.Items[i], leases.Items[j]
+		if !a.CreationTimestamp.Equal(&b.CreationTimestamp) {
+			return a.CreationTimestamp.Before(&b.CreationTimestamp)
+		}
+		return a.Name < b.Name
+	})
+
+	tickets := make([]QueueTicket, 0, len(leases.Items))
+	for _, lease := range leases.Items {
+		t := QueueTicket{
+			UUID:      lease.Labels["benchmark-uuid"],
+			Owner:     lease.Annotations[queueOwnerAnnotation],
+			Namespace: lease.Annotations[queueNSAnnotation],
+			State:     lease.Annotations[queueStateAnnotation],
+			Created:   lease.CreationTimestamp.Time,
+			Renewed:   lease.CreationTimestamp.Time,
+		}
+		if lease.Spec.RenewTime != nil {
+			t.Renewed = lease.Spec.RenewTime.Time
+		}
+		t.Position, _ = strconv.Atoi(lease.Annotations[queuePosAnnotation])
+		if classes := lease.Annotations[queueClassAnnotation]; classes != "" {
+			_ = json.Unmarshal([]byte(classes), &t.StorageClasses)
+		}
+		tickets = append(tickets, t)
+	}
+	return tickets, nil
+}
+
+// Admit decides which tickets may run, in queue order. Running tickets keep their slots, and
+// every queued ticket is admitted when the global and per storage class limits leave room
+// for it, so a run waiting for a busy storage class does not hold up runs on other classes.
+// Every run computes the same decision from the same tickets, so no controller is needed.
+// The queued tickets that are not admitted get their position.
+func Admit(tickets []QueueTicket, limits QueueLimits) []QueueTicket {
+	running := 0
+	perClass := make(map[string]int)
+	fits := func(t QueueTicket) bool {
+		if limits.MaxConcurrent > 0 && running >= limits.MaxConcurrent {
+			return false
+		}
+		if limits.MaxPerStorageClass > 0 {
+			for _, class := range t.StorageClasses {
+				if perClass[class] >= limits.MaxPerStorageClass {
+					return false
+				}
+			}
+		}
+		return true
+	}
+	take := func(t QueueTicket) {
+		running++
+		for _, class := range t.StorageClasses {
+			perClass[class]++
+		}
+	}
+
+	admitted := make([]QueueTicket, len(tickets))
+	copy(admitted, tickets)
+	for _, t := range admitted {
+		if t.State == TicketRunning {
+			take(t)
+		}
+	}
+
+	position := 0
+	for i, t := range admitted {
+		if t.State == TicketRunning {
+			admitted[i].Position = 0
+			continue
+		}
+		if fits(t) {
+			take(t)
+			admitted[i].State = TicketRunning
+			admitted[i].Position = 0
+			continue
+		}
+		position++
+		admitted[i].State = TicketQueued
+		admitted[i].Position = position
+	}
+	return admitted
+}
@@ -0,0 +1,34 @@
+package kubernetes
+
+import (
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/yaml"
+)
+
+// RenderManifest returns a manifest as ApplyManifest would apply it, with the namespace and
+// the labels and annotations of the client, for manifests applied by other tools such as
+// GitOps controllers
+func (c *Client) RenderManifest(manifestYAML string, namespace string) (string, error) {
+	data, err := yaml.YAMLToJSON([]byte(manifestYAML))
+	if err != nil {
+		return "", fmt.Errorf("failed to decode manifest: %w", err)
+	}
+	obj := &unstructured.Unstructured{}
+	if err := obj.UnmarshalJSON(data); err != nil {
+		return "", fmt.Errorf("failed to decode manifest: %w", err)
+	}
+
+	if obj.GetNamespace() == "" && namespace != "" {
+		obj.SetNamespace(namespace)
+	}
+	addLabels(obj, c.labels)
+	addAnnotations(obj, c.annotations)
+
+	rendered, err := yaml.Marshal(obj.Object)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode %s/%s: %w", obj.GetKind(), obj.GetName(), err)
+	}
+	return string(rendered), nil
+}
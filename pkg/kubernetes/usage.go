@@ -0,0 +1,87 @@
+package kubernetes
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// ResourceUsage is the CPU and memory usage of a pod or node reported by metrics-server
+type ResourceUsage struct {
+	Name      string
+	Namespace string // Empty for nodes
+	Timestamp time.Time
+	CPU       float64 // Cores
+	Memory    float64 // Bytes
+}
+
+// ListPodUsage returns the usage of the pods matching the label selector, summed over their containers
+func (c *Client) ListPodUsage(ctx context.Context, namespace, labelSelector string) ([]ResourceUsage, error) {
+	gvr := schema.GroupVersionResource{Group: "metrics.k8s.io", Version: "v1beta1", Resource: "pods"}
+	list, err := c.dynamicClient.Resource(gvr).Namespace(namespace).List(ctx, metav1.ListOptions{LabelSelector: labelSelector})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list pod metrics: %w", err)
+	}
+
+	var usage []ResourceUsage
+	for _, item := range list.Items {
+		u := ResourceUsage{Name: item.GetName(), Namespace: item.GetNamespace(), Timestamp: usageTimestamp(item)}
+		containers, _, _ := unstructured.NestedSlice(item.Object, "containers")
+		for _, container := range containers {
+			if m, ok := container.(map[string]interface{}); ok {
+				cpu, memory := usageOf(m)
+				u.CPU += cpu
+				u.Memory += memory
+			}
+		}
+		usage = append(usage, u)
+	}
+	return usage, nil
+}
+
+// ListNodeUsage returns the usage of every node
+func (c *Client) ListNodeUsage(ctx context.Context) ([]ResourceUsage, error) {
+	gvr := schema.GroupVersionResource{Group: "metrics.k8s.io", Version: "v1beta1", Resource: "nodes"}
+	list, err := c.dynamicClient.Resource(gvr).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list node metrics: %w", err)
+	}
+
+	var usage []ResourceUsage
+	for _, item := range list.Items {
+		cpu, memory := usageOf(item.Object)
+		usage = append(usage, ResourceUsage{Name: item.GetName(), Timestamp: usageTimestamp(item), CPU: cpu, Memory: memory})
+	}
+	return usage, nil
+}
+
+// usageOf parses the usage field of a metrics object or container
+func usageOf(obj map[string]interface{}) (float64, float64) {
+	var cpu, memory float64
+	if value, found, _ := unstructured.NestedString(obj, "usage", "cpu"); found {
+		if q, err := resource.ParseQuantity(value); err == nil {
+			cpu = q.AsApproximateFloat64()
+		}
+	}
+	if value, found, _ := unstructured.NestedString(obj, "usage", "memory"); found {
+		if q, err := resource.ParseQuantity(value); err == nil {
+			memory = q.AsApproximateFloat64()
+		}
+	}
+	return cpu, memory
+}
+
+// usageTimestamp returns the time a metrics object was sampled, or now if it is missing
+func usageTimestamp(item unstructured.Unstructured) time.Time {
+	if value, found, _ := unstructured.NestedString(item.Object, "timestamp"); found {
+		if t, err := time.Parse(time.RFC3339, value); err == nil {
+			return t
+		}
+	}
+	return time.Now()
+}
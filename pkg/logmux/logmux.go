@@ -0,0 +1,98 @@
+package logmux
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/jtaleric/k8s-io/pkg/kubernetes"
+)
+
+// pollInterval is how often new pods and restarted containers are picked up
+const pollInterval = 5 * time.Second
+
+// Multiplexer follows the logs of every pod matching a selector concurrently and writes
+// their lines to one writer, prefixed with the pod name
+type Multiplexer struct {
+	k8sClient *kubernetes.Client
+	namespace string
+	selector  string
+
+	mu  sync.Mutex // Serializes the lines of the streams
+	out io.Writer
+}
+
+// New creates a multiplexer for the pods matching selector in namespace
+func New(k8sClient *kubernetes.Client, namespace, selector string, out io.Writer) *Multiplexer {
+	return &Multiplexer{k8sClient: k8sClient, namespace: namespace, selector: selector, out: out}
+}
+
+// Run follows the containers of the matching pods as they start, and restarted containers
+// again, until ctx is done. It returns once every stream has ended.
+func (m *Multiplexer) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	// Keyed by pod, container and restart count, so a restarted container is followed again
+	followed := make(map[string]bool)
+	for ctx.Err() == nil {
+		pods, err := m.k8sClient.ListPods(ctx, m.namespace, m.selector)
+		if err != nil && ctx.Err() == nil {
+			log.Printf("Warning: failed to list pods to follow: %v", err)
+		}
+		if pods != nil {
+			for _, pod := range pods.Items {
+				for _, cs := range pod.Status.ContainerStatuses {
+					key := fmt.Sprintf("%s/%s/%d", pod.Name, cs.Name, cs.RestartCount)
+					if followed[key] || (cs.State.Running == nil && cs.State.Terminated == nil) {
+						continue
+					}
+					followed[key] = true
+
+					wg.Add(1)
+					go func(pod corev1.Pod, container string) {
+						defer wg.Done()
+						m.follow(ctx, pod, container)
+					}(pod, cs.Name)
+				}
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+		case <-time.After(pollInterval):
+		}
+	}
+}
+
+// follow writes the log lines of a container until it exits or ctx is done
+func (m *Multiplexer) follow(ctx context.Context, pod corev1.Pod, container string) {
+	stream, err := m.k8sClient.GetPodLogsStream(ctx, m.namespace, pod.Name, container, true)
+	if err != nil {
+		if ctx.Err() == nil {
+			log.Printf("Warning: failed to follow %s/%s: %v", pod.Name, container, err)
+		}
+		return
+	}
+	defer stream.Close()
+
+	// The container is only named for pods with sidecars
+	prefix := pod.Name
+	if len(pod.Spec.Containers) > 1 {
+		prefix = pod.Name + "/" + container
+	}
+
+	scanner := bufio.NewScanner(stream)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		m.mu.Lock()
+		fmt.Fprintf(m.out, "[%s] %s\n", prefix, scanner.Text())
+		m.mu.Unlock()
+	}
+}
@@ -0,0 +1,126 @@
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/jtaleric/k8s-io/pkg/config"
+	"github.com/jtaleric/k8s-io/pkg/kubernetes"
+	"github.com/jtaleric/k8s-io/pkg/sinks"
+	"github.com/jtaleric/k8s-io/pkg/timeline"
+)
+
+// Capturer captures cluster metrics over a benchmark run. Start is called before the first
+// phase, Stop after the last one and Collect then returns the captured documents.
+type Capturer interface {
+	Start(ctx context.Context) error
+	Stop(ctx context.Context) error
+	Collect(ctx context.Context, phases []timeline.Phase) ([]Document, error)
+}
+
+// Factory creates the capturer of a run
+type Factory func(cfg *config.Config, k8sClient *kubernetes.Client) (Capturer, error)
+
+// factories are the capturers selectable with metrics_capture.capturer
+var factories = map[string]Factory{
+	"none":           func(*config.Config, *kubernetes.Client) (Capturer, error) { return noop{}, nil },
+	"prometheus":     newPrometheusCapturer,
+	"metrics-server": newMetricsServerCapturer,
+}
+
+// Register makes a capturer selectable by name, so other metrics sources can be added
+// without changing the workloads
+func Register(name string, factory Factory) {
+	factories[name] = factory
+}
+
+// New creates the capturer selected in the configuration
+func New(cfg *config.Config, k8sClient *kubernetes.Client) (Capturer, error) {
+	factory, ok := factories[cfg.MetricsCapture.Capturer]
+	if !ok {
+		names := make([]string, 0, len(factories))
+		for name := range factories {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return nil, fmt.Errorf("unknown metrics capturer %q, expected one of: %s", cfg.MetricsCapture.Capturer, strings.Join(names, ", "))
+	}
+	return factory(cfg, k8sClient)
+}
+
+// Finish stops the capturer and publishes its documents: they are written to
+// <artifacts>/metrics/<metricName>.json and sent to the sinks of the run
+func Finish(ctx context.Context, c Capturer, cfg *config.Config, phases []timeline.Phase) error {
+	if err := c.Stop(ctx); err != nil {
+		return err
+	}
+	if _, ok := c.(noop); ok || len(phases) == 0 {
+		return nil
+	}
+
+	docs, err := c.Collect(ctx, phases)
+	if err != nil {
+		return err
+	}
+
+	dir := filepath.Join(cfg.RunArtifactsDir(), "metrics")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create metrics directory: %w", err)
+	}
+
+	// The run clock and metadata go with the tags, so every document carries the UTC run start
+	metadata := make(map[string]string)
+	for k, v := range cfg.Clock.Metadata() {
+		metadata[k] = v
+	}
+	for k, v := range cfg.Metadata {
+		metadata[k] = v
+	}
+	for k, v := range cfg.Tags {
+		metadata[k] = v
+	}
+
+	byName := make(map[string][]Document)
+	for i := range docs {
+		docs[i].Timestamp = docs[i].Timestamp.UTC()
+		docs[i].UUID = cfg.UUID
+		docs[i].JobName = cfg.Workload.Name
+		docs[i].Metadata = metadata
+		byName[docs[i].MetricName] = append(byName[docs[i].MetricName], docs[i])
+	}
+	for name, metricDocs := range byName {
+		if err := write(filepath.Join(dir, name+".json"), metricDocs); err != nil {
+			return err
+		}
+	}
+	if cfg.WritesParquet() {
+		if err := writeParquet(filepath.Join(dir, "metrics.parquet"), docs); err != nil {
+			return err
+		}
+	}
+	log.Printf("Captured %d documents for %d metrics in %s", len(docs), len(byName), dir)
+
+	bulk := make([]interface{}, len(docs))
+	for i := range docs {
+		bulk[i] = docs[i]
+	}
+	if err := sinks.Publish(ctx, cfg, cfg.MetricsCapture.IndexName, bulk); err != nil {
+		return fmt.Errorf("failed to publish metrics: %w", err)
+	}
+
+	return nil
+}
+
+// noop is the capturer of runs that capture no metrics
+type noop struct{}
+
+func (noop) Start(context.Context) error { return nil }
+func (noop) Stop(context.Context) error  { return nil }
+func (noop) Collect(context.Context, []timeline.Phase) ([]Document, error) {
+	return nil, nil
+}
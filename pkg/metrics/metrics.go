@@ -0,0 +1,101 @@
+package metrics
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/jtaleric/k8s-io/pkg/parquet"
+	"github.com/jtaleric/k8s-io/pkg/prometheus"
+)
+
+// Document is a captured metric value in the kube-burner document format, so dashboards
+// built on kube-burner indices can display k8s-io runs
+type Document struct {
+	Timestamp  time.Time         `json:"timestamp"`
+	Labels     map[string]string `json:"labels"`
+	Value      float64           `json:"value"`
+	UUID       string            `json:"uuid"`
+	Query      string            `json:"query"`
+	MetricName string            `json:"metricName"`
+	JobName    string            `json:"jobName"`
+	Metadata   map[string]string `json:"metadata,omitempty"`
+}
+
+// query runs a profile query as a range query over the window, or as an instant query
+// at its end
+func query(ctx context.Context, prom *prometheus.Client, m Metric, start, end time.Time, step time.Duration) ([]Document, error) {
+	q, err := m.render(end.Sub(start))
+	if err != nil {
+		return nil, err
+	}
+
+	var docs []Document
+	if m.Instant {
+		samples, err := prom.QueryAt(ctx, q, end)
+		if err != nil {
+			return nil, err
+		}
+		for _, s := range samples {
+			docs = append(docs, Document{Timestamp: end, Labels: s.Labels, Value: s.Value, Query: q, MetricName: m.MetricName})
+		}
+		return docs, nil
+	}
+
+	series, err := prom.QueryRange(ctx, q, start, end, step)
+	if err != nil {
+		return nil, err
+	}
+	for _, s := range series {
+		for _, p := range s.Points {
+			docs = append(docs, Document{Timestamp: p.Time, Labels: s.Labels, Value: p.Value, Query: q, MetricName: m.MetricName})
+		}
+	}
+	return docs, nil
+}
+
+// write stores the documents of a metric as a JSON array
+func write(filename string, docs []Document) error {
+	if docs == nil {
+		docs = []Document{}
+	}
+	data, err := json.MarshalIndent(docs, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode metric documents: %w", err)
+	}
+	if err := os.WriteFile(filename, data, 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", filename, err)
+	}
+	return nil
+}
+
+// writeParquet writes the documents of every metric to one Parquet table, with the labels
+// as a JSON object
+func writeParquet(filename string, docs []Document) error {
+	timestamps := make([]time.Time, len(docs))
+	names := make([]string, len(docs))
+	values := make([]float64, len(docs))
+	labels := make([]string, len(docs))
+	uuids := make([]string, len(docs))
+	for i, doc := range docs {
+		timestamps[i] = doc.Timestamp
+		names[i] = doc.MetricName
+		values[i] = doc.Value
+		uuids[i] = doc.UUID
+		data, err := json.Marshal(doc.Labels)
+		if err != nil {
+			return fmt.Errorf("failed to encode metric labels: %w", err)
+		}
+		labels[i] = string(data)
+	}
+
+	var table parquet.Table
+	table.Timestamp("timestamp", timestamps)
+	table.String("metric", names)
+	table.Float64("value", values)
+	table.String("labels", labels)
+	table.String("uuid", uuids)
+	return table.WriteFile(filename)
+}
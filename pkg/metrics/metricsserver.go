@@ -0,0 +1,115 @@
+package metrics
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/jtaleric/k8s-io/pkg/config"
+	"github.com/jtaleric/k8s-io/pkg/kubernetes"
+	"github.com/jtaleric/k8s-io/pkg/timeline"
+)
+
+// metricsServerCapturer polls metrics-server for the usage of the benchmark pods and the
+// nodes while the run is in progress, for clusters without a Prometheus
+type metricsServerCapturer struct {
+	k8sClient *kubernetes.Client
+	namespace string
+	selector  string
+	interval  time.Duration
+
+	mu     sync.Mutex
+	docs   []Document
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// newMetricsServerCapturer creates a capturer for the pods of the run
+func newMetricsServerCapturer(cfg *config.Config, k8sClient *kubernetes.Client) (Capturer, error) {
+	interval, err := time.ParseDuration(cfg.MetricsCapture.Interval)
+	if err != nil {
+		return nil, err
+	}
+	return &metricsServerCapturer{
+		k8sClient: k8sClient,
+		namespace: cfg.Namespace,
+		selector:  "benchmark-uuid=" + cfg.UUID,
+		interval:  interval,
+	}, nil
+}
+
+// Start polls metrics-server every interval until Stop
+func (c *metricsServerCapturer) Start(ctx context.Context) error {
+	ctx, c.cancel = context.WithCancel(ctx)
+	c.done = make(chan struct{})
+
+	go func() {
+		defer close(c.done)
+		ticker := time.NewTicker(c.interval)
+		defer ticker.Stop()
+
+		for {
+			c.poll(ctx)
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+	return nil
+}
+
+// Stop ends the polling
+func (c *metricsServerCapturer) Stop(ctx context.Context) error {
+	if c.cancel == nil {
+		return nil
+	}
+	c.cancel()
+	<-c.done
+	return nil
+}
+
+// Collect returns the samples taken since Start
+func (c *metricsServerCapturer) Collect(ctx context.Context, phases []timeline.Phase) ([]Document, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.docs, nil
+}
+
+// poll records the current usage of the benchmark pods and the nodes
+func (c *metricsServerCapturer) poll(ctx context.Context) {
+	pods, err := c.k8sClient.ListPodUsage(ctx, c.namespace, c.selector)
+	if err != nil {
+		if ctx.Err() == nil {
+			log.Printf("Warning: %v", err)
+		}
+		return
+	}
+	nodes, err := c.k8sClient.ListNodeUsage(ctx)
+	if err != nil {
+		if ctx.Err() == nil {
+			log.Printf("Warning: %v", err)
+		}
+		return
+	}
+
+	var docs []Document
+	for _, u := range pods {
+		labels := map[string]string{"pod": u.Name, "namespace": u.Namespace}
+		docs = append(docs,
+			Document{Timestamp: u.Timestamp, Labels: labels, Value: u.CPU, Query: "metrics.k8s.io/v1beta1 pods", MetricName: "podCPU"},
+			Document{Timestamp: u.Timestamp, Labels: labels, Value: u.Memory, Query: "metrics.k8s.io/v1beta1 pods", MetricName: "podMemory"})
+	}
+	for _, u := range nodes {
+		labels := map[string]string{"node": u.Name}
+		docs = append(docs,
+			Document{Timestamp: u.Timestamp, Labels: labels, Value: u.CPU, Query: "metrics.k8s.io/v1beta1 nodes", MetricName: "nodeCPU"},
+			Document{Timestamp: u.Timestamp, Labels: labels, Value: u.Memory, Query: "metrics.k8s.io/v1beta1 nodes", MetricName: "nodeMemory"})
+	}
+
+	c.mu.Lock()
+	c.docs = append(c.docs, docs...)
+	c.mu.Unlock()
+}
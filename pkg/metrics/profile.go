@@ -0,0 +1,68 @@
+package metrics
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"text/template"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Metric is a query of a kube-burner metrics profile
+type Metric struct {
+	Query      string `yaml:"query"`
+	MetricName string `yaml:"metricName"`
+	Instant    bool   `yaml:"instant,omitempty"` // Evaluate once at the end of the run instead of over the whole run
+}
+
+// LoadProfiles reads kube-burner metrics profile files. Both the plain list format and
+// the older format with the list under a metrics key are accepted.
+func LoadProfiles(paths []string) ([]Metric, error) {
+	var metrics []Metric
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read metrics profile %s: %w", path, err)
+		}
+
+		var profile []Metric
+		if err := yaml.Unmarshal(data, &profile); err != nil {
+			var wrapped struct {
+				Metrics []Metric `yaml:"metrics"`
+			}
+			if err := yaml.Unmarshal(data, &wrapped); err != nil {
+				return nil, fmt.Errorf("failed to parse metrics profile %s: %w", path, err)
+			}
+			profile = wrapped.Metrics
+		}
+
+		for i, m := range profile {
+			if m.Query == "" || m.MetricName == "" {
+				return nil, fmt.Errorf("metrics profile %s entry %d must have a query and a metricName", path, i+1)
+			}
+			if _, err := template.New(m.MetricName).Parse(m.Query); err != nil {
+				return nil, fmt.Errorf("metrics profile %s query %s: %w", path, m.MetricName, err)
+			}
+		}
+		metrics = append(metrics, profile...)
+	}
+
+	return metrics, nil
+}
+
+// render expands the kube-burner template variables of a query, such as {{ .elapsed }}
+func (m Metric) render(elapsed time.Duration) (string, error) {
+	tmpl, err := template.New(m.MetricName).Parse(m.Query)
+	if err != nil {
+		return "", err
+	}
+
+	var buf bytes.Buffer
+	data := map[string]string{"elapsed": fmt.Sprintf("%ds", int(elapsed.Seconds()))}
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
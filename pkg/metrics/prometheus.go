@@ -0,0 +1,84 @@
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/jtaleric/k8s-io/pkg/config"
+	"github.com/jtaleric/k8s-io/pkg/kubernetes"
+	"github.com/jtaleric/k8s-io/pkg/prometheus"
+	"github.com/jtaleric/k8s-io/pkg/timeline"
+)
+
+// prometheusCapturer runs the queries of kube-burner metrics profiles over the window
+// spanned by the phases once the run is over
+type prometheusCapturer struct {
+	k8sClient *kubernetes.Client
+	config    *config.PrometheusConfig
+	profile   []Metric
+	step      time.Duration
+}
+
+// newPrometheusCapturer loads the configured metrics profiles
+func newPrometheusCapturer(cfg *config.Config, k8sClient *kubernetes.Client) (Capturer, error) {
+	if cfg.Prometheus == nil || len(cfg.Prometheus.MetricsProfiles) == 0 {
+		return nil, fmt.Errorf("the prometheus capturer needs prometheus metrics_profiles")
+	}
+
+	profile, err := LoadProfiles(cfg.Prometheus.MetricsProfiles)
+	if err != nil {
+		return nil, err
+	}
+
+	step, err := time.ParseDuration(cfg.Prometheus.Step)
+	if err != nil {
+		return nil, fmt.Errorf("invalid prometheus step: %w", err)
+	}
+
+	return &prometheusCapturer{k8sClient: k8sClient, config: cfg.Prometheus, profile: profile, step: step}, nil
+}
+
+// Start does nothing, Prometheus keeps the history queried by Collect
+func (c *prometheusCapturer) Start(ctx context.Context) error {
+	return nil
+}
+
+// Stop does nothing, Prometheus keeps the history queried by Collect
+func (c *prometheusCapturer) Stop(ctx context.Context) error {
+	return nil
+}
+
+// Collect runs every profile query over the window of the phases
+func (c *prometheusCapturer) Collect(ctx context.Context, phases []timeline.Phase) ([]Document, error) {
+	promInfo, err := c.k8sClient.DiscoverPrometheusWithConfig(ctx, c.config)
+	if err != nil {
+		return nil, err
+	}
+	if !promInfo.Found {
+		return nil, fmt.Errorf("Prometheus not found")
+	}
+	prom := prometheus.NewClient(promInfo.URL, promInfo.Token)
+
+	start, end := phases[0].Start, phases[len(phases)-1].End
+	for _, p := range phases {
+		if p.Start.Before(start) {
+			start = p.Start
+		}
+		if p.End.After(end) {
+			end = p.End
+		}
+	}
+
+	var all []Document
+	for _, m := range c.profile {
+		docs, err := query(ctx, prom, m, start, end, c.step)
+		if err != nil {
+			log.Printf("Warning: failed to capture metric %s: %v", m.MetricName, err)
+			continue
+		}
+		all = append(all, docs...)
+	}
+	return all, nil
+}
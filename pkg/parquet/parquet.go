@@ -0,0 +1,215 @@
+// Package parquet writes flat tables as Apache Parquet files that pandas, Polars, DuckDB and
+// Spark load directly. It supports what the results need and no more: required columns of
+// 64-bit integers, doubles, UTF-8 strings and millisecond UTC timestamps, in a single row
+// group of uncompressed, plain encoded pages.
+package parquet
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"os"
+	"time"
+)
+
+// magic starts and ends every Parquet file
+const magic = "PAR1"
+
+// Physical types, converted types and encodings of the Parquet format
+const (
+	typeInt64     = 2
+	typeDouble    = 5
+	typeByteArray = 6
+
+	convertedUTF8            = 0
+	convertedTimestampMillis = 9
+
+	encodingPlain = 0
+	encodingRLE   = 3
+
+	repetitionRequired = 0
+	pageTypeData       = 0
+	codecUncompressed  = 0
+)
+
+// column is a column of a table with its values encoded
+type column struct {
+	name      string
+	physical  int32
+	converted int32 // -1 for none
+	rows      int
+	data      bytes.Buffer
+}
+
+// Table is a table of columns with the same number of rows, written as one Parquet file
+type Table struct {
+	columns []*column
+}
+
+// add adds a column
+func (t *Table) add(name string, physical, converted int32, rows int) *column {
+	c := &column{name: name, physical: physical, converted: converted, rows: rows}
+	t.columns = append(t.columns, c)
+	return c
+}
+
+// Int64 adds a column of 64-bit integers
+func (t *Table) Int64(name string, values []int64) {
+	c := t.add(name, typeInt64, -1, len(values))
+	for _, v := range values {
+		binary.Write(&c.data, binary.LittleEndian, v)
+	}
+}
+
+// Float64 adds a column of doubles
+func (t *Table) Float64(name string, values []float64) {
+	c := t.add(name, typeDouble, -1, len(values))
+	for _, v := range values {
+		binary.Write(&c.data, binary.LittleEndian, math.Float64bits(v))
+	}
+}
+
+// String adds a column of UTF-8 strings
+func (t *Table) String(name string, values []string) {
+	c := t.add(name, typeByteArray, convertedUTF8, len(values))
+	for _, v := range values {
+		binary.Write(&c.data, binary.LittleEndian, uint32(len(v)))
+		c.data.WriteString(v)
+	}
+}
+
+// Timestamp adds a column of UTC timestamps with millisecond precision
+func (t *Table) Timestamp(name string, values []time.Time) {
+	c := t.add(name, typeInt64, convertedTimestampMillis, len(values))
+	for _, v := range values {
+		binary.Write(&c.data, binary.LittleEndian, v.UnixMilli())
+	}
+}
+
+// rows returns the number of rows of the table, or an error when its columns differ
+func (t *Table) rows() (int, error) {
+	if len(t.columns) == 0 {
+		return 0, fmt.Errorf("table has no columns")
+	}
+	rows := t.columns[0].rows
+	for _, c := range t.columns[1:] {
+		if c.rows != rows {
+			return 0, fmt.Errorf("column %s has %d rows, column %s has %d", c.name, c.rows, t.columns[0].name, rows)
+		}
+	}
+	return rows, nil
+}
+
+// WriteFile writes the table to a Parquet file
+func (t *Table) WriteFile(filename string) error {
+	data, err := t.Marshal()
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(filename, data, 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", filename, err)
+	}
+	return nil
+}
+
+// Marshal returns the table as a Parquet file: the magic, a data page per column, the file
+// metadata in the Thrift compact protocol, its length and the magic again
+func (t *Table) Marshal() ([]byte, error) {
+	rows, err := t.rows()
+	if err != nil {
+		return nil, err
+	}
+
+	var file bytes.Buffer
+	file.WriteString(magic)
+
+	offsets := make([]int64, len(t.columns))
+	sizes := make([]int64, len(t.columns))
+	for i, c := range t.columns {
+		header := pageHeader(rows, c.data.Len())
+		offsets[i] = int64(file.Len())
+		sizes[i] = int64(len(header) + c.data.Len())
+		file.Write(header)
+		file.Write(c.data.Bytes())
+	}
+
+	footer := t.fileMetaData(rows, offsets, sizes)
+	file.Write(footer)
+	binary.Write(&file, binary.LittleEndian, uint32(len(footer)))
+	file.WriteString(magic)
+	return file.Bytes(), nil
+}
+
+// pageHeader returns the header of a plain encoded, uncompressed data page. Required columns
+// have no repetition or definition levels, so the page holds only the values.
+func pageHeader(rows, size int) []byte {
+	var w compactWriter
+	w.i32(1, pageTypeData)
+	w.i32(2, int32(size))
+	w.i32(3, int32(size))
+	w.structBegin(5)
+	w.i32(1, int32(rows))
+	w.i32(2, encodingPlain)
+	w.i32(3, encodingRLE)
+	w.i32(4, encodingRLE)
+	w.structEnd()
+	w.stop()
+	return w.buf.Bytes()
+}
+
+// fileMetaData returns the footer of the file with the schema and the single row group
+func (t *Table) fileMetaData(rows int, offsets, sizes []int64) []byte {
+	var w compactWriter
+	w.i32(1, 1) // Format version
+
+	w.listBegin(2, ctStruct, len(t.columns)+1)
+	w.elemBegin()
+	w.binary(4, "schema")
+	w.i32(5, int32(len(t.columns)))
+	w.structEnd()
+	for _, c := range t.columns {
+		w.elemBegin()
+		w.i32(1, c.physical)
+		w.i32(3, repetitionRequired)
+		w.binary(4, c.name)
+		if c.converted >= 0 {
+			w.i32(6, c.converted)
+		}
+		w.structEnd()
+	}
+
+	w.i64(3, int64(rows))
+
+	var total int64
+	for _, size := range sizes {
+		total += size
+	}
+	w.listBegin(4, ctStruct, 1)
+	w.elemBegin()
+	w.listBegin(1, ctStruct, len(t.columns))
+	for i, c := range t.columns {
+		w.elemBegin()
+		w.i64(2, offsets[i])
+		w.structBegin(3)
+		w.i32(1, c.physical)
+		w.listBegin(2, ctI32, 1)
+		w.varint(encodingPlain)
+		w.listBegin(3, ctBinary, 1)
+		w.bytes(c.name)
+		w.i32(4, codecUncompressed)
+		w.i64(5, int64(rows))
+		w.i64(6, sizes[i])
+		w.i64(7, sizes[i])
+		w.i64(9, offsets[i])
+		w.structEnd()
+		w.structEnd()
+	}
+	w.i64(2, total)
+	w.i64(3, int64(rows))
+	w.structEnd()
+
+	w.binary(6, "k8s-io")
+	w.stop()
+	return w.buf.Bytes()
+}
@@ -0,0 +1,124 @@
+package parquet_test
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/jtaleric/k8s-io/pkg/parquet"
+	"github.com/jtaleric/k8s-io/pkg/parquet/parquettest"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files")
+
+// testTable returns a table with a column of every type, including empty and multi-byte
+// strings, negative and extreme integers and timestamps before the epoch
+func testTable() *parquet.Table {
+	var t parquet.Table
+	t.String("job", []string{"randread", "", "rand_write_ünïcode"})
+	t.Int64("numjobs", []int64{1, -42, math.MaxInt64})
+	t.Float64("iops", []float64{1234.5, 0, -0.25})
+	t.Timestamp("run_start", []time.Time{
+		time.Date(2024, 3, 1, 12, 30, 45, 123e6, time.UTC),
+		time.Unix(0, 0).UTC(),
+		time.Date(1969, 12, 31, 23, 59, 59, 0, time.UTC),
+	})
+	return &t
+}
+
+func TestMarshalRoundTrip(t *testing.T) {
+	data, err := testTable().Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := parquettest.Read(data)
+	if err != nil {
+		t.Fatalf("failed to read the file back: %v", err)
+	}
+
+	if f.Rows != 3 || f.CreatedBy != "k8s-io" {
+		t.Errorf("file has %d rows created by %q, want 3 by k8s-io", f.Rows, f.CreatedBy)
+	}
+	want := []parquettest.Column{
+		{Name: "job", Type: 6, Converted: 0, Values: []interface{}{"randread", "", "rand_write_ünïcode"}},
+		{Name: "numjobs", Type: 2, Converted: -1, Values: []interface{}{int64(1), int64(-42), int64(math.MaxInt64)}},
+		{Name: "iops", Type: 5, Converted: -1, Values: []interface{}{1234.5, 0.0, -0.25}},
+		{Name: "run_start", Type: 2, Converted: 9, Values: []interface{}{
+			time.Date(2024, 3, 1, 12, 30, 45, 123e6, time.UTC),
+			time.Unix(0, 0).UTC(),
+			time.Date(1969, 12, 31, 23, 59, 59, 0, time.UTC),
+		}},
+	}
+	if !reflect.DeepEqual(f.Columns, want) {
+		t.Errorf("read back %+v, want %+v", f.Columns, want)
+	}
+}
+
+// TestMarshalManyColumns covers the long list header of the schema, used from 15 elements
+func TestMarshalManyColumns(t *testing.T) {
+	var table parquet.Table
+	for i := 0; i < 20; i++ {
+		table.Int64(fmt.Sprintf("c%d", i), []int64{int64(i), int64(i * 1000)})
+	}
+	data, err := table.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := parquettest.Read(data)
+	if err != nil {
+		t.Fatalf("failed to read the file back: %v", err)
+	}
+	if len(f.Columns) != 20 {
+		t.Fatalf("read %d columns, want 20", len(f.Columns))
+	}
+	for i, c := range f.Columns {
+		if want := []interface{}{int64(i), int64(i * 1000)}; c.Name != fmt.Sprintf("c%d", i) || !reflect.DeepEqual(c.Values, want) {
+			t.Errorf("column %d is %s %v, want c%d %v", i, c.Name, c.Values, i, want)
+		}
+	}
+}
+
+// TestMarshalGolden pins the bytes of the file. After a deliberate change to the writer,
+// rewrite the golden file with -update and check it with a real reader before committing
+// it, such as:
+//
+//	python3 -c 'import pyarrow.parquet as pq; print(pq.read_table("pkg/parquet/testdata/golden.parquet"))'
+//	duckdb -c "select * from 'pkg/parquet/testdata/golden.parquet'"
+func TestMarshalGolden(t *testing.T) {
+	data, err := testTable().Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	golden := filepath.Join("testdata", "golden.parquet")
+	if *update {
+		if err := os.WriteFile(golden, data, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want, err := os.ReadFile(golden)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(data, want) {
+		t.Errorf("file differs from %s, run the test with -update after checking the change", golden)
+	}
+}
+
+func TestMarshalRowsMismatch(t *testing.T) {
+	var table parquet.Table
+	table.String("job", []string{"read", "write"})
+	table.Int64("numjobs", []int64{1})
+	if _, err := table.Marshal(); err == nil {
+		t.Error("table with columns of 2 and 1 rows marshalled")
+	}
+
+	if _, err := (&parquet.Table{}).Marshal(); err == nil {
+		t.Error("table without columns marshalled")
+	}
+}
@@ -0,0 +1,309 @@
+// Package parquettest reads the Parquet files of the parquet package back for tests. It
+// decodes the Thrift compact protocol and the pages from the format specification, sharing
+// no code with the writer, so a round trip checks the writer against the format rather than
+// against itself. It reads flat files of required, plain encoded, uncompressed columns.
+package parquettest
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"time"
+)
+
+// Column is a column of a Parquet file. Values are int64, float64, string or, for
+// TIMESTAMP_MILLIS columns, UTC time.Time.
+type Column struct {
+	Name      string
+	Type      int64 // Physical type: 2 INT64, 5 DOUBLE, 6 BYTE_ARRAY
+	Converted int64 // Converted type, -1 for none: 0 UTF8, 9 TIMESTAMP_MILLIS
+	Values    []interface{}
+}
+
+// File is the content of a Parquet file
+type File struct {
+	Rows      int64
+	CreatedBy string
+	Columns   []Column
+}
+
+// Column returns the column of a name, nil without one
+func (f *File) Column(name string) *Column {
+	for i := range f.Columns {
+		if f.Columns[i].Name == name {
+			return &f.Columns[i]
+		}
+	}
+	return nil
+}
+
+// Read decodes a Parquet file
+func Read(data []byte) (*File, error) {
+	if len(data) < 12 || string(data[:4]) != "PAR1" || string(data[len(data)-4:]) != "PAR1" {
+		return nil, fmt.Errorf("not a Parquet file")
+	}
+	footerLen := int(binary.LittleEndian.Uint32(data[len(data)-8:]))
+	if footerLen > len(data)-12 {
+		return nil, fmt.Errorf("footer length %d exceeds the file", footerLen)
+	}
+	footerStart := len(data) - 8 - footerLen
+	r := &reader{data: data[footerStart : len(data)-8]}
+	meta, err := r.readStruct()
+	if err != nil {
+		return nil, fmt.Errorf("footer: %w", err)
+	}
+	if r.pos != len(r.data) {
+		return nil, fmt.Errorf("footer has %d trailing bytes", len(r.data)-r.pos)
+	}
+
+	f := &File{Rows: meta.int(3)}
+	f.CreatedBy, _ = meta[6].(string)
+
+	schema := meta.list(2)
+	if len(schema) == 0 {
+		return nil, fmt.Errorf("file has no schema")
+	}
+	if root := schema[0].(tstruct); root.int(5) != int64(len(schema)-1) {
+		return nil, fmt.Errorf("schema root has %d children, %d elements follow", root.int(5), len(schema)-1)
+	}
+	for _, e := range schema[1:] {
+		element := e.(tstruct)
+		if element.int(3) != 0 {
+			return nil, fmt.Errorf("column %s is not required", element[4])
+		}
+		converted := int64(-1)
+		if _, ok := element[6]; ok {
+			converted = element.int(6)
+		}
+		name, _ := element[4].(string)
+		f.Columns = append(f.Columns, Column{Name: name, Type: element.int(1), Converted: converted})
+	}
+
+	groups := meta.list(4)
+	if len(groups) != 1 {
+		return nil, fmt.Errorf("file has %d row groups, want 1", len(groups))
+	}
+	group := groups[0].(tstruct)
+	if group.int(3) != f.Rows {
+		return nil, fmt.Errorf("row group has %d rows, file %d", group.int(3), f.Rows)
+	}
+	chunks := group.list(1)
+	if len(chunks) != len(f.Columns) {
+		return nil, fmt.Errorf("row group has %d column chunks for %d columns", len(chunks), len(f.Columns))
+	}
+
+	var total int64
+	for i, c := range chunks {
+		column := &f.Columns[i]
+		chunk := c.(tstruct)
+		cmeta, ok := chunk[3].(tstruct)
+		if !ok {
+			return nil, fmt.Errorf("column %s has no metadata", column.Name)
+		}
+		if path := cmeta.list(3); len(path) != 1 || path[0] != column.Name {
+			return nil, fmt.Errorf("column %s has path %v", column.Name, path)
+		}
+		if cmeta.int(1) != column.Type || cmeta.int(4) != 0 || cmeta.int(5) != f.Rows {
+			return nil, fmt.Errorf("column %s has type %d, codec %d and %d values", column.Name, cmeta.int(1), cmeta.int(4), cmeta.int(5))
+		}
+		offset, size := cmeta.int(9), cmeta.int(7)
+		if offset < 4 || offset+size > int64(footerStart) {
+			return nil, fmt.Errorf("column %s at %d+%d is outside the data", column.Name, offset, size)
+		}
+		total += size
+		if err := readPage(column, data[offset:offset+size], f.Rows); err != nil {
+			return nil, fmt.Errorf("column %s: %w", column.Name, err)
+		}
+	}
+	if group.int(2) != total {
+		return nil, fmt.Errorf("row group has %d bytes, its columns %d", group.int(2), total)
+	}
+	return f, nil
+}
+
+// readPage decodes the single data page of a column chunk
+func readPage(column *Column, chunk []byte, rows int64) error {
+	r := &reader{data: chunk}
+	header, err := r.readStruct()
+	if err != nil {
+		return fmt.Errorf("page header: %w", err)
+	}
+	page := chunk[r.pos:]
+	dataHeader, _ := header[5].(tstruct)
+	switch {
+	case header.int(1) != 0 || dataHeader == nil:
+		return fmt.Errorf("page type %d is not a data page", header.int(1))
+	case header.int(2) != int64(len(page)) || header.int(3) != int64(len(page)):
+		return fmt.Errorf("page of %d bytes has sizes %d and %d", len(page), header.int(2), header.int(3))
+	case dataHeader.int(1) != rows || dataHeader.int(2) != 0:
+		return fmt.Errorf("page has %d values in encoding %d", dataHeader.int(1), dataHeader.int(2))
+	}
+
+	for i := int64(0); i < rows; i++ {
+		switch column.Type {
+		case 2, 5:
+			if len(page) < 8 {
+				return fmt.Errorf("page ends at value %d", i)
+			}
+			bits := binary.LittleEndian.Uint64(page)
+			page = page[8:]
+			switch {
+			case column.Type == 5:
+				column.Values = append(column.Values, math.Float64frombits(bits))
+			case column.Converted == 9:
+				column.Values = append(column.Values, time.UnixMilli(int64(bits)).UTC())
+			default:
+				column.Values = append(column.Values, int64(bits))
+			}
+		case 6:
+			if len(page) < 4 {
+				return fmt.Errorf("page ends at value %d", i)
+			}
+			n := int(binary.LittleEndian.Uint32(page))
+			if n > len(page)-4 {
+				return fmt.Errorf("value %d of %d bytes exceeds the page", i, n)
+			}
+			column.Values = append(column.Values, string(page[4:4+n]))
+			page = page[4+n:]
+		default:
+			return fmt.Errorf("unsupported physical type %d", column.Type)
+		}
+	}
+	if len(page) != 0 {
+		return fmt.Errorf("page has %d bytes after its values", len(page))
+	}
+	return nil
+}
+
+// tstruct is a decoded Thrift struct, its values by field id
+type tstruct map[int16]interface{}
+
+// int returns an integer field, 0 without it
+func (s tstruct) int(id int16) int64 {
+	v, _ := s[id].(int64)
+	return v
+}
+
+// list returns a list field, nil without it
+func (s tstruct) list(id int16) []interface{} {
+	v, _ := s[id].([]interface{})
+	return v
+}
+
+// reader decodes the Thrift compact protocol
+type reader struct {
+	data []byte
+	pos  int
+}
+
+// byte reads a byte
+func (r *reader) byte() (byte, error) {
+	if r.pos >= len(r.data) {
+		return 0, fmt.Errorf("unexpected end at %d", r.pos)
+	}
+	b := r.data[r.pos]
+	r.pos++
+	return b, nil
+}
+
+// uvarint reads an unsigned varint
+func (r *reader) uvarint() (uint64, error) {
+	v, n := binary.Uvarint(r.data[r.pos:])
+	if n <= 0 {
+		return 0, fmt.Errorf("bad varint at %d", r.pos)
+	}
+	r.pos += n
+	return v, nil
+}
+
+// zigzag reads a zigzag encoded signed varint
+func (r *reader) zigzag() (int64, error) {
+	v, err := r.uvarint()
+	return int64(v>>1) ^ -int64(v&1), err
+}
+
+// readStruct reads a struct up to its stop field
+func (r *reader) readStruct() (tstruct, error) {
+	s := make(tstruct)
+	var last int16
+	for {
+		b, err := r.byte()
+		if err != nil {
+			return nil, err
+		}
+		if b == 0 {
+			return s, nil
+		}
+		typ := b & 0x0f
+		id := last + int16(b>>4)
+		if b>>4 == 0 {
+			v, err := r.zigzag()
+			if err != nil {
+				return nil, err
+			}
+			id = int16(v)
+		}
+		if id <= last {
+			return nil, fmt.Errorf("field %d follows field %d", id, last)
+		}
+		last = id
+		if s[id], err = r.readValue(typ); err != nil {
+			return nil, fmt.Errorf("field %d: %w", id, err)
+		}
+	}
+}
+
+// readValue reads a value of a compact type: integers as int64, binaries as string, lists
+// and sets as []interface{} and structs as tstruct
+func (r *reader) readValue(typ byte) (interface{}, error) {
+	switch typ {
+	case 1, 2:
+		return typ == 1, nil
+	case 3:
+		b, err := r.byte()
+		return int64(int8(b)), err
+	case 4, 5, 6:
+		return r.zigzag()
+	case 7:
+		if r.pos+8 > len(r.data) {
+			return nil, fmt.Errorf("unexpected end at %d", r.pos)
+		}
+		v := math.Float64frombits(binary.LittleEndian.Uint64(r.data[r.pos:]))
+		r.pos += 8
+		return v, nil
+	case 8:
+		n, err := r.uvarint()
+		if err != nil {
+			return nil, err
+		}
+		if uint64(len(r.data)-r.pos) < n {
+			return nil, fmt.Errorf("binary of %d bytes exceeds the data", n)
+		}
+		v := string(r.data[r.pos : r.pos+int(n)])
+		r.pos += int(n)
+		return v, nil
+	case 9, 10:
+		b, err := r.byte()
+		if err != nil {
+			return nil, err
+		}
+		size := uint64(b >> 4)
+		if size == 15 {
+			if size, err = r.uvarint(); err != nil {
+				return nil, err
+			}
+		}
+		values := make([]interface{}, 0, size)
+		for i := uint64(0); i < size; i++ {
+			v, err := r.readValue(b & 0x0f)
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, v)
+		}
+		return values, nil
+	case 12:
+		return r.readStruct()
+	}
+	return nil, fmt.Errorf("unsupported type %d", typ)
+}
@@ -0,0 +1,105 @@
+package parquet
+
+import (
+	"bytes"
+	"encoding/binary"
+)
+
+// Field types of the Thrift compact protocol
+const (
+	ctI32    = 5
+	ctI64    = 6
+	ctBinary = 8
+	ctList   = 9
+	ctStruct = 12
+)
+
+// compactWriter encodes Thrift structs in the compact protocol, which the Parquet metadata
+// uses. Fields are written in ascending order of their ids within every struct.
+type compactWriter struct {
+	buf    bytes.Buffer
+	lastID int16
+	stack  []int16 // Last field ids of the enclosing structs
+}
+
+// varint writes a signed integer zigzag encoded as a varint
+func (w *compactWriter) varint(v int64) {
+	w.uvarint(uint64((v << 1) ^ (v >> 63)))
+}
+
+// uvarint writes an unsigned varint
+func (w *compactWriter) uvarint(v uint64) {
+	var b [binary.MaxVarintLen64]byte
+	w.buf.Write(b[:binary.PutUvarint(b[:], v)])
+}
+
+// bytes writes a length-prefixed binary, the encoding of strings
+func (w *compactWriter) bytes(s string) {
+	w.uvarint(uint64(len(s)))
+	w.buf.WriteString(s)
+}
+
+// field writes the header of a field, with the id as a delta of the previous one when it fits
+func (w *compactWriter) field(typ byte, id int16) {
+	if delta := id - w.lastID; delta > 0 && delta <= 15 {
+		w.buf.WriteByte(byte(delta)<<4 | typ)
+	} else {
+		w.buf.WriteByte(typ)
+		w.varint(int64(id))
+	}
+	w.lastID = id
+}
+
+// i32 writes an i32 field, the type of enums too
+func (w *compactWriter) i32(id int16, v int32) {
+	w.field(ctI32, id)
+	w.varint(int64(v))
+}
+
+// i64 writes an i64 field
+func (w *compactWriter) i64(id int16, v int64) {
+	w.field(ctI64, id)
+	w.varint(v)
+}
+
+// binary writes a string field
+func (w *compactWriter) binary(id int16, s string) {
+	w.field(ctBinary, id)
+	w.bytes(s)
+}
+
+// structBegin starts a struct field, ended by structEnd
+func (w *compactWriter) structBegin(id int16) {
+	w.field(ctStruct, id)
+	w.elemBegin()
+}
+
+// elemBegin starts a struct element of a list, ended by structEnd
+func (w *compactWriter) elemBegin() {
+	w.stack = append(w.stack, w.lastID)
+	w.lastID = 0
+}
+
+// structEnd ends the current struct
+func (w *compactWriter) structEnd() {
+	w.buf.WriteByte(0)
+	w.lastID = w.stack[len(w.stack)-1]
+	w.stack = w.stack[:len(w.stack)-1]
+}
+
+// listBegin writes the header of a list field of size elements of a type. The elements
+// follow without field headers.
+func (w *compactWriter) listBegin(id int16, elem byte, size int) {
+	w.field(ctList, id)
+	if size < 15 {
+		w.buf.WriteByte(byte(size)<<4 | elem)
+		return
+	}
+	w.buf.WriteByte(0xf0 | elem)
+	w.uvarint(uint64(size))
+}
+
+// stop ends the top-level struct
+func (w *compactWriter) stop() {
+	w.buf.WriteByte(0)
+}
@@ -0,0 +1,249 @@
+package plan
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/jtaleric/k8s-io/pkg/config"
+	"github.com/jtaleric/k8s-io/pkg/estimate"
+)
+
+// Version is bumped whenever the plan format changes incompatibly
+const Version = 1
+
+// Plan is everything a run will do, decided before anything is applied. It can be reviewed,
+// saved and executed later with the exact same configuration and UUID.
+type Plan struct {
+	Version     int        `yaml:"version"`
+	Created     time.Time  `yaml:"created"`
+	Workload    string     `yaml:"workload"`
+	UUID        string     `yaml:"uuid"`
+	Namespace   string     `yaml:"namespace"`
+	Fingerprint string     `yaml:"fingerprint"`
+	Estimate    Estimate   `yaml:"estimate"`
+	Phases      []Phase    `yaml:"phases"`
+	Manifests   []Manifest `yaml:"manifests"`
+	Config      string     `yaml:"config"` // Effective configuration the plan is executed with
+}
+
+// Phase is a step of the run in execution order, named like the timeline phases
+type Phase struct {
+	Name        string   `yaml:"name"`
+	Description string   `yaml:"description"`
+	Applies     []string `yaml:"applies,omitempty"` // Manifests applied in this phase
+	Jobs        []string `yaml:"jobs,omitempty"`    // Jobs the phase waits for
+	Waits       []Wait   `yaml:"waits,omitempty"`
+}
+
+// Wait is a condition a phase waits for and how long it waits at most
+type Wait struct {
+	For     string `yaml:"for"`
+	Timeout string `yaml:"timeout"`
+}
+
+// Manifest is a manifest rendered before the run, as dry-run shows it. Manifests that depend
+// on the cluster, such as the FIO client with the server IPs, are rendered again during the run.
+type Manifest struct {
+	Name    string `yaml:"name"`
+	Kind    string `yaml:"kind"`
+	Digest  string `yaml:"digest"`
+	Content string `yaml:"content"`
+}
+
+// Estimate is the printable form of the run estimate
+type Estimate struct {
+	Pods     int      `yaml:"pods"`
+	VMs      int      `yaml:"vms,omitempty"`
+	PVCs     int      `yaml:"pvcs"`
+	Storage  string   `yaml:"storage"`
+	CPU      string   `yaml:"cpu"`
+	Memory   string   `yaml:"memory"`
+	Duration string   `yaml:"duration"`
+	Notes    []string `yaml:"notes,omitempty"`
+}
+
+// New creates a plan from the effective configuration and what the workload decided to do
+func New(cfg *config.Config, fingerprint string, manifests map[string]string, phases []Phase, est *estimate.Estimate) (*Plan, error) {
+	effective, err := yaml.Marshal(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal configuration: %w", err)
+	}
+
+	p := &Plan{
+		Version:     Version,
+		Created:     time.Now().UTC(),
+		Workload:    cfg.Workload.Name,
+		UUID:        cfg.UUID,
+		Namespace:   cfg.Namespace,
+		Fingerprint: fingerprint,
+		Phases:      phases,
+		Manifests:   manifestList(manifests),
+		Config:      string(effective),
+		Estimate: Estimate{
+			Pods:     est.Pods,
+			VMs:      est.VMs,
+			PVCs:     est.PVCs,
+			Storage:  est.Storage.String(),
+			CPU:      est.CPU.String(),
+			Memory:   est.Memory.String(),
+			Duration: est.Duration.Round(time.Second).String(),
+			Notes:    est.Notes,
+		},
+	}
+	return p, nil
+}
+
+// Load reads a saved plan
+func Load(path string) (*Plan, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read plan %s: %w", path, err)
+	}
+
+	p, err := Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return p, nil
+}
+
+// Parse parses a saved plan
+func Parse(data []byte) (*Plan, error) {
+	var p Plan
+	if err := yaml.Unmarshal(data, &p); err != nil {
+		return nil, fmt.Errorf("failed to parse plan: %w", err)
+	}
+	if p.Version != Version {
+		return nil, fmt.Errorf("plan has version %d, this k8s-io reads version %d", p.Version, Version)
+	}
+	return &p, nil
+}
+
+// Marshal returns the plan as it is saved
+func (p *Plan) Marshal() ([]byte, error) {
+	data, err := yaml.Marshal(p)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal plan: %w", err)
+	}
+	return data, nil
+}
+
+// Rewrite replaces the configuration and manifests of the plan with f applied to them. The
+// digests are updated, so the plan verifies when the rewritten configuration renders the
+// rewritten manifests.
+func (p *Plan) Rewrite(f func(string) string) {
+	p.Config = f(p.Config)
+	manifests := make(map[string]string, len(p.Manifests))
+	for _, m := range p.Manifests {
+		manifests[m.Name] = f(m.Content)
+	}
+	p.Manifests = manifestList(manifests)
+}
+
+// Save writes the plan to a file, readable only by the owner since the configuration may
+// hold credentials
+func (p *Plan) Save(path string) error {
+	data, err := p.Marshal()
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		return fmt.Errorf("failed to write plan %s: %w", path, err)
+	}
+	return nil
+}
+
+// Verify checks that manifests rendered from the plan configuration are the ones that were
+// reviewed, so a plan is not executed differently by another k8s-io version
+func (p *Plan) Verify(manifests map[string]string) error {
+	rendered := make(map[string]Manifest)
+	for _, m := range manifestList(manifests) {
+		rendered[m.Name] = m
+	}
+
+	var diffs []string
+	for _, m := range p.Manifests {
+		r, ok := rendered[m.Name]
+		switch {
+		case !ok:
+			diffs = append(diffs, m.Name+" is no longer rendered")
+		case r.Digest != m.Digest:
+			diffs = append(diffs, m.Name+" changed")
+		}
+		delete(rendered, m.Name)
+	}
+	for name := range rendered {
+		diffs = append(diffs, name+" was not in the plan")
+	}
+
+	if len(diffs) > 0 {
+		sort.Strings(diffs)
+		return fmt.Errorf("manifests differ from the plan: %s", strings.Join(diffs, ", "))
+	}
+	return nil
+}
+
+// Print writes a review of the plan without the manifest contents
+func (p *Plan) Print(w io.Writer) {
+	fmt.Fprintf(w, "Plan for %s run %s in namespace %s (fingerprint %s)\n", p.Workload, p.UUID, p.Namespace, p.Fingerprint)
+
+	fmt.Fprintln(w, "\nPhases:")
+	for i, phase := range p.Phases {
+		fmt.Fprintf(w, "  %d. %s: %s\n", i+1, phase.Name, phase.Description)
+		for _, name := range phase.Applies {
+			fmt.Fprintf(w, "       apply %s\n", name)
+		}
+		for _, job := range phase.Jobs {
+			fmt.Fprintf(w, "       job   %s\n", job)
+		}
+		for _, wait := range phase.Waits {
+			fmt.Fprintf(w, "       wait  %s (timeout %s)\n", wait.For, wait.Timeout)
+		}
+	}
+
+	fmt.Fprintln(w, "\nManifests:")
+	for _, m := range p.Manifests {
+		fmt.Fprintf(w, "  %-32s %-24s %s\n", m.Name, m.Kind, m.Digest[:12])
+	}
+
+	e := p.Estimate
+	fmt.Fprintf(w, "\nEstimate: %d pods, %d VMs, %d PVCs (%s), %s CPU, %s memory, %s\n",
+		e.Pods, e.VMs, e.PVCs, e.Storage, e.CPU, e.Memory, e.Duration)
+	for _, note := range e.Notes {
+		fmt.Fprintf(w, "  Note: %s\n", note)
+	}
+}
+
+// manifestList returns the manifests sorted by name with their kind and digest
+func manifestList(manifests map[string]string) []Manifest {
+	list := make([]Manifest, 0, len(manifests))
+	for name, content := range manifests {
+		sum := sha256.Sum256([]byte(content))
+		list = append(list, Manifest{
+			Name:    name,
+			Kind:    kindOf(content),
+			Digest:  hex.EncodeToString(sum[:]),
+			Content: content,
+		})
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
+
+// kindOf returns the kind of a manifest from its top-level kind field
+func kindOf(content string) string {
+	for _, line := range strings.Split(content, "\n") {
+		if strings.HasPrefix(line, "kind:") {
+			return strings.Trim(strings.TrimSpace(strings.TrimPrefix(line, "kind:")), `"'`)
+		}
+	}
+	return ""
+}
@@ -0,0 +1,81 @@
+package preflight
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/jtaleric/k8s-io/pkg/prometheus"
+	"github.com/jtaleric/k8s-io/pkg/timeline"
+)
+
+// Node exporter metrics reported by the kernel NTP state
+const (
+	clockOffsetQuery = "node_timex_offset_seconds"
+	clockSyncQuery   = "node_timex_sync_status"
+	timezoneQuery    = "node_time_zone_offset_seconds"
+)
+
+// CheckClockSkew checks the node clock offsets reported by node-exporter and returns
+// a warning for every node whose clock is unsynchronized or off by more than maxOffsetMS
+func CheckClockSkew(ctx context.Context, prom *prometheus.Client, maxOffsetMS float64) ([]string, error) {
+	offsets, err := prom.Query(ctx, clockOffsetQuery)
+	if err != nil {
+		return nil, err
+	}
+	if len(offsets) == 0 {
+		return nil, fmt.Errorf("no %s samples found, is node-exporter running?", clockOffsetQuery)
+	}
+
+	var warnings []string
+	for _, sample := range offsets {
+		offsetMS := math.Abs(sample.Value) * 1000
+		if offsetMS > maxOffsetMS {
+			warnings = append(warnings, fmt.Sprintf("clock on %s is off by %.1fms (max %.1fms)", nodeName(sample), offsetMS, maxOffsetMS))
+		}
+	}
+
+	syncStatus, err := prom.Query(ctx, clockSyncQuery)
+	if err != nil {
+		return nil, err
+	}
+	for _, sample := range syncStatus {
+		if sample.Value == 0 {
+			warnings = append(warnings, fmt.Sprintf("clock on %s is not synchronized", nodeName(sample)))
+		}
+	}
+
+	sort.Strings(warnings)
+	return warnings, nil
+}
+
+// NodeTimezones returns the timezone of every node reported by the node-exporter time
+// collector, such as "UTC (+00:00)"
+func NodeTimezones(ctx context.Context, prom *prometheus.Client) (map[string]string, error) {
+	samples, err := prom.Query(ctx, timezoneQuery)
+	if err != nil {
+		return nil, err
+	}
+	if len(samples) == 0 {
+		return nil, fmt.Errorf("no %s samples found, is node-exporter running?", timezoneQuery)
+	}
+
+	timezones := make(map[string]string, len(samples))
+	for _, sample := range samples {
+		offset := time.FixedZone(sample.Labels["time_zone"], int(sample.Value))
+		timezones[nodeName(sample)] = timeline.Timezone(time.Now().In(offset))
+	}
+	return timezones, nil
+}
+
+// nodeName returns the most specific name of the node a sample was scraped from
+func nodeName(sample prometheus.Sample) string {
+	for _, label := range []string{"node", "nodename", "instance"} {
+		if name := sample.Labels[label]; name != "" {
+			return name
+		}
+	}
+	return "unknown node"
+}
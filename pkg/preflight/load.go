@@ -0,0 +1,66 @@
+package preflight
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/jtaleric/k8s-io/pkg/prometheus"
+)
+
+// Node exporter CPU utilization and Ceph manager health metrics
+const (
+	nodeCPUQuery      = `100 * (1 - avg by (instance) (rate(node_cpu_seconds_total{mode="idle"}[2m])))`
+	cephHealthQuery   = "ceph_health_status"
+	cephHealthOKValue = 0
+)
+
+// cephHealthNames are the ceph_health_status values of the Ceph manager exporter
+var cephHealthNames = map[float64]string{0: "HEALTH_OK", 1: "HEALTH_WARN", 2: "HEALTH_ERR"}
+
+// CheckNodeCPU returns a violation for every node whose CPU utilization over the last two
+// minutes is above maxPercent
+func CheckNodeCPU(ctx context.Context, prom *prometheus.Client, maxPercent float64) ([]string, error) {
+	samples, err := prom.Query(ctx, nodeCPUQuery)
+	if err != nil {
+		return nil, err
+	}
+	if len(samples) == 0 {
+		return nil, fmt.Errorf("no node_cpu_seconds_total samples found, is node-exporter running?")
+	}
+
+	var violations []string
+	for _, sample := range samples {
+		if sample.Value > maxPercent {
+			violations = append(violations, fmt.Sprintf("CPU on %s is %.1f%% busy (max %.1f%%)", nodeName(sample), sample.Value, maxPercent))
+		}
+	}
+	sort.Strings(violations)
+	return violations, nil
+}
+
+// CheckCephHealth returns a violation for every Ceph cluster the manager exporter reports as
+// not HEALTH_OK. It reports found as false when Prometheus has no Ceph metrics.
+func CheckCephHealth(ctx context.Context, prom *prometheus.Client) (violations []string, found bool, err error) {
+	samples, err := prom.Query(ctx, cephHealthQuery)
+	if err != nil {
+		return nil, false, err
+	}
+
+	for _, sample := range samples {
+		if sample.Value == cephHealthOKValue {
+			continue
+		}
+		health, ok := cephHealthNames[sample.Value]
+		if !ok {
+			health = fmt.Sprintf("health status %g", sample.Value)
+		}
+		cluster := sample.Labels["namespace"]
+		if cluster == "" {
+			cluster = sample.Labels["job"]
+		}
+		violations = append(violations, fmt.Sprintf("Ceph cluster %s is %s", cluster, health))
+	}
+	sort.Strings(violations)
+	return violations, len(samples) > 0, nil
+}
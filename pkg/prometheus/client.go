@@ -0,0 +1,219 @@
+package prometheus
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Client queries the Prometheus HTTP API
+type Client struct {
+	url        string
+	token      string
+	httpClient *http.Client
+}
+
+// Sample is a single instant vector sample
+type Sample struct {
+	Labels map[string]string
+	Value  float64
+}
+
+// Point is a single value of a range vector
+type Point struct {
+	Time  time.Time
+	Value float64
+}
+
+// Series is a single range vector series
+type Series struct {
+	Labels map[string]string
+	Points []Point
+}
+
+// queryResponse is the Prometheus instant and range query response
+type queryResponse struct {
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+	Data   struct {
+		ResultType string `json:"resultType"`
+		Result     []struct {
+			Metric map[string]string `json:"metric"`
+			Value  []interface{}     `json:"value"`
+			Values [][]interface{}   `json:"values"`
+		} `json:"result"`
+	} `json:"data"`
+}
+
+// Options configure the HTTP client of a Prometheus client
+type Options struct {
+	Timeout            time.Duration     // Timeout of every query (default 30s)
+	InsecureSkipVerify bool              // Do not verify the certificate of the server
+	Transport          http.RoundTripper // Replaces the default transport, such as to reach Prometheus through the API server
+}
+
+// NewClient creates a new Prometheus client
+func NewClient(url, token string) *Client {
+	return NewClientWithOptions(url, token, Options{})
+}
+
+// NewClientWithOptions creates a new Prometheus client with a custom HTTP client
+func NewClientWithOptions(url, token string, opts Options) *Client {
+	httpClient := &http.Client{Timeout: 30 * time.Second}
+	if opts.Timeout > 0 {
+		httpClient.Timeout = opts.Timeout
+	}
+	if opts.Transport != nil {
+		httpClient.Transport = opts.Transport
+	} else if opts.InsecureSkipVerify {
+		httpClient.Transport = &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}}
+	}
+
+	return &Client{
+		url:        strings.TrimSuffix(url, "/"),
+		token:      token,
+		httpClient: httpClient,
+	}
+}
+
+// Verify checks that the endpoint answers queries with the token by querying up, and returns
+// the number of targets that are up
+func (c *Client) Verify(ctx context.Context) (int, error) {
+	samples, err := c.Query(ctx, "up")
+	if err != nil {
+		return 0, err
+	}
+	up := 0
+	for _, s := range samples {
+		if s.Value == 1 {
+			up++
+		}
+	}
+	return up, nil
+}
+
+// Query runs an instant query at the current time and returns the resulting vector
+func (c *Client) Query(ctx context.Context, query string) ([]Sample, error) {
+	return c.QueryAt(ctx, query, time.Time{})
+}
+
+// QueryAt runs an instant query evaluated at the given time and returns the resulting vector
+func (c *Client) QueryAt(ctx context.Context, query string, ts time.Time) ([]Sample, error) {
+	params := url.Values{"query": {query}}
+	if !ts.IsZero() {
+		params.Set("time", strconv.FormatInt(ts.Unix(), 10))
+	}
+
+	result, err := c.get(ctx, "query", params)
+	if err != nil {
+		return nil, err
+	}
+	if result.Data.ResultType != "vector" {
+		return nil, fmt.Errorf("unexpected Prometheus result type: %s", result.Data.ResultType)
+	}
+
+	samples := make([]Sample, 0, len(result.Data.Result))
+	for _, r := range result.Data.Result {
+		if len(r.Value) != 2 {
+			continue
+		}
+		value, ok := parseValue(r.Value[1])
+		if !ok {
+			continue
+		}
+		samples = append(samples, Sample{Labels: r.Metric, Value: value})
+	}
+
+	return samples, nil
+}
+
+// QueryRange runs a range query between start and end at the given resolution
+func (c *Client) QueryRange(ctx context.Context, query string, start, end time.Time, step time.Duration) ([]Series, error) {
+	params := url.Values{
+		"query": {query},
+		"start": {strconv.FormatInt(start.Unix(), 10)},
+		"end":   {strconv.FormatInt(end.Unix(), 10)},
+		"step":  {strconv.FormatFloat(step.Seconds(), 'f', -1, 64)},
+	}
+
+	result, err := c.get(ctx, "query_range", params)
+	if err != nil {
+		return nil, err
+	}
+	if result.Data.ResultType != "matrix" {
+		return nil, fmt.Errorf("unexpected Prometheus result type: %s", result.Data.ResultType)
+	}
+
+	series := make([]Series, 0, len(result.Data.Result))
+	for _, r := range result.Data.Result {
+		s := Series{Labels: r.Metric}
+		for _, v := range r.Values {
+			if len(v) != 2 {
+				continue
+			}
+			ts, ok := v[0].(float64)
+			if !ok {
+				continue
+			}
+			value, ok := parseValue(v[1])
+			if !ok {
+				continue
+			}
+			s.Points = append(s.Points, Point{Time: time.UnixMilli(int64(ts * 1000)).UTC(), Value: value})
+		}
+		series = append(series, s)
+	}
+
+	return series, nil
+}
+
+// get calls a Prometheus query API endpoint and decodes the response
+func (c *Client) get(ctx context.Context, api string, params url.Values) (*queryResponse, error) {
+	endpoint := fmt.Sprintf("%s/api/v1/%s?%s", c.url, api, params.Encode())
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create query request: %w", err)
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query Prometheus: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var result queryResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		if resp.StatusCode >= 300 {
+			return nil, fmt.Errorf("Prometheus returned %s", resp.Status)
+		}
+		return nil, fmt.Errorf("failed to decode Prometheus response (%s): %w", resp.Status, err)
+	}
+	if result.Status != "success" {
+		return nil, fmt.Errorf("Prometheus query failed: %s", result.Error)
+	}
+
+	return &result, nil
+}
+
+// parseValue converts a sample value, which Prometheus encodes as a string
+func parseValue(raw interface{}) (float64, bool) {
+	str, ok := raw.(string)
+	if !ok {
+		return 0, false
+	}
+	value, err := strconv.ParseFloat(str, 64)
+	if err != nil {
+		return 0, false
+	}
+	return value, true
+}
@@ -0,0 +1,71 @@
+package report
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/jtaleric/k8s-io/pkg/config"
+)
+
+// PostComment posts a markdown comment to the configured GitHub pull request or GitLab merge request
+func PostComment(ctx context.Context, cfg *config.PRCommentConfig, body string) error {
+	token := os.Getenv(cfg.TokenEnv)
+	if token == "" {
+		return fmt.Errorf("environment variable %s with the API token is not set", cfg.TokenEnv)
+	}
+
+	var endpoint string
+	var payload []byte
+	var err error
+	req := func(r *http.Request) {}
+
+	switch cfg.Provider {
+	case "github":
+		endpoint = fmt.Sprintf("%s/repos/%s/issues/%d/comments", strings.TrimSuffix(cfg.APIURL, "/"), cfg.Repository, cfg.Number)
+		payload, err = json.Marshal(map[string]string{"body": body})
+		req = func(r *http.Request) {
+			r.Header.Set("Authorization", "Bearer "+token)
+			r.Header.Set("Accept", "application/vnd.github+json")
+		}
+	case "gitlab":
+		endpoint = fmt.Sprintf("%s/projects/%s/merge_requests/%d/notes", strings.TrimSuffix(cfg.APIURL, "/"), url.PathEscape(cfg.Repository), cfg.Number)
+		payload, err = json.Marshal(map[string]string{"body": body})
+		req = func(r *http.Request) {
+			r.Header.Set("PRIVATE-TOKEN", token)
+		}
+	default:
+		return fmt.Errorf("unsupported PR comment provider: %s", cfg.Provider)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to marshal comment: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(payload))
+	if err != nil {
+		return fmt.Errorf("failed to create comment request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	req(httpReq)
+
+	resp, err := http.DefaultClient.Do(httpReq)
+	if err != nil {
+		return fmt.Errorf("failed to post comment: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("%s returned status %s", cfg.Provider, resp.Status)
+	}
+
+	return nil
+}
@@ -0,0 +1,51 @@
+package report
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/jtaleric/k8s-io/pkg/history"
+	"github.com/jtaleric/k8s-io/pkg/timeline"
+	"github.com/jtaleric/k8s-io/pkg/units"
+)
+
+// ComparisonMarkdown renders a markdown table comparing a run against a baseline run.
+// The baseline may be nil when there is no previous comparable run.
+func ComparisonMarkdown(baseline *history.Record, current history.Record) string {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "### k8s-io %s results\n\n", current.Workload)
+	fmt.Fprintf(&b, "Run `%s` started %s, config fingerprint `%s`\n\n", current.UUID, timeline.FormatUTC(current.Timestamp), current.ConfigHash)
+
+	metrics := make([]string, 0, len(current.Metrics))
+	for metric := range current.Metrics {
+		metrics = append(metrics, metric)
+	}
+	sort.Strings(metrics)
+
+	if baseline == nil {
+		b.WriteString("No comparable baseline run found.\n\n")
+		b.WriteString("| Metric | Current |\n")
+		b.WriteString("|--------|--------:|\n")
+		for _, metric := range metrics {
+			fmt.Fprintf(&b, "| %s | %s |\n", metric, units.Number(current.Metrics[metric], 2))
+		}
+		return b.String()
+	}
+
+	fmt.Fprintf(&b, "Compared against baseline run `%s` started %s.\n\n", baseline.UUID, timeline.FormatUTC(baseline.Timestamp))
+	b.WriteString("| Metric | Baseline | Current | Change |\n")
+	b.WriteString("|--------|---------:|--------:|-------:|\n")
+	for _, metric := range metrics {
+		after := current.Metrics[metric]
+		before, ok := baseline.Metrics[metric]
+		if !ok || before == 0 {
+			fmt.Fprintf(&b, "| %s | - | %s | - |\n", metric, units.Number(after, 2))
+			continue
+		}
+		fmt.Fprintf(&b, "| %s | %s | %s | %+.1f%% |\n", metric, units.Number(before, 2), units.Number(after, 2), (after-before)/before*100)
+	}
+
+	return b.String()
+}
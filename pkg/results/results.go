@@ -0,0 +1,32 @@
+package results
+
+import (
+	"sort"
+	"time"
+)
+
+// Run is the outcome of a benchmark run in the form every workload reports it, so commands
+// and reports handle the results of any workload the same way
+type Run struct {
+	UUID     string
+	Workload string
+	Start    time.Time // Run start in UTC
+	Samples  int       // Samples that produced results
+
+	// Metrics named like the history metrics, such as "randread-4KiB-1.read_iops" for FIO
+	// or "postgres.tpm" for HammerDB
+	Metrics map[string]float64
+
+	// Threshold violations, prefixed with the sample that violated them
+	Violations []string
+}
+
+// MetricNames returns the names of the metrics of the run, sorted
+func (r *Run) MetricNames() []string {
+	names := make([]string, 0, len(r.Metrics))
+	for name := range r.Metrics {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
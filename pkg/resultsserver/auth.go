@@ -0,0 +1,66 @@
+package resultsserver
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+
+	authenticationv1 "k8s.io/api/authentication/v1"
+
+	"github.com/jtaleric/k8s-io/pkg/kubernetes"
+)
+
+// Access levels an Authorizer decides on
+const (
+	AccessRead   = "read"   // Browse the runs of a namespace and download their artifacts
+	AccessUpload = "upload" // Upload runs of a namespace
+)
+
+// Authorizer authenticates the requests to the server and decides which runs they may access
+type Authorizer interface {
+	// Authenticate returns the user making the request
+	Authenticate(r *http.Request) (authenticationv1.UserInfo, error)
+
+	// Allowed reports whether the user has the access to the runs of the namespace
+	Allowed(ctx context.Context, user authenticationv1.UserInfo, namespace, access string) (bool, error)
+}
+
+// errNoToken is returned for requests without a bearer token
+var errNoToken = errors.New("no bearer token")
+
+// KubernetesAuthorizer authenticates bearer tokens with TokenReviews and enforces RBAC with
+// SubjectAccessReviews: reading the runs of a namespace requires listing its jobs, and
+// uploading them requires creating jobs, like running the benchmark there. Runs without a
+// namespace, recorded before runs had one, require the access in every namespace.
+type KubernetesAuthorizer struct {
+	client *kubernetes.Client
+}
+
+// NewKubernetesAuthorizer creates an authorizer reviewing tokens and access with the API
+// server of the client
+func NewKubernetesAuthorizer(client *kubernetes.Client) *KubernetesAuthorizer {
+	return &KubernetesAuthorizer{client: client}
+}
+
+// Authenticate reviews the bearer token of the Authorization header, or the access token an
+// authenticating proxy such as oauth2-proxy forwards
+func (a *KubernetesAuthorizer) Authenticate(r *http.Request) (authenticationv1.UserInfo, error) {
+	token := r.Header.Get("X-Forwarded-Access-Token")
+	if auth := r.Header.Get("Authorization"); strings.HasPrefix(auth, "Bearer ") {
+		token = strings.TrimPrefix(auth, "Bearer ")
+	}
+	if token == "" {
+		return authenticationv1.UserInfo{}, errNoToken
+	}
+	return a.client.AuthenticateToken(r.Context(), token)
+}
+
+// Allowed checks the jobs access of the user in the namespace
+func (a *KubernetesAuthorizer) Allowed(ctx context.Context, user authenticationv1.UserInfo, namespace, access string) (bool, error) {
+	verb := "list"
+	if access == AccessUpload {
+		verb = "create"
+	}
+	return a.client.CanAccess(ctx, user, namespace, verb, "batch", "jobs")
+}
@@ -0,0 +1,100 @@
+package resultsserver
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/jtaleric/k8s-io/pkg/history"
+)
+
+// Upload sends the history record of a run, when there is one, and every file of its
+// artifacts directory to the results server at serverURL, authenticated with the bearer token
+// when it is not empty. It returns the number of uploaded files.
+func Upload(ctx context.Context, serverURL, token string, record *history.Record, uuid, dir string) (int, error) {
+	base := strings.TrimRight(serverURL, "/")
+
+	if record != nil {
+		body, err := json.Marshal(record)
+		if err != nil {
+			return 0, fmt.Errorf("failed to marshal history record: %w", err)
+		}
+		if err := send(ctx, http.MethodPost, base+"/api/runs", token, body); err != nil {
+			return 0, fmt.Errorf("failed to upload the history record: %w", err)
+		}
+	}
+
+	uploaded := 0
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) && path == dir {
+				return filepath.SkipDir
+			}
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", path, err)
+		}
+
+		target := base + "/api/runs/" + url.PathEscape(uuid) + "/artifacts/" + escapePath(filepath.ToSlash(rel))
+		if err := send(ctx, http.MethodPut, target, token, data); err != nil {
+			return fmt.Errorf("failed to upload %s: %w", rel, err)
+		}
+		uploaded++
+		return nil
+	})
+	return uploaded, err
+}
+
+// send sends a request body to the server
+func send(ctx context.Context, method, target, token string, body []byte) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Minute)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, method, target, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	if method == http.MethodPost {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("results server returned status %s", resp.Status)
+	}
+	return nil
+}
+
+// escapePath escapes every element of a slash separated path
+func escapePath(path string) string {
+	parts := strings.Split(path, "/")
+	for i, part := range parts {
+		parts[i] = url.PathEscape(part)
+	}
+	return strings.Join(parts, "/")
+}
@@ -0,0 +1,34 @@
+package resultsserver
+
+import (
+	_ "embed"
+	"fmt"
+
+	"github.com/flosch/pongo2/v6"
+)
+
+//go:embed templates/manifests.yaml.j2
+var manifestsTemplate string
+
+// ManifestOptions are the settings of the results server deployment
+type ManifestOptions struct {
+	Namespace    string // Namespace of the deployment
+	Image        string // k8s-io image running the server
+	StorageClass string // Storage class of the history and artifacts volume, the default class when empty
+	StorageSize  string // Size of the history and artifacts volume
+	Port         int    // Port the server listens on
+	Auth         bool   // Enforce RBAC with TokenReviews and SubjectAccessReviews, which the server account may create
+}
+
+// Manifests renders the PVC, Deployment and Service running the results server in a cluster
+func Manifests(opts ManifestOptions) (string, error) {
+	tpl, err := pongo2.FromString(manifestsTemplate)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse results server manifests: %w", err)
+	}
+	out, err := tpl.Execute(pongo2.Context{"opts": opts})
+	if err != nil {
+		return "", fmt.Errorf("failed to render results server manifests: %w", err)
+	}
+	return out, nil
+}
@@ -0,0 +1,500 @@
+package resultsserver
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io"
+	"io/fs"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	authenticationv1 "k8s.io/api/authentication/v1"
+
+	"github.com/jtaleric/k8s-io/pkg/history"
+	"github.com/jtaleric/k8s-io/pkg/timeline"
+	"github.com/jtaleric/k8s-io/pkg/units"
+)
+
+//go:embed templates/*.html
+var templateFS embed.FS
+
+// maxUploadSize is the largest record or artifact accepted by the server
+const maxUploadSize = 512 << 20
+
+// Server serves the run history and the artifacts of the runs over HTTP: a web UI for
+// browsing and comparing runs, a JSON API, and uploads from k8s-io
+type Server struct {
+	store     *history.Store
+	artifacts string
+	pages     *template.Template
+	auth      Authorizer // Without one, every request may access every run
+	mu        sync.Mutex // Serializes appends to the history file
+}
+
+// New creates a server for the history file and the artifacts directory with one
+// subdirectory per run, like artifacts_dir. With an authorizer, requests only see the runs of
+// the namespaces it allows them and the owner of an uploaded run is the uploading user.
+func New(historyPath, artifactsDir string, auth Authorizer) (*Server, error) {
+	pages, err := template.New("").Funcs(template.FuncMap{
+		"utc":    timeline.FormatUTC,
+		"metric": func(name string, value float64) string { return units.Metric(name, value, units.Human) },
+	}).ParseFS(templateFS, "templates/*.html")
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse UI templates: %w", err)
+	}
+
+	return &Server{store: history.NewStore(historyPath), artifacts: artifactsDir, pages: pages, auth: auth}, nil
+}
+
+// Handler returns the routes of the server
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", s.handleIndex)
+	mux.HandleFunc("/runs/", s.handleRun)
+	mux.HandleFunc("/compare", s.handleCompare)
+	mux.HandleFunc("/artifacts/", s.handleArtifact)
+	mux.HandleFunc("/api/runs", s.handleAPIRuns)
+	mux.HandleFunc("/api/runs/", s.handleAPIRun)
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) { fmt.Fprintln(w, "ok") })
+	return mux
+}
+
+// runPage is the data of the run page
+type runPage struct {
+	Record    history.Record
+	Metrics   []string
+	Artifacts []string
+}
+
+// comparison is a metric of two runs
+type comparison struct {
+	Metric   string
+	Baseline float64
+	Current  float64
+	Change   string
+}
+
+// comparePage is the data of the compare page
+type comparePage struct {
+	Records  []history.Record
+	Baseline history.Record
+	Current  history.Record
+	Rows     []comparison
+	Warning  string
+}
+
+// handleIndex lists the runs, newest first, optionally of one workload
+func (s *Server) handleIndex(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
+	ss, ok := s.begin(w, r)
+	if !ok {
+		return
+	}
+	records, ok := s.load(w)
+	if !ok {
+		return
+	}
+	records = ss.visible(records)
+
+	workload := r.URL.Query().Get("workload")
+	var runs []history.Record
+	for i := len(records) - 1; i >= 0; i-- {
+		if workload == "" || records[i].Workload == workload {
+			runs = append(runs, records[i])
+		}
+	}
+	s.render(w, "index.html", struct {
+		Runs     []history.Record
+		Workload string
+	}{runs, workload})
+}
+
+// handleRun shows the metrics, tags and artifacts of a run
+func (s *Server) handleRun(w http.ResponseWriter, r *http.Request) {
+	uuid := strings.TrimPrefix(r.URL.Path, "/runs/")
+	ss, ok := s.begin(w, r)
+	if !ok {
+		return
+	}
+	records, ok := s.load(w)
+	if !ok {
+		return
+	}
+	record, found := history.Find(ss.visible(records), uuid)
+	if !found {
+		http.Error(w, fmt.Sprintf("run %s not found", uuid), http.StatusNotFound)
+		return
+	}
+
+	artifacts, err := s.listArtifacts(uuid)
+	if err != nil {
+		log.Printf("Warning: %v", err)
+	}
+	s.render(w, "run.html", runPage{Record: record, Metrics: sortedMetrics(record.Metrics), Artifacts: artifacts})
+}
+
+// handleCompare compares the metrics of two runs like `k8s-io compare`
+func (s *Server) handleCompare(w http.ResponseWriter, r *http.Request) {
+	ss, ok := s.begin(w, r)
+	if !ok {
+		return
+	}
+	records, ok := s.load(w)
+	if !ok {
+		return
+	}
+	records = ss.visible(records)
+
+	page := comparePage{Records: records}
+	baselineUUID, currentUUID := r.URL.Query().Get("baseline"), r.URL.Query().Get("run")
+	if baselineUUID != "" && currentUUID != "" {
+		var found bool
+		if page.Baseline, found = history.Find(records, baselineUUID); !found {
+			http.Error(w, fmt.Sprintf("run %s not found", baselineUUID), http.StatusNotFound)
+			return
+		}
+		if page.Current, found = history.Find(records, currentUUID); !found {
+			http.Error(w, fmt.Sprintf("run %s not found", currentUUID), http.StatusNotFound)
+			return
+		}
+		if page.Baseline.ConfigHash != page.Current.ConfigHash {
+			page.Warning = fmt.Sprintf("The runs have different config fingerprints (%s vs %s).", page.Baseline.ConfigHash, page.Current.ConfigHash)
+		}
+		page.Rows = compareRecords(page.Baseline, page.Current)
+	}
+	s.render(w, "compare.html", page)
+}
+
+// handleArtifact serves the files of a run for download
+func (s *Server) handleArtifact(w http.ResponseWriter, r *http.Request) {
+	uuid, file, ok := splitArtifactPath(strings.TrimPrefix(r.URL.Path, "/artifacts/"))
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	ss, ok := s.begin(w, r)
+	if !ok {
+		return
+	}
+	// Artifacts are only served with access to their run, which the history knows
+	if s.auth != nil {
+		records, ok := s.load(w)
+		if !ok {
+			return
+		}
+		if _, found := history.Find(ss.visible(records), uuid); !found {
+			http.NotFound(w, r)
+			return
+		}
+	}
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filepath.Base(file)))
+	http.ServeFile(w, r, filepath.Join(s.artifacts, uuid, file))
+}
+
+// handleAPIRuns lists the records as JSON, or appends an uploaded record
+func (s *Server) handleAPIRuns(w http.ResponseWriter, r *http.Request) {
+	ss, ok := s.begin(w, r)
+	if !ok {
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		records, ok := s.load(w)
+		if !ok {
+			return
+		}
+		writeJSON(w, ss.visible(records))
+	case http.MethodPost:
+		var record history.Record
+		if err := json.NewDecoder(io.LimitReader(r.Body, maxUploadSize)).Decode(&record); err != nil {
+			http.Error(w, fmt.Sprintf("invalid record: %v", err), http.StatusBadRequest)
+			return
+		}
+		if !validUUID(record.UUID) {
+			http.Error(w, "invalid record: missing or invalid uuid", http.StatusBadRequest)
+			return
+		}
+		if !ss.can(record.Namespace, AccessUpload) {
+			http.Error(w, fmt.Sprintf("%s may not upload runs of namespace %q", ss.user.Username, record.Namespace), http.StatusForbidden)
+			return
+		}
+		// The owner is whoever uploads the run, not what the record claims
+		if s.auth != nil {
+			record.Owner = ss.user.Username
+		}
+
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		records, ok := s.load(w)
+		if !ok {
+			return
+		}
+		// Uploads are retried, so a run already recorded is not added again
+		if _, found := history.Find(records, record.UUID); !found {
+			if err := s.store.Append(record); err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+		}
+		w.WriteHeader(http.StatusCreated)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleAPIRun returns a record as JSON, or stores an artifact uploaded to
+// /api/runs/<uuid>/artifacts/<path>
+func (s *Server) handleAPIRun(w http.ResponseWriter, r *http.Request) {
+	rest := strings.TrimPrefix(r.URL.Path, "/api/runs/")
+	uuid, file, isArtifact := strings.Cut(rest, "/artifacts/")
+	ss, ok := s.begin(w, r)
+	if !ok {
+		return
+	}
+
+	if !isArtifact {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		records, ok := s.load(w)
+		if !ok {
+			return
+		}
+		record, found := history.Find(ss.visible(records), uuid)
+		if !found {
+			http.Error(w, fmt.Sprintf("run %s not found", uuid), http.StatusNotFound)
+			return
+		}
+		writeJSON(w, record)
+		return
+	}
+
+	if r.Method != http.MethodPut {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	uuid, file, ok = splitArtifactPath(uuid + "/" + file)
+	if !ok {
+		http.Error(w, "invalid artifact path", http.StatusBadRequest)
+		return
+	}
+	// Only the owner of a run adds artifacts to it, so its record must be uploaded first
+	if s.auth != nil {
+		records, ok := s.load(w)
+		if !ok {
+			return
+		}
+		record, found := history.Find(records, uuid)
+		if !found {
+			http.Error(w, fmt.Sprintf("run %s not found, upload its record first", uuid), http.StatusNotFound)
+			return
+		}
+		if record.Owner != ss.user.Username || !ss.can(record.Namespace, AccessUpload) {
+			http.Error(w, fmt.Sprintf("%s may not upload artifacts of run %s owned by %s", ss.user.Username, uuid, record.Owner), http.StatusForbidden)
+			return
+		}
+	}
+	if err := s.saveArtifact(filepath.Join(s.artifacts, uuid, file), io.LimitReader(r.Body, maxUploadSize)); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusCreated)
+}
+
+// saveArtifact writes an uploaded artifact through a temporary file, so downloads never
+// see a partial file
+func (s *Server) saveArtifact(filename string, body io.Reader) error {
+	if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+		return fmt.Errorf("failed to create artifacts directory: %w", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(filename), ".upload-*")
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", filename, err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := io.Copy(tmp, body); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write %s: %w", filename, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write %s: %w", filename, err)
+	}
+	return os.Rename(tmp.Name(), filename)
+}
+
+// listArtifacts returns the files of a run relative to its artifacts directory
+func (s *Server) listArtifacts(uuid string) ([]string, error) {
+	dir := filepath.Join(s.artifacts, uuid)
+	var files []string
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) && path == dir {
+				return filepath.SkipDir
+			}
+			return err
+		}
+		if d.IsDir() || strings.HasPrefix(d.Name(), ".upload-") {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		files = append(files, filepath.ToSlash(rel))
+		return nil
+	})
+	if err != nil {
+		return files, fmt.Errorf("failed to list the artifacts of run %s: %w", uuid, err)
+	}
+	return files, nil
+}
+
+// session is an authenticated request with the access decisions made for it
+type session struct {
+	server   *Server
+	request  *http.Request
+	user     authenticationv1.UserInfo
+	decision map[string]bool // Access decisions by access and namespace
+}
+
+// begin authenticates a request, replying with 401 Unauthorized when it cannot be
+func (s *Server) begin(w http.ResponseWriter, r *http.Request) (*session, bool) {
+	ss := &session{server: s, request: r, decision: make(map[string]bool)}
+	if s.auth == nil {
+		return ss, true
+	}
+
+	user, err := s.auth.Authenticate(r)
+	if err != nil {
+		if err != errNoToken {
+			log.Printf("Warning: rejected request for %s: %v", r.URL.Path, err)
+		}
+		w.Header().Set("WWW-Authenticate", `Bearer realm="k8s-io results"`)
+		http.Error(w, "a valid Kubernetes bearer token is required", http.StatusUnauthorized)
+		return nil, false
+	}
+	ss.user = user
+	return ss, true
+}
+
+// can reports whether the session has the access to the runs of the namespace. Failed
+// reviews deny the access.
+func (ss *session) can(namespace, access string) bool {
+	if ss.server.auth == nil {
+		return true
+	}
+
+	key := access + "/" + namespace
+	allowed, ok := ss.decision[key]
+	if !ok {
+		var err error
+		allowed, err = ss.server.auth.Allowed(ss.request.Context(), ss.user, namespace, access)
+		if err != nil {
+			log.Printf("Warning: %v", err)
+		}
+		ss.decision[key] = allowed
+	}
+	return allowed
+}
+
+// visible returns the records the session may read
+func (ss *session) visible(records []history.Record) []history.Record {
+	if ss.server.auth == nil {
+		return records
+	}
+	var allowed []history.Record
+	for _, record := range records {
+		if ss.can(record.Namespace, AccessRead) {
+			allowed = append(allowed, record)
+		}
+	}
+	return allowed
+}
+
+// load loads the history, replying with an error when it cannot be read
+func (s *Server) load(w http.ResponseWriter) ([]history.Record, bool) {
+	records, err := s.store.Load()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return nil, false
+	}
+	return records, true
+}
+
+// render executes a page template
+func (s *Server) render(w http.ResponseWriter, name string, data interface{}) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := s.pages.ExecuteTemplate(w, name, data); err != nil {
+		log.Printf("Warning: failed to render %s: %v", name, err)
+	}
+}
+
+// writeJSON writes an indented JSON response
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		log.Printf("Warning: failed to write response: %v", err)
+	}
+}
+
+// compareRecords returns the metrics both runs have with the change of the current run
+func compareRecords(baseline, current history.Record) []comparison {
+	var rows []comparison
+	for _, metric := range sortedMetrics(current.Metrics) {
+		before, ok := baseline.Metrics[metric]
+		if !ok {
+			continue
+		}
+		after := current.Metrics[metric]
+		change := "-"
+		if before != 0 {
+			change = fmt.Sprintf("%+.1f%%", (after-before)/before*100)
+		}
+		rows = append(rows, comparison{Metric: metric, Baseline: before, Current: after, Change: change})
+	}
+	return rows
+}
+
+// sortedMetrics returns the metric names in order
+func sortedMetrics(metrics map[string]float64) []string {
+	names := make([]string, 0, len(metrics))
+	for name := range metrics {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// splitArtifactPath splits <uuid>/<file> and rejects paths leaving the run directory
+func splitArtifactPath(path string) (string, string, bool) {
+	uuid, file, ok := strings.Cut(path, "/")
+	if !ok || !validUUID(uuid) || file == "" {
+		return "", "", false
+	}
+	for _, part := range strings.Split(file, "/") {
+		if part == "" || part == "." || part == ".." {
+			return "", "", false
+		}
+	}
+	return uuid, filepath.FromSlash(file), true
+}
+
+// validUUID reports whether a run UUID can be used as a directory name
+func validUUID(uuid string) bool {
+	return uuid != "" && uuid != "." && uuid != ".." && !strings.ContainsAny(uuid, `/\`)
+}
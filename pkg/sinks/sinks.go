@@ -0,0 +1,208 @@
+package sinks
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jtaleric/k8s-io/pkg/config"
+	"github.com/jtaleric/k8s-io/pkg/elasticsearch"
+	"github.com/jtaleric/k8s-io/pkg/timeline"
+)
+
+// defaultTimeout is the time one send to a sink may take without timeout
+const defaultTimeout = 60 * time.Second
+
+// Sends that fail are retried sendAttempts times in all, waiting retryBackoff before the
+// first retry and twice as long before every next one
+const (
+	sendAttempts = 3
+	retryBackoff = 2 * time.Second
+)
+
+// Sink is a destination of the metrics and result documents of a run. Documents are sent
+// by index, the Elasticsearch index name of the documents.
+type Sink interface {
+	Send(ctx context.Context, index string, docs []interface{}) error
+}
+
+// Factory creates a sink of the run from its configuration
+type Factory func(cfg *config.Config, sink config.SinkConfig) (Sink, error)
+
+// factories are the sink types selectable with sinks[].type
+var factories = map[string]Factory{
+	config.SinkElasticsearch: newElasticsearchSink,
+	config.SinkS3:            newS3Sink,
+	config.SinkStdout:        func(*config.Config, config.SinkConfig) (Sink, error) { return stdoutSink{out: os.Stdout}, nil },
+}
+
+// Register makes a sink type selectable by name, so other destinations can be added without
+// changing the workloads
+func Register(name string, factory Factory) {
+	factories[name] = factory
+}
+
+// Destination is a sink of the run with its name and timeout
+type Destination struct {
+	Sink
+	Name    string
+	Timeout time.Duration
+}
+
+// New creates the sinks of a run. Without sinks, the documents go to the elasticsearch
+// section when it is configured.
+func New(cfg *config.Config) ([]Destination, error) {
+	configs := cfg.Sinks
+	if len(configs) == 0 && cfg.Elasticsearch != nil && cfg.Elasticsearch.URL != "" {
+		configs = []config.SinkConfig{{Type: config.SinkElasticsearch, Name: config.SinkElasticsearch}}
+	}
+
+	sinks := make([]Destination, 0, len(configs))
+	for _, c := range configs {
+		factory, ok := factories[c.Type]
+		if !ok {
+			names := make([]string, 0, len(factories))
+			for name := range factories {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			return nil, fmt.Errorf("unknown sink type %q, expected one of: %s", c.Type, strings.Join(names, ", "))
+		}
+		sink, err := factory(cfg, c)
+		if err != nil {
+			return nil, fmt.Errorf("sink %s: %w", c.Name, err)
+		}
+		timeout := defaultTimeout
+		if d, err := time.ParseDuration(c.Timeout); err == nil && d > 0 {
+			timeout = d
+		}
+		sinks = append(sinks, Destination{Sink: sink, Name: c.Name, Timeout: timeout})
+	}
+	return sinks, nil
+}
+
+// Publish sends the documents to every sink of the run at the same time. A sink that fails
+// does not stop the others, and after the retries its documents are spooled to the run
+// artifacts for flush-results. The error names the sinks that failed and their spool files.
+func Publish(ctx context.Context, cfg *config.Config, index string, docs []interface{}) error {
+	if len(docs) == 0 {
+		return nil
+	}
+	sinks, err := New(cfg)
+	if err != nil {
+		return err
+	}
+
+	errs := make([]error, len(sinks))
+	var wg sync.WaitGroup
+	for i, sink := range sinks {
+		wg.Add(1)
+		go func(i int, sink Destination) {
+			defer wg.Done()
+			err := send(ctx, sink, index, docs)
+			if err == nil {
+				log.Printf("Sent %d document(s) of %s to sink %s", len(docs), index, sink.Name)
+				return
+			}
+			filename, serr := spool(cfg.RunArtifactsDir(), sink.Name, index, docs)
+			if serr != nil {
+				errs[i] = fmt.Errorf("sink %s: %w; the documents are lost, %v", sink.Name, err, serr)
+				return
+			}
+			errs[i] = fmt.Errorf("sink %s: %w; %d document(s) spooled to %s, retry them with k8s-io flush-results", sink.Name, err, len(docs), filename)
+		}(i, sink)
+	}
+	wg.Wait()
+
+	failed := 0
+	for _, err := range errs {
+		if err != nil {
+			failed++
+		}
+	}
+	if failed == 0 {
+		return nil
+	}
+	return fmt.Errorf("%d of %d sinks failed to receive %s: %w", failed, len(sinks), index, errors.Join(errs...))
+}
+
+// send sends the documents to a sink, retrying failed attempts with backoff. Every attempt
+// has the timeout of the sink.
+func send(ctx context.Context, sink Destination, index string, docs []interface{}) error {
+	backoff := retryBackoff
+	for attempt := 1; ; attempt++ {
+		attemptCtx, cancel := context.WithTimeout(ctx, sink.Timeout)
+		err := sink.Send(attemptCtx, index, docs)
+		cancel()
+		if err == nil || attempt == sendAttempts {
+			return err
+		}
+
+		log.Printf("Warning: sink %s failed to receive %s (attempt %d of %d, will retry in %s): %v", sink.Name, index, attempt, sendAttempts, backoff, err)
+		timeline.Record(timeline.EventRetry, "sink "+sink.Name, "failed to receive %s: %v", index, err)
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+// elasticsearchSink indexes the documents with the bulk API
+type elasticsearchSink struct {
+	es *config.ElasticsearchConfig
+}
+
+func newElasticsearchSink(cfg *config.Config, sink config.SinkConfig) (Sink, error) {
+	es := &config.ElasticsearchConfig{URL: sink.URL, VerifyCert: sink.VerifyCert}
+	if cfg.Elasticsearch != nil {
+		if es.URL == "" {
+			es.URL = cfg.Elasticsearch.URL
+		}
+		if es.VerifyCert == nil {
+			es.VerifyCert = cfg.Elasticsearch.VerifyCert
+		}
+	}
+	if es.URL == "" {
+		return nil, fmt.Errorf("no Elasticsearch url")
+	}
+	return elasticsearchSink{es: es}, nil
+}
+
+func (s elasticsearchSink) Send(ctx context.Context, index string, docs []interface{}) error {
+	return elasticsearch.Bulk(ctx, s.es, index, docs)
+}
+
+// stdoutSink prints every document as a JSON line with its index, for pipelines that
+// collect the output of k8s-io
+type stdoutSink struct {
+	out io.Writer
+}
+
+// stdoutMu keeps the lines of documents sent at the same time apart
+var stdoutMu sync.Mutex
+
+func (s stdoutSink) Send(ctx context.Context, index string, docs []interface{}) error {
+	stdoutMu.Lock()
+	defer stdoutMu.Unlock()
+
+	enc := json.NewEncoder(s.out)
+	for _, doc := range docs {
+		if err := enc.Encode(struct {
+			Index    string      `json:"index"`
+			Document interface{} `json:"document"`
+		}{index, doc}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,150 @@
+package sinks
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/jtaleric/k8s-io/pkg/config"
+)
+
+// SpoolDir is the directory in the artifacts of a run holding the documents that a sink did
+// not receive, as <sink>/<index>.jsonl with one document per line
+const SpoolDir = "spool"
+
+// spoolMu keeps documents spooled at the same time from interleaving
+var spoolMu sync.Mutex
+
+// spool appends the documents a sink did not receive to the spool of the run and returns
+// the spool file
+func spool(runDir, sink, index string, docs []interface{}) (string, error) {
+	dir := filepath.Join(runDir, SpoolDir, sink)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create spool directory: %w", err)
+	}
+	filename := filepath.Join(dir, index+".jsonl")
+
+	spoolMu.Lock()
+	defer spoolMu.Unlock()
+	file, err := os.OpenFile(filename, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return "", fmt.Errorf("failed to open spool file: %w", err)
+	}
+	defer file.Close()
+
+	w := bufio.NewWriter(file)
+	enc := json.NewEncoder(w)
+	for _, doc := range docs {
+		if err := enc.Encode(doc); err != nil {
+			return "", fmt.Errorf("failed to spool document: %w", err)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		return "", fmt.Errorf("failed to write %s: %w", filename, err)
+	}
+	return filename, nil
+}
+
+// SpooledBatch is the documents of an index that a sink did not receive during a run
+type SpooledBatch struct {
+	Run   string
+	Sink  string
+	Index string
+	Path  string
+}
+
+// ListSpool returns the spooled batches of the runs in the artifacts directory, or of one
+// run when uuid is set, sorted by run, sink and index
+func ListSpool(artifactsDir, uuid string) ([]SpooledBatch, error) {
+	pattern := filepath.Join(artifactsDir, "*", SpoolDir, "*", "*.jsonl")
+	if uuid != "" {
+		pattern = filepath.Join(artifactsDir, uuid, SpoolDir, "*", "*.jsonl")
+	}
+	paths, err := filepath.Glob(pattern)
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(paths)
+
+	batches := make([]SpooledBatch, 0, len(paths))
+	for _, path := range paths {
+		sinkDir := filepath.Dir(path)
+		runDir := filepath.Dir(filepath.Dir(sinkDir))
+		batches = append(batches, SpooledBatch{
+			Run:   filepath.Base(runDir),
+			Sink:  filepath.Base(sinkDir),
+			Index: strings.TrimSuffix(filepath.Base(path), ".jsonl"),
+			Path:  path,
+		})
+	}
+	return batches, nil
+}
+
+// Documents reads the spooled documents of the batch
+func (b SpooledBatch) Documents() ([]interface{}, error) {
+	file, err := os.Open(b.Path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var docs []interface{}
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 64*1024*1024)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+		if !json.Valid(line) {
+			return nil, fmt.Errorf("invalid document in %s", b.Path)
+		}
+		docs = append(docs, json.RawMessage(append([]byte(nil), line...)))
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", b.Path, err)
+	}
+	return docs, nil
+}
+
+// Flush sends the spooled documents of the batch to its sink again, with the sink settings
+// of the configuration, and removes the batch once the sink received them
+func Flush(ctx context.Context, cfg *config.Config, b SpooledBatch) (int, error) {
+	// Sinks such as S3 key the documents by the run they belong to
+	runCfg := *cfg
+	runCfg.UUID = b.Run
+	destinations, err := New(&runCfg)
+	if err != nil {
+		return 0, err
+	}
+	var sink *Destination
+	for i := range destinations {
+		if destinations[i].Name == b.Sink {
+			sink = &destinations[i]
+		}
+	}
+	if sink == nil {
+		return 0, fmt.Errorf("the configuration has no sink named %s", b.Sink)
+	}
+
+	docs, err := b.Documents()
+	if err != nil {
+		return 0, err
+	}
+	if err := send(ctx, *sink, b.Index, docs); err != nil {
+		return 0, err
+	}
+	if err := os.Remove(b.Path); err != nil {
+		return len(docs), fmt.Errorf("sent, but failed to remove %s: %w", b.Path, err)
+	}
+	// Leave no empty spool directories behind
+	os.Remove(filepath.Dir(b.Path))
+	os.Remove(filepath.Dir(filepath.Dir(b.Path)))
+	return len(docs), nil
+}
@@ -0,0 +1,284 @@
+package soak
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/jtaleric/k8s-io/pkg/units"
+)
+
+// StateFile is the file in the artifacts directory of a soak test that records its
+// checkpoints, so an interrupted soak resumes where it stopped
+const StateFile = "soak.json"
+
+// LogDir is the directory in the artifacts directory of a soak test with the log of every
+// checkpoint
+const LogDir = "soak-logs"
+
+// Statuses of a checkpoint
+const (
+	StatusRunning = "running" // Started and not finished, interrupted when found on resume
+	StatusSuccess = "success"
+	StatusFailure = "failure"
+)
+
+// Checkpoint is one run of a soak test, with its own UUID, results and artifacts
+type Checkpoint struct {
+	Index   int                `json:"index"`
+	UUID    string             `json:"uuid"` // <uuid>-c<index>
+	Start   time.Time          `json:"start"`
+	End     *time.Time         `json:"end,omitempty"`
+	Status  string             `json:"status"`
+	Error   string             `json:"error,omitempty"`
+	Metrics map[string]float64 `json:"metrics,omitempty"` // Summarized metrics, see SummarizeMetrics
+}
+
+// State is the progress of a soak test, written to StateFile after every change
+type State struct {
+	UUID        string       `json:"uuid"`
+	Start       time.Time    `json:"start"`
+	Duration    string       `json:"duration"`           // Checkpoints are started until this long after start
+	Interval    string       `json:"interval,omitempty"` // Checkpoints start this far apart, back to back when empty
+	Checkpoints []Checkpoint `json:"checkpoints"`
+}
+
+// New returns the state of a soak test starting now
+func New(uuid string, duration, interval time.Duration, now time.Time) *State {
+	s := &State{UUID: uuid, Start: now.UTC(), Duration: duration.String()}
+	if interval > 0 {
+		s.Interval = interval.String()
+	}
+	return s
+}
+
+// Load reads the state of the soak test in dir, nil when it was never started
+func Load(dir string) (*State, error) {
+	filename := filepath.Join(dir, StateFile)
+	data, err := os.ReadFile(filename)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", filename, err)
+	}
+
+	var s State
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, fmt.Errorf("failed to decode %s: %w", filename, err)
+	}
+	if _, err := time.ParseDuration(s.Duration); err != nil {
+		return nil, fmt.Errorf("invalid duration in %s: %w", filename, err)
+	}
+	return &s, nil
+}
+
+// Save writes the state to StateFile in dir through a temporary file, so a soak test killed
+// while saving keeps its previous state
+func (s *State) Save(dir string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create artifacts directory: %w", err)
+	}
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode soak state: %w", err)
+	}
+
+	filename := filepath.Join(dir, StateFile)
+	tmp, err := os.CreateTemp(dir, ".soak-*")
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", filename, err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write %s: %w", filename, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write %s: %w", filename, err)
+	}
+	return os.Rename(tmp.Name(), filename)
+}
+
+// End returns when the last checkpoint may start
+func (s *State) End() time.Time {
+	d, _ := time.ParseDuration(s.Duration)
+	return s.Start.Add(d)
+}
+
+// Next returns the next checkpoint and when it is due. A checkpoint that is still running
+// was interrupted with the soak test and is returned to be resumed, with resume set. ok is
+// false once the duration of the soak test is over.
+func (s *State) Next(now time.Time) (cp Checkpoint, due time.Time, resume, ok bool) {
+	if n := len(s.Checkpoints); n > 0 && s.Checkpoints[n-1].Status == StatusRunning {
+		return s.Checkpoints[n-1], now, true, true
+	}
+
+	index := len(s.Checkpoints) + 1
+	due = now
+	if interval, _ := time.ParseDuration(s.Interval); interval > 0 {
+		// A checkpoint that ran longer than the interval delays the next one, it is not skipped
+		if scheduled := s.Start.Add(time.Duration(index-1) * interval); scheduled.After(now) {
+			due = scheduled
+		}
+	}
+	if !due.Before(s.End()) {
+		return Checkpoint{}, time.Time{}, false, false
+	}
+	return Checkpoint{Index: index, UUID: fmt.Sprintf("%s-c%04d", s.UUID, index)}, due, false, true
+}
+
+// Started records the start of a new checkpoint; an interrupted one keeps its start
+func (s *State) Started(cp Checkpoint, now time.Time) {
+	if n := len(s.Checkpoints); n > 0 && s.Checkpoints[n-1].Index == cp.Index {
+		return
+	}
+	cp.Start = now.UTC()
+	cp.Status = StatusRunning
+	s.Checkpoints = append(s.Checkpoints, cp)
+}
+
+// Finished records the outcome of the running checkpoint
+func (s *State) Finished(metrics map[string]float64, err error, now time.Time) {
+	n := len(s.Checkpoints)
+	if n == 0 {
+		return
+	}
+	cp := &s.Checkpoints[n-1]
+	end := now.UTC()
+	cp.End = &end
+	cp.Metrics = metrics
+	cp.Status = StatusSuccess
+	if err != nil {
+		cp.Status = StatusFailure
+		cp.Error = err.Error()
+	}
+}
+
+// Failures returns the number of failed checkpoints, and of those that failed in a row at the end
+func (s *State) Failures() (total, consecutive int) {
+	for _, cp := range s.Checkpoints {
+		if cp.Status == StatusFailure {
+			total++
+			consecutive++
+		} else {
+			consecutive = 0
+		}
+	}
+	return total, consecutive
+}
+
+// OpenLog creates the log file of a checkpoint in the LogDir of dir and removes the oldest
+// checkpoint logs beyond keep, so a soak test of days does not fill the disk. A resumed
+// checkpoint appends to its log.
+func OpenLog(dir string, index, keep int) (*os.File, error) {
+	logDir := filepath.Join(dir, LogDir)
+	if err := os.MkdirAll(logDir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create %s: %w", logDir, err)
+	}
+	filename := filepath.Join(logDir, fmt.Sprintf("checkpoint-%04d.log", index))
+	file, err := os.OpenFile(filename, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", filename, err)
+	}
+
+	if keep > 0 {
+		logs, _ := filepath.Glob(filepath.Join(logDir, "checkpoint-*.log"))
+		sort.Strings(logs)
+		for len(logs) > keep {
+			if err := os.Remove(logs[0]); err != nil {
+				file.Close()
+				return nil, fmt.Errorf("failed to rotate %s: %w", logs[0], err)
+			}
+			logs = logs[1:]
+		}
+	}
+	return file, nil
+}
+
+// Drift is the change of a summarized metric from the first to the last successful checkpoint
+type Drift struct {
+	Metric string
+	First  float64
+	Last   float64
+	Min    float64
+	Max    float64
+	Change float64 // Percent of the first value
+}
+
+// Drifts returns the drift of every metric over the successful checkpoints, sorted by metric.
+// Storage that degrades during burn-in shows as throughput that drops or latency that grows.
+func (s *State) Drifts() []Drift {
+	var ok []Checkpoint
+	for _, cp := range s.Checkpoints {
+		if cp.Status == StatusSuccess && len(cp.Metrics) > 0 {
+			ok = append(ok, cp)
+		}
+	}
+	if len(ok) == 0 {
+		return nil
+	}
+
+	var drifts []Drift
+	for metric, first := range ok[0].Metrics {
+		d := Drift{Metric: metric, First: first, Last: first, Min: first, Max: first}
+		for _, cp := range ok[1:] {
+			value, found := cp.Metrics[metric]
+			if !found {
+				continue
+			}
+			d.Last = value
+			if value < d.Min {
+				d.Min = value
+			}
+			if value > d.Max {
+				d.Max = value
+			}
+		}
+		if first != 0 {
+			d.Change = (d.Last - first) / first * 100
+		}
+		drifts = append(drifts, d)
+	}
+	sort.Slice(drifts, func(i, j int) bool { return drifts[i].Metric < drifts[j].Metric })
+	return drifts
+}
+
+// PrintSummary prints the checkpoints of the soak test and the drift of their metrics
+func (s *State) PrintSummary(out io.Writer, mode units.Mode) {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "Checkpoint\tRun\tStart\tDuration\tStatus")
+	for _, cp := range s.Checkpoints {
+		duration := "-"
+		if cp.End != nil {
+			duration = cp.End.Sub(cp.Start).Round(time.Second).String()
+		}
+		result := cp.Status
+		if cp.Error != "" {
+			result += ": " + strings.SplitN(cp.Error, "\n", 2)[0]
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\n", cp.Index, cp.UUID, cp.Start.Format(time.RFC3339), duration, result)
+	}
+	w.Flush()
+
+	drifts := s.Drifts()
+	if len(drifts) == 0 {
+		return
+	}
+	fmt.Fprintln(out)
+	w = tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "Metric\tFirst\tLast\tMin\tMax\tChange")
+	for _, d := range drifts {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%+.1f%%\n", d.Metric,
+			units.Metric(d.Metric, d.First, mode), units.Metric(d.Metric, d.Last, mode),
+			units.Metric(d.Metric, d.Min, mode), units.Metric(d.Metric, d.Max, mode), d.Change)
+	}
+	w.Flush()
+}
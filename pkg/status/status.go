@@ -0,0 +1,128 @@
+package status
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Reason is a machine-readable failure reason
+type Reason string
+
+const (
+	ReasonNone      Reason = ""
+	ReasonConfig    Reason = "config_error"
+	ReasonPreflight Reason = "preflight_failure"
+	ReasonDeploy    Reason = "deploy_failure"
+	ReasonBenchmark Reason = "benchmark_failure"
+	ReasonThreshold Reason = "threshold_regression"
+)
+
+// Process exit codes for each failure reason
+const (
+	ExitSuccess   = 0
+	ExitConfig    = 2
+	ExitPreflight = 3
+	ExitDeploy    = 4
+	ExitBenchmark = 5
+	ExitThreshold = 6
+)
+
+// Error is an error tagged with a failure reason
+type Error struct {
+	Reason Reason
+	Err    error
+}
+
+// Error returns the underlying error message
+func (e *Error) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap returns the underlying error
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Errorf creates an error tagged with the given failure reason
+func Errorf(reason Reason, format string, args ...interface{}) error {
+	return &Error{Reason: reason, Err: fmt.Errorf(format, args...)}
+}
+
+// ReasonOf returns the failure reason of an error, defaulting to a benchmark failure
+func ReasonOf(err error) Reason {
+	if err == nil {
+		return ReasonNone
+	}
+
+	var statusErr *Error
+	if errors.As(err, &statusErr) {
+		return statusErr.Reason
+	}
+
+	return ReasonBenchmark
+}
+
+// ExitCode returns the process exit code for a failure reason
+func ExitCode(reason Reason) int {
+	switch reason {
+	case ReasonNone:
+		return ExitSuccess
+	case ReasonConfig:
+		return ExitConfig
+	case ReasonPreflight:
+		return ExitPreflight
+	case ReasonDeploy:
+		return ExitDeploy
+	case ReasonThreshold:
+		return ExitThreshold
+	default:
+		return ExitBenchmark
+	}
+}
+
+// Status is the final machine-readable status of a run
+type Status struct {
+	Status   string   `json:"status"` // "success" or "failure"
+	Reason   Reason   `json:"reason,omitempty"`
+	ExitCode int      `json:"exit_code"`
+	Message  string   `json:"message,omitempty"`
+	UUID     string   `json:"uuid,omitempty"`
+	Workload string   `json:"workload,omitempty"`
+	Owner    string   `json:"owner,omitempty"` // User requesting the run
+	Start    string   `json:"start,omitempty"` // Run start in RFC3339 UTC
+	End      string   `json:"end,omitempty"`   // Run end in RFC3339 UTC
+	Warnings []string `json:"warnings,omitempty"`
+
+	// Metrics of the run as recorded in the history, such as randread-4KiB-1.read_iops
+	Metrics map[string]float64 `json:"metrics,omitempty"`
+}
+
+// New creates the status for a run that ended with the given error
+func New(err error) Status {
+	reason := ReasonOf(err)
+
+	s := Status{
+		Status:   "success",
+		Reason:   reason,
+		ExitCode: ExitCode(reason),
+	}
+	if err != nil {
+		s.Status = "failure"
+		s.Message = err.Error()
+	}
+
+	return s
+}
+
+// Write writes the status as a single JSON line
+func (s Status) Write(w io.Writer) error {
+	data, err := json.Marshal(s)
+	if err != nil {
+		return fmt.Errorf("failed to marshal status: %w", err)
+	}
+
+	_, err = fmt.Fprintln(w, string(data))
+	return err
+}
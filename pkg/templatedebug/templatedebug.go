@@ -0,0 +1,120 @@
+package templatedebug
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// sensitiveKey matches context keys whose values are redacted
+var sensitiveKey = regexp.MustCompile(`(?i)token|password|passwd|secret|credential|apikey|api_key`)
+
+// Dumper writes the context and the template text of every rendered manifest to a directory
+type Dumper struct {
+	dir   string
+	mu    sync.Mutex
+	count int
+}
+
+// NewDumper creates a dumper writing to dir
+func NewDumper(dir string) *Dumper {
+	return &Dumper{dir: dir}
+}
+
+// Dump writes the redacted context, the original and preprocessed template text and the
+// rendered output or error of one render. Failures are logged and never fail the render.
+func (d *Dumper) Dump(name, source, processed string, context map[string]interface{}, rendered string, renderErr error) {
+	if d == nil {
+		return
+	}
+
+	d.mu.Lock()
+	d.count++
+	prefix := filepath.Join(d.dir, fmt.Sprintf("%03d-%s", d.count, sanitize(name)))
+	d.mu.Unlock()
+
+	if err := os.MkdirAll(d.dir, 0755); err != nil {
+		log.Printf("Warning: failed to create template debug directory: %v", err)
+		return
+	}
+
+	contextJSON, err := json.MarshalIndent(Redact(context), "", "  ")
+	if err != nil {
+		contextJSON = []byte(fmt.Sprintf("failed to marshal context: %v", err))
+	}
+
+	output := rendered
+	if renderErr != nil {
+		output = "ERROR: " + renderErr.Error() + "\n"
+	}
+
+	files := map[string]string{
+		prefix + ".context.json": string(contextJSON),
+		prefix + ".source.j2":    source,
+		prefix + ".pongo2":       processed,
+		prefix + ".rendered":     output,
+	}
+	for filename, content := range files {
+		if err := os.WriteFile(filename, []byte(content), 0644); err != nil {
+			log.Printf("Warning: failed to write %s: %v", filename, err)
+		}
+	}
+}
+
+// Redact returns a copy of the context with sensitive values and URL credentials replaced
+func Redact(context map[string]interface{}) interface{} {
+	// Round-trip through JSON to walk structs and maps alike
+	data, err := json.Marshal(context)
+	if err != nil {
+		return map[string]string{"error": err.Error()}
+	}
+	var generic interface{}
+	if err := json.Unmarshal(data, &generic); err != nil {
+		return map[string]string{"error": err.Error()}
+	}
+
+	return redactValue("", generic)
+}
+
+// redactValue redacts a value found under the given key
+func redactValue(key string, value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for k, child := range v {
+			v[k] = redactValue(k, child)
+		}
+		return v
+	case []interface{}:
+		for i, child := range v {
+			v[i] = redactValue(key, child)
+		}
+		return v
+	case string:
+		if v != "" && sensitiveKey.MatchString(key) {
+			return "REDACTED"
+		}
+		if u, err := url.Parse(v); err == nil && u.User != nil {
+			u.User = url.User("REDACTED")
+			return u.String()
+		}
+		return v
+	default:
+		return v
+	}
+}
+
+// sanitize turns a template name into a file name
+func sanitize(name string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '/' || r == ' ' {
+			return '-'
+		}
+		return r
+	}, name)
+}
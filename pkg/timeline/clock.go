@@ -0,0 +1,74 @@
+package timeline
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Clock is the run clock metadata: when the run started and ended in UTC, and the
+// timezones of the machine running k8s-io and of the cluster nodes. Results record the
+// run start in UTC instead of the local time, so runs from different machines line up.
+type Clock struct {
+	Start         time.Time         `json:"start"`
+	End           time.Time         `json:"end,omitempty"`
+	LocalTimezone string            `json:"localTimezone"`           // Timezone of the machine running k8s-io
+	NodeTimezones map[string]string `json:"nodeTimezones,omitempty"` // Timezone of every node, when node-exporter reports it
+}
+
+// NewClock starts the clock of a run
+func NewClock() *Clock {
+	now := time.Now()
+	return &Clock{Start: now.UTC(), LocalTimezone: Timezone(now)}
+}
+
+// Stop records the end of the run, once
+func (c *Clock) Stop() {
+	if c != nil && c.End.IsZero() {
+		c.End = time.Now().UTC()
+	}
+}
+
+// Metadata returns the run start, end and local timezone as RFC3339 document metadata
+func (c *Clock) Metadata() map[string]string {
+	if c == nil {
+		return nil
+	}
+	metadata := map[string]string{
+		"runStart":      FormatUTC(c.Start),
+		"localTimezone": c.LocalTimezone,
+	}
+	if !c.End.IsZero() {
+		metadata["runEnd"] = FormatUTC(c.End)
+	}
+	return metadata
+}
+
+// Write writes the clock to clock.json in the run artifacts directory
+func (c *Clock) Write(dir string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create artifacts directory: %w", err)
+	}
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode run clock: %w", err)
+	}
+	filename := filepath.Join(dir, "clock.json")
+	if err := os.WriteFile(filename, data, 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", filename, err)
+	}
+	return nil
+}
+
+// FormatUTC formats a time as RFC3339 in UTC, the format of every timestamp k8s-io records
+func FormatUTC(t time.Time) string {
+	return t.UTC().Format(time.RFC3339)
+}
+
+// Timezone returns the name and UTC offset of the timezone of t, such as "CEST (+02:00)"
+func Timezone(t time.Time) string {
+	name, _ := t.Zone()
+	return fmt.Sprintf("%s (%s)", name, t.Format("-07:00"))
+}
@@ -0,0 +1,126 @@
+package timeline
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Kinds of the events of a run
+const (
+	EventPhaseStart = "phase-start"
+	EventPhaseEnd   = "phase-end"
+	EventApply      = "apply"      // A manifest was created or updated
+	EventWait       = "wait"       // A wait for resources was satisfied
+	EventRetry      = "retry"      // A failed operation or sample is retried
+	EventCacheDrop  = "cache-drop" // Kernel or Ceph caches were dropped before a sample
+	EventFailure    = "failure"    // The run failed
+)
+
+// EventsFile is the event log in the artifacts directory of a run, one JSON event per line
+const EventsFile = "events.jsonl"
+
+// Event is something that happened during a run
+type Event struct {
+	Time    time.Time `json:"time"`
+	Kind    string    `json:"kind"`
+	Subject string    `json:"subject"` // Phase, resource or server the event is about
+	Message string    `json:"message,omitempty"`
+}
+
+// events is the event log of the run of the process. Events are written as they are
+// recorded, so the log of a run that crashes is complete up to the crash.
+var events struct {
+	mu   sync.Mutex
+	file *os.File
+}
+
+// OpenEvents starts writing the events of the run to events.jsonl in its artifacts directory
+func OpenEvents(dir string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create artifacts directory: %w", err)
+	}
+	file, err := os.OpenFile(filepath.Join(dir, EventsFile), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open the event log: %w", err)
+	}
+
+	events.mu.Lock()
+	defer events.mu.Unlock()
+	if events.file != nil {
+		events.file.Close()
+	}
+	events.file = file
+	return nil
+}
+
+// CloseEvents stops writing the event log
+func CloseEvents() {
+	events.mu.Lock()
+	defer events.mu.Unlock()
+	if events.file != nil {
+		events.file.Close()
+		events.file = nil
+	}
+}
+
+// Record records an event that happens now. Nothing is recorded before OpenEvents, such as
+// during dry runs.
+func Record(kind, subject, format string, args ...interface{}) {
+	RecordAt(time.Now(), kind, subject, fmt.Sprintf(format, args...))
+}
+
+// RecordAt records an event that was timed elsewhere, such as on the benchmark client
+func RecordAt(t time.Time, kind, subject, message string) {
+	data, err := json.Marshal(Event{Time: t.UTC(), Kind: kind, Subject: subject, Message: message})
+	if err != nil {
+		return
+	}
+
+	events.mu.Lock()
+	defer events.mu.Unlock()
+	if events.file == nil {
+		return
+	}
+	if _, err := events.file.Write(append(data, '\n')); err != nil {
+		log.Printf("Warning: failed to write the event log, it stops here: %v", err)
+		events.file.Close()
+		events.file = nil
+	}
+}
+
+// ReadEvents reads the event log of a run from its artifacts directory, sorted by time
+func ReadEvents(dir string) ([]Event, error) {
+	filename := filepath.Join(dir, EventsFile)
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open the event log: %w", err)
+	}
+	defer file.Close()
+
+	var list []Event
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var event Event
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			return nil, fmt.Errorf("invalid event on line %d of %s: %w", line, filename, err)
+		}
+		list = append(list, event)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", filename, err)
+	}
+
+	sort.SliceStable(list, func(i, j int) bool { return list[i].Time.Before(list[j].Time) })
+	return list, nil
+}
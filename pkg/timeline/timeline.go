@@ -0,0 +1,117 @@
+package timeline
+
+import (
+	"log"
+	"math"
+	"sync"
+	"time"
+)
+
+// Phase is a benchmark phase with its exact start and end time
+type Phase struct {
+	Name  string    `json:"name"`
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+	Error string    `json:"error,omitempty"`
+}
+
+// Duration returns how long the phase took
+func (p Phase) Duration() time.Duration {
+	return p.End.Sub(p.Start)
+}
+
+// Timeline records the phases of a benchmark run
+type Timeline struct {
+	Phases []Phase
+
+	// The running phase and whether the run finished, read by Progress while the run goes on
+	mu       sync.Mutex
+	current  Phase
+	finished bool
+}
+
+// Track runs fn as the named phase and records its start and end time
+func (t *Timeline) Track(name string, fn func() error) error {
+	phase := Phase{Name: name, Start: time.Now().UTC()}
+	t.mu.Lock()
+	t.current = phase
+	t.mu.Unlock()
+	RecordAt(phase.Start, EventPhaseStart, name, "")
+
+	err := fn()
+	phase.End = time.Now().UTC()
+	if err != nil {
+		phase.Error = err.Error()
+	}
+	recordPhaseEnd(phase)
+
+	t.mu.Lock()
+	t.Phases = append(t.Phases, phase)
+	t.current = Phase{}
+	t.mu.Unlock()
+	return err
+}
+
+// Add records a phase that was timed elsewhere, such as on the benchmark client
+func (t *Timeline) Add(phase Phase) {
+	t.mu.Lock()
+	t.Phases = append(t.Phases, phase)
+	t.mu.Unlock()
+	RecordAt(phase.Start, EventPhaseStart, phase.Name, "")
+	recordPhaseEnd(phase)
+}
+
+// recordPhaseEnd records the end of a phase in the event log with its duration and error
+func recordPhaseEnd(phase Phase) {
+	message := phase.Duration().Round(time.Second).String()
+	if phase.Error != "" {
+		message = "failed after " + message + ": " + phase.Error
+	}
+	RecordAt(phase.End, EventPhaseEnd, phase.Name, message)
+}
+
+// Finish marks the run as finished, so Progress reports it complete
+func (t *Timeline) Finish() {
+	t.mu.Lock()
+	t.finished = true
+	t.mu.Unlock()
+}
+
+// Progress returns the running phase, or the last phase between phases, and the percentage
+// of the expected duration elapsed since the first phase started. The percentage stays
+// below 100 until the run finished, since runs often take longer than expected.
+func (t *Timeline) Progress(expected time.Duration) (string, float64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	name, start := t.current.Name, t.current.Start
+	if len(t.Phases) > 0 {
+		start = t.Phases[0].Start
+		if name == "" {
+			name = t.Phases[len(t.Phases)-1].Name
+		}
+	}
+	switch {
+	case t.finished:
+		return name, 100
+	case start.IsZero() || expected <= 0:
+		return name, 0
+	}
+	return name, math.Min(99, 100*float64(time.Since(start))/float64(expected))
+}
+
+// Log prints the recorded phase windows
+func (t *Timeline) Log() {
+	if len(t.Phases) == 0 {
+		return
+	}
+
+	log.Println("Benchmark phases:")
+	for _, p := range t.Phases {
+		status := "ok"
+		if p.Error != "" {
+			status = "failed"
+		}
+		log.Printf("  %-16s %s - %s (%s, %s)", p.Name, p.Start.Format(time.RFC3339), p.End.Format(time.RFC3339), p.Duration().Round(time.Second), status)
+	}
+}
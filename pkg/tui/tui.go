@@ -0,0 +1,218 @@
+package tui
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/jtaleric/k8s-io/pkg/kubernetes"
+	"github.com/jtaleric/k8s-io/pkg/units"
+	"github.com/jtaleric/k8s-io/pkg/workloads/fio"
+)
+
+// maxLineWidth truncates log lines so a refresh fits the terminal
+const maxLineWidth = 160
+
+// Options selects the run shown and how often it is refreshed
+type Options struct {
+	Namespace string
+	UUID      string // Newest run in the namespace when empty
+	Interval  time.Duration
+	LogLines  int64
+	Units     units.Mode // How the results show bandwidth and latency
+}
+
+// jobPhases maps the job name prefixes of the workloads to their benchmark phase
+var jobPhases = []struct {
+	marker string
+	phase  string
+}{
+	{"fio-check-", "server-check"},
+	{"fio-prefill-", "prefill"},
+	{"fio-client-", "benchmark"},
+	{"-creator-", "db-init"},
+	{"-workload-", "benchmark"},
+}
+
+// Run redraws the run status every interval until the context is cancelled
+func Run(ctx context.Context, k8sClient *kubernetes.Client, opts Options) error {
+	ticker := time.NewTicker(opts.Interval)
+	defer ticker.Stop()
+
+	for {
+		var screen bytes.Buffer
+		if err := render(ctx, &screen, k8sClient, opts); err != nil {
+			fmt.Fprintf(&screen, "\nError: %v\n", err)
+		}
+
+		// Clear the terminal and draw the whole screen at once to avoid flicker
+		fmt.Fprint(os.Stdout, "\033[H\033[2J")
+		os.Stdout.Write(screen.Bytes())
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// render writes one snapshot of the run: phase, pods, client log tail and results so far
+func render(ctx context.Context, w io.Writer, k8sClient *kubernetes.Client, opts Options) error {
+	fmt.Fprintf(w, "k8s-io  namespace %s  %s  (Ctrl-C to quit)\n\n", opts.Namespace, time.Now().Format("15:04:05"))
+
+	pods, err := k8sClient.ListPods(ctx, opts.Namespace, "benchmark-uuid")
+	if err != nil {
+		return fmt.Errorf("failed to list benchmark pods: %w", err)
+	}
+
+	uuid := opts.UUID
+	if uuid == "" {
+		uuid = newestRun(pods.Items)
+	}
+	var run []corev1.Pod
+	for _, pod := range pods.Items {
+		if pod.Labels["benchmark-uuid"] == uuid {
+			run = append(run, pod)
+		}
+	}
+	if len(run) == 0 {
+		fmt.Fprintln(w, "No benchmark pods found")
+		return nil
+	}
+	sort.Slice(run, func(i, j int) bool { return run[i].CreationTimestamp.Before(&run[j].CreationTimestamp) })
+
+	phase, active := currentPhase(run)
+	fmt.Fprintf(w, "Run:   %s\nPhase: %s\n", uuid, phase)
+	if active != nil && active.Status.Phase == corev1.PodRunning {
+		if job, err := k8sClient.GetJob(ctx, active.Labels["job-name"], opts.Namespace); err == nil {
+			if eta, ok := kubernetes.JobETA(job); ok {
+				fmt.Fprintf(w, "ETA:   %s\n", kubernetes.FormatETA(eta))
+			}
+		}
+	}
+	fmt.Fprintln(w)
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "POD\tPHASE\tNODE\tAGE\tRESTARTS\tREASON")
+	for _, pod := range run {
+		restarts := int32(0)
+		for _, cs := range pod.Status.ContainerStatuses {
+			restarts += cs.RestartCount
+		}
+		reason := ""
+		if failure := kubernetes.ClassifyPod(pod); failure.Environmental || pod.Status.Phase == corev1.PodFailed {
+			reason = failure.Reason
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%d\t%s\n", pod.Name, pod.Status.Phase, pod.Spec.NodeName,
+			time.Since(pod.CreationTimestamp.Time).Round(time.Second), restarts, reason)
+	}
+	tw.Flush()
+
+	if active == nil {
+		return nil
+	}
+
+	tail, err := k8sClient.GetPodLogTail(ctx, opts.Namespace, active.Name, "", opts.LogLines)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "\n--- %s ---\n", active.Name)
+	for _, line := range strings.Split(strings.TrimRight(tail, "\n"), "\n") {
+		if len(line) > maxLineWidth {
+			line = line[:maxLineWidth] + "..."
+		}
+		fmt.Fprintln(w, line)
+	}
+
+	if strings.HasPrefix(active.Labels["job-name"], "fio-client-") {
+		return renderFIOResults(ctx, w, k8sClient, opts.Namespace, active.Name, opts.Units)
+	}
+	return nil
+}
+
+// renderFIOResults prints the samples the FIO client has finished so far
+func renderFIOResults(ctx context.Context, w io.Writer, k8sClient *kubernetes.Client, namespace, pod string, mode units.Mode) error {
+	stream, err := k8sClient.GetPodLogs(ctx, namespace, pod, "")
+	if err != nil {
+		return fmt.Errorf("failed to get logs for pod %s: %w", pod, err)
+	}
+	defer stream.Close()
+
+	logs, err := io.ReadAll(stream)
+	if err != nil {
+		return fmt.Errorf("failed to read logs for pod %s: %w", pod, err)
+	}
+
+	results, err := fio.ParseFIOResults(string(logs))
+	if err != nil {
+		return err
+	}
+	summaries := fio.ExtractResultSummaries(results, "")
+
+	fmt.Fprintf(w, "\n--- Results (%d so far) ---\n", len(summaries))
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "SAMPLE\tJOB\tHOSTNAME\tREAD IOPS\tWRITE IOPS\t%s\t%s\t%s\t%s\n",
+		units.BandwidthHeader("READ BW", mode), units.BandwidthHeader("WRITE BW", mode),
+		units.LatencyHeader("READ P95", mode), units.LatencyHeader("WRITE P95", mode))
+	for _, s := range summaries {
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n", s.Sample, s.JobName, s.Hostname,
+			units.IOPS(s.ReadIOPS, mode), units.IOPS(s.WriteIOPS, mode),
+			units.Bandwidth(float64(s.ReadBW), mode), units.Bandwidth(float64(s.WriteBW), mode),
+			units.Latency(s.ReadLatP95, mode), units.Latency(s.WriteLatP95, mode))
+	}
+	return tw.Flush()
+}
+
+// newestRun returns the UUID of the most recently created benchmark pod
+func newestRun(pods []corev1.Pod) string {
+	var uuid string
+	var newest time.Time
+	for _, pod := range pods {
+		if pod.CreationTimestamp.Time.After(newest) {
+			newest = pod.CreationTimestamp.Time
+			uuid = pod.Labels["benchmark-uuid"]
+		}
+	}
+	return uuid
+}
+
+// currentPhase derives the benchmark phase from the newest job pod of the run, which is
+// also the pod whose log is tailed
+func currentPhase(pods []corev1.Pod) (string, *corev1.Pod) {
+	var active *corev1.Pod
+	for i := range pods {
+		if pods[i].Labels["job-name"] != "" {
+			active = &pods[i]
+		}
+	}
+	if active == nil {
+		return "deploy", nil
+	}
+
+	phase := "unknown"
+	for _, jp := range jobPhases {
+		if strings.Contains(active.Labels["job-name"], jp.marker) {
+			phase = jp.phase
+			break
+		}
+	}
+
+	switch active.Status.Phase {
+	case corev1.PodSucceeded:
+		phase += " (completed)"
+	case corev1.PodFailed:
+		phase += " (failed)"
+	case corev1.PodPending:
+		phase += " (pending)"
+	}
+	return phase, active
+}
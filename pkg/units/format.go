@@ -0,0 +1,98 @@
+package units
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Format is the rounding policy of the values in tables and reports. Machine-readable
+// outputs, such as the CSV export, history and results sinks, do not use it and keep the
+// full precision.
+type Format struct {
+	SignificantFigures int    // Round to this many significant figures instead of the fixed decimals of a column, 0 to keep them
+	ThousandsSeparator string // Between groups of three integer digits, empty for none
+}
+
+// ThousandsSeparators are the supported separators between groups of three digits. The
+// decimal point is always a dot, so a dot cannot separate thousands.
+var ThousandsSeparators = []string{",", "'", "_", " "}
+
+// format is the policy of the tables, set once at startup by SetFormat
+var format Format
+
+// SetFormat sets the rounding policy of the tables and reports
+func SetFormat(f Format) {
+	format = f
+}
+
+// ValidateFormat checks a rounding policy
+func ValidateFormat(f Format) error {
+	if f.SignificantFigures < 0 || f.SignificantFigures > 15 {
+		return fmt.Errorf("significant_figures must be 0 (full precision) or 1 to 15, got %d", f.SignificantFigures)
+	}
+	if f.ThousandsSeparator == "" {
+		return nil
+	}
+	for _, sep := range ThousandsSeparators {
+		if f.ThousandsSeparator == sep {
+			return nil
+		}
+	}
+	return fmt.Errorf("thousands_separator must be one of %q, got %q", ThousandsSeparators, f.ThousandsSeparator)
+}
+
+// Number formats a value for a table with the given decimals, or rounded to the significant
+// figures of the policy, and groups its thousands. With significant figures, small values
+// like the latencies of NVMe devices keep their digits instead of rounding to 0.0.
+func Number(value float64, decimals int) string {
+	if math.IsNaN(value) || math.IsInf(value, 0) {
+		return strconv.FormatFloat(value, 'f', -1, 64)
+	}
+	if sig := format.SignificantFigures; sig > 0 && value != 0 {
+		// Rounding through the exponent form handles values that gain a digit, such as
+		// 9.996 becoming 10.0 with three figures
+		value, _ = strconv.ParseFloat(strconv.FormatFloat(value, 'e', sig-1, 64), 64)
+		exponent := int(math.Floor(math.Log10(math.Abs(value))))
+		decimals = sig - 1 - exponent
+		if decimals < 0 {
+			decimals = 0
+		}
+	}
+	return groupThousands(strconv.FormatFloat(value, 'f', decimals, 64))
+}
+
+// groupThousands inserts the thousands separator of the policy into a formatted number
+func groupThousands(s string) string {
+	sep := format.ThousandsSeparator
+	if sep == "" {
+		return s
+	}
+	sign := ""
+	if strings.HasPrefix(s, "-") {
+		sign, s = "-", s[1:]
+	}
+	integer, fraction := s, ""
+	if i := strings.IndexByte(s, '.'); i >= 0 {
+		integer, fraction = s[:i], s[i:]
+	}
+	if len(integer) <= 3 {
+		return sign + s
+	}
+
+	var b strings.Builder
+	b.WriteString(sign)
+	head := len(integer) % 3
+	if head > 0 {
+		b.WriteString(integer[:head])
+	}
+	for i := head; i < len(integer); i += 3 {
+		if i > 0 {
+			b.WriteString(sep)
+		}
+		b.WriteString(integer[i : i+3])
+	}
+	b.WriteString(fraction)
+	return b.String()
+}
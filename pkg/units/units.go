@@ -0,0 +1,113 @@
+package units
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Mode selects how values are shown in tables
+type Mode string
+
+const (
+	// Raw shows plain numbers in fixed units named in the column headers: IOPS, MiB/s and usec
+	Raw Mode = "raw"
+	// Human scales every value and adds its unit, such as 1.2 GiB/s or 850 usec
+	Human Mode = "human"
+)
+
+// ParseMode returns the mode of a -units flag or units setting
+func ParseMode(s string) (Mode, error) {
+	switch Mode(s) {
+	case Raw, Human:
+		return Mode(s), nil
+	case "":
+		return Raw, nil
+	}
+	return "", fmt.Errorf("units must be either 'raw' or 'human'")
+}
+
+// KiBToMiB converts a bandwidth in KiB/s, as fio reports it, to MiB/s
+func KiBToMiB(kib float64) float64 {
+	return kib / 1024
+}
+
+// NsToUsec converts a latency in nanoseconds, as fio reports it, to microseconds
+func NsToUsec(ns float64) float64 {
+	return ns / 1000
+}
+
+// BandwidthHeader returns the column header of a bandwidth
+func BandwidthHeader(name string, mode Mode) string {
+	if mode == Human {
+		return name
+	}
+	return name + " (MiB/s)"
+}
+
+// LatencyHeader returns the column header of a latency
+func LatencyHeader(name string, mode Mode) string {
+	if mode == Human {
+		return name
+	}
+	return name + " (usec)"
+}
+
+// Bandwidth formats a bandwidth given in KiB/s
+func Bandwidth(kib float64, mode Mode) string {
+	if mode != Human {
+		return Number(KiBToMiB(kib), 1)
+	}
+	value, unit := kib, "KiB/s"
+	for _, next := range []string{"MiB/s", "GiB/s", "TiB/s"} {
+		if value < 1024 {
+			break
+		}
+		value, unit = value/1024, next
+	}
+	return Number(value, 1) + " " + unit
+}
+
+// Latency formats a latency given in microseconds
+func Latency(usec float64, mode Mode) string {
+	if mode != Human {
+		return Number(usec, 1)
+	}
+	switch {
+	case usec >= 1000000:
+		return Number(usec/1000000, 2) + " sec"
+	case usec >= 1000:
+		return Number(usec/1000, 2) + " msec"
+	}
+	return Number(usec, 1) + " usec"
+}
+
+// IOPS formats operations per second
+func IOPS(iops float64, mode Mode) string {
+	if mode != Human {
+		return Number(iops, 1)
+	}
+	switch {
+	case iops >= 1000000:
+		return Number(iops/1000000, 2) + "M"
+	case iops >= 1000:
+		return Number(iops/1000, 1) + "k"
+	}
+	return Number(iops, 1)
+}
+
+// Metric formats a summarized metric. Raw values are printed as they are, since the metric
+// names end with their unit; human values are scaled by that suffix: _kbs for KiB/s, _us
+// for microseconds and _iops.
+func Metric(name string, value float64, mode Mode) string {
+	if mode == Human {
+		switch {
+		case strings.HasSuffix(name, "_kbs"):
+			return Bandwidth(value, mode)
+		case strings.HasSuffix(name, "_us"):
+			return Latency(value, mode)
+		case strings.HasSuffix(name, "_iops"):
+			return IOPS(value, mode)
+		}
+	}
+	return Number(value, 2)
+}
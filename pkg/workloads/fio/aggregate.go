@@ -0,0 +1,198 @@
+package fio
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/jtaleric/k8s-io/pkg/sinks"
+	"github.com/jtaleric/k8s-io/pkg/timeline"
+	"github.com/jtaleric/k8s-io/pkg/units"
+)
+
+// ClusterThroughput is the throughput of a sample of a job summed across the servers of the
+// cluster, or of one node when Node is set
+type ClusterThroughput struct {
+	Job       string  `json:"job"` // <job>-<bs>-<numjobs>, like the history metrics
+	Sample    int     `json:"sample"`
+	Node      string  `json:"node,omitempty"`
+	Servers   int     `json:"servers"`
+	ReadIOPS  float64 `json:"readIOPS"`
+	ReadBW    int     `json:"readBW"` // KiB/s
+	WriteIOPS float64 `json:"writeIOPS"`
+	WriteBW   int     `json:"writeBW"` // KiB/s
+}
+
+// AggregateThroughput sums the results of every sample of every job across the servers,
+// returning the cluster total of each sample followed by its node subtotals. Results
+// without a node count as their own node.
+func AggregateThroughput(summaries []ResultSummary) []ClusterThroughput {
+	type sampleKey struct {
+		job    string
+		sample int
+	}
+
+	totals := make(map[sampleKey]*ClusterThroughput)
+	nodes := make(map[sampleKey]map[string]*ClusterThroughput)
+	servers := make(map[sampleKey]map[string]bool)
+	nodeServers := make(map[sampleKey]map[string]map[string]bool)
+	var order []sampleKey
+
+	for _, summary := range summaries {
+		key := sampleKey{
+			job:    fmt.Sprintf("%s-%s-%d", summary.JobName, summary.BlockSize, summary.NumJobs),
+			sample: summary.Sample,
+		}
+		if _, ok := totals[key]; !ok {
+			totals[key] = &ClusterThroughput{Job: key.job, Sample: key.sample}
+			nodes[key] = make(map[string]*ClusterThroughput)
+			servers[key] = make(map[string]bool)
+			nodeServers[key] = make(map[string]map[string]bool)
+			order = append(order, key)
+		}
+
+		node := summary.Node
+		if node == "" {
+			node = summary.Hostname
+		}
+		subtotal, ok := nodes[key][node]
+		if !ok {
+			subtotal = &ClusterThroughput{Job: key.job, Sample: key.sample, Node: node}
+			nodes[key][node] = subtotal
+			nodeServers[key][node] = make(map[string]bool)
+		}
+
+		// Servers testing several volumes report a row per volume
+		servers[key][summary.Hostname] = true
+		nodeServers[key][node][summary.Hostname] = true
+		for _, t := range []*ClusterThroughput{totals[key], subtotal} {
+			t.ReadIOPS += summary.ReadIOPS
+			t.ReadBW += summary.ReadBW
+			t.WriteIOPS += summary.WriteIOPS
+			t.WriteBW += summary.WriteBW
+		}
+	}
+
+	var aggregates []ClusterThroughput
+	for _, key := range order {
+		total := totals[key]
+		total.Servers = len(servers[key])
+		aggregates = append(aggregates, *total)
+
+		names := make([]string, 0, len(nodes[key]))
+		for name := range nodes[key] {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			subtotal := nodes[key][name]
+			subtotal.Servers = len(nodeServers[key][name])
+			aggregates = append(aggregates, *subtotal)
+		}
+	}
+	return aggregates
+}
+
+// PrintClusterThroughput prints the cluster total of every sample, with the node subtotals
+// when the servers ran on more than one node
+func PrintClusterThroughput(aggregates []ClusterThroughput, mode units.Mode) {
+	if len(aggregates) == 0 {
+		return
+	}
+
+	nodes := make(map[string]bool)
+	for _, a := range aggregates {
+		if a.Node != "" {
+			nodes[a.Node] = true
+		}
+	}
+	perNode := len(nodes) > 1
+
+	columns := []string{"Job", "Sample", "Node", "Servers",
+		"Read IOPS", units.BandwidthHeader("Read BW", mode), "Write IOPS", units.BandwidthHeader("Write BW", mode)}
+	rules := make([]string, len(columns))
+	for i, column := range columns {
+		rules[i] = strings.Repeat("-", len([]rune(column)))
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "=== Cluster Throughput ===\n")
+	fmt.Fprintln(w, strings.Join(columns, "\t"))
+	fmt.Fprintln(w, strings.Join(rules, "\t"))
+	for _, a := range aggregates {
+		node := a.Node
+		if node == "" {
+			node = "(all)"
+		} else if !perNode {
+			continue
+		}
+		fmt.Fprintf(w, "%s\t%d\t%s\t%d\t%s\t%s\t%s\t%s\n",
+			a.Job,
+			a.Sample,
+			node,
+			a.Servers,
+			units.IOPS(a.ReadIOPS, mode),
+			units.Bandwidth(float64(a.ReadBW), mode),
+			units.IOPS(a.WriteIOPS, mode),
+			units.Bandwidth(float64(a.WriteBW), mode),
+		)
+	}
+	w.Flush()
+	fmt.Println()
+}
+
+// clusterThroughputDocument is the Elasticsearch document of a cluster total or node
+// subtotal
+type clusterThroughputDocument struct {
+	UUID        string            `json:"uuid"`
+	User        string            `json:"user"`
+	ClusterName string            `json:"clustername"`
+	RunStart    string            `json:"runStart"`
+	Metadata    map[string]string `json:"metadata,omitempty"`
+	ClusterThroughput
+}
+
+// publishClusterThroughput prints the cluster throughput of the results, writes it to
+// cluster-throughput.json in the run artifacts directory and sends it to the sinks of the
+// run as <index_name>-cluster
+func (w *Workload) publishClusterThroughput(ctx context.Context) error {
+	aggregates := AggregateThroughput(w.summaries)
+	if len(aggregates) == 0 {
+		return nil
+	}
+	PrintClusterThroughput(aggregates, units.Mode(w.config.Units))
+
+	dir := w.config.RunArtifactsDir()
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create artifacts directory: %w", err)
+	}
+	data, err := json.MarshalIndent(aggregates, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(dir, "cluster-throughput.json"), data, 0644); err != nil {
+		return fmt.Errorf("failed to write cluster throughput: %w", err)
+	}
+
+	docs := make([]interface{}, 0, len(aggregates))
+	for _, a := range aggregates {
+		docs = append(docs, clusterThroughputDocument{
+			UUID:              w.config.UUID,
+			User:              w.config.TestUser,
+			ClusterName:       w.config.ClusterName,
+			RunStart:          timeline.FormatUTC(w.runStart()),
+			Metadata:          w.config.Tags,
+			ClusterThroughput: a,
+		})
+	}
+	index := w.config.Elasticsearch.IndexPrefix("fio") + "-cluster"
+	if err := sinks.Publish(ctx, w.config, index, docs); err != nil {
+		return fmt.Errorf("failed to publish cluster throughput: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,181 @@
+package fio
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/jtaleric/k8s-io/pkg/units"
+)
+
+// biolatencyScript returns the bpftrace program printing per-device block I/O latency
+// histograms in microseconds every interval seconds
+func biolatencyScript(interval int) string {
+	return fmt.Sprintf(`tracepoint:block:block_rq_issue { @start[args->dev, args->sector] = nsecs; }
+tracepoint:block:block_rq_complete /@start[args->dev, args->sector]/ {
+  @usecs[args->dev] = hist((nsecs - @start[args->dev, args->sector]) / 1000);
+  delete(@start[args->dev, args->sector]);
+}
+interval:s:%d { print(@usecs); clear(@usecs); }
+END { clear(@start); }
+`, interval)
+}
+
+// HistogramBucket is a power-of-two latency bucket in microseconds
+type HistogramBucket struct {
+	Low   float64
+	High  float64
+	Count int64
+}
+
+// LatencyHistogram is the kernel block I/O latency histogram of a device
+type LatencyHistogram struct {
+	Node    string
+	Device  string // major:minor
+	Buckets map[float64]*HistogramBucket
+}
+
+var (
+	histHeader = regexp.MustCompile(`^@usecs\[(\d+)\]:`)
+	histBucket = regexp.MustCompile(`^\[([\d.]+[KMG]?), ([\d.]+[KMG]?)\)\s+(\d+)`)
+)
+
+// ParseBiolatency merges the histograms printed by the biolatency program at every interval
+func ParseBiolatency(node, output string) []*LatencyHistogram {
+	histograms := make(map[string]*LatencyHistogram)
+	var current *LatencyHistogram
+
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+
+		if m := histHeader.FindStringSubmatch(line); m != nil {
+			dev, _ := strconv.ParseUint(m[1], 10, 64)
+			// Kernel-internal dev_t: 12 bits major, 20 bits minor
+			device := fmt.Sprintf("%d:%d", dev>>20, dev&0xfffff)
+			if histograms[device] == nil {
+				histograms[device] = &LatencyHistogram{Node: node, Device: device, Buckets: make(map[float64]*HistogramBucket)}
+			}
+			current = histograms[device]
+			continue
+		}
+
+		m := histBucket.FindStringSubmatch(line)
+		if m == nil || current == nil {
+			continue
+		}
+		low, high := parseHistValue(m[1]), parseHistValue(m[2])
+		count, _ := strconv.ParseInt(m[3], 10, 64)
+
+		bucket := current.Buckets[low]
+		if bucket == nil {
+			bucket = &HistogramBucket{Low: low, High: high}
+			current.Buckets[low] = bucket
+		}
+		bucket.Count += count
+	}
+
+	result := make([]*LatencyHistogram, 0, len(histograms))
+	for _, h := range histograms {
+		result = append(result, h)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Device < result[j].Device })
+
+	return result
+}
+
+// parseHistValue parses a bpftrace bucket bound such as 512, 1K or 2M
+func parseHistValue(s string) float64 {
+	multiplier := 1.0
+	switch {
+	case strings.HasSuffix(s, "K"):
+		multiplier = 1024
+	case strings.HasSuffix(s, "M"):
+		multiplier = 1024 * 1024
+	case strings.HasSuffix(s, "G"):
+		multiplier = 1024 * 1024 * 1024
+	}
+	v, _ := strconv.ParseFloat(strings.TrimRight(s, "KMG"), 64)
+	return v * multiplier
+}
+
+// Count returns the number of I/Os in the histogram
+func (h *LatencyHistogram) Count() int64 {
+	var total int64
+	for _, b := range h.Buckets {
+		total += b.Count
+	}
+	return total
+}
+
+// Percentile returns the upper bound of the bucket containing the given percentile
+func (h *LatencyHistogram) Percentile(p float64) float64 {
+	lows := make([]float64, 0, len(h.Buckets))
+	for low := range h.Buckets {
+		lows = append(lows, low)
+	}
+	sort.Float64s(lows)
+
+	target := float64(h.Count()) * p / 100
+	var seen int64
+	for _, low := range lows {
+		seen += h.Buckets[low].Count
+		if float64(seen) >= target {
+			return h.Buckets[low].High
+		}
+	}
+	return 0
+}
+
+// reportBlockLatency prints the kernel block latency of every device next to the
+// latency reported by fio, to show how much latency is added above the block layer
+func (w *Workload) reportBlockLatency(dir string) error {
+	files, err := filepath.Glob(filepath.Join(dir, "*-biolatency.log"))
+	if err != nil {
+		return err
+	}
+
+	var histograms []*LatencyHistogram
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return err
+		}
+		node := strings.TrimSuffix(filepath.Base(file), "-biolatency.log")
+		histograms = append(histograms, ParseBiolatency(node, string(data))...)
+	}
+	if len(histograms) == 0 {
+		return nil
+	}
+
+	// Slowest P95 latency reported by fio across all jobs
+	var fioReadP95, fioWriteP95 float64
+	for _, s := range w.summaries {
+		fioReadP95 = max(fioReadP95, s.ReadLatP95)
+		fioWriteP95 = max(fioWriteP95, s.WriteLatP95)
+	}
+
+	fmt.Println("\n=== Block Layer Latency (eBPF) ===")
+	fmt.Printf("FIO P95 latency: read %s usec, write %s usec\n\n", units.Number(fioReadP95, 1), units.Number(fioWriteP95, 1))
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "Node\tDevice\tI/Os\tP50 (usec)\tP95 (usec)\tP99 (usec)\tFIO P95 - Block P95 (usec)")
+	for _, h := range histograms {
+		if h.Count() == 0 {
+			continue
+		}
+		p95 := h.Percentile(95)
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%s\t%s\t%s\t%s\n", h.Node, h.Device, h.Count(),
+			units.Number(h.Percentile(50), 0), units.Number(p95, 0), units.Number(h.Percentile(99), 0), units.Number(max(fioReadP95, fioWriteP95)-p95, 0))
+	}
+	tw.Flush()
+	fmt.Println("\nBlock latencies are bucket upper bounds. A large difference points at latency added above the block layer (network, CSI, filesystem).")
+
+	return nil
+}
@@ -0,0 +1,198 @@
+package fio
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"gopkg.in/yaml.v3"
+	"k8s.io/apimachinery/pkg/api/resource"
+
+	"github.com/jtaleric/k8s-io/pkg/config"
+	"github.com/jtaleric/k8s-io/pkg/units"
+)
+
+// CacheArm is one run of a page cache study
+type CacheArm struct {
+	Name   string                 `json:"name"`             // direct, buffered, or buffered-<memory>
+	UUID   string                 `json:"uuid"`             // Run of the arm, <uuid>-<name>
+	Direct bool                   `json:"direct"`           // O_DIRECT reads bypassing the page cache
+	Memory string                 `json:"memory,omitempty"` // Memory limit of the server pods, empty for none
+	Args   map[string]interface{} `json:"-"`
+}
+
+// CacheStudyArgs returns the runs of a page cache study of the read profile of a
+// configuration: with O_DIRECT, buffered, and buffered with every memory limit on the server
+// pods. The page cache of a pod counts against its memory limit, so a limit smaller than the
+// files evicts what read-ahead brought in. The pods of the limited runs get cpu as their CPU
+// unless the configuration sets server_cpu.
+func CacheStudyArgs(args interface{}, jobParams []config.JobParam, memoryLimits []string, cpu string) ([]CacheArm, error) {
+	base, ok := args.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("workload args must be a mapping")
+	}
+
+	data, err := yaml.Marshal(base)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal FIO args: %w", err)
+	}
+	var f FIOConfig
+	if err := yaml.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal FIO config: %w", err)
+	}
+	f.SetDefaults()
+
+	if f.Kind != "pod" {
+		return nil, fmt.Errorf("the page cache of VMs belongs to the guest, the study requires kind 'pod'")
+	}
+	if f.PVCVolumeMode == "Block" {
+		return nil, fmt.Errorf("raw block devices only take direct I/O, the study requires a filesystem volume")
+	}
+	if f.VolumeType == VolumeTypeMemory {
+		return nil, fmt.Errorf("volume_type 'memory' is the page cache itself, test a volume backed by storage")
+	}
+	for _, c := range f.Matrix(jobParams) {
+		if c.RW != "read" && c.RW != "randread" {
+			return nil, fmt.Errorf("the study compares reads, job %s runs %s", c.Job, c.RW)
+		}
+	}
+	if len(memoryLimits) > 0 {
+		if f.CPUPinning != nil {
+			return nil, fmt.Errorf("memory limits are set with server_memory, which cannot be combined with cpu_pinning")
+		}
+		if f.ServerMemory != "" {
+			return nil, fmt.Errorf("server_memory is set by the memory limits of the study, remove it")
+		}
+		if f.ServerCPU != "" {
+			cpu = f.ServerCPU
+		}
+		if _, err := resource.ParseQuantity(cpu); err != nil {
+			return nil, fmt.Errorf("invalid server CPU %q: %w", cpu, err)
+		}
+	}
+
+	arm := func(name string, direct bool, memory string) CacheArm {
+		a := CacheArm{Name: name, Direct: direct, Memory: memory, Args: make(map[string]interface{}, len(base)+3)}
+		for k, v := range base {
+			a.Args[k] = v
+		}
+		a.Args["direct"] = direct
+		if memory != "" {
+			a.Args["server_cpu"] = cpu
+			a.Args["server_memory"] = memory
+		}
+		return a
+	}
+
+	arms := []CacheArm{arm("direct", true, ""), arm("buffered", false, "")}
+	for _, limit := range memoryLimits {
+		q, err := resource.ParseQuantity(limit)
+		if err != nil {
+			return nil, fmt.Errorf("invalid memory limit %q: %w", limit, err)
+		}
+		arms = append(arms, arm("buffered-"+strings.ToLower(q.String()), false, q.String()))
+	}
+	return arms, nil
+}
+
+// CacheEffect is a summarized metric of every run of the study, see SummarizeMetrics
+type CacheEffect struct {
+	Metric string             `json:"metric"`
+	Values map[string]float64 `json:"values"` // By arm
+	Gains  map[string]float64 `json:"gains"`  // By buffered arm, percent over the direct run
+}
+
+// CacheEffects compares the summarized metrics of the buffered runs with the direct run. A
+// positive gain is always better for the buffered run: more IOPS and bandwidth, or less latency.
+func CacheEffects(arms []CacheArm, metrics map[string]map[string]float64) []CacheEffect {
+	if len(arms) == 0 {
+		return nil
+	}
+	direct := metrics[arms[0].Name]
+
+	var effects []CacheEffect
+	for metric, before := range direct {
+		// Read-only profiles report no writes
+		if strings.HasPrefix(metric[strings.LastIndex(metric, ".")+1:], "write_") {
+			continue
+		}
+		effect := CacheEffect{Metric: metric, Values: map[string]float64{arms[0].Name: before}, Gains: make(map[string]float64)}
+		for _, arm := range arms[1:] {
+			after, ok := metrics[arm.Name][metric]
+			if !ok {
+				continue
+			}
+			effect.Values[arm.Name] = after
+			if before != 0 {
+				gain := (after - before) / before * 100
+				if strings.HasSuffix(metric, "_us") {
+					gain = -gain
+				}
+				effect.Gains[arm.Name] = gain
+			}
+		}
+		effects = append(effects, effect)
+	}
+	sort.Slice(effects, func(i, j int) bool { return effects[i].Metric < effects[j].Metric })
+	return effects
+}
+
+// PrintCacheEffectTable prints the metrics of every run with the gain of the buffered runs
+func PrintCacheEffectTable(out io.Writer, arms []CacheArm, effects []CacheEffect, mode units.Mode) {
+	if len(effects) == 0 {
+		fmt.Fprintln(out, "No read metrics were captured by the direct run")
+		return
+	}
+
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	header := []string{"Metric"}
+	for _, arm := range arms {
+		header = append(header, arm.Name)
+	}
+	fmt.Fprintln(w, strings.Join(header, "\t"))
+	for _, e := range effects {
+		row := []string{e.Metric}
+		for _, arm := range arms {
+			value, ok := e.Values[arm.Name]
+			if !ok {
+				row = append(row, "-")
+				continue
+			}
+			cell := units.Metric(e.Metric, value, mode)
+			if gain, ok := e.Gains[arm.Name]; ok {
+				cell += fmt.Sprintf(" (%+.1f%%)", gain)
+			}
+			row = append(row, cell)
+		}
+		fmt.Fprintln(w, strings.Join(row, "\t"))
+	}
+	w.Flush()
+}
+
+// CacheStudyReport is the cache-study.json artifact of a study
+type CacheStudyReport struct {
+	UUID    string        `json:"uuid"`
+	Arms    []CacheArm    `json:"arms"`
+	Effects []CacheEffect `json:"effects"`
+}
+
+// Write writes the report to cache-study.json in dir
+func (r *CacheStudyReport) Write(dir string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create artifacts directory: %w", err)
+	}
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode cache study report: %w", err)
+	}
+	filename := filepath.Join(dir, "cache-study.json")
+	if err := os.WriteFile(filename, data, 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", filename, err)
+	}
+	return nil
+}
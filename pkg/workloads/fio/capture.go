@@ -0,0 +1,114 @@
+package fio
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// startNodeCapture deploys the capture DaemonSet on the nodes hosting the FIO servers
+func (w *Workload) startNodeCapture(ctx context.Context) error {
+	nodeSet := make(map[string]bool)
+	for _, node := range w.podDetails {
+		nodeSet[node] = true
+	}
+	nodes := make([]string, 0, len(nodeSet))
+	for node := range nodeSet {
+		nodes = append(nodes, node)
+	}
+	sort.Strings(nodes)
+
+	if w.fioConfig.NodeCapture.UsesBPF() {
+		script, err := w.templateEngine.RenderFIONodeCaptureScript(w.config, w.fioConfig)
+		if err != nil {
+			return fmt.Errorf("failed to render bpftrace scripts: %w", err)
+		}
+		if err := w.k8sClient.ApplyManifest(ctx, script, w.config.Namespace); err != nil {
+			return fmt.Errorf("failed to apply bpftrace scripts: %w", err)
+		}
+	}
+
+	daemonSet, err := w.templateEngine.RenderFIONodeCapture(w.config, w.fioConfig, nodes)
+	if err != nil {
+		return fmt.Errorf("failed to render node capture: %w", err)
+	}
+	if err := w.k8sClient.ApplyManifest(ctx, daemonSet, w.config.Namespace); err != nil {
+		return fmt.Errorf("failed to apply node capture: %w", err)
+	}
+
+	labelSelector := fmt.Sprintf("app=fio-node-capture-%s", w.config.GetTruncatedUUID())
+	if err := w.k8sClient.WaitForPodsReady(ctx, w.config.Namespace, labelSelector, len(nodes), 5*time.Minute); err != nil {
+		return fmt.Errorf("failed to wait for node capture pods: %w", err)
+	}
+
+	log.Printf("Capturing %v on %d nodes", w.fioConfig.NodeCapture.Tools, len(nodes))
+	return nil
+}
+
+// collectNodeCapture writes the output of every capture tool into the artifacts directory
+// and removes the capture DaemonSet
+func (w *Workload) collectNodeCapture(ctx context.Context) error {
+	name := fmt.Sprintf("fio-node-capture-%s", w.config.GetTruncatedUUID())
+	defer func() {
+		if err := w.k8sClient.DeleteResource(ctx, "DaemonSet", name, w.config.Namespace); err != nil {
+			log.Printf("Warning: failed to delete node capture: %v", err)
+		}
+		if w.fioConfig.NodeCapture.UsesBPF() {
+			if err := w.k8sClient.DeleteResource(ctx, "ConfigMap", name, w.config.Namespace); err != nil {
+				log.Printf("Warning: failed to delete bpftrace scripts: %v", err)
+			}
+		}
+	}()
+
+	dir := filepath.Join(w.config.RunArtifactsDir(), "node-capture")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create %s: %w", dir, err)
+	}
+
+	pods, err := w.k8sClient.ListPods(ctx, w.config.Namespace, "app="+name)
+	if err != nil {
+		return fmt.Errorf("failed to list node capture pods: %w", err)
+	}
+
+	for _, pod := range pods.Items {
+		for _, tool := range w.fioConfig.NodeCapture.Tools {
+			filename := filepath.Join(dir, fmt.Sprintf("%s-%s.log", pod.Spec.NodeName, tool))
+			if err := w.saveContainerLogs(ctx, pod.Name, tool, filename); err != nil {
+				log.Printf("Warning: failed to collect %s output from %s: %v", tool, pod.Spec.NodeName, err)
+			}
+		}
+	}
+
+	log.Printf("Node capture output written to %s", dir)
+
+	if w.fioConfig.NodeCapture.Uses("biolatency") {
+		if err := w.reportBlockLatency(dir); err != nil {
+			log.Printf("Warning: failed to report block latency: %v", err)
+		}
+	}
+
+	return nil
+}
+
+// saveContainerLogs writes the logs of a container to a file
+func (w *Workload) saveContainerLogs(ctx context.Context, podName, container, filename string) error {
+	logs, err := w.k8sClient.GetPodLogs(ctx, w.config.Namespace, podName, container)
+	if err != nil {
+		return err
+	}
+	defer logs.Close()
+
+	file, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	_, err = io.Copy(file, logs)
+	return err
+}
@@ -0,0 +1,103 @@
+package fio
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/jtaleric/k8s-io/pkg/prometheus"
+	"github.com/jtaleric/k8s-io/pkg/timeline"
+	"github.com/jtaleric/k8s-io/pkg/units"
+)
+
+// cephLatencyQuery returns the average OSD op latency in seconds over the window, per OSD
+func cephLatencyQuery(op string, osds []string, window time.Duration) string {
+	selector := ""
+	if len(osds) > 0 {
+		selector = fmt.Sprintf(`{ceph_daemon=~"%s"}`, strings.Join(osds, "|"))
+	}
+	w := fmt.Sprintf("%ds", int(window.Seconds()))
+
+	return fmt.Sprintf("sum by (ceph_daemon) (increase(ceph_osd_op_%s_latency_sum%s[%s])) / sum by (ceph_daemon) (increase(ceph_osd_op_%s_latency_count%s[%s]))",
+		op, selector, w, op, selector, w)
+}
+
+// reportCephLatency prints the OSD-side read and write latency during the benchmark
+// window next to the latency seen by the FIO clients
+func (w *Workload) reportCephLatency(ctx context.Context, phase timeline.Phase) error {
+	promInfo, err := w.k8sClient.DiscoverPrometheusWithConfig(ctx, w.config.Prometheus)
+	if err != nil {
+		return err
+	}
+	if !promInfo.Found {
+		return fmt.Errorf("Prometheus not found")
+	}
+	prom := prometheus.NewClient(promInfo.URL, promInfo.Token)
+
+	window := phase.Duration().Round(time.Second)
+	if window < time.Minute {
+		window = time.Minute
+	}
+
+	latencies := make(map[string][2]float64) // OSD -> read, write latency in usec
+	for i, op := range []string{"r", "w"} {
+		samples, err := prom.QueryAt(ctx, cephLatencyQuery(op, w.fioConfig.CephLatency.OSDs, window), phase.End)
+		if err != nil {
+			return err
+		}
+		for _, s := range samples {
+			lat := latencies[s.Labels["ceph_daemon"]]
+			lat[i] = s.Value * 1e6
+			latencies[s.Labels["ceph_daemon"]] = lat
+		}
+	}
+	if len(latencies) == 0 {
+		return fmt.Errorf("no ceph_osd_op latency metrics found, is the Ceph mgr prometheus module enabled?")
+	}
+
+	// Median latency seen by the FIO clients, averaged across jobs
+	var fioRead, fioWrite float64
+	var reads, writes int
+	for _, s := range w.summaries {
+		if s.ReadIOPS > 0 {
+			fioRead += s.ReadLatP50
+			reads++
+		}
+		if s.WriteIOPS > 0 {
+			fioWrite += s.WriteLatP50
+			writes++
+		}
+	}
+	if reads > 0 {
+		fioRead /= float64(reads)
+	}
+	if writes > 0 {
+		fioWrite /= float64(writes)
+	}
+
+	osds := make([]string, 0, len(latencies))
+	for osd := range latencies {
+		osds = append(osds, osd)
+	}
+	sort.Strings(osds)
+
+	fmt.Println("\n=== Ceph OSD Latency ===")
+	fmt.Printf("Window: %s - %s\n", phase.Start.Format(time.RFC3339), phase.End.Format(time.RFC3339))
+	fmt.Printf("FIO client P50 latency: read %s usec, write %s usec\n\n", units.Number(fioRead, 1), units.Number(fioWrite, 1))
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "OSD\tRead (usec)\tWrite (usec)\tRead Delta (usec)\tWrite Delta (usec)")
+	for _, osd := range osds {
+		lat := latencies[osd]
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", osd, units.Number(lat[0], 1), units.Number(lat[1], 1),
+			units.Number(fioRead-lat[0], 1), units.Number(fioWrite-lat[1], 1))
+	}
+	tw.Flush()
+	fmt.Println("\nOSD latencies are averages over the window. The delta is the latency added between the client and the OSD (network, librbd/krbd, CSI).")
+
+	return nil
+}
@@ -0,0 +1,101 @@
+package fio
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+)
+
+// clientErrorPatterns match fio errors in the client log, which also relays the output of
+// the servers, with the misconfiguration that usually causes them. The first match of a
+// line wins, so specific patterns come before generic ones.
+var clientErrorPatterns = []struct {
+	pattern *regexp.Regexp
+	cause   string
+}{
+	{
+		regexp.MustCompile(`(?i)connection refused|failed to connect|no route to host|connection timed out`),
+		"the client cannot reach a FIO server on port 8765: check that the fio-server pods are running, and that no NetworkPolicy or service mesh sidecar blocks the port (see service_mesh)",
+	},
+	{
+		regexp.MustCompile(`(?i)permission denied.*/dev/|/dev/.*permission denied`),
+		"the server cannot open the raw block device: pvcvolumemode Block needs a volume the fio-server user may open, check the storage class and fio_path",
+	},
+	{
+		regexp.MustCompile(`(?i)permission denied|read-only file system`),
+		"the server cannot write fio_path: check fio_path, the volume mount and the fsGroup or ownership of the volume",
+	},
+	{
+		regexp.MustCompile(`(?i)no space left on device`),
+		"the volume is full: storagesize must hold filesize for every numjobs process, increase storagesize or reduce filesize",
+	},
+	{
+		regexp.MustCompile(`(?i)too small|exceeds.*size|size.*exceeds`),
+		"the file or device is smaller than the job needs: filesize must exceed the largest block size and fit on the volume, check filesize, bs and storagesize",
+	},
+	{
+		regexp.MustCompile(`(?i)o_direct|support direct|func=open.*invalid argument`),
+		"the filesystem does not support direct I/O (such as tmpfs): set direct: false or test a volume that supports O_DIRECT",
+	},
+	{
+		regexp.MustCompile(`(?i)(failed to load|not loadable).*engine|engine.*(not loadable|not supported)|io_uring.*(not permitted|not implemented)`),
+		"the ioengine is not available: the image or node kernel does not support it, or the container runtime blocks it (io_uring is often blocked by seccomp), try ioengine: libaio",
+	},
+}
+
+// ClientError is a fio error found in the client log with its probable misconfiguration
+type ClientError struct {
+	Line  string // Log line the error was reported in
+	Cause string // Probable misconfiguration
+}
+
+// String returns the error and its probable misconfiguration
+func (e ClientError) String() string {
+	return fmt.Sprintf("fio reported %q, probably %s", e.Line, e.Cause)
+}
+
+// DetectClientErrors returns the known fio errors in a client log, the first occurrence of
+// every cause in log order
+func DetectClientErrors(logOutput string) []ClientError {
+	var found []ClientError
+	seen := make(map[string]bool)
+
+	scanner := bufio.NewScanner(strings.NewReader(logOutput))
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		// Only fio and snafu report errors, result JSON and job files are skipped
+		if !strings.Contains(strings.ToLower(line), "fio") && !strings.Contains(strings.ToLower(line), "error") {
+			continue
+		}
+
+		for _, p := range clientErrorPatterns {
+			if !p.pattern.MatchString(line) {
+				continue
+			}
+			if !seen[p.cause] {
+				seen[p.cause] = true
+				found = append(found, ClientError{Line: line, Cause: p.cause})
+			}
+			break
+		}
+	}
+	return found
+}
+
+// explainClientFailure adds the known fio errors in the log of a failed client job to its
+// error, so the run fails with the probable misconfiguration instead of a generic job failure
+func (w *Workload) explainClientFailure(ctx context.Context, jobName string, err error) error {
+	logs, lerr := w.k8sClient.GetJobPodLogs(ctx, jobName, w.config.Namespace)
+	if lerr != nil {
+		log.Printf("Warning: failed to get the logs of %s to look for fio errors: %v", jobName, lerr)
+		return err
+	}
+	for _, clientErr := range DetectClientErrors(logs) {
+		err = fmt.Errorf("%w; %s", err, clientErr)
+	}
+	return err
+}
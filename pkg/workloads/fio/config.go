@@ -1,9 +1,34 @@
 package fio
 
-import "fmt"
+import (
+	"bytes"
+	_ "embed"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/util/validation"
+
+	"github.com/jtaleric/k8s-io/pkg/guest"
+	"github.com/jtaleric/k8s-io/pkg/kubernetes"
+)
+
+// ConfigSource is the source of this file, used to document the configuration fields
+//
+//go:embed config.go
+var ConfigSource string
 
 // FIOConfig represents the FIO benchmark parameters
 type FIOConfig struct {
+	// Built-in profile providing the jobs, block sizes and options the configuration leaves
+	// out: etcd, postgres-wal, kafka-log or vm-boot
+	Profile string `yaml:"profile,omitempty"`
+
 	// Basic FIO settings
 	Kind     string   `yaml:"kind"`     // "pod" or "vm"
 	Servers  int      `yaml:"servers"`  // Number of FIO server pods/VMs
@@ -15,6 +40,13 @@ type FIOConfig struct {
 	IODepth  int      `yaml:"iodepth"`  // Queue depth
 	FileSize string   `yaml:"filesize"` // Size of files to test
 
+	// I/O engine settings
+	IOEngine  string `yaml:"ioengine,omitempty"`  // I/O engine (libaio, io_uring, psync, sync)
+	Direct    *bool  `yaml:"direct,omitempty"`    // Use non-buffered I/O (defaults to true)
+	Sync      bool   `yaml:"sync,omitempty"`      // Open files with O_SYNC
+	Fsync     int    `yaml:"fsync,omitempty"`     // Issue an fsync every N writes
+	Fdatasync int    `yaml:"fdatasync,omitempty"` // Issue an fdatasync every N writes
+
 	// Timing settings
 	ReadRuntime   int `yaml:"read_runtime"`    // Read test duration
 	WriteRuntime  int `yaml:"write_runtime"`   // Write test duration
@@ -23,17 +55,23 @@ type FIOConfig struct {
 	JobTimeout    int `yaml:"job_timeout"`     // Overall job timeout
 
 	// Storage settings
-	StorageClass  string `yaml:"storageclass,omitempty"`  // Kubernetes storage class
-	StorageSize   string `yaml:"storagesize,omitempty"`   // PVC size
-	PVCAccessMode string `yaml:"pvcaccessmode,omitempty"` // PVC access mode
-	PVCVolumeMode string `yaml:"pvcvolumemode,omitempty"` // PVC volume mode
-	HostPath      string `yaml:"hostpath,omitempty"`      // Host path for storage
-	FIOPath       string `yaml:"fio_path,omitempty"`      // Path where FIO tests run (defaults: /tmp for pods, /test for VMs)
+	StorageClass     string `yaml:"storageclass,omitempty"`       // Kubernetes storage class
+	StorageSize      string `yaml:"storagesize,omitempty"`        // PVC size
+	PVCAccessMode    string `yaml:"pvcaccessmode,omitempty"`      // PVC access mode
+	PVCVolumeMode    string `yaml:"pvcvolumemode,omitempty"`      // PVC volume mode
+	HostPath         string `yaml:"hostpath,omitempty"`           // Host path for storage
+	VolumeType       string `yaml:"volume_type,omitempty"`        // Volume under test: pvc, hostpath, emptydir, memory or ephemeral (defaults to hostpath with only a hostpath, pvc otherwise)
+	VolumesPerServer int    `yaml:"volumes_per_server,omitempty"` // Volumes every server mounts and tests concurrently (defaults to 1)
+	FIOPath          string `yaml:"fio_path,omitempty"`           // Path where FIO tests run (defaults: /dev/xvda for Block PVCs, /tmp for pods, /test for VMs)
+
+	// Storage under test, declared explicitly; volume_type and pvcvolumemode follow from it
+	Target *TargetConfig `yaml:"target,omitempty"`
 
 	// Prefill settings
 	Prefill          bool   `yaml:"prefill,omitempty"`            // Enable prefill
 	PrefillBS        string `yaml:"prefill_bs,omitempty"`         // Prefill block size
 	PostPrefillSleep int    `yaml:"post_prefill_sleep,omitempty"` // Sleep after prefill
+	PrefillRate      string `yaml:"prefill_rate,omitempty"`       // Expected prefill write rate per server and second, e.g. 500MiB, for the duration estimate
 
 	// VM settings (when kind=vm)
 	VMImage  string `yaml:"vm_image,omitempty"`  // VM container image
@@ -41,16 +79,63 @@ type FIOConfig struct {
 	VMMemory string `yaml:"vm_memory,omitempty"` // VM memory
 	VMBus    string `yaml:"vm_bus,omitempty"`    // VM disk bus type
 
+	// KubeVirt instancetype and preference sizing the VMs instead of vm_cores and vm_memory,
+	// "<name>" for the cluster-wide kinds or "<kind>/<name>" like virtctl
+	VMInstancetype string `yaml:"vm_instancetype,omitempty"` // Provides vm_cores and vm_memory
+	VMPreference   string `yaml:"vm_preference,omitempty"`   // Provides vm_bus when it sets a preferred disk bus
+
+	// Guest OS tuning applied by cloud-init before the FIO server starts in the VMs
+	GuestTuning *guest.Tuning `yaml:"guest_tuning,omitempty"`
+
 	// Container settings
 	Image        string `yaml:"image,omitempty"`         // FIO container image
 	RuntimeClass string `yaml:"runtime_class,omitempty"` // Pod runtime class
 
+	// CPU pinning for FIO servers
+	CPUPinning *CPUPinningConfig `yaml:"cpu_pinning,omitempty"`
+
+	// Resources of the FIO server pods without CPU pinning
+	ServerCPU    string `yaml:"server_cpu,omitempty"`    // CPU request and limit of the server pods
+	ServerMemory string `yaml:"server_memory,omitempty"` // Memory request and limit of the server pods
+
+	// Environment variables and Secret/ConfigMap volumes injected into the pods
+	Env          map[string]string `yaml:"env,omitempty"`
+	ServerEnv    map[string]string `yaml:"server_env,omitempty"`
+	ClientEnv    map[string]string `yaml:"client_env,omitempty"`
+	ExtraVolumes []ExtraVolume     `yaml:"extra_volumes,omitempty"`
+
+	// Additional sidecar containers, such as telemetry agents, for the pods
+	Sidecars []Sidecar `yaml:"sidecars,omitempty"`
+
+	// Node-level iostat/blktrace/bpftrace capture on the server nodes during the run
+	NodeCapture *NodeCaptureConfig `yaml:"node_capture,omitempty"`
+
+	// Ceph OSD-side latency correlation from the Ceph mgr Prometheus metrics
+	CephLatency *CephLatencyConfig `yaml:"ceph_latency,omitempty"`
+
+	// CPU throttling and frequency of the server nodes during every sample, from node-exporter
+	ThermalCheck *ThermalCheckConfig `yaml:"thermal_check,omitempty"`
+
+	// Online expansion of the server PVCs while the benchmark runs
+	Expansion *ExpansionConfig `yaml:"expansion,omitempty"`
+
+	// Failure of the pods serving RWX storage while the benchmark runs
+	Failover *FailoverConfig `yaml:"failover,omitempty"`
+
+	// Check after the benchmark that the data of the raw block volumes is encrypted on the nodes
+	EncryptionCheck *EncryptionCheckConfig `yaml:"encryption_check,omitempty"`
+
 	// Scheduling and placement
-	NodeSelector      map[string]string `yaml:"nodeselector,omitempty"`
-	Tolerations       interface{}       `yaml:"tolerations,omitempty"`
-	Annotations       map[string]string `yaml:"annotations,omitempty"`
-	ServerAnnotations map[string]string `yaml:"server_annotations,omitempty"`
-	ClientAnnotations map[string]string `yaml:"client_annotations,omitempty"`
+	NodeSelector        map[string]string `yaml:"nodeselector,omitempty"`
+	Tolerations         interface{}       `yaml:"tolerations,omitempty"`
+	Annotations         map[string]string `yaml:"annotations,omitempty"`
+	ServerAnnotations   map[string]string `yaml:"server_annotations,omitempty"`
+	ClientAnnotations   map[string]string `yaml:"client_annotations,omitempty"`
+	PodDisruptionBudget bool              `yaml:"pod_disruption_budget,omitempty"` // Protect servers from voluntary evictions
+
+	// Namespaces the servers and their PVCs are spread over round-robin, to test namespace
+	// scoped quotas and policies; the client and configmaps stay in the run namespace
+	ServerNamespaces []string `yaml:"server_namespaces,omitempty"`
 
 	// Logging and monitoring
 	LogSampleRate int  `yaml:"log_sample_rate,omitempty"` // I/O stat sample interval
@@ -58,12 +143,166 @@ type FIOConfig struct {
 	FioJSONToLog  bool `yaml:"fio_json_to_log,omitempty"` // Log FIO JSON output
 	Debug         bool `yaml:"debug,omitempty"`           // Enable debug mode
 
-	// Compression settings
-	CmpRatio int `yaml:"cmp_ratio,omitempty"` // Compression ratio
+	// Index the raw fio JSON of every sample into <elasticsearch index_name>-raw, next to the
+	// copy always written to the artifacts directory
+	IndexRawResults bool `yaml:"index_raw_results,omitempty"`
+
+	// Data pattern settings
+	CmpRatio                 int  `yaml:"cmp_ratio,omitempty"`                  // Compression ratio (alias for buffer_compress_percentage)
+	BufferCompressPercentage int  `yaml:"buffer_compress_percentage,omitempty"` // Percentage of each buffer that is compressible
+	BufferCompressChunk      int  `yaml:"buffer_compress_chunk,omitempty"`      // Size in bytes of compressible regions
+	DedupePercentage         int  `yaml:"dedupe_percentage,omitempty"`          // Percentage of writes that are duplicates
+	RefillBuffers            bool `yaml:"refill_buffers,omitempty"`             // Refill I/O buffers on every submit
+
+	// Burst shaping: idle time between blocks of I/O, or cycles of I/O and idle time, instead
+	// of steady-state saturation
+	ThinkTime       string           `yaml:"thinktime,omitempty"`        // Idle time after every thinktime_blocks I/Os, e.g. 500us or 10ms
+	ThinkTimeBlocks int              `yaml:"thinktime_blocks,omitempty"` // I/Os between think times (defaults to 1)
+	ThinkTimeSpin   string           `yaml:"thinktime_spin,omitempty"`   // Part of every think time spent busy on the CPU instead of sleeping
+	DutyCycle       *DutyCycleConfig `yaml:"duty_cycle,omitempty"`       // Cycles of active I/O and idle time, e.g. 30s on and 30s off
 
 	// Cache drop settings
 	DropCacheKernel   bool `yaml:"drop_cache_kernel,omitempty"`    // Drop kernel cache
 	DropCacheRookCeph bool `yaml:"drop_cache_rook_ceph,omitempty"` // Drop Ceph cache
+	SampleBarrier     bool `yaml:"sample_barrier,omitempty"`       // Drop caches and sync the servers before every sample
+
+	// Pause after every sample, fixed or until the server nodes are idle, requires sample_barrier
+	Cooldown *CooldownConfig `yaml:"cooldown,omitempty"`
+
+	// Rerun the incomplete combinations up to this many times when the client is evicted, OOM killed or drained
+	SampleRetries int `yaml:"sample_retries,omitempty"`
+
+	// Directory of templates replacing the built-in templates of the same name, such as
+	// configmap.yml.j2 for the job files; templates see the topology of the server nodes
+	TemplatesDir string `yaml:"templates_dir,omitempty"`
+}
+
+// CPUPinningConfig represents CPU pinning settings for FIO servers
+type CPUPinningConfig struct {
+	Cores    string `yaml:"cores"`               // CPU list to pin to, e.g. "2-3" or "2,4"
+	Tool     string `yaml:"tool,omitempty"`      // "taskset" or "numactl"
+	NUMANode *int   `yaml:"numa_node,omitempty"` // NUMA node to bind memory to (numactl only)
+	CPU      string `yaml:"cpu,omitempty"`       // CPU request and limit (defaults to the number of cores)
+	Memory   string `yaml:"memory,omitempty"`    // Memory request and limit
+}
+
+// NodeCaptureConfig represents the node-level capture DaemonSet settings
+type NodeCaptureConfig struct {
+	Tools          []string `yaml:"tools"`                     // "iostat", "blktrace", "bpftrace" and/or "biolatency"
+	Image          string   `yaml:"image"`                     // Image providing the tools
+	Interval       int      `yaml:"interval,omitempty"`        // iostat and biolatency interval in seconds
+	Devices        []string `yaml:"devices,omitempty"`         // Block devices traced by blktrace
+	BPFTraceScript string   `yaml:"bpftrace_script,omitempty"` // bpftrace program to run
+}
+
+// CephLatencyConfig represents the Ceph OSD latency correlation settings
+type CephLatencyConfig struct {
+	OSDs []string `yaml:"osds,omitempty"` // OSD daemons to include, e.g. "osd.0" (all OSDs if empty)
+}
+
+// ThermalCheckConfig represents the node CPU throttling correlation settings
+type ThermalCheckConfig struct {
+	MinFrequencyPercent float64 `yaml:"min_frequency_percent,omitempty"` // Also flag samples whose node averaged less of its maximum CPU frequency (off if 0)
+}
+
+// TargetConfig represents the storage the servers test
+type TargetConfig struct {
+	Type string `yaml:"type"` // pvc, block (a raw PVC device), hostpath, emptydir, memory or ephemeral
+}
+
+// ExpansionConfig represents the online PVC expansion settings
+type ExpansionConfig struct {
+	Size    string `yaml:"size"`              // Size every PVC is expanded to, larger than storagesize
+	Delay   int    `yaml:"delay,omitempty"`   // Seconds after the benchmark client starts before expanding (defaults to 60)
+	Timeout int    `yaml:"timeout,omitempty"` // Seconds to wait for every PVC to reach the size (defaults to 600)
+}
+
+// FailoverConfig represents the RWX storage failover test settings
+type FailoverConfig struct {
+	Namespace      string `yaml:"namespace"`                 // Namespace of the pods serving the storage, such as rook-ceph
+	Selector       string `yaml:"selector"`                  // Label selector of the pods serving the storage, such as app=rook-ceph-mds
+	Action         string `yaml:"action"`                    // "delete" the serving pods or "drain" the node of the first one
+	Delay          int    `yaml:"delay,omitempty"`           // Seconds after the benchmark client starts before the failure (defaults to 60)
+	Timeout        int    `yaml:"timeout,omitempty"`         // Seconds to wait for the serving pods to be ready again (defaults to 600)
+	StallThreshold int    `yaml:"stall_threshold,omitempty"` // Milliseconds without completed I/O that count as a stall (defaults to 1000)
+}
+
+// EncryptionCheckConfig represents the encryption at rest check settings
+type EncryptionCheckConfig struct {
+	Image      string  `yaml:"image,omitempty"`       // Image of the privileged inspector pods, with fio, od and awk (defaults to image)
+	Pattern    string  `yaml:"pattern,omitempty"`     // 16-byte pattern written and searched for, as 32 hex digits (defaults to "k8s-io-encrypt!!")
+	SampleMiB  int     `yaml:"sample_mib,omitempty"`  // MiB of the pattern written at the start of every device and read back (defaults to 64, at least 32)
+	MinEntropy float64 `yaml:"min_entropy,omitempty"` // Bits per byte the backing device must reach to count as encrypted (defaults to 7.9)
+}
+
+// DefaultEncryptionPattern is "k8s-io-encrypt!!" in hex
+const DefaultEncryptionPattern = "6b38732d696f2d656e63727970742121"
+
+// ExtraVolume represents a Secret or ConfigMap mounted into the FIO pods
+type ExtraVolume struct {
+	Name      string `yaml:"name"`
+	MountPath string `yaml:"mount_path"`
+	Secret    string `yaml:"secret,omitempty"`     // Secret to mount
+	ConfigMap string `yaml:"config_map,omitempty"` // ConfigMap to mount
+	Target    string `yaml:"target,omitempty"`     // "server", "client" or "all"
+}
+
+// Sidecar represents an additional container rendered into the FIO pods
+type Sidecar struct {
+	Name         string            `yaml:"name"`
+	Image        string            `yaml:"image"`
+	Command      []string          `yaml:"command,omitempty"`
+	Args         []string          `yaml:"args,omitempty"`
+	Env          map[string]string `yaml:"env,omitempty"`
+	Privileged   bool              `yaml:"privileged,omitempty"`    // Run as root with full privileges, e.g. for blktrace
+	VolumeMounts []SidecarMount    `yaml:"volume_mounts,omitempty"` // data-volume or extra volumes to mount
+	Target       string            `yaml:"target,omitempty"`        // "server", "client" or "all"
+}
+
+// SidecarMount represents a pod volume mounted into a sidecar container
+type SidecarMount struct {
+	Name      string `yaml:"name"`
+	MountPath string `yaml:"mount_path"`
+}
+
+// SidecarSharedPath is where the volume shared between the FIO container and the sidecars is mounted
+const SidecarSharedPath = "/shared"
+
+// CommandJSON returns the sidecar command as a YAML flow sequence
+func (s Sidecar) CommandJSON() string {
+	return toJSONList(s.Command)
+}
+
+// ArgsJSON returns the sidecar args as a YAML flow sequence
+func (s Sidecar) ArgsJSON() string {
+	return toJSONList(s.Args)
+}
+
+// EnvVars returns the sidecar environment variables sorted by name
+func (s Sidecar) EnvVars() []EnvVar {
+	env := make([]EnvVar, 0, len(s.Env))
+	for k, v := range s.Env {
+		env = append(env, EnvVar{Name: k, Value: v})
+	}
+	sort.Slice(env, func(i, j int) bool { return env[i].Name < env[j].Name })
+	return env
+}
+
+// toJSONList encodes a list of strings, which is also valid YAML
+func toJSONList(values []string) string {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(values); err != nil {
+		return "[]"
+	}
+	return strings.TrimSpace(buf.String())
+}
+
+// EnvVar represents an environment variable injected into the FIO pods
+type EnvVar struct {
+	Name  string
+	Value string
 }
 
 // JobParams represents job-specific parameters
@@ -74,6 +313,9 @@ type JobParams struct {
 
 // SetDefaults sets default values for FIO configuration
 func (f *FIOConfig) SetDefaults() {
+	// The profile comes first, the defaults only fill in what it leaves out
+	f.applyProfile()
+
 	if f.Kind == "" {
 		f.Kind = "pod"
 	}
@@ -90,6 +332,64 @@ func (f *FIOConfig) SetDefaults() {
 		f.IODepth = 4
 	}
 
+	if f.IOEngine == "" {
+		f.IOEngine = "libaio"
+	}
+
+	for i := range f.ExtraVolumes {
+		if f.ExtraVolumes[i].Target == "" {
+			f.ExtraVolumes[i].Target = "all"
+		}
+	}
+
+	if f.NodeCapture != nil && f.NodeCapture.Interval == 0 {
+		f.NodeCapture.Interval = 5
+	}
+
+	if f.Expansion != nil {
+		if f.Expansion.Delay == 0 {
+			f.Expansion.Delay = 60
+		}
+		if f.Expansion.Timeout == 0 {
+			f.Expansion.Timeout = 600
+		}
+	}
+
+	if f.Failover != nil {
+		if f.Failover.Delay == 0 {
+			f.Failover.Delay = 60
+		}
+		if f.Failover.Timeout == 0 {
+			f.Failover.Timeout = 600
+		}
+		if f.Failover.StallThreshold == 0 {
+			f.Failover.StallThreshold = 1000
+		}
+	}
+
+	if f.EncryptionCheck != nil {
+		if f.EncryptionCheck.Pattern == "" {
+			f.EncryptionCheck.Pattern = DefaultEncryptionPattern
+		}
+		if f.EncryptionCheck.SampleMiB == 0 {
+			f.EncryptionCheck.SampleMiB = 64
+		}
+		if f.EncryptionCheck.MinEntropy == 0 {
+			f.EncryptionCheck.MinEntropy = 7.9
+		}
+	}
+
+	for i := range f.Sidecars {
+		if f.Sidecars[i].Target == "" {
+			f.Sidecars[i].Target = "server"
+		}
+	}
+
+	if f.Direct == nil {
+		direct := true
+		f.Direct = &direct
+	}
+
 	if f.JobTimeout == 0 {
 		f.JobTimeout = 3600
 	}
@@ -98,6 +398,10 @@ func (f *FIOConfig) SetDefaults() {
 		f.Image = "quay.io/jtaleric/fio:latest"
 	}
 
+	if f.EncryptionCheck != nil && f.EncryptionCheck.Image == "" {
+		f.EncryptionCheck.Image = f.Image
+	}
+
 	if f.VMImage == "" {
 		f.VMImage = "quay.io/kubevirt/fedora-container-disk-images:latest"
 	}
@@ -118,6 +422,20 @@ func (f *FIOConfig) SetDefaults() {
 		f.PVCAccessMode = "ReadWriteOnce"
 	}
 
+	// Settings left out follow the target, conflicting ones are rejected by Validate
+	if f.Target != nil {
+		if f.Target.Type == TargetTypeBlock {
+			if f.VolumeType == "" {
+				f.VolumeType = VolumeTypePVC
+			}
+			if f.PVCVolumeMode == "" {
+				f.PVCVolumeMode = "Block"
+			}
+		} else if f.VolumeType == "" {
+			f.VolumeType = f.Target.Type
+		}
+	}
+
 	if f.PVCVolumeMode == "" {
 		f.PVCVolumeMode = "Filesystem"
 	}
@@ -126,13 +444,51 @@ func (f *FIOConfig) SetDefaults() {
 		f.StorageSize = "10Gi"
 	}
 
+	if f.VolumesPerServer == 0 {
+		f.VolumesPerServer = 1
+	}
+
+	// Without a storageclass, the PVCs are provisioned from the default class of the cluster
+	if f.VolumeType == "" {
+		if f.HostPath != "" && f.StorageClass == "" {
+			f.VolumeType = VolumeTypeHostPath
+		} else {
+			f.VolumeType = VolumeTypePVC
+		}
+	}
+
 	if f.PrefillBS == "" {
 		f.PrefillBS = "4096KiB"
 	}
+
+	if f.CPUPinning != nil {
+		if f.CPUPinning.Tool == "" {
+			f.CPUPinning.Tool = "taskset"
+		}
+
+		if f.CPUPinning.CPU == "" {
+			if count, err := countCPUs(f.CPUPinning.Cores); err == nil {
+				f.CPUPinning.CPU = strconv.Itoa(count)
+			}
+		}
+
+		if f.CPUPinning.Memory == "" {
+			f.CPUPinning.Memory = "1Gi"
+		}
+	}
+
+	// cmp_ratio predates buffer_compress_percentage and maps onto it
+	if f.BufferCompressPercentage == 0 && f.CmpRatio > 0 {
+		f.BufferCompressPercentage = f.CmpRatio
+	}
 }
 
 // Validate validates the FIO configuration
 func (f *FIOConfig) Validate() error {
+	if err := f.validateProfile(); err != nil {
+		return err
+	}
+
 	if len(f.Jobs) == 0 {
 		return fmt.Errorf("at least one job type must be specified")
 	}
@@ -149,25 +505,768 @@ func (f *FIOConfig) Validate() error {
 		return fmt.Errorf("filesize must be specified")
 	}
 
+	if f.SampleRetries < 0 {
+		return fmt.Errorf("sample_retries must not be negative")
+	}
+
 	if f.Kind != "pod" && f.Kind != "vm" {
 		return fmt.Errorf("kind must be either 'pod' or 'vm'")
 	}
 
+	// The budget selects the server pods, the virt-launcher pods of VMs do not carry their labels
+	if f.PodDisruptionBudget && f.Kind == "vm" {
+		return fmt.Errorf("pod_disruption_budget is not supported for VMs, set the eviction strategy of the KubeVirt cluster instead")
+	}
+
+	if f.PrefillRate != "" {
+		if rate, err := parseFIOSize(f.PrefillRate); err != nil || rate <= 0 {
+			return fmt.Errorf("prefill_rate must be a positive size such as 500MiB")
+		}
+	}
+
+	if f.PVCVolumeMode != "Filesystem" && f.PVCVolumeMode != "Block" {
+		return fmt.Errorf("pvcvolumemode must be either 'Filesystem' or 'Block'")
+	}
+
+	if f.VMInstancetype != "" || f.VMPreference != "" {
+		if f.Kind != "vm" {
+			return fmt.Errorf("vm_instancetype and vm_preference require kind 'vm'")
+		}
+		if err := kubernetes.ValidateVMResourceRefs(f.VMInstancetype, f.VMPreference); err != nil {
+			return err
+		}
+	}
+
+	if f.GuestTuning != nil {
+		if f.Kind != "vm" {
+			return fmt.Errorf("guest_tuning requires kind 'vm'")
+		}
+		if err := f.GuestTuning.Validate(); err != nil {
+			return err
+		}
+	}
+
+	if err := f.validateTarget(); err != nil {
+		return err
+	}
+
+	if err := f.validateVolumeType(); err != nil {
+		return err
+	}
+
+	if err := f.validateFIOPath(); err != nil {
+		return err
+	}
+
+	if err := f.validateIOEngine(); err != nil {
+		return err
+	}
+
+	if err := f.validateDataPattern(); err != nil {
+		return err
+	}
+
+	if err := f.validateShaping(); err != nil {
+		return err
+	}
+
+	if err := f.validateCooldown(); err != nil {
+		return err
+	}
+
+	if err := f.validateCPUPinning(); err != nil {
+		return err
+	}
+
+	if err := f.validateServerResources(); err != nil {
+		return err
+	}
+
+	if err := f.validateExtraVolumes(); err != nil {
+		return err
+	}
+
+	if err := f.validateSidecars(); err != nil {
+		return err
+	}
+
+	if err := f.validateNodeCapture(); err != nil {
+		return err
+	}
+
+	if err := f.validateExpansion(); err != nil {
+		return err
+	}
+
+	if err := f.validateFailover(); err != nil {
+		return err
+	}
+
+	if err := f.validateEncryptionCheck(); err != nil {
+		return err
+	}
+
+	if err := f.validateThermalCheck(); err != nil {
+		return err
+	}
+
+	if err := f.validateServerNamespaces(); err != nil {
+		return err
+	}
+
+	if f.TemplatesDir != "" {
+		if info, err := os.Stat(f.TemplatesDir); err != nil || !info.IsDir() {
+			return fmt.Errorf("templates_dir %s is not a directory", f.TemplatesDir)
+		}
+	}
+
+	return nil
+}
+
+// Volume types of the FIO servers
+const (
+	VolumeTypePVC       = "pvc"       // A PVC of storageclass, or of the default class, per server
+	VolumeTypeHostPath  = "hostpath"  // A directory of the node
+	VolumeTypeEmptyDir  = "emptydir"  // A node-local emptyDir on the node's ephemeral storage
+	VolumeTypeMemory    = "memory"    // A memory-backed emptyDir (tmpfs), the page-cache baseline
+	VolumeTypeEphemeral = "ephemeral" // A generic ephemeral volume of storageclass or the default class, deleted with the pod
+)
+
+// HasDataVolume reports whether the servers test a volume rather than the container filesystem
+func (f *FIOConfig) HasDataVolume() bool {
+	return f.VolumeType != ""
+}
+
+// UsesPVC reports whether a PVC is created for every server before it is deployed
+func (f *FIOConfig) UsesPVC() bool {
+	return f.VolumeType == VolumeTypePVC
+}
+
+// TargetTypeBlock is the target type of a raw block device attached from a PVC, the other
+// target types are the volume types
+const TargetTypeBlock = "block"
+
+// validateTarget checks that the target has a known type and that volume_type and
+// pvcvolumemode, when set as well, agree with it
+func (f *FIOConfig) validateTarget() error {
+	if f.Target == nil {
+		return nil
+	}
+
+	switch f.Target.Type {
+	case "":
+		return fmt.Errorf("target requires a type: pvc, block, hostpath, emptydir, memory or ephemeral")
+	case TargetTypeBlock:
+		if f.VolumeType != VolumeTypePVC && f.VolumeType != VolumeTypeEphemeral {
+			return fmt.Errorf("target type 'block' conflicts with volume_type '%s', raw block devices are attached from a PVC or an ephemeral volume", f.VolumeType)
+		}
+		if f.PVCVolumeMode != "Block" {
+			return fmt.Errorf("target type 'block' conflicts with pvcvolumemode '%s'", f.PVCVolumeMode)
+		}
+	case VolumeTypePVC, VolumeTypeHostPath, VolumeTypeEmptyDir, VolumeTypeMemory, VolumeTypeEphemeral:
+		if f.VolumeType != f.Target.Type {
+			return fmt.Errorf("target type '%s' conflicts with volume_type '%s'", f.Target.Type, f.VolumeType)
+		}
+		if f.Target.Type == VolumeTypePVC && f.PVCVolumeMode == "Block" {
+			return fmt.Errorf("target type 'pvc' is a filesystem, use target type 'block' to test the raw device")
+		}
+	default:
+		return fmt.Errorf("unknown target type '%s', must be pvc, block, hostpath, emptydir, memory or ephemeral", f.Target.Type)
+	}
 	return nil
 }
 
-// GetFIOPath returns the FIO path based on storage configuration
+// validateVolumeType checks that the volume type has the settings it needs
+func (f *FIOConfig) validateVolumeType() error {
+	switch f.VolumeType {
+	case "":
+	case VolumeTypePVC, VolumeTypeEphemeral:
+		// An empty storageclass is the default class, detected when the run starts
+	case VolumeTypeHostPath:
+		if f.HostPath == "" {
+			return fmt.Errorf("volume_type 'hostpath' requires a hostpath")
+		}
+	case VolumeTypeEmptyDir, VolumeTypeMemory:
+		if f.StorageClass != "" || f.HostPath != "" {
+			return fmt.Errorf("volume_type '%s' does not use storageclass or hostpath, remove them", f.VolumeType)
+		}
+		if f.PVCVolumeMode == "Block" {
+			return fmt.Errorf("volume_type '%s' is a directory, pvcvolumemode 'Block' requires volume_type 'pvc' or 'ephemeral'", f.VolumeType)
+		}
+		// tmpfs rejects O_DIRECT, so every job would fail to open its file
+		if f.VolumeType == VolumeTypeMemory && f.IsDirect() {
+			return fmt.Errorf("volume_type 'memory' requires direct: false, tmpfs does not support direct I/O")
+		}
+	default:
+		return fmt.Errorf("volume_type must be one of: pvc, hostpath, emptydir, memory, ephemeral")
+	}
+
+	if f.VolumesPerServer < 1 {
+		return fmt.Errorf("volumes_per_server must be at least 1")
+	}
+	if f.VolumesPerServer > 1 {
+		if !f.HasDataVolume() || f.VolumeType == VolumeTypeHostPath {
+			return fmt.Errorf("volumes_per_server requires volume_type 'pvc', 'ephemeral', 'emptydir' or 'memory', every volume must be separate")
+		}
+		if f.Kind == "vm" {
+			return fmt.Errorf("volumes_per_server is not supported for VMs")
+		}
+	}
+
+	// VMs attach their data disk from a PVC or a disk image on the host
+	if f.Kind == "vm" && f.VolumeType != "" && f.VolumeType != VolumeTypePVC && f.VolumeType != VolumeTypeHostPath {
+		return fmt.Errorf("volume_type '%s' is not supported for VMs, use 'pvc' or 'hostpath'", f.VolumeType)
+	}
+	return nil
+}
+
+// DataVolume is one of the volumes a server tests
+type DataVolume struct {
+	Index  int    // Position of the volume, from 1
+	Name   string // Name of the pod volume
+	Path   string // Device path or mount path in the server
+	Suffix string // Suffix of the PVC name and manifest key, empty for the first volume
+}
+
+// DataVolumes returns the volumes every server tests. The first volume keeps the names of a
+// single volume setup, so sidecars can mount data-volume either way; with more than one
+// volume every volume gets its own path next to fio_path: a device path suffixed with the
+// volume number for pvcvolumemode Block, or a vol<N> directory below it.
+func (f *FIOConfig) DataVolumes() []DataVolume {
+	if f.VolumesPerServer <= 1 {
+		return []DataVolume{{Index: 1, Name: "data-volume", Path: f.GetFIOPath()}}
+	}
+
+	volumes := make([]DataVolume, f.VolumesPerServer)
+	for i := range volumes {
+		volume := DataVolume{Index: i + 1, Name: "data-volume"}
+		if i > 0 {
+			volume.Suffix = fmt.Sprintf("-%d", i+1)
+			volume.Name += volume.Suffix
+		}
+		if f.PVCVolumeMode == "Block" {
+			volume.Path = fmt.Sprintf("%s-%d", f.GetFIOPath(), i+1)
+		} else {
+			volume.Path = fmt.Sprintf("%s/vol%d", f.GetFIOPath(), i+1)
+		}
+		volumes[i] = volume
+	}
+	return volumes
+}
+
+// defaultBlockDevicePath is the device path of Block PVCs in the server pods
+const defaultBlockDevicePath = "/dev/xvda"
+
+// GetFIOPath returns the path fio tests: fio_path when set, otherwise the device path of the
+// PVC for pvcvolumemode Block, or the directory the volume is mounted at
 func (f *FIOConfig) GetFIOPath() string {
 	// If user explicitly set fio_path, use it
 	if f.FIOPath != "" {
 		return f.FIOPath
 	}
 
-	// Default based on workload kind
+	// VMs format their data disk and mount it
 	if f.Kind == "vm" {
 		return "/test"
 	}
 
-	// Default for pods
+	// A raw block PVC is attached as a device, anything else is mounted as a directory
+	if f.PVCVolumeMode == "Block" {
+		return defaultBlockDevicePath
+	}
 	return "/tmp"
 }
+
+// validateFIOPath checks that the volume mode and fio_path agree, since fio silently
+// benchmarks a regular file when it is given a device path without a device behind it
+func (f *FIOConfig) validateFIOPath() error {
+	if f.PVCVolumeMode == "Block" {
+		if f.Kind == "vm" {
+			return fmt.Errorf("pvcvolumemode 'Block' is not supported for VMs, they format and mount their data disk at fio_path")
+		}
+		if f.VolumeType != VolumeTypePVC && f.VolumeType != VolumeTypeEphemeral {
+			return fmt.Errorf("pvcvolumemode 'Block' requires volume_type 'pvc' or 'ephemeral', raw block volumes can only be attached from a PVC or an ephemeral volume")
+		}
+		return nil
+	}
+
+	if strings.HasPrefix(f.GetFIOPath(), "/dev/") {
+		return fmt.Errorf("fio_path %s is a device path but pvcvolumemode is 'Filesystem', set pvcvolumemode: Block to test the raw device", f.GetFIOPath())
+	}
+	return nil
+}
+
+// validateIOEngine validates the I/O engine and sync settings
+func (f *FIOConfig) validateIOEngine() error {
+	switch f.IOEngine {
+	case "libaio", "io_uring", "psync", "sync":
+	default:
+		return fmt.Errorf("ioengine must be one of: libaio, io_uring, psync, sync")
+	}
+
+	if f.Fsync < 0 || f.Fdatasync < 0 {
+		return fmt.Errorf("fsync and fdatasync must not be negative")
+	}
+
+	if f.PVCVolumeMode == "Block" {
+		// Buffered I/O against a raw device only measures the page cache
+		if !f.IsDirect() {
+			return fmt.Errorf("direct must be enabled when pvcvolumemode is 'Block'")
+		}
+
+		// There is no filesystem to flush on a raw device
+		if f.Fsync > 0 || f.Fdatasync > 0 {
+			return fmt.Errorf("fsync and fdatasync are not supported when pvcvolumemode is 'Block'")
+		}
+	}
+
+	return nil
+}
+
+// IsDirect reports whether FIO jobs use non-buffered I/O
+func (f *FIOConfig) IsDirect() bool {
+	return f.Direct == nil || *f.Direct
+}
+
+// validateDataPattern validates the compression and dedupe settings
+func (f *FIOConfig) validateDataPattern() error {
+	if f.CmpRatio < 0 || f.CmpRatio > 100 {
+		return fmt.Errorf("cmp_ratio must be between 0 and 100")
+	}
+
+	if f.BufferCompressPercentage < 0 || f.BufferCompressPercentage > 100 {
+		return fmt.Errorf("buffer_compress_percentage must be between 0 and 100")
+	}
+
+	if f.CmpRatio > 0 && f.BufferCompressPercentage != f.CmpRatio {
+		return fmt.Errorf("cmp_ratio and buffer_compress_percentage are both set and differ (%d vs %d)", f.CmpRatio, f.BufferCompressPercentage)
+	}
+
+	if f.DedupePercentage < 0 || f.DedupePercentage > 100 {
+		return fmt.Errorf("dedupe_percentage must be between 0 and 100")
+	}
+
+	if f.BufferCompressChunk < 0 {
+		return fmt.Errorf("buffer_compress_chunk must not be negative")
+	}
+
+	if f.BufferCompressChunk > 0 && f.BufferCompressPercentage == 0 {
+		return fmt.Errorf("buffer_compress_chunk requires buffer_compress_percentage to be set")
+	}
+
+	return nil
+}
+
+// validateCPUPinning validates the CPU pinning settings
+func (f *FIOConfig) validateCPUPinning() error {
+	if f.CPUPinning == nil {
+		return nil
+	}
+
+	if _, err := countCPUs(f.CPUPinning.Cores); err != nil {
+		return fmt.Errorf("invalid cpu_pinning.cores: %w", err)
+	}
+
+	if f.CPUPinning.Tool != "taskset" && f.CPUPinning.Tool != "numactl" {
+		return fmt.Errorf("cpu_pinning.tool must be either 'taskset' or 'numactl'")
+	}
+
+	if f.CPUPinning.NUMANode != nil && f.CPUPinning.Tool != "numactl" {
+		return fmt.Errorf("cpu_pinning.numa_node requires cpu_pinning.tool to be 'numactl'")
+	}
+
+	// The static CPU manager only hands out exclusive cores to whole-CPU Guaranteed pods
+	if n, err := strconv.Atoi(f.CPUPinning.CPU); err != nil || n <= 0 {
+		return fmt.Errorf("cpu_pinning.cpu must be a whole number of CPUs for Guaranteed QoS")
+	}
+
+	return nil
+}
+
+// validateServerResources checks the resources of the server pods
+func (f *FIOConfig) validateServerResources() error {
+	if f.ServerCPU == "" && f.ServerMemory == "" {
+		return nil
+	}
+
+	if f.Kind != "pod" {
+		return fmt.Errorf("server_cpu and server_memory require kind 'pod', VMs are sized with vm_cores and vm_memory")
+	}
+	if f.CPUPinning != nil {
+		return fmt.Errorf("server_cpu and server_memory cannot be combined with cpu_pinning, use cpu_pinning.cpu and cpu_pinning.memory")
+	}
+	if f.ServerCPU == "" || f.ServerMemory == "" {
+		return fmt.Errorf("server_cpu and server_memory must be set together")
+	}
+	if _, err := resource.ParseQuantity(f.ServerCPU); err != nil {
+		return fmt.Errorf("invalid server_cpu %q: %w", f.ServerCPU, err)
+	}
+	if _, err := resource.ParseQuantity(f.ServerMemory); err != nil {
+		return fmt.Errorf("invalid server_memory %q: %w", f.ServerMemory, err)
+	}
+
+	return nil
+}
+
+// PinCommand returns the command prefix used to pin the FIO server process
+func (f *FIOConfig) PinCommand() string {
+	if f.CPUPinning == nil {
+		return ""
+	}
+
+	if f.CPUPinning.Tool == "numactl" {
+		if f.CPUPinning.NUMANode != nil {
+			return fmt.Sprintf("numactl --physcpubind=%s --membind=%d", f.CPUPinning.Cores, *f.CPUPinning.NUMANode)
+		}
+		return fmt.Sprintf("numactl --physcpubind=%s", f.CPUPinning.Cores)
+	}
+
+	return fmt.Sprintf("taskset -c %s", f.CPUPinning.Cores)
+}
+
+// PinnedCPUs returns the pinned cores separated by spaces, which the server checks against the
+// CPUs its cgroup allows before pinning
+func (f *FIOConfig) PinnedCPUs() string {
+	if f.CPUPinning == nil {
+		return ""
+	}
+	cpus, _ := expandCPUs(f.CPUPinning.Cores)
+	list := make([]string, len(cpus))
+	for i, cpu := range cpus {
+		list[i] = strconv.Itoa(cpu)
+	}
+	return strings.Join(list, " ")
+}
+
+// validateExtraVolumes checks the Secret and ConfigMap volumes injected into the pods
+func (f *FIOConfig) validateExtraVolumes() error {
+	// Volume names used by the templates
+	names := map[string]bool{"data-volume": true, "fio-volume": true, "host-volume": true, "hooks-volume": true}
+
+	for _, v := range f.ExtraVolumes {
+		if v.Name == "" || v.MountPath == "" {
+			return fmt.Errorf("extra_volumes entries must have a name and mount_path")
+		}
+		if names[v.Name] {
+			return fmt.Errorf("extra volume name %s is already in use", v.Name)
+		}
+		names[v.Name] = true
+
+		if (v.Secret == "") == (v.ConfigMap == "") {
+			return fmt.Errorf("extra volume %s must set exactly one of secret or config_map", v.Name)
+		}
+
+		if v.Target != "server" && v.Target != "client" && v.Target != "all" {
+			return fmt.Errorf("extra volume %s target must be 'server', 'client' or 'all'", v.Name)
+		}
+	}
+
+	return nil
+}
+
+// validateServerNamespaces checks that the server namespaces are valid and distinct
+func (f *FIOConfig) validateServerNamespaces() error {
+	seen := make(map[string]bool)
+	for _, ns := range f.ServerNamespaces {
+		if errs := validation.IsDNS1123Label(ns); len(errs) > 0 {
+			return fmt.Errorf("server namespace %q is invalid: %s", ns, strings.Join(errs, ", "))
+		}
+		if seen[ns] {
+			return fmt.Errorf("server namespace %s is listed twice", ns)
+		}
+		seen[ns] = true
+	}
+	if len(f.ServerNamespaces) > f.Servers {
+		return fmt.Errorf("server_namespaces lists %d namespaces for %d servers", len(f.ServerNamespaces), f.Servers)
+	}
+	return nil
+}
+
+// ServerNamespace returns the namespace of a server, numbered from 1, which is the run
+// namespace unless server_namespaces is set
+func (f *FIOConfig) ServerNamespace(serverNum int, runNamespace string) string {
+	if len(f.ServerNamespaces) == 0 {
+		return runNamespace
+	}
+	return f.ServerNamespaces[(serverNum-1)%len(f.ServerNamespaces)]
+}
+
+// ServerCounts returns the number of servers in each server namespace
+func (f *FIOConfig) ServerCounts(runNamespace string) map[string]int {
+	counts := make(map[string]int)
+	for i := 1; i <= f.Servers; i++ {
+		counts[f.ServerNamespace(i, runNamespace)]++
+	}
+	return counts
+}
+
+// validateSidecars checks the sidecar containers and the volumes they mount
+func (f *FIOConfig) validateSidecars() error {
+	volumes := map[string]bool{"data-volume": true, "sidecar-shared": true}
+	for _, v := range f.ExtraVolumes {
+		volumes[v.Name] = true
+	}
+
+	names := map[string]bool{"fio-server": true, "fio-client": true}
+	for _, sc := range f.Sidecars {
+		if sc.Name == "" || sc.Image == "" {
+			return fmt.Errorf("sidecars must have a name and image")
+		}
+		if names[sc.Name] {
+			return fmt.Errorf("sidecar name %s is already in use", sc.Name)
+		}
+		names[sc.Name] = true
+
+		if sc.Target != "server" && sc.Target != "client" && sc.Target != "all" {
+			return fmt.Errorf("sidecar %s target must be 'server', 'client' or 'all'", sc.Name)
+		}
+
+		for _, m := range sc.VolumeMounts {
+			if !volumes[m.Name] || m.MountPath == "" {
+				return fmt.Errorf("sidecar %s mounts unknown volume %s or has no mount_path", sc.Name, m.Name)
+			}
+		}
+	}
+
+	return nil
+}
+
+// validateNodeCapture checks the node capture tools and their settings
+func (f *FIOConfig) validateNodeCapture() error {
+	nc := f.NodeCapture
+	if nc == nil {
+		return nil
+	}
+
+	if f.Kind != "pod" {
+		return fmt.Errorf("node_capture is only supported for pod servers")
+	}
+	if nc.Image == "" || len(nc.Tools) == 0 {
+		return fmt.Errorf("node_capture requires an image and at least one tool")
+	}
+
+	for _, tool := range nc.Tools {
+		switch tool {
+		case "iostat", "biolatency":
+		case "blktrace":
+			if len(nc.Devices) == 0 {
+				return fmt.Errorf("node_capture blktrace requires devices")
+			}
+		case "bpftrace":
+			if nc.BPFTraceScript == "" {
+				return fmt.Errorf("node_capture bpftrace requires a bpftrace_script")
+			}
+		default:
+			return fmt.Errorf("unsupported node_capture tool: %s", tool)
+		}
+	}
+
+	return nil
+}
+
+// validateExpansion validates the online PVC expansion settings
+func (f *FIOConfig) validateExpansion() error {
+	e := f.Expansion
+	if e == nil {
+		return nil
+	}
+
+	// Only PVCs created by the run can be expanded, generic ephemeral volumes are owned by the pods
+	if !f.UsesPVC() {
+		return fmt.Errorf("expansion requires volume_type 'pvc'")
+	}
+	if e.Delay < 0 || e.Timeout < 0 {
+		return fmt.Errorf("expansion delay and timeout must not be negative")
+	}
+
+	size, err := resource.ParseQuantity(e.Size)
+	if err != nil {
+		return fmt.Errorf("expansion size must be a Kubernetes quantity such as 20Gi: %w", err)
+	}
+	current, err := resource.ParseQuantity(f.StorageSize)
+	if err != nil {
+		return fmt.Errorf("storagesize must be a Kubernetes quantity such as 10Gi: %w", err)
+	}
+	if size.Cmp(current) <= 0 {
+		return fmt.Errorf("expansion size %s must be larger than storagesize %s", e.Size, f.StorageSize)
+	}
+	return nil
+}
+
+// validateFailover validates the RWX storage failover test settings
+func (f *FIOConfig) validateFailover() error {
+	fo := f.Failover
+	if fo == nil {
+		return nil
+	}
+
+	if !f.UsesPVC() || f.PVCAccessMode != "ReadWriteMany" {
+		return fmt.Errorf("failover requires volume_type 'pvc' with pvcaccessmode 'ReadWriteMany'")
+	}
+	if f.Kind != "pod" {
+		return fmt.Errorf("failover is only supported for pod servers")
+	}
+	if fo.Namespace == "" || fo.Selector == "" {
+		return fmt.Errorf("failover requires the namespace and selector of the pods serving the storage")
+	}
+	if fo.Action != "delete" && fo.Action != "drain" {
+		return fmt.Errorf("failover action must be either 'delete' or 'drain'")
+	}
+	if fo.Delay < 0 || fo.Timeout < 0 || fo.StallThreshold < 0 {
+		return fmt.Errorf("failover delay, timeout and stall_threshold must not be negative")
+	}
+
+	// Stalls are found in the averaged latency logs, which log every I/O without an interval
+	if f.LogSampleRate == 0 {
+		return fmt.Errorf("failover requires log_sample_rate, stalls are found in the averaged fio latency logs")
+	}
+	if fo.StallThreshold < 2*f.LogSampleRate {
+		return fmt.Errorf("failover stall_threshold must be at least twice log_sample_rate")
+	}
+	return nil
+}
+
+// validateThermalCheck validates the node CPU throttling correlation settings
+func (f *FIOConfig) validateThermalCheck() error {
+	if f.ThermalCheck == nil {
+		return nil
+	}
+	if p := f.ThermalCheck.MinFrequencyPercent; p < 0 || p >= 100 {
+		return fmt.Errorf("thermal_check.min_frequency_percent must be between 0 and 100, got %g", p)
+	}
+	return nil
+}
+
+// validateEncryptionCheck validates the encryption at rest check settings
+func (f *FIOConfig) validateEncryptionCheck() error {
+	ec := f.EncryptionCheck
+	if ec == nil {
+		return nil
+	}
+
+	if f.Kind != "pod" {
+		return fmt.Errorf("encryption_check is only supported for pod servers")
+	}
+	// The pattern is only at a known offset of a raw device, a filesystem places it anywhere
+	if (!f.UsesPVC() && f.VolumeType != VolumeTypeEphemeral) || f.PVCVolumeMode != "Block" {
+		return fmt.Errorf("encryption_check requires target type 'block', the pattern is searched for at the start of the raw device")
+	}
+	if _, err := ec.PatternBytes(); err != nil {
+		return err
+	}
+	// The backing device is read from 16MiB on, past the LUKS header, and must overlap the pattern
+	if ec.SampleMiB < 32 {
+		return fmt.Errorf("encryption_check sample_mib must be at least 32")
+	}
+	if ec.MinEntropy <= 0 || ec.MinEntropy > 8 {
+		return fmt.Errorf("encryption_check min_entropy must be between 0 and 8 bits per byte")
+	}
+	return nil
+}
+
+// PatternBytes returns the pattern written to the devices
+func (e *EncryptionCheckConfig) PatternBytes() ([]byte, error) {
+	pattern, err := hex.DecodeString(strings.TrimPrefix(strings.ToLower(e.Pattern), "0x"))
+	if err != nil || len(pattern) != 16 {
+		return nil, fmt.Errorf("encryption_check pattern must be 16 bytes as 32 hex digits")
+	}
+	return pattern, nil
+}
+
+// Uses reports whether the capture runs the given tool
+func (n *NodeCaptureConfig) Uses(tool string) bool {
+	for _, t := range n.Tools {
+		if t == tool {
+			return true
+		}
+	}
+	return false
+}
+
+// UsesBPF reports whether the capture runs a bpftrace program
+func (n *NodeCaptureConfig) UsesBPF() bool {
+	return n.Uses("bpftrace") || n.Uses("biolatency")
+}
+
+// SidecarsFor returns the sidecar containers for the "server" or "client" pods
+func (f *FIOConfig) SidecarsFor(role string) []Sidecar {
+	var sidecars []Sidecar
+	for _, sc := range f.Sidecars {
+		if sc.Target == "all" || sc.Target == role {
+			sidecars = append(sidecars, sc)
+		}
+	}
+	return sidecars
+}
+
+// EnvFor returns the environment variables for the "server" or "client" pods, sorted by name
+func (f *FIOConfig) EnvFor(role string) []EnvVar {
+	merged := make(map[string]string)
+	for k, v := range f.Env {
+		merged[k] = v
+	}
+
+	roleEnv := f.ServerEnv
+	if role == "client" {
+		roleEnv = f.ClientEnv
+	}
+	for k, v := range roleEnv {
+		merged[k] = v
+	}
+
+	env := make([]EnvVar, 0, len(merged))
+	for k, v := range merged {
+		env = append(env, EnvVar{Name: k, Value: v})
+	}
+	sort.Slice(env, func(i, j int) bool { return env[i].Name < env[j].Name })
+
+	return env
+}
+
+// VolumesFor returns the extra volumes mounted into the "server" or "client" pods
+func (f *FIOConfig) VolumesFor(role string) []ExtraVolume {
+	var volumes []ExtraVolume
+	for _, v := range f.ExtraVolumes {
+		if v.Target == "all" || v.Target == role {
+			volumes = append(volumes, v)
+		}
+	}
+	return volumes
+}
+
+// countCPUs returns the number of CPUs in a cpuset list such as "0-3,6"
+func countCPUs(cpuList string) (int, error) {
+	cpus, err := expandCPUs(cpuList)
+	return len(cpus), err
+}
+
+// expandCPUs returns the CPUs of a CPU list such as "2-3,6"
+func expandCPUs(cpuList string) ([]int, error) {
+	if strings.TrimSpace(cpuList) == "" {
+		return nil, fmt.Errorf("cpu list is empty")
+	}
+
+	var cpus []int
+	for _, part := range strings.Split(cpuList, ",") {
+		bounds := strings.SplitN(strings.TrimSpace(part), "-", 2)
+
+		start, err := strconv.Atoi(bounds[0])
+		if err != nil || start < 0 {
+			return nil, fmt.Errorf("invalid cpu %q", part)
+		}
+
+		end := start
+		if len(bounds) == 2 {
+			end, err = strconv.Atoi(bounds[1])
+			if err != nil || end < start {
+				return nil, fmt.Errorf("invalid cpu range %q", part)
+			}
+		}
+
+		for cpu := start; cpu <= end; cpu++ {
+			cpus = append(cpus, cpu)
+		}
+	}
+
+	return cpus, nil
+}
@@ -0,0 +1,178 @@
+package fio
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jtaleric/k8s-io/pkg/config"
+	"github.com/jtaleric/k8s-io/pkg/prometheus"
+	"github.com/jtaleric/k8s-io/pkg/timeline"
+)
+
+// CooldownConfig pauses the client after every sample, so the next sample does not start
+// while the storage is still busy with the previous one, such as thin-provisioned volumes
+// reclaiming space or SSDs collecting garbage
+type CooldownConfig struct {
+	Duration           string  `yaml:"duration,omitempty"`              // Fixed pause after every sample, e.g. 30s
+	MaxNodeCPUPercent  float64 `yaml:"max_node_cpu_percent,omitempty"`  // And until the CPU utilization of every server node is below
+	MaxDiskBusyPercent float64 `yaml:"max_disk_busy_percent,omitempty"` // And until the busiest disk of every server node is below
+	Timeout            string  `yaml:"timeout,omitempty"`               // Longest wait for the utilization, 10m by default
+}
+
+// defaultCooldownTimeout is how long a cool-down waits for the utilization by default
+const defaultCooldownTimeout = 10 * time.Minute
+
+// cooldownPollInterval is how often the utilization of the server nodes is queried
+const cooldownPollInterval = 10 * time.Second
+
+// cooldownCPUQuery returns the CPU utilization of every node in percent over the last minute
+const cooldownCPUQuery = `100 * (1 - avg by (nodename) (avg by (instance) (rate(node_cpu_seconds_total{mode="idle"}[1m])) * on (instance) group_left (nodename) node_uname_info))`
+
+// cooldownDiskQuery returns the busy time of the busiest disk of every node in percent over the last minute
+const cooldownDiskQuery = `100 * max by (nodename) (max by (instance) (rate(node_disk_io_time_seconds_total[1m])) * on (instance) group_left (nodename) node_uname_info)`
+
+// validateCooldown checks the cool-down settings
+func (f *FIOConfig) validateCooldown() error {
+	c := f.Cooldown
+	if c == nil {
+		return nil
+	}
+	if !f.SampleBarrier {
+		return fmt.Errorf("cooldown requires sample_barrier, without it all samples run in one snafu invocation")
+	}
+	if c.Duration == "" && !c.WaitsForUtilization() {
+		return fmt.Errorf("cooldown needs a duration, max_node_cpu_percent or max_disk_busy_percent")
+	}
+	if c.Duration != "" {
+		if _, err := positiveDuration("cooldown.duration", c.Duration); err != nil {
+			return err
+		}
+	}
+	for name, p := range map[string]float64{"max_node_cpu_percent": c.MaxNodeCPUPercent, "max_disk_busy_percent": c.MaxDiskBusyPercent} {
+		if p < 0 || p >= 100 {
+			return fmt.Errorf("cooldown.%s must be between 0 and 100, got %g", name, p)
+		}
+	}
+	if c.Timeout != "" {
+		if !c.WaitsForUtilization() {
+			return fmt.Errorf("cooldown.timeout requires max_node_cpu_percent or max_disk_busy_percent")
+		}
+		if _, err := positiveDuration("cooldown.timeout", c.Timeout); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WaitsForUtilization reports whether the cool-down waits for the server nodes to be idle
+func (c *CooldownConfig) WaitsForUtilization() bool {
+	return c != nil && (c.MaxNodeCPUPercent > 0 || c.MaxDiskBusyPercent > 0)
+}
+
+// Seconds returns the fixed pause after every sample in whole seconds, for the client
+func (c *CooldownConfig) Seconds() int {
+	if c == nil || c.Duration == "" {
+		return 0
+	}
+	d, _ := time.ParseDuration(c.Duration)
+	return int(d.Round(time.Second).Seconds())
+}
+
+// timeout returns the longest wait for the utilization
+func (c *CooldownConfig) timeout() time.Duration {
+	if c == nil || !c.WaitsForUtilization() {
+		return 0
+	}
+	if d, err := time.ParseDuration(c.Timeout); err == nil {
+		return d
+	}
+	return defaultCooldownTimeout
+}
+
+// sampleGate reports whether the client waits to be released after every sample, by the
+// post_sample hooks or a cool-down waiting for the utilization of the server nodes
+func sampleGate(cfg *config.Config, fioConfig *FIOConfig) bool {
+	return len(sampleHooks(cfg, fioConfig)) > 0 || fioConfig.Cooldown.WaitsForUtilization()
+}
+
+// cooldown waits until the CPU and disks of the server nodes are below the cool-down
+// thresholds, or its timeout. A cool-down that cannot query the utilization only warns, so
+// it never fails the run.
+func (w *Workload) cooldown(ctx context.Context, sample string) {
+	c := w.fioConfig.Cooldown
+	if !c.WaitsForUtilization() {
+		return
+	}
+	if w.cooldownProm == nil {
+		promInfo, err := w.k8sClient.DiscoverPrometheusWithConfig(ctx, w.config.Prometheus)
+		if err != nil || !promInfo.Found {
+			log.Printf("Warning: the cool-down after sample %s cannot query the server nodes without Prometheus: %v", sample, err)
+			return
+		}
+		w.cooldownProm = prometheus.NewClient(promInfo.URL, promInfo.Token)
+	}
+
+	nodes := make(map[string]bool)
+	for _, node := range w.podDetails {
+		nodes[node] = true
+	}
+
+	start := time.Now()
+	deadline := start.Add(c.timeout())
+	for {
+		busy, err := w.busyServerNodes(ctx, nodes)
+		if err != nil {
+			log.Printf("Warning: the cool-down after sample %s failed to query the server nodes: %v", sample, err)
+			return
+		}
+		if len(busy) == 0 {
+			waited := time.Since(start).Round(time.Second)
+			log.Printf("Server nodes cooled down after sample %s in %s", sample, waited)
+			timeline.Record(timeline.EventWait, sample, "server nodes cooled down in %s", waited)
+			return
+		}
+		if time.Now().After(deadline) {
+			log.Printf("Warning: server nodes still busy %s after sample %s, starting the next sample: %s", c.timeout(), sample, strings.Join(busy, ", "))
+			return
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(cooldownPollInterval):
+		}
+	}
+}
+
+// busyServerNodes returns the server nodes above a cool-down threshold with their utilization
+func (w *Workload) busyServerNodes(ctx context.Context, nodes map[string]bool) ([]string, error) {
+	c := w.fioConfig.Cooldown
+	var busy []string
+	for _, q := range []struct {
+		name, query string
+		max         float64
+	}{
+		{"CPU", cooldownCPUQuery, c.MaxNodeCPUPercent},
+		{"disk", cooldownDiskQuery, c.MaxDiskBusyPercent},
+	} {
+		if q.max <= 0 {
+			continue
+		}
+		values, err := queryByNodename(ctx, w.cooldownProm, q.query, time.Time{})
+		if err != nil {
+			return nil, err
+		}
+		for node := range nodes {
+			value, found := lookupNodename(values, node)
+			if found && value >= q.max {
+				busy = append(busy, fmt.Sprintf("%s %s %.0f%%", node, q.name, value))
+			}
+		}
+	}
+	sort.Strings(busy)
+	return busy, nil
+}
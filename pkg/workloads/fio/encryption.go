@@ -0,0 +1,351 @@
+package fio
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// Verdicts of the encryption check of a volume
+const (
+	EncryptionEncrypted    = "encrypted"    // The backing device has no pattern and the entropy of ciphertext
+	EncryptionPlaintext    = "plaintext"    // The pattern is readable on the backing device
+	EncryptionInconclusive = "inconclusive" // The pattern was not written, or the backing device is neither
+	EncryptionFailed       = "failed"       // The inspector did not report
+)
+
+// encryptionTarget is a volume of a server and where its device is on the node
+type encryptionTarget struct {
+	Server string
+	Node   string
+	PodUID string
+	Volume string
+	PV     string
+}
+
+// EncryptionScan is what the inspector read from a device
+type EncryptionScan struct {
+	Rows      int64      `json:"rows"` // 16-byte rows read
+	Hits      int64      `json:"hits"` // Rows holding the pattern
+	Histogram [256]int64 `json:"-"`
+}
+
+// Entropy returns the Shannon entropy of the bytes read in bits per byte, 8 for random data
+func (s EncryptionScan) Entropy() float64 {
+	var total int64
+	for _, n := range s.Histogram {
+		total += n
+	}
+	if total == 0 {
+		return 0
+	}
+	entropy := 0.0
+	for _, n := range s.Histogram {
+		if n > 0 {
+			p := float64(n) / float64(total)
+			entropy -= p * math.Log2(p)
+		}
+	}
+	return entropy
+}
+
+// EncryptionResult is the encryption check of a server volume, as written to encryption.json
+type EncryptionResult struct {
+	Server     string         `json:"server"`
+	Node       string         `json:"node"`
+	Volume     string         `json:"volume"`
+	PV         string         `json:"pv"`
+	Device     string         `json:"device,omitempty"`  // Device the pod writes to
+	Backing    string         `json:"backing,omitempty"` // Lowest device of the device-mapper stack
+	Layers     []string       `json:"layers,omitempty"`  // Devices from the pod device down to the backing device
+	CryptLayer bool           `json:"cryptLayer"`        // Whether a dm-crypt device is in the stack
+	Written    EncryptionScan `json:"written"`           // Read from the start of the pod device
+	Backed     EncryptionScan `json:"backed"`            // Read from 16MiB of the backing device
+	Entropy    float64        `json:"entropy"`           // Bits per byte of the backing device read
+	Verdict    string         `json:"verdict"`
+	Note       string         `json:"note,omitempty"`
+}
+
+// ParseEncryptionCheck reads the device stack and the scans from the logs of an inspector
+func ParseEncryptionCheck(logs string) (EncryptionResult, error) {
+	var result EncryptionResult
+	var device, written, backed bool
+	scanner := bufio.NewScanner(strings.NewReader(logs))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		values := make(map[string]string)
+		for _, field := range fields[1:] {
+			if key, value, ok := strings.Cut(field, "="); ok {
+				values[key] = value
+			}
+		}
+
+		switch fields[0] {
+		case "FIO_ENCRYPTION_DEVICE":
+			result.Device = values["top"]
+			result.Backing = values["lower"]
+			result.Layers = strings.Split(values["layers"], ",")
+			result.CryptLayer = values["crypt"] == "1"
+			device = true
+		case "FIO_ENCRYPTION_SCAN":
+			scan, err := parseEncryptionScan(values)
+			if err != nil {
+				return result, err
+			}
+			switch values["device"] {
+			case "top":
+				result.Written, written = scan, true
+			case "lower":
+				result.Backed, backed = scan, true
+			}
+		}
+	}
+	if !device || !written || !backed {
+		return result, fmt.Errorf("the inspector did not report the device and both scans")
+	}
+	result.Entropy = result.Backed.Entropy()
+	return result, nil
+}
+
+// parseEncryptionScan parses the rows, hits and byte histogram of a scan
+func parseEncryptionScan(values map[string]string) (EncryptionScan, error) {
+	var scan EncryptionScan
+	var err error
+	if scan.Rows, err = strconv.ParseInt(values["rows"], 10, 64); err != nil {
+		return scan, fmt.Errorf("invalid scan rows %q", values["rows"])
+	}
+	if scan.Hits, err = strconv.ParseInt(values["hits"], 10, 64); err != nil {
+		return scan, fmt.Errorf("invalid scan hits %q", values["hits"])
+	}
+	counts := strings.Split(values["hist"], ",")
+	if len(counts) != len(scan.Histogram) {
+		return scan, fmt.Errorf("scan histogram has %d values instead of 256", len(counts))
+	}
+	for i, count := range counts {
+		if scan.Histogram[i], err = strconv.ParseInt(count, 10, 64); err != nil {
+			return scan, fmt.Errorf("invalid scan histogram value %q", count)
+		}
+	}
+	return scan, nil
+}
+
+// judge sets the verdict of the result. The pattern must have reached the pod device, and the
+// backing device must neither hold it nor look less random than ciphertext.
+func (r *EncryptionResult) judge(minEntropy float64) {
+	switch {
+	case r.Written.Hits == 0:
+		r.Verdict = EncryptionInconclusive
+		r.Note = "the pattern was not found on the device of the pod after writing it"
+	case r.Backed.Hits > 0 && r.CryptLayer:
+		r.Verdict = EncryptionPlaintext
+		r.Note = "the pattern is readable below the dm-crypt device"
+	case r.Backed.Hits > 0:
+		r.Verdict = EncryptionPlaintext
+		r.Note = "no dm-crypt device on the node, encryption by the storage backend cannot be observed from the node"
+	case r.Entropy < minEntropy:
+		r.Verdict = EncryptionInconclusive
+		r.Note = fmt.Sprintf("the backing device does not hold the pattern but has %.3f bits/byte, less than min_entropy %.3f", r.Entropy, minEntropy)
+	default:
+		r.Verdict = EncryptionEncrypted
+	}
+}
+
+// encryptionTargets returns the raw block volumes of the servers with the node and pod they
+// are mapped into
+func (w *Workload) encryptionTargets(ctx context.Context) ([]encryptionTarget, error) {
+	paths := make(map[string]string)
+	for _, volume := range w.fioConfig.DataVolumes() {
+		paths[volume.Name] = volume.Path
+	}
+
+	labelSelector := fmt.Sprintf("app=fio-benchmark-%s", w.config.GetTruncatedUUID())
+	var targets []encryptionTarget
+	for _, ns := range w.serverNamespaces() {
+		pods, err := w.k8sClient.ListPods(ctx, ns, labelSelector)
+		if err != nil {
+			return nil, err
+		}
+		volumes, err := w.k8sClient.ListPodVolumes(ctx, ns, labelSelector)
+		if err != nil {
+			return nil, err
+		}
+		for _, pod := range pods.Items {
+			for _, volume := range volumes[pod.Status.PodIP] {
+				path, ok := paths[volume.Name]
+				if !ok || volume.Claim == "" || volume.Device == "" {
+					continue
+				}
+				targets = append(targets, encryptionTarget{
+					Server: pod.Name,
+					Node:   pod.Spec.NodeName,
+					PodUID: string(pod.UID),
+					Volume: path,
+					PV:     volume.Device,
+				})
+			}
+		}
+	}
+
+	sort.Slice(targets, func(i, j int) bool {
+		if targets[i].Server != targets[j].Server {
+			return targets[i].Server < targets[j].Server
+		}
+		return targets[i].Volume < targets[j].Volume
+	})
+	return targets, nil
+}
+
+// checkEncryption writes the pattern to the device of every server volume from a privileged
+// inspector on its node and reads the device backing it, to confirm the data is encrypted at
+// rest. Inspectors that fail only fail the check of their volume.
+func (w *Workload) checkEncryption(ctx context.Context) ([]EncryptionResult, error) {
+	targets, err := w.encryptionTargets(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find the volumes of the servers: %w", err)
+	}
+	if len(targets) == 0 {
+		return nil, fmt.Errorf("the servers have no bound block volumes")
+	}
+
+	jobs := make([]string, len(targets))
+	defer func() {
+		for _, job := range jobs {
+			if job == "" {
+				continue
+			}
+			if err := w.k8sClient.DeleteJob(ctx, job, w.config.Namespace); err != nil {
+				log.Printf("Warning: failed to delete encryption inspector: %v", err)
+			}
+		}
+	}()
+	for i, target := range targets {
+		manifest, err := w.templateEngine.RenderFIOEncryptionCheck(w.config, w.fioConfig, i+1, target)
+		if err != nil {
+			return nil, fmt.Errorf("failed to render encryption inspector: %w", err)
+		}
+		if err := w.k8sClient.ApplyManifest(ctx, manifest, w.config.Namespace); err != nil {
+			return nil, fmt.Errorf("failed to apply encryption inspector: %w", err)
+		}
+		jobs[i] = fmt.Sprintf("fio-encryption-%d-%s", i+1, w.config.GetTruncatedUUID())
+	}
+	log.Printf("Checking the encryption of %d volume(s)", len(targets))
+
+	results := make([]EncryptionResult, len(targets))
+	for i, target := range targets {
+		results[i] = w.inspectEncryption(ctx, jobs[i], target)
+	}
+	return results, nil
+}
+
+// inspectEncryption waits for the inspector of a volume and judges what it read
+func (w *Workload) inspectEncryption(ctx context.Context, job string, target encryptionTarget) EncryptionResult {
+	failed := func(err error) EncryptionResult {
+		log.Printf("Warning: encryption check of %s on %s failed: %v", target.Volume, target.Server, err)
+		return EncryptionResult{Server: target.Server, Node: target.Node, Volume: target.Volume, PV: target.PV,
+			Verdict: EncryptionFailed, Note: err.Error()}
+	}
+
+	if err := w.k8sClient.WaitForJobCompletion(ctx, job, w.config.Namespace, 30*time.Minute); err != nil {
+		if logs, lerr := w.k8sClient.GetJobPodLogs(ctx, job, w.config.Namespace); lerr == nil && strings.TrimSpace(logs) != "" {
+			lines := strings.Split(strings.TrimSpace(logs), "\n")
+			return failed(fmt.Errorf("%w: %s", err, lines[len(lines)-1]))
+		}
+		return failed(err)
+	}
+	logs, err := w.k8sClient.GetJobPodLogs(ctx, job, w.config.Namespace)
+	if err != nil {
+		return failed(err)
+	}
+	result, err := ParseEncryptionCheck(logs)
+	if err != nil {
+		return failed(err)
+	}
+	result.Server, result.Node, result.Volume, result.PV = target.Server, target.Node, target.Volume, target.PV
+	result.judge(w.fioConfig.EncryptionCheck.MinEntropy)
+	return result
+}
+
+// encryptionReport is the encryption.json artifact
+type encryptionReport struct {
+	Pattern    string             `json:"pattern"`
+	SampleMiB  int                `json:"sampleMiB"`
+	MinEntropy float64            `json:"minEntropy"`
+	Verdict    string             `json:"verdict"`
+	Volumes    []EncryptionResult `json:"volumes"`
+}
+
+// encryptionVerdict returns the verdict of the run, the worst of its volumes
+func encryptionVerdict(results []EncryptionResult) string {
+	rank := map[string]int{EncryptionEncrypted: 0, EncryptionInconclusive: 1, EncryptionFailed: 2, EncryptionPlaintext: 3}
+	verdict := EncryptionEncrypted
+	for _, r := range results {
+		if rank[r.Verdict] > rank[verdict] {
+			verdict = r.Verdict
+		}
+	}
+	return verdict
+}
+
+// reportEncryption prints the verdict of every volume, records the verdict of the run in the
+// metadata and writes the report to encryption.json in the run artifacts
+func (w *Workload) reportEncryption(results []EncryptionResult) error {
+	ec := w.fioConfig.EncryptionCheck
+	verdict := encryptionVerdict(results)
+
+	fmt.Println("\n=== Encryption at Rest ===")
+	fmt.Printf("Storage class: %s, pattern 0x%s over %d MiB, min entropy %.2f bits/byte\n\n", w.storageClass,
+		strings.TrimPrefix(strings.ToLower(ec.Pattern), "0x"), ec.SampleMiB, ec.MinEntropy)
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "Server\tVolume\tNode\tDevices\tdm-crypt\tPattern Written\tPattern Backing\tEntropy\tVerdict")
+	for _, r := range results {
+		crypt := "no"
+		if r.CryptLayer {
+			crypt = "yes"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%d/%d\t%d/%d\t%.3f\t%s\n", r.Server, r.Volume, r.Node, strings.Join(r.Layers, " > "),
+			crypt, r.Written.Hits, r.Written.Rows, r.Backed.Hits, r.Backed.Rows, r.Entropy, r.Verdict)
+	}
+	tw.Flush()
+	for _, r := range results {
+		if r.Note != "" {
+			fmt.Printf("%s %s: %s\n", r.Server, r.Volume, r.Note)
+		}
+	}
+	fmt.Printf("\nVerdict: %s\n", verdict)
+	if verdict != EncryptionEncrypted {
+		log.Printf("Warning: the data of storage class %s is %s at rest", w.storageClass, verdict)
+	}
+
+	if w.config.Metadata == nil {
+		w.config.Metadata = make(map[string]string)
+	}
+	w.config.Metadata["encryptionAtRest"] = verdict
+
+	dir := w.config.RunArtifactsDir()
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create artifacts directory: %w", err)
+	}
+	data, err := json.MarshalIndent(encryptionReport{Pattern: ec.Pattern, SampleMiB: ec.SampleMiB, MinEntropy: ec.MinEntropy,
+		Verdict: verdict, Volumes: results}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode encryption report: %w", err)
+	}
+	filename := filepath.Join(dir, "encryption.json")
+	if err := os.WriteFile(filename, data, 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", filename, err)
+	}
+	return nil
+}
@@ -0,0 +1,177 @@
+package fio
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+
+	"github.com/jtaleric/k8s-io/pkg/estimate"
+)
+
+// Estimate returns the cluster footprint of the generated manifests and the expected
+// duration of the benchmark
+func (w *Workload) Estimate() (*estimate.Estimate, error) {
+	manifests, err := w.GenerateManifests()
+	if err != nil {
+		return nil, err
+	}
+
+	e, err := estimate.FromManifests(manifests)
+	if err != nil {
+		return nil, err
+	}
+
+	duration, notes := w.estimateDuration()
+	e.Duration = duration
+	e.Notes = append(e.Notes, notes...)
+	return e, nil
+}
+
+// estimateDuration returns the expected duration of the prefill and benchmark jobs
+func (w *Workload) estimateDuration() (time.Duration, []string) {
+	duration, notes := w.benchmarkDuration()
+
+	if w.fioConfig.Prefill {
+		if prefill, ok := w.prefillDuration(); ok {
+			duration += prefill
+		} else {
+			notes = append(notes, fmt.Sprintf("prefill writes %s per job before the benchmark, not included; set prefill_rate to estimate it", w.fioConfig.FileSize))
+		}
+		duration += time.Duration(w.fioConfig.PostPrefillSleep) * time.Second
+	}
+	return duration, notes
+}
+
+// benchmarkDuration adds up the runtime and ramp_time of every case and sample of the
+// client job. Cases run one after the other, each on all servers at once.
+func (w *Workload) benchmarkDuration() (time.Duration, []string) {
+	var notes []string
+
+	sizes := len(w.fioConfig.BS)
+	if len(w.fioConfig.BSRange) > 0 {
+		sizes = len(w.fioConfig.BSRange)
+	}
+	combinations := sizes * len(w.fioConfig.NumJobs)
+
+	var perSample time.Duration
+	for _, job := range w.fioConfig.Jobs {
+		runtime, ramp := w.jobRuntime(job)
+		if runtime == 0 {
+			notes = append(notes, fmt.Sprintf("job %s has no runtime in its profile or job_params and runs until %s is transferred, not included", job, w.fioConfig.FileSize))
+		}
+		perSample += time.Duration(combinations) * (runtime + ramp)
+	}
+	if pause := w.fioConfig.Cooldown.Seconds(); pause > 0 {
+		perSample += time.Duration(combinations*len(w.fioConfig.Jobs)) * time.Duration(pause) * time.Second
+	}
+
+	if len(sampleHooks(w.config, w.fioConfig)) > 0 {
+		notes = append(notes, "post_sample hooks run after every sample, not included; the client also needs up to a minute to see each release")
+	}
+	if c := w.fioConfig.Cooldown; c.WaitsForUtilization() {
+		notes = append(notes, fmt.Sprintf("the cool-down waits up to %s after every sample for the server nodes to be idle, not included", c.timeout()))
+	}
+
+	return time.Duration(w.fioConfig.Samples) * perSample, notes
+}
+
+// prefillDuration returns the time the servers need to write their files at prefill_rate,
+// and false when it cannot be estimated
+func (w *Workload) prefillDuration() (time.Duration, bool) {
+	if w.fioConfig.PrefillRate == "" {
+		return 0, false
+	}
+	rate, err := parseFIOSize(w.fioConfig.PrefillRate)
+	if err != nil || rate <= 0 {
+		return 0, false
+	}
+	size, err := parseFIOSize(w.fioConfig.FileSize)
+	if err != nil {
+		return 0, false
+	}
+
+	// Every server writes one file per job in parallel with the others
+	numjobs := 0
+	for _, n := range w.fioConfig.NumJobs {
+		numjobs = max(numjobs, n)
+	}
+	seconds := float64(size) * float64(numjobs) / float64(rate)
+	return time.Duration(seconds * float64(time.Second)), true
+}
+
+// checkTimeouts fails when job_timeout is too short for the planned prefill or benchmark
+func (w *Workload) checkTimeouts() error {
+	timeout := time.Duration(w.fioConfig.JobTimeout) * time.Second
+
+	if planned, _ := w.benchmarkDuration(); planned > 0 {
+		if err := estimate.CheckTimeout("benchmark", planned, timeout); err != nil {
+			return err
+		}
+	}
+	if w.fioConfig.Prefill {
+		if planned, ok := w.prefillDuration(); ok {
+			if err := estimate.CheckTimeout("prefill", planned, timeout); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// setETA logs when a job that just started is expected to complete and records it on the job
+func (w *Workload) setETA(ctx context.Context, jobName string, planned time.Duration) {
+	eta := time.Now().Add(planned)
+	log.Printf("Job %s expected to complete in %s (ETA %s)", jobName, planned.Round(time.Second), eta.Format(time.Kitchen))
+	if err := w.k8sClient.SetJobETA(ctx, jobName, w.config.Namespace, eta); err != nil {
+		log.Printf("Warning: failed to record ETA on job %s: %v", jobName, err)
+	}
+}
+
+// jobRuntime returns the runtime and ramp_time set for a job by the profile or job_params
+func (w *Workload) jobRuntime(job string) (time.Duration, time.Duration) {
+	var runtime, ramp time.Duration
+	for _, param := range w.fioConfig.JobParams(job, w.config.JobParams) {
+		key, value, ok := strings.Cut(param, "=")
+		if !ok {
+			continue
+		}
+		switch strings.TrimSpace(key) {
+		case "runtime":
+			runtime = parseFIOTime(value)
+		case "ramp_time":
+			ramp = parseFIOTime(value)
+		}
+	}
+	return runtime, ramp
+}
+
+// parseFIOTime parses an FIO time value, which is in seconds unless it has a unit suffix
+func parseFIOTime(value string) time.Duration {
+	value = strings.TrimSpace(value)
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return time.Duration(seconds) * time.Second
+	}
+	if d, err := time.ParseDuration(value); err == nil {
+		return d
+	}
+	return 0
+}
+
+// parseFIOSize parses an FIO size such as 1G, 4096KiB or 500MiB into bytes. Like the job
+// files, which set kb_base=1000, units without an i are decimal.
+func parseFIOSize(value string) (int64, error) {
+	value = strings.TrimSuffix(strings.TrimSpace(value), "B")
+	if strings.HasSuffix(value, "K") {
+		value = strings.TrimSuffix(value, "K") + "k"
+	}
+	q, err := resource.ParseQuantity(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid size %q", value)
+	}
+	return q.Value(), nil
+}
@@ -0,0 +1,284 @@
+package fio
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+
+	"github.com/jtaleric/k8s-io/pkg/timeline"
+	"github.com/jtaleric/k8s-io/pkg/units"
+)
+
+// expansionPhasePrefix starts the name of the timeline phase of every expanded PVC
+const expansionPhasePrefix = "expand "
+
+// ExpansionImpact compares the throughput of a job, block size and numjobs combination
+// during the PVC expansion with its throughput outside of it
+type ExpansionImpact struct {
+	Case         string  `json:"case"`         // <job>_<bs>_<numjobs>
+	Samples      int     `json:"samples"`      // Samples overlapping the expansion
+	DuringIOPS   float64 `json:"duringIOPS"`   // Mean IOPS of the samples overlapping the expansion
+	BaselineIOPS float64 `json:"baselineIOPS"` // Mean IOPS of the other samples, 0 if every sample overlapped
+	DuringBW     float64 `json:"duringBW"`     // KiB/s
+	BaselineBW   float64 `json:"baselineBW"`   // KiB/s
+}
+
+// Change returns the relative IOPS change during the expansion in percent, 0 without a baseline
+func (i ExpansionImpact) Change() float64 {
+	if i.BaselineIOPS == 0 {
+		return 0
+	}
+	return (i.DuringIOPS - i.BaselineIOPS) / i.BaselineIOPS * 100
+}
+
+// sampleWindow returns when the servers of a sample did I/O, from the job start and
+// runtime fio reports for every server
+func sampleWindow(result *FIOResult) (time.Time, time.Time, bool) {
+	var start, end time.Time
+	for _, client := range result.ClientStats {
+		if client.JobName == "All clients" || client.JobStart == 0 {
+			continue
+		}
+		s := time.UnixMilli(client.JobStart)
+		e := s.Add(time.Duration(client.JobRuntime) * time.Millisecond)
+		if start.IsZero() || s.Before(start) {
+			start = s
+		}
+		if e.After(end) {
+			end = e
+		}
+	}
+	return start, end, !start.IsZero()
+}
+
+// ExpansionImpacts compares the throughput of the samples of every combination that
+// overlapped the expansion window with the samples that did not
+func ExpansionImpacts(results []*FIOResult, uuid string, window timeline.Phase) []ExpansionImpact {
+	type totals struct {
+		during, baseline     int
+		duringIOPS, baseIOPS float64
+		duringBW, baselineBW float64
+	}
+	byCase := make(map[string]*totals)
+	var order []string
+
+	for _, result := range results {
+		start, end, ok := sampleWindow(result)
+		if !ok {
+			continue
+		}
+		sample := strings.TrimPrefix(result.ID, uuid+"_")
+		if i := strings.LastIndex(sample, "-"); i > 0 {
+			sample = sample[:i]
+		}
+
+		var iops, bw float64
+		for _, client := range result.ClientStats {
+			if client.JobName == "All clients" {
+				continue
+			}
+			iops += client.Read.IOPS + client.Write.IOPS
+			bw += float64(client.Read.BW + client.Write.BW)
+		}
+
+		t, ok := byCase[sample]
+		if !ok {
+			t = &totals{}
+			byCase[sample] = t
+			order = append(order, sample)
+		}
+		if start.Before(window.End) && end.After(window.Start) {
+			t.during++
+			t.duringIOPS += iops
+			t.duringBW += bw
+		} else {
+			t.baseline++
+			t.baseIOPS += iops
+			t.baselineBW += bw
+		}
+	}
+
+	var impacts []ExpansionImpact
+	for _, c := range order {
+		t := byCase[c]
+		if t.during == 0 {
+			continue
+		}
+		impact := ExpansionImpact{
+			Case:       c,
+			Samples:    t.during,
+			DuringIOPS: t.duringIOPS / float64(t.during),
+			DuringBW:   t.duringBW / float64(t.during),
+		}
+		if t.baseline > 0 {
+			impact.BaselineIOPS = t.baseIOPS / float64(t.baseline)
+			impact.BaselineBW = t.baselineBW / float64(t.baseline)
+		}
+		impacts = append(impacts, impact)
+	}
+	return impacts
+}
+
+// checkExpansion fails the run before anything is deployed when the storage class does not
+// allow volume expansion
+func (w *Workload) checkExpansion(ctx context.Context) error {
+	allowed, err := w.k8sClient.StorageClassAllowsExpansion(ctx, w.storageClass)
+	if err != nil {
+		return err
+	}
+	if !allowed {
+		return fmt.Errorf("storage class %s does not set allowVolumeExpansion", w.storageClass)
+	}
+	return nil
+}
+
+// startExpansion expands the server PVCs once expansion.delay has passed since the
+// benchmark client started. The returned function waits for the expansion and returns a
+// phase per PVC; when the benchmark finished before the delay, nothing is expanded.
+func (w *Workload) startExpansion(ctx context.Context) func() []timeline.Phase {
+	delayCtx, cancel := context.WithCancel(ctx)
+	done := make(chan []timeline.Phase, 1)
+
+	go func() {
+		select {
+		case <-delayCtx.Done():
+			log.Printf("Warning: the benchmark finished before expansion.delay, the PVCs were not expanded")
+			done <- nil
+			return
+		case <-time.After(time.Duration(w.fioConfig.Expansion.Delay) * time.Second):
+		}
+		done <- w.expandVolumes(ctx)
+	}()
+
+	return func() []timeline.Phase {
+		cancel()
+		return <-done
+	}
+}
+
+// expandVolumes expands every PVC of the servers concurrently and times how long each takes
+// to reach the new size
+func (w *Workload) expandVolumes(ctx context.Context) []timeline.Phase {
+	size := resource.MustParse(w.fioConfig.Expansion.Size)
+	timeout := time.Duration(w.fioConfig.Expansion.Timeout) * time.Second
+
+	var pvcs, namespaces []string
+	for i := 1; i <= w.fioConfig.Servers; i++ {
+		for _, volume := range w.fioConfig.DataVolumes() {
+			pvcs = append(pvcs, fmt.Sprintf("fio-claim-%d-%s%s", i, w.config.GetTruncatedUUID(), volume.Suffix))
+			namespaces = append(namespaces, w.fioConfig.ServerNamespace(i, w.config.Namespace))
+		}
+	}
+	log.Printf("Expanding %d PVC(s) to %s", len(pvcs), size.String())
+
+	phases := make([]timeline.Phase, len(pvcs))
+	var wg sync.WaitGroup
+	for i, pvc := range pvcs {
+		wg.Add(1)
+		go func(i int, pvc string) {
+			defer wg.Done()
+			phase := timeline.Phase{Name: expansionPhasePrefix + pvc, Start: time.Now().UTC()}
+			err := w.k8sClient.ExpandPVC(ctx, pvc, namespaces[i], size)
+			if err == nil {
+				err = w.k8sClient.WaitForPVCExpansion(ctx, pvc, namespaces[i], size, timeout)
+			}
+			phase.End = time.Now().UTC()
+			if err != nil {
+				phase.Error = err.Error()
+				log.Printf("Warning: PVC %s was not expanded: %v", pvc, err)
+			} else {
+				log.Printf("PVC %s expanded to %s in %s", pvc, size.String(), phase.Duration().Round(time.Second))
+			}
+			phases[i] = phase
+		}(i, pvc)
+	}
+	wg.Wait()
+
+	return phases
+}
+
+// expansionReport is the pvc-expansion.json artifact
+type expansionReport struct {
+	Size   string            `json:"size"`
+	PVCs   []timeline.Phase  `json:"pvcs"`
+	Impact []ExpansionImpact `json:"impact"`
+}
+
+// reportExpansion prints how long every PVC took to expand and the throughput of the
+// samples during the expansion against the other samples, and writes both to
+// pvc-expansion.json in the run artifacts
+func (w *Workload) reportExpansion(phases []timeline.Phase) error {
+	if len(phases) == 0 {
+		return nil
+	}
+
+	window := timeline.Phase{Name: "pvc-expansion", Start: phases[0].Start, End: phases[0].End}
+	for _, phase := range phases[1:] {
+		if phase.Start.Before(window.Start) {
+			window.Start = phase.Start
+		}
+		if phase.End.After(window.End) {
+			window.End = phase.End
+		}
+	}
+	impacts := ExpansionImpacts(w.results, w.config.UUID, window)
+	mode := units.Mode(w.config.Units)
+
+	fmt.Println("\n=== PVC Expansion ===")
+	fmt.Printf("Window: %s - %s\n\n", timeline.FormatUTC(window.Start), timeline.FormatUTC(window.End))
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "PVC\tDuration\tStatus")
+	for _, phase := range phases {
+		result := "expanded"
+		if phase.Error != "" {
+			result = "failed: " + phase.Error
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", strings.TrimPrefix(phase.Name, expansionPhasePrefix), phase.Duration().Round(time.Second), result)
+	}
+	tw.Flush()
+
+	if len(impacts) == 0 {
+		fmt.Println("\nNo sample overlapped the expansion, increase samples or reduce expansion.delay to measure its impact.")
+	} else {
+		fmt.Println()
+		tw = tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintf(tw, "Case\tSamples\tIOPS During\tIOPS Outside\tChange\t%s\t%s\n",
+			units.BandwidthHeader("BW During", mode), units.BandwidthHeader("BW Outside", mode))
+		for _, impact := range impacts {
+			change, baselineIOPS, baselineBW := "-", "-", "-"
+			if impact.BaselineIOPS > 0 {
+				change = fmt.Sprintf("%+.1f%%", impact.Change())
+				baselineIOPS = units.IOPS(impact.BaselineIOPS, mode)
+				baselineBW = units.Bandwidth(impact.BaselineBW, mode)
+			}
+			fmt.Fprintf(tw, "%s\t%d\t%s\t%s\t%s\t%s\t%s\n", impact.Case, impact.Samples,
+				units.IOPS(impact.DuringIOPS, mode), baselineIOPS, change,
+				units.Bandwidth(impact.DuringBW, mode), baselineBW)
+		}
+		tw.Flush()
+		fmt.Println("\nSamples count as during the expansion when their I/O overlapped the window. Combinations without samples outside it have no baseline.")
+	}
+
+	dir := w.config.RunArtifactsDir()
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create artifacts directory: %w", err)
+	}
+	data, err := json.MarshalIndent(expansionReport{Size: w.fioConfig.Expansion.Size, PVCs: phases, Impact: impacts}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode PVC expansion report: %w", err)
+	}
+	filename := filepath.Join(dir, "pvc-expansion.json")
+	if err := os.WriteFile(filename, data, 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", filename, err)
+	}
+	return nil
+}
@@ -0,0 +1,223 @@
+package fio
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	"github.com/jtaleric/k8s-io/pkg/timeline"
+)
+
+// failoverPhase is the timeline phase from the failure of the serving pods until they are ready again
+const failoverPhase = "failover"
+
+// ioStallPhase is the timeline phase of the longest stall found around the failover
+const ioStallPhase = "io-stall"
+
+// logStart returns when the job that wrote a latency log started: the job start of the
+// server the log is named after, or the first server of the sample
+func logStart(result *FIOResult, file string) (time.Time, bool) {
+	for _, client := range result.ClientStats {
+		if client.JobName == "All clients" || client.JobStart == 0 || client.Hostname == "" {
+			continue
+		}
+		if strings.HasSuffix(file, "."+client.Hostname) {
+			return time.UnixMilli(client.JobStart), true
+		}
+	}
+	start, _, ok := sampleWindow(result)
+	return start, ok
+}
+
+// FailoverStalls returns the longest stall of every latency log of the samples that
+// overlapped the failover window
+func FailoverStalls(results []*FIOResult, logs []LatencyLog, window timeline.Phase, threshold time.Duration) []Stall {
+	byID := make(map[string]*FIOResult, len(results))
+	for _, result := range results {
+		byID[result.ID] = result
+	}
+
+	var stalls []Stall
+	for _, lat := range logs {
+		result, ok := byID[lat.Sample]
+		if !ok {
+			continue
+		}
+		start, end, ok := sampleWindow(result)
+		if !ok || !start.Before(window.End) || !end.After(window.Start) {
+			continue
+		}
+		jobStart, ok := logStart(result, lat.File)
+		if !ok {
+			continue
+		}
+		if stall, ok := FindStall(lat, jobStart, threshold); ok {
+			stalls = append(stalls, stall)
+		}
+	}
+	return stalls
+}
+
+// startFailover fails the pods serving the RWX storage once failover.delay has passed since
+// the benchmark client started. The returned function waits for the pods to be replaced and
+// returns the failover phase; when the benchmark finished before the delay, nothing fails.
+func (w *Workload) startFailover(ctx context.Context) func() []timeline.Phase {
+	delayCtx, cancel := context.WithCancel(ctx)
+	done := make(chan []timeline.Phase, 1)
+
+	go func() {
+		select {
+		case <-delayCtx.Done():
+			log.Printf("Warning: the benchmark finished before failover.delay, the storage pods were not failed")
+			done <- nil
+			return
+		case <-time.After(time.Duration(w.fioConfig.Failover.Delay) * time.Second):
+		}
+		done <- []timeline.Phase{w.failStoragePods(ctx)}
+	}()
+
+	return func() []timeline.Phase {
+		cancel()
+		return <-done
+	}
+}
+
+// failStoragePods deletes the pods serving the storage, or drains the node of the first
+// one, and times how long the selector takes to have as many ready pods again
+func (w *Workload) failStoragePods(ctx context.Context) timeline.Phase {
+	fo := w.fioConfig.Failover
+	phase := timeline.Phase{Name: failoverPhase + " " + fo.Action, Start: time.Now().UTC()}
+	fail := func(err error) timeline.Phase {
+		phase.End = time.Now().UTC()
+		phase.Error = err.Error()
+		log.Printf("Warning: storage failover failed: %v", err)
+		return phase
+	}
+
+	pods, err := w.k8sClient.ListPods(ctx, fo.Namespace, fo.Selector)
+	if err != nil {
+		return fail(err)
+	}
+	if len(pods.Items) == 0 {
+		return fail(fmt.Errorf("no pods match %s in namespace %s", fo.Selector, fo.Namespace))
+	}
+
+	replaced := make(map[types.UID]bool)
+	switch fo.Action {
+	case "delete":
+		for _, pod := range pods.Items {
+			log.Printf("Deleting storage pod %s/%s on node %s", pod.Namespace, pod.Name, pod.Spec.NodeName)
+			if err := w.k8sClient.DeletePod(ctx, pod.Namespace, pod.Name); err != nil {
+				return fail(err)
+			}
+			replaced[pod.UID] = true
+		}
+	case "drain":
+		node := pods.Items[0].Spec.NodeName
+		if node == "" {
+			return fail(fmt.Errorf("storage pod %s is not scheduled", pods.Items[0].Name))
+		}
+		log.Printf("Draining node %s of storage pod %s/%s", node, fo.Namespace, pods.Items[0].Name)
+		if err := w.k8sClient.CordonNode(ctx, node, true); err != nil {
+			return fail(err)
+		}
+		defer func() {
+			if err := w.k8sClient.CordonNode(context.Background(), node, false); err != nil {
+				log.Printf("Warning: failed to uncordon node %s: %v", node, err)
+			}
+		}()
+		// The benchmark pods stay on the node so the run measures the storage failure only
+		evicted, err := w.k8sClient.DrainNode(ctx, node, func(pod corev1.Pod) bool {
+			return pod.Labels["benchmark-uuid"] == w.config.UUID
+		})
+		if err != nil {
+			return fail(err)
+		}
+		log.Printf("Evicted %d pod(s) from node %s", len(evicted), node)
+		for _, pod := range pods.Items {
+			if pod.Spec.NodeName == node {
+				replaced[pod.UID] = true
+			}
+		}
+	}
+
+	err = w.k8sClient.WaitForReplacementPods(ctx, fo.Namespace, fo.Selector, replaced, len(pods.Items),
+		time.Duration(fo.Timeout)*time.Second)
+	phase.End = time.Now().UTC()
+	if err != nil {
+		phase.Error = fmt.Sprintf("storage pods were not ready again: %v", err)
+		log.Printf("Warning: %s", phase.Error)
+		return phase
+	}
+	log.Printf("Storage pods ready again after %s", phase.Duration().Round(time.Second))
+	return phase
+}
+
+// failoverReport is the failover.json artifact
+type failoverReport struct {
+	Action   string         `json:"action"`
+	Failover timeline.Phase `json:"failover"`
+	Stalls   []Stall        `json:"stalls"`
+}
+
+// reportFailover prints the longest I/O stall of every latency log of the samples that
+// overlapped the failover with the time I/O took to recover, adds the longest to the timeline
+// and writes all of them to failover.json in the run artifacts
+func (w *Workload) reportFailover(phases []timeline.Phase) error {
+	if len(phases) == 0 {
+		return nil
+	}
+
+	window := phases[0]
+	threshold := time.Duration(w.fioConfig.Failover.StallThreshold) * time.Millisecond
+	stalls := FailoverStalls(w.results, w.latencyLogs, window, threshold)
+
+	fmt.Println("\n=== Storage Failover ===")
+	fmt.Printf("%s: %s - %s (%s)\n\n", window.Name, timeline.FormatUTC(window.Start), timeline.FormatUTC(window.End),
+		window.Duration().Round(time.Second))
+	if len(stalls) == 0 {
+		fmt.Printf("No I/O stalled for %s or longer in the samples overlapping the failover.\n", threshold)
+	} else {
+		longest := stalls[0]
+		tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "Sample\tLog\tStall Start\tStall\tRecovery")
+		for _, stall := range stalls {
+			recovery := "not recovered"
+			if r := stall.Recovery(); r >= 0 {
+				recovery = r.Round(time.Millisecond).String()
+			}
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", strings.TrimPrefix(stall.Sample, w.config.UUID+"_"), stall.File,
+				timeline.FormatUTC(stall.Start), stall.Duration().Round(time.Millisecond), recovery)
+			if stall.Duration() > longest.Duration() {
+				longest = stall
+			}
+		}
+		tw.Flush()
+		fmt.Println("\nStall is the longest window without completed I/O, recovery the time until latency was back to twice its median before the stall.")
+
+		w.timeline.Add(timeline.Phase{Name: ioStallPhase, Start: longest.Start.UTC(), End: longest.End.UTC()})
+	}
+
+	dir := w.config.RunArtifactsDir()
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create artifacts directory: %w", err)
+	}
+	data, err := json.MarshalIndent(failoverReport{Action: w.fioConfig.Failover.Action, Failover: window, Stalls: stalls}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode failover report: %w", err)
+	}
+	filename := filepath.Join(dir, "failover.json")
+	if err := os.WriteFile(filename, data, 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", filename, err)
+	}
+	return nil
+}
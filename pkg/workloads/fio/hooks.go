@@ -0,0 +1,108 @@
+package fio
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/jtaleric/k8s-io/pkg/config"
+	"github.com/jtaleric/k8s-io/pkg/hooks"
+)
+
+// sampleHookPropagation is how long the kubelet may take to update the hooks ConfigMap
+// mounted in the client after it is changed
+const sampleHookPropagation = 2 * time.Minute
+
+// sampleHooks returns the post_sample hooks, which need sample barriers to run between samples
+func sampleHooks(cfg *config.Config, fioConfig *FIOConfig) []config.Hook {
+	if cfg.Hooks == nil || !fioConfig.SampleBarrier {
+		return nil
+	}
+	return cfg.Hooks.PostSample
+}
+
+// sampleHookTimeout returns how many seconds the client waits for the post_sample hooks and
+// cool-down of a sample
+func sampleHookTimeout(cfg *config.Config, fioConfig *FIOConfig) int {
+	timeout := sampleHookPropagation + fioConfig.Cooldown.timeout()
+	for _, h := range sampleHooks(cfg, fioConfig) {
+		d, _ := time.ParseDuration(h.Timeout)
+		timeout += d
+	}
+	return int(timeout.Seconds())
+}
+
+// followSampleHooks follows the logs of the client pods and runs the post_sample hooks and
+// the cool-down after every sample. The client waits until they have set the key of the
+// sample in the hooks ConfigMap, to "ok" or "failed". It returns when ctx is done.
+func (w *Workload) followSampleHooks(ctx context.Context) {
+	runner := hooks.NewRunner(w.k8sClient, w.config)
+	selector := fmt.Sprintf("job-name=fio-client-%s", w.config.GetTruncatedUUID())
+	handled := make(map[string]bool)
+
+	for ctx.Err() == nil {
+		pods, err := w.k8sClient.ListPods(ctx, w.config.Namespace, selector)
+		if err != nil && ctx.Err() == nil {
+			log.Printf("Warning: failed to list client pods to release samples: %v", err)
+		}
+		if pods != nil {
+			for _, pod := range pods.Items {
+				if pod.Status.Phase == corev1.PodRunning {
+					w.followClientPod(ctx, runner, pod.Name, handled)
+				}
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+		case <-time.After(5 * time.Second):
+		}
+	}
+}
+
+// followClientPod runs the post_sample hooks and cool-down for the samples a client pod
+// finishes, until its log ends. Samples in handled are skipped, so a broken log stream can be followed again.
+func (w *Workload) followClientPod(ctx context.Context, runner *hooks.Runner, podName string, handled map[string]bool) {
+	stream, err := w.k8sClient.GetPodLogsStream(ctx, w.config.Namespace, podName, "fio-client", true)
+	if err != nil {
+		if ctx.Err() == nil {
+			log.Printf("Warning: failed to follow client pod %s to release samples: %v", podName, err)
+		}
+		return
+	}
+	defer stream.Close()
+
+	scanner := bufio.NewScanner(stream)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 3 || fields[0] != sampleEndMarker {
+			continue
+		}
+
+		// The client waits for /tmp/hooks/<pod>.<job>_<bs>_<numjobs>-<sample>
+		key := podName + "." + strings.TrimPrefix(fields[1], w.config.UUID+"_")
+		if handled[key] {
+			continue
+		}
+		handled[key] = true
+
+		result := "ok"
+		if postSample := sampleHooks(w.config, w.fioConfig); len(postSample) > 0 {
+			if err := runner.Run(ctx, hooks.StagePostSample, postSample, map[string]string{"K8SIO_SAMPLE": fields[1]}); err != nil {
+				log.Printf("Warning: %v", err)
+				result = "failed"
+			}
+		}
+		if result == "ok" {
+			w.cooldown(ctx, fields[1])
+		}
+		if err := w.k8sClient.SetConfigMapKey(ctx, "fio-hooks-"+w.config.GetTruncatedUUID(), w.config.Namespace, key, result); err != nil {
+			log.Printf("Warning: failed to release sample %s: %v", fields[1], err)
+		}
+	}
+}
@@ -0,0 +1,312 @@
+package fio
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// Formats of externally produced results that can be imported
+const (
+	ImportFIOJSON = "fio"    // fio --output-format=json, standalone or client/server
+	ImportRipsaw  = "ripsaw" // benchmark-operator (snafu) fio documents from Elasticsearch
+)
+
+// ImportedRun is a run read from externally produced results
+type ImportedRun struct {
+	UUID        string
+	User        string
+	ClusterName string
+	Timestamp   time.Time
+	Results     []*FIOResult // One result per sample, in sample order
+}
+
+// Summaries returns the result summaries of the run. fio leaves out options that have their
+// default value, so a missing block size is 4k and missing numjobs is 1, as fio ran them.
+func (r *ImportedRun) Summaries() []ResultSummary {
+	summaries := ExtractResultSummaries(r.Results, r.UUID)
+	for i := range summaries {
+		if summaries[i].BlockSize == "" {
+			summaries[i].BlockSize = "4k"
+		}
+		if summaries[i].NumJobs == 0 {
+			summaries[i].NumJobs = 1
+		}
+		summaries[i].RunStart = r.Timestamp
+	}
+	return summaries
+}
+
+// DetectImportFormat tells fio JSON output from Elasticsearch documents by the first object
+func DetectImportFormat(data []byte) (string, error) {
+	objects, err := decodeObjects(data)
+	if err != nil {
+		return "", err
+	}
+	if len(objects) == 0 {
+		return "", fmt.Errorf("no JSON objects found")
+	}
+
+	first := objects[0]
+	if _, ok := first["fio version"]; ok {
+		return ImportFIOJSON, nil
+	}
+	for _, key := range []string{"hits", "_source", "fio"} {
+		if _, ok := first[key]; ok {
+			return ImportRipsaw, nil
+		}
+	}
+	return "", fmt.Errorf("neither fio JSON output nor benchmark-operator documents")
+}
+
+// ParseFIOJSON parses one or more concatenated fio JSON outputs, one per sample. Standalone
+// fio reports its jobs under "jobs" instead of "client_stats".
+func ParseFIOJSON(data []byte) ([]*FIOResult, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	var results []*FIOResult
+	for {
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("failed to parse fio JSON: %w", err)
+		}
+
+		var result struct {
+			FIOResult
+			Jobs []ClientStats `json:"jobs"`
+		}
+		if err := json.Unmarshal(raw, &result); err != nil {
+			return nil, fmt.Errorf("failed to parse fio JSON: %w", err)
+		}
+		if len(result.ClientStats) == 0 {
+			result.ClientStats = result.Jobs
+		}
+		if len(result.ClientStats) == 0 {
+			return nil, fmt.Errorf("fio JSON output %d has no jobs", len(results)+1)
+		}
+		result.Raw = raw
+		results = append(results, &result.FIOResult)
+	}
+	if len(results) == 0 {
+		return nil, fmt.Errorf("no fio JSON output found")
+	}
+	return results, nil
+}
+
+// ripsawDocument is a fio result document indexed by snafu into <index>-results, or the raw
+// result document k8s-io indexes with index_raw_results
+type ripsawDocument struct {
+	UUID          string                 `json:"uuid"`
+	User          string                 `json:"user"`
+	ClusterName   string                 `json:"clustername"`
+	Sample        json.RawMessage        `json:"sample"`
+	Hostname      string                 `json:"hostname"`
+	GlobalOptions map[string]interface{} `json:"global_options"`
+	FIO           json.RawMessage        `json:"fio"`
+	Date          json.RawMessage        `json:"date"`
+	AtTimestamp   json.RawMessage        `json:"@timestamp"`
+	Timestamp     json.RawMessage        `json:"timestamp"`
+	RunStart      string                 `json:"runStart"`
+}
+
+// ParseRipsawDocuments parses benchmark-operator fio documents: an Elasticsearch search
+// response, elasticdump lines with _source, or the documents themselves as an array or one
+// per line. The documents are grouped into runs by UUID.
+func ParseRipsawDocuments(data []byte) ([]*ImportedRun, error) {
+	objects, err := decodeObjects(data)
+	if err != nil {
+		return nil, err
+	}
+
+	var docs []ripsawDocument
+	for _, obj := range objects {
+		for _, source := range sources(obj) {
+			var doc ripsawDocument
+			if err := json.Unmarshal(source, &doc); err != nil {
+				return nil, fmt.Errorf("failed to parse document: %w", err)
+			}
+			if doc.UUID == "" || len(doc.FIO) == 0 {
+				continue
+			}
+			docs = append(docs, doc)
+		}
+	}
+	if len(docs) == 0 {
+		return nil, fmt.Errorf("no fio result documents with a uuid found")
+	}
+
+	type sampleKey struct {
+		uuid   string
+		sample string
+	}
+	runs := make(map[string]*ImportedRun)
+	samples := make(map[sampleKey]*FIOResult)
+	var order []string
+	var sampleOrder []sampleKey
+
+	for _, doc := range docs {
+		run, ok := runs[doc.UUID]
+		if !ok {
+			run = &ImportedRun{UUID: doc.UUID, User: doc.User, ClusterName: doc.ClusterName}
+			runs[doc.UUID] = run
+			order = append(order, doc.UUID)
+		}
+		for _, raw := range []json.RawMessage{doc.Date, doc.AtTimestamp, doc.Timestamp, json.RawMessage(strconv.Quote(doc.RunStart))} {
+			if t, ok := parseDocumentTime(raw); ok && (run.Timestamp.IsZero() || t.Before(run.Timestamp)) {
+				run.Timestamp = t
+			}
+		}
+
+		// Raw result documents hold the whole fio output of a sample
+		if full, err := ParseFIOJSON(doc.FIO); err == nil {
+			key := sampleKey{doc.UUID, string(doc.Sample)}
+			samples[key] = full[0]
+			sampleOrder = append(sampleOrder, key)
+			continue
+		}
+
+		var job ClientStats
+		if err := json.Unmarshal(doc.FIO, &job); err != nil {
+			return nil, fmt.Errorf("failed to parse the fio job of run %s: %w", doc.UUID, err)
+		}
+		if job.Hostname == "" {
+			job.Hostname = doc.Hostname
+		}
+		key := sampleKey{doc.UUID, string(doc.Sample)}
+		result, ok := samples[key]
+		if !ok {
+			result = &FIOResult{GlobalOptions: doc.GlobalOptions}
+			samples[key] = result
+			sampleOrder = append(sampleOrder, key)
+		}
+		result.ClientStats = append(result.ClientStats, job)
+	}
+
+	sort.Slice(sampleOrder, func(i, j int) bool {
+		a, b := sampleOrder[i], sampleOrder[j]
+		if a.uuid != b.uuid {
+			return a.uuid < b.uuid
+		}
+		return sampleNumber(a.sample) < sampleNumber(b.sample)
+	})
+	for _, key := range sampleOrder {
+		runs[key.uuid].Results = append(runs[key.uuid].Results, samples[key])
+	}
+
+	imported := make([]*ImportedRun, 0, len(order))
+	for _, uuid := range order {
+		imported = append(imported, runs[uuid])
+	}
+	return imported, nil
+}
+
+// decodeObjects decodes a JSON array of objects or a stream of objects
+func decodeObjects(data []byte) ([]map[string]json.RawMessage, error) {
+	trimmed := bytes.TrimSpace(data)
+	if len(trimmed) > 0 && trimmed[0] == '[' {
+		var objects []map[string]json.RawMessage
+		if err := json.Unmarshal(trimmed, &objects); err != nil {
+			return nil, fmt.Errorf("failed to parse JSON array: %w", err)
+		}
+		return objects, nil
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(trimmed))
+	var objects []map[string]json.RawMessage
+	for {
+		var obj map[string]json.RawMessage
+		if err := dec.Decode(&obj); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("failed to parse JSON: %w", err)
+		}
+		objects = append(objects, obj)
+	}
+	return objects, nil
+}
+
+// sources returns the documents of an object: the hits of a search response, the _source of
+// a hit or elasticdump line, or the object itself
+func sources(obj map[string]json.RawMessage) []json.RawMessage {
+	if hits, ok := obj["hits"]; ok {
+		var response struct {
+			Hits []struct {
+				Source json.RawMessage `json:"_source"`
+			} `json:"hits"`
+		}
+		if json.Unmarshal(hits, &response) == nil {
+			docs := make([]json.RawMessage, 0, len(response.Hits))
+			for _, hit := range response.Hits {
+				docs = append(docs, hit.Source)
+			}
+			return docs
+		}
+	}
+	if source, ok := obj["_source"]; ok {
+		return []json.RawMessage{source}
+	}
+
+	whole, err := json.Marshal(obj)
+	if err != nil {
+		return nil
+	}
+	return []json.RawMessage{whole}
+}
+
+// documentTimeLayouts are the timestamp formats of snafu and Elasticsearch documents
+var documentTimeLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05.999999Z",
+	"2006-01-02T15:04:05.999999",
+	"2006-01-02 15:04:05",
+}
+
+// parseDocumentTime parses a timestamp string or epoch seconds or milliseconds
+func parseDocumentTime(raw json.RawMessage) (time.Time, bool) {
+	if len(raw) == 0 {
+		return time.Time{}, false
+	}
+
+	var s string
+	if json.Unmarshal(raw, &s) == nil {
+		for _, layout := range documentTimeLayouts {
+			if t, err := time.Parse(layout, s); err == nil {
+				return t.UTC(), true
+			}
+		}
+		return time.Time{}, false
+	}
+
+	var epoch float64
+	if json.Unmarshal(raw, &epoch) == nil && epoch > 0 {
+		if epoch > 1e12 {
+			return time.UnixMilli(int64(epoch)).UTC(), true
+		}
+		return time.Unix(int64(epoch), 0).UTC(), true
+	}
+	return time.Time{}, false
+}
+
+// sampleNumber returns the sample number of a document, the raw sample may be a number or
+// a string such as "1" or "randread_4k_1-1"
+func sampleNumber(raw string) int {
+	var s string
+	if json.Unmarshal([]byte(raw), &s) != nil {
+		s = raw
+	}
+	if n, err := strconv.Atoi(s); err == nil {
+		return n
+	}
+	for i := len(s) - 1; i >= 0; i-- {
+		if s[i] < '0' || s[i] > '9' {
+			n, _ := strconv.Atoi(s[i+1:])
+			return n
+		}
+	}
+	return 0
+}
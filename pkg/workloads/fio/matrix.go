@@ -0,0 +1,102 @@
+package fio
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/jtaleric/k8s-io/pkg/config"
+)
+
+// JobCase is one job, block size and numjobs combination of the run matrix, with the
+// parameters of its section in the FIO job file
+type JobCase struct {
+	Job     string   // FIO job name
+	RW      string   // rw of the job section, the job name unless job_params override it
+	BS      string   // Block size or block size range
+	BSParam string   // "bs" or "bsrange"
+	NumJobs int      // FIO processes per server
+	IODepth int      // Queue depth, from iodepth or job_params
+	Params  []string // Profile options and job_params of the job, added to its section
+}
+
+// Key identifies the combination, see caseKey
+func (c JobCase) Key() string {
+	return caseKey(c.Job, c.BS, c.NumJobs)
+}
+
+// FileName returns the ConfigMap key and file name of the job file
+func (c JobCase) FileName() string {
+	return fmt.Sprintf("fiojob-%s-%s-%d", c.Job, c.BS, c.NumJobs)
+}
+
+// Matrix expands jobs × block sizes × numjobs into the combinations the client runs, in
+// run order, with the profile options and job_params of every job
+func (f *FIOConfig) Matrix(jobParams []config.JobParam) []JobCase {
+	blockSizes, bsParam := f.BS, "bs"
+	if len(f.BSRange) > 0 {
+		blockSizes, bsParam = f.BSRange, "bsrange"
+	}
+
+	var cases []JobCase
+	for _, numjobs := range f.NumJobs {
+		for _, bs := range blockSizes {
+			for _, job := range f.Jobs {
+				c := JobCase{
+					Job:     job,
+					RW:      job,
+					BS:      bs,
+					BSParam: bsParam,
+					NumJobs: numjobs,
+					IODepth: f.IODepth,
+				}
+				c.Params = f.JobParams(job, jobParams)
+				for _, param := range c.Params {
+					c.override(param)
+				}
+				cases = append(cases, c)
+			}
+		}
+	}
+	return cases
+}
+
+// override applies a job_params option that changes a parameter of the case, since the
+// options of the job section take precedence over the global section
+func (c *JobCase) override(param string) {
+	key, value, ok := strings.Cut(param, "=")
+	if !ok {
+		return
+	}
+	switch strings.TrimSpace(key) {
+	case "rw", "readwrite":
+		c.RW = strings.TrimSpace(value)
+	case "iodepth":
+		if depth, err := strconv.Atoi(strings.TrimSpace(value)); err == nil {
+			c.IODepth = depth
+		}
+	}
+}
+
+// correlateCases fills in the parameters of every summary that its fio options did not
+// report from the combination of the run matrix that generated it, so every row carries
+// the rw type, block size, numjobs and queue depth it ran with
+func correlateCases(summaries []ResultSummary, cases []JobCase) {
+	byKey := make(map[string]JobCase, len(cases))
+	for _, c := range cases {
+		byKey[c.Key()] = c
+	}
+
+	for i := range summaries {
+		c, ok := byKey[caseKey(summaries[i].JobName, summaries[i].BlockSize, summaries[i].NumJobs)]
+		if !ok {
+			continue
+		}
+		if summaries[i].RW == "" {
+			summaries[i].RW = c.RW
+		}
+		if summaries[i].IODepth == 0 {
+			summaries[i].IODepth = c.IODepth
+		}
+	}
+}
@@ -0,0 +1,148 @@
+package fio
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// NotebookFile is the analysis notebook written to the run artifacts
+const NotebookFile = "analysis.ipynb"
+
+// markdownCell is a text cell of a Jupyter notebook in the nbformat 4 schema
+type markdownCell struct {
+	CellType string   `json:"cell_type"`
+	Metadata struct{} `json:"metadata"`
+	Source   string   `json:"source"`
+}
+
+// codeCell is a code cell of a Jupyter notebook that has not been run
+type codeCell struct {
+	CellType       string        `json:"cell_type"`
+	ExecutionCount *int          `json:"execution_count"`
+	Metadata       struct{}      `json:"metadata"`
+	Outputs        []interface{} `json:"outputs"`
+	Source         string        `json:"source"`
+}
+
+// notebook is a Jupyter notebook in the nbformat 4 schema
+type notebook struct {
+	Cells         []interface{}          `json:"cells"`
+	Metadata      map[string]interface{} `json:"metadata"`
+	NBFormat      int                    `json:"nbformat"`
+	NBFormatMinor int                    `json:"nbformat_minor"`
+}
+
+// notebookLoad loads the Parquet files of the run, from the directory of the notebook or
+// K8S_IO_ARTIFACTS
+const notebookLoad = `import os
+
+import matplotlib.pyplot as plt
+import pandas as pd
+
+ARTIFACTS = os.environ.get("K8S_IO_ARTIFACTS", ".")
+
+
+def load(name):
+    path = os.path.join(ARTIFACTS, name)
+    return pd.read_parquet(path) if os.path.exists(path) else None
+
+
+results = load("results.parquet")
+intervals = load("intervals.parquet")
+metrics = load("metrics/metrics.parquet")
+jobs = ["job", "block_size", "numjobs"]
+results.head()`
+
+// notebookThroughput plots the throughput of every job like the history metrics: summed
+// across the servers, then averaged across the samples
+const notebookThroughput = `per_sample = results.groupby(jobs + ["sample"])[["read_iops", "write_iops", "read_bw_kbs", "write_bw_kbs"]].sum()
+throughput = per_sample.groupby(jobs).mean()
+throughput[["read_iops", "write_iops"]].plot.bar(stacked=True, figsize=(10, 4), ylabel="IOPS")
+plt.tight_layout()
+throughput`
+
+// notebookLatency plots the mean P95 latencies of every job, leaving out the directions a
+// job did not run
+const notebookLatency = `p95 = ["read_lat_p95_us", "write_lat_p95_us"]
+latency = results[jobs + p95].replace(0, float("nan")).groupby(jobs).mean()
+latency.plot.bar(figsize=(10, 4), ylabel="P95 latency (usec)")
+plt.tight_layout()
+latency`
+
+// notebookServers plots the spread of the IOPS of every server across jobs and samples
+const notebookServers = `servers = results.assign(iops=results.read_iops + results.write_iops)
+servers.boxplot(column="iops", by="hostname", rot=90, figsize=(10, 4))
+plt.suptitle("")
+plt.ylabel("IOPS")
+plt.tight_layout()`
+
+// notebookIntervals plots the IOPS of every sample over time, summed across the servers
+const notebookIntervals = `if intervals is None:
+    print("No interval logs, set log_sample_rate to record them")
+else:
+    iops = intervals[intervals.metric == "iops"]
+    for sample_id, points in iops.groupby("sample_id"):
+        series = points.groupby(["offset_ms", "direction"]).value.sum().unstack()
+        series.index = series.index / 1000
+        series.plot(title=sample_id, xlabel="Seconds", ylabel="IOPS", figsize=(10, 3))
+        plt.show()`
+
+// notebookMetrics plots every captured metric over time, summed across its series
+const notebookMetrics = `if metrics is None:
+    print("No captured metrics, set metrics_profiles or metrics_capture to capture them")
+else:
+    for name, docs in metrics.groupby("metric"):
+        docs.groupby("timestamp").value.sum().plot(title=name, figsize=(10, 3))
+        plt.show()`
+
+// WriteNotebook writes a Jupyter notebook to dir that loads the Parquet files of the run
+// with pandas and plots the throughput and latency of every job, the IOPS of every server,
+// the interval logs and the captured metrics
+func WriteNotebook(dir, uuid string, runStart time.Time) error {
+	markdown := func(source string) interface{} {
+		return markdownCell{CellType: "markdown", Source: source}
+	}
+	code := func(source string) interface{} {
+		return codeCell{CellType: "code", Outputs: []interface{}{}, Source: source}
+	}
+
+	nb := notebook{
+		Cells: []interface{}{
+			markdown(fmt.Sprintf("# k8s-io run %s\n\n"+
+				"Started %s. The cells load the Parquet files of the run artifacts directory with pandas and plot them with matplotlib. "+
+				"Open the notebook in that directory, or set `K8S_IO_ARTIFACTS` to it.", uuid, runStart.UTC().Format(time.RFC3339))),
+			code(notebookLoad),
+			markdown("## Throughput\n\nIOPS of every job, summed across the servers and averaged across the samples."),
+			code(notebookThroughput),
+			markdown("## Latency\n\nP95 latency of every job, averaged across the servers and samples."),
+			code(notebookLatency),
+			markdown("## Servers\n\nIOPS of every server across the jobs and samples, to spot slow nodes and volumes."),
+			code(notebookServers),
+			markdown("## Intervals\n\nIOPS of every sample over time, from the fio interval logs summed across the servers."),
+			code(notebookIntervals),
+			markdown("## Cluster Metrics\n\nCaptured metrics over the run, summed across their series."),
+			code(notebookMetrics),
+		},
+		Metadata: map[string]interface{}{
+			"kernelspec":    map[string]string{"display_name": "Python 3", "language": "python", "name": "python3"},
+			"language_info": map[string]string{"name": "python"},
+		},
+		NBFormat:      4,
+		NBFormatMinor: 4,
+	}
+
+	data, err := json.MarshalIndent(nb, "", " ")
+	if err != nil {
+		return fmt.Errorf("failed to encode notebook: %w", err)
+	}
+	filename := filepath.Join(dir, NotebookFile)
+	if err := os.WriteFile(filename, data, 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", filename, err)
+	}
+	log.Printf("Analysis notebook written to: %s", filename)
+	return nil
+}
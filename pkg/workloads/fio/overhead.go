@@ -0,0 +1,134 @@
+package fio
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/jtaleric/k8s-io/pkg/units"
+)
+
+// OverheadArgs returns the workload args of the two runs of a virtualization overhead
+// comparison: the same profile with kind pod and with kind vm. The server pods get the
+// vm_cores and vm_memory of the VMs as their requests and limits, and both runs test a PVC
+// of the same storage class.
+func OverheadArgs(args interface{}) (map[string]interface{}, map[string]interface{}, error) {
+	base, ok := args.(map[string]interface{})
+	if !ok {
+		return nil, nil, fmt.Errorf("workload args must be a mapping")
+	}
+
+	data, err := yaml.Marshal(base)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to marshal FIO args: %w", err)
+	}
+	var f FIOConfig
+	if err := yaml.Unmarshal(data, &f); err != nil {
+		return nil, nil, fmt.Errorf("failed to unmarshal FIO config: %w", err)
+	}
+	f.SetDefaults()
+
+	if f.VolumeType != VolumeTypePVC {
+		return nil, nil, fmt.Errorf("the pod and VM runs must test the same storage, set a storageclass")
+	}
+	if f.VMInstancetype != "" || f.VMPreference != "" {
+		return nil, nil, fmt.Errorf("vm_instancetype and vm_preference cannot size the pods, use vm_cores and vm_memory")
+	}
+	if f.CPUPinning != nil {
+		return nil, nil, fmt.Errorf("cpu_pinning only applies to the pods, remove it to compare like for like")
+	}
+	if f.ServerCPU != "" || f.ServerMemory != "" {
+		return nil, nil, fmt.Errorf("server_cpu and server_memory are taken from vm_cores and vm_memory, remove them")
+	}
+
+	pod := make(map[string]interface{}, len(base)+2)
+	vm := make(map[string]interface{}, len(base))
+	for k, v := range base {
+		pod[k] = v
+		vm[k] = v
+	}
+	pod["kind"] = "pod"
+	pod["server_cpu"] = strconv.Itoa(f.VMCores)
+	pod["server_memory"] = f.VMMemory
+	// Guest tuning only applies inside the VMs
+	delete(pod, "guest_tuning")
+	vm["kind"] = "vm"
+
+	return pod, vm, nil
+}
+
+// Overhead is the difference of a summarized metric between the pod and VM runs
+type Overhead struct {
+	Metric  string  `json:"metric"`
+	Pod     float64 `json:"pod"`
+	VM      float64 `json:"vm"`
+	Percent float64 `json:"overheadPercent"` // Throughput lost or latency added by the VM, relative to the pod
+}
+
+// VirtualizationOverhead compares the summarized metrics of the pod and VM runs, see
+// SummarizeMetrics. A positive overhead is always worse for the VM: less IOPS and bandwidth,
+// or more latency.
+func VirtualizationOverhead(pod, vm map[string]float64) []Overhead {
+	var overheads []Overhead
+	for metric, before := range pod {
+		after, ok := vm[metric]
+		if !ok || before == 0 {
+			continue
+		}
+		percent := (before - after) / before * 100
+		if strings.HasSuffix(metric, "_us") {
+			percent = -percent
+		}
+		overheads = append(overheads, Overhead{Metric: metric, Pod: before, VM: after, Percent: percent})
+	}
+	sort.Slice(overheads, func(i, j int) bool { return overheads[i].Metric < overheads[j].Metric })
+	return overheads
+}
+
+// PrintOverheadTable prints the metrics of both runs with the overhead of the VM
+func PrintOverheadTable(out io.Writer, overheads []Overhead, mode units.Mode) {
+	if len(overheads) == 0 {
+		fmt.Fprintln(out, "No metrics were captured by both runs")
+		return
+	}
+
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "Metric\tPod\tVM\tOverhead (%)")
+	for _, o := range overheads {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%+.1f\n", o.Metric, units.Metric(o.Metric, o.Pod, mode), units.Metric(o.Metric, o.VM, mode), o.Percent)
+	}
+	w.Flush()
+}
+
+// OverheadReport is the vm-overhead.json artifact of a comparison
+type OverheadReport struct {
+	PodUUID   string     `json:"podUUID"`
+	VMUUID    string     `json:"vmUUID"`
+	CPU       string     `json:"cpu"`    // vm_cores, the CPU of the server pods
+	Memory    string     `json:"memory"` // vm_memory, the memory of the server pods
+	Overheads []Overhead `json:"overheads"`
+}
+
+// Write writes the report to vm-overhead.json in dir
+func (r *OverheadReport) Write(dir string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create artifacts directory: %w", err)
+	}
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode VM overhead report: %w", err)
+	}
+	filename := filepath.Join(dir, "vm-overhead.json")
+	if err := os.WriteFile(filename, data, 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", filename, err)
+	}
+	return nil
+}
@@ -0,0 +1,290 @@
+package fio
+
+import (
+	"bufio"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jtaleric/k8s-io/pkg/anonymize"
+	"github.com/jtaleric/k8s-io/pkg/config"
+	"github.com/jtaleric/k8s-io/pkg/parquet"
+)
+
+// Markers printed by the client around every fio bandwidth, IOPS and latency log when the
+// results are exported to Parquet
+const (
+	intervalLogMarker    = "FIO_INTERVAL_LOG" // FIO_INTERVAL_LOG <uuid>_<job>_<bs>_<numjobs>-<sample> <file>
+	intervalLogEndMarker = "END_FIO_INTERVAL_LOG"
+)
+
+// intervalMetrics names the value of every fio log by the prefix of its file, in the units
+// of the history metrics
+var intervalMetrics = map[string]string{
+	"fio_bw.":   "bw_kbs",
+	"fio_iops.": "iops",
+	"fio_lat.":  "lat_us",
+}
+
+// intervalLogs reports whether the client prints the interval logs for the Parquet export.
+// Without log_sample_rate fio logs every I/O, far too much to print, so only the results
+// are exported then.
+func intervalLogs(cfg *config.Config, fioConfig *FIOConfig) bool {
+	return cfg.WritesParquet() && fioConfig.LogSampleRate > 0
+}
+
+// IntervalPoint is a line of a fio log: the average of a log_sample_rate interval ending at
+// Offset after the job started, for one direction
+type IntervalPoint struct {
+	Offset    time.Duration
+	Direction string // read, write or trim
+	Value     float64
+}
+
+// IntervalLog is the bandwidth, IOPS or latency log of one job of a sample on one server
+type IntervalLog struct {
+	Sample string // <uuid>_<job>_<bs>_<numjobs>-<sample>, the ID of the sample result
+	File   string // Log file name, which names the server for client/server runs
+	Metric string // bw_kbs, iops or lat_us
+	Points []IntervalPoint
+}
+
+// ParseIntervalLogs extracts the fio logs the client printed between interval log markers.
+// Lines are "time, value, ddir, bs, offset" with the time in msec, bandwidth in KiB/s and
+// latency in nsec, which is converted to usec.
+func ParseIntervalLogs(logOutput string) []IntervalLog {
+	var logs []IntervalLog
+	var current *IntervalLog
+
+	scanner := bufio.NewScanner(strings.NewReader(logOutput))
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		fields := strings.Fields(line)
+
+		if len(fields) == 3 && fields[0] == intervalLogMarker {
+			current = nil
+			for prefix, metric := range intervalMetrics {
+				if strings.HasPrefix(fields[2], prefix) {
+					logs = append(logs, IntervalLog{Sample: fields[1], File: fields[2], Metric: metric})
+					current = &logs[len(logs)-1]
+				}
+			}
+			continue
+		}
+		if line == intervalLogEndMarker {
+			current = nil
+			continue
+		}
+		if current == nil {
+			continue
+		}
+
+		values := strings.Split(line, ",")
+		if len(values) < 3 {
+			continue
+		}
+		msec, err1 := strconv.ParseInt(strings.TrimSpace(values[0]), 10, 64)
+		value, err2 := strconv.ParseFloat(strings.TrimSpace(values[1]), 64)
+		ddir, err3 := strconv.Atoi(strings.TrimSpace(values[2]))
+		if err1 != nil || err2 != nil || err3 != nil {
+			continue
+		}
+		if current.Metric == "lat_us" {
+			value /= 1000
+		}
+		direction := "read"
+		switch ddir {
+		case 1:
+			direction = "write"
+		case 2:
+			direction = "trim"
+		}
+		current.Points = append(current.Points, IntervalPoint{
+			Offset:    time.Duration(msec) * time.Millisecond,
+			Direction: direction,
+			Value:     value,
+		})
+	}
+	return logs
+}
+
+// splitSampleID returns the job, block size, numjobs and sample of a sample ID without the
+// UUID, such as randread_4KiB_1-2
+func splitSampleID(id string) (job, bs string, numjobs, sample int) {
+	name := id
+	if i := strings.LastIndex(id, "-"); i >= 0 {
+		name = id[:i]
+		sample, _ = strconv.Atoi(id[i+1:])
+	}
+	parts := strings.Split(name, "_")
+	if len(parts) < 3 {
+		return name, "", 0, sample
+	}
+	numjobs, _ = strconv.Atoi(parts[len(parts)-1])
+	return strings.Join(parts[:len(parts)-2], "_"), parts[len(parts)-2], numjobs, sample
+}
+
+// exportParquet writes the Parquet files of the run to its artifacts directory, with the
+// config fingerprint and run start the summaries are recorded with
+func (w *Workload) exportParquet(results []*FIOResult, logs []IntervalLog, fingerprint string) error {
+	summaries := make([]ResultSummary, len(w.summaries))
+	for i, summary := range w.summaries {
+		summary.Fingerprint = fingerprint
+		summary.RunStart = w.runStart()
+		summaries[i] = summary
+	}
+	dir := w.config.RunArtifactsDir()
+	if err := ExportResultsToParquet(dir, summaries, results, logs, w.config.UUID, w.anonymizer()); err != nil {
+		return err
+	}
+	log.Printf("Parquet results written to: %s", dir)
+	return nil
+}
+
+// ExportResultsToParquet writes the results of every server and sample to results.parquet
+// in dir, with the columns of the CSV export in snake_case, and the points of the interval
+// logs to intervals.parquet, one row per point in long format
+func ExportResultsToParquet(dir string, summaries []ResultSummary, results []*FIOResult, logs []IntervalLog, uuid string, a *anonymize.Anonymizer) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create artifacts directory: %w", err)
+	}
+
+	var table parquet.Table
+	n := len(summaries)
+	column := func(get func(ResultSummary) string) []string {
+		values := make([]string, n)
+		for i, s := range summaries {
+			values[i] = get(s)
+		}
+		return values
+	}
+	ints := func(get func(ResultSummary) int) []int64 {
+		values := make([]int64, n)
+		for i, s := range summaries {
+			values[i] = int64(get(s))
+		}
+		return values
+	}
+	floats := func(get func(ResultSummary) float64) []float64 {
+		values := make([]float64, n)
+		for i, s := range summaries {
+			values[i] = get(s)
+		}
+		return values
+	}
+	runStart := make([]time.Time, n)
+	for i, s := range summaries {
+		runStart[i] = s.RunStart
+	}
+
+	table.String("uuid", column(func(ResultSummary) string { return uuid }))
+	table.String("test_id", column(func(s ResultSummary) string { return s.TestID }))
+	table.Int64("sample", ints(func(s ResultSummary) int { return s.Sample }))
+	table.String("job", column(func(s ResultSummary) string { return s.JobName }))
+	table.String("rw", column(func(s ResultSummary) string { return s.RW }))
+	table.String("block_size", column(func(s ResultSummary) string { return s.BlockSize }))
+	table.Int64("numjobs", ints(func(s ResultSummary) int { return s.NumJobs }))
+	table.Int64("iodepth", ints(func(s ResultSummary) int { return s.IODepth }))
+	table.String("hostname", column(func(s ResultSummary) string { return s.Hostname }))
+	table.String("node", column(func(s ResultSummary) string { return s.Node }))
+	table.String("volume", column(func(s ResultSummary) string { return s.Volume }))
+	table.String("device", column(func(s ResultSummary) string { return s.Device }))
+	table.Float64("read_iops", floats(func(s ResultSummary) float64 { return s.ReadIOPS }))
+	table.Int64("read_bw_kbs", ints(func(s ResultSummary) int { return s.ReadBW }))
+	table.Float64("write_iops", floats(func(s ResultSummary) float64 { return s.WriteIOPS }))
+	table.Int64("write_bw_kbs", ints(func(s ResultSummary) int { return s.WriteBW }))
+	table.Float64("read_lat_p50_us", floats(func(s ResultSummary) float64 { return s.ReadLatP50 }))
+	table.Float64("read_lat_p95_us", floats(func(s ResultSummary) float64 { return s.ReadLatP95 }))
+	table.Float64("write_lat_p50_us", floats(func(s ResultSummary) float64 { return s.WriteLatP50 }))
+	table.Float64("write_lat_p95_us", floats(func(s ResultSummary) float64 { return s.WriteLatP95 }))
+	table.Int64("runtime_s", ints(func(s ResultSummary) int { return s.Runtime }))
+	table.Int64("compress_pct", ints(func(s ResultSummary) int { return s.CompressPct }))
+	table.Int64("dedupe_pct", ints(func(s ResultSummary) int { return s.DedupePct }))
+	table.String("config_fingerprint", column(func(s ResultSummary) string { return s.Fingerprint }))
+	table.Int64("retries", ints(func(s ResultSummary) int { return s.Retries }))
+	table.String("tags", column(func(s ResultSummary) string { return s.Tags }))
+	table.Timestamp("run_start", runStart)
+	if err := table.WriteFile(filepath.Join(dir, "results.parquet")); err != nil {
+		return err
+	}
+
+	if len(logs) == 0 {
+		return nil
+	}
+	return writeIntervals(filepath.Join(dir, "intervals.parquet"), results, logs, uuid, a)
+}
+
+// writeIntervals writes the points of the interval logs with the time they were logged at,
+// from the start of their job on the server the log names
+func writeIntervals(filename string, results []*FIOResult, logs []IntervalLog, uuid string, a *anonymize.Anonymizer) error {
+	byID := make(map[string]*FIOResult, len(results))
+	for _, result := range results {
+		byID[result.ID] = result
+	}
+
+	var (
+		samples, jobs, sizes, hostnames, metrics, directions []string
+		numjobs, sampleNumbers, offsets                      []int64
+		times                                                []time.Time
+		values                                               []float64
+	)
+	for _, l := range logs {
+		id := strings.TrimPrefix(l.Sample, uuid+"_")
+		job, bs, nj, sample := splitSampleID(id)
+
+		start := time.Unix(0, 0)
+		hostname := ""
+		if result, ok := byID[l.Sample]; ok {
+			if jobStart, ok := logStart(result, l.File); ok {
+				start = jobStart
+			}
+			for _, client := range result.ClientStats {
+				if client.JobName != "All clients" && client.Hostname != "" && strings.HasSuffix(l.File, "."+client.Hostname) {
+					hostname = client.Hostname
+				}
+			}
+		}
+		if a != nil {
+			hostname = a.Pseudonym(anonymize.KindHost, hostname)
+		}
+
+		for _, p := range l.Points {
+			samples = append(samples, id)
+			jobs = append(jobs, job)
+			sizes = append(sizes, bs)
+			numjobs = append(numjobs, int64(nj))
+			sampleNumbers = append(sampleNumbers, int64(sample))
+			hostnames = append(hostnames, hostname)
+			metrics = append(metrics, l.Metric)
+			directions = append(directions, p.Direction)
+			offsets = append(offsets, p.Offset.Milliseconds())
+			// Points of samples without a known start are at the epoch, offset_ms still orders them
+			times = append(times, start.Add(p.Offset))
+			values = append(values, p.Value)
+		}
+	}
+
+	var table parquet.Table
+	uuids := make([]string, len(samples))
+	for i := range uuids {
+		uuids[i] = uuid
+	}
+	table.String("uuid", uuids)
+	table.String("sample_id", samples)
+	table.String("job", jobs)
+	table.String("block_size", sizes)
+	table.Int64("numjobs", numjobs)
+	table.Int64("sample", sampleNumbers)
+	table.String("hostname", hostnames)
+	table.String("metric", metrics)
+	table.String("direction", directions)
+	table.Int64("offset_ms", offsets)
+	table.Timestamp("time", times)
+	table.Float64("value", values)
+	return table.WriteFile(filename)
+}
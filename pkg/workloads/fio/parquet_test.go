@@ -0,0 +1,165 @@
+package fio
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/jtaleric/k8s-io/pkg/anonymize"
+	"github.com/jtaleric/k8s-io/pkg/parquet/parquettest"
+)
+
+const intervalOutput = `fio: sample 1 starting
+FIO_INTERVAL_LOG 0123abcd_rand_read_4KiB_1-1 fio_iops.1.log.10.128.0.1
+1000, 250, 0, 4096, 0
+2000, 260, 1, 4096, 0
+not, a, point
+3000, 270, 2, 4096, 0
+END_FIO_INTERVAL_LOG
+4000, 999, 0, 4096, 0
+FIO_INTERVAL_LOG 0123abcd_rand_read_4KiB_1-1 fio_lat.1.log.10.128.0.1
+1000, 1500000, 0, 4096, 0
+END_FIO_INTERVAL_LOG
+FIO_INTERVAL_LOG 0123abcd_rand_read_4KiB_1-1 fio_clat.1.log.10.128.0.1
+1000, 1, 0, 4096, 0
+END_FIO_INTERVAL_LOG
+`
+
+func TestParseIntervalLogs(t *testing.T) {
+	logs := ParseIntervalLogs(intervalOutput)
+
+	// Lines outside the markers, bad lines and logs of other metrics are skipped, and
+	// latencies are converted from nsec to usec
+	want := []IntervalLog{
+		{Sample: "0123abcd_rand_read_4KiB_1-1", File: "fio_iops.1.log.10.128.0.1", Metric: "iops", Points: []IntervalPoint{
+			{Offset: time.Second, Direction: "read", Value: 250},
+			{Offset: 2 * time.Second, Direction: "write", Value: 260},
+			{Offset: 3 * time.Second, Direction: "trim", Value: 270},
+		}},
+		{Sample: "0123abcd_rand_read_4KiB_1-1", File: "fio_lat.1.log.10.128.0.1", Metric: "lat_us", Points: []IntervalPoint{
+			{Offset: time.Second, Direction: "read", Value: 1500},
+		}},
+	}
+	if !reflect.DeepEqual(logs, want) {
+		t.Errorf("ParseIntervalLogs returned %+v, want %+v", logs, want)
+	}
+}
+
+func TestSplitSampleID(t *testing.T) {
+	tests := []struct {
+		id              string
+		job, bs         string
+		numjobs, sample int
+	}{
+		{"randread_4KiB_1-2", "randread", "4KiB", 1, 2},
+		{"rand_read_mix_64KiB_8-10", "rand_read_mix", "64KiB", 8, 10},
+		{"seq-write_1MiB_4-1", "seq-write", "1MiB", 4, 1},
+		{"randread-3", "randread", "", 0, 3},
+	}
+	for _, test := range tests {
+		job, bs, numjobs, sample := splitSampleID(test.id)
+		if job != test.job || bs != test.bs || numjobs != test.numjobs || sample != test.sample {
+			t.Errorf("splitSampleID(%q) = %q, %q, %d, %d, want %q, %q, %d, %d", test.id,
+				job, bs, numjobs, sample, test.job, test.bs, test.numjobs, test.sample)
+		}
+	}
+}
+
+// readParquet reads a Parquet file of the export back
+func readParquet(t *testing.T, filename string) *parquettest.File {
+	t.Helper()
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := parquettest.Read(data)
+	if err != nil {
+		t.Fatalf("failed to read %s: %v", filename, err)
+	}
+	return f
+}
+
+func TestExportResultsToParquet(t *testing.T) {
+	dir := t.TempDir()
+	uuid := "0123abcd-0000-4000-8000-000000000000"
+	runStart := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	summaries := []ResultSummary{
+		{TestID: "0123abcd_rand_read", Sample: 1, JobName: "rand_read", BlockSize: "4KiB", NumJobs: 1, RW: "randread", Hostname: "10.128.0.1", ReadIOPS: 1000.5, ReadBW: 4002, RunStart: runStart},
+		{TestID: "0123abcd_rand_read", Sample: 1, JobName: "rand_read", BlockSize: "4KiB", NumJobs: 1, RW: "randread", Hostname: "10.128.0.2", ReadIOPS: 990, ReadBW: 3960, RunStart: runStart},
+	}
+	jobStart := runStart.Add(time.Minute)
+	results := []*FIOResult{{
+		ID: uuid + "_rand_read_4KiB_1-1",
+		ClientStats: []ClientStats{
+			{JobName: "rand_read", Hostname: "10.128.0.1", JobStart: jobStart.UnixMilli()},
+			{JobName: "rand_read", Hostname: "10.128.0.2", JobStart: jobStart.Add(time.Second).UnixMilli()},
+			{JobName: "All clients"},
+		},
+	}}
+	logs := []IntervalLog{{Sample: uuid + "_rand_read_4KiB_1-1", File: "fio_iops.1.log.10.128.0.2", Metric: "iops", Points: []IntervalPoint{
+		{Offset: time.Second, Direction: "read", Value: 250},
+		{Offset: 2 * time.Second, Direction: "read", Value: 260},
+	}}}
+
+	if err := ExportResultsToParquet(dir, summaries, results, logs, uuid, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	f := readParquet(t, filepath.Join(dir, "results.parquet"))
+	if f.Rows != 2 || len(f.Columns) != 27 {
+		t.Fatalf("results.parquet has %d rows and %d columns, want 2 and 27", f.Rows, len(f.Columns))
+	}
+	for name, want := range map[string][]interface{}{
+		"uuid":        {uuid, uuid},
+		"job":         {"rand_read", "rand_read"},
+		"hostname":    {"10.128.0.1", "10.128.0.2"},
+		"read_iops":   {1000.5, 990.0},
+		"read_bw_kbs": {int64(4002), int64(3960)},
+		"run_start":   {runStart, runStart},
+	} {
+		if c := f.Column(name); c == nil || !reflect.DeepEqual(c.Values, want) {
+			t.Errorf("results.parquet column %s is %+v, want %v", name, c, want)
+		}
+	}
+
+	// The points are at the start of the job on the server the log names
+	f = readParquet(t, filepath.Join(dir, "intervals.parquet"))
+	for name, want := range map[string][]interface{}{
+		"sample_id":  {"rand_read_4KiB_1-1", "rand_read_4KiB_1-1"},
+		"job":        {"rand_read", "rand_read"},
+		"block_size": {"4KiB", "4KiB"},
+		"numjobs":    {int64(1), int64(1)},
+		"sample":     {int64(1), int64(1)},
+		"hostname":   {"10.128.0.2", "10.128.0.2"},
+		"offset_ms":  {int64(1000), int64(2000)},
+		"time":       {jobStart.Add(2 * time.Second), jobStart.Add(3 * time.Second)},
+		"value":      {250.0, 260.0},
+	} {
+		if c := f.Column(name); c == nil || !reflect.DeepEqual(c.Values, want) {
+			t.Errorf("intervals.parquet column %s is %+v, want %v", name, c, want)
+		}
+	}
+}
+
+func TestExportResultsToParquetAnonymized(t *testing.T) {
+	dir := t.TempDir()
+	uuid := "0123abcd-0000-4000-8000-000000000000"
+	a := anonymize.New(uuid)
+	results := []*FIOResult{{ID: uuid + "_randread_4KiB_1-1", ClientStats: []ClientStats{{JobName: "randread", Hostname: "10.128.0.1"}}}}
+	logs := []IntervalLog{{Sample: uuid + "_randread_4KiB_1-1", File: "fio_bw.1.log.10.128.0.1", Metric: "bw_kbs", Points: []IntervalPoint{{Offset: time.Second, Direction: "read", Value: 4000}}}}
+
+	if err := ExportResultsToParquet(dir, []ResultSummary{{JobName: "randread"}}, results, logs, uuid, a); err != nil {
+		t.Fatal(err)
+	}
+	f := readParquet(t, filepath.Join(dir, "intervals.parquet"))
+	want := a.Pseudonym(anonymize.KindHost, "10.128.0.1")
+	if got := f.Column("hostname").Values; len(got) != 1 || got[0] != want || want == "10.128.0.1" {
+		t.Errorf("intervals.parquet hostnames are %v, want the pseudonym %s", got, want)
+	}
+	// Without a start of the job, the points are at the epoch
+	if got := f.Column("time").Values[0]; got != time.Unix(1, 0).UTC() {
+		t.Errorf("point without a job start is at %v, want a second after the epoch", got)
+	}
+}
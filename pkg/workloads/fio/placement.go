@@ -0,0 +1,172 @@
+package fio
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/jtaleric/k8s-io/pkg/kubernetes"
+)
+
+// ServerPlacement is where a server ran: its node and the storage behind its volumes
+type ServerPlacement struct {
+	Node    string
+	Devices map[string]string // Bound PV, host path or emptyDir behind every volume path
+}
+
+// placeSummaries sets the node and device of every summary from the placement of its server
+func placeSummaries(summaries []ResultSummary, placements map[string]ServerPlacement) {
+	for i := range summaries {
+		placement, ok := placements[summaries[i].Hostname]
+		if !ok {
+			continue
+		}
+		summaries[i].Node = placement.Node
+		summaries[i].Device = placement.Devices[summaries[i].Volume]
+	}
+}
+
+// serverPlacements returns the node of every server and the storage behind its volumes.
+// VM servers only have their node, since the volumes belong to their virt-launcher pods,
+// which do not have the addresses of the servers.
+func (w *Workload) serverPlacements(ctx context.Context) map[string]ServerPlacement {
+	placements := make(map[string]ServerPlacement, len(w.podDetails))
+	for ip, node := range w.podDetails {
+		placements[ip] = ServerPlacement{Node: node}
+	}
+	if w.fioConfig.Kind == "vm" {
+		return placements
+	}
+
+	paths := make(map[string]string)
+	for _, volume := range w.fioConfig.DataVolumes() {
+		paths[volume.Name] = volume.Path
+	}
+
+	labelSelector := fmt.Sprintf("app=fio-benchmark-%s", w.config.GetTruncatedUUID())
+	for _, ns := range w.serverNamespaces() {
+		volumes, err := w.k8sClient.ListPodVolumes(ctx, ns, labelSelector)
+		if err != nil {
+			log.Printf("Warning: the results have no devices: %v", err)
+			return placements
+		}
+		for ip, podVolumes := range volumes {
+			placement, ok := placements[ip]
+			if !ok {
+				continue
+			}
+			placement.Devices = make(map[string]string)
+			for _, volume := range podVolumes {
+				if path, ok := paths[volume.Name]; ok && volume.Device != "" {
+					placement.Devices[path] = volume.Device
+				}
+			}
+			placements[ip] = placement
+		}
+	}
+
+	for ip, placement := range placements {
+		if len(placement.Devices) == 0 {
+			continue
+		}
+		devices := make([]string, 0, len(placement.Devices))
+		for path, device := range placement.Devices {
+			devices = append(devices, path+" on "+device)
+		}
+		sort.Strings(devices)
+		log.Printf("Server %s on node %s tests %s", ip, placement.Node, strings.Join(devices, ", "))
+	}
+	return placements
+}
+
+// recordVolumes records the attributes of the PVs of the servers in the run metadata and
+// writes them by claim to volumes.json in the run artifacts directory, so the results can
+// be traced back to the volumes they were measured on
+func (w *Workload) recordVolumes(ctx context.Context) error {
+	labelSelector := fmt.Sprintf("app=fio-benchmark-%s", w.config.GetTruncatedUUID())
+	var volumes []kubernetes.VolumeAttributes
+	for _, ns := range w.serverNamespaces() {
+		nsVolumes, err := w.k8sClient.ListVolumeAttributes(ctx, ns, labelSelector)
+		if err != nil {
+			return fmt.Errorf("failed to read the volumes of the servers: %w", err)
+		}
+		volumes = append(volumes, nsVolumes...)
+	}
+	if len(volumes) == 0 {
+		return nil
+	}
+
+	if w.config.Metadata == nil {
+		w.config.Metadata = make(map[string]string)
+	}
+	for k, v := range volumeMetadata(volumes) {
+		w.config.Metadata[k] = v
+	}
+
+	dir := w.config.RunArtifactsDir()
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create artifacts directory: %w", err)
+	}
+	data, err := json.MarshalIndent(volumes, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(dir, "volumes.json"), data, 0644); err != nil {
+		return fmt.Errorf("failed to write volume attributes: %w", err)
+	}
+	log.Printf("Attributes of %d volume(s) written to %s", len(volumes), filepath.Join(dir, "volumes.json"))
+	return nil
+}
+
+// volumeMetadata reduces the attributes of the volumes to run metadata. The volumes of a run
+// usually share their driver, access modes, topology and class, so each key lists the
+// distinct values; the keys do not depend on the claim names, which change every run.
+func volumeMetadata(volumes []kubernetes.VolumeAttributes) map[string]string {
+	var drivers, handles, accessModes, topology, provisioners, parameters []string
+	for _, v := range volumes {
+		drivers = append(drivers, v.CSIDriver)
+		if v.VolumeHandle != "" {
+			handles = append(handles, v.Claim+"="+v.VolumeHandle)
+		}
+		accessModes = append(accessModes, strings.Join(v.AccessModes, "|"))
+		topology = append(topology, v.Topology...)
+		provisioners = append(provisioners, v.Provisioner)
+		for key, value := range v.Parameters {
+			parameters = append(parameters, key+"="+value)
+		}
+	}
+
+	metadata := make(map[string]string)
+	for key, values := range map[string][]string{
+		"csiDriver":               drivers,
+		"csiVolumeHandles":        handles,
+		"pvAccessModes":           accessModes,
+		"pvTopology":              topology,
+		"storageClassProvisioner": provisioners,
+		"storageClassParameters":  parameters,
+	} {
+		if joined := joinDistinct(values); joined != "" {
+			metadata[key] = joined
+		}
+	}
+	return metadata
+}
+
+// joinDistinct returns the distinct non-empty values, sorted and separated by commas
+func joinDistinct(values []string) string {
+	seen := make(map[string]bool)
+	var distinct []string
+	for _, v := range values {
+		if v != "" && !seen[v] {
+			seen[v] = true
+			distinct = append(distinct, v)
+		}
+	}
+	sort.Strings(distinct)
+	return strings.Join(distinct, ",")
+}
@@ -0,0 +1,127 @@
+package fio
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jtaleric/k8s-io/pkg/plan"
+)
+
+// Phases returns the phases RunBenchmark goes through, with the manifests it applies and
+// the jobs and conditions it waits for
+func (w *Workload) Phases() []plan.Phase {
+	trunc := w.config.GetTruncatedUUID()
+	timeout := (time.Duration(w.fioConfig.JobTimeout) * time.Second).String()
+
+	deploy := plan.Phase{
+		Name:        "deploy",
+		Description: "create the job files, volumes and FIO servers",
+		Applies:     []string{"fio-configmap"},
+	}
+	if w.fioConfig.Prefill {
+		deploy.Applies = append(deploy.Applies, "fio-prefill-configmap")
+	}
+	if w.fioConfig.UsesPVC() {
+		for i := 1; i <= w.fioConfig.Servers; i++ {
+			for _, volume := range w.fioConfig.DataVolumes() {
+				deploy.Applies = append(deploy.Applies, fmt.Sprintf("pvc-%d%s", i, volume.Suffix))
+			}
+		}
+	}
+	if sampleGate(w.config, w.fioConfig) {
+		deploy.Applies = append(deploy.Applies, "fio-hooks-configmap")
+	}
+	if w.fioConfig.PodDisruptionBudget {
+		for _, ns := range w.serverNamespaces() {
+			deploy.Applies = append(deploy.Applies, w.pdbName(ns))
+		}
+	}
+	for i := 1; i <= w.fioConfig.Servers; i++ {
+		deploy.Applies = append(deploy.Applies, fmt.Sprintf("server-%d", i))
+	}
+	if w.fioConfig.VMInstancetype != "" || w.fioConfig.VMPreference != "" {
+		deploy.Description += ", sizing the VMs from the instancetype and preference instead of the rendered vm_cores and vm_memory"
+	}
+
+	kind := "pods"
+	if w.fioConfig.Kind == "vm" {
+		kind = "VMs"
+	}
+	ready := fmt.Sprintf("%d pods app=fio-benchmark-%s ready", w.fioConfig.Servers, trunc)
+	if len(w.fioConfig.ServerNamespaces) > 0 {
+		ready += " in namespaces " + strings.Join(w.fioConfig.ServerNamespaces, ", ")
+	}
+	phases := []plan.Phase{
+		deploy,
+		{
+			Name:        "wait-servers",
+			Description: fmt.Sprintf("wait for the FIO server %s and create the hosts file", kind),
+			Waits: []plan.Wait{{
+				For:     ready,
+				Timeout: timeout,
+			}},
+		},
+		{
+			Name:        "server-check",
+			Description: "check that every FIO server is reachable",
+			Jobs:        []string{"fio-check-" + trunc},
+			Waits:       []plan.Wait{{For: "job fio-check-" + trunc + " complete", Timeout: "5m0s"}},
+		},
+	}
+
+	if w.fioConfig.Prefill {
+		prefill := plan.Phase{
+			Name:        "prefill",
+			Description: fmt.Sprintf("write %s per job before the benchmark", w.fioConfig.FileSize),
+			Applies:     []string{"fio-prefill-client"},
+			Jobs:        []string{"fio-prefill-" + trunc},
+			Waits:       []plan.Wait{{For: "job fio-prefill-" + trunc + " complete", Timeout: timeout}},
+		}
+		if w.fioConfig.PostPrefillSleep > 0 {
+			prefill.Description += fmt.Sprintf(", then sleep %ds", w.fioConfig.PostPrefillSleep)
+		}
+		phases = append(phases, prefill)
+	}
+
+	benchmark := plan.Phase{
+		Name: "benchmark",
+		Description: fmt.Sprintf("run %d sample(s) of jobs %v; the client is rendered again with the server IPs",
+			w.fioConfig.Samples, w.fioConfig.Jobs),
+		Applies: []string{"fio-client"},
+		Jobs:    []string{"fio-client-" + trunc},
+		Waits:   []plan.Wait{{For: "job fio-client-" + trunc + " complete", Timeout: timeout}},
+	}
+	if w.fioConfig.SampleRetries > 0 {
+		benchmark.Description += fmt.Sprintf(", rerunning failed cases up to %d times", w.fioConfig.SampleRetries)
+	}
+	if hooks := sampleHooks(w.config, w.fioConfig); len(hooks) > 0 {
+		benchmark.Description += fmt.Sprintf(", running %d post_sample hook(s) after every sample", len(hooks))
+	}
+	if c := w.fioConfig.Cooldown; c != nil {
+		benchmark.Description += ", cooling down after every sample"
+		if c.Duration != "" {
+			benchmark.Description += " for " + c.Duration
+		}
+		if c.WaitsForUtilization() {
+			benchmark.Description += " until the server nodes are idle"
+		}
+	}
+	if e := w.fioConfig.Expansion; e != nil {
+		benchmark.Description += fmt.Sprintf(", expanding the PVCs to %s after %ds", e.Size, e.Delay)
+		benchmark.Waits = append(benchmark.Waits, plan.Wait{For: "PVCs expanded to " + e.Size, Timeout: (time.Duration(e.Timeout) * time.Second).String()})
+	}
+	if f := w.fioConfig.Failover; f != nil {
+		action := "deleting the pods"
+		if f.Action == "drain" {
+			action = "draining the node of the first pod"
+		}
+		benchmark.Description += fmt.Sprintf(", %s matching %s in %s after %ds", action, f.Selector, f.Namespace, f.Delay)
+		benchmark.Waits = append(benchmark.Waits, plan.Wait{For: "pods " + f.Selector + " ready again", Timeout: (time.Duration(f.Timeout) * time.Second).String()})
+	}
+	if w.fioConfig.NodeCapture != nil {
+		benchmark.Applies = append([]string{"fio-node-capture"}, benchmark.Applies...)
+	}
+
+	return append(phases, benchmark)
+}
@@ -0,0 +1,168 @@
+package fio
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/jtaleric/k8s-io/pkg/config"
+)
+
+// Profile is a named set of FIO arguments modelled on the I/O of an application, selected
+// with profile. Arguments set in the configuration take precedence over the profile.
+type Profile struct {
+	Description string
+	Jobs        []string
+	BS          []string
+	BSRange     []string
+	NumJobs     []int
+	IODepth     int
+	IOEngine    string
+	FileSize    string
+	Buffered    bool                // Buffered I/O through the page cache, unless the target is a raw block device
+	Unaligned   bool                // Block sizes that are not a multiple of the sector size, which rule out direct I/O
+	Params      map[string][]string // Options of the job sections by job name, before job_params
+}
+
+// profiles are the built-in profiles
+var profiles = map[string]Profile{
+	// The fio check of the etcd documentation: etcd appends small WAL entries and fdatasyncs
+	// every one, and needs a 99th percentile fdatasync latency below 10ms
+	"etcd": {
+		Description: "etcd WAL: 2300 byte sequential writes, each followed by fdatasync",
+		Jobs:        []string{"etcdwal"},
+		BS:          []string{"2300"},
+		NumJobs:     []int{1},
+		IODepth:     1,
+		IOEngine:    "sync",
+		FileSize:    "22MiB",
+		Buffered:    true,
+		Unaligned:   true,
+		Params: map[string][]string{
+			"etcdwal": {"rw=write", "fdatasync=1"},
+		},
+	},
+	// PostgreSQL writes the WAL in 8KiB pages and flushes it at every commit with the default
+	// wal_sync_method fdatasync; a few backends commit concurrently
+	"postgres-wal": {
+		Description: "PostgreSQL WAL: 8KiB sequential writes with fdatasync per commit, 4 committers",
+		Jobs:        []string{"pgwal"},
+		BS:          []string{"8KiB"},
+		NumJobs:     []int{4},
+		IODepth:     1,
+		IOEngine:    "sync",
+		FileSize:    "1GiB",
+		Params: map[string][]string{
+			"pgwal": {"rw=write", "fdatasync=1", "time_based=1", "runtime=60", "ramp_time=5"},
+		},
+	},
+	// Kafka appends producer batches to the partition logs through the page cache and leaves
+	// flushing to the kernel, while lagging consumers read the logs sequentially
+	"kafka-log": {
+		Description: "Kafka log: buffered 64KiB appends to 4 partitions and sequential catch-up reads of them",
+		Jobs:        []string{"kafkaappend", "kafkacatchup"},
+		BS:          []string{"64KiB"},
+		NumJobs:     []int{4},
+		IODepth:     1,
+		IOEngine:    "psync",
+		FileSize:    "2GiB",
+		Buffered:    true,
+		Params: map[string][]string{
+			"kafkaappend":  {"rw=write", "fsync_on_close=1", "time_based=1", "runtime=60", "ramp_time=5"},
+			"kafkacatchup": {"rw=read", "time_based=1", "runtime=60", "ramp_time=5"},
+		},
+	},
+	// Booting VMs read their images in mixed block sizes at a high queue depth, with some
+	// writes for logs and package caches
+	"vm-boot": {
+		Description: "VM boot storm: 90% random reads of 4KiB to 128KiB at queue depth 16, 4 VMs per server",
+		Jobs:        []string{"vmboot"},
+		BSRange:     []string{"4KiB-128KiB"},
+		NumJobs:     []int{4},
+		IODepth:     16,
+		IOEngine:    "libaio",
+		FileSize:    "4GiB",
+		Params: map[string][]string{
+			"vmboot": {"rw=randrw", "rwmixread=90", "time_based=1", "runtime=60", "ramp_time=5"},
+		},
+	},
+}
+
+// ProfileNames returns the names of the built-in profiles, sorted
+func ProfileNames() []string {
+	names := make([]string, 0, len(profiles))
+	for name := range profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// LookupProfile returns the built-in profile of the name
+func LookupProfile(name string) (Profile, bool) {
+	p, ok := profiles[name]
+	return p, ok
+}
+
+// applyProfile fills in the arguments left out of the configuration from the profile
+func (f *FIOConfig) applyProfile() {
+	p, ok := profiles[f.Profile]
+	if !ok {
+		return
+	}
+
+	if len(f.Jobs) == 0 {
+		f.Jobs = p.Jobs
+	}
+	if len(f.BS) == 0 && len(f.BSRange) == 0 {
+		f.BS, f.BSRange = p.BS, p.BSRange
+	}
+	if len(f.NumJobs) == 0 {
+		f.NumJobs = p.NumJobs
+	}
+	if f.IODepth == 0 {
+		f.IODepth = p.IODepth
+	}
+	if f.IOEngine == "" {
+		f.IOEngine = p.IOEngine
+	}
+	if f.FileSize == "" {
+		f.FileSize = p.FileSize
+	}
+	// Raw block devices only take direct I/O
+	rawBlock := f.PVCVolumeMode == "Block" || (f.Target != nil && f.Target.Type == TargetTypeBlock)
+	if p.Buffered && f.Direct == nil && !rawBlock {
+		direct := false
+		f.Direct = &direct
+	}
+}
+
+// validateProfile checks that the profile is a built-in profile
+func (f *FIOConfig) validateProfile() error {
+	if f.Profile == "" {
+		return nil
+	}
+	p, ok := profiles[f.Profile]
+	if !ok {
+		return fmt.Errorf("unknown profile %q, expected one of: %s", f.Profile, strings.Join(ProfileNames(), ", "))
+	}
+	if p.Unaligned && f.IsDirect() {
+		return fmt.Errorf("profile %s writes unaligned blocks and requires direct: false on a filesystem volume", f.Profile)
+	}
+	return nil
+}
+
+// JobParams returns the options of the section of a job: the options of the profile, then
+// the matching job_params, which fio applies last and so take precedence
+func (f *FIOConfig) JobParams(job string, jobParams []config.JobParam) []string {
+	var params []string
+	if p, ok := profiles[f.Profile]; ok {
+		params = append(params, p.Params[job]...)
+	}
+	for _, match := range jobParams {
+		if match.JobnameMatch == job {
+			params = append(params, match.Params...)
+		}
+	}
+	return params
+}
@@ -0,0 +1,71 @@
+package fio
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net"
+
+	"github.com/jtaleric/k8s-io/pkg/prometheus"
+)
+
+// Values of the prometheusExport run metadata, whether the client pods export the metrics
+// of the run from Prometheus
+const (
+	exportVerified = "verified"      // The endpoint answered a query with the token
+	exportProxied  = "proxied"       // The in-cluster endpoint answered through the API server, the token is unchecked
+	exportFailed   = "failed"        // The endpoint did not answer, it is not passed to the pods
+	exportNone     = "no-prometheus" // No Prometheus was configured or discovered
+)
+
+// resolvePrometheus finds the Prometheus the client pods export metrics from and checks that
+// it answers an up query before it is passed to them. An endpoint that does not answer is
+// left out of the pods, so the run reports it now instead of exporting nothing.
+func (w *Workload) resolvePrometheus(ctx context.Context) {
+	result := exportNone
+	defer func() {
+		if w.config.Metadata == nil {
+			w.config.Metadata = make(map[string]string)
+		}
+		w.config.Metadata["prometheusExport"] = result
+	}()
+
+	info, err := w.k8sClient.DiscoverPrometheusWithConfig(ctx, w.config.Prometheus)
+	if err != nil || !info.Found {
+		log.Printf("No Prometheus found, the client pods do not export metrics")
+		w.templateEngine.SetPrometheus(nil)
+		return
+	}
+
+	timeout, verifyCert := w.config.Prometheus.VerifyOptions()
+	opts := prometheus.Options{Timeout: timeout, InsecureSkipVerify: !verifyCert}
+	up, err := prometheus.NewClientWithOptions(info.URL, info.Token, opts).Verify(ctx)
+
+	// Service URLs only resolve inside the cluster, so k8s-io running outside of it checks
+	// them through the API server. The API server does not pass the token on.
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		if proxy, transport, perr := w.k8sClient.ServiceProxy(info.URL); perr == nil {
+			opts.Transport = transport
+			if up, err = prometheus.NewClientWithOptions(proxy, "", opts).Verify(ctx); err == nil {
+				log.Printf("Prometheus at %s answers through the API server with %d targets up; the client pods export metrics from it, but its token can only be checked inside the cluster", info.URL, up)
+				result = exportProxied
+				w.templateEngine.SetPrometheus(info)
+				return
+			}
+		}
+	}
+
+	if err != nil {
+		log.Printf("Warning: Prometheus at %s does not answer queries, the client pods do not export metrics: %v", info.URL, err)
+		result = exportFailed
+		w.templateEngine.SetPrometheus(nil)
+		return
+	}
+	if up == 0 {
+		log.Printf("Warning: Prometheus at %s answers queries, but has no targets up", info.URL)
+	}
+	log.Printf("Prometheus at %s answers queries with %d targets up, the client pods export metrics from it", info.URL, up)
+	result = exportVerified
+	w.templateEngine.SetPrometheus(info)
+}
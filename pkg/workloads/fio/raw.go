@@ -0,0 +1,69 @@
+package fio
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/jtaleric/k8s-io/pkg/sinks"
+	"github.com/jtaleric/k8s-io/pkg/timeline"
+)
+
+// rawResultDocument is the Elasticsearch document of the raw fio JSON of a sample. The fio
+// output is nested unchanged under fio, so percentiles can be re-crunched from it later.
+type rawResultDocument struct {
+	UUID        string            `json:"uuid"`
+	User        string            `json:"user"`
+	ClusterName string            `json:"clustername"`
+	Sample      string            `json:"sample"` // <job>_<bs>_<numjobs>-<sample>
+	RunStart    string            `json:"runStart"`
+	Metadata    map[string]string `json:"metadata,omitempty"`
+	FIO         json.RawMessage   `json:"fio"`
+}
+
+// preserveRawResults writes the raw fio JSON of every sample to
+// <artifacts>/<uuid>/fio-json/<job>_<bs>_<numjobs>-<sample>.json and sends it to the sinks
+// of the run as <index_name>-raw when index_raw_results is set
+func (w *Workload) preserveRawResults(ctx context.Context, results []*FIOResult) error {
+	if len(results) == 0 {
+		return nil
+	}
+
+	dir := filepath.Join(w.config.RunArtifactsDir(), "fio-json")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create raw results directory: %w", err)
+	}
+
+	docs := make([]interface{}, 0, len(results))
+	for _, result := range results {
+		sample := strings.TrimPrefix(result.ID, w.config.UUID+"_")
+		filename := filepath.Join(dir, sample+".json")
+		if err := os.WriteFile(filename, result.Raw, 0644); err != nil {
+			return fmt.Errorf("failed to write %s: %w", filename, err)
+		}
+
+		docs = append(docs, rawResultDocument{
+			UUID:        w.config.UUID,
+			User:        w.config.TestUser,
+			ClusterName: w.config.ClusterName,
+			Sample:      sample,
+			RunStart:    timeline.FormatUTC(w.runStart()),
+			Metadata:    w.config.Tags,
+			FIO:         result.Raw,
+		})
+	}
+	log.Printf("Raw fio JSON of %d sample(s) written to %s", len(results), dir)
+
+	if !w.fioConfig.IndexRawResults {
+		return nil
+	}
+	index := w.config.Elasticsearch.IndexPrefix("fio") + "-raw"
+	if err := sinks.Publish(ctx, w.config, index, docs); err != nil {
+		return fmt.Errorf("failed to publish raw fio results: %w", err)
+	}
+	return nil
+}
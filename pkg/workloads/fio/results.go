@@ -10,6 +10,10 @@ import (
 	"strings"
 	"text/tabwriter"
 	"time"
+
+	"github.com/jtaleric/k8s-io/pkg/anonymize"
+	"github.com/jtaleric/k8s-io/pkg/config"
+	"github.com/jtaleric/k8s-io/pkg/units"
 )
 
 // FIOResult represents the complete FIO JSON output
@@ -20,6 +24,9 @@ type FIOResult struct {
 	GlobalOptions map[string]interface{} `json:"global options"`
 	ClientStats   []ClientStats          `json:"client_stats"`
 	DiskUtil      []interface{}          `json:"disk_util"`
+
+	ID  string          `json:"-"` // Sample the result was logged for, <uuid>_<job>_<bs>_<numjobs>-<sample>
+	Raw json.RawMessage `json:"-"` // fio JSON output as logged
 }
 
 // ClientStats represents individual client/job statistics
@@ -91,16 +98,44 @@ type ResultSummary struct {
 	TestID      string
 	Sample      int // Sample number/iteration
 	JobName     string
+	BlockSize   string // bs or bsrange used for the job
+	NumJobs     int
+	RW          string // rw type the job ran with
+	IODepth     int    // queue depth the job ran with
 	Hostname    string
+	Volume      string // directory or device the job ran against
+	Node        string // node the server ran on
+	Device      string // PV, host path or emptyDir behind the volume
 	ReadIOPS    float64
-	ReadBW      int // KB/s
+	ReadBW      int // KiB/s
 	WriteIOPS   float64
-	WriteBW     int     // KB/s
-	ReadLatP50  float64 // microseconds
-	ReadLatP95  float64 // microseconds
-	WriteLatP50 float64 // microseconds
-	WriteLatP95 float64 // microseconds
-	Runtime     int     // seconds
+	WriteBW     int       // KiB/s
+	ReadLatP50  float64   // usec
+	ReadLatP95  float64   // usec
+	WriteLatP50 float64   // usec
+	WriteLatP95 float64   // usec
+	Runtime     int       // seconds
+	CompressPct int       // effective buffer_compress_percentage
+	DedupePct   int       // effective dedupe_percentage
+	Fingerprint string    // config fingerprint of the run
+	Retries     int       // times the sample was rerun after the client was evicted, OOM killed or drained
+	Tags        string    // configured tags as key=value pairs separated by semicolons
+	RunStart    time.Time // start of the run in UTC
+}
+
+// CaptureOptions controls how captured results are exported
+type CaptureOptions struct {
+	ExportCSV   bool                       // Export the results to a CSV file
+	CSV         config.CSVFormat           // Delimiter and decimal separator of the CSV file
+	ExportXLSX  bool                       // Export the results to an Excel workbook next to the CSV file
+	Fingerprint string                     // Config fingerprint recorded with the results
+	Retries     map[string]int             // Reruns per job, block size and numjobs combination
+	Cases       []JobCase                  // Run matrix, fills in parameters missing from the fio options
+	Units       units.Mode                 // How the results table shows bandwidth and latency
+	Tags        string                     // Configured tags recorded with the results
+	RunStart    time.Time                  // Start of the run recorded with the results, now if unset
+	Anonymizer  *anonymize.Anonymizer      // Replaces the hostnames and nodes of the table and CSV, nil to keep them
+	Placements  map[string]ServerPlacement // Node and devices of every server by hostname
 }
 
 // ParseFIOResults parses FIO JSON results from log output
@@ -132,6 +167,8 @@ func ParseFIOResults(logOutput string) ([]*FIOResult, error) {
 				if err := json.Unmarshal([]byte(currentJSON.String()), &result); err != nil {
 					fmt.Printf("Warning: Failed to parse FIO JSON for test %s: %v\n", testID, err)
 				} else {
+					result.ID = testID
+					result.Raw = json.RawMessage(currentJSON.String())
 					results = append(results, &result)
 				}
 			}
@@ -169,6 +206,24 @@ func ExtractResultSummaries(results []*FIOResult, testID string) []ResultSummary
 				Runtime:  client.JobRuntime / 1000, // Convert ms to seconds
 			}
 
+			summary.BlockSize = getStringOption(result, client, "bs")
+			if summary.BlockSize == "" {
+				summary.BlockSize = getStringOption(result, client, "bsrange")
+			}
+			summary.NumJobs = getIntOption(result, client, "numjobs")
+			summary.RW = getStringOption(result, client, "rw")
+			summary.IODepth = getIntOption(result, client, "iodepth")
+
+			// Every volume of a server runs its own job section against its own path
+			summary.Volume = getStringOption(result, client, "directory")
+			if summary.Volume == "" {
+				summary.Volume = getStringOption(result, client, "filename")
+			}
+
+			// Record the data reducibility fio actually used
+			summary.CompressPct = getIntOption(result, client, "buffer_compress_percentage")
+			summary.DedupePct = getIntOption(result, client, "dedupe_percentage")
+
 			// Extract read stats
 			if client.Read.TotalIOs > 0 {
 				summary.ReadIOPS = client.Read.IOPS
@@ -176,10 +231,10 @@ func ExtractResultSummaries(results []*FIOResult, testID string) []ResultSummary
 
 				// Extract percentiles (convert from nanoseconds to microseconds)
 				if p50, ok := client.Read.ClatNs.Percentile["50.000000"].(float64); ok {
-					summary.ReadLatP50 = p50 / 1000.0
+					summary.ReadLatP50 = units.NsToUsec(p50)
 				}
 				if p95, ok := client.Read.ClatNs.Percentile["95.000000"].(float64); ok {
-					summary.ReadLatP95 = p95 / 1000.0
+					summary.ReadLatP95 = units.NsToUsec(p95)
 				}
 			}
 
@@ -190,10 +245,10 @@ func ExtractResultSummaries(results []*FIOResult, testID string) []ResultSummary
 
 				// Extract percentiles (convert from nanoseconds to microseconds)
 				if p50, ok := client.Write.ClatNs.Percentile["50.000000"].(float64); ok {
-					summary.WriteLatP50 = p50 / 1000.0
+					summary.WriteLatP50 = units.NsToUsec(p50)
 				}
 				if p95, ok := client.Write.ClatNs.Percentile["95.000000"].(float64); ok {
-					summary.WriteLatP95 = p95 / 1000.0
+					summary.WriteLatP95 = units.NsToUsec(p95)
 				}
 			}
 
@@ -204,8 +259,180 @@ func ExtractResultSummaries(results []*FIOResult, testID string) []ResultSummary
 	return summaries
 }
 
-// PrintResultsTable prints FIO results in a formatted table
-func PrintResultsTable(summaries []ResultSummary) {
+// AggregationIssue describes a mismatch between the client aggregate and the per-server results
+type AggregationIssue struct {
+	Sample   int
+	Hostname string
+	Reason   string
+}
+
+// String returns a human readable description of the issue
+func (i AggregationIssue) String() string {
+	if i.Hostname != "" {
+		return fmt.Sprintf("sample %d, server %s: %s", i.Sample, i.Hostname, i.Reason)
+	}
+	return fmt.Sprintf("sample %d: %s", i.Sample, i.Reason)
+}
+
+// aggregateTolerance is the allowed relative difference between the aggregate and the per-server sum
+const aggregateTolerance = 0.01
+
+// ValidateAggregation cross-checks the "All clients" totals against the per-server results and
+// flags samples where a server dropped out or did not contribute any I/O
+func ValidateAggregation(results []*FIOResult, expectedServers int) []AggregationIssue {
+	var issues []AggregationIssue
+
+	for sampleIdx, result := range results {
+		sample := sampleIdx + 1
+		hostBytes := make(map[string]int64)
+		var aggregate *ClientStats
+
+		for i := range result.ClientStats {
+			client := &result.ClientStats[i]
+			if client.JobName == "All clients" {
+				aggregate = client
+				continue
+			}
+			hostBytes[client.Hostname] += client.Read.IOBytes + client.Write.IOBytes + client.Trim.IOBytes
+		}
+
+		if len(hostBytes) < expectedServers {
+			issues = append(issues, AggregationIssue{
+				Sample: sample,
+				Reason: fmt.Sprintf("only %d of %d servers reported results", len(hostBytes), expectedServers),
+			})
+		}
+
+		var total int64
+		for hostname, bytes := range hostBytes {
+			if bytes == 0 {
+				issues = append(issues, AggregationIssue{
+					Sample:   sample,
+					Hostname: hostname,
+					Reason:   "server contributed zero I/O",
+				})
+			}
+			total += bytes
+		}
+
+		// fio only emits the aggregate when more than one server is used
+		if aggregate == nil {
+			continue
+		}
+
+		aggregateBytes := aggregate.Read.IOBytes + aggregate.Write.IOBytes + aggregate.Trim.IOBytes
+		if aggregateBytes == 0 && total == 0 {
+			continue
+		}
+
+		diff := float64(aggregateBytes - total)
+		if diff < 0 {
+			diff = -diff
+		}
+		if diff/float64(max(aggregateBytes, total)) > aggregateTolerance {
+			issues = append(issues, AggregationIssue{
+				Sample: sample,
+				Reason: fmt.Sprintf("aggregate I/O (%d bytes) does not match the sum of servers (%d bytes)", aggregateBytes, total),
+			})
+		}
+	}
+
+	return issues
+}
+
+// getStringOption returns a fio option, preferring the job options over global options
+func getStringOption(result *FIOResult, client ClientStats, name string) string {
+	if value, ok := client.JobOptions[name]; ok {
+		return value
+	}
+
+	switch value := result.GlobalOptions[name].(type) {
+	case string:
+		return value
+	case float64:
+		return strconv.FormatFloat(value, 'f', -1, 64)
+	}
+
+	return ""
+}
+
+// getIntOption returns an integer fio option, preferring the job options over global options
+func getIntOption(result *FIOResult, client ClientStats, name string) int {
+	n, err := strconv.Atoi(getStringOption(result, client, name))
+	if err != nil {
+		return 0
+	}
+	return n
+}
+
+// SummarizeMetrics reduces result summaries to key metrics per job, block size and numjobs.
+// Throughput is summed across servers and averaged across samples, latencies are averaged.
+func SummarizeMetrics(summaries []ResultSummary) map[string]float64 {
+	type sampleKey struct {
+		job    string
+		sample int
+	}
+
+	totals := make(map[sampleKey]*ResultSummary)
+	latencies := make(map[string][]float64)
+	var order []sampleKey
+
+	for _, summary := range summaries {
+		job := fmt.Sprintf("%s-%s-%d", summary.JobName, summary.BlockSize, summary.NumJobs)
+		key := sampleKey{job: job, sample: summary.Sample}
+
+		total, ok := totals[key]
+		if !ok {
+			total = &ResultSummary{}
+			totals[key] = total
+			order = append(order, key)
+		}
+		total.ReadIOPS += summary.ReadIOPS
+		total.ReadBW += summary.ReadBW
+		total.WriteIOPS += summary.WriteIOPS
+		total.WriteBW += summary.WriteBW
+
+		if summary.ReadIOPS > 0 {
+			latencies[job+".read_lat_p95_us"] = append(latencies[job+".read_lat_p95_us"], summary.ReadLatP95)
+		}
+		if summary.WriteIOPS > 0 {
+			latencies[job+".write_lat_p95_us"] = append(latencies[job+".write_lat_p95_us"], summary.WriteLatP95)
+		}
+	}
+
+	metrics := make(map[string]float64)
+	samples := make(map[string]float64)
+	for _, key := range order {
+		total := totals[key]
+		metrics[key.job+".read_iops"] += total.ReadIOPS
+		metrics[key.job+".read_bw_kbs"] += float64(total.ReadBW)
+		metrics[key.job+".write_iops"] += total.WriteIOPS
+		metrics[key.job+".write_bw_kbs"] += float64(total.WriteBW)
+		samples[key.job]++
+	}
+
+	for name := range metrics {
+		job := name[:strings.LastIndex(name, ".")]
+		metrics[name] /= samples[job]
+		if metrics[name] == 0 {
+			delete(metrics, name)
+		}
+	}
+
+	for name, values := range latencies {
+		var sum float64
+		for _, v := range values {
+			sum += v
+		}
+		metrics[name] = sum / float64(len(values))
+	}
+
+	return metrics
+}
+
+// PrintResultsTable prints FIO results in a formatted table, with bandwidth and latency
+// shown as plain MiB/s and usec or scaled for reading
+func PrintResultsTable(summaries []ResultSummary, mode units.Mode) {
 	if len(summaries) == 0 {
 		fmt.Println("No FIO results found")
 		return
@@ -214,36 +441,87 @@ func PrintResultsTable(summaries []ResultSummary) {
 	// Create a tab writer for aligned columns
 	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
 
+	// Name the volume of every row when servers test more than one, and the node and device
+	// when the servers were placed
+	volumes := make(map[string]bool)
+	placed, withDevice := false, false
+	for _, summary := range summaries {
+		volumes[summary.Volume] = true
+		placed = placed || summary.Node != ""
+		withDevice = withDevice || summary.Device != ""
+	}
+	perVolume := len(volumes) > 1
+
 	// Print header
+	columns := []string{"Test ID", "Sample", "Job", "Hostname"}
+	if placed {
+		columns = append(columns, "Node")
+	}
+	if perVolume {
+		columns = append(columns, "Volume")
+	}
+	if withDevice {
+		columns = append(columns, "Device")
+	}
+	columns = append(columns,
+		"Read IOPS", units.BandwidthHeader("Read BW", mode), "Write IOPS", units.BandwidthHeader("Write BW", mode),
+		units.LatencyHeader("Read Lat P50", mode), units.LatencyHeader("Read Lat P95", mode),
+		units.LatencyHeader("Write Lat P50", mode), units.LatencyHeader("Write Lat P95", mode),
+		"Runtime (s)",
+	)
+	rules := make([]string, len(columns))
+	for i, column := range columns {
+		rules[i] = strings.Repeat("-", len([]rune(column)))
+	}
 	fmt.Fprintf(w, "\n=== FIO Benchmark Results ===\n")
-	fmt.Fprintf(w, "Test ID\tSample\tJob\tHostname\tRead IOPS\tRead BW (KB/s)\tWrite IOPS\tWrite BW (KB/s)\tRead Lat P50 (μs)\tRead Lat P95 (μs)\tWrite Lat P50 (μs)\tWrite Lat P95 (μs)\tRuntime (s)\n")
-	fmt.Fprintf(w, "-------\t------\t---\t--------\t---------\t-----------\t----------\t------------\t--------------\t--------------\t---------------\t---------------\t-----------\n")
+	fmt.Fprintln(w, strings.Join(columns, "\t"))
+	fmt.Fprintln(w, strings.Join(rules, "\t"))
 
-	// Print data rows
+	// Print data rows, marking samples that were rerun
+	retried := false
 	for _, summary := range summaries {
-		fmt.Fprintf(w, "%s\t%d\t%s\t%s\t%.1f\t%d\t%.1f\t%d\t%.1f\t%.1f\t%.1f\t%.1f\t%d\n",
+		sample := strconv.Itoa(summary.Sample)
+		if summary.Retries > 0 {
+			sample += "*"
+			retried = true
+		}
+		host := summary.Hostname
+		if placed {
+			host += "\t" + summary.Node
+		}
+		if perVolume {
+			host += "\t" + summary.Volume
+		}
+		if withDevice {
+			host += "\t" + summary.Device
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%d\n",
 			summary.TestID,
-			summary.Sample,
+			sample,
 			summary.JobName,
-			summary.Hostname,
-			summary.ReadIOPS,
-			summary.ReadBW,
-			summary.WriteIOPS,
-			summary.WriteBW,
-			summary.ReadLatP50,
-			summary.ReadLatP95,
-			summary.WriteLatP50,
-			summary.WriteLatP95,
+			host,
+			units.IOPS(summary.ReadIOPS, mode),
+			units.Bandwidth(float64(summary.ReadBW), mode),
+			units.IOPS(summary.WriteIOPS, mode),
+			units.Bandwidth(float64(summary.WriteBW), mode),
+			units.Latency(summary.ReadLatP50, mode),
+			units.Latency(summary.ReadLatP95, mode),
+			units.Latency(summary.WriteLatP50, mode),
+			units.Latency(summary.WriteLatP95, mode),
 			summary.Runtime,
 		)
 	}
 
 	w.Flush()
+	if retried {
+		fmt.Println("* rerun after the client was evicted, OOM killed or drained")
+	}
 	fmt.Println()
 }
 
-// ExportResultsToCSV exports FIO results to a CSV file
-func ExportResultsToCSV(summaries []ResultSummary, filename string) error {
+// ExportResultsToCSV exports FIO results to a CSV file with the delimiter and decimal
+// separator of format, a comma and a dot when it is zero
+func ExportResultsToCSV(summaries []ResultSummary, filename string, format config.CSVFormat) error {
 	if len(summaries) == 0 {
 		return fmt.Errorf("no results to export")
 	}
@@ -257,51 +535,23 @@ func ExportResultsToCSV(summaries []ResultSummary, filename string) error {
 
 	// Create CSV writer
 	writer := csv.NewWriter(file)
+	if format.Delimiter != 0 {
+		writer.Comma = format.Delimiter
+	}
 	defer writer.Flush()
 
 	// Write header
-	header := []string{
-		"Test ID",
-		"Sample",
-		"Job Type",
-		"Hostname",
-		"Read IOPS",
-		"Read BW (KB/s)",
-		"Write IOPS",
-		"Write BW (KB/s)",
-		"Read Lat P50 (μs)",
-		"Read Lat P95 (μs)",
-		"Write Lat P50 (μs)",
-		"Write Lat P95 (μs)",
-		"Runtime (s)",
-		"Timestamp",
-	}
-
-	if err := writer.Write(header); err != nil {
+	if err := writer.Write(csvSchemas[CSVSchemaVersion]); err != nil {
 		return fmt.Errorf("failed to write CSV header: %w", err)
 	}
 
 	// Write data rows
-	timestamp := time.Now().Format("2006-01-02 15:04:05")
 	for _, summary := range summaries {
-		record := []string{
-			summary.TestID,
-			strconv.Itoa(summary.Sample),
-			summary.JobName,
-			summary.Hostname,
-			strconv.FormatFloat(summary.ReadIOPS, 'f', 1, 64),
-			strconv.Itoa(summary.ReadBW),
-			strconv.FormatFloat(summary.WriteIOPS, 'f', 1, 64),
-			strconv.Itoa(summary.WriteBW),
-			strconv.FormatFloat(summary.ReadLatP50, 'f', 1, 64),
-			strconv.FormatFloat(summary.ReadLatP95, 'f', 1, 64),
-			strconv.FormatFloat(summary.WriteLatP50, 'f', 1, 64),
-			strconv.FormatFloat(summary.WriteLatP95, 'f', 1, 64),
-			strconv.Itoa(summary.Runtime),
-			timestamp,
+		row := summaryToCSVRow(summary)
+		if format.DecimalComma {
+			localizeCSVRow(row)
 		}
-
-		if err := writer.Write(record); err != nil {
+		if err := writer.Write(row); err != nil {
 			return fmt.Errorf("failed to write CSV record: %w", err)
 		}
 	}
@@ -310,38 +560,106 @@ func ExportResultsToCSV(summaries []ResultSummary, filename string) error {
 }
 
 // CaptureFIOResults captures and parses FIO results from log output
-func CaptureFIOResults(logOutput, testID string) {
-	CaptureFIOResultsWithOptions(logOutput, testID, true) // Default: export to CSV
+func CaptureFIOResults(logOutput, testID string) []*FIOResult {
+	return CaptureFIOResultsWithOptions(logOutput, testID, CaptureOptions{ExportCSV: true}) // Default: export to CSV
 }
 
 // CaptureFIOResultsWithOptions captures and parses FIO results with export options
-func CaptureFIOResultsWithOptions(logOutput, testID string, exportCSV bool) {
+func CaptureFIOResultsWithOptions(logOutput, testID string, opts CaptureOptions) []*FIOResult {
 	results, err := ParseFIOResults(logOutput)
 	if err != nil {
 		fmt.Printf("Error parsing FIO results: %v\n", err)
-		return
+		return nil
 	}
 
 	if len(results) == 0 {
 		fmt.Println("No FIO results found in output")
-		return
+		return nil
 	}
 
 	fmt.Printf("Found %d FIO result(s)\n", len(results))
 
 	// Extract and display summaries
 	summaries := ExtractResultSummaries(results, testID)
-	PrintResultsTable(summaries)
-
-	// Export to CSV if requested
-	if exportCSV {
-		csvFilename := fmt.Sprintf("fio-results-%s-%s.csv", testID, time.Now().Format("20060102-150405"))
-		if err := ExportResultsToCSV(summaries, csvFilename); err != nil {
+	runStart := opts.RunStart
+	if runStart.IsZero() {
+		runStart = time.Now()
+	}
+	for i := range summaries {
+		summaries[i].Fingerprint = opts.Fingerprint
+		summaries[i].Tags = opts.Tags
+		summaries[i].RunStart = runStart.UTC()
+	}
+	annotateRetries(summaries, opts.Retries)
+	correlateCases(summaries, opts.Cases)
+	placeSummaries(summaries, opts.Placements)
+	anonymizeHosts(summaries, opts.Anonymizer)
+	PrintResultsTable(summaries, opts.Units)
+	printDataReducibility(summaries)
+
+	// Export to CSV and Excel if requested
+	basename := fmt.Sprintf("fio-results-%s-%s", testID, time.Now().UTC().Format("20060102-150405Z"))
+	if opts.ExportCSV {
+		csvFilename := basename + ".csv"
+		if err := ExportResultsToCSV(summaries, csvFilename, opts.CSV); err != nil {
 			fmt.Printf("Warning: Failed to export results to CSV: %v\n", err)
 		} else {
 			fmt.Printf("Results exported to: %s\n", csvFilename)
 		}
 	}
+	if opts.ExportXLSX {
+		xlsxFilename := basename + ".xlsx"
+		if err := ExportResultsToXLSX(summaries, xlsxFilename); err != nil {
+			fmt.Printf("Warning: Failed to export results to Excel: %v\n", err)
+		} else {
+			fmt.Printf("Results exported to: %s\n", xlsxFilename)
+		}
+	}
+
+	return results
+}
+
+// anonymizeHosts replaces the hostnames and nodes of the summaries with their pseudonyms
+func anonymizeHosts(summaries []ResultSummary, a *anonymize.Anonymizer) {
+	if a == nil {
+		return
+	}
+	for i := range summaries {
+		summaries[i].Hostname = a.Pseudonym(anonymize.KindHost, summaries[i].Hostname)
+		summaries[i].Node = a.Pseudonym(anonymize.KindNode, summaries[i].Node)
+	}
+}
+
+// printDataReducibility prints the compression and dedupe settings used for the results: one
+// line when every job and server used the same, otherwise a line per job and server
+func printDataReducibility(summaries []ResultSummary) {
+	type setting struct{ compress, dedupe int }
+	var lines []string
+	seen := make(map[string]bool)
+	settings := make(map[setting]bool)
+	reducible := false
+	for _, summary := range summaries {
+		s := setting{summary.CompressPct, summary.DedupePct}
+		settings[s] = true
+		reducible = reducible || s.compress > 0 || s.dedupe > 0
+		line := fmt.Sprintf("Data reducibility of %s-%s-%d on %s: %d%% compressible, %d%% dedupable",
+			summary.JobName, summary.BlockSize, summary.NumJobs, summary.Hostname, s.compress, s.dedupe)
+		if !seen[line] {
+			seen[line] = true
+			lines = append(lines, line)
+		}
+	}
+	if !reducible {
+		return
+	}
+	if len(settings) == 1 {
+		s := summaries[0]
+		fmt.Printf("Data reducibility: %d%% compressible, %d%% dedupable\n", s.CompressPct, s.DedupePct)
+		return
+	}
+	for _, line := range lines {
+		fmt.Println(line)
+	}
 }
 
 // ParseFIOResultsFromReader parses FIO results from a reader (e.g., pod logs)
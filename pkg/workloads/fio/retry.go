@@ -0,0 +1,125 @@
+package fio
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/jtaleric/k8s-io/pkg/forensics"
+	"github.com/jtaleric/k8s-io/pkg/kubernetes"
+	"github.com/jtaleric/k8s-io/pkg/timeline"
+)
+
+// caseKey identifies a job, block size and numjobs combination of the client run matrix
+func caseKey(job, bs string, numjobs int) string {
+	return fmt.Sprintf("%s_%s_%d", job, bs, numjobs)
+}
+
+// clientCases returns the keys of every combination the client runs, in run order
+func (f *FIOConfig) clientCases() []string {
+	var cases []string
+	for _, c := range f.Matrix(nil) {
+		cases = append(cases, c.Key())
+	}
+	return cases
+}
+
+// CompletedCases returns the combinations whose results the client printed for every sample.
+// Results are printed once all samples of a combination finished, so a client that dies
+// mid-combination leaves it incomplete.
+func CompletedCases(logOutput, uuid string, samples int) map[string]bool {
+	printed := make(map[string]int)
+
+	scanner := bufio.NewScanner(strings.NewReader(logOutput))
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if !strings.HasPrefix(line, "END FIO Result for ") {
+			continue
+		}
+
+		// The result ID is <uuid>_<job>_<bs>_<numjobs>-<sample>
+		id := strings.TrimPrefix(strings.TrimPrefix(line, "END FIO Result for "), uuid+"_")
+		if i := strings.LastIndex(id, "-"); i > 0 {
+			printed[id[:i]]++
+		}
+	}
+
+	completed := make(map[string]bool)
+	for key, count := range printed {
+		if count >= samples {
+			completed[key] = true
+		}
+	}
+	return completed
+}
+
+// annotateRetries records how often the combination of each summary was rerun
+func annotateRetries(summaries []ResultSummary, retries map[string]int) {
+	for i := range summaries {
+		summaries[i].Retries = retries[caseKey(summaries[i].JobName, summaries[i].BlockSize, summaries[i].NumJobs)]
+	}
+}
+
+// retryClient reruns the combinations a failed client did not complete when the failure was
+// caused by the environment and retries are left, and returns the job error otherwise
+func (w *Workload) retryClient(ctx context.Context, jobName string, attempt int, jobErr error) error {
+	if attempt >= w.fioConfig.SampleRetries {
+		return jobErr
+	}
+	// A client that ran out of job_timeout would run out of it again
+	if errors.Is(jobErr, kubernetes.ErrTimeout) {
+		return fmt.Errorf("client did not complete within job_timeout of %ds: %w", w.fioConfig.JobTimeout, jobErr)
+	}
+
+	failure, err := w.k8sClient.ClassifyJobFailure(ctx, jobName, w.config.Namespace)
+	if err != nil {
+		log.Printf("Warning: failed to classify client failure: %v", err)
+		return jobErr
+	}
+	if !failure.Environmental {
+		return fmt.Errorf("%w: %s", jobErr, failure)
+	}
+
+	log.Printf("Client failed because of the environment, %s; retrying (attempt %d of %d)",
+		failure, attempt+1, w.fioConfig.SampleRetries)
+
+	// Keep the results of the combinations that completed before the failure
+	logs, err := w.k8sClient.GetJobPodLogs(ctx, jobName, w.config.Namespace)
+	if err != nil {
+		log.Printf("Warning: failed to get logs of the failed client, rerunning all incomplete combinations: %v", err)
+	}
+	w.clientLogs = append(w.clientLogs, logs)
+
+	completed := CompletedCases(strings.Join(w.clientLogs, ""), w.config.UUID, w.fioConfig.Samples)
+	remaining := 0
+	for _, key := range w.fioConfig.clientCases() {
+		if !completed[key] {
+			w.caseRetries[key]++
+			remaining++
+		}
+	}
+	log.Printf("Rerunning %d of %d combinations", remaining, len(w.fioConfig.clientCases()))
+	timeline.Record(timeline.EventRetry, jobName, "%s; attempt %d of %d reruns %d of %d combinations",
+		failure, attempt+1, w.fioConfig.SampleRetries, remaining, len(w.fioConfig.clientCases()))
+
+	// The failed pod is deleted with its job, so record why it failed first
+	forensics.Capture(ctx, w.k8sClient, w.config, w.Namespaces())
+
+	if err := w.k8sClient.DeleteJob(ctx, jobName, w.config.Namespace); err != nil {
+		return fmt.Errorf("failed to delete failed client: %w", err)
+	}
+
+	client, err := w.templateEngine.RenderFIOClientWithPrometheus(w.config, w.fioConfig, w.podDetails, w.k8sClient, completed)
+	if err != nil {
+		return fmt.Errorf("failed to render client: %w", err)
+	}
+	if err := w.k8sClient.ApplyManifest(ctx, client, w.config.Namespace); err != nil {
+		return fmt.Errorf("failed to apply client: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,78 @@
+package fio
+
+import (
+	"bufio"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jtaleric/k8s-io/pkg/timeline"
+)
+
+// Markers printed by the client around every sample when sample barriers are enabled
+const (
+	sampleStartMarker = "FIO_SAMPLE_START"
+	sampleEndMarker   = "FIO_SAMPLE_END"
+	cacheDropMarker   = "FIO_CACHE_DROP"
+)
+
+// ParseSampleWindows extracts the start and end time of every sample from the client log
+func ParseSampleWindows(logOutput string) []timeline.Phase {
+	starts := make(map[string]time.Time)
+	var phases []timeline.Phase
+
+	scanner := bufio.NewScanner(strings.NewReader(logOutput))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 3 || (fields[0] != sampleStartMarker && fields[0] != sampleEndMarker) {
+			continue
+		}
+
+		epoch, err := strconv.ParseInt(fields[2], 10, 64)
+		if err != nil {
+			continue
+		}
+		ts := time.Unix(epoch, 0).UTC()
+
+		if fields[0] == sampleStartMarker {
+			starts[fields[1]] = ts
+			continue
+		}
+
+		if start, ok := starts[fields[1]]; ok {
+			phases = append(phases, timeline.Phase{Name: "sample " + fields[1], Start: start, End: ts})
+			delete(starts, fields[1])
+		}
+	}
+
+	return phases
+}
+
+// ParseCacheDrops extracts the cache drops the client made before every sample from its log,
+// as events of the run timeline. Failed drops are only logged as warnings.
+func ParseCacheDrops(logOutput string) []timeline.Event {
+	var events []timeline.Event
+
+	scanner := bufio.NewScanner(strings.NewReader(logOutput))
+	for scanner.Scan() {
+		// FIO_CACHE_DROP <sample> <kernel|ceph> <address> <epoch>
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 5 || fields[0] != cacheDropMarker {
+			continue
+		}
+
+		epoch, err := strconv.ParseInt(fields[4], 10, 64)
+		if err != nil {
+			continue
+		}
+		events = append(events, timeline.Event{
+			Time:    time.Unix(epoch, 0).UTC(),
+			Kind:    timeline.EventCacheDrop,
+			Subject: "sample " + fields[1],
+			Message: fmt.Sprintf("dropped the %s cache on %s", fields[2], fields[3]),
+		})
+	}
+
+	return events
+}
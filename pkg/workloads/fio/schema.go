@@ -0,0 +1,301 @@
+package fio
+
+import (
+	"encoding/csv"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jtaleric/k8s-io/pkg/config"
+	"github.com/jtaleric/k8s-io/pkg/timeline"
+)
+
+// CSVSchemaVersion is the version of the CSV schema written by ExportResultsToCSV
+const CSVSchemaVersion = 10
+
+// legacyTimestampFormat is the format of the Timestamp column of schema versions before 8,
+// the local time of the machine that exported the results
+const legacyTimestampFormat = "2006-01-02 15:04:05"
+
+// csvSchemas lists the CSV header of every schema version that can be read.
+// Columns are matched by name, so converting an older file only leaves the newer columns empty.
+var csvSchemas = map[int][]string{
+	1: {
+		"Test ID", "Sample", "Job Type", "Hostname",
+		"Read IOPS", "Read BW (KB/s)", "Write IOPS", "Write BW (KB/s)",
+		"Read Lat P50 (μs)", "Read Lat P95 (μs)", "Write Lat P50 (μs)", "Write Lat P95 (μs)",
+		"Runtime (s)", "Timestamp",
+	},
+	2: {
+		"Test ID", "Sample", "Job Type", "Hostname",
+		"Read IOPS", "Read BW (KB/s)", "Write IOPS", "Write BW (KB/s)",
+		"Read Lat P50 (μs)", "Read Lat P95 (μs)", "Write Lat P50 (μs)", "Write Lat P95 (μs)",
+		"Runtime (s)", "Compress (%)", "Dedupe (%)", "Config Fingerprint", "Timestamp",
+	},
+	3: {
+		"Test ID", "Sample", "Job Type", "Block Size", "NumJobs", "Hostname",
+		"Read IOPS", "Read BW (KB/s)", "Write IOPS", "Write BW (KB/s)",
+		"Read Lat P50 (μs)", "Read Lat P95 (μs)", "Write Lat P50 (μs)", "Write Lat P95 (μs)",
+		"Runtime (s)", "Compress (%)", "Dedupe (%)", "Config Fingerprint", "Timestamp",
+		"Schema Version",
+	},
+	4: {
+		"Test ID", "Sample", "Job Type", "Block Size", "NumJobs", "Hostname",
+		"Read IOPS", "Read BW (KB/s)", "Write IOPS", "Write BW (KB/s)",
+		"Read Lat P50 (μs)", "Read Lat P95 (μs)", "Write Lat P50 (μs)", "Write Lat P95 (μs)",
+		"Runtime (s)", "Compress (%)", "Dedupe (%)", "Config Fingerprint", "Retries", "Timestamp",
+		"Schema Version",
+	},
+	5: {
+		"Test ID", "Sample", "Job Type", "Block Size", "NumJobs", "Hostname",
+		"Read IOPS", "Read BW (KB/s)", "Write IOPS", "Write BW (KB/s)",
+		"Read Lat P50 (μs)", "Read Lat P95 (μs)", "Write Lat P50 (μs)", "Write Lat P95 (μs)",
+		"Runtime (s)", "Compress (%)", "Dedupe (%)", "Config Fingerprint", "Retries", "Tags", "Timestamp",
+		"Schema Version",
+	},
+	6: {
+		"Test ID", "Sample", "Job Type", "RW", "Block Size", "NumJobs", "IODepth", "Hostname",
+		"Read IOPS", "Read BW (KB/s)", "Write IOPS", "Write BW (KB/s)",
+		"Read Lat P50 (μs)", "Read Lat P95 (μs)", "Write Lat P50 (μs)", "Write Lat P95 (μs)",
+		"Runtime (s)", "Compress (%)", "Dedupe (%)", "Config Fingerprint", "Retries", "Tags", "Timestamp",
+		"Schema Version",
+	},
+	7: {
+		"Test ID", "Sample", "Job Type", "RW", "Block Size", "NumJobs", "IODepth", "Hostname",
+		"Read IOPS", "Read BW (KiB/s)", "Write IOPS", "Write BW (KiB/s)",
+		"Read Lat P50 (usec)", "Read Lat P95 (usec)", "Write Lat P50 (usec)", "Write Lat P95 (usec)",
+		"Runtime (s)", "Compress (%)", "Dedupe (%)", "Config Fingerprint", "Retries", "Tags", "Timestamp",
+		"Schema Version",
+	},
+	8: {
+		"Test ID", "Sample", "Job Type", "RW", "Block Size", "NumJobs", "IODepth", "Hostname",
+		"Read IOPS", "Read BW (KiB/s)", "Write IOPS", "Write BW (KiB/s)",
+		"Read Lat P50 (usec)", "Read Lat P95 (usec)", "Write Lat P50 (usec)", "Write Lat P95 (usec)",
+		"Runtime (s)", "Compress (%)", "Dedupe (%)", "Config Fingerprint", "Retries", "Tags", "Run Start (UTC)",
+		"Schema Version",
+	},
+	9: {
+		"Test ID", "Sample", "Job Type", "RW", "Block Size", "NumJobs", "IODepth", "Hostname", "Volume",
+		"Read IOPS", "Read BW (KiB/s)", "Write IOPS", "Write BW (KiB/s)",
+		"Read Lat P50 (usec)", "Read Lat P95 (usec)", "Write Lat P50 (usec)", "Write Lat P95 (usec)",
+		"Runtime (s)", "Compress (%)", "Dedupe (%)", "Config Fingerprint", "Retries", "Tags", "Run Start (UTC)",
+		"Schema Version",
+	},
+	10: {
+		"Test ID", "Sample", "Job Type", "RW", "Block Size", "NumJobs", "IODepth", "Hostname", "Node", "Volume", "Device",
+		"Read IOPS", "Read BW (KiB/s)", "Write IOPS", "Write BW (KiB/s)",
+		"Read Lat P50 (usec)", "Read Lat P95 (usec)", "Write Lat P50 (usec)", "Write Lat P95 (usec)",
+		"Runtime (s)", "Compress (%)", "Dedupe (%)", "Config Fingerprint", "Retries", "Tags", "Run Start (UTC)",
+		"Schema Version",
+	},
+}
+
+// renamedColumns maps the column names of schema versions before 7 to the current ones.
+// The values did not change: fio reports bandwidth in KiB/s, which was labeled KB/s.
+var renamedColumns = map[string]string{
+	"Read BW (KB/s)":     "Read BW (KiB/s)",
+	"Write BW (KB/s)":    "Write BW (KiB/s)",
+	"Read Lat P50 (μs)":  "Read Lat P50 (usec)",
+	"Read Lat P95 (μs)":  "Read Lat P95 (usec)",
+	"Write Lat P50 (μs)": "Write Lat P50 (usec)",
+	"Write Lat P95 (μs)": "Write Lat P95 (usec)",
+}
+
+// detectCSVSchema returns the schema version matching a CSV header
+func detectCSVSchema(header []string) (int, error) {
+	for version, columns := range csvSchemas {
+		if strings.Join(columns, ",") == strings.Join(header, ",") {
+			return version, nil
+		}
+	}
+	return 0, fmt.Errorf("unrecognized results CSV header")
+}
+
+// summaryToCSVRow converts a result summary to a row of the current CSV schema
+func summaryToCSVRow(summary ResultSummary) []string {
+	values := map[string]string{
+		"Test ID":              summary.TestID,
+		"Sample":               strconv.Itoa(summary.Sample),
+		"Job Type":             summary.JobName,
+		"Block Size":           summary.BlockSize,
+		"RW":                   summary.RW,
+		"NumJobs":              strconv.Itoa(summary.NumJobs),
+		"IODepth":              strconv.Itoa(summary.IODepth),
+		"Hostname":             summary.Hostname,
+		"Node":                 summary.Node,
+		"Volume":               summary.Volume,
+		"Device":               summary.Device,
+		"Read IOPS":            strconv.FormatFloat(summary.ReadIOPS, 'f', -1, 64),
+		"Read BW (KiB/s)":      strconv.Itoa(summary.ReadBW),
+		"Write IOPS":           strconv.FormatFloat(summary.WriteIOPS, 'f', -1, 64),
+		"Write BW (KiB/s)":     strconv.Itoa(summary.WriteBW),
+		"Read Lat P50 (usec)":  strconv.FormatFloat(summary.ReadLatP50, 'f', -1, 64),
+		"Read Lat P95 (usec)":  strconv.FormatFloat(summary.ReadLatP95, 'f', -1, 64),
+		"Write Lat P50 (usec)": strconv.FormatFloat(summary.WriteLatP50, 'f', -1, 64),
+		"Write Lat P95 (usec)": strconv.FormatFloat(summary.WriteLatP95, 'f', -1, 64),
+		"Runtime (s)":          strconv.Itoa(summary.Runtime),
+		"Compress (%)":         strconv.Itoa(summary.CompressPct),
+		"Dedupe (%)":           strconv.Itoa(summary.DedupePct),
+		"Config Fingerprint":   summary.Fingerprint,
+		"Retries":              strconv.Itoa(summary.Retries),
+		"Tags":                 summary.Tags,
+		"Run Start (UTC)":      timeline.FormatUTC(summary.RunStart),
+		"Schema Version":       strconv.Itoa(CSVSchemaVersion),
+	}
+
+	columns := csvSchemas[CSVSchemaVersion]
+	row := make([]string, len(columns))
+	for i, column := range columns {
+		row[i] = values[column]
+	}
+	return row
+}
+
+// csvFloatColumns are the columns of fractional numbers, written with a decimal comma for
+// locales that use one
+var csvFloatColumns = map[string]bool{
+	"Read IOPS": true, "Write IOPS": true,
+	"Read Lat P50 (usec)": true, "Read Lat P95 (usec)": true,
+	"Write Lat P50 (usec)": true, "Write Lat P95 (usec)": true,
+}
+
+// localizeCSVRow replaces the decimal point of the fractional numbers of a row of the
+// current schema with a comma
+func localizeCSVRow(row []string) {
+	for i, column := range csvSchemas[CSVSchemaVersion] {
+		if csvFloatColumns[column] {
+			row[i] = strings.Replace(row[i], ".", ",", 1)
+		}
+	}
+}
+
+// csvRowToSummary converts a CSV row of any known schema to a result summary
+func csvRowToSummary(header, row []string) (ResultSummary, error) {
+	values := make(map[string]string, len(header))
+	for i, column := range header {
+		if renamed, ok := renamedColumns[column]; ok {
+			column = renamed
+		}
+		if i < len(row) {
+			values[column] = row[i]
+		}
+	}
+
+	var summary ResultSummary
+	var errs []string
+
+	parseInt := func(column string) int {
+		if values[column] == "" {
+			return 0
+		}
+		n, err := strconv.Atoi(values[column])
+		if err != nil {
+			errs = append(errs, column)
+		}
+		return n
+	}
+	parseFloat := func(column string) float64 {
+		if values[column] == "" {
+			return 0
+		}
+		// Files exported for a locale with a decimal comma
+		f, err := strconv.ParseFloat(strings.Replace(values[column], ",", ".", 1), 64)
+		if err != nil {
+			errs = append(errs, column)
+		}
+		return f
+	}
+
+	summary.TestID = values["Test ID"]
+	summary.Sample = parseInt("Sample")
+	summary.JobName = values["Job Type"]
+	summary.BlockSize = values["Block Size"]
+	summary.RW = values["RW"]
+	summary.NumJobs = parseInt("NumJobs")
+	summary.IODepth = parseInt("IODepth")
+	summary.Hostname = values["Hostname"]
+	summary.Node = values["Node"]
+	summary.Volume = values["Volume"]
+	summary.Device = values["Device"]
+	summary.ReadIOPS = parseFloat("Read IOPS")
+	summary.ReadBW = parseInt("Read BW (KiB/s)")
+	summary.WriteIOPS = parseFloat("Write IOPS")
+	summary.WriteBW = parseInt("Write BW (KiB/s)")
+	summary.ReadLatP50 = parseFloat("Read Lat P50 (usec)")
+	summary.ReadLatP95 = parseFloat("Read Lat P95 (usec)")
+	summary.WriteLatP50 = parseFloat("Write Lat P50 (usec)")
+	summary.WriteLatP95 = parseFloat("Write Lat P95 (usec)")
+	summary.Runtime = parseInt("Runtime (s)")
+	summary.CompressPct = parseInt("Compress (%)")
+	summary.DedupePct = parseInt("Dedupe (%)")
+	summary.Fingerprint = values["Config Fingerprint"]
+	summary.Retries = parseInt("Retries")
+	summary.Tags = values["Tags"]
+	summary.RunStart = parseRunStart(values, &errs)
+
+	if len(errs) > 0 {
+		return summary, fmt.Errorf("invalid values in columns: %s", strings.Join(errs, ", "))
+	}
+
+	return summary, nil
+}
+
+// parseRunStart returns the run start of a CSV row. Schema versions before 8 recorded the
+// local time of the export instead, which is assumed to be in the local timezone.
+func parseRunStart(values map[string]string, errs *[]string) time.Time {
+	if value := values["Run Start (UTC)"]; value != "" {
+		t, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			*errs = append(*errs, "Run Start (UTC)")
+		}
+		return t.UTC()
+	}
+	if value := values["Timestamp"]; value != "" {
+		t, err := time.ParseInLocation(legacyTimestampFormat, value, time.Local)
+		if err != nil {
+			*errs = append(*errs, "Timestamp")
+		}
+		return t.UTC()
+	}
+	return time.Time{}
+}
+
+// ReadResultsCSV reads a results CSV written by any schema version and with any delimiter
+// and decimal separator, and returns the summaries converted to the current schema together
+// with the file's schema version
+func ReadResultsCSV(filename string) ([]ResultSummary, int, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to open CSV file %s: %w", filename, err)
+	}
+
+	header, _, _ := strings.Cut(string(data), "\n")
+	reader := csv.NewReader(strings.NewReader(string(data)))
+	reader.Comma = config.SniffCSVDelimiter(header)
+	rows, err := reader.ReadAll()
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to read CSV file %s: %w", filename, err)
+	}
+
+	if len(rows) == 0 {
+		return nil, 0, fmt.Errorf("CSV file %s is empty", filename)
+	}
+
+	version, err := detectCSVSchema(rows[0])
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to read CSV file %s: %w", filename, err)
+	}
+
+	var summaries []ResultSummary
+	for i, row := range rows[1:] {
+		summary, err := csvRowToSummary(rows[0], row)
+		if err != nil {
+			return nil, version, fmt.Errorf("failed to parse line %d of %s: %w", i+2, filename, err)
+		}
+		summaries = append(summaries, summary)
+	}
+
+	return summaries, version, nil
+}
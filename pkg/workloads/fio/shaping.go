@@ -0,0 +1,93 @@
+package fio
+
+import (
+	"fmt"
+	"time"
+)
+
+// DutyCycleConfig alternates periods of I/O with idle periods, like a batch job or an
+// application with a daily peak, rather than keeping the volume saturated for the whole run
+type DutyCycleConfig struct {
+	Active string `yaml:"active"` // Duration of I/O of every cycle, e.g. 30s
+	Idle   string `yaml:"idle"`   // Duration without I/O of every cycle, e.g. 30s
+}
+
+// validateShaping checks the think time and duty cycle settings
+func (f *FIOConfig) validateShaping() error {
+	if f.DutyCycle != nil {
+		if f.ThinkTime != "" || f.ThinkTimeBlocks != 0 || f.ThinkTimeSpin != "" {
+			return fmt.Errorf("duty_cycle is implemented with fio think times and cannot be combined with thinktime, thinktime_blocks or thinktime_spin")
+		}
+		if _, err := positiveDuration("duty_cycle.active", f.DutyCycle.Active); err != nil {
+			return err
+		}
+		if _, err := positiveDuration("duty_cycle.idle", f.DutyCycle.Idle); err != nil {
+			return err
+		}
+		return nil
+	}
+
+	if f.ThinkTime == "" {
+		if f.ThinkTimeBlocks != 0 || f.ThinkTimeSpin != "" {
+			return fmt.Errorf("thinktime_blocks and thinktime_spin require thinktime")
+		}
+		return nil
+	}
+	think, err := positiveDuration("thinktime", f.ThinkTime)
+	if err != nil {
+		return err
+	}
+	if f.ThinkTimeBlocks < 0 {
+		return fmt.Errorf("thinktime_blocks must be positive, got %d", f.ThinkTimeBlocks)
+	}
+	if f.ThinkTimeSpin != "" {
+		spin, err := positiveDuration("thinktime_spin", f.ThinkTimeSpin)
+		if err != nil {
+			return err
+		}
+		if spin > think {
+			return fmt.Errorf("thinktime_spin %s exceeds thinktime %s", f.ThinkTimeSpin, f.ThinkTime)
+		}
+	}
+	return nil
+}
+
+// positiveDuration parses a Go duration that must be positive
+func positiveDuration(name, value string) (time.Duration, error) {
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s %q: %w", name, value, err)
+	}
+	if d <= 0 {
+		return 0, fmt.Errorf("%s must be positive, got %s", name, value)
+	}
+	return d, nil
+}
+
+// ShapingParams returns the fio options of the think time or duty cycle settings. A duty
+// cycle sets thinktime_iotime without thinktime_blocks, so fio idles for the idle period
+// after every active period of I/O; it requires fio 3.28 or later.
+func (f *FIOConfig) ShapingParams() []string {
+	if f.DutyCycle != nil {
+		active, _ := time.ParseDuration(f.DutyCycle.Active)
+		idle, _ := time.ParseDuration(f.DutyCycle.Idle)
+		return []string{
+			fmt.Sprintf("thinktime=%dus", idle.Microseconds()),
+			fmt.Sprintf("thinktime_iotime=%dus", active.Microseconds()),
+		}
+	}
+	if f.ThinkTime == "" {
+		return nil
+	}
+
+	think, _ := time.ParseDuration(f.ThinkTime)
+	params := []string{fmt.Sprintf("thinktime=%dus", think.Microseconds())}
+	if f.ThinkTimeBlocks > 0 {
+		params = append(params, fmt.Sprintf("thinktime_blocks=%d", f.ThinkTimeBlocks))
+	}
+	if f.ThinkTimeSpin != "" {
+		spin, _ := time.ParseDuration(f.ThinkTimeSpin)
+		params = append(params, fmt.Sprintf("thinktime_spin=%dus", spin.Microseconds()))
+	}
+	return params
+}
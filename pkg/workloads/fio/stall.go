@@ -0,0 +1,154 @@
+package fio
+
+import (
+	"bufio"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Markers printed by the client around every fio latency log when failover is enabled
+const (
+	latLogMarker    = "FIO_LAT_LOG" // FIO_LAT_LOG <uuid>_<job>_<bs>_<numjobs>-<sample> <file>
+	latLogEndMarker = "END_FIO_LAT_LOG"
+)
+
+// LatencyEntry is a line of a fio latency log: the I/O, or the average of the I/O of a
+// log_avg_msec interval, completed at Offset after the job started and took Latency
+type LatencyEntry struct {
+	Offset  time.Duration
+	Latency time.Duration
+}
+
+// LatencyLog is the latency log of one job of a sample on one server
+type LatencyLog struct {
+	Sample  string // <uuid>_<job>_<bs>_<numjobs>-<sample>, the ID of the sample result
+	File    string // Log file name, which names the server for client/server runs
+	Entries []LatencyEntry
+}
+
+// ParseLatencyLogs extracts the fio latency logs the client printed between latency log
+// markers. Lines are "time, value, ddir, bs, offset" with the time in msec and the latency
+// in nsec.
+func ParseLatencyLogs(logOutput string) []LatencyLog {
+	var logs []LatencyLog
+	var current *LatencyLog
+
+	scanner := bufio.NewScanner(strings.NewReader(logOutput))
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		fields := strings.Fields(line)
+
+		if len(fields) == 3 && fields[0] == latLogMarker {
+			logs = append(logs, LatencyLog{Sample: fields[1], File: fields[2]})
+			current = &logs[len(logs)-1]
+			continue
+		}
+		if line == latLogEndMarker {
+			current = nil
+			continue
+		}
+		if current == nil {
+			continue
+		}
+
+		values := strings.Split(line, ",")
+		if len(values) < 2 {
+			continue
+		}
+		msec, err1 := strconv.ParseInt(strings.TrimSpace(values[0]), 10, 64)
+		nsec, err2 := strconv.ParseInt(strings.TrimSpace(values[1]), 10, 64)
+		if err1 != nil || err2 != nil {
+			continue
+		}
+		current.Entries = append(current.Entries, LatencyEntry{
+			Offset:  time.Duration(msec) * time.Millisecond,
+			Latency: time.Duration(nsec),
+		})
+	}
+	return logs
+}
+
+// Stall is the longest window of a latency log in which no I/O completed
+type Stall struct {
+	Sample    string    `json:"sample"`
+	File      string    `json:"file"`
+	Start     time.Time `json:"start"`
+	End       time.Time `json:"end"`
+	Recovered time.Time `json:"recovered,omitempty"` // When latency was back to twice the median before the stall, zero if it was not
+}
+
+// Duration returns how long no I/O completed
+func (s Stall) Duration() time.Duration {
+	return s.End.Sub(s.Start)
+}
+
+// Recovery returns how long latency took to return to its baseline after the stall, or -1
+// if it did not before the log ended
+func (s Stall) Recovery() time.Duration {
+	if s.Recovered.IsZero() {
+		return -1
+	}
+	return s.Recovered.Sub(s.End)
+}
+
+// FindStall returns the longest stall of at least threshold in a latency log of a job that
+// started at jobStart. A stall is a gap between two log lines, or an I/O that took longer
+// than threshold, which was stuck for the whole window before it completed.
+func FindStall(lat LatencyLog, jobStart time.Time, threshold time.Duration) (Stall, bool) {
+	entries := append([]LatencyEntry(nil), lat.Entries...)
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Offset < entries[j].Offset })
+
+	var start, end time.Duration
+	extend := func(s, e time.Duration) {
+		// Windows are found in completion order, so an overlapping window extends the last one
+		if e-s < threshold {
+			return
+		}
+		if s <= end && e > end && end > 0 {
+			if e-start > end-start {
+				end = e
+			}
+			return
+		}
+		if e-s > end-start {
+			start, end = s, e
+		}
+	}
+	for i, entry := range entries {
+		if entry.Latency >= threshold {
+			extend(entry.Offset-entry.Latency, entry.Offset)
+		}
+		if i > 0 {
+			extend(entries[i-1].Offset, entry.Offset)
+		}
+	}
+	if end == 0 {
+		return Stall{}, false
+	}
+
+	stall := Stall{Sample: lat.Sample, File: lat.File, Start: jobStart.Add(start), End: jobStart.Add(end)}
+
+	// Baseline is the median latency before the stall
+	var before []time.Duration
+	for _, entry := range entries {
+		if entry.Offset < start {
+			before = append(before, entry.Latency)
+		}
+	}
+	if len(before) == 0 {
+		return stall, true
+	}
+	sort.Slice(before, func(i, j int) bool { return before[i] < before[j] })
+	baseline := before[len(before)/2]
+
+	for _, entry := range entries {
+		if entry.Offset > end && entry.Latency <= 2*baseline {
+			stall.Recovered = jobStart.Add(entry.Offset)
+			break
+		}
+	}
+	return stall, true
+}
@@ -0,0 +1,98 @@
+package fio
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// targetMarker starts the line every server pod logs at start for each data volume, with its
+// path, the path it resolves to and its file type as reported by stat
+const targetMarker = "FIO_TARGET"
+
+// serverTarget is a FIO_TARGET line of a server pod
+type serverTarget struct {
+	Path     string // Data volume path, fio_path for a single volume
+	Resolved string // Path it resolves to
+	FileType string // File type reported by stat
+}
+
+// verifyTargets checks that every data volume path is a block device in every server pod for
+// pvcvolumemode Block and a directory otherwise, so a missing device or volume fails the run
+// instead of fio benchmarking a file on the container filesystem. It returns the facts the
+// servers logged about their nodes.
+func (w *Workload) verifyTargets(ctx context.Context) (map[string]nodeFacts, error) {
+	facts := make(map[string]nodeFacts)
+
+	// VM servers log to their console
+	if w.fioConfig.Kind == "vm" {
+		return facts, nil
+	}
+
+	want := "directory"
+	if w.fioConfig.PVCVolumeMode == "Block" {
+		want = "block special file"
+	}
+
+	var pods []corev1.Pod
+	for _, ns := range w.serverNamespaces() {
+		list, err := w.k8sClient.ListPods(ctx, ns, fmt.Sprintf("app=fio-benchmark-%s", w.config.GetTruncatedUUID()))
+		if err != nil {
+			return nil, fmt.Errorf("failed to list servers: %w", err)
+		}
+		pods = append(pods, list.Items...)
+	}
+
+	for _, pod := range pods {
+		targets, node, err := w.serverTargets(ctx, pod.Namespace, pod.Name)
+		if err != nil {
+			log.Printf("Warning: could not verify fio_path on %s: %v", pod.Name, err)
+			continue
+		}
+		if node != nil {
+			facts[pod.Spec.NodeName] = *node
+		}
+		if len(targets) < w.fioConfig.VolumesPerServer {
+			log.Printf("Warning: %s logged %d of %d data volumes", pod.Name, len(targets), w.fioConfig.VolumesPerServer)
+		}
+		for _, target := range targets {
+			if target.FileType != want {
+				return nil, fmt.Errorf("fio_path %s on %s is %q, expected a %s for pvcvolumemode %s", target.Path, pod.Name, target.FileType, want, w.fioConfig.PVCVolumeMode)
+			}
+			log.Printf("Server %s tests %s (%s)", pod.Name, target.Resolved, target.FileType)
+		}
+	}
+	return facts, nil
+}
+
+// serverTargets returns the FIO_TARGET lines of a server pod, one per data volume, and the
+// FIO_NODE facts about its node, nil for servers started from older templates
+func (w *Workload) serverTargets(ctx context.Context, namespace, podName string) ([]serverTarget, *nodeFacts, error) {
+	stream, err := w.k8sClient.GetPodLogs(ctx, namespace, podName, "fio-server")
+	if err != nil {
+		return nil, nil, err
+	}
+	defer stream.Close()
+
+	var targets []serverTarget
+	var node *nodeFacts
+	scanner := bufio.NewScanner(stream)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) >= 4 && fields[0] == targetMarker {
+			targets = append(targets, serverTarget{Path: fields[1], Resolved: fields[2], FileType: strings.Join(fields[3:], " ")})
+		}
+		if len(fields) >= 2 && fields[0] == nodeMarker {
+			facts := parseNodeFacts(fields[1:])
+			node = &facts
+		}
+	}
+	if len(targets) == 0 {
+		return nil, nil, fmt.Errorf("no %s line in the server log", targetMarker)
+	}
+	return targets, node, nil
+}
@@ -4,31 +4,22 @@ import (
 	"context"
 	"embed"
 	"fmt"
+	"log"
+	"os"
+	"path/filepath"
 	"regexp"
+	"sort"
 	"strings"
 
 	"github.com/flosch/pongo2/v6"
 	"github.com/jtaleric/k8s-io/pkg/config"
 	"github.com/jtaleric/k8s-io/pkg/kubernetes"
+	"github.com/jtaleric/k8s-io/pkg/templatedebug"
 )
 
 //go:embed templates/*.j2
 var embeddedTemplates embed.FS
 
-// safeElasticsearch returns a safe Elasticsearch config or empty map if nil
-func safeElasticsearch(es *config.ElasticsearchConfig) interface{} {
-	if es == nil {
-		return map[string]interface{}{}
-	}
-	// Convert struct to map for better template compatibility
-	return map[string]interface{}{
-		"url":         es.URL,
-		"index_name":  es.IndexName,
-		"verify_cert": es.VerifyCert,
-		"parallel":    es.Parallel,
-	}
-}
-
 // safePrometheus returns a safe Prometheus config or empty map if nil
 func safePrometheus(prom interface{}) interface{} {
 	if prom == nil {
@@ -39,26 +30,59 @@ func safePrometheus(prom interface{}) interface{} {
 
 // TemplateEngine handles FIO template processing
 type TemplateEngine struct {
-	templateSet *pongo2.TemplateSet
+	templateSet  *pongo2.TemplateSet
+	templatesDir string // Templates replacing the embedded ones, empty for none
+	debug        *templatedebug.Dumper
+	checksums    map[string]string // ConfigMap checksums by role, see recordChecksum
+	topology     Topology          // Nodes of the servers, once they are placed
+	prometheus   *prometheusEndpoint
+}
+
+// prometheusEndpoint is the Prometheus passed to the client pods once the run checked it,
+// without an endpoint when none answered
+type prometheusEndpoint struct {
+	info *kubernetes.PrometheusInfo
 }
 
-// NewTemplateEngine creates a new FIO template engine
+// configMount is a ConfigMap mount whose content a pod verifies before starting fio
+type configMount struct {
+	Path     string
+	Checksum string
+}
+
+// NewTemplateEngine creates a new FIO template engine. Templates are embedded in the binary,
+// a template of the same name in templatesDir replaces the embedded one.
 func NewTemplateEngine(templatesDir string) *TemplateEngine {
-	// Note: templatesDir parameter is kept for backward compatibility but not used
-	// Templates are now embedded in the binary
 	templateSet := pongo2.NewSet("fio-templates", nil)
 
 	return &TemplateEngine{
-		templateSet: templateSet,
+		templateSet:  templateSet,
+		templatesDir: templatesDir,
+		checksums:    make(map[string]string),
 	}
 }
 
+// SetTopology makes the nodes of the servers available to the templates rendered from now on
+func (e *TemplateEngine) SetTopology(topology Topology) {
+	e.topology = topology
+}
+
+// SetPrometheus sets the checked Prometheus passed to the client pods rendered from now on,
+// nil to pass none. Until it is set, Prometheus is discovered for every rendering.
+func (e *TemplateEngine) SetPrometheus(info *kubernetes.PrometheusInfo) {
+	e.prometheus = &prometheusEndpoint{info: info}
+}
+
+// EnableDebug writes the context and template text of every rendered manifest to dir
+func (e *TemplateEngine) EnableDebug(dir string) {
+	e.debug = templatedebug.NewDumper(dir)
+}
+
 // LoadTemplate loads and preprocesses a template file
 func (e *TemplateEngine) LoadTemplate(templatePath string) (*pongo2.Template, error) {
-	// Read from embedded filesystem
-	content, err := embeddedTemplates.ReadFile("templates/" + templatePath)
+	content, err := e.templateSource(templatePath)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read embedded template file %s: %w", templatePath, err)
+		return nil, err
 	}
 
 	// Preprocess Jinja2 syntax to Pongo2 compatible syntax
@@ -75,6 +99,37 @@ func (e *TemplateEngine) LoadTemplate(templatePath string) (*pongo2.Template, er
 
 // RenderTemplate renders a template with the given context
 func (e *TemplateEngine) RenderTemplate(templatePath string, context pongo2.Context) (string, error) {
+	rendered, err := e.renderTemplate(templatePath, context)
+
+	if e.debug != nil {
+		source, _ := e.templateSource(templatePath)
+		e.debug.Dump(templatePath, string(source), e.preprocessJinja2ToPongo2(string(source)), context, rendered, err)
+	}
+
+	return rendered, err
+}
+
+// templateSource reads a template from the templates directory, or the embedded one
+func (e *TemplateEngine) templateSource(templatePath string) ([]byte, error) {
+	if e.templatesDir != "" {
+		content, err := os.ReadFile(filepath.Join(e.templatesDir, templatePath))
+		if err == nil {
+			return content, nil
+		}
+		if !os.IsNotExist(err) {
+			return nil, fmt.Errorf("failed to read template file %s: %w", templatePath, err)
+		}
+	}
+
+	content, err := embeddedTemplates.ReadFile("templates/" + templatePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read embedded template file %s: %w", templatePath, err)
+	}
+	return content, nil
+}
+
+// renderTemplate loads and renders a template file
+func (e *TemplateEngine) renderTemplate(templatePath string, context pongo2.Context) (string, error) {
 	template, err := e.LoadTemplate(templatePath)
 	if err != nil {
 		return "", fmt.Errorf("failed to load template %s: %w", templatePath, err)
@@ -88,6 +143,23 @@ func (e *TemplateEngine) RenderTemplate(templatePath string, context pongo2.Cont
 	return rendered, nil
 }
 
+// renderInline renders a template defined in code, which is not preprocessed
+func (e *TemplateEngine) renderInline(name, source string, context pongo2.Context) (string, error) {
+	var rendered string
+	template, err := e.templateSet.FromString(source)
+	if err != nil {
+		err = fmt.Errorf("failed to compile %s template: %w", name, err)
+	} else {
+		rendered, err = template.Execute(context)
+	}
+
+	if e.debug != nil {
+		e.debug.Dump(name, source, source, context, rendered, err)
+	}
+
+	return rendered, err
+}
+
 // preprocessJinja2ToPongo2 converts Jinja2 specific syntax to Pongo2 compatible syntax
 func (e *TemplateEngine) preprocessJinja2ToPongo2(content string) string {
 	// Handle "is defined" checks
@@ -164,8 +236,10 @@ func (e *TemplateEngine) createBaseContext(cfg *config.Config) pongo2.Context {
 		"ceph_osd_cache_drop_pod_ip":  cfg.CephOSDCacheDropPodIP,
 		"ceph_cache_drop_svc_port":    cfg.CephCacheDropSvcPort,
 		"rook_ceph_drop_cache_pod_ip": cfg.RookCephDropCachePodIP,
-		"elasticsearch":               safeElasticsearch(cfg.Elasticsearch),
+		"job":                         cfg.Job,
+		"elasticsearch":               cfg.Elasticsearch.TemplateContext("fio"),
 		"prometheus":                  safePrometheus(nil), // Will be set by createContextWithPrometheus
+		"nodes":                       e.topology,
 	}
 }
 
@@ -176,8 +250,15 @@ func (e *TemplateEngine) createContextWithPrometheus(cfg *config.Config, k8sClie
 	// Handle Prometheus configuration (user-provided or auto-discovered)
 	var prometheusContext pongo2.Context
 
-	// Check if user provided Prometheus configuration directly
-	if cfg.Prometheus != nil && cfg.Prometheus.URL != "" {
+	// Use the endpoint the run checked, or check if user provided Prometheus configuration directly
+	if e.prometheus != nil {
+		if info := e.prometheus.info; info != nil {
+			prometheusContext = pongo2.Context{
+				"url":        info.URL,
+				"prom_token": info.Token,
+			}
+		}
+	} else if cfg.Prometheus != nil && cfg.Prometheus.URL != "" {
 		prometheusContext = pongo2.Context{
 			"url":        cfg.Prometheus.URL,
 			"prom_token": cfg.Prometheus.Token,
@@ -196,9 +277,9 @@ func (e *TemplateEngine) createContextWithPrometheus(cfg *config.Config, k8sClie
 	// If we have Prometheus context, add Elasticsearch configuration and set it
 	if prometheusContext != nil {
 		// Add Elasticsearch configuration if available
-		if cfg.Elasticsearch != nil && cfg.Elasticsearch.URL != "" {
-			prometheusContext["es_url"] = cfg.Elasticsearch.URL
-			prometheusContext["es_parallel"] = cfg.Elasticsearch.Parallel
+		if es := cfg.Elasticsearch.TemplateContext("fio"); len(es) > 0 {
+			prometheusContext["es_url"] = es["url"]
+			prometheusContext["es_parallel"] = es["parallel"]
 		}
 
 		templateContext["prometheus"] = prometheusContext
@@ -207,14 +288,43 @@ func (e *TemplateEngine) createContextWithPrometheus(cfg *config.Config, k8sClie
 	return templateContext
 }
 
+// recordChecksum remembers the content checksum of a rendered ConfigMap for the pods mounting it
+func (e *TemplateEngine) recordChecksum(role, manifest string) {
+	checksum, err := kubernetes.ConfigMapChecksum(manifest)
+	if err != nil {
+		log.Printf("Warning: pods will not verify the %s ConfigMap: %v", role, err)
+		delete(e.checksums, role)
+		return
+	}
+	e.checksums[role] = checksum
+}
+
+// configMounts returns the mounts of the given ConfigMap roles that have a recorded checksum
+func (e *TemplateEngine) configMounts(mounts map[string]string) []configMount {
+	var result []configMount
+	for path, role := range mounts {
+		if checksum, ok := e.checksums[role]; ok {
+			result = append(result, configMount{Path: path, Checksum: checksum})
+		}
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Path < result[j].Path })
+	return result
+}
+
 // RenderFIOConfigMap renders the FIO configuration map
 func (e *TemplateEngine) RenderFIOConfigMap(cfg *config.Config, fioConfig *FIOConfig) (string, error) {
 	context := e.createBaseContext(cfg)
 	context["workload_args"] = fioConfig
 	context["fio_path"] = fioConfig.GetFIOPath()
-	context["job_params"] = cfg.JobParams
+	context["data_volumes"] = fioConfig.DataVolumes()
+	context["multi_volume"] = fioConfig.VolumesPerServer > 1
+	context["cases"] = fioConfig.Matrix(cfg.JobParams)
 
-	return e.RenderTemplate("configmap.yml.j2", context)
+	manifest, err := e.RenderTemplate("configmap.yml.j2", context)
+	if err == nil {
+		e.recordChecksum("fio", manifest)
+	}
+	return manifest, err
 }
 
 // RenderFIOPrefillConfigMap renders the FIO prefill configuration map
@@ -222,16 +332,28 @@ func (e *TemplateEngine) RenderFIOPrefillConfigMap(cfg *config.Config, fioConfig
 	context := e.createBaseContext(cfg)
 	context["workload_args"] = fioConfig
 	context["fio_path"] = fioConfig.GetFIOPath()
+	context["data_volumes"] = fioConfig.DataVolumes()
+	context["multi_volume"] = fioConfig.VolumesPerServer > 1
 
-	return e.RenderTemplate("prefill-configmap.yml.j2", context)
+	manifest, err := e.RenderTemplate("prefill-configmap.yml.j2", context)
+	if err == nil {
+		e.recordChecksum("prefill", manifest)
+	}
+	return manifest, err
 }
 
 // RenderFIOServer renders a FIO server pod
 func (e *TemplateEngine) RenderFIOServer(cfg *config.Config, fioConfig *FIOConfig, serverNum int) (string, error) {
 	context := e.createBaseContext(cfg)
+	context["namespace"] = fioConfig.ServerNamespace(serverNum, cfg.Namespace)
 	context["workload_args"] = fioConfig
 	context["server_num"] = serverNum
 	context["fio_path"] = fioConfig.GetFIOPath()
+	context["data_volumes"] = fioConfig.DataVolumes()
+	context["extra_env"] = fioConfig.EnvFor("server")
+	context["extra_volumes"] = fioConfig.VolumesFor("server")
+	context["sidecars"] = fioConfig.SidecarsFor("server")
+	context["shared_path"] = SidecarSharedPath
 
 	return e.RenderTemplate("servers.yaml.j2", context)
 }
@@ -239,10 +361,13 @@ func (e *TemplateEngine) RenderFIOServer(cfg *config.Config, fioConfig *FIOConfi
 // RenderFIOServerVM renders a FIO server VM
 func (e *TemplateEngine) RenderFIOServerVM(cfg *config.Config, fioConfig *FIOConfig, serverNum int) (string, error) {
 	context := e.createBaseContext(cfg)
+	context["namespace"] = fioConfig.ServerNamespace(serverNum, cfg.Namespace)
 	context["workload_args"] = fioConfig
 	context["server_num"] = serverNum
 	context["resource_kind"] = "vm"
 	context["fio_path"] = fioConfig.GetFIOPath()
+	context["guest_tuning"] = fioConfig.GuestTuning.Commands()
+	context["guest_mount_flag"] = fioConfig.GuestTuning.MountFlag()
 
 	return e.RenderTemplate("server_vm.yml.j2", context)
 }
@@ -252,38 +377,73 @@ func (e *TemplateEngine) RenderFIOClient(cfg *config.Config, fioConfig *FIOConfi
 	context := e.createBaseContext(cfg)
 	context["workload_args"] = fioConfig
 	context["pod_details"] = podDetails
+	context["extra_env"] = fioConfig.EnvFor("client")
+	context["extra_volumes"] = fioConfig.VolumesFor("client")
+	context["sidecars"] = fioConfig.SidecarsFor("client")
+	context["shared_path"] = SidecarSharedPath
+	context["run_case"] = runCaseFunc(nil)
+	context["config_mounts"] = e.configMounts(map[string]string{"/tmp/fio": "fio", "/tmp/host": "hosts"})
+	context["sample_gate"] = sampleGate(cfg, fioConfig)
+	context["sample_hook_timeout"] = sampleHookTimeout(cfg, fioConfig)
+	context["cooldown_seconds"] = fioConfig.Cooldown.Seconds()
+	context["interval_logs"] = intervalLogs(cfg, fioConfig)
 
 	return e.RenderTemplate("client.yaml.j2", context)
 }
 
 // RenderFIOClientWithPrometheus renders the FIO client job with auto-discovered Prometheus
-func (e *TemplateEngine) RenderFIOClientWithPrometheus(cfg *config.Config, fioConfig *FIOConfig, podDetails map[string]string, k8sClient interface{}) (string, error) {
+// Combinations in skipCases, keyed by caseKey, are left out when a failed client is retried.
+func (e *TemplateEngine) RenderFIOClientWithPrometheus(cfg *config.Config, fioConfig *FIOConfig, podDetails map[string]string, k8sClient interface{}, skipCases map[string]bool) (string, error) {
 	context := e.createContextWithPrometheus(cfg, k8sClient)
 	context["workload_args"] = fioConfig
 	context["pod_details"] = podDetails
+	context["extra_env"] = fioConfig.EnvFor("client")
+	context["extra_volumes"] = fioConfig.VolumesFor("client")
+	context["sidecars"] = fioConfig.SidecarsFor("client")
+	context["shared_path"] = SidecarSharedPath
+	context["run_case"] = runCaseFunc(skipCases)
+	context["config_mounts"] = e.configMounts(map[string]string{"/tmp/fio": "fio", "/tmp/host": "hosts"})
+	context["sample_gate"] = sampleGate(cfg, fioConfig)
+	context["sample_hook_timeout"] = sampleHookTimeout(cfg, fioConfig)
+	context["cooldown_seconds"] = fioConfig.Cooldown.Seconds()
+	context["interval_logs"] = intervalLogs(cfg, fioConfig)
 
 	return e.RenderTemplate("client.yaml.j2", context)
 }
 
+// runCaseFunc returns the template function deciding whether the client runs a combination
+func runCaseFunc(skipCases map[string]bool) func(job, bs string, numjobs int) bool {
+	return func(job, bs string, numjobs int) bool {
+		return !skipCases[caseKey(job, bs, numjobs)]
+	}
+}
+
 // RenderFIOPrefillClient renders the FIO prefill client job
 func (e *TemplateEngine) RenderFIOPrefillClient(cfg *config.Config, fioConfig *FIOConfig) (string, error) {
 	context := e.createBaseContext(cfg)
 	context["workload_args"] = fioConfig
+	context["extra_env"] = fioConfig.EnvFor("client")
+	context["extra_volumes"] = fioConfig.VolumesFor("client")
+	context["sidecars"] = fioConfig.SidecarsFor("client")
+	context["shared_path"] = SidecarSharedPath
+	context["config_mounts"] = e.configMounts(map[string]string{"/tmp/fio": "prefill", "/tmp/host": "hosts"})
 
 	return e.RenderTemplate("prefill-client.yaml.j2", context)
 }
 
-// RenderFIOPVC renders a FIO PVC
-func (e *TemplateEngine) RenderFIOPVC(cfg *config.Config, fioConfig *FIOConfig, serverNum int) (string, error) {
+// RenderFIOPVC renders the PVC of a data volume of a FIO server
+func (e *TemplateEngine) RenderFIOPVC(cfg *config.Config, fioConfig *FIOConfig, serverNum int, volume DataVolume) (string, error) {
 	context := e.createBaseContext(cfg)
+	context["namespace"] = fioConfig.ServerNamespace(serverNum, cfg.Namespace)
 	context["workload_args"] = fioConfig
 	context["server_num"] = serverNum
+	context["volume"] = volume
 
 	pvcTemplate := `---
 apiVersion: v1
 kind: PersistentVolumeClaim
 metadata:
-  name: fio-claim-{{ server_num }}-{{ trunc_uuid }}
+  name: fio-claim-{{ server_num }}-{{ trunc_uuid }}{{ volume.Suffix }}
   namespace: '{{ namespace }}'
   labels:
     app: "fio-benchmark-{{ trunc_uuid }}"
@@ -299,12 +459,87 @@ spec:
   storageClassName: "{{ workload_args.StorageClass }}"
 {% endif %}`
 
-	template, err := e.templateSet.FromString(pvcTemplate)
-	if err != nil {
-		return "", fmt.Errorf("failed to compile PVC template: %w", err)
-	}
+	return e.renderInline("PVC", pvcTemplate, context)
+}
+
+// RenderFIOPDB renders a pod disruption budget covering the given number of FIO servers in
+// a namespace
+func (e *TemplateEngine) RenderFIOPDB(cfg *config.Config, fioConfig *FIOConfig, namespace string, servers int) (string, error) {
+	context := e.createBaseContext(cfg)
+	context["workload_args"] = fioConfig
+	context["namespace"] = namespace
+	context["servers"] = servers
+
+	pdbTemplate := `---
+apiVersion: policy/v1
+kind: PodDisruptionBudget
+metadata:
+  name: fio-servers-{{ trunc_uuid }}
+  namespace: '{{ namespace }}'
+  labels:
+    app: "fio-benchmark-{{ trunc_uuid }}"
+    benchmark-uuid: "{{ uuid }}"
+spec:
+  minAvailable: {{ servers }}
+  selector:
+    matchLabels:
+      app: "fio-benchmark-{{ trunc_uuid }}"`
+
+	return e.renderInline("PDB", pdbTemplate, context)
+}
+
+// RenderFIONodeCapture renders the capture DaemonSet for the nodes hosting the FIO servers
+func (e *TemplateEngine) RenderFIONodeCapture(cfg *config.Config, fioConfig *FIOConfig, nodes []string) (string, error) {
+	context := e.createBaseContext(cfg)
+	context["capture"] = fioConfig.NodeCapture
+	context["nodes"] = nodes
 
-	return template.Execute(context)
+	return e.RenderTemplate("node-capture.yaml.j2", context)
+}
+
+// RenderFIONodeCaptureScript renders the configmap holding the bpftrace programs
+func (e *TemplateEngine) RenderFIONodeCaptureScript(cfg *config.Config, fioConfig *FIOConfig) (string, error) {
+	context := e.createBaseContext(cfg)
+	context["script"] = indentLines(fioConfig.NodeCapture.BPFTraceScript, "    ")
+	context["biolatency"] = indentLines(biolatencyScript(fioConfig.NodeCapture.Interval), "    ")
+
+	template := `---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: fio-node-capture-{{ trunc_uuid }}
+  namespace: '{{ namespace }}'
+  labels:
+    app: "fio-node-capture-{{ trunc_uuid }}"
+    benchmark-uuid: "{{ uuid }}"
+data:
+  script.bt: |
+{{ script|safe }}
+  biolatency.bt: |
+{{ biolatency|safe }}`
+
+	return e.renderInline("node capture script", template, context)
+}
+
+// RenderFIOEncryptionCheck renders the inspector job writing the pattern to the device of a
+// server volume and reading it back from the device backing it
+func (e *TemplateEngine) RenderFIOEncryptionCheck(cfg *config.Config, fioConfig *FIOConfig, index int, target encryptionTarget) (string, error) {
+	context := e.createBaseContext(cfg)
+	context["check"] = fioConfig.EncryptionCheck
+	context["pattern"] = strings.TrimPrefix(strings.ToLower(fioConfig.EncryptionCheck.Pattern), "0x")
+	context["index"] = index
+	context["target"] = target
+
+	return e.RenderTemplate("encryption-check.yaml.j2", context)
+}
+
+// indentLines indents every line of text for embedding in a YAML block scalar
+func indentLines(text, indent string) string {
+	var lines []string
+	for _, line := range strings.Split(strings.TrimRight(text, "\n"), "\n") {
+		lines = append(lines, indent+line)
+	}
+	return strings.Join(lines, "\n")
 }
 
 // RenderHostsConfigMap renders the hosts configuration map
@@ -331,10 +566,26 @@ data:
 	context := e.createBaseContext(cfg)
 	context["hosts_data"] = hostsData
 
-	tmpl, err := e.templateSet.FromString(template)
-	if err != nil {
-		return "", fmt.Errorf("failed to compile hosts configmap template: %w", err)
+	manifest, err := e.renderInline("hosts configmap", template, context)
+	if err == nil {
+		e.recordChecksum("hosts", manifest)
 	}
+	return manifest, err
+}
+
+// RenderFIOHooksConfigMap renders the configmap the post_sample hooks release the client
+// through, empty until the first sample
+func (e *TemplateEngine) RenderFIOHooksConfigMap(cfg *config.Config) (string, error) {
+	template := `---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: fio-hooks-{{ trunc_uuid }}
+  namespace: '{{ namespace }}'
+  labels:
+    app: "fio-benchmark-{{ trunc_uuid }}"
+    benchmark-uuid: "{{ uuid }}"
+data: {}`
 
-	return tmpl.Execute(context)
+	return e.renderInline("hooks configmap", template, e.createBaseContext(cfg))
 }
@@ -0,0 +1,296 @@
+package fio
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/jtaleric/k8s-io/pkg/anonymize"
+	"github.com/jtaleric/k8s-io/pkg/prometheus"
+	"github.com/jtaleric/k8s-io/pkg/units"
+)
+
+// ThermalSample is the CPU frequency and throttling of the node of a server during a sample
+type ThermalSample struct {
+	Sample           string    `json:"sample"` // <uuid>_<job>_<bs>_<numjobs>-<sample>
+	Server           string    `json:"server"`
+	Node             string    `json:"node"`
+	Start            time.Time `json:"start"`
+	End              time.Time `json:"end"`
+	CoreThrottles    float64   `json:"coreThrottles"`              // Times a core was throttled
+	PackageThrottles float64   `json:"packageThrottles"`           // Times a package was throttled
+	FrequencyMHz     float64   `json:"frequencyMHz,omitempty"`     // Average over the cores and the sample
+	FrequencyPercent float64   `json:"frequencyPercent,omitempty"` // Of the maximum frequency of the cores
+	Throttled        bool      `json:"throttled"`
+	Found            bool      `json:"found"` // Whether node-exporter reported the node
+}
+
+// thermalThrottleQuery returns how often the cores or packages of every node were throttled
+// over the window. node-exporter series are labeled with the scraped instance, which
+// node_uname_info maps to the kernel nodename.
+func thermalThrottleQuery(level, window string) string {
+	return fmt.Sprintf("sum by (nodename) (increase(node_cpu_%s_throttles_total[%s]) * on (instance) group_left (nodename) node_uname_info)", level, window)
+}
+
+// thermalFrequencyQuery returns the average CPU frequency of every node over the window in Hz
+func thermalFrequencyQuery(window string) string {
+	return fmt.Sprintf("avg by (nodename) (avg_over_time(node_cpu_scaling_frequency_hertz[%s]) * on (instance) group_left (nodename) node_uname_info)", window)
+}
+
+// thermalMaxFrequencyQuery returns the average maximum CPU frequency of every node in Hz
+const thermalMaxFrequencyQuery = "avg by (nodename) (node_cpu_scaling_frequency_max_hertz * on (instance) group_left (nodename) node_uname_info)"
+
+// thermalNodeStats are the node-exporter metrics of a node over a window
+type thermalNodeStats struct {
+	core, pkg, freq, maxFreq float64
+}
+
+// thermalSamples returns the window of every sample on every server from the fio results.
+// fio 3.28 and later report when the job started; older versions only when the run ended.
+func thermalSamples(results []*FIOResult, placements map[string]ServerPlacement) []ThermalSample {
+	seen := make(map[string]bool)
+	var samples []ThermalSample
+	for _, result := range results {
+		for _, client := range result.ClientStats {
+			if client.JobName == "All clients" || client.JobRuntime == 0 {
+				continue
+			}
+			// Every volume of a server is a job of its own in the same window
+			key := result.ID + "/" + client.Hostname
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+
+			runtime := time.Duration(client.JobRuntime) * time.Millisecond
+			var start time.Time
+			if client.JobStart > 0 {
+				start = time.UnixMilli(client.JobStart).UTC()
+			} else if result.Timestamp > 0 {
+				start = time.Unix(result.Timestamp, 0).UTC().Add(-runtime)
+			} else {
+				continue
+			}
+			samples = append(samples, ThermalSample{
+				Sample: result.ID,
+				Server: client.Hostname,
+				Node:   placements[client.Hostname].Node,
+				Start:  start,
+				End:    start.Add(runtime),
+			})
+		}
+	}
+	return samples
+}
+
+// checkThermal queries the CPU throttling and frequency of the server nodes during every
+// sample from node-exporter and flags the samples that ran on a throttled node
+func (w *Workload) checkThermal(ctx context.Context) ([]ThermalSample, error) {
+	samples := thermalSamples(w.results, w.placements)
+	if len(samples) == 0 {
+		return nil, fmt.Errorf("the results have no sample windows")
+	}
+
+	promInfo, err := w.k8sClient.DiscoverPrometheusWithConfig(ctx, w.config.Prometheus)
+	if err != nil {
+		return nil, err
+	}
+	if !promInfo.Found {
+		return nil, fmt.Errorf("Prometheus not found")
+	}
+	prom := prometheus.NewClient(promInfo.URL, promInfo.Token)
+
+	maxFreq, err := queryByNodename(ctx, prom, thermalMaxFrequencyQuery, time.Time{})
+	if err != nil {
+		return nil, err
+	}
+
+	// The servers of a sample share its window, so every window is only queried once
+	windows := make(map[string]map[string]thermalNodeStats)
+	minPercent := w.fioConfig.ThermalCheck.MinFrequencyPercent
+	for i := range samples {
+		s := &samples[i]
+		// increase() needs two scrapes in the window
+		window := s.End.Sub(s.Start).Round(time.Second)
+		if window < time.Minute {
+			window = time.Minute
+		}
+		span := fmt.Sprintf("%ds", int(window.Seconds()))
+		key := span + "@" + s.End.Format(time.RFC3339)
+
+		stats, ok := windows[key]
+		if !ok {
+			stats = make(map[string]thermalNodeStats)
+			for _, q := range []struct {
+				query string
+				set   func(*thermalNodeStats, float64)
+			}{
+				{thermalThrottleQuery("core", span), func(n *thermalNodeStats, v float64) { n.core = v }},
+				{thermalThrottleQuery("package", span), func(n *thermalNodeStats, v float64) { n.pkg = v }},
+				{thermalFrequencyQuery(span), func(n *thermalNodeStats, v float64) { n.freq = v }},
+			} {
+				values, err := queryByNodename(ctx, prom, q.query, s.End)
+				if err != nil {
+					return nil, err
+				}
+				for node, v := range values {
+					n := stats[node]
+					q.set(&n, v)
+					stats[node] = n
+				}
+			}
+			for node, v := range maxFreq {
+				if n, ok := stats[node]; ok {
+					n.maxFreq = v
+					stats[node] = n
+				}
+			}
+			windows[key] = stats
+		}
+
+		n, found := lookupNodename(stats, s.Node)
+		if !found {
+			continue
+		}
+		s.Found = true
+		s.CoreThrottles, s.PackageThrottles = n.core, n.pkg
+		s.FrequencyMHz = n.freq / 1e6
+		if n.maxFreq > 0 {
+			s.FrequencyPercent = n.freq / n.maxFreq * 100
+		}
+		s.Throttled = s.CoreThrottles > 0 || s.PackageThrottles > 0 ||
+			(minPercent > 0 && s.FrequencyPercent > 0 && s.FrequencyPercent < minPercent)
+	}
+
+	found := 0
+	for _, s := range samples {
+		if s.Found {
+			found++
+		}
+	}
+	if found == 0 {
+		return nil, fmt.Errorf("node-exporter reports none of the server nodes, is node_uname_info scraped?")
+	}
+	if len(maxFreq) == 0 {
+		log.Printf("Warning: node-exporter reports no CPU frequencies, the thermal check only sees throttling")
+	}
+	return samples, nil
+}
+
+// queryByNodename runs a query by nodename at ts, or now for a zero ts
+func queryByNodename(ctx context.Context, prom *prometheus.Client, query string, ts time.Time) (map[string]float64, error) {
+	var samples []prometheus.Sample
+	var err error
+	if ts.IsZero() {
+		samples, err = prom.Query(ctx, query)
+	} else {
+		samples, err = prom.QueryAt(ctx, query, ts)
+	}
+	if err != nil {
+		return nil, err
+	}
+	values := make(map[string]float64, len(samples))
+	for _, s := range samples {
+		values[s.Labels["nodename"]] = s.Value
+	}
+	return values, nil
+}
+
+// lookupNodename returns the value of a Kubernetes node in values by kernel nodename, which
+// can be the node name or its fully qualified name
+func lookupNodename[T any](values map[string]T, node string) (T, bool) {
+	var zero T
+	if node == "" {
+		return zero, false
+	}
+	if v, ok := values[node]; ok {
+		return v, true
+	}
+	short := strings.SplitN(node, ".", 2)[0]
+	for name, v := range values {
+		if strings.SplitN(name, ".", 2)[0] == short {
+			return v, true
+		}
+	}
+	return zero, false
+}
+
+// thermalReport is the thermal.json artifact of a run
+type thermalReport struct {
+	MinFrequencyPercent float64         `json:"minFrequencyPercent,omitempty"`
+	Throttled           int             `json:"throttled"`
+	Samples             []ThermalSample `json:"samples"`
+}
+
+// reportThermal prints the samples that ran on throttled nodes, records how many did in the
+// run metadata and writes every sample to thermal.json
+func (w *Workload) reportThermal(samples []ThermalSample) error {
+	if a := w.anonymizer(); a != nil {
+		for i := range samples {
+			samples[i].Server = a.Pseudonym(anonymize.KindHost, samples[i].Server)
+			samples[i].Node = a.Pseudonym(anonymize.KindNode, samples[i].Node)
+		}
+	}
+	sort.Slice(samples, func(i, j int) bool {
+		if !samples[i].Start.Equal(samples[j].Start) {
+			return samples[i].Start.Before(samples[j].Start)
+		}
+		return samples[i].Server < samples[j].Server
+	})
+
+	var throttled []ThermalSample
+	missing := 0
+	for _, s := range samples {
+		if s.Throttled {
+			throttled = append(throttled, s)
+		}
+		if !s.Found {
+			missing++
+		}
+	}
+
+	fmt.Println("\n=== CPU Throttling ===")
+	if len(throttled) == 0 {
+		fmt.Printf("No throttling on the server nodes during %d sample(s)\n", len(samples))
+	} else {
+		tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "Sample\tServer\tNode\tCore Throttles\tPackage Throttles\tFrequency (MHz)\tOf Max")
+		for _, s := range throttled {
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%.0f%%\n", s.Sample, s.Server, s.Node,
+				units.Number(s.CoreThrottles, 0), units.Number(s.PackageThrottles, 0), units.Number(s.FrequencyMHz, 0), s.FrequencyPercent)
+		}
+		tw.Flush()
+		fmt.Println("\nThe results of these samples may be limited by the CPU of the node rather than the storage.")
+		log.Printf("Warning: %d of %d samples ran on throttled nodes", len(throttled), len(samples))
+	}
+	if missing > 0 {
+		fmt.Printf("node-exporter did not report the node of %d sample(s)\n", missing)
+	}
+
+	if w.config.Metadata == nil {
+		w.config.Metadata = make(map[string]string)
+	}
+	w.config.Metadata["thermalThrottling"] = fmt.Sprintf("%d/%d", len(throttled), len(samples))
+
+	dir := w.config.RunArtifactsDir()
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create artifacts directory: %w", err)
+	}
+	data, err := json.MarshalIndent(thermalReport{MinFrequencyPercent: w.fioConfig.ThermalCheck.MinFrequencyPercent,
+		Throttled: len(throttled), Samples: samples}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode thermal report: %w", err)
+	}
+	filename := filepath.Join(dir, "thermal.json")
+	if err := os.WriteFile(filename, data, 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", filename, err)
+	}
+	return nil
+}
@@ -0,0 +1,97 @@
+package fio
+
+import (
+	"fmt"
+
+	"github.com/jtaleric/k8s-io/pkg/config"
+)
+
+// ThresholdResult represents the pass/fail outcome of one job sample
+type ThresholdResult struct {
+	Job        string // job, block size and numjobs, e.g. "read-4KiB-1"
+	Sample     int
+	Runtime    int // seconds
+	Violations []string
+}
+
+// Passed reports whether the sample met all thresholds
+func (r ThresholdResult) Passed() bool {
+	return len(r.Violations) == 0
+}
+
+// EvaluateThresholds checks every job sample against the configured thresholds.
+// Throughput is summed across servers and latency is taken from the slowest server.
+func EvaluateThresholds(summaries []ResultSummary, thresholds []config.Threshold) []ThresholdResult {
+	type sampleKey struct {
+		job    string
+		sample int
+	}
+
+	totals := make(map[sampleKey]*ResultSummary)
+	var order []sampleKey
+
+	for _, summary := range summaries {
+		key := sampleKey{
+			job:    fmt.Sprintf("%s-%s-%d", summary.JobName, summary.BlockSize, summary.NumJobs),
+			sample: summary.Sample,
+		}
+
+		total, ok := totals[key]
+		if !ok {
+			total = &ResultSummary{JobName: summary.JobName}
+			totals[key] = total
+			order = append(order, key)
+		}
+
+		total.ReadIOPS += summary.ReadIOPS
+		total.WriteIOPS += summary.WriteIOPS
+		total.ReadBW += summary.ReadBW
+		total.WriteBW += summary.WriteBW
+		total.ReadLatP95 = max(total.ReadLatP95, summary.ReadLatP95)
+		total.WriteLatP95 = max(total.WriteLatP95, summary.WriteLatP95)
+		total.Runtime = max(total.Runtime, summary.Runtime)
+	}
+
+	var results []ThresholdResult
+	for _, key := range order {
+		total := totals[key]
+		result := ThresholdResult{Job: key.job, Sample: key.sample, Runtime: total.Runtime}
+
+		for _, t := range thresholds {
+			if t.JobnameMatch != "" && t.JobnameMatch != total.JobName {
+				continue
+			}
+			result.Violations = append(result.Violations, checkThreshold(total, t)...)
+		}
+
+		results = append(results, result)
+	}
+
+	return results
+}
+
+// checkThreshold returns the limits of a threshold violated by a job sample
+func checkThreshold(total *ResultSummary, t config.Threshold) []string {
+	var violations []string
+
+	if t.MinReadIOPS > 0 && total.ReadIOPS < t.MinReadIOPS {
+		violations = append(violations, fmt.Sprintf("read IOPS %.1f below minimum %.1f", total.ReadIOPS, t.MinReadIOPS))
+	}
+	if t.MinWriteIOPS > 0 && total.WriteIOPS < t.MinWriteIOPS {
+		violations = append(violations, fmt.Sprintf("write IOPS %.1f below minimum %.1f", total.WriteIOPS, t.MinWriteIOPS))
+	}
+	if t.MinReadBW > 0 && total.ReadBW < t.MinReadBW {
+		violations = append(violations, fmt.Sprintf("read BW %d KiB/s below minimum %d KiB/s", total.ReadBW, t.MinReadBW))
+	}
+	if t.MinWriteBW > 0 && total.WriteBW < t.MinWriteBW {
+		violations = append(violations, fmt.Sprintf("write BW %d KiB/s below minimum %d KiB/s", total.WriteBW, t.MinWriteBW))
+	}
+	if t.MaxReadLatP95 > 0 && total.ReadLatP95 > t.MaxReadLatP95 {
+		violations = append(violations, fmt.Sprintf("read P95 latency %.1f usec above maximum %.1f usec", total.ReadLatP95, t.MaxReadLatP95))
+	}
+	if t.MaxWriteLatP95 > 0 && total.WriteLatP95 > t.MaxWriteLatP95 {
+		violations = append(violations, fmt.Sprintf("write P95 latency %.1f usec above maximum %.1f usec", total.WriteLatP95, t.MaxWriteLatP95))
+	}
+
+	return violations
+}
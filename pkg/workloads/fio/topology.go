@@ -0,0 +1,118 @@
+package fio
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/jtaleric/k8s-io/pkg/kubernetes"
+)
+
+// nodeMarker starts the line every server pod logs at start with the number of NUMA nodes
+// and the block devices of its node, as seen in sysfs
+const nodeMarker = "FIO_NODE"
+
+// NodeTopology is what a run discovers about the node of one or more servers. Templates see
+// the nodes of the run as nodes, so custom job files can be rendered for the hardware.
+type NodeTopology struct {
+	Name         string   `json:"name"`
+	Zone         string   `json:"zone,omitempty"`
+	Region       string   `json:"region,omitempty"`
+	InstanceType string   `json:"instance_type,omitempty"`
+	NUMANodes    int      `json:"numa_nodes,omitempty"` // 0 when unknown, such as for VM servers
+	Devices      []string `json:"devices,omitempty"`    // Block devices without loop devices and RAM disks
+	Servers      []string `json:"servers"`              // Addresses of the servers on the node
+}
+
+// Topology is the nodes of the servers of a run, sorted by name
+type Topology []NodeTopology
+
+// Node returns the topology of a node, for templates such as
+// {{ nodes.Node("worker-1").NUMANodes }}; it is empty when no server runs on the node
+func (t Topology) Node(name string) NodeTopology {
+	for _, node := range t {
+		if node.Name == name {
+			return node
+		}
+	}
+	return NodeTopology{Name: name}
+}
+
+// nodeFacts are the FIO_NODE facts a server logs about its node
+type nodeFacts struct {
+	NUMANodes int
+	Devices   []string
+}
+
+// parseNodeFacts parses the fields after the FIO_NODE marker: the NUMA node count followed
+// by the block devices
+func parseNodeFacts(fields []string) nodeFacts {
+	var facts nodeFacts
+	if len(fields) == 0 {
+		return facts
+	}
+	facts.NUMANodes, _ = strconv.Atoi(fields[0])
+	for _, device := range fields[1:] {
+		if !strings.HasPrefix(device, "loop") && !strings.HasPrefix(device, "ram") && !strings.HasPrefix(device, "zram") {
+			facts.Devices = append(facts.Devices, device)
+		}
+	}
+	return facts
+}
+
+// discoverTopology returns the nodes of the placed servers with their zone, region and
+// instance type labels and the facts the servers logged about them. Nodes that cannot be
+// read only lose their labels.
+func (w *Workload) discoverTopology(ctx context.Context, facts map[string]nodeFacts) Topology {
+	byName := make(map[string]*NodeTopology)
+	forbidden := false
+	for ip, name := range w.podDetails {
+		node, ok := byName[name]
+		if !ok {
+			node = &NodeTopology{Name: name}
+			if f, ok := facts[name]; ok {
+				node.NUMANodes = f.NUMANodes
+				node.Devices = f.Devices
+			}
+			// Nodes are cluster-scoped, so one forbidden node means all of them are
+			if !forbidden {
+				n, err := w.k8sClient.GetNode(ctx, name)
+				switch {
+				case errors.Is(err, kubernetes.ErrForbidden):
+					log.Printf("Warning: not allowed to read nodes, the topology has no zones, regions or instance types: %v", err)
+					forbidden = true
+				case err != nil:
+					log.Printf("Warning: failed to read the labels of node %s: %v", name, err)
+				default:
+					node.Zone = n.Labels["topology.kubernetes.io/zone"]
+					node.Region = n.Labels["topology.kubernetes.io/region"]
+					node.InstanceType = n.Labels["node.kubernetes.io/instance-type"]
+				}
+			}
+			byName[name] = node
+		}
+		node.Servers = append(node.Servers, ip)
+	}
+
+	topology := make(Topology, 0, len(byName))
+	for _, node := range byName {
+		sort.Strings(node.Servers)
+		topology = append(topology, *node)
+	}
+	sort.Slice(topology, func(i, j int) bool { return topology[i].Name < topology[j].Name })
+	return topology
+}
+
+// setTopology discovers the nodes of the placed servers and makes them available to the
+// templates rendered from now on
+func (w *Workload) setTopology(ctx context.Context, facts map[string]nodeFacts) {
+	topology := w.discoverTopology(ctx, facts)
+	for _, node := range topology {
+		log.Printf("Server node %s: zone %q, %d NUMA nodes, devices [%s], servers %s",
+			node.Name, node.Zone, node.NUMANodes, strings.Join(node.Devices, " "), strings.Join(node.Servers, ", "))
+	}
+	w.templateEngine.SetTopology(topology)
+}
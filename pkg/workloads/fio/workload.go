@@ -4,11 +4,24 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"path/filepath"
+	"sort"
 	"strings"
 	"time"
 
+	"github.com/jtaleric/k8s-io/pkg/anonymize"
 	"github.com/jtaleric/k8s-io/pkg/config"
+	"github.com/jtaleric/k8s-io/pkg/grafana"
+	"github.com/jtaleric/k8s-io/pkg/history"
+	"github.com/jtaleric/k8s-io/pkg/junit"
 	"github.com/jtaleric/k8s-io/pkg/kubernetes"
+	"github.com/jtaleric/k8s-io/pkg/metrics"
+	"github.com/jtaleric/k8s-io/pkg/prometheus"
+	"github.com/jtaleric/k8s-io/pkg/report"
+	"github.com/jtaleric/k8s-io/pkg/results"
+	"github.com/jtaleric/k8s-io/pkg/status"
+	"github.com/jtaleric/k8s-io/pkg/timeline"
+	"github.com/jtaleric/k8s-io/pkg/units"
 )
 
 // Workload implements the FIO distributed benchmark workload
@@ -18,11 +31,24 @@ type Workload struct {
 	config         *config.Config
 	fioConfig      *FIOConfig
 	podDetails     map[string]string
+	summaries      []ResultSummary
+	results        []*FIOResult // Parsed fio output of every sample
+	latencyLogs    []LatencyLog // fio latency logs of every sample, captured for failover
+	timeline       timeline.Timeline
+	capturer       metrics.Capturer           // Started with the run, published with the timeline
+	clientLogs     []string                   // Logs of client attempts that failed and were retried
+	caseRetries    map[string]int             // Reruns per combination, see caseKey
+	placements     map[string]ServerPlacement // Node and devices of every server, by address
+	storageClass   string                     // Class the volumes are provisioned from, detected without storageclass
+	cooldownProm   *prometheus.Client         // Queries the server nodes between samples, see cooldown
 }
 
 // NewWorkload creates a new FIO workload
 func NewWorkload(k8sClient *kubernetes.Client, cfg *config.Config, fioConfig *FIOConfig) (*Workload, error) {
-	templateEngine := NewTemplateEngine("pkg/workloads/fio/templates")
+	templateEngine := NewTemplateEngine(fioConfig.TemplatesDir)
+	if cfg.DebugTemplates {
+		templateEngine.EnableDebug(filepath.Join(cfg.RunArtifactsDir(), "templates"))
+	}
 
 	return &Workload{
 		k8sClient:      k8sClient,
@@ -30,6 +56,7 @@ func NewWorkload(k8sClient *kubernetes.Client, cfg *config.Config, fioConfig *FI
 		config:         cfg,
 		fioConfig:      fioConfig,
 		podDetails:     make(map[string]string),
+		caseRetries:    make(map[string]int),
 	}, nil
 }
 
@@ -38,9 +65,53 @@ func (w *Workload) GetName() string {
 	return "fio"
 }
 
+// Summaries returns the result summaries captured by RunBenchmark
+func (w *Workload) Summaries() []ResultSummary {
+	return w.summaries
+}
+
+// Progress returns the running phase and the percentage of the expected prefill and
+// benchmark duration elapsed
+func (w *Workload) Progress(ctx context.Context) (string, float64) {
+	expected, _ := w.estimateDuration()
+	return w.timeline.Progress(expected)
+}
+
+// CollectResults returns the metrics and threshold violations of the samples captured by
+// RunBenchmark, throughput summed across servers
+func (w *Workload) CollectResults(ctx context.Context) (*results.Run, error) {
+	run := &results.Run{
+		UUID:     w.config.UUID,
+		Workload: w.GetName(),
+		Start:    w.runStart(),
+		Metrics:  SummarizeMetrics(w.summaries),
+	}
+	for _, result := range EvaluateThresholds(w.summaries, w.config.Thresholds) {
+		run.Samples++
+		for _, violation := range result.Violations {
+			run.Violations = append(run.Violations, fmt.Sprintf("%s sample %d: %s", result.Job, result.Sample, violation))
+		}
+	}
+	return run, nil
+}
+
 // Validate validates the workload configuration
 func (w *Workload) Validate() error {
-	return w.fioConfig.Validate()
+	if err := w.fioConfig.Validate(); err != nil {
+		return err
+	}
+	if w.config.Hooks != nil && len(w.config.Hooks.PostSample) > 0 && !w.fioConfig.SampleBarrier {
+		return fmt.Errorf("post_sample hooks require sample_barrier, without it all samples run in one snafu invocation")
+	}
+	if w.fioConfig.IndexRawResults && w.config.Elasticsearch == nil && len(w.config.Sinks) == 0 {
+		return fmt.Errorf("index_raw_results requires elasticsearch or sinks to be configured")
+	}
+	return w.checkTimeouts()
+}
+
+// Fingerprint returns the canonical hash of the effective FIO configuration
+func (w *Workload) Fingerprint() (string, error) {
+	return w.config.Fingerprint(w.fioConfig)
 }
 
 // GenerateManifests generates all Kubernetes manifests
@@ -63,14 +134,37 @@ func (w *Workload) GenerateManifests() (map[string]string, error) {
 		manifests["fio-prefill-configmap"] = prefillConfigMap
 	}
 
-	// Generate PVCs if storage class is defined
-	if w.fioConfig.StorageClass != "" {
+	// Generate the configmap releasing the client after the post_sample hooks and cool-down
+	if sampleGate(w.config, w.fioConfig) {
+		hooksConfigMap, err := w.templateEngine.RenderFIOHooksConfigMap(w.config)
+		if err != nil {
+			return nil, fmt.Errorf("failed to render hooks configmap: %w", err)
+		}
+		manifests["fio-hooks-configmap"] = hooksConfigMap
+	}
+
+	// Generate PVCs for volume type pvc
+	if w.fioConfig.UsesPVC() {
 		for i := 1; i <= w.fioConfig.Servers; i++ {
-			pvc, err := w.templateEngine.RenderFIOPVC(w.config, w.fioConfig, i)
+			for _, volume := range w.fioConfig.DataVolumes() {
+				pvc, err := w.templateEngine.RenderFIOPVC(w.config, w.fioConfig, i, volume)
+				if err != nil {
+					return nil, fmt.Errorf("failed to render PVC %d%s: %w", i, volume.Suffix, err)
+				}
+				manifests[fmt.Sprintf("pvc-%d%s", i, volume.Suffix)] = pvc
+			}
+		}
+	}
+
+	// Generate pod disruption budget if enabled
+	if w.fioConfig.PodDisruptionBudget {
+		counts := w.fioConfig.ServerCounts(w.config.Namespace)
+		for _, ns := range w.serverNamespaces() {
+			pdb, err := w.templateEngine.RenderFIOPDB(w.config, w.fioConfig, ns, counts[ns])
 			if err != nil {
-				return nil, fmt.Errorf("failed to render PVC %d: %w", i, err)
+				return nil, fmt.Errorf("failed to render pod disruption budget: %w", err)
 			}
-			manifests[fmt.Sprintf("pvc-%d", i)] = pvc
+			manifests[w.pdbName(ns)] = pdb
 		}
 	}
 
@@ -97,7 +191,30 @@ func (w *Workload) GenerateManifests() (map[string]string, error) {
 		mockPodDetails[fmt.Sprintf("10.0.0.%d", i)] = fmt.Sprintf("worker-%d", i)
 	}
 
-	client, err := w.templateEngine.RenderFIOClientWithPrometheus(w.config, w.fioConfig, mockPodDetails, w.k8sClient)
+	// Generate node capture for the mock nodes if enabled
+	if w.fioConfig.NodeCapture != nil {
+		var mockNodes []string
+		for _, node := range mockPodDetails {
+			mockNodes = append(mockNodes, node)
+		}
+		sort.Strings(mockNodes)
+
+		capture, err := w.templateEngine.RenderFIONodeCapture(w.config, w.fioConfig, mockNodes)
+		if err != nil {
+			return nil, fmt.Errorf("failed to render node capture: %w", err)
+		}
+		manifests["fio-node-capture"] = capture
+
+		if w.fioConfig.NodeCapture.UsesBPF() {
+			script, err := w.templateEngine.RenderFIONodeCaptureScript(w.config, w.fioConfig)
+			if err != nil {
+				return nil, fmt.Errorf("failed to render bpftrace scripts: %w", err)
+			}
+			manifests["fio-node-capture-script"] = script
+		}
+	}
+
+	client, err := w.templateEngine.RenderFIOClientWithPrometheus(w.config, w.fioConfig, mockPodDetails, w.k8sClient, nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to render client: %w", err)
 	}
@@ -118,15 +235,99 @@ func (w *Workload) GenerateManifests() (map[string]string, error) {
 // RunBenchmark executes the complete FIO distributed benchmark
 func (w *Workload) RunBenchmark(ctx context.Context) error {
 	log.Println("Starting FIO distributed benchmark execution...")
+	if planned, _ := w.estimateDuration(); planned > 0 {
+		log.Printf("Expected duration of prefill and benchmark: %s (ETA %s)", planned.Round(time.Second),
+			time.Now().Add(planned).Format(time.Kitchen))
+	}
+
+	if err := w.applyServiceMesh(ctx); err != nil {
+		return status.Errorf(status.ReasonPreflight, "failed to check service mesh: %w", err)
+	}
+
+	for _, ns := range w.Namespaces() {
+		if err := w.k8sClient.ResolveCollisions(ctx, ns, w.config.CollisionPolicy,
+			fmt.Sprintf("benchmark-uuid=%s", w.config.UUID),
+			fmt.Sprintf("app=fio-benchmark-%s", w.config.GetTruncatedUUID())); err != nil {
+			return status.Errorf(status.ReasonPreflight, "failed to resolve resource collisions: %w", err)
+		}
+	}
+
+	if w.fioConfig.VMInstancetype != "" || w.fioConfig.VMPreference != "" {
+		if err := w.applyInstancetype(ctx); err != nil {
+			return status.Errorf(status.ReasonPreflight, "failed to resolve the VM instancetype: %w", err)
+		}
+	}
+
+	if w.fioConfig.VolumeType == VolumeTypePVC || w.fioConfig.VolumeType == VolumeTypeEphemeral {
+		if err := w.resolveStorageClass(ctx); err != nil {
+			return status.Errorf(status.ReasonPreflight, "%w", err)
+		}
+	}
+
+	w.resolvePrometheus(ctx)
+
+	if w.fioConfig.Expansion != nil {
+		if err := w.checkExpansion(ctx); err != nil {
+			return status.Errorf(status.ReasonPreflight, "PVC expansion is not possible: %w", err)
+		}
+	}
+
+	if w.fioConfig.Profile != "" {
+		if w.config.Metadata == nil {
+			w.config.Metadata = make(map[string]string)
+		}
+		w.config.Metadata["fioProfile"] = w.fioConfig.Profile
+	}
+	if w.fioConfig.DutyCycle != nil {
+		if w.config.Metadata == nil {
+			w.config.Metadata = make(map[string]string)
+		}
+		w.config.Metadata["dutyCycle"] = w.fioConfig.DutyCycle.Active + " on/" + w.fioConfig.DutyCycle.Idle + " idle"
+	}
+
+	if err := w.fioConfig.GuestTuning.Record(w.config); err != nil {
+		log.Printf("Warning: %v", err)
+	}
+
+	capturer, err := metrics.New(w.config, w.k8sClient)
+	if err != nil {
+		return status.Errorf(status.ReasonConfig, "failed to create metrics capturer: %w", err)
+	}
+	if err := capturer.Start(ctx); err != nil {
+		log.Printf("Warning: failed to start metrics capture: %v", err)
+	} else {
+		w.capturer = capturer
+	}
+
+	defer w.publishTimeline(ctx)
 
 	// Phase 1: Deploy infrastructure
-	if err := w.deployInfrastructure(ctx); err != nil {
-		return fmt.Errorf("failed to deploy infrastructure: %w", err)
+	if err := w.timeline.Track("deploy", func() error { return w.deployInfrastructure(ctx) }); err != nil {
+		return status.Errorf(status.ReasonDeploy, "failed to deploy infrastructure: %w", err)
 	}
 
 	// Phase 2: Wait for servers to be ready
-	if err := w.waitForServers(ctx); err != nil {
-		return fmt.Errorf("failed to wait for servers: %w", err)
+	if err := w.timeline.Track("wait-servers", func() error { return w.waitForServers(ctx) }); err != nil {
+		return status.Errorf(status.ReasonDeploy, "failed to wait for servers: %w", err)
+	}
+
+	// Custom templates render the job files again, now that the nodes of the servers are known
+	if w.fioConfig.TemplatesDir != "" {
+		if err := w.applyJobFiles(ctx); err != nil {
+			return status.Errorf(status.ReasonDeploy, "failed to render the job files for the server nodes: %w", err)
+		}
+	}
+
+	// Capture node-level block statistics for the rest of the run
+	if w.fioConfig.NodeCapture != nil {
+		if err := w.startNodeCapture(ctx); err != nil {
+			log.Printf("Warning: node capture disabled: %v", err)
+		}
+		defer func() {
+			if err := w.collectNodeCapture(ctx); err != nil {
+				log.Printf("Warning: failed to collect node capture: %v", err)
+			}
+		}()
 	}
 
 	// Phase 3: Create hosts configmap
@@ -136,25 +337,97 @@ func (w *Workload) RunBenchmark(ctx context.Context) error {
 
 	// Phase 3: Wait for server check job to complete
 	timeout := time.Duration(300) * time.Second
-	if err := w.k8sClient.WaitForJobCompletion(ctx, "fio-check-"+w.config.GetTruncatedUUID(), w.config.Namespace, timeout); err != nil {
+	if err := w.timeline.Track("server-check", func() error {
+		return w.k8sClient.WaitForJobCompletion(ctx, "fio-check-"+w.config.GetTruncatedUUID(), w.config.Namespace, timeout)
+	}); err != nil {
 		return fmt.Errorf("server check job failed: %w", err)
 	}
 
 	// Phase 4: Run prefill if enabled
 	if w.fioConfig.Prefill {
-		if err := w.runPrefill(ctx); err != nil {
+		if err := w.timeline.Track("prefill", func() error { return w.runPrefill(ctx) }); err != nil {
 			return fmt.Errorf("failed to run prefill: %w", err)
 		}
 	}
 
-	// Phase 5: Run benchmark
-	if err := w.runBenchmarkClient(ctx); err != nil {
-		return fmt.Errorf("failed to run benchmark client: %w", err)
+	// Phase 5: Run benchmark and wait for completion
+	var waitExpansion, waitFailover func() []timeline.Phase
+	err = w.timeline.Track("benchmark", func() error {
+		if err := w.runBenchmarkClient(ctx); err != nil {
+			return fmt.Errorf("failed to run benchmark client: %w", err)
+		}
+		if w.fioConfig.Expansion != nil {
+			waitExpansion = w.startExpansion(ctx)
+		}
+		if w.fioConfig.Failover != nil {
+			waitFailover = w.startFailover(ctx)
+		}
+		if sampleGate(w.config, w.fioConfig) {
+			hookCtx, cancel := context.WithCancel(ctx)
+			defer cancel()
+			go w.followSampleHooks(hookCtx)
+		}
+		if err := w.waitForCompletion(ctx); err != nil {
+			return fmt.Errorf("failed to wait for completion: %w", err)
+		}
+		return nil
+	})
+	benchmark := w.timeline.Phases[len(w.timeline.Phases)-1]
+
+	// Record the expansion of every PVC and the failover as phases, even when the benchmark failed
+	if waitExpansion != nil {
+		phases := waitExpansion()
+		for _, phase := range phases {
+			w.timeline.Add(phase)
+		}
+		if err == nil {
+			if rerr := w.reportExpansion(phases); rerr != nil {
+				log.Printf("Warning: %v", rerr)
+			}
+		}
+	}
+	if waitFailover != nil {
+		phases := waitFailover()
+		for _, phase := range phases {
+			w.timeline.Add(phase)
+		}
+		if err == nil {
+			if rerr := w.reportFailover(phases); rerr != nil {
+				log.Printf("Warning: %v", rerr)
+			}
+		}
+	}
+	if err != nil {
+		return err
 	}
 
-	// Phase 6: Wait for completion
-	if err := w.waitForCompletion(ctx); err != nil {
-		return fmt.Errorf("failed to wait for completion: %w", err)
+	// Correlate the client latency with the OSD-side latency during the benchmark window
+	if w.fioConfig.CephLatency != nil {
+		if err := w.reportCephLatency(ctx, benchmark); err != nil {
+			log.Printf("Warning: failed to report Ceph OSD latency: %v", err)
+		}
+	}
+
+	// Flag the samples that ran on nodes whose CPUs were throttled
+	if w.fioConfig.ThermalCheck != nil {
+		if samples, err := w.checkThermal(ctx); err != nil {
+			log.Printf("Warning: failed to check CPU throttling: %v", err)
+		} else if err := w.reportThermal(samples); err != nil {
+			log.Printf("Warning: %v", err)
+		}
+	}
+
+	// Read the pattern back below the volumes once the benchmark no longer writes to them
+	if w.fioConfig.EncryptionCheck != nil {
+		var results []EncryptionResult
+		if err := w.timeline.Track("encryption-check", func() (err error) {
+			results, err = w.checkEncryption(ctx)
+			return err
+		}); err != nil {
+			log.Printf("Warning: failed to check encryption at rest: %v", err)
+		} else if err := w.reportEncryption(results); err != nil {
+			log.Printf("Warning: %v", err)
+		}
 	}
 
 	log.Println("Benchmark completed successfully!")
@@ -162,47 +435,162 @@ func (w *Workload) RunBenchmark(ctx context.Context) error {
 	return nil
 }
 
+// publishTimeline logs the benchmark phase windows, annotates them in Grafana and captures
+// the metrics profiles over them if configured
+func (w *Workload) publishTimeline(ctx context.Context) {
+	w.timeline.Finish()
+	w.timeline.Log()
+
+	if w.config.Grafana != nil {
+		if err := grafana.AnnotatePhases(ctx, w.config, w.timeline.Phases); err != nil {
+			log.Printf("Warning: failed to create Grafana annotations: %v", err)
+		} else {
+			log.Printf("Created Grafana annotations for %d phases", len(w.timeline.Phases))
+		}
+	}
+
+	if w.capturer != nil {
+		if err := metrics.Finish(ctx, w.capturer, w.config, w.timeline.Phases); err != nil {
+			log.Printf("Warning: failed to capture metrics: %v", err)
+		}
+	}
+}
+
+// applyServiceMesh adds the pod annotations for the service mesh injecting sidecars into the namespace
+func (w *Workload) applyServiceMesh(ctx context.Context) error {
+	// The pods share their annotations, so a mesh injecting into any namespace applies to all
+	mesh := kubernetes.MeshNone
+	for _, ns := range w.Namespaces() {
+		var err error
+		if mesh, err = w.k8sClient.DetectServiceMesh(ctx, ns); err != nil {
+			return err
+		}
+		if mesh != kubernetes.MeshNone {
+			break
+		}
+	}
+	if mesh == kubernetes.MeshNone {
+		return nil
+	}
+
+	enabled := w.config.ServiceMesh == "enabled"
+	if enabled {
+		log.Printf("Detected %s sidecar injection, holding FIO containers until the proxy is ready", mesh)
+	} else {
+		log.Printf("Detected %s sidecar injection, disabling it for FIO pods", mesh)
+	}
+
+	if w.fioConfig.Annotations == nil {
+		w.fioConfig.Annotations = make(map[string]string)
+	}
+	for k, v := range kubernetes.MeshPodAnnotations(mesh, enabled) {
+		// Annotations set in the configuration take precedence
+		if _, ok := w.fioConfig.Annotations[k]; !ok {
+			w.fioConfig.Annotations[k] = v
+		}
+	}
+
+	return nil
+}
+
+// applyInstancetype sizes the server VMs from vm_instancetype and vm_preference, since the
+// VMIs cannot reference them like a VirtualMachine does
+func (w *Workload) applyInstancetype(ctx context.Context) error {
+	res, err := w.k8sClient.ResolveVMResources(ctx, w.config.Namespace, w.fioConfig.VMInstancetype, w.fioConfig.VMPreference)
+	if err != nil {
+		return err
+	}
+	if res.CPUs > 0 {
+		w.fioConfig.VMCores = res.CPUs
+		w.fioConfig.VMMemory = res.Memory
+	}
+	if res.DiskBus != "" {
+		w.fioConfig.VMBus = res.DiskBus
+	}
+
+	log.Printf("Server VMs sized from the instancetype and preference: %d vCPUs, %s memory, %s disks",
+		w.fioConfig.VMCores, w.fioConfig.VMMemory, w.fioConfig.VMBus)
+	return nil
+}
+
+// resolveStorageClass sets the class the volumes are provisioned from and records it in the
+// run metadata. Without storageclass, the volumes get the default class of the cluster, so
+// a cluster without one fails the run instead of leaving the PVCs pending.
+func (w *Workload) resolveStorageClass(ctx context.Context) error {
+	w.storageClass = w.fioConfig.StorageClass
+	if w.storageClass == "" {
+		class, err := w.k8sClient.DefaultStorageClass(ctx)
+		if err != nil {
+			return fmt.Errorf("no storageclass is set and the default storage class cannot be detected, set storageclass or volume_type: %w", err)
+		}
+		w.storageClass = class
+		log.Printf("No storageclass is set, the volumes are provisioned from the default storage class %s", class)
+	}
+
+	if w.config.Metadata == nil {
+		w.config.Metadata = make(map[string]string)
+	}
+	w.config.Metadata["storageClass"] = w.storageClass
+	return nil
+}
+
 // deployInfrastructure deploys the initial infrastructure
 func (w *Workload) deployInfrastructure(ctx context.Context) error {
 	log.Println("Deploying infrastructure...")
 
 	// Deploy configmaps
-	configMap, err := w.templateEngine.RenderFIOConfigMap(w.config, w.fioConfig)
-	if err != nil {
-		return fmt.Errorf("failed to render configmap: %w", err)
-	}
-
-	if err := w.k8sClient.ApplyManifest(ctx, configMap, w.config.Namespace); err != nil {
-		return fmt.Errorf("failed to apply configmap: %w", err)
+	if err := w.applyJobFiles(ctx); err != nil {
+		return err
 	}
 
-	// Deploy prefill configmap if needed
-	if w.fioConfig.Prefill {
-		prefillConfigMap, err := w.templateEngine.RenderFIOPrefillConfigMap(w.config, w.fioConfig)
+	// Deploy the configmap releasing the client after the post_sample hooks and cool-down
+	if sampleGate(w.config, w.fioConfig) {
+		hooksConfigMap, err := w.templateEngine.RenderFIOHooksConfigMap(w.config)
 		if err != nil {
-			return fmt.Errorf("failed to render prefill configmap: %w", err)
+			return fmt.Errorf("failed to render hooks configmap: %w", err)
 		}
 
-		if err := w.k8sClient.ApplyManifest(ctx, prefillConfigMap, w.config.Namespace); err != nil {
-			return fmt.Errorf("failed to apply prefill configmap: %w", err)
+		if err := w.k8sClient.ApplyManifest(ctx, hooksConfigMap, w.config.Namespace); err != nil {
+			return fmt.Errorf("failed to apply hooks configmap: %w", err)
 		}
 	}
 
-	// Deploy PVCs if storage class is defined
-	if w.fioConfig.StorageClass != "" {
+	// Deploy PVCs for volume type pvc
+	if w.fioConfig.UsesPVC() {
 		for i := 1; i <= w.fioConfig.Servers; i++ {
-			pvc, err := w.templateEngine.RenderFIOPVC(w.config, w.fioConfig, i)
+			for _, volume := range w.fioConfig.DataVolumes() {
+				pvc, err := w.templateEngine.RenderFIOPVC(w.config, w.fioConfig, i, volume)
+				if err != nil {
+					return fmt.Errorf("failed to render PVC %d%s: %w", i, volume.Suffix, err)
+				}
+
+				if err := w.k8sClient.ApplyManifest(ctx, pvc, w.fioConfig.ServerNamespace(i, w.config.Namespace)); err != nil {
+					return fmt.Errorf("failed to apply PVC %d%s: %w", i, volume.Suffix, err)
+				}
+			}
+		}
+	}
+
+	// Deploy pod disruption budget before the servers so they are covered from the start
+	if w.fioConfig.PodDisruptionBudget {
+		counts := w.fioConfig.ServerCounts(w.config.Namespace)
+		for _, ns := range w.serverNamespaces() {
+			pdb, err := w.templateEngine.RenderFIOPDB(w.config, w.fioConfig, ns, counts[ns])
 			if err != nil {
-				return fmt.Errorf("failed to render PVC %d: %w", i, err)
+				return fmt.Errorf("failed to render pod disruption budget: %w", err)
 			}
 
-			if err := w.k8sClient.ApplyManifest(ctx, pvc, w.config.Namespace); err != nil {
-				return fmt.Errorf("failed to apply PVC %d: %w", i, err)
+			if err := w.k8sClient.ApplyManifest(ctx, pdb, ns); err != nil {
+				return fmt.Errorf("failed to apply pod disruption budget: %w", err)
 			}
 		}
 	}
 
 	// Deploy servers
+	if w.fioConfig.CPUPinning != nil {
+		log.Printf("Pinning FIO servers with: %s", w.fioConfig.PinCommand())
+	}
+
 	for i := 1; i <= w.fioConfig.Servers; i++ {
 		var server string
 		var err error
@@ -217,7 +605,7 @@ func (w *Workload) deployInfrastructure(ctx context.Context) error {
 			return fmt.Errorf("failed to render server %d: %w", i, err)
 		}
 
-		if err := w.k8sClient.ApplyManifest(ctx, server, w.config.Namespace); err != nil {
+		if err := w.k8sClient.ApplyManifest(ctx, server, w.fioConfig.ServerNamespace(i, w.config.Namespace)); err != nil {
 			return fmt.Errorf("failed to apply server %d: %w", i, err)
 		}
 	}
@@ -249,14 +637,25 @@ func (w *Workload) waitForServers(ctx context.Context) error {
 	labelSelector := fmt.Sprintf("app=fio-benchmark-%s", w.config.GetTruncatedUUID())
 	timeout := time.Duration(w.fioConfig.JobTimeout) * time.Second
 
-	if err := w.k8sClient.WaitForPodsReady(ctx, w.config.Namespace, labelSelector, w.fioConfig.Servers, timeout); err != nil {
-		return fmt.Errorf("failed to wait for servers to be ready: %w", err)
+	if w.fioConfig.Kind == "vm" {
+		return w.waitForVMServers(ctx, labelSelector, timeout)
 	}
 
-	// Get pod IPs and node names
-	podDetails, err := w.k8sClient.GetPodIPs(ctx, w.config.Namespace, labelSelector)
-	if err != nil {
-		return fmt.Errorf("failed to get pod IPs: %w", err)
+	// Get pod IPs and node names of the servers in every namespace
+	podDetails := make(map[string]string)
+	counts := w.fioConfig.ServerCounts(w.config.Namespace)
+	for _, ns := range w.serverNamespaces() {
+		if err := w.k8sClient.WaitForPodsReady(ctx, ns, labelSelector, counts[ns], timeout); err != nil {
+			return fmt.Errorf("failed to wait for servers in namespace %s to be ready: %w", ns, err)
+		}
+
+		details, err := w.k8sClient.GetPodIPs(ctx, ns, labelSelector)
+		if err != nil {
+			return fmt.Errorf("failed to get pod IPs: %w", err)
+		}
+		for ip, node := range details {
+			podDetails[ip] = node
+		}
 	}
 
 	if len(podDetails) != w.fioConfig.Servers {
@@ -266,6 +665,80 @@ func (w *Workload) waitForServers(ctx context.Context) error {
 	w.podDetails = podDetails
 
 	log.Printf("All %d servers are ready", len(podDetails))
+	w.placements = w.serverPlacements(ctx)
+	if err := w.recordVolumes(ctx); err != nil {
+		log.Printf("Warning: %v", err)
+	}
+	facts, err := w.verifyTargets(ctx)
+	if err != nil {
+		return err
+	}
+	w.setTopology(ctx, facts)
+	return nil
+}
+
+// waitForVMServers waits for the server VMIs to run and report their addresses, which the
+// client connects to instead of the virt-launcher pod IPs
+func (w *Workload) waitForVMServers(ctx context.Context, labelSelector string, timeout time.Duration) error {
+	var guests []kubernetes.GuestInfo
+	counts := w.fioConfig.ServerCounts(w.config.Namespace)
+	for _, ns := range w.serverNamespaces() {
+		nsGuests, err := w.k8sClient.WaitForVMIGuestInfo(ctx, ns, labelSelector, counts[ns], timeout)
+		if err != nil {
+			return fmt.Errorf("failed to wait for server VMs in namespace %s to be running: %w", ns, err)
+		}
+		guests = append(guests, nsGuests...)
+	}
+
+	podDetails := make(map[string]string)
+	for _, guest := range guests {
+		podDetails[guest.IPs[0]] = guest.Node
+		if guest.OS != "" {
+			log.Printf("Server VM %s on %s: %s, %s (kernel %s)", guest.Name, guest.Node, guest.IPs[0], guest.OS, guest.Kernel)
+		} else {
+			log.Printf("Server VM %s on %s: %s (no guest agent)", guest.Name, guest.Node, guest.IPs[0])
+		}
+	}
+
+	if len(podDetails) != w.fioConfig.Servers {
+		return fmt.Errorf("expected %d server VMs, got %d", w.fioConfig.Servers, len(podDetails))
+	}
+
+	w.podDetails = podDetails
+
+	log.Printf("All %d server VMs are running", len(podDetails))
+	w.placements = w.serverPlacements(ctx)
+	if err := w.recordVolumes(ctx); err != nil {
+		log.Printf("Warning: %v", err)
+	}
+	w.setTopology(ctx, nil)
+	return nil
+}
+
+// applyJobFiles renders and applies the configmaps with the job files of the benchmark and
+// the prefill
+func (w *Workload) applyJobFiles(ctx context.Context) error {
+	configMap, err := w.templateEngine.RenderFIOConfigMap(w.config, w.fioConfig)
+	if err != nil {
+		return fmt.Errorf("failed to render configmap: %w", err)
+	}
+
+	if err := w.k8sClient.ApplyManifest(ctx, configMap, w.config.Namespace); err != nil {
+		return fmt.Errorf("failed to apply configmap: %w", err)
+	}
+
+	// Deploy prefill configmap if needed
+	if w.fioConfig.Prefill {
+		prefillConfigMap, err := w.templateEngine.RenderFIOPrefillConfigMap(w.config, w.fioConfig)
+		if err != nil {
+			return fmt.Errorf("failed to render prefill configmap: %w", err)
+		}
+
+		if err := w.k8sClient.ApplyManifest(ctx, prefillConfigMap, w.config.Namespace); err != nil {
+			return fmt.Errorf("failed to apply prefill configmap: %w", err)
+		}
+	}
+
 	return nil
 }
 
@@ -287,12 +760,6 @@ func (w *Workload) createHostsConfigMap(ctx context.Context) error {
 		return fmt.Errorf("failed to apply hosts configmap: %w", err)
 	}
 
-	// Wait for FIO server port if VMs
-	if w.fioConfig.Kind == "vm" {
-		log.Println("Waiting for FIO server port 8765 to be ready on VMs...")
-		time.Sleep(30 * time.Second)
-	}
-
 	return nil
 }
 
@@ -312,9 +779,12 @@ func (w *Workload) runPrefill(ctx context.Context) error {
 	// Wait for prefill job to complete
 	jobName := fmt.Sprintf("fio-prefill-%s", w.config.GetTruncatedUUID())
 	timeout := time.Duration(w.fioConfig.JobTimeout) * time.Second
+	if planned, ok := w.prefillDuration(); ok {
+		w.setETA(ctx, jobName, planned)
+	}
 
 	if err := w.k8sClient.WaitForJobCompletion(ctx, jobName, w.config.Namespace, timeout); err != nil {
-		return fmt.Errorf("prefill job failed: %w", err)
+		return fmt.Errorf("prefill job failed: %w", w.explainClientFailure(ctx, jobName, err))
 	}
 
 	// Sleep after prefill if configured
@@ -332,7 +802,7 @@ func (w *Workload) runPrefill(ctx context.Context) error {
 func (w *Workload) runBenchmarkClient(ctx context.Context) error {
 	log.Println("Starting benchmark client...")
 
-	client, err := w.templateEngine.RenderFIOClientWithPrometheus(w.config, w.fioConfig, w.podDetails, w.k8sClient)
+	client, err := w.templateEngine.RenderFIOClientWithPrometheus(w.config, w.fioConfig, w.podDetails, w.k8sClient, nil)
 	if err != nil {
 		return fmt.Errorf("failed to render client: %w", err)
 	}
@@ -342,6 +812,9 @@ func (w *Workload) runBenchmarkClient(ctx context.Context) error {
 	}
 
 	log.Println("Benchmark client started")
+	if planned, _ := w.benchmarkDuration(); planned > 0 {
+		w.setETA(ctx, fmt.Sprintf("fio-client-%s", w.config.GetTruncatedUUID()), planned)
+	}
 
 	return nil
 }
@@ -353,8 +826,14 @@ func (w *Workload) waitForCompletion(ctx context.Context) error {
 	jobName := fmt.Sprintf("fio-client-%s", w.config.GetTruncatedUUID())
 	timeout := time.Duration(w.fioConfig.JobTimeout) * time.Second
 
-	if err := w.k8sClient.WaitForJobCompletion(ctx, jobName, w.config.Namespace, timeout); err != nil {
-		return fmt.Errorf("benchmark job failed: %w", err)
+	for attempt := 0; ; attempt++ {
+		err := w.k8sClient.WaitForJobCompletion(ctx, jobName, w.config.Namespace, timeout)
+		if err == nil {
+			break
+		}
+		if err := w.retryClient(ctx, jobName, attempt, err); err != nil {
+			return fmt.Errorf("benchmark job failed: %w", w.explainClientFailure(ctx, jobName, err))
+		}
 	}
 
 	// Capture and parse results
@@ -364,6 +843,11 @@ func (w *Workload) waitForCompletion(ctx context.Context) error {
 		// Don't fail the benchmark if result capture fails
 	}
 
+	// Fail the run if the results violate the configured thresholds
+	if err := w.checkThresholds(); err != nil {
+		return err
+	}
+
 	return nil
 }
 
@@ -374,6 +858,8 @@ func (w *Workload) captureResults(ctx context.Context, jobName string) error {
 	if err != nil {
 		return fmt.Errorf("failed to get job logs: %w", err)
 	}
+	// Prepend the results of the combinations that completed in failed attempts
+	logs = strings.Join(w.clientLogs, "") + logs
 
 	// Generate a test ID for this run
 	testID := fmt.Sprintf("%s_%s_%s_%d",
@@ -383,26 +869,270 @@ func (w *Workload) captureResults(ctx context.Context, jobName string) error {
 		w.fioConfig.NumJobs[0], // Use first numjobs value
 	)
 
+	fingerprint, err := w.Fingerprint()
+	if err != nil {
+		return fmt.Errorf("failed to compute config fingerprint: %w", err)
+	}
+	log.Printf("Config fingerprint: %s", fingerprint)
+
 	// Parse and display results
-	CaptureFIOResults(logs, testID)
+	results := CaptureFIOResultsWithOptions(logs, testID, CaptureOptions{
+		ExportCSV:   true,
+		CSV:         w.config.CSVFormat(),
+		ExportXLSX:  w.config.Export != nil && w.config.Export.XLSX,
+		Fingerprint: fingerprint,
+		Retries:     w.caseRetries,
+		Cases:       w.fioConfig.Matrix(w.config.JobParams),
+		Units:       units.Mode(w.config.Units),
+		Tags:        w.config.TagString(),
+		RunStart:    w.runStart(),
+		Anonymizer:  w.anonymizer(),
+		Placements:  w.placements,
+	})
+
+	// A client that completed without results usually hit a fio error
+	if len(results) == 0 {
+		for _, clientErr := range DetectClientErrors(logs) {
+			log.Printf("Warning: %s", clientErr)
+		}
+	}
+
+	// Keep the raw fio JSON of every sample for later analysis
+	if err := w.preserveRawResults(ctx, results); err != nil {
+		log.Printf("Warning: %v", err)
+	}
+
+	// Cross-check the client aggregate against the per-server results
+	for _, issue := range ValidateAggregation(results, w.fioConfig.Servers) {
+		log.Printf("Warning: %s", issue)
+	}
 
+	w.results = results
+	if w.fioConfig.Failover != nil {
+		w.latencyLogs = ParseLatencyLogs(logs)
+	}
+	w.summaries = ExtractResultSummaries(results, testID)
+	annotateRetries(w.summaries, w.caseRetries)
+	correlateCases(w.summaries, w.fioConfig.Matrix(w.config.JobParams))
+	placeSummaries(w.summaries, w.placements)
+	anonymizeHosts(w.summaries, w.anonymizer())
+	for i := range w.summaries {
+		w.summaries[i].Tags = w.config.TagString()
+	}
+
+	// Write the results and interval logs for pandas and DuckDB, and the notebook plotting them
+	if w.config.WritesParquet() && len(results) > 0 {
+		if err := w.exportParquet(results, ParseIntervalLogs(logs), fingerprint); err != nil {
+			log.Printf("Warning: %v", err)
+		} else if w.config.Export.Notebook {
+			if err := WriteNotebook(w.config.RunArtifactsDir(), w.config.UUID, w.runStart()); err != nil {
+				log.Printf("Warning: %v", err)
+			}
+		}
+	}
+
+	// Sum the servers up for the whole cluster and each node
+	if err := w.publishClusterThroughput(ctx); err != nil {
+		log.Printf("Warning: %v", err)
+	}
+
+	// Record the sample windows so they are logged and annotated with the phases
+	for _, sample := range ParseSampleWindows(logs) {
+		w.timeline.Add(sample)
+	}
+	for _, drop := range ParseCacheDrops(logs) {
+		timeline.RecordAt(drop.Time, drop.Kind, drop.Subject, drop.Message)
+	}
+
+	// Compare against the rolling baseline of previous runs
+	if w.config.History != nil && len(results) > 0 {
+		if err := w.recordHistory(ctx, fingerprint, w.summaries); err != nil {
+			log.Printf("Warning: failed to record run history: %v", err)
+		}
+	}
+
+	return nil
+}
+
+// checkThresholds checks the captured results against the configured thresholds
+func (w *Workload) checkThresholds() error {
+	results := EvaluateThresholds(w.summaries, w.config.Thresholds)
+
+	if w.config.JUnitFile != "" {
+		if err := w.writeJUnit(results); err != nil {
+			log.Printf("Warning: %v", err)
+		} else {
+			log.Printf("JUnit report written to: %s", w.config.JUnitFile)
+		}
+	}
+
+	if len(w.config.Thresholds) == 0 {
+		return nil
+	}
+
+	if len(w.summaries) == 0 {
+		return status.Errorf(status.ReasonThreshold, "no results available to check thresholds against")
+	}
+
+	failed := 0
+	for _, result := range results {
+		if result.Passed() {
+			log.Printf("Threshold check passed: %s sample %d", result.Job, result.Sample)
+			continue
+		}
+
+		failed++
+		for _, violation := range result.Violations {
+			log.Printf("Threshold check failed: %s sample %d: %s", result.Job, result.Sample, violation)
+		}
+	}
+
+	if failed > 0 {
+		return status.Errorf(status.ReasonThreshold, "%d job sample(s) violated thresholds", failed)
+	}
+
+	return nil
+}
+
+// writeJUnit writes a JUnit report with one test case per job sample
+func (w *Workload) writeJUnit(results []ThresholdResult) error {
+	suite := junit.TestSuite{Name: fmt.Sprintf("fio-%s", w.config.UUID)}
+
+	if len(results) == 0 {
+		suite.AddTestCase(junit.TestCase{
+			Name:      "results",
+			ClassName: "fio",
+			Failure:   &junit.Failure{Message: "no FIO results captured", Type: "missing_results"},
+		})
+	}
+
+	for _, result := range results {
+		tc := junit.TestCase{
+			Name:      fmt.Sprintf("%s sample %d", result.Job, result.Sample),
+			ClassName: "fio." + result.Job,
+			Time:      float64(result.Runtime),
+		}
+		if !result.Passed() {
+			tc.Failure = &junit.Failure{
+				Message: fmt.Sprintf("%d threshold(s) violated", len(result.Violations)),
+				Type:    string(status.ReasonThreshold),
+				Text:    strings.Join(result.Violations, "\n"),
+			}
+		}
+		suite.AddTestCase(tc)
+	}
+
+	return junit.Write(w.config.JUnitFile, suite)
+}
+
+// runStart returns the start of the run in UTC, or now when the run has no clock
+func (w *Workload) runStart() time.Time {
+	if w.config.Clock == nil {
+		return time.Now().UTC()
+	}
+	return w.config.Clock.Start
+}
+
+// anonymizer returns the anonymizer of the reports when the run is anonymized, nil otherwise
+func (w *Workload) anonymizer() *anonymize.Anonymizer {
+	if !w.config.Anonymize {
+		return nil
+	}
+	return anonymize.New(w.config.UUID)
+}
+
+// recordHistory checks the run against the configured history and records it
+func (w *Workload) recordHistory(ctx context.Context, fingerprint string, summaries []ResultSummary) error {
+	record := history.Record{
+		UUID:       w.config.UUID,
+		Workload:   w.GetName(),
+		ConfigHash: fingerprint,
+		Timestamp:  w.runStart(),
+		Metrics:    SummarizeMetrics(summaries),
+		Tags:       w.config.Tags,
+		Owner:      w.config.Owner,
+		Namespace:  w.config.Namespace,
+	}
+
+	// The baseline must be looked up before the run itself is recorded
+	if w.config.PRComment != nil {
+		if err := w.postPRComment(ctx, record); err != nil {
+			log.Printf("Warning: failed to post PR comment: %v", err)
+		}
+	}
+
+	_, err := history.Check(ctx, w.config.History, record)
+	return err
+}
+
+// postPRComment posts a comparison of the run against the latest comparable run
+func (w *Workload) postPRComment(ctx context.Context, record history.Record) error {
+	records, err := history.NewStore(w.config.History.Path).Load()
+	if err != nil {
+		return err
+	}
+
+	var baseline *history.Record
+	if previous := history.Baseline(records, record, 1); len(previous) > 0 {
+		baseline = &previous[0]
+	}
+
+	if err := report.PostComment(ctx, w.config.PRComment, report.ComparisonMarkdown(baseline, record)); err != nil {
+		return err
+	}
+
+	log.Printf("Posted results comment to %s %s#%d", w.config.PRComment.Provider, w.config.PRComment.Repository, w.config.PRComment.Number)
 	return nil
 }
 
+// Namespaces returns the run namespace and the server namespaces
+func (w *Workload) Namespaces() []string {
+	namespaces := []string{w.config.Namespace}
+	for _, ns := range w.fioConfig.ServerNamespaces {
+		if ns != w.config.Namespace {
+			namespaces = append(namespaces, ns)
+		}
+	}
+	return namespaces
+}
+
+// serverNamespaces returns the namespaces the servers run in
+func (w *Workload) serverNamespaces() []string {
+	if len(w.fioConfig.ServerNamespaces) == 0 {
+		return []string{w.config.Namespace}
+	}
+	return w.fioConfig.ServerNamespaces
+}
+
+// pdbName returns the manifest name of the pod disruption budget of a server namespace
+func (w *Workload) pdbName(namespace string) string {
+	if len(w.fioConfig.ServerNamespaces) == 0 {
+		return "fio-pdb"
+	}
+	return "fio-pdb-" + namespace
+}
+
 // Cleanup removes all resources created by the benchmark
 func (w *Workload) Cleanup(ctx context.Context) error {
 	log.Println("Cleaning up benchmark resources...")
 
-	labelSelector := fmt.Sprintf("benchmark-uuid=%s", w.config.UUID)
+	for _, ns := range w.Namespaces() {
+		labelSelector := fmt.Sprintf("benchmark-uuid=%s", w.config.UUID)
+
+		if err := w.k8sClient.CleanupResources(ctx, ns, labelSelector); err != nil {
+			return fmt.Errorf("failed to cleanup resources in namespace %s: %w", ns, err)
+		}
 
-	if err := w.k8sClient.CleanupResources(ctx, w.config.Namespace, labelSelector); err != nil {
-		return fmt.Errorf("failed to cleanup resources: %w", err)
+		// Also cleanup by truncated UUID selector
+		labelSelector = fmt.Sprintf("app=fio-benchmark-%s", w.config.GetTruncatedUUID())
+		if err := w.k8sClient.CleanupResources(ctx, ns, labelSelector); err != nil {
+			log.Printf("Warning: failed to cleanup resources with label %s in namespace %s: %v", labelSelector, ns, err)
+		}
 	}
 
-	// Also cleanup by truncated UUID selector
-	labelSelector = fmt.Sprintf("app=fio-benchmark-%s", w.config.GetTruncatedUUID())
-	if err := w.k8sClient.CleanupResources(ctx, w.config.Namespace, labelSelector); err != nil {
-		log.Printf("Warning: failed to cleanup resources with label %s: %v", labelSelector, err)
+	// The service account and token for Prometheus live in its namespace
+	if err := w.k8sClient.CleanupAuxiliary(ctx, w.config.UUID); err != nil {
+		log.Printf("Warning: failed to cleanup the auxiliary resources of the run: %v", err)
 	}
 
 	log.Println("Cleanup completed")
@@ -0,0 +1,203 @@
+package fio
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/jtaleric/k8s-io/pkg/config"
+	"github.com/jtaleric/k8s-io/pkg/kubernetes/kubetest"
+	"github.com/jtaleric/k8s-io/pkg/timeline"
+)
+
+const testConfig = `namespace: fio-test
+uuid: 0123abcd-0000-4000-8000-000000000000
+workload:
+  name: fio
+  args:
+    kind: pod
+    servers: 2
+    samples: 1
+    jobs: [randread]
+    bs: [4KiB]
+    numjobs: [1]
+    filesize: 1G
+    read_runtime: 5
+    read_ramp_time: 0
+    prefill: false
+    post_prefill_sleep: 0
+    target:
+      type: emptydir
+`
+
+// newTestWorkload returns the workload of a config on a fake cluster, with its namespaces
+// created like main does. The run writes its events and artifacts to the returned
+// directory, which is also the working directory of the test.
+func newTestWorkload(t *testing.T, cluster *kubetest.Cluster, configYAML string) (*Workload, string) {
+	t.Helper()
+	dir := t.TempDir()
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+
+	cfg, err := config.Parse([]byte(configYAML+"artifacts_dir: "+dir+"\n"), "test.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	args, err := yaml.Marshal(cfg.Workload.Args)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var fioConfig FIOConfig
+	if err := yaml.Unmarshal(args, &fioConfig); err != nil {
+		t.Fatal(err)
+	}
+	fioConfig.SetDefaults()
+	if err := fioConfig.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	w, err := NewWorkload(cluster.Client, cfg, &fioConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	labels, annotations := cfg.NamespaceMetadata()
+	for _, ns := range w.Namespaces() {
+		if err := cluster.Client.CreateNamespace(context.Background(), ns, labels, annotations); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := timeline.OpenEvents(dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(timeline.CloseEvents)
+	return w, dir
+}
+
+// clientLog returns the log of a client that ran a randread sample on every server
+func clientLog(t *testing.T, sample string, servers []string) string {
+	t.Helper()
+	result := FIOResult{FIOVersion: "fio-3.35", GlobalOptions: map[string]interface{}{"bs": "4KiB", "rw": "randread", "numjobs": "1", "iodepth": "1"}}
+	read := IOStats{IOPS: 1000, BW: 4000, IOBytes: 20480000, TotalIOs: 5000, Runtime: 5000}
+	for _, server := range servers {
+		result.ClientStats = append(result.ClientStats, ClientStats{JobName: "randread", Hostname: server, JobRuntime: 5000, Read: read})
+	}
+	all := read
+	all.IOPS, all.BW, all.IOBytes = read.IOPS*float64(len(servers)), read.BW*len(servers), read.IOBytes*int64(len(servers))
+	result.ClientStats = append(result.ClientStats, ClientStats{JobName: "All clients", JobRuntime: 5000, Read: all})
+
+	data, err := json.Marshal(result)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return fmt.Sprintf("FIO Result for %s\n%s\nEND FIO Result for %s\n", sample, data, sample)
+}
+
+// waits returns the subjects of the waits of a run, with the kind of wait
+func waits(t *testing.T, dir string) []string {
+	t.Helper()
+	events, err := timeline.ReadEvents(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var waits []string
+	for _, e := range events {
+		if e.Kind == timeline.EventWait {
+			waits = append(waits, strings.Fields(e.Message)[0]+" "+e.Subject)
+		}
+	}
+	return waits
+}
+
+func TestRunBenchmark(t *testing.T) {
+	cluster := kubetest.New(
+		&corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "worker-0"}},
+		&corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "worker-1"}},
+	)
+	w, dir := newTestWorkload(t, cluster, testConfig)
+	cluster.SetLogs("fio-server", "FIO_TARGET /tmp/fio /tmp/fio directory\n")
+	cluster.SetLogs("fio-client", clientLog(t, w.config.UUID+"_randread_4KiB_1-1", []string{"10.128.0.1", "10.128.0.2"}))
+
+	if err := w.RunBenchmark(context.Background()); err != nil {
+		t.Fatalf("RunBenchmark failed: %v", err)
+	}
+
+	wantApplied := []string{
+		"/Namespace/fio-test",
+		"fio-test/ConfigMap/fio-test-0123abcd",
+		"fio-test/Pod/fio-server-1-benchmark-0123abcd",
+		"fio-test/Pod/fio-server-2-benchmark-0123abcd",
+		"fio-test/Job/fio-check-0123abcd",
+		"fio-test/ConfigMap/fio-hosts-0123abcd",
+		"fio-test/Job/fio-client-0123abcd",
+	}
+	if got := cluster.Applied(); !reflect.DeepEqual(got, wantApplied) {
+		t.Errorf("applied %q, want %q", got, wantApplied)
+	}
+
+	wantWaits := []string{
+		"wait-pods fio-test/app=fio-benchmark-0123abcd (2 pods)",
+		"wait-job fio-test/fio-check-0123abcd",
+		"wait-job fio-test/fio-client-0123abcd",
+	}
+	if got := waits(t, dir); !reflect.DeepEqual(got, wantWaits) {
+		t.Errorf("waits %q, want %q", got, wantWaits)
+	}
+
+	// The client reaches the servers by the IPs they got once running
+	hosts, err := cluster.Get("configmaps", "fio-test", "fio-hosts-0123abcd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := json.Marshal(hosts.Object["data"])
+	for _, ip := range []string{"10.128.0.1", "10.128.0.2"} {
+		if !strings.Contains(string(data), ip) {
+			t.Errorf("hosts configmap %s does not list server %s", data, ip)
+		}
+	}
+
+	if got := len(w.Summaries()); got != 2 {
+		t.Fatalf("captured %d summaries, want one per server", got)
+	}
+	for _, summary := range w.Summaries() {
+		if summary.ReadIOPS != 1000 || summary.Node == "" {
+			t.Errorf("summary of %s has %.0f read IOPS on node %q, want 1000 on a worker", summary.Hostname, summary.ReadIOPS, summary.Node)
+		}
+	}
+}
+
+func TestRunBenchmarkPrefill(t *testing.T) {
+	cluster := kubetest.New()
+	w, dir := newTestWorkload(t, cluster, strings.Replace(testConfig, "prefill: false", "prefill: true", 1))
+	cluster.SetLogs("fio-server", "FIO_TARGET /tmp/fio /tmp/fio directory\n")
+	cluster.SetLogs("fio-client", clientLog(t, w.config.UUID+"_randread_4KiB_1-1", []string{"10.128.0.1", "10.128.0.2"}))
+
+	if err := w.RunBenchmark(context.Background()); err != nil {
+		t.Fatalf("RunBenchmark failed: %v", err)
+	}
+
+	// The prefill job runs to completion before the client starts
+	wantWaits := []string{
+		"wait-pods fio-test/app=fio-benchmark-0123abcd (2 pods)",
+		"wait-job fio-test/fio-check-0123abcd",
+		"wait-job fio-test/fio-prefill-0123abcd",
+		"wait-job fio-test/fio-client-0123abcd",
+	}
+	if got := waits(t, dir); !reflect.DeepEqual(got, wantWaits) {
+		t.Errorf("waits %q, want %q", got, wantWaits)
+	}
+}
@@ -0,0 +1,217 @@
+package fio
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// xlsxCell is a cell of a worksheet, a number or text
+type xlsxCell struct {
+	value  string
+	number bool
+}
+
+// xlsxSheet is a worksheet whose first row is the header
+type xlsxSheet struct {
+	name string
+	rows [][]xlsxCell
+}
+
+// xlsxIntColumns are the columns of whole numbers in the CSV schema, stored as numbers like
+// csvFloatColumns
+var xlsxIntColumns = map[string]bool{
+	"Sample": true, "NumJobs": true, "IODepth": true, "Read BW (KiB/s)": true, "Write BW (KiB/s)": true,
+	"Runtime (s)": true, "Compress (%)": true, "Dedupe (%)": true, "Retries": true, "Schema Version": true,
+}
+
+// xlsxSummaryColumns are the columns of the summary sheet
+var xlsxSummaryColumns = []string{
+	"Job", "Samples", "Read IOPS", "Read BW (KiB/s)", "Write IOPS", "Write BW (KiB/s)",
+	"Read Lat P95 (usec)", "Write Lat P95 (usec)",
+}
+
+// ExportResultsToXLSX exports FIO results to an Excel workbook with a summary sheet and a
+// sheet per sample. The summary has the throughput of every job summed across the servers
+// and averaged across the samples, and its mean P95 latencies, like the history metrics;
+// the sample sheets have the rows and columns of the CSV export. Values are stored as
+// numbers, so Excel shows them with the decimal separator of the reader's locale.
+func ExportResultsToXLSX(summaries []ResultSummary, filename string) error {
+	if len(summaries) == 0 {
+		return fmt.Errorf("no results to export")
+	}
+
+	sheets := []xlsxSheet{xlsxSummarySheet(summaries)}
+	bySample := make(map[int][]ResultSummary)
+	for _, summary := range summaries {
+		bySample[summary.Sample] = append(bySample[summary.Sample], summary)
+	}
+	samples := make([]int, 0, len(bySample))
+	for sample := range bySample {
+		samples = append(samples, sample)
+	}
+	sort.Ints(samples)
+
+	columns := csvSchemas[CSVSchemaVersion]
+	for _, sample := range samples {
+		sheet := xlsxSheet{name: fmt.Sprintf("Sample %d", sample), rows: [][]xlsxCell{xlsxHeader(columns)}}
+		for _, summary := range bySample[sample] {
+			row := summaryToCSVRow(summary)
+			cells := make([]xlsxCell, len(row))
+			for i, value := range row {
+				cells[i] = xlsxCell{value: value, number: csvFloatColumns[columns[i]] || xlsxIntColumns[columns[i]]}
+			}
+			sheet.rows = append(sheet.rows, cells)
+		}
+		sheets = append(sheets, sheet)
+	}
+
+	file, err := os.Create(filename)
+	if err != nil {
+		return fmt.Errorf("failed to create Excel file %s: %w", filename, err)
+	}
+	defer file.Close()
+
+	if err := writeXLSX(file, sheets); err != nil {
+		return fmt.Errorf("failed to write Excel file %s: %w", filename, err)
+	}
+	return file.Close()
+}
+
+// xlsxSummarySheet returns the sheet of the key metrics of every job
+func xlsxSummarySheet(summaries []ResultSummary) xlsxSheet {
+	metrics := SummarizeMetrics(summaries)
+	samples := make(map[string]map[int]bool)
+	var jobs []string
+	for _, summary := range summaries {
+		job := fmt.Sprintf("%s-%s-%d", summary.JobName, summary.BlockSize, summary.NumJobs)
+		if samples[job] == nil {
+			samples[job] = make(map[int]bool)
+			jobs = append(jobs, job)
+		}
+		samples[job][summary.Sample] = true
+	}
+
+	sheet := xlsxSheet{name: "Summary", rows: [][]xlsxCell{xlsxHeader(xlsxSummaryColumns)}}
+	for _, job := range jobs {
+		row := []xlsxCell{{value: job}, {value: strconv.Itoa(len(samples[job])), number: true}}
+		for _, metric := range []string{"read_iops", "read_bw_kbs", "write_iops", "write_bw_kbs", "read_lat_p95_us", "write_lat_p95_us"} {
+			value, ok := metrics[job+"."+metric]
+			if !ok {
+				row = append(row, xlsxCell{})
+				continue
+			}
+			row = append(row, xlsxCell{value: strconv.FormatFloat(value, 'f', -1, 64), number: true})
+		}
+		sheet.rows = append(sheet.rows, row)
+	}
+	return sheet
+}
+
+// xlsxHeader returns the header row of columns
+func xlsxHeader(columns []string) []xlsxCell {
+	cells := make([]xlsxCell, len(columns))
+	for i, column := range columns {
+		cells[i] = xlsxCell{value: column}
+	}
+	return cells
+}
+
+// writeXLSX writes a workbook of sheets as a minimal Office Open XML package, with inline
+// strings instead of a shared string table and the header row frozen
+func writeXLSX(file *os.File, sheets []xlsxSheet) error {
+	z := zip.NewWriter(file)
+
+	var types, workbook, rels strings.Builder
+	types.WriteString(xml.Header + `<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">` +
+		`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>` +
+		`<Default Extension="xml" ContentType="application/xml"/>` +
+		`<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>`)
+	workbook.WriteString(xml.Header + `<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" ` +
+		`xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"><sheets>`)
+	rels.WriteString(xml.Header + `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">`)
+
+	for i, sheet := range sheets {
+		n := i + 1
+		fmt.Fprintf(&types, `<Override PartName="/xl/worksheets/sheet%d.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>`, n)
+		fmt.Fprintf(&workbook, `<sheet name="%s" sheetId="%d" r:id="rId%d"/>`, xlsxEscape(sheet.name), n, n)
+		fmt.Fprintf(&rels, `<Relationship Id="rId%d" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet%d.xml"/>`, n, n)
+		if err := writeZipFile(z, fmt.Sprintf("xl/worksheets/sheet%d.xml", n), xlsxWorksheet(sheet)); err != nil {
+			return err
+		}
+	}
+	types.WriteString(`</Types>`)
+	workbook.WriteString(`</sheets></workbook>`)
+	rels.WriteString(`</Relationships>`)
+
+	for _, part := range []struct{ name, content string }{
+		{"[Content_Types].xml", types.String()},
+		{"_rels/.rels", xml.Header + `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+			`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>` +
+			`</Relationships>`},
+		{"xl/workbook.xml", workbook.String()},
+		{"xl/_rels/workbook.xml.rels", rels.String()},
+	} {
+		if err := writeZipFile(z, part.name, part.content); err != nil {
+			return err
+		}
+	}
+	return z.Close()
+}
+
+// xlsxWorksheet returns the XML of a worksheet
+func xlsxWorksheet(sheet xlsxSheet) string {
+	var b strings.Builder
+	b.WriteString(xml.Header + `<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">` +
+		`<sheetViews><sheetView workbookViewId="0"><pane ySplit="1" topLeftCell="A2" activePane="bottomLeft" state="frozen"/></sheetView></sheetViews>` +
+		`<sheetData>`)
+	for r, row := range sheet.rows {
+		fmt.Fprintf(&b, `<row r="%d">`, r+1)
+		for c, cell := range row {
+			if cell.value == "" {
+				continue
+			}
+			ref := xlsxColumn(c) + strconv.Itoa(r+1)
+			// Excel has no NaN or infinity, a <v> of them makes it repair the workbook
+			if f, err := strconv.ParseFloat(cell.value, 64); cell.number && err == nil && !math.IsNaN(f) && !math.IsInf(f, 0) {
+				fmt.Fprintf(&b, `<c r="%s"><v>%s</v></c>`, ref, cell.value)
+				continue
+			}
+			fmt.Fprintf(&b, `<c r="%s" t="inlineStr"><is><t>%s</t></is></c>`, ref, xlsxEscape(cell.value))
+		}
+		b.WriteString(`</row>`)
+	}
+	b.WriteString(`</sheetData></worksheet>`)
+	return b.String()
+}
+
+// xlsxColumn returns the letters of a zero-based column index, A to Z, then AA and so on
+func xlsxColumn(i int) string {
+	name := ""
+	for i++; i > 0; i = (i - 1) / 26 {
+		name = string(rune('A'+(i-1)%26)) + name
+	}
+	return name
+}
+
+// xlsxEscape escapes text for an XML element or attribute
+func xlsxEscape(s string) string {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
+
+// writeZipFile adds a file to a zip archive
+func writeZipFile(z *zip.Writer, name, content string) error {
+	w, err := z.Create(name)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write([]byte(content))
+	return err
+}
@@ -0,0 +1,139 @@
+package fio
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"io"
+	"math"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// worksheet is the XML of a worksheet as ExportResultsToXLSX writes it
+type worksheet struct {
+	Rows []struct {
+		R     int `xml:"r,attr"`
+		Cells []struct {
+			Ref    string `xml:"r,attr"`
+			Type   string `xml:"t,attr"`
+			Value  string `xml:"v"`
+			Inline string `xml:"is>t"`
+		} `xml:"c"`
+	} `xml:"sheetData>row"`
+}
+
+// readXLSX unzips a workbook and returns its parts by name, failing on XML that is not well
+// formed
+func readXLSX(t *testing.T, filename string) map[string][]byte {
+	t.Helper()
+	z, err := zip.OpenReader(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer z.Close()
+
+	parts := make(map[string][]byte)
+	for _, f := range z.File {
+		r, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := io.ReadAll(r)
+		r.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		decoder := xml.NewDecoder(bytes.NewReader(data))
+		for {
+			if _, err := decoder.Token(); err == io.EOF {
+				break
+			} else if err != nil {
+				t.Fatalf("%s is not well formed: %v", f.Name, err)
+			}
+		}
+		parts[f.Name] = data
+	}
+	return parts
+}
+
+// cells returns the cells of a worksheet by header, one map per row after the header, with
+// numbers as "n:<value>" and text as "s:<value>"
+func cells(t *testing.T, data []byte) []map[string]string {
+	t.Helper()
+	var sheet worksheet
+	if err := xml.Unmarshal(data, &sheet); err != nil {
+		t.Fatal(err)
+	}
+	column := func(ref string) string {
+		for i, c := range ref {
+			if c >= '0' && c <= '9' {
+				return ref[:i]
+			}
+		}
+		return ref
+	}
+
+	header := make(map[string]string)
+	for _, c := range sheet.Rows[0].Cells {
+		header[column(c.Ref)] = c.Inline
+	}
+	var rows []map[string]string
+	for _, row := range sheet.Rows[1:] {
+		values := make(map[string]string)
+		for _, c := range row.Cells {
+			switch c.Type {
+			case "":
+				values[header[column(c.Ref)]] = "n:" + c.Value
+			case "inlineStr":
+				values[header[column(c.Ref)]] = "s:" + c.Inline
+			default:
+				t.Errorf("cell %s has type %q", c.Ref, c.Type)
+			}
+		}
+		rows = append(rows, values)
+	}
+	return rows
+}
+
+func TestExportResultsToXLSX(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "results.xlsx")
+	summaries := []ResultSummary{
+		{TestID: "t", Sample: 1, JobName: "randread", BlockSize: "4KiB", NumJobs: 1, Hostname: "server-1 & <2>", ReadIOPS: 1000.5, ReadBW: 4002, ReadLatP95: 250},
+		{TestID: "t", Sample: 1, JobName: "randread", BlockSize: "4KiB", NumJobs: 1, Hostname: "server-2", ReadIOPS: math.Inf(1), ReadLatP95: math.NaN(), WriteLatP95: math.Inf(-1)},
+	}
+	if err := ExportResultsToXLSX(summaries, filename); err != nil {
+		t.Fatal(err)
+	}
+	parts := readXLSX(t, filename)
+
+	for _, name := range []string{"[Content_Types].xml", "_rels/.rels", "xl/workbook.xml", "xl/_rels/workbook.xml.rels", "xl/worksheets/sheet1.xml", "xl/worksheets/sheet2.xml"} {
+		if parts[name] == nil {
+			t.Errorf("workbook has no %s", name)
+		}
+	}
+
+	rows := cells(t, parts["xl/worksheets/sheet2.xml"])
+	if len(rows) != 2 {
+		t.Fatalf("sample sheet has %d rows, want 2", len(rows))
+	}
+	for i, want := range []map[string]string{
+		{"Hostname": "s:server-1 & <2>", "Read IOPS": "n:1000.5", "Read BW (KiB/s)": "n:4002", "Read Lat P95 (usec)": "n:250"},
+		// Excel has no NaN or infinity, so they are text
+		{"Hostname": "s:server-2", "Read IOPS": "s:+Inf", "Read BW (KiB/s)": "n:0", "Read Lat P95 (usec)": "s:NaN", "Write Lat P95 (usec)": "s:-Inf"},
+	} {
+		got := make(map[string]string)
+		for column := range want {
+			got[column] = rows[i][column]
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("sample sheet row %d is %v, want %v", i+2, got, want)
+		}
+	}
+
+	summary := cells(t, parts["xl/worksheets/sheet1.xml"])
+	if len(summary) != 1 || summary[0]["Job"] != "s:randread-4KiB-1" || summary[0]["Samples"] != "n:1" {
+		t.Errorf("summary sheet is %v, want one row for randread-4KiB-1", summary)
+	}
+}
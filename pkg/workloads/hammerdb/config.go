@@ -1,6 +1,17 @@
 package hammerdb
 
-import "fmt"
+import (
+	_ "embed"
+	"fmt"
+
+	"github.com/jtaleric/k8s-io/pkg/guest"
+	"github.com/jtaleric/k8s-io/pkg/kubernetes"
+)
+
+// ConfigSource is the source of this file, used to document the configuration fields
+//
+//go:embed config.go
+var ConfigSource string
 
 // HammerDBConfig represents the HammerDB benchmark parameters
 type HammerDBConfig struct {
@@ -38,6 +49,14 @@ type HammerDBConfig struct {
 	VMMemory string `yaml:"vm_memory,omitempty"` // VM memory
 	VMBus    string `yaml:"vm_bus,omitempty"`    // VM disk bus type
 
+	// KubeVirt instancetype and preference sizing the VM instead of vm_cores and vm_memory,
+	// "<name>" for the cluster-wide kinds or "<kind>/<name>" like virtctl
+	VMInstancetype string `yaml:"vm_instancetype,omitempty"` // Provides vm_cores and vm_memory
+	VMPreference   string `yaml:"vm_preference,omitempty"`   // Provides vm_bus when it sets a preferred disk bus
+
+	// Guest OS tuning applied by cloud-init before the database starts in the VM
+	GuestTuning *guest.Tuning `yaml:"guest_tuning,omitempty"`
+
 	// Client VM PVC settings
 	ClientVM ClientVMConfig `yaml:"client_vm,omitempty"`
 
@@ -48,6 +67,7 @@ type HammerDBConfig struct {
 	Tolerations       interface{}       `yaml:"tolerations,omitempty"`
 	Annotations       map[string]string `yaml:"annotations,omitempty"`
 	ServerAnnotations map[string]string `yaml:"server_annotations,omitempty"`
+	ClientAnnotations map[string]string `yaml:"client_annotations,omitempty"`
 
 	// Debug settings
 	Debug bool `yaml:"debug,omitempty"` // Enable debug mode
@@ -56,7 +76,7 @@ type HammerDBConfig struct {
 // ClientVMConfig represents client VM PVC configuration
 type ClientVMConfig struct {
 	PVC             bool   `yaml:"pvc"`               // Enable PVC
-	PVCStorageClass string `yaml:"pvc_storageclass"`  // Storage class for PVC
+	PVCStorageClass string `yaml:"pvc_storageclass"`  // Storage class for PVC, the cluster default when empty
 	PVCAccessMode   string `yaml:"pvc_pvcaccessmode"` // PVC access mode
 	PVCVolumeMode   string `yaml:"pvc_pvcvolumemode"` // PVC volume mode
 	PVCStorageSize  string `yaml:"pvc_storagesize"`   // PVC size
@@ -175,6 +195,24 @@ func (h *HammerDBConfig) Validate() error {
 		return fmt.Errorf("kind must be either 'pod' or 'vm'")
 	}
 
+	if h.VMInstancetype != "" || h.VMPreference != "" {
+		if h.Kind != "vm" {
+			return fmt.Errorf("vm_instancetype and vm_preference require kind 'vm'")
+		}
+		if err := kubernetes.ValidateVMResourceRefs(h.VMInstancetype, h.VMPreference); err != nil {
+			return err
+		}
+	}
+
+	if h.GuestTuning != nil {
+		if h.Kind != "vm" {
+			return fmt.Errorf("guest_tuning requires kind 'vm'")
+		}
+		if err := h.GuestTuning.Validate(); err != nil {
+			return err
+		}
+	}
+
 	if h.Warehouses <= 0 {
 		return fmt.Errorf("warehouses must be greater than 0")
 	}
@@ -0,0 +1,79 @@
+package hammerdb
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/jtaleric/k8s-io/pkg/kubernetes"
+)
+
+// consoleTailLines is the number of serial console lines logged when a VM run fails
+const consoleTailLines = 30
+
+// captureVMConsoles writes the status, serial console log and virt-launcher log of every VMI
+// of the run to <artifacts>/vm-console/<vmi>/, so failures of the scripts run inside the
+// guest can be diagnosed. When the run failed, the end of every console log is also logged.
+func (w *Workload) captureVMConsoles(ctx context.Context, failed bool) {
+	vmis, err := w.k8sClient.ListVMIs(ctx, w.config.Namespace, fmt.Sprintf("benchmark-uuid=%s", w.config.UUID))
+	if err != nil {
+		log.Printf("Warning: failed to capture VM console logs: %v", err)
+		return
+	}
+
+	for _, vmi := range vmis {
+		dir := filepath.Join(w.config.RunArtifactsDir(), "vm-console", vmi.GetName())
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			log.Printf("Warning: failed to create %s: %v", dir, err)
+			return
+		}
+
+		if data, err := json.MarshalIndent(vmi.Object["status"], "", "  "); err == nil {
+			if err := os.WriteFile(filepath.Join(dir, "status.json"), data, 0644); err != nil {
+				log.Printf("Warning: failed to write the status of VMI %s: %v", vmi.GetName(), err)
+			}
+		}
+
+		pod, err := w.k8sClient.GetVirtLauncherPod(ctx, vmi)
+		if err != nil {
+			log.Printf("Warning: %v", err)
+			continue
+		}
+
+		if logs, err := w.k8sClient.GetContainerLogs(ctx, pod.Namespace, pod.Name, "compute"); err != nil {
+			log.Printf("Warning: %v", err)
+		} else if err := os.WriteFile(filepath.Join(dir, "virt-launcher.log"), []byte(logs), 0644); err != nil {
+			log.Printf("Warning: failed to write the virt-launcher log of VMI %s: %v", vmi.GetName(), err)
+		}
+
+		// KubeVirt only adds the console container when serial console logging is enabled
+		if !kubernetes.HasContainer(pod, kubernetes.GuestConsoleContainer) {
+			log.Printf("Warning: VMI %s does not log its serial console, only its status and virt-launcher log were captured", vmi.GetName())
+			continue
+		}
+		console, err := w.k8sClient.GetContainerLogs(ctx, pod.Namespace, pod.Name, kubernetes.GuestConsoleContainer)
+		if err != nil {
+			log.Printf("Warning: %v", err)
+			continue
+		}
+		if err := os.WriteFile(filepath.Join(dir, "console.log"), []byte(console), 0644); err != nil {
+			log.Printf("Warning: failed to write the console log of VMI %s: %v", vmi.GetName(), err)
+		}
+
+		if failed {
+			lines := strings.Split(strings.TrimRight(console, "\n"), "\n")
+			if len(lines) > consoleTailLines {
+				lines = lines[len(lines)-consoleTailLines:]
+			}
+			log.Printf("Last %d console lines of VMI %s:\n%s", len(lines), vmi.GetName(), strings.Join(lines, "\n"))
+		}
+	}
+
+	if len(vmis) > 0 {
+		log.Printf("VM console logs written to %s", filepath.Join(w.config.RunArtifactsDir(), "vm-console"))
+	}
+}
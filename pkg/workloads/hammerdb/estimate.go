@@ -0,0 +1,79 @@
+package hammerdb
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/jtaleric/k8s-io/pkg/estimate"
+)
+
+// Estimate returns the cluster footprint of the generated manifests and jobs and the
+// expected duration of the benchmark
+func (w *Workload) Estimate() (*estimate.Estimate, error) {
+	manifests, err := w.GenerateManifests()
+	if err != nil {
+		return nil, err
+	}
+
+	// The jobs are rendered when their phase starts, so add them here
+	dbType := w.hammerdbConfig.DBType
+	if dbType == "pg" {
+		dbType = "postgres"
+	}
+	if w.hammerdbConfig.DBInit {
+		var job string
+		if w.hammerdbConfig.Kind == "vm" {
+			job, err = w.templateEngine.RenderHammerDBCreateJobVM(w.config, w.hammerdbConfig, dbType)
+		} else {
+			job, err = w.templateEngine.RenderHammerDBCreateJob(w.config, w.hammerdbConfig)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to render DB creation job: %w", err)
+		}
+		manifests["hammerdb-creator"] = job
+	}
+	if w.hammerdbConfig.DBBenchmark {
+		job, err := w.templateEngine.RenderHammerDBWorkloadJob(w.config, w.hammerdbConfig, dbType)
+		if err != nil {
+			return nil, fmt.Errorf("failed to render workload job: %w", err)
+		}
+		manifests["hammerdb-workload"] = job
+	}
+
+	e, err := estimate.FromManifests(manifests)
+	if err != nil {
+		return nil, err
+	}
+
+	if w.hammerdbConfig.DBBenchmark {
+		e.Duration = w.benchmarkDuration()
+	}
+	if w.hammerdbConfig.DBInit {
+		e.Notes = append(e.Notes, fmt.Sprintf("building %d warehouses before the benchmark, not included", w.hammerdbConfig.Warehouses))
+	}
+	return e, nil
+}
+
+// benchmarkDuration returns the rampup and test time of the workload job
+func (w *Workload) benchmarkDuration() time.Duration {
+	return time.Duration(w.hammerdbConfig.RampupTime+w.hammerdbConfig.Duration) * time.Minute
+}
+
+// checkTimeouts fails when job_timeout is too short for the planned benchmark
+func (w *Workload) checkTimeouts() error {
+	if !w.hammerdbConfig.DBBenchmark {
+		return nil
+	}
+	return estimate.CheckTimeout("workload", w.benchmarkDuration(), time.Duration(w.hammerdbConfig.JobTimeout)*time.Second)
+}
+
+// setETA logs when a job that just started is expected to complete and records it on the job
+func (w *Workload) setETA(ctx context.Context, jobName string, planned time.Duration) {
+	eta := time.Now().Add(planned)
+	log.Printf("Job %s expected to complete in %s (ETA %s)", jobName, planned.Round(time.Second), eta.Format(time.Kitchen))
+	if err := w.k8sClient.SetJobETA(ctx, jobName, w.config.Namespace, eta); err != nil {
+		log.Printf("Warning: failed to record ETA on job %s: %v", jobName, err)
+	}
+}
@@ -0,0 +1,53 @@
+package hammerdb
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/jtaleric/k8s-io/pkg/plan"
+)
+
+// Phases returns the phases RunBenchmark goes through, with the manifests it applies and
+// the jobs it waits for
+func (w *Workload) Phases() []plan.Phase {
+	timeout := (time.Duration(w.hammerdbConfig.JobTimeout) * time.Second).String()
+
+	deploy := plan.Phase{
+		Name:        "deploy",
+		Description: "create the database creation and workload scripts",
+		Applies:     []string{"hammerdb-createdb-script", "hammerdb-workload-script"},
+	}
+	if w.hammerdbConfig.ClientVM.PVC {
+		deploy.Applies = append([]string{"hammerdb-pvc"}, deploy.Applies...)
+	}
+	if w.hammerdbConfig.Kind == "vm" {
+		deploy.Applies = append(deploy.Applies, "hammerdb-vm-workload-script")
+	}
+	if w.hammerdbConfig.VMInstancetype != "" || w.hammerdbConfig.VMPreference != "" {
+		deploy.Description += ", sizing the VM from the instancetype and preference instead of the rendered vm_cores and vm_memory"
+	}
+	phases := []plan.Phase{deploy}
+
+	if w.hammerdbConfig.DBInit {
+		job := "hammerdb-creator-" + w.config.GetTruncatedUUID()
+		phases = append(phases, plan.Phase{
+			Name:        "db-init",
+			Description: fmt.Sprintf("build %d warehouses in %s on %s", w.hammerdbConfig.Warehouses, w.hammerdbConfig.DBName, w.hammerdbConfig.DBServer),
+			Jobs:        []string{job},
+			Waits:       []plan.Wait{{For: "job " + job + " complete", Timeout: timeout}},
+		})
+	}
+
+	if w.hammerdbConfig.DBBenchmark {
+		job := w.workloadJobName()
+		phases = append(phases, plan.Phase{
+			Name: "benchmark",
+			Description: fmt.Sprintf("run %d virtual users for %d minutes after a %d minute rampup",
+				w.hammerdbConfig.VirtualUsers, w.hammerdbConfig.Duration, w.hammerdbConfig.RampupTime),
+			Jobs:  []string{job},
+			Waits: []plan.Wait{{For: "job " + job + " complete", Timeout: timeout}},
+		})
+	}
+
+	return phases
+}
@@ -0,0 +1,49 @@
+package hammerdb
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+
+	"github.com/jtaleric/k8s-io/pkg/config"
+)
+
+// testResultPattern matches the HammerDB virtual user result line, e.g.
+// "Vuser 1:TEST RESULT : System achieved 12345 NOPM from 28390 PostgreSQL TPM"
+var testResultPattern = regexp.MustCompile(`System achieved (\d+) NOPM from (\d+) \w+ TPM`)
+
+// Result represents the outcome of one HammerDB test run
+type Result struct {
+	Sample int
+	NOPM   int
+	TPM    int
+}
+
+// ParseHammerDBResults parses the TPM and NOPM results from HammerDB log output
+func ParseHammerDBResults(logOutput string) []Result {
+	var results []Result
+
+	for i, match := range testResultPattern.FindAllStringSubmatch(logOutput, -1) {
+		nopm, _ := strconv.Atoi(match[1])
+		tpm, _ := strconv.Atoi(match[2])
+		results = append(results, Result{Sample: i + 1, NOPM: nopm, TPM: tpm})
+	}
+
+	return results
+}
+
+// CheckThresholds returns the thresholds violated by a HammerDB result
+func CheckThresholds(result Result, thresholds []config.Threshold) []string {
+	var violations []string
+
+	for _, t := range thresholds {
+		if t.MinTPM > 0 && result.TPM < t.MinTPM {
+			violations = append(violations, fmt.Sprintf("TPM %d below minimum %d", result.TPM, t.MinTPM))
+		}
+		if t.MinNOPM > 0 && result.NOPM < t.MinNOPM {
+			violations = append(violations, fmt.Sprintf("NOPM %d below minimum %d", result.NOPM, t.MinNOPM))
+		}
+	}
+
+	return violations
+}
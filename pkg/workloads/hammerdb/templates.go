@@ -3,11 +3,14 @@ package hammerdb
 import (
 	"embed"
 	"fmt"
+	"log"
 	"regexp"
 	"strings"
 
 	"github.com/flosch/pongo2/v6"
 	"github.com/jtaleric/k8s-io/pkg/config"
+	"github.com/jtaleric/k8s-io/pkg/kubernetes"
+	"github.com/jtaleric/k8s-io/pkg/templatedebug"
 )
 
 //go:embed templates/*.j2
@@ -27,6 +30,8 @@ func indentContent(content, prefix string) string {
 // TemplateEngine handles HammerDB template processing
 type TemplateEngine struct {
 	templateSet *pongo2.TemplateSet
+	debug       *templatedebug.Dumper
+	checksums   map[string]string // Script ConfigMap checksums by role, see recordChecksum
 }
 
 // NewTemplateEngine creates a new HammerDB template engine
@@ -37,9 +42,15 @@ func NewTemplateEngine(templatesDir string) *TemplateEngine {
 
 	return &TemplateEngine{
 		templateSet: templateSet,
+		checksums:   make(map[string]string),
 	}
 }
 
+// EnableDebug writes the context and template text of every rendered manifest to dir
+func (e *TemplateEngine) EnableDebug(dir string) {
+	e.debug = templatedebug.NewDumper(dir)
+}
+
 // LoadTemplate loads and preprocesses a template file
 func (e *TemplateEngine) LoadTemplate(templatePath string) (*pongo2.Template, error) {
 	// Read from embedded filesystem
@@ -62,6 +73,18 @@ func (e *TemplateEngine) LoadTemplate(templatePath string) (*pongo2.Template, er
 
 // RenderTemplate renders a template with the given context
 func (e *TemplateEngine) RenderTemplate(templatePath string, context pongo2.Context) (string, error) {
+	rendered, err := e.renderTemplate(templatePath, context)
+
+	if e.debug != nil {
+		source, _ := embeddedTemplates.ReadFile("templates/" + templatePath)
+		e.debug.Dump(templatePath, string(source), e.preprocessJinja2ToPongo2(string(source)), context, rendered, err)
+	}
+
+	return rendered, err
+}
+
+// renderTemplate loads and renders a template file
+func (e *TemplateEngine) renderTemplate(templatePath string, context pongo2.Context) (string, error) {
 	template, err := e.LoadTemplate(templatePath)
 	if err != nil {
 		return "", fmt.Errorf("failed to load template %s: %w", templatePath, err)
@@ -75,6 +98,23 @@ func (e *TemplateEngine) RenderTemplate(templatePath string, context pongo2.Cont
 	return rendered, nil
 }
 
+// renderInline renders a template defined in code, which is not preprocessed
+func (e *TemplateEngine) renderInline(name, source string, context pongo2.Context) (string, error) {
+	var rendered string
+	template, err := e.templateSet.FromString(source)
+	if err != nil {
+		err = fmt.Errorf("failed to compile %s template: %w", name, err)
+	} else {
+		rendered, err = template.Execute(context)
+	}
+
+	if e.debug != nil {
+		e.debug.Dump(name, source, source, context, rendered, err)
+	}
+
+	return rendered, err
+}
+
 // preprocessJinja2ToPongo2 converts Jinja2 specific syntax to Pongo2 compatible syntax
 func (e *TemplateEngine) preprocessJinja2ToPongo2(content string) string {
 	// Handle "is defined" checks
@@ -152,10 +192,22 @@ func (e *TemplateEngine) createBaseContext(cfg *config.Config) pongo2.Context {
 		"ceph_osd_cache_drop_pod_ip":  cfg.CephOSDCacheDropPodIP,
 		"ceph_cache_drop_svc_port":    cfg.CephCacheDropSvcPort,
 		"rook_ceph_drop_cache_pod_ip": cfg.RookCephDropCachePodIP,
-		"elasticsearch":               cfg.Elasticsearch,
+		"job":                         cfg.Job,
+		"elasticsearch":               cfg.Elasticsearch.TemplateContext("hammerdb"),
 	}
 }
 
+// recordChecksum remembers the content checksum of a rendered script ConfigMap for the job mounting it
+func (e *TemplateEngine) recordChecksum(role, manifest string) {
+	checksum, err := kubernetes.ConfigMapChecksum(manifest)
+	if err != nil {
+		log.Printf("Warning: pods will not verify the %s ConfigMap: %v", role, err)
+		delete(e.checksums, role)
+		return
+	}
+	e.checksums[role] = checksum
+}
+
 // RenderHammerDBPVC renders a HammerDB PVC
 func (e *TemplateEngine) RenderHammerDBPVC(cfg *config.Config, hammerdbConfig *HammerDBConfig) (string, error) {
 	context := e.createBaseContext(cfg)
@@ -170,9 +222,10 @@ metadata:
   labels:
     app: "hammerdb-{{ trunc_uuid }}"
     benchmark-uuid: "{{ uuid }}"
-  annotations:
-    volume.beta.kubernetes.io/storage-class: "{{ workload_args.ClientVM.PVCStorageClass }}"
 spec:
+{% if workload_args.ClientVM.PVCStorageClass %}
+  storageClassName: "{{ workload_args.ClientVM.PVCStorageClass }}"
+{% endif %}
   accessModes:
     - "{{ workload_args.ClientVM.PVCAccessMode }}"
   volumeMode: "{{ workload_args.ClientVM.PVCVolumeMode }}"
@@ -180,12 +233,7 @@ spec:
     requests:
       storage: "{{ workload_args.ClientVM.PVCStorageSize }}"`
 
-	template, err := e.templateSet.FromString(pvcTemplate)
-	if err != nil {
-		return "", fmt.Errorf("failed to compile HammerDB PVC template: %w", err)
-	}
-
-	return template.Execute(context)
+	return e.renderInline("HammerDB PVC", pvcTemplate, context)
 }
 
 // RenderHammerDBCreateScript renders the HammerDB database creation script configmap
@@ -227,12 +275,11 @@ data:
 	indentedScript := indentContent(scriptContent, "    ")
 	context["script_content"] = indentedScript
 
-	template, err := e.templateSet.FromString(configMapTemplate)
-	if err != nil {
-		return "", fmt.Errorf("failed to compile create script configmap template: %w", err)
+	manifest, err := e.renderInline("create script configmap", configMapTemplate, context)
+	if err == nil {
+		e.recordChecksum("creator", manifest)
 	}
-
-	return template.Execute(context)
+	return manifest, err
 }
 
 // RenderHammerDBWorkloadScript renders the HammerDB workload script configmap
@@ -284,12 +331,11 @@ data:
 	indentedScript := indentContent(scriptContent, "    ")
 	context["script_content"] = indentedScript
 
-	template, err := e.templateSet.FromString(configMapTemplate)
-	if err != nil {
-		return "", fmt.Errorf("failed to compile workload script configmap template: %w", err)
+	manifest, err := e.renderInline("workload script configmap", configMapTemplate, context)
+	if err == nil {
+		e.recordChecksum("workload", manifest)
 	}
-
-	return template.Execute(context)
+	return manifest, err
 }
 
 // RenderHammerDBVMWorkloadScript renders the HammerDB VM workload script configmap
@@ -337,12 +383,7 @@ data:
 	indentedScript := indentContent(scriptContent, "    ")
 	context["script_content"] = indentedScript
 
-	template, err := e.templateSet.FromString(configMapTemplate)
-	if err != nil {
-		return "", fmt.Errorf("failed to compile VM workload script configmap template: %w", err)
-	}
-
-	return template.Execute(context)
+	return e.renderInline("VM workload script configmap", configMapTemplate, context)
 }
 
 // RenderHammerDBCreateJob renders the HammerDB database creation job
@@ -350,6 +391,7 @@ func (e *TemplateEngine) RenderHammerDBCreateJob(cfg *config.Config, hammerdbCon
 	context := e.createBaseContext(cfg)
 	context["workload_args"] = hammerdbConfig
 	context["resource_kind"] = hammerdbConfig.Kind
+	context["config_checksum"] = e.checksums["creator"]
 
 	return e.RenderTemplate("db_creation.yml.j2", context)
 }
@@ -359,6 +401,8 @@ func (e *TemplateEngine) RenderHammerDBCreateJobVM(cfg *config.Config, hammerdbC
 	context := e.createBaseContext(cfg)
 	context["workload_args"] = hammerdbConfig
 	context["resource_kind"] = "vm"
+	context["guest_tuning"] = hammerdbConfig.GuestTuning.Commands()
+	context["guest_mount_flag"] = hammerdbConfig.GuestTuning.MountFlag()
 
 	templateFile := fmt.Sprintf("db_creation_%s_vm.yml.j2", dbType)
 	return e.RenderTemplate(templateFile, context)
@@ -369,6 +413,7 @@ func (e *TemplateEngine) RenderHammerDBWorkloadJob(cfg *config.Config, hammerdbC
 	context := e.createBaseContext(cfg)
 	context["workload_args"] = hammerdbConfig
 	context["resource_kind"] = hammerdbConfig.Kind
+	context["config_checksum"] = e.checksums["workload"]
 
 	templateFile := fmt.Sprintf("db_%s_workload.yml.j2", dbType)
 	return e.RenderTemplate(templateFile, context)
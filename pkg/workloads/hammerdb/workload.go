@@ -4,10 +4,18 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"path/filepath"
+	"strings"
 	"time"
 
 	"github.com/jtaleric/k8s-io/pkg/config"
+	"github.com/jtaleric/k8s-io/pkg/grafana"
+	"github.com/jtaleric/k8s-io/pkg/junit"
 	"github.com/jtaleric/k8s-io/pkg/kubernetes"
+	"github.com/jtaleric/k8s-io/pkg/metrics"
+	"github.com/jtaleric/k8s-io/pkg/results"
+	"github.com/jtaleric/k8s-io/pkg/status"
+	"github.com/jtaleric/k8s-io/pkg/timeline"
 )
 
 // Workload implements the HammerDB benchmark workload
@@ -16,11 +24,17 @@ type Workload struct {
 	templateEngine *TemplateEngine
 	config         *config.Config
 	hammerdbConfig *HammerDBConfig
+	results        []Result
+	timeline       timeline.Timeline
+	capturer       metrics.Capturer // Started with the run, published with the timeline
 }
 
 // NewWorkload creates a new HammerDB workload
 func NewWorkload(k8sClient *kubernetes.Client, cfg *config.Config, hammerdbConfig *HammerDBConfig) (*Workload, error) {
 	templateEngine := NewTemplateEngine("pkg/workloads/hammerdb/templates")
+	if cfg.DebugTemplates {
+		templateEngine.EnableDebug(filepath.Join(cfg.RunArtifactsDir(), "templates"))
+	}
 
 	return &Workload{
 		k8sClient:      k8sClient,
@@ -35,9 +49,53 @@ func (w *Workload) GetName() string {
 	return "hammerdb"
 }
 
+// Progress returns the running phase and the percentage of the expected rampup and
+// benchmark duration elapsed
+func (w *Workload) Progress(ctx context.Context) (string, float64) {
+	return w.timeline.Progress(w.benchmarkDuration())
+}
+
+// CollectResults returns the mean TPM and NOPM and the threshold violations of the samples
+// captured by RunBenchmark
+func (w *Workload) CollectResults(ctx context.Context) (*results.Run, error) {
+	run := &results.Run{
+		UUID:     w.config.UUID,
+		Workload: w.GetName(),
+		Samples:  len(w.results),
+		Metrics:  make(map[string]float64),
+	}
+	if w.config.Clock != nil {
+		run.Start = w.config.Clock.Start
+	}
+
+	db := w.hammerdbConfig.DBType
+	if db == "pg" {
+		db = "postgres"
+	}
+	for _, result := range w.results {
+		run.Metrics[db+".tpm"] += float64(result.TPM) / float64(len(w.results))
+		run.Metrics[db+".nopm"] += float64(result.NOPM) / float64(len(w.results))
+		for _, violation := range CheckThresholds(result, w.config.Thresholds) {
+			run.Violations = append(run.Violations, fmt.Sprintf("sample %d: %s", result.Sample, violation))
+		}
+	}
+	return run, nil
+}
+
 // Validate validates the workload configuration
 func (w *Workload) Validate() error {
-	return w.hammerdbConfig.Validate()
+	if err := w.hammerdbConfig.Validate(); err != nil {
+		return err
+	}
+	if w.config.Hooks != nil && len(w.config.Hooks.PostSample) > 0 {
+		return fmt.Errorf("post_sample hooks are only supported by the fio workload")
+	}
+	return w.checkTimeouts()
+}
+
+// Fingerprint returns the canonical hash of the effective HammerDB configuration
+func (w *Workload) Fingerprint() (string, error) {
+	return w.config.Fingerprint(w.hammerdbConfig)
 }
 
 // GenerateManifests generates all Kubernetes manifests
@@ -80,40 +138,149 @@ func (w *Workload) GenerateManifests() (map[string]string, error) {
 }
 
 // RunBenchmark executes the complete HammerDB benchmark
-func (w *Workload) RunBenchmark(ctx context.Context) error {
+func (w *Workload) RunBenchmark(ctx context.Context) (err error) {
 	log.Println("Starting HammerDB benchmark execution...")
 
+	if err := w.applyServiceMesh(ctx); err != nil {
+		return status.Errorf(status.ReasonPreflight, "failed to check service mesh: %w", err)
+	}
+
+	if err := w.k8sClient.ResolveCollisions(ctx, w.config.Namespace, w.config.CollisionPolicy,
+		fmt.Sprintf("benchmark-uuid=%s", w.config.UUID)); err != nil {
+		return status.Errorf(status.ReasonPreflight, "failed to resolve resource collisions: %w", err)
+	}
+
+	if w.hammerdbConfig.VMInstancetype != "" || w.hammerdbConfig.VMPreference != "" {
+		if err := w.applyInstancetype(ctx); err != nil {
+			return status.Errorf(status.ReasonPreflight, "failed to resolve the VM instancetype: %w", err)
+		}
+	}
+
+	if err := w.hammerdbConfig.GuestTuning.Record(w.config); err != nil {
+		log.Printf("Warning: %v", err)
+	}
+
+	capturer, err := metrics.New(w.config, w.k8sClient)
+	if err != nil {
+		return status.Errorf(status.ReasonConfig, "failed to create metrics capturer: %w", err)
+	}
+	if err := capturer.Start(ctx); err != nil {
+		log.Printf("Warning: failed to start metrics capture: %v", err)
+	} else {
+		w.capturer = capturer
+	}
+
+	defer w.publishTimeline(ctx)
+
+	// Scripts run inside the guest only report through the serial console
+	if w.hammerdbConfig.Kind == "vm" {
+		defer func() { w.captureVMConsoles(ctx, err != nil) }()
+	}
+
 	// Phase 1: Deploy infrastructure
-	if err := w.deployInfrastructure(ctx); err != nil {
-		return fmt.Errorf("failed to deploy infrastructure: %w", err)
+	if err := w.timeline.Track("deploy", func() error { return w.deployInfrastructure(ctx) }); err != nil {
+		return status.Errorf(status.ReasonDeploy, "failed to deploy infrastructure: %w", err)
 	}
 
 	// Phase 2: Run database initialization if enabled
 	if w.hammerdbConfig.DBInit {
-		if err := w.runDBInitialization(ctx); err != nil {
+		if err := w.timeline.Track("db-init", func() error { return w.runDBInitialization(ctx) }); err != nil {
 			return fmt.Errorf("failed to initialize database: %w", err)
 		}
 	}
 
-	// Phase 3: Run benchmark if enabled
+	// Phase 3: Run benchmark if enabled and wait for completion
 	if w.hammerdbConfig.DBBenchmark {
-		if err := w.runBenchmark(ctx); err != nil {
-			return fmt.Errorf("failed to run benchmark: %w", err)
+		err := w.timeline.Track("benchmark", func() error {
+			if err := w.runBenchmark(ctx); err != nil {
+				return fmt.Errorf("failed to run benchmark: %w", err)
+			}
+			if err := w.waitForCompletion(ctx); err != nil {
+				return fmt.Errorf("failed to wait for completion: %w", err)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
 		}
 	}
 
-	// Phase 4: Wait for completion
-	if w.hammerdbConfig.DBBenchmark {
-		if err := w.waitForCompletion(ctx); err != nil {
-			return fmt.Errorf("failed to wait for completion: %w", err)
+	log.Println("HammerDB benchmark completed successfully!")
+
+	return nil
+}
+
+// publishTimeline logs the benchmark phase windows, annotates them in Grafana and captures
+// the metrics profiles over them if configured
+func (w *Workload) publishTimeline(ctx context.Context) {
+	w.timeline.Finish()
+	w.timeline.Log()
+
+	if w.config.Grafana != nil {
+		if err := grafana.AnnotatePhases(ctx, w.config, w.timeline.Phases); err != nil {
+			log.Printf("Warning: failed to create Grafana annotations: %v", err)
+		} else {
+			log.Printf("Created Grafana annotations for %d phases", len(w.timeline.Phases))
 		}
 	}
 
-	log.Println("HammerDB benchmark completed successfully!")
+	if w.capturer != nil {
+		if err := metrics.Finish(ctx, w.capturer, w.config, w.timeline.Phases); err != nil {
+			log.Printf("Warning: failed to capture metrics: %v", err)
+		}
+	}
+}
+
+// applyServiceMesh adds the pod annotations for the service mesh injecting sidecars into the namespace
+func (w *Workload) applyServiceMesh(ctx context.Context) error {
+	mesh, err := w.k8sClient.DetectServiceMesh(ctx, w.config.Namespace)
+	if err != nil {
+		return err
+	}
+	if mesh == kubernetes.MeshNone {
+		return nil
+	}
+
+	enabled := w.config.ServiceMesh == "enabled"
+	if enabled {
+		log.Printf("Detected %s sidecar injection, holding HammerDB containers until the proxy is ready", mesh)
+	} else {
+		log.Printf("Detected %s sidecar injection, disabling it for HammerDB pods", mesh)
+	}
+
+	if w.hammerdbConfig.Annotations == nil {
+		w.hammerdbConfig.Annotations = make(map[string]string)
+	}
+	for k, v := range kubernetes.MeshPodAnnotations(mesh, enabled) {
+		// Annotations set in the configuration take precedence
+		if _, ok := w.hammerdbConfig.Annotations[k]; !ok {
+			w.hammerdbConfig.Annotations[k] = v
+		}
+	}
 
 	return nil
 }
 
+// applyInstancetype sizes the database VM from vm_instancetype and vm_preference, since the
+// VMI cannot reference them like a VirtualMachine does
+func (w *Workload) applyInstancetype(ctx context.Context) error {
+	res, err := w.k8sClient.ResolveVMResources(ctx, w.config.Namespace, w.hammerdbConfig.VMInstancetype, w.hammerdbConfig.VMPreference)
+	if err != nil {
+		return err
+	}
+	if res.CPUs > 0 {
+		w.hammerdbConfig.VMCores = res.CPUs
+		w.hammerdbConfig.VMMemory = res.Memory
+	}
+	if res.DiskBus != "" {
+		w.hammerdbConfig.VMBus = res.DiskBus
+	}
+
+	log.Printf("Database VM sized from the instancetype and preference: %d vCPUs, %s memory, %s disks",
+		w.hammerdbConfig.VMCores, w.hammerdbConfig.VMMemory, w.hammerdbConfig.VMBus)
+	return nil
+}
+
 // deployInfrastructure deploys the initial infrastructure
 func (w *Workload) deployInfrastructure(ctx context.Context) error {
 	log.Println("Deploying HammerDB infrastructure...")
@@ -199,6 +366,17 @@ func (w *Workload) runDBInitialization(ctx context.Context) error {
 	jobName := fmt.Sprintf("hammerdb-creator-%s", w.config.GetTruncatedUUID())
 	timeout := time.Duration(w.hammerdbConfig.JobTimeout) * time.Second
 
+	if w.hammerdbConfig.Kind == "vm" {
+		selector := fmt.Sprintf("app=hammerdb_workload-%s", w.config.GetTruncatedUUID())
+		guests, err := w.k8sClient.WaitForVMIGuestInfo(ctx, w.config.Namespace, selector, 1, timeout)
+		if err != nil {
+			return fmt.Errorf("database VM did not start: %w", err)
+		}
+		for _, guest := range guests {
+			log.Printf("Database VM %s running on %s: %s %s", guest.Name, guest.Node, strings.Join(guest.IPs, ","), guest.OS)
+		}
+	}
+
 	if err := w.k8sClient.WaitForJobCompletion(ctx, jobName, w.config.Namespace, timeout); err != nil {
 		return fmt.Errorf("DB creation job failed: %w", err)
 	}
@@ -233,35 +411,96 @@ func (w *Workload) runBenchmark(ctx context.Context) error {
 	}
 
 	log.Println("HammerDB benchmark workload started")
+	w.setETA(ctx, w.workloadJobName(), w.benchmarkDuration())
 
 	return nil
 }
 
-// waitForCompletion waits for the benchmark to complete
-func (w *Workload) waitForCompletion(ctx context.Context) error {
-	log.Println("Waiting for HammerDB benchmark to complete...")
-
-	// The job name depends on the database type
-	var jobName string
+// workloadJobName returns the name of the workload job, which depends on the database type
+func (w *Workload) workloadJobName() string {
 	switch w.hammerdbConfig.DBType {
 	case "mssql":
-		jobName = fmt.Sprintf("hammerdb-mssql-workload-%s", w.config.GetTruncatedUUID())
+		return fmt.Sprintf("hammerdb-mssql-workload-%s", w.config.GetTruncatedUUID())
 	case "mariadb":
-		jobName = fmt.Sprintf("hammerdb-mariadb-workload-%s", w.config.GetTruncatedUUID())
+		return fmt.Sprintf("hammerdb-mariadb-workload-%s", w.config.GetTruncatedUUID())
 	case "pg":
-		jobName = fmt.Sprintf("hammerdb-postgres-workload-%s", w.config.GetTruncatedUUID())
+		return fmt.Sprintf("hammerdb-postgres-workload-%s", w.config.GetTruncatedUUID())
 	}
+	return ""
+}
+
+// waitForCompletion waits for the benchmark to complete
+func (w *Workload) waitForCompletion(ctx context.Context) error {
+	log.Println("Waiting for HammerDB benchmark to complete...")
 
+	jobName := w.workloadJobName()
 	timeout := time.Duration(w.hammerdbConfig.JobTimeout) * time.Second
 
 	if err := w.k8sClient.WaitForJobCompletion(ctx, jobName, w.config.Namespace, timeout); err != nil {
 		return fmt.Errorf("benchmark job failed: %w", err)
 	}
 
+	// Capture and parse results
+	logs, err := w.k8sClient.GetJobPodLogs(ctx, jobName, w.config.Namespace)
+	if err != nil {
+		log.Printf("Warning: Failed to capture results: %v", err)
+	} else {
+		w.results = ParseHammerDBResults(logs)
+		for _, result := range w.results {
+			log.Printf("HammerDB result %d: %d TPM, %d NOPM", result.Sample, result.TPM, result.NOPM)
+		}
+	}
+
+	// Fail the run if the results violate the configured thresholds
+	if err := w.checkThresholds(); err != nil {
+		return err
+	}
+
 	log.Println("HammerDB benchmark completed successfully!")
 	return nil
 }
 
+// checkThresholds checks the captured results against the configured thresholds
+func (w *Workload) checkThresholds() error {
+	if w.config.JUnitFile != "" {
+		if err := w.writeJUnit(); err != nil {
+			log.Printf("Warning: %v", err)
+		} else {
+			log.Printf("JUnit report written to: %s", w.config.JUnitFile)
+		}
+	}
+
+	if len(w.config.Thresholds) == 0 {
+		return nil
+	}
+
+	if len(w.results) == 0 {
+		return status.Errorf(status.ReasonThreshold, "no results available to check thresholds against")
+	}
+
+	failed := 0
+	for _, result := range w.results {
+		violations := CheckThresholds(result, w.config.Thresholds)
+		for _, violation := range violations {
+			log.Printf("Threshold check failed: sample %d: %s", result.Sample, violation)
+		}
+		if len(violations) > 0 {
+			failed++
+		}
+	}
+
+	if failed > 0 {
+		return status.Errorf(status.ReasonThreshold, "%d sample(s) violated thresholds", failed)
+	}
+
+	return nil
+}
+
+// Namespaces returns the run namespace, the only namespace of the database and client
+func (w *Workload) Namespaces() []string {
+	return []string{w.config.Namespace}
+}
+
 // Cleanup removes all resources created by the benchmark
 func (w *Workload) Cleanup(ctx context.Context) error {
 	log.Println("Cleaning up HammerDB benchmark resources...")
@@ -272,6 +511,44 @@ func (w *Workload) Cleanup(ctx context.Context) error {
 		return fmt.Errorf("failed to cleanup resources: %w", err)
 	}
 
+	// The service account and token for Prometheus live in its namespace
+	if err := w.k8sClient.CleanupAuxiliary(ctx, w.config.UUID); err != nil {
+		log.Printf("Warning: failed to cleanup the auxiliary resources of the run: %v", err)
+	}
+
 	log.Println("Cleanup completed")
 	return nil
 }
+
+// writeJUnit writes a JUnit report with one test case per HammerDB sample
+func (w *Workload) writeJUnit() error {
+	suite := junit.TestSuite{Name: fmt.Sprintf("hammerdb-%s", w.config.UUID)}
+	className := "hammerdb." + w.hammerdbConfig.DBType
+	sampleTime := float64((w.hammerdbConfig.RampupTime + w.hammerdbConfig.Duration) * 60)
+
+	if len(w.results) == 0 {
+		suite.AddTestCase(junit.TestCase{
+			Name:      "results",
+			ClassName: className,
+			Failure:   &junit.Failure{Message: "no HammerDB results captured", Type: "missing_results"},
+		})
+	}
+
+	for _, result := range w.results {
+		tc := junit.TestCase{
+			Name:      fmt.Sprintf("sample %d", result.Sample),
+			ClassName: className,
+			Time:      sampleTime,
+		}
+		if violations := CheckThresholds(result, w.config.Thresholds); len(violations) > 0 {
+			tc.Failure = &junit.Failure{
+				Message: fmt.Sprintf("%d threshold(s) violated", len(violations)),
+				Type:    string(status.ReasonThreshold),
+				Text:    strings.Join(violations, "\n"),
+			}
+		}
+		suite.AddTestCase(tc)
+	}
+
+	return junit.Write(w.config.JUnitFile, suite)
+}
@@ -0,0 +1,138 @@
+package hammerdb
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/jtaleric/k8s-io/pkg/config"
+	"github.com/jtaleric/k8s-io/pkg/kubernetes/kubetest"
+	"github.com/jtaleric/k8s-io/pkg/status"
+	"github.com/jtaleric/k8s-io/pkg/timeline"
+)
+
+const testConfig = `namespace: hammerdb-test
+uuid: 4567cdef-0000-4000-8000-000000000000
+workload:
+  name: hammerdb
+  args:
+    kind: pod
+    db_type: pg
+    db_init: true
+    db_benchmark: true
+    db_server: postgresql.default.svc.cluster.local
+    db_port: 5432
+    db_name: tpcc
+    db_user: postgres
+    db_password: password
+    warehouses: 10
+    virtual_users: 5
+    rampup_time: 1
+    duration: 1
+`
+
+// newTestWorkload returns the workload of a config on a fake cluster, with its namespace
+// created like main does. The run writes its events and artifacts to the returned directory.
+func newTestWorkload(t *testing.T, cluster *kubetest.Cluster, configYAML string) (*Workload, string) {
+	t.Helper()
+	dir := t.TempDir()
+	cfg, err := config.Parse([]byte(configYAML+"artifacts_dir: "+dir+"\n"), "test.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	args, err := yaml.Marshal(cfg.Workload.Args)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var hammerdbConfig HammerDBConfig
+	if err := yaml.Unmarshal(args, &hammerdbConfig); err != nil {
+		t.Fatal(err)
+	}
+	hammerdbConfig.SetDefaults()
+	if err := hammerdbConfig.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	w, err := NewWorkload(cluster.Client, cfg, &hammerdbConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	labels, annotations := cfg.NamespaceMetadata()
+	if err := cluster.Client.CreateNamespace(context.Background(), cfg.Namespace, labels, annotations); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := timeline.OpenEvents(dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(timeline.CloseEvents)
+	return w, dir
+}
+
+// waits returns the subjects of the waits of a run, with the kind of wait
+func waits(t *testing.T, dir string) []string {
+	t.Helper()
+	events, err := timeline.ReadEvents(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var waits []string
+	for _, e := range events {
+		if e.Kind == timeline.EventWait {
+			waits = append(waits, strings.Fields(e.Message)[0]+" "+e.Subject)
+		}
+	}
+	return waits
+}
+
+const workloadLog = `Vuser 1:TEST RESULT : System achieved 12345 NOPM from 28390 PostgreSQL TPM
+`
+
+func TestRunBenchmark(t *testing.T) {
+	cluster := kubetest.New()
+	w, dir := newTestWorkload(t, cluster, testConfig+"thresholds:\n  - min_tpm: 20000\n")
+	cluster.SetLogs("hammerdb-postgres-workload", workloadLog)
+
+	if err := w.RunBenchmark(context.Background()); err != nil {
+		t.Fatalf("RunBenchmark failed: %v", err)
+	}
+
+	wantApplied := []string{
+		"/Namespace/hammerdb-test",
+		"hammerdb-test/ConfigMap/hammerdb-creator-4567cdef",
+		"hammerdb-test/ConfigMap/hammerdb-workload-4567cdef",
+		"hammerdb-test/Job/hammerdb-creator-4567cdef",
+		"hammerdb-test/Job/hammerdb-postgres-workload-4567cdef",
+	}
+	if got := cluster.Applied(); !reflect.DeepEqual(got, wantApplied) {
+		t.Errorf("applied %q, want %q", got, wantApplied)
+	}
+
+	// The benchmark starts once the database is initialized
+	wantWaits := []string{
+		"wait-job hammerdb-test/hammerdb-creator-4567cdef",
+		"wait-job hammerdb-test/hammerdb-postgres-workload-4567cdef",
+	}
+	if got := waits(t, dir); !reflect.DeepEqual(got, wantWaits) {
+		t.Errorf("waits %q, want %q", got, wantWaits)
+	}
+
+	want := []Result{{Sample: 1, NOPM: 12345, TPM: 28390}}
+	if !reflect.DeepEqual(w.results, want) {
+		t.Errorf("results %+v, want %+v", w.results, want)
+	}
+}
+
+func TestRunBenchmarkThresholds(t *testing.T) {
+	cluster := kubetest.New()
+	w, _ := newTestWorkload(t, cluster, testConfig+"thresholds:\n  - min_tpm: 30000\n")
+	cluster.SetLogs("hammerdb-postgres-workload", workloadLog)
+
+	err := w.RunBenchmark(context.Background())
+	if status.ReasonOf(err) != status.ReasonThreshold {
+		t.Fatalf("RunBenchmark returned %v, want a threshold failure", err)
+	}
+}
@@ -7,9 +7,13 @@ import (
 	"gopkg.in/yaml.v3"
 
 	"github.com/jtaleric/k8s-io/pkg/config"
+	"github.com/jtaleric/k8s-io/pkg/estimate"
 	"github.com/jtaleric/k8s-io/pkg/kubernetes"
+	"github.com/jtaleric/k8s-io/pkg/plan"
+	"github.com/jtaleric/k8s-io/pkg/results"
 	"github.com/jtaleric/k8s-io/pkg/workloads/fio"
 	"github.com/jtaleric/k8s-io/pkg/workloads/hammerdb"
+	"github.com/jtaleric/k8s-io/pkg/workloads/provision"
 )
 
 // Workload represents a benchmark workload
@@ -20,12 +24,34 @@ type Workload interface {
 	// Validate validates the workload configuration
 	Validate() error
 
+	// Fingerprint returns a canonical hash of the effective workload configuration
+	Fingerprint() (string, error)
+
 	// GenerateManifests generates all Kubernetes manifests for the workload
 	GenerateManifests() (map[string]string, error)
 
+	// Estimate returns the cluster footprint and expected duration of the benchmark
+	Estimate() (*estimate.Estimate, error)
+
+	// Phases returns the phases the benchmark goes through, used to review a plan
+	Phases() []plan.Phase
+
+	// Namespaces returns every namespace the benchmark creates resources in, the run
+	// namespace first
+	Namespaces() []string
+
 	// RunBenchmark executes the complete benchmark
 	RunBenchmark(ctx context.Context) error
 
+	// Progress returns the phase the benchmark is in and the percentage of its expected
+	// duration elapsed, 100 once RunBenchmark returned. It is safe to call while
+	// RunBenchmark runs.
+	Progress(ctx context.Context) (phase string, percent float64)
+
+	// CollectResults returns the results of the samples RunBenchmark captured, also after
+	// a failed run
+	CollectResults(ctx context.Context) (*results.Run, error)
+
 	// Cleanup removes all resources created by the benchmark
 	Cleanup(ctx context.Context) error
 }
@@ -51,6 +77,8 @@ func (f *Factory) CreateWorkload() (Workload, error) {
 		return f.createFIOWorkload()
 	case "hammerdb":
 		return f.createHammerDBWorkload()
+	case "provision":
+		return f.createProvisionWorkload()
 	default:
 		return nil, fmt.Errorf("unsupported workload: %s", f.config.Workload.Name)
 	}
@@ -99,3 +127,25 @@ func (f *Factory) createHammerDBWorkload() (Workload, error) {
 
 	return hammerdb.NewWorkload(f.k8sClient, f.config, &hammerdbConfig)
 }
+
+// createProvisionWorkload creates a provisioning scale test workload
+func (f *Factory) createProvisionWorkload() (Workload, error) {
+	// Marshal the args back to YAML and unmarshal to ProvisionConfig
+	argsData, err := yaml.Marshal(f.config.Workload.Args)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal provision args: %w", err)
+	}
+
+	var provisionConfig provision.ProvisionConfig
+	if err := yaml.Unmarshal(argsData, &provisionConfig); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal provision config: %w", err)
+	}
+
+	// Set defaults and validate
+	provisionConfig.SetDefaults()
+	if err := provisionConfig.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid provision configuration: %w", err)
+	}
+
+	return provision.NewWorkload(f.k8sClient, f.config, &provisionConfig)
+}
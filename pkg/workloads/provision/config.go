@@ -0,0 +1,157 @@
+package provision
+
+import (
+	_ "embed"
+	"fmt"
+	"regexp"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// ConfigSource is the source of this file, used to document the configuration fields
+//
+//go:embed config.go
+var ConfigSource string
+
+// ProvisionConfig represents the parameters of the provisioning scale test
+type ProvisionConfig struct {
+	// Scale of the test, namespaces x pvcs_per_namespace claims in total
+	Namespaces       int    `yaml:"namespaces"`         // Namespaces created for the test
+	PVCsPerNamespace int    `yaml:"pvcs_per_namespace"` // PVCs, and pods mounting them, in every namespace
+	NamespacePrefix  string `yaml:"namespace_prefix"`   // Namespaces are named <prefix>-<uuid>-<index>
+
+	// Creation of the claims and pods
+	Concurrency int     `yaml:"concurrency"` // Objects created at the same time
+	ClientQPS   float32 `yaml:"client_qps"`  // Client-side API rate limit, with a burst of twice as many requests
+
+	// PVC settings
+	StorageClass string `yaml:"storageclass,omitempty"` // Storage class of the claims, the cluster default when empty
+	StorageSize  string `yaml:"storage_size"`           // Size of every claim
+	AccessMode   string `yaml:"access_mode"`            // PVC access mode
+	VolumeMode   string `yaml:"volume_mode"`            // "Filesystem" or "Block"
+
+	// Pod settings
+	Pods         *bool             `yaml:"pods,omitempty"`  // Start a pod mounting every claim, true by default
+	Image        string            `yaml:"image,omitempty"` // Image of the pods, which only hold the volume
+	NodeSelector map[string]string `yaml:"nodeselector,omitempty"`
+
+	// Outcome of the test
+	Timeout           int     `yaml:"timeout"`                       // Seconds to wait for the claims to bind and the pods to be ready
+	MaxFailurePercent float64 `yaml:"max_failure_percent,omitempty"` // Failed claims and pods tolerated, in percent of all objects
+}
+
+// namespacePrefixPattern matches prefixes that leave valid namespace names
+var namespacePrefixPattern = regexp.MustCompile(`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`)
+
+// SetDefaults sets default values for the provisioning configuration
+func (p *ProvisionConfig) SetDefaults() {
+	if p.Namespaces == 0 {
+		p.Namespaces = 10
+	}
+
+	if p.PVCsPerNamespace == 0 {
+		p.PVCsPerNamespace = 5
+	}
+
+	if p.NamespacePrefix == "" {
+		p.NamespacePrefix = "k8s-io-scale"
+	}
+
+	if p.Concurrency == 0 {
+		p.Concurrency = 20
+	}
+
+	if p.ClientQPS == 0 {
+		p.ClientQPS = 50
+	}
+
+	if p.StorageSize == "" {
+		p.StorageSize = "1Gi"
+	}
+
+	if p.AccessMode == "" {
+		p.AccessMode = "ReadWriteOnce"
+	}
+
+	if p.VolumeMode == "" {
+		p.VolumeMode = "Filesystem"
+	}
+
+	if p.Pods == nil {
+		pods := true
+		p.Pods = &pods
+	}
+
+	if p.Image == "" {
+		p.Image = "registry.k8s.io/pause:3.9"
+	}
+
+	if p.Timeout == 0 {
+		p.Timeout = 1800
+	}
+}
+
+// Validate validates the provisioning configuration
+func (p *ProvisionConfig) Validate() error {
+	if p.Namespaces <= 0 {
+		return fmt.Errorf("namespaces must be greater than 0")
+	}
+
+	if p.PVCsPerNamespace <= 0 {
+		return fmt.Errorf("pvcs_per_namespace must be greater than 0")
+	}
+
+	// <prefix>-<8 character uuid>-<index> must fit the 63 characters of a namespace name
+	if !namespacePrefixPattern.MatchString(p.NamespacePrefix) {
+		return fmt.Errorf("namespace_prefix %q must consist of lower case alphanumeric characters or '-'", p.NamespacePrefix)
+	}
+	if max := 63 - len(fmt.Sprintf("-12345678-%d", p.Namespaces)); len(p.NamespacePrefix) > max {
+		return fmt.Errorf("namespace_prefix must be at most %d characters for %d namespaces", max, p.Namespaces)
+	}
+
+	if p.Concurrency <= 0 {
+		return fmt.Errorf("concurrency must be greater than 0")
+	}
+
+	if p.ClientQPS < 0 {
+		return fmt.Errorf("client_qps must be positive")
+	}
+
+	if _, err := resource.ParseQuantity(p.StorageSize); err != nil {
+		return fmt.Errorf("invalid storage_size %q: %w", p.StorageSize, err)
+	}
+
+	switch p.AccessMode {
+	case "ReadWriteOnce", "ReadWriteOncePod", "ReadWriteMany", "ReadOnlyMany":
+	default:
+		return fmt.Errorf("access_mode must be one of: ReadWriteOnce, ReadWriteOncePod, ReadWriteMany, ReadOnlyMany")
+	}
+
+	if p.VolumeMode != "Filesystem" && p.VolumeMode != "Block" {
+		return fmt.Errorf("volume_mode must be either 'Filesystem' or 'Block'")
+	}
+
+	if p.Timeout <= 0 {
+		return fmt.Errorf("timeout must be greater than 0")
+	}
+
+	if p.MaxFailurePercent < 0 || p.MaxFailurePercent > 100 {
+		return fmt.Errorf("max_failure_percent must be between 0 and 100")
+	}
+
+	return nil
+}
+
+// PodsEnabled reports whether a pod mounts every claim
+func (p *ProvisionConfig) PodsEnabled() bool {
+	return p.Pods == nil || *p.Pods
+}
+
+// Objects returns the number of claims and pods the test creates
+func (p *ProvisionConfig) Objects() int {
+	claims := p.Namespaces * p.PVCsPerNamespace
+	if p.PodsEnabled() {
+		return 2 * claims
+	}
+	return claims
+}
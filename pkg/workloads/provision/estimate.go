@@ -0,0 +1,24 @@
+package provision
+
+import (
+	"fmt"
+
+	"github.com/jtaleric/k8s-io/pkg/estimate"
+)
+
+// Estimate returns the claims and pods of the scale test. How long provisioning takes is
+// what the test measures, so only the timeout bounds its duration.
+func (w *Workload) Estimate() (*estimate.Estimate, error) {
+	manifests, err := w.GenerateManifests()
+	if err != nil {
+		return nil, err
+	}
+
+	e, err := estimate.FromManifests(manifests)
+	if err != nil {
+		return nil, err
+	}
+
+	e.Notes = append(e.Notes, fmt.Sprintf("provisioning takes as long as the storage class needs, up to the %ds timeout", w.provisionConfig.Timeout))
+	return e, nil
+}
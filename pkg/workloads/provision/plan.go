@@ -0,0 +1,37 @@
+package provision
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/jtaleric/k8s-io/pkg/plan"
+)
+
+// Phases returns the phases RunBenchmark goes through, with the claims and pods it creates
+func (w *Workload) Phases() []plan.Phase {
+	var applies []string
+	for _, object := range w.plannedObjects() {
+		applies = append(applies, object.manifestName())
+	}
+	sort.Strings(applies)
+
+	claims := w.provisionConfig.Namespaces * w.provisionConfig.PVCsPerNamespace
+	create := plan.Phase{
+		Name: "create",
+		Description: fmt.Sprintf("create %d claims in %d namespaces, %d at a time",
+			claims, w.provisionConfig.Namespaces, w.provisionConfig.Concurrency),
+		Applies: applies,
+	}
+	waitFor := fmt.Sprintf("%d claims bound", claims)
+	if w.provisionConfig.PodsEnabled() {
+		create.Description += ", each with a pod mounting it"
+		waitFor += fmt.Sprintf(" and %d pods ready", claims)
+	}
+
+	return []plan.Phase{create, {
+		Name:        "provision",
+		Description: "measure the binding of the claims and the scheduling and readiness of the pods",
+		Waits:       []plan.Wait{{For: waitFor, Timeout: (time.Duration(w.provisionConfig.Timeout) * time.Second).String()}},
+	}}
+}
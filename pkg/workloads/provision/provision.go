@@ -0,0 +1,352 @@
+package provision
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/jtaleric/k8s-io/pkg/kubernetes"
+)
+
+// Kinds of the objects of the scale test
+const (
+	KindPVC = "PersistentVolumeClaim"
+	KindPod = "Pod"
+)
+
+// pollInterval is how often the claims and pods are listed while waiting for them
+const pollInterval = 2 * time.Second
+
+// Object is a claim or pod of the scale test and its outcome. The times are taken from the
+// API server, so the latencies do not depend on the clock of the machine running k8s-io.
+type Object struct {
+	Kind      string    `json:"kind"`
+	Namespace string    `json:"namespace"`
+	Name      string    `json:"name"`
+	Created   time.Time `json:"created"`
+	Scheduled time.Time `json:"scheduled"`      // Pods only
+	Ready     time.Time `json:"ready"`          // Creation of the bound volume for claims, readiness for pods
+	Node      string    `json:"node,omitempty"` // Pods only
+	Done      bool      `json:"done"`           // Bound or ready
+	Error     string    `json:"error,omitempty"`
+
+	index int
+}
+
+// manifestName returns the name of the manifest of the object
+func (o Object) manifestName() string {
+	kind := "pvc"
+	if o.Kind == KindPod {
+		kind = "pod"
+	}
+	return fmt.Sprintf("provision-%s-%s-%d", kind, o.Namespace, o.index)
+}
+
+// key returns the namespace and name of the object
+func (o Object) key() string {
+	return o.Namespace + "/" + o.Name
+}
+
+// final reports whether the object will not change anymore
+func (o Object) final() bool {
+	return o.Done || o.Error != ""
+}
+
+// Latency returns how long the object took from creation to bound or ready, ok is false
+// when it did not get there or the times are not known. The API server times have a
+// resolution of a second, so objects done within a second have a latency of zero.
+func (o Object) Latency() (latency time.Duration, ok bool) {
+	if o.Created.IsZero() || o.Ready.IsZero() || o.Ready.Before(o.Created) {
+		return 0, false
+	}
+	return o.Ready.Sub(o.Created), true
+}
+
+// SchedulingLatency returns how long a pod waited for a node, ok is false when it is not known
+func (o Object) SchedulingLatency() (latency time.Duration, ok bool) {
+	if o.Created.IsZero() || o.Scheduled.IsZero() || o.Scheduled.Before(o.Created) {
+		return 0, false
+	}
+	return o.Scheduled.Sub(o.Created), true
+}
+
+// createObjects creates the claims and pods with the configured number of concurrent
+// workers. Objects the API server rejects, such as by a quota, fail on their own; only a
+// cancelled run fails the phase.
+func (w *Workload) createObjects(ctx context.Context, client *kubernetes.Client) error {
+	log.Printf("Creating %d claims and pods in %d namespaces with %d workers...",
+		len(w.objects), w.provisionConfig.Namespaces, w.provisionConfig.Concurrency)
+	start := time.Now()
+
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for i := 0; i < w.provisionConfig.Concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				object := &w.objects[i]
+				manifest, err := w.render(*object)
+				if err == nil {
+					err = client.ApplyManifest(ctx, manifest, object.Namespace)
+				}
+				if err != nil {
+					object.Error = fmt.Sprintf("create: %v", err)
+					w.done.Add(1)
+				}
+			}
+		}()
+	}
+
+	for i := range w.objects {
+		select {
+		case indexes <- i:
+		case <-ctx.Done():
+			close(indexes)
+			wg.Wait()
+			return ctx.Err()
+		}
+	}
+	close(indexes)
+	wg.Wait()
+
+	failed := 0
+	for _, object := range w.objects {
+		if object.Error != "" {
+			failed++
+		}
+	}
+	elapsed := time.Since(start)
+	log.Printf("Created %d of %d objects in %s (%.1f/s)", len(w.objects)-failed, len(w.objects),
+		elapsed.Round(time.Millisecond), float64(len(w.objects))/elapsed.Seconds())
+	if failed == len(w.objects) {
+		return fmt.Errorf("the API server rejected every claim and pod, first: %s", w.objects[0].Error)
+	}
+	return nil
+}
+
+// waitForObjects lists the claims and pods until every claim is bound and every pod is
+// ready, a pod failed, or the timeout expired. Objects still pending at the timeout fail
+// with their state, and fail the run through max_failure_percent rather than here.
+func (w *Workload) waitForObjects(ctx context.Context, client *kubernetes.Client, timeout time.Duration) error {
+	log.Printf("Waiting up to %s for the claims to bind and the pods to be ready...", timeout)
+	selector := fmt.Sprintf("app=provision-%s", w.config.GetTruncatedUUID())
+	byKey := make(map[string]*Object, len(w.objects))
+	for i := range w.objects {
+		byKey[w.objects[i].Kind+"/"+w.objects[i].key()] = &w.objects[i]
+	}
+
+	deadline := time.Now().Add(timeout)
+	pvsListed := true
+	lastLog := time.Now()
+	for {
+		pvcs, err := client.ListPVCs(ctx, "", selector)
+		if err != nil {
+			if !kubernetes.Retryable(err) {
+				return fmt.Errorf("failed to list claims: %w", err)
+			}
+			log.Printf("Warning: Transient error listing claims (will retry): %v", err)
+		}
+		var pods *corev1.PodList
+		if err == nil && w.provisionConfig.PodsEnabled() {
+			pods, err = client.ListPods(ctx, "", selector)
+			if err != nil && !kubernetes.Retryable(err) {
+				return fmt.Errorf("failed to list pods: %w", err)
+			}
+		}
+
+		if err == nil {
+			// Claims are bound once their volume was created, so the volumes are only
+			// listed when a claim is bound and its volume not known yet
+			var volumes map[string]time.Time
+			if pvsListed && w.boundWithoutVolume(pvcs, byKey) {
+				volumes, err = listVolumeCreation(ctx, client)
+				if err != nil {
+					log.Printf("Warning: the claims have no binding latency without the persistent volumes: %v", err)
+					pvsListed = false
+				}
+			}
+			w.updateClaims(pvcs, volumes, byKey, pvsListed)
+			if pods != nil {
+				w.updatePods(pods, byKey)
+			}
+		}
+
+		pending := 0
+		for _, object := range w.objects {
+			if !object.final() {
+				pending++
+			}
+		}
+		if pending == 0 {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			w.failPending(timeout)
+			log.Printf("Warning: %d claims and pods were not bound or ready after %s", pending, timeout)
+			return nil
+		}
+		if time.Since(lastLog) >= time.Minute {
+			log.Printf("Waiting for %d of %d claims and pods", pending, len(w.objects))
+			lastLog = time.Now()
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(pollInterval):
+		}
+	}
+}
+
+// boundWithoutVolume reports whether a claim is bound whose volume creation is not known yet
+func (w *Workload) boundWithoutVolume(pvcs *corev1.PersistentVolumeClaimList, byKey map[string]*Object) bool {
+	for _, pvc := range pvcs.Items {
+		object := byKey[KindPVC+"/"+pvc.Namespace+"/"+pvc.Name]
+		if object != nil && !object.final() && pvc.Status.Phase == corev1.ClaimBound {
+			return true
+		}
+	}
+	return false
+}
+
+// listVolumeCreation returns when the volume bound to every claim was created, by claim
+func listVolumeCreation(ctx context.Context, client *kubernetes.Client) (map[string]time.Time, error) {
+	pvs, err := client.ListPVs(ctx)
+	if err != nil {
+		return nil, err
+	}
+	volumes := make(map[string]time.Time, len(pvs.Items))
+	for _, pv := range pvs.Items {
+		if ref := pv.Spec.ClaimRef; ref != nil {
+			volumes[ref.Namespace+"/"+ref.Name] = pv.CreationTimestamp.Time
+		}
+	}
+	return volumes, nil
+}
+
+// updateClaims records the creation and binding of the claims
+func (w *Workload) updateClaims(pvcs *corev1.PersistentVolumeClaimList, volumes map[string]time.Time, byKey map[string]*Object, pvsListed bool) {
+	for _, pvc := range pvcs.Items {
+		object := byKey[KindPVC+"/"+pvc.Namespace+"/"+pvc.Name]
+		if object == nil || object.final() {
+			continue
+		}
+		object.Created = pvc.CreationTimestamp.Time
+
+		switch pvc.Status.Phase {
+		case corev1.ClaimBound:
+			created, ok := volumes[object.key()]
+			if !ok && pvsListed {
+				// Bound after the volumes were listed, its volume is picked up next time
+				continue
+			}
+			object.Ready = created
+			object.Done = true
+			w.done.Add(1)
+		case corev1.ClaimLost:
+			object.Error = "claim lost its volume"
+			w.done.Add(1)
+		}
+	}
+}
+
+// updatePods records the creation, scheduling and readiness of the pods
+func (w *Workload) updatePods(pods *corev1.PodList, byKey map[string]*Object) {
+	for _, pod := range pods.Items {
+		object := byKey[KindPod+"/"+pod.Namespace+"/"+pod.Name]
+		if object == nil || object.final() {
+			continue
+		}
+		object.Created = pod.CreationTimestamp.Time
+		object.Node = pod.Spec.NodeName
+
+		for _, condition := range pod.Status.Conditions {
+			if condition.Status != corev1.ConditionTrue {
+				continue
+			}
+			switch condition.Type {
+			case corev1.PodScheduled:
+				object.Scheduled = condition.LastTransitionTime.Time
+			case corev1.PodReady:
+				object.Ready = condition.LastTransitionTime.Time
+				object.Done = true
+			}
+		}
+
+		if pod.Status.Phase == corev1.PodFailed {
+			object.Error = fmt.Sprintf("pod failed: %s", podStateReason(&pod))
+		}
+		if object.final() {
+			w.done.Add(1)
+		}
+	}
+}
+
+// failPending fails the claims and pods that were not bound or ready at the timeout with
+// their last state
+func (w *Workload) failPending(timeout time.Duration) {
+	for i := range w.objects {
+		object := &w.objects[i]
+		if object.final() {
+			continue
+		}
+		switch {
+		case object.Created.IsZero():
+			object.Error = fmt.Sprintf("not found after %s", timeout)
+		case object.Kind == KindPVC:
+			object.Error = fmt.Sprintf("not bound after %s", timeout)
+		case object.Scheduled.IsZero():
+			object.Error = fmt.Sprintf("not scheduled after %s", timeout)
+		default:
+			object.Error = fmt.Sprintf("not ready after %s", timeout)
+		}
+	}
+}
+
+// podStateReason returns why a pod is not running, from its containers or its status
+func podStateReason(pod *corev1.Pod) string {
+	for _, container := range pod.Status.ContainerStatuses {
+		if t := container.State.Terminated; t != nil {
+			return fmt.Sprintf("%s (exit code %d)", t.Reason, t.ExitCode)
+		}
+		if w := container.State.Waiting; w != nil && w.Reason != "" {
+			return w.Reason
+		}
+	}
+	if pod.Status.Reason != "" {
+		return pod.Status.Reason
+	}
+	return string(pod.Status.Phase)
+}
+
+// countWarningEvents counts the warning events of the claims and pods by reason, such as
+// ProvisioningFailed, FailedScheduling, FailedAttachVolume and FailedMount. Objects that
+// were retried until they succeeded count here too.
+func (w *Workload) countWarningEvents(ctx context.Context, client *kubernetes.Client) map[string]int {
+	prefix := fmt.Sprintf("scale-%s-", w.config.GetTruncatedUUID())
+	counts := make(map[string]int)
+	for _, ns := range w.scaleNamespaces() {
+		events, err := client.ListWarningEvents(ctx, ns)
+		if err != nil {
+			log.Printf("Warning: failed to list the events of namespace %s: %v", ns, err)
+			continue
+		}
+		for _, event := range events.Items {
+			if !strings.HasPrefix(event.InvolvedObject.Name, prefix) {
+				continue
+			}
+			count := int(event.Count)
+			if count == 0 {
+				count = 1
+			}
+			counts[event.Reason] += count
+		}
+	}
+	return counts
+}
@@ -0,0 +1,296 @@
+package provision
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	"github.com/jtaleric/k8s-io/pkg/anonymize"
+	"github.com/jtaleric/k8s-io/pkg/results"
+	"github.com/jtaleric/k8s-io/pkg/units"
+)
+
+// Latencies are the percentiles of the latencies of a kind of object, in nanoseconds in JSON
+type Latencies struct {
+	Count int           `json:"count"`
+	P50   time.Duration `json:"p50"`
+	P95   time.Duration `json:"p95"`
+	P99   time.Duration `json:"p99"`
+	Max   time.Duration `json:"max"`
+}
+
+// newLatencies returns the percentiles of latencies, by nearest rank
+func newLatencies(latencies []time.Duration) Latencies {
+	if len(latencies) == 0 {
+		return Latencies{}
+	}
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	rank := func(p float64) time.Duration {
+		return latencies[int(math.Ceil(p/100*float64(len(latencies))))-1]
+	}
+	return Latencies{Count: len(latencies), P50: rank(50), P95: rank(95), P99: rank(99), Max: latencies[len(latencies)-1]}
+}
+
+// Summary is the outcome of the scale test
+type Summary struct {
+	Objects         int            `json:"objects"`
+	Failed          int            `json:"failed"`
+	FailedPercent   float64        `json:"failedPercent"`
+	Claims          int            `json:"claims"`
+	Bound           int            `json:"bound"`
+	Pods            int            `json:"pods"`
+	Ready           int            `json:"ready"`
+	ClaimsPerSecond float64        `json:"claimsPerSecond,omitempty"` // Claims bound from the first creation to the last binding
+	PodsPerSecond   float64        `json:"podsPerSecond,omitempty"`   // Pods ready from the first creation to the last readiness
+	Binding         Latencies      `json:"binding"`
+	Scheduling      Latencies      `json:"scheduling"`
+	Readiness       Latencies      `json:"readiness"`
+	WarningEvents   map[string]int `json:"warningEvents,omitempty"` // By reason
+	Errors          map[string]int `json:"errors,omitempty"`        // Failed objects by error
+}
+
+// summarize computes the throughput, latencies and error rate of the claims and pods
+func (w *Workload) summarize() Summary {
+	s := Summary{Objects: len(w.objects), WarningEvents: w.events}
+	var binding, scheduling, readiness []time.Duration
+	var claimSpan, podSpan span
+	for _, object := range w.objects {
+		if object.Error != "" {
+			s.Failed++
+			if s.Errors == nil {
+				s.Errors = make(map[string]int)
+			}
+			s.Errors[object.Kind+" "+object.Error]++
+		}
+
+		if object.Kind == KindPVC {
+			s.Claims++
+			if object.Done {
+				s.Bound++
+			}
+			if latency, ok := object.Latency(); ok {
+				binding = append(binding, latency)
+				claimSpan.add(object)
+			}
+			continue
+		}
+
+		s.Pods++
+		if object.Done {
+			s.Ready++
+		}
+		if latency, ok := object.SchedulingLatency(); ok {
+			scheduling = append(scheduling, latency)
+		}
+		if latency, ok := object.Latency(); ok {
+			readiness = append(readiness, latency)
+			podSpan.add(object)
+		}
+	}
+
+	if s.Objects > 0 {
+		s.FailedPercent = 100 * float64(s.Failed) / float64(s.Objects)
+	}
+	s.ClaimsPerSecond = claimSpan.rate()
+	s.PodsPerSecond = podSpan.rate()
+	s.Binding = newLatencies(binding)
+	s.Scheduling = newLatencies(scheduling)
+	s.Readiness = newLatencies(readiness)
+	return s
+}
+
+// span is the window from the first creation to the last binding or readiness of objects
+type span struct {
+	first, last time.Time
+	count       int
+}
+
+// add extends the span by an object that got bound or ready
+func (s *span) add(object Object) {
+	if s.first.IsZero() || object.Created.Before(s.first) {
+		s.first = object.Created
+	}
+	if object.Ready.After(s.last) {
+		s.last = object.Ready
+	}
+	s.count++
+}
+
+// rate returns the objects per second over the span. The API server times have a resolution
+// of a second, so the span is at least a second.
+func (s *span) rate() float64 {
+	if s.count == 0 {
+		return 0
+	}
+	return float64(s.count) / math.Max(1, s.last.Sub(s.first).Seconds())
+}
+
+// Metrics returns the metrics of the summary, named like the history metrics
+func (s Summary) Metrics() map[string]float64 {
+	m := map[string]float64{
+		"provision.failed_percent": s.FailedPercent,
+	}
+	if s.ClaimsPerSecond > 0 {
+		m["provision.claims_per_sec"] = s.ClaimsPerSecond
+	}
+	if s.PodsPerSecond > 0 {
+		m["provision.pods_per_sec"] = s.PodsPerSecond
+	}
+	for name, l := range map[string]Latencies{"bind": s.Binding, "schedule": s.Scheduling, "ready": s.Readiness} {
+		if l.Count == 0 {
+			continue
+		}
+		m["provision."+name+"_p50_us"] = float64(l.P50.Microseconds())
+		m["provision."+name+"_p95_us"] = float64(l.P95.Microseconds())
+		m["provision."+name+"_p99_us"] = float64(l.P99.Microseconds())
+		m["provision."+name+"_max_us"] = float64(l.Max.Microseconds())
+	}
+	warnings := 0
+	for _, count := range s.WarningEvents {
+		warnings += count
+	}
+	m["provision.warning_events"] = float64(warnings)
+	return m
+}
+
+// CollectResults returns the throughput, latencies and error rate of the scale test as a
+// single sample
+func (w *Workload) CollectResults(ctx context.Context) (*results.Run, error) {
+	run := &results.Run{
+		UUID:     w.config.UUID,
+		Workload: w.GetName(),
+		Metrics:  make(map[string]float64),
+	}
+	if w.config.Clock != nil {
+		run.Start = w.config.Clock.Start
+	}
+	if len(w.objects) == 0 {
+		return run, nil
+	}
+
+	summary := w.summarize()
+	run.Samples = 1
+	run.Metrics = summary.Metrics()
+	if summary.FailedPercent > w.provisionConfig.MaxFailurePercent {
+		run.Violations = append(run.Violations, fmt.Sprintf("sample 1: %d of %d claims and pods failed (%.1f%%), more than the %.1f%% tolerated",
+			summary.Failed, summary.Objects, summary.FailedPercent, w.provisionConfig.MaxFailurePercent))
+	}
+	return run, nil
+}
+
+// provisionReport is the provision.json artifact of a run
+type provisionReport struct {
+	Namespaces       int      `json:"namespaces"`
+	PVCsPerNamespace int      `json:"pvcsPerNamespace"`
+	StorageClass     string   `json:"storageClass,omitempty"`
+	Concurrency      int      `json:"concurrency"`
+	Summary          Summary  `json:"summary"`
+	Objects          []Object `json:"objects"`
+}
+
+// report prints the throughput, latencies and errors of the scale test, records them in
+// the run metadata and writes every claim and pod to provision.json
+func (w *Workload) report(s Summary) error {
+	mode, _ := units.ParseMode(w.config.Units)
+
+	fmt.Println("\n=== Provisioning Scale Test ===")
+	fmt.Printf("Claims bound: %d of %d", s.Bound, s.Claims)
+	if s.ClaimsPerSecond > 0 {
+		fmt.Printf(" (%s/s)", units.Number(s.ClaimsPerSecond, 2))
+	}
+	fmt.Println()
+	if s.Pods > 0 {
+		fmt.Printf("Pods ready:   %d of %d", s.Ready, s.Pods)
+		if s.PodsPerSecond > 0 {
+			fmt.Printf(" (%s/s)", units.Number(s.PodsPerSecond, 2))
+		}
+		fmt.Println()
+	}
+	fmt.Printf("Failed:       %d of %d (%.1f%%)\n\n", s.Failed, s.Objects, s.FailedPercent)
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "Latency\tCount\t%s\t%s\t%s\t%s\n", units.LatencyHeader("P50", mode), units.LatencyHeader("P95", mode),
+		units.LatencyHeader("P99", mode), units.LatencyHeader("Max", mode))
+	for _, row := range []struct {
+		name string
+		l    Latencies
+	}{
+		{"Claim binding", s.Binding},
+		{"Pod scheduling", s.Scheduling},
+		{"Pod ready", s.Readiness},
+	} {
+		if row.l.Count == 0 {
+			continue
+		}
+		fmt.Fprintf(tw, "%s\t%d\t%s\t%s\t%s\t%s\n", row.name, row.l.Count,
+			units.Latency(float64(row.l.P50.Microseconds()), mode), units.Latency(float64(row.l.P95.Microseconds()), mode),
+			units.Latency(float64(row.l.P99.Microseconds()), mode), units.Latency(float64(row.l.Max.Microseconds()), mode))
+	}
+	tw.Flush()
+
+	if len(s.WarningEvents) > 0 {
+		fmt.Println("\nWarning events:")
+		for _, reason := range sortedKeys(s.WarningEvents) {
+			fmt.Printf("  %-24s %d\n", reason, s.WarningEvents[reason])
+		}
+	}
+	if len(s.Errors) > 0 {
+		fmt.Println("\nFailures:")
+		for _, reason := range sortedKeys(s.Errors) {
+			fmt.Printf("  %4d  %s\n", s.Errors[reason], reason)
+		}
+	}
+
+	if w.config.Metadata == nil {
+		w.config.Metadata = make(map[string]string)
+	}
+	w.config.Metadata["provisionScale"] = fmt.Sprintf("%dx%d", w.provisionConfig.Namespaces, w.provisionConfig.PVCsPerNamespace)
+	w.config.Metadata["provisionFailures"] = fmt.Sprintf("%d/%d", s.Failed, s.Objects)
+
+	objects := append([]Object(nil), w.objects...)
+	if w.config.Anonymize {
+		a := anonymize.New(w.config.UUID)
+		for i := range objects {
+			if objects[i].Node != "" {
+				objects[i].Node = a.Pseudonym(anonymize.KindNode, objects[i].Node)
+			}
+		}
+	}
+
+	dir := w.config.RunArtifactsDir()
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create artifacts directory: %w", err)
+	}
+	data, err := json.MarshalIndent(provisionReport{
+		Namespaces:       w.provisionConfig.Namespaces,
+		PVCsPerNamespace: w.provisionConfig.PVCsPerNamespace,
+		StorageClass:     w.provisionConfig.StorageClass,
+		Concurrency:      w.provisionConfig.Concurrency,
+		Summary:          s,
+		Objects:          objects,
+	}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode provisioning report: %w", err)
+	}
+	filename := filepath.Join(dir, "provision.json")
+	if err := os.WriteFile(filename, data, 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", filename, err)
+	}
+	return nil
+}
+
+// sortedKeys returns the keys of counts, sorted
+func sortedKeys(counts map[string]int) []string {
+	keys := make([]string, 0, len(counts))
+	for key := range counts {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,99 @@
+package provision
+
+import (
+	"embed"
+	"fmt"
+	"sync"
+
+	"github.com/flosch/pongo2/v6"
+	"github.com/jtaleric/k8s-io/pkg/config"
+	"github.com/jtaleric/k8s-io/pkg/templatedebug"
+)
+
+//go:embed templates/*.j2
+var embeddedTemplates embed.FS
+
+// TemplateEngine handles the templates of the provisioning scale test
+type TemplateEngine struct {
+	templateSet *pongo2.TemplateSet
+	debug       *templatedebug.Dumper
+
+	mu        sync.Mutex
+	templates map[string]*pongo2.Template // Compiled once, every claim and pod is rendered from them
+}
+
+// NewTemplateEngine creates a new provisioning template engine
+func NewTemplateEngine() *TemplateEngine {
+	return &TemplateEngine{
+		templateSet: pongo2.NewSet("provision-templates", nil),
+		templates:   make(map[string]*pongo2.Template),
+	}
+}
+
+// EnableDebug writes the context and template text of every rendered manifest to dir
+func (e *TemplateEngine) EnableDebug(dir string) {
+	e.debug = templatedebug.NewDumper(dir)
+}
+
+// loadTemplate returns the compiled template of an embedded file
+func (e *TemplateEngine) loadTemplate(templatePath string) (*pongo2.Template, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if template, ok := e.templates[templatePath]; ok {
+		return template, nil
+	}
+
+	content, err := embeddedTemplates.ReadFile("templates/" + templatePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read embedded template file %s: %w", templatePath, err)
+	}
+	template, err := e.templateSet.FromString(string(content))
+	if err != nil {
+		return nil, fmt.Errorf("failed to compile template %s: %w", templatePath, err)
+	}
+	e.templates[templatePath] = template
+	return template, nil
+}
+
+// RenderTemplate renders a template with the given context. It is safe to call from the
+// workers creating the objects.
+func (e *TemplateEngine) RenderTemplate(templatePath string, context pongo2.Context) (string, error) {
+	var rendered string
+	template, err := e.loadTemplate(templatePath)
+	if err == nil {
+		rendered, err = template.Execute(context)
+		if err != nil {
+			err = fmt.Errorf("failed to render template %s: %w", templatePath, err)
+		}
+	}
+
+	if e.debug != nil {
+		source, _ := embeddedTemplates.ReadFile("templates/" + templatePath)
+		e.debug.Dump(templatePath, string(source), string(source), context, rendered, err)
+	}
+
+	return rendered, err
+}
+
+// createContext creates the context of the claim or pod with an index in a namespace
+func (e *TemplateEngine) createContext(cfg *config.Config, provisionConfig *ProvisionConfig, namespace string, index int) pongo2.Context {
+	return pongo2.Context{
+		"uuid":          cfg.UUID,
+		"trunc_uuid":    cfg.GetTruncatedUUID(),
+		"namespace":     namespace,
+		"index":         index,
+		"workload_name": "provision",
+		"workload_args": provisionConfig,
+	}
+}
+
+// RenderPVC renders a claim of the scale test
+func (e *TemplateEngine) RenderPVC(cfg *config.Config, provisionConfig *ProvisionConfig, namespace string, index int) (string, error) {
+	return e.RenderTemplate("pvc.yml.j2", e.createContext(cfg, provisionConfig, namespace, index))
+}
+
+// RenderPod renders the pod mounting a claim of the scale test
+func (e *TemplateEngine) RenderPod(cfg *config.Config, provisionConfig *ProvisionConfig, namespace string, index int) (string, error) {
+	return e.RenderTemplate("pod.yml.j2", e.createContext(cfg, provisionConfig, namespace, index))
+}
@@ -0,0 +1,236 @@
+package provision
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"path/filepath"
+	"sort"
+	"sync/atomic"
+	"time"
+
+	"github.com/jtaleric/k8s-io/pkg/config"
+	"github.com/jtaleric/k8s-io/pkg/grafana"
+	"github.com/jtaleric/k8s-io/pkg/kubernetes"
+	"github.com/jtaleric/k8s-io/pkg/metrics"
+	"github.com/jtaleric/k8s-io/pkg/status"
+	"github.com/jtaleric/k8s-io/pkg/timeline"
+)
+
+// Workload implements the provisioning scale test, which creates claims and pods in many
+// namespaces at once to stress the CSI provisioner and the scheduler
+type Workload struct {
+	k8sClient       *kubernetes.Client
+	templateEngine  *TemplateEngine
+	config          *config.Config
+	provisionConfig *ProvisionConfig
+	objects         []Object // Claims and pods of the test, in creation order
+	events          map[string]int
+	done            atomic.Int64 // Objects that reached their final state, for Progress
+	timeline        timeline.Timeline
+	capturer        metrics.Capturer // Started with the run, published with the timeline
+}
+
+// NewWorkload creates a new provisioning scale test workload
+func NewWorkload(k8sClient *kubernetes.Client, cfg *config.Config, provisionConfig *ProvisionConfig) (*Workload, error) {
+	templateEngine := NewTemplateEngine()
+	if cfg.DebugTemplates {
+		templateEngine.EnableDebug(filepath.Join(cfg.RunArtifactsDir(), "templates"))
+	}
+
+	return &Workload{
+		k8sClient:       k8sClient,
+		templateEngine:  templateEngine,
+		config:          cfg,
+		provisionConfig: provisionConfig,
+	}, nil
+}
+
+// GetName returns the workload name
+func (w *Workload) GetName() string {
+	return "provision"
+}
+
+// Progress returns the running phase and the percentage of claims and pods that reached
+// their final state
+func (w *Workload) Progress(ctx context.Context) (string, float64) {
+	phase, percent := w.timeline.Progress(0)
+	if percent == 100 {
+		return phase, percent
+	}
+	return phase, 99 * float64(w.done.Load()) / float64(w.provisionConfig.Objects())
+}
+
+// Validate validates the workload configuration
+func (w *Workload) Validate() error {
+	if err := w.provisionConfig.Validate(); err != nil {
+		return err
+	}
+	if w.config.Hooks != nil && len(w.config.Hooks.PostSample) > 0 {
+		return fmt.Errorf("post_sample hooks are only supported by the fio workload")
+	}
+	return nil
+}
+
+// Fingerprint returns the canonical hash of the effective provisioning configuration
+func (w *Workload) Fingerprint() (string, error) {
+	return w.config.Fingerprint(w.provisionConfig)
+}
+
+// scaleNamespaces returns the namespaces the claims and pods are created in
+func (w *Workload) scaleNamespaces() []string {
+	namespaces := make([]string, w.provisionConfig.Namespaces)
+	for i := range namespaces {
+		namespaces[i] = fmt.Sprintf("%s-%s-%d", w.provisionConfig.NamespacePrefix, w.config.GetTruncatedUUID(), i+1)
+	}
+	return namespaces
+}
+
+// Namespaces returns the run namespace and the namespaces of the claims and pods
+func (w *Workload) Namespaces() []string {
+	return append([]string{w.config.Namespace}, w.scaleNamespaces()...)
+}
+
+// plannedObjects returns the claims and pods of the test without their outcome. Claims are
+// interleaved across the namespaces, so concurrent creations spread over all of them.
+func (w *Workload) plannedObjects() []Object {
+	namespaces := w.scaleNamespaces()
+	objects := make([]Object, 0, w.provisionConfig.Objects())
+	for i := 1; i <= w.provisionConfig.PVCsPerNamespace; i++ {
+		for _, ns := range namespaces {
+			name := fmt.Sprintf("scale-%s-%d", w.config.GetTruncatedUUID(), i)
+			objects = append(objects, Object{Kind: KindPVC, Namespace: ns, Name: name, index: i})
+			if w.provisionConfig.PodsEnabled() {
+				objects = append(objects, Object{Kind: KindPod, Namespace: ns, Name: name, index: i})
+			}
+		}
+	}
+	return objects
+}
+
+// render renders the manifest of a claim or pod
+func (w *Workload) render(object Object) (string, error) {
+	if object.Kind == KindPod {
+		return w.templateEngine.RenderPod(w.config, w.provisionConfig, object.Namespace, object.index)
+	}
+	return w.templateEngine.RenderPVC(w.config, w.provisionConfig, object.Namespace, object.index)
+}
+
+// GenerateManifests generates the manifests of every claim and pod
+func (w *Workload) GenerateManifests() (map[string]string, error) {
+	manifests := make(map[string]string)
+	for _, object := range w.plannedObjects() {
+		manifest, err := w.render(object)
+		if err != nil {
+			return nil, fmt.Errorf("failed to render %s %s/%s: %w", object.Kind, object.Namespace, object.Name, err)
+		}
+		manifests[object.manifestName()] = manifest
+	}
+	return manifests, nil
+}
+
+// RunBenchmark creates the claims and pods and waits until they are bound and ready
+func (w *Workload) RunBenchmark(ctx context.Context) error {
+	log.Println("Starting provisioning scale test execution...")
+
+	selector := fmt.Sprintf("benchmark-uuid=%s", w.config.UUID)
+	for _, ns := range w.scaleNamespaces() {
+		if err := w.k8sClient.ResolveCollisions(ctx, ns, w.config.CollisionPolicy, selector); err != nil {
+			return status.Errorf(status.ReasonPreflight, "failed to resolve resource collisions: %w", err)
+		}
+	}
+
+	client, err := w.k8sClient.WithRateLimit(w.provisionConfig.ClientQPS, 2*int(w.provisionConfig.ClientQPS))
+	if err != nil {
+		return status.Errorf(status.ReasonPreflight, "failed to create Kubernetes client: %w", err)
+	}
+
+	capturer, err := metrics.New(w.config, w.k8sClient)
+	if err != nil {
+		return status.Errorf(status.ReasonConfig, "failed to create metrics capturer: %w", err)
+	}
+	if err := capturer.Start(ctx); err != nil {
+		log.Printf("Warning: failed to start metrics capture: %v", err)
+	} else {
+		w.capturer = capturer
+	}
+
+	defer w.publishTimeline(ctx)
+
+	w.objects = w.plannedObjects()
+	w.done.Store(0)
+
+	// Phase 1: Create the claims and pods concurrently
+	if err := w.timeline.Track("create", func() error { return w.createObjects(ctx, client) }); err != nil {
+		return status.Errorf(status.ReasonDeploy, "failed to create the claims and pods: %w", err)
+	}
+
+	// Phase 2: Wait for the claims to bind and the pods to be ready
+	timeout := time.Duration(w.provisionConfig.Timeout) * time.Second
+	if err := w.timeline.Track("provision", func() error { return w.waitForObjects(ctx, client, timeout) }); err != nil {
+		return err
+	}
+
+	w.events = w.countWarningEvents(ctx, client)
+	summary := w.summarize()
+	if err := w.report(summary); err != nil {
+		log.Printf("Warning: %v", err)
+	}
+
+	if summary.FailedPercent > w.provisionConfig.MaxFailurePercent {
+		return status.Errorf(status.ReasonThreshold, "%d of %d claims and pods failed (%.1f%%), more than the %.1f%% tolerated",
+			summary.Failed, summary.Objects, summary.FailedPercent, w.provisionConfig.MaxFailurePercent)
+	}
+
+	log.Println("Provisioning scale test completed successfully!")
+	return nil
+}
+
+// publishTimeline logs the benchmark phase windows, annotates them in Grafana and captures
+// the metrics profiles over them if configured
+func (w *Workload) publishTimeline(ctx context.Context) {
+	w.timeline.Finish()
+	w.timeline.Log()
+
+	if w.config.Grafana != nil {
+		if err := grafana.AnnotatePhases(ctx, w.config, w.timeline.Phases); err != nil {
+			log.Printf("Warning: failed to create Grafana annotations: %v", err)
+		} else {
+			log.Printf("Created Grafana annotations for %d phases", len(w.timeline.Phases))
+		}
+	}
+
+	if w.capturer != nil {
+		if err := metrics.Finish(ctx, w.capturer, w.config, w.timeline.Phases); err != nil {
+			log.Printf("Warning: failed to capture metrics: %v", err)
+		}
+	}
+}
+
+// Cleanup deletes the namespaces of the claims and pods, which deletes the volumes with a
+// Delete reclaim policy, and the resources of the run in its namespace
+func (w *Workload) Cleanup(ctx context.Context) error {
+	log.Println("Cleaning up provisioning scale test resources...")
+
+	namespaces := w.scaleNamespaces()
+	sort.Strings(namespaces)
+	for _, ns := range namespaces {
+		if err := w.k8sClient.DeleteNamespace(ctx, ns); err != nil {
+			return fmt.Errorf("failed to cleanup resources: %w", err)
+		}
+	}
+	log.Printf("Deleting %d namespaces, their volumes are released in the background", len(namespaces))
+
+	labelSelector := fmt.Sprintf("benchmark-uuid=%s", w.config.UUID)
+	if err := w.k8sClient.CleanupResources(ctx, w.config.Namespace, labelSelector); err != nil {
+		return fmt.Errorf("failed to cleanup resources: %w", err)
+	}
+
+	// The service account and token for Prometheus live in its namespace
+	if err := w.k8sClient.CleanupAuxiliary(ctx, w.config.UUID); err != nil {
+		log.Printf("Warning: failed to cleanup the auxiliary resources of the run: %v", err)
+	}
+
+	log.Println("Cleanup completed")
+	return nil
+}
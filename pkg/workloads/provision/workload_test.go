@@ -0,0 +1,132 @@
+package provision
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	k8stesting "k8s.io/client-go/testing"
+
+	"github.com/jtaleric/k8s-io/pkg/config"
+	"github.com/jtaleric/k8s-io/pkg/kubernetes/kubetest"
+	"github.com/jtaleric/k8s-io/pkg/status"
+	"github.com/jtaleric/k8s-io/pkg/timeline"
+)
+
+const testConfig = `namespace: provision-test
+uuid: 89abcdef-0000-4000-8000-000000000000
+workload:
+  name: provision
+  args:
+    namespaces: 2
+    pvcs_per_namespace: 2
+    namespace_prefix: scale
+    concurrency: 2
+    storage_size: 1Gi
+    pods: true
+    timeout: 60
+`
+
+// newTestWorkload returns the workload of a config on a fake cluster, with its namespaces
+// created like main does
+func newTestWorkload(t *testing.T, cluster *kubetest.Cluster, configYAML string) *Workload {
+	t.Helper()
+	dir := t.TempDir()
+	cfg, err := config.Parse([]byte(configYAML+"artifacts_dir: "+dir+"\n"), "test.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	args, err := yaml.Marshal(cfg.Workload.Args)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var provisionConfig ProvisionConfig
+	if err := yaml.Unmarshal(args, &provisionConfig); err != nil {
+		t.Fatal(err)
+	}
+	provisionConfig.SetDefaults()
+	if err := provisionConfig.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	w, err := NewWorkload(cluster.Client, cfg, &provisionConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	labels, annotations := cfg.NamespaceMetadata()
+	for _, ns := range w.Namespaces() {
+		if err := cluster.Client.CreateNamespace(context.Background(), ns, labels, annotations); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := timeline.OpenEvents(dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(timeline.CloseEvents)
+	return w
+}
+
+func TestRunBenchmark(t *testing.T) {
+	cluster := kubetest.New()
+	w := newTestWorkload(t, cluster, testConfig)
+
+	if err := w.RunBenchmark(context.Background()); err != nil {
+		t.Fatalf("RunBenchmark failed: %v", err)
+	}
+
+	// The claims and pods are created concurrently, in any order
+	applied := cluster.Applied()[3:]
+	sort.Strings(applied)
+	want := []string{
+		"scale-89abcdef-1/PersistentVolumeClaim/scale-89abcdef-1",
+		"scale-89abcdef-1/PersistentVolumeClaim/scale-89abcdef-2",
+		"scale-89abcdef-1/Pod/scale-89abcdef-1",
+		"scale-89abcdef-1/Pod/scale-89abcdef-2",
+		"scale-89abcdef-2/PersistentVolumeClaim/scale-89abcdef-1",
+		"scale-89abcdef-2/PersistentVolumeClaim/scale-89abcdef-2",
+		"scale-89abcdef-2/Pod/scale-89abcdef-1",
+		"scale-89abcdef-2/Pod/scale-89abcdef-2",
+	}
+	if !reflect.DeepEqual(applied, want) {
+		t.Errorf("applied %q, want %q", applied, want)
+	}
+
+	// The wait is over once every claim is bound and every pod ready
+	s := w.summarize()
+	if s.Objects != 8 || s.Bound != 4 || s.Ready != 4 || s.Failed != 0 {
+		t.Errorf("summary has %d of %d claims bound, %d of %d pods ready and %d failed, want every object done",
+			s.Bound, s.Claims, s.Ready, s.Pods, s.Failed)
+	}
+	for _, object := range w.objects {
+		if object.Kind == KindPod && object.Node == "" {
+			t.Errorf("pod %s has no node", object.key())
+		}
+	}
+}
+
+func TestRunBenchmarkRejected(t *testing.T) {
+	cluster := kubetest.New()
+	w := newTestWorkload(t, cluster, testConfig)
+
+	// A quota of the second namespace rejects its pods
+	cluster.Dynamic.PrependReactor("create", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		if action.GetNamespace() != "scale-89abcdef-2" {
+			return false, nil, nil
+		}
+		return true, nil, apierrors.NewForbidden(schema.GroupResource{Resource: "pods"}, "", nil)
+	})
+
+	err := w.RunBenchmark(context.Background())
+	if status.ReasonOf(err) != status.ReasonThreshold {
+		t.Fatalf("RunBenchmark returned %v, want a threshold failure", err)
+	}
+	if s := w.summarize(); s.Failed != 2 || s.Ready != 2 || s.Bound != 4 {
+		t.Errorf("summary has %d failed, %d pods ready and %d claims bound, want the 2 rejected pods failed", s.Failed, s.Ready, s.Bound)
+	}
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/jtaleric/k8s-io/pkg/config"
+	"github.com/jtaleric/k8s-io/pkg/hooks"
+	"github.com/jtaleric/k8s-io/pkg/plan"
+	"github.com/jtaleric/k8s-io/pkg/status"
+	"github.com/jtaleric/k8s-io/pkg/timeline"
+)
+
+// runPlanCommand decides everything a run will do without touching the cluster, prints it
+// for review and optionally saves it for apply-plan
+func runPlanCommand(args []string) {
+	fs := flag.NewFlagSet("plan", flag.ExitOnError)
+	configFile := fs.String("config", "config.yaml", "Path to configuration file")
+	output := fs.String("o", "", "Save the plan to this file for apply-plan")
+	namespace := fs.String("namespace", "", "Namespace of the benchmark (overrides the configuration)")
+	kubeconfig := fs.String("kubeconfig", "", "Path to the kubeconfig file")
+	kubeContext := fs.String("context", "", "Kubeconfig context to use")
+	fs.Parse(args)
+
+	cfg, err := config.LoadConfig(*configFile)
+	if err != nil {
+		exit(nil, status.Errorf(status.ReasonConfig, "failed to load configuration: %w", err))
+	}
+	if *namespace != "" {
+		cfg.Namespace = *namespace
+	}
+
+	_, workload := createWorkload(cfg, *kubeconfig, *kubeContext)
+
+	manifests, err := workload.GenerateManifests()
+	if err != nil {
+		exit(cfg, status.Errorf(status.ReasonConfig, "failed to generate manifests: %w", err))
+	}
+	est, err := workload.Estimate()
+	if err != nil {
+		exit(cfg, status.Errorf(status.ReasonConfig, "failed to estimate the run: %w", err))
+	}
+	fingerprint, err := workload.Fingerprint()
+	if err != nil {
+		exit(cfg, status.Errorf(status.ReasonConfig, "failed to compute config fingerprint: %w", err))
+	}
+
+	p, err := plan.New(cfg, fingerprint, manifests, withHookPhases(cfg, workload.Phases()), est)
+	if err != nil {
+		exit(cfg, status.Errorf(status.ReasonConfig, "failed to create plan: %w", err))
+	}
+	p.Print(os.Stdout)
+
+	if *output != "" {
+		if err := p.Save(*output); err != nil {
+			exit(cfg, status.Errorf(status.ReasonConfig, "%w", err))
+		}
+		fmt.Printf("\nSaved plan to %s, run it with: k8s-io apply-plan %s\n", *output, *output)
+	}
+}
+
+// runApplyPlanCommand executes a saved plan with its configuration and UUID, after checking
+// that this k8s-io renders the same manifests that were reviewed
+func runApplyPlanCommand(args []string) {
+	fs := flag.NewFlagSet("apply-plan", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: k8s-io apply-plan [flags] <plan file>\n\n")
+		fs.PrintDefaults()
+	}
+	kubeconfig := fs.String("kubeconfig", "", "Path to the kubeconfig file")
+	kubeContext := fs.String("context", "", "Kubeconfig context to use")
+	follow := fs.Bool("follow", false, "Stream the logs of all benchmark pods, prefixed with the pod name")
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	p, err := plan.Load(fs.Arg(0))
+	if err != nil {
+		exit(nil, status.Errorf(status.ReasonConfig, "%w", err))
+	}
+	cfg, err := config.Parse([]byte(p.Config), fs.Arg(0))
+	if err != nil {
+		exit(nil, status.Errorf(status.ReasonConfig, "failed to load configuration: %w", err))
+	}
+	log.Printf("Applying plan for %s run %s created %s", p.Workload, p.UUID, timeline.FormatUTC(p.Created))
+
+	k8sClient, workload := createWorkload(cfg, *kubeconfig, *kubeContext)
+
+	manifests, err := workload.GenerateManifests()
+	if err != nil {
+		exit(cfg, status.Errorf(status.ReasonConfig, "failed to generate manifests: %w", err))
+	}
+	if err := p.Verify(manifests); err != nil {
+		exit(cfg, status.Errorf(status.ReasonConfig, "refusing to apply plan: %w", err))
+	}
+
+	runWorkload(cfg, k8sClient, workload, *follow)
+}
+
+// withHookPhases adds the pre_run and post_run hooks around the phases of the workload
+func withHookPhases(cfg *config.Config, phases []plan.Phase) []plan.Phase {
+	if cfg.Hooks == nil {
+		return phases
+	}
+	if len(cfg.Hooks.PreRun) > 0 {
+		phases = append([]plan.Phase{hookPhase(hooks.StagePreRun, cfg.Hooks.PreRun)}, phases...)
+	}
+	if len(cfg.Hooks.PostRun) > 0 {
+		phases = append(phases, hookPhase(hooks.StagePostRun, cfg.Hooks.PostRun))
+	}
+	return phases
+}
+
+// hookPhase describes the hooks of a stage as a plan phase
+func hookPhase(stage string, stageHooks []config.Hook) plan.Phase {
+	phase := plan.Phase{Name: stage, Description: "run the " + strings.Replace(stage, "-", "_", 1) + " hooks"}
+	for _, h := range stageHooks {
+		where := "locally"
+		if h.Image != "" {
+			where = "as a job with image " + h.Image
+		}
+		phase.Waits = append(phase.Waits, plan.Wait{For: fmt.Sprintf("hook %s (%s) %s", h.Name, strings.Join(h.Command, " "), where), Timeout: h.Timeout})
+	}
+	return phase
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// pluginName is the binary name kubectl looks up for `kubectl k8sio`
+const pluginName = "kubectl-k8sio"
+
+// isKubectlPlugin reports whether the binary was invoked as the kubectl plugin
+func isKubectlPlugin(arg0 string) bool {
+	return strings.TrimSuffix(filepath.Base(arg0), ".exe") == pluginName
+}
+
+// translatePluginArgs maps `kubectl k8sio <command> [flags]` to the k8s-io command line.
+// The kubectl flags --kubeconfig, --context and -n/--namespace are accepted anywhere
+// after the command.
+func translatePluginArgs(args []string) []string {
+	if len(args) < 2 {
+		pluginUsage()
+		os.Exit(2)
+	}
+
+	command, rest := args[1], args[2:]
+	var kubeFlags, other []string
+	for i := 0; i < len(rest); i++ {
+		arg := rest[i]
+		if !strings.HasPrefix(arg, "-") {
+			other = append(other, arg)
+			continue
+		}
+
+		name, value, hasValue := strings.Cut(strings.TrimLeft(arg, "-"), "=")
+		switch name {
+		case "kubeconfig", "context", "namespace", "n":
+			if !hasValue {
+				if i+1 >= len(rest) {
+					fmt.Fprintf(os.Stderr, "flag needs an argument: %s\n", arg)
+					os.Exit(2)
+				}
+				i++
+				value = rest[i]
+			}
+			if name == "n" {
+				name = "namespace"
+			}
+			kubeFlags = append(kubeFlags, "-"+name+"="+value)
+		default:
+			other = append(other, arg)
+		}
+	}
+
+	translated := []string{args[0]}
+	switch command {
+	case "run":
+		translated = append(translated, kubeFlags...)
+	case "cleanup":
+		translated = append(append(translated, kubeFlags...), "-cleanup")
+	case "status":
+		translated = append(append(translated, "status"), kubeFlags...)
+	case "results":
+		// Results are read from the history file and do not need the cluster
+		translated = append(translated, "history")
+	case "help", "-h", "--help":
+		pluginUsage()
+		os.Exit(0)
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n", command)
+		pluginUsage()
+		os.Exit(2)
+	}
+
+	return append(translated, other...)
+}
+
+// pluginUsage prints the commands of the kubectl plugin
+func pluginUsage() {
+	fmt.Fprintf(os.Stderr, `Usage: kubectl k8sio <command> [flags]
+
+Commands:
+  run       Run the benchmark in -config
+  status    List the benchmark pods and their phase
+  cleanup   Delete the resources of the benchmark in -config
+  results   List the runs recorded in the history file
+
+The kubectl flags --kubeconfig, --context and -n/--namespace are honored.
+Run "kubectl k8sio <command> -h" for the flags of a command.
+`)
+}
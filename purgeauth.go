@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/jtaleric/k8s-io/pkg/kubernetes"
+)
+
+// runPurgeAuthCommand deletes the service accounts and token secrets for Prometheus access
+// that runs left behind in any namespace. Those of runs that still hold a queue ticket are
+// kept whatever their age, since soak runs use their token for longer than a day.
+func runPurgeAuthCommand(args []string) {
+	fs := flag.NewFlagSet("purge-auth", flag.ExitOnError)
+	olderThan := fs.Duration("older-than", 24*time.Hour, "Only delete resources older than this, so running benchmarks keep their token")
+	dryRun := fs.Bool("dry-run", false, "List the resources that would be deleted without deleting them")
+	kubeconfig := fs.String("kubeconfig", "", "Path to the kubeconfig file")
+	kubeContext := fs.String("context", "", "Kubeconfig context to use")
+	fs.Parse(args)
+
+	k8sClient, err := kubernetes.NewClientForContext(*kubeconfig, *kubeContext)
+	if err != nil {
+		log.Fatalf("Failed to create Kubernetes client: %v", err)
+	}
+
+	ctx := context.Background()
+	resources, err := k8sClient.ListAuxiliary(ctx)
+	if err != nil {
+		log.Fatalf("Failed to list the auxiliary resources: %v", err)
+	}
+	var stale []kubernetes.AuxiliaryResource
+	for _, r := range resources {
+		if time.Since(r.Created) >= *olderThan {
+			stale = append(stale, r)
+		}
+	}
+
+	// The tickets of every queue namespace, a run renews its ticket until it ends
+	tickets, err := k8sClient.ListQueue(ctx, "")
+	if err != nil {
+		log.Fatalf("Failed to list the queue tickets of running benchmarks: %v", err)
+	}
+	held := make(map[string]bool)
+	for _, t := range tickets {
+		if t.UUID != "" && !t.Expired(time.Now()) {
+			held[t.UUID] = true
+		}
+	}
+	if len(stale) == 0 {
+		fmt.Printf("No k8s-io service accounts or secrets older than %s\n", *olderThan)
+		return
+	}
+
+	failed := 0
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "Namespace\tKind\tName\tRun\tAge\tResult\n")
+	for _, r := range stale {
+		result := "deleted"
+		if held[r.Run] {
+			result = "kept, run holds a queue ticket"
+		} else if *dryRun {
+			result = "would be deleted"
+		} else if err := k8sClient.DeleteAuxiliary(ctx, r); err != nil {
+			log.Printf("Warning: %v", err)
+			result = "failed"
+			failed++
+		}
+		run := r.Run
+		if run == "" {
+			run = "-"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", r.Namespace, r.Kind, r.Name, run, time.Since(r.Created).Round(time.Second), result)
+	}
+	w.Flush()
+
+	if failed > 0 {
+		log.Fatalf("Failed to delete %d of %d resources", failed, len(stale))
+	}
+}
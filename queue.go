@@ -0,0 +1,166 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/jtaleric/k8s-io/pkg/config"
+	"github.com/jtaleric/k8s-io/pkg/kubernetes"
+	"github.com/jtaleric/k8s-io/pkg/status"
+	"github.com/jtaleric/k8s-io/pkg/workloads"
+)
+
+// queuePollInterval is how often a queued run renews its ticket and checks whether it may start
+const queuePollInterval = 15 * time.Second
+
+// waitInQueue queues the run and waits until the queue limits let it start. The returned
+// function gives up the place of the run when it ends.
+func waitInQueue(ctx context.Context, k8sClient *kubernetes.Client, cfg *config.Config, workload workloads.Workload) (func(), error) {
+	q := cfg.Queue
+	limits := kubernetes.QueueLimits{MaxConcurrent: q.MaxConcurrent, MaxPerStorageClass: q.MaxPerStorageClass}
+
+	est, err := workload.Estimate()
+	if err != nil {
+		return nil, status.Errorf(status.ReasonPreflight, "failed to find the storage classes of the run: %w", err)
+	}
+
+	exists, err := k8sClient.NamespaceExists(ctx, q.Namespace)
+	if err != nil {
+		return nil, status.Errorf(status.ReasonPreflight, "failed to check the queue namespace: %w", err)
+	}
+	if !exists {
+		if err := k8sClient.CreateNamespace(ctx, q.Namespace, cfg.ExtraLabels, cfg.ExtraAnnotations); err != nil {
+			return nil, status.Errorf(status.ReasonPreflight, "failed to create the queue namespace: %w", err)
+		}
+	}
+
+	ticket := kubernetes.QueueTicket{
+		UUID:           cfg.UUID,
+		Owner:          cfg.Owner,
+		Namespace:      cfg.Namespace,
+		StorageClasses: est.StorageClasses,
+	}
+	if err := k8sClient.Enqueue(ctx, q.Namespace, ticket); err != nil {
+		return nil, status.Errorf(status.ReasonPreflight, "%w", err)
+	}
+	leave := func() {
+		if err := k8sClient.Dequeue(context.Background(), q.Namespace, cfg.UUID); err != nil {
+			log.Printf("Warning: %v", err)
+		}
+	}
+
+	var deadline time.Time
+	if q.MaxWait != "" {
+		maxWait, _ := time.ParseDuration(q.MaxWait)
+		deadline = time.Now().Add(maxWait)
+	}
+
+	lastPosition := -1
+	for {
+		tickets, err := k8sClient.ListQueue(ctx, q.Namespace)
+		if err != nil {
+			leave()
+			return nil, status.Errorf(status.ReasonPreflight, "%w", err)
+		}
+
+		// Runs that crashed without leaving the queue stop renewing their tickets
+		var live []kubernetes.QueueTicket
+		for _, t := range tickets {
+			if t.UUID != cfg.UUID && t.Expired(time.Now()) {
+				log.Printf("Removing the expired queue ticket of run %s", t.UUID)
+				if err := k8sClient.Dequeue(ctx, q.Namespace, t.UUID); err != nil {
+					log.Printf("Warning: %v", err)
+				}
+				continue
+			}
+			live = append(live, t)
+		}
+
+		position := -1
+		for _, t := range kubernetes.Admit(live, limits) {
+			if t.UUID == cfg.UUID {
+				position = t.Position
+			}
+		}
+		if position < 0 {
+			return nil, status.Errorf(status.ReasonPreflight, "the queue ticket of run %s was removed while it waited", cfg.UUID)
+		}
+		if position == 0 {
+			break
+		}
+
+		if err := k8sClient.RenewTicket(ctx, q.Namespace, cfg.UUID, kubernetes.TicketQueued, position); err != nil {
+			log.Printf("Warning: %v", err)
+		}
+		if position != lastPosition {
+			log.Printf("Run %s is queued at position %d in namespace %s", cfg.UUID, position, q.Namespace)
+			lastPosition = position
+		}
+
+		if !deadline.IsZero() && time.Now().After(deadline) {
+			leave()
+			return nil, status.Errorf(status.ReasonPreflight, "run was still queued at position %d after the queue max_wait of %s", position, q.MaxWait)
+		}
+		time.Sleep(queuePollInterval)
+	}
+
+	if err := k8sClient.RenewTicket(ctx, q.Namespace, cfg.UUID, kubernetes.TicketRunning, 0); err != nil {
+		leave()
+		return nil, status.Errorf(status.ReasonPreflight, "%w", err)
+	}
+	if lastPosition > 0 {
+		log.Printf("Run %s left the queue and is starting", cfg.UUID)
+	}
+
+	// Renew the ticket while the run holds its slot
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		ticker := time.NewTicker(queuePollInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				if err := k8sClient.RenewTicket(ctx, q.Namespace, cfg.UUID, kubernetes.TicketRunning, 0); err != nil {
+					log.Printf("Warning: %v", err)
+				}
+			}
+		}
+	}()
+
+	return func() {
+		close(stop)
+		wg.Wait()
+		leave()
+	}, nil
+}
+
+// printQueue writes the tickets of a queue in queue order
+func printQueue(w io.Writer, tickets []kubernetes.QueueTicket) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "Position\tUUID\tState\tNamespace\tOwner\tStorage classes\tAge\n")
+	now := time.Now()
+	for _, t := range tickets {
+		position := "-"
+		if t.State == kubernetes.TicketQueued {
+			position = fmt.Sprint(t.Position)
+		}
+		state := t.State
+		if t.Expired(now) {
+			state += " (expired)"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n", position, t.UUID, state, t.Namespace, t.Owner,
+			strings.Join(t.StorageClasses, ","), now.Sub(t.Created).Round(time.Second))
+	}
+	tw.Flush()
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"github.com/jtaleric/k8s-io/pkg/config"
+	"github.com/jtaleric/k8s-io/pkg/history"
+	"github.com/jtaleric/k8s-io/pkg/kubernetes"
+	"github.com/jtaleric/k8s-io/pkg/resultsserver"
+)
+
+// runResultsServerCommand serves the run history and artifacts with a web UI, or prints the
+// manifests running the server in a cluster
+func runResultsServerCommand(args []string) {
+	fs := flag.NewFlagSet("results-server", flag.ExitOnError)
+	configFile := fs.String("config", "", "Take the history file and artifacts directory from this configuration file")
+	historyFile := fs.String("file", "", "Path to the history file (defaults to history.path from -config)")
+	artifactsDir := fs.String("artifacts", "", "Directory with the artifacts of every run (defaults to artifacts_dir from -config)")
+	addr := fs.String("addr", ":8080", "Address to serve on")
+	manifests := fs.Bool("manifests", false, "Print the manifests running the server in a cluster instead of serving")
+	namespace := fs.String("namespace", "k8s-io-results", "Namespace of the manifests")
+	image := fs.String("image", "", "k8s-io image of the manifests, built from the Dockerfile")
+	storageClass := fs.String("storageclass", "", "Storage class of the history and artifacts volume (defaults to the cluster default)")
+	storageSize := fs.String("storagesize", "10Gi", "Size of the history and artifacts volume")
+	auth := fs.Bool("auth", false, "Require Kubernetes bearer tokens and only show the runs of namespaces where the user may list jobs")
+	kubeconfig := fs.String("kubeconfig", "", "Path to the kubeconfig file reviewing tokens with -auth (defaults to the in-cluster config)")
+	fs.Parse(args)
+
+	if *manifests {
+		if *image == "" {
+			log.Fatalf("-manifests requires the -image running the server")
+		}
+		out, err := resultsserver.Manifests(resultsserver.ManifestOptions{
+			Namespace:    *namespace,
+			Image:        *image,
+			StorageClass: *storageClass,
+			StorageSize:  *storageSize,
+			Port:         8080,
+			Auth:         *auth,
+		})
+		if err != nil {
+			log.Fatalf("%v", err)
+		}
+		fmt.Print(out)
+		return
+	}
+
+	if *configFile != "" {
+		cfg, err := config.LoadConfig(*configFile)
+		if err != nil {
+			log.Fatalf("Failed to load configuration: %v", err)
+		}
+		if *historyFile == "" && cfg.History != nil {
+			*historyFile = cfg.History.Path
+		}
+		if *artifactsDir == "" {
+			*artifactsDir = cfg.ArtifactsDir
+		}
+	}
+	if *historyFile == "" {
+		log.Fatalf("A history file must be given with -file or history.path in -config")
+	}
+	if *artifactsDir == "" {
+		*artifactsDir = "artifacts"
+	}
+
+	if err := os.MkdirAll(filepath.Dir(*historyFile), 0755); err != nil {
+		log.Fatalf("Failed to create the history directory: %v", err)
+	}
+	var authorizer resultsserver.Authorizer
+	if *auth {
+		k8sClient, err := kubernetes.NewClientForContext(*kubeconfig, "")
+		if err != nil {
+			log.Fatalf("Failed to create Kubernetes client: %v", err)
+		}
+		authorizer = resultsserver.NewKubernetesAuthorizer(k8sClient)
+		log.Printf("Requests are authenticated with TokenReviews and authorized with SubjectAccessReviews")
+	}
+
+	server, err := resultsserver.New(*historyFile, *artifactsDir, authorizer)
+	if err != nil {
+		log.Fatalf("%v", err)
+	}
+
+	log.Printf("Serving the runs of %s and the artifacts in %s on http://%s/", *historyFile, *artifactsDir, *addr)
+	if err := http.ListenAndServe(*addr, server.Handler()); err != nil {
+		log.Fatalf("Results server stopped: %v", err)
+	}
+}
+
+// runUploadCommand uploads the history record and artifacts of a finished run to a results server
+func runUploadCommand(args []string) {
+	fs := flag.NewFlagSet("upload", flag.ExitOnError)
+	configFile := fs.String("config", "", "Take the history file, artifacts directory and server from this configuration file")
+	historyFile := fs.String("file", "", "Path to the history file (defaults to history.path from -config)")
+	artifactsDir := fs.String("artifacts", "", "Directory with the artifacts of every run (defaults to artifacts_dir from -config)")
+	server := fs.String("server", "", "URL of the results server (defaults to history.server from -config)")
+	token := fs.String("token", os.Getenv(resultsTokenEnv), "Bearer token for a results server with -auth (defaults to "+resultsTokenEnv+")")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: k8s-io upload [flags] <uuid>\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	if *configFile != "" {
+		cfg, err := config.LoadConfig(*configFile)
+		if err != nil {
+			log.Fatalf("Failed to load configuration: %v", err)
+		}
+		if cfg.History != nil {
+			if *historyFile == "" {
+				*historyFile = cfg.History.Path
+			}
+			if *server == "" {
+				*server = cfg.History.Server
+			}
+		}
+		if *artifactsDir == "" {
+			*artifactsDir = cfg.ArtifactsDir
+		}
+	}
+	if *server == "" {
+		log.Fatalf("A results server must be given with -server or history.server in -config")
+	}
+	if *artifactsDir == "" {
+		*artifactsDir = "artifacts"
+	}
+
+	if err := uploadRun(*server, *token, *historyFile, fs.Arg(0), filepath.Join(*artifactsDir, fs.Arg(0))); err != nil {
+		log.Fatalf("%v", err)
+	}
+}
+
+// resultsTokenEnv is the environment variable with the bearer token of uploads
+const resultsTokenEnv = "K8SIO_RESULTS_TOKEN"
+
+// uploadRun uploads the history record of a run, if it was recorded, and its artifacts
+func uploadRun(server, token, historyFile, uuid, dir string) error {
+	var record *history.Record
+	if historyFile != "" {
+		records, err := history.NewStore(historyFile).Load()
+		if err != nil {
+			return err
+		}
+		if r, ok := history.Find(records, uuid); ok {
+			record = &r
+		}
+	}
+	if record == nil {
+		log.Printf("Warning: run %s is not in the history, only its artifacts are uploaded", uuid)
+	}
+
+	files, err := resultsserver.Upload(context.Background(), server, token, record, uuid, dir)
+	if err != nil {
+		return fmt.Errorf("failed to upload run %s to %s: %w", uuid, server, err)
+	}
+	log.Printf("Uploaded run %s with %d artifact(s) to %s", uuid, files, server)
+	return nil
+}
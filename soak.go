@@ -0,0 +1,184 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"time"
+
+	"github.com/jtaleric/k8s-io/pkg/config"
+	"github.com/jtaleric/k8s-io/pkg/kubernetes"
+	"github.com/jtaleric/k8s-io/pkg/soak"
+	"github.com/jtaleric/k8s-io/pkg/status"
+	"github.com/jtaleric/k8s-io/pkg/timeline"
+	"github.com/jtaleric/k8s-io/pkg/units"
+)
+
+// soakAuthRetry is how often a soak test checks again whether expired credentials were renewed
+const soakAuthRetry = time.Minute
+
+// runSoakCommand runs the workload of a configuration over and over as checkpoints until the
+// duration of the soak test is over, recording every checkpoint so a restarted soak test
+// resumes where it stopped
+func runSoakCommand(args []string) {
+	fs := flag.NewFlagSet("soak", flag.ExitOnError)
+	configFile := fs.String("config", "config.yaml", "Path to a configuration file with a low-intensity profile")
+	uuid := fs.String("uuid", "", "UUID of the soak test to start or resume (defaults to the configuration uuid)")
+	duration := fs.Duration("duration", 0, "How long to start checkpoints for, e.g. 72h (defaults to the duration of a resumed soak test)")
+	interval := fs.Duration("interval", 0, "Start a checkpoint this often, e.g. 1h, instead of back to back")
+	maxFailures := fs.Int("max-failures", 3, "Stop after this many checkpoints failed in a row, 0 to never stop")
+	keepLogs := fs.Int("keep-logs", 48, "Checkpoint logs to keep, 0 to keep all")
+	authWait := fs.Duration("auth-wait", 15*time.Minute, "How long to wait for rejected credentials to be renewed before a checkpoint")
+	namespace := fs.String("namespace", "", "Namespace of the benchmark (overrides the configuration)")
+	kubeconfig := fs.String("kubeconfig", "", "Path to the kubeconfig file")
+	kubeContext := fs.String("context", "", "Kubeconfig context to use")
+	unitsMode := fs.String("units", "", "Units of the summary: raw or human (overrides the configuration)")
+	fs.Parse(args)
+
+	cfg, err := config.LoadConfig(*configFile)
+	if err != nil {
+		exit(nil, status.Errorf(status.ReasonConfig, "failed to load configuration: %w", err))
+	}
+	if *uuid != "" {
+		cfg.UUID = *uuid
+	}
+	if *namespace != "" {
+		cfg.Namespace = *namespace
+	}
+	if *unitsMode != "" {
+		cfg.Units = *unitsMode
+	}
+	mode, err := units.ParseMode(cfg.Units)
+	if err != nil {
+		exit(cfg, status.Errorf(status.ReasonConfig, "%w", err))
+	}
+	units.SetFormat(cfg.NumberFormat())
+	if *interval < 0 || *maxFailures < 0 || *keepLogs < 0 {
+		exit(cfg, status.Errorf(status.ReasonConfig, "-interval, -max-failures and -keep-logs cannot be negative"))
+	}
+
+	dir := cfg.RunArtifactsDir()
+	state, err := soak.Load(dir)
+	if err != nil {
+		exit(cfg, status.Errorf(status.ReasonConfig, "cannot resume soak test %s: %w", cfg.UUID, err))
+	}
+	switch {
+	case state == nil && *duration <= 0:
+		exit(cfg, status.Errorf(status.ReasonConfig, "a new soak test needs a positive -duration"))
+	case state == nil:
+		state = soak.New(cfg.UUID, *duration, *interval, time.Now())
+		log.Printf("Starting soak test %s until %s; resume it after an interruption with -uuid %s", cfg.UUID, timeline.FormatUTC(state.End()), cfg.UUID)
+	default:
+		// A resumed soak test keeps its start, so the interruption counts against its duration
+		if *duration > 0 {
+			state.Duration = duration.String()
+		}
+		if *interval > 0 {
+			state.Interval = interval.String()
+		}
+		log.Printf("Resuming soak test %s after %d checkpoint(s), until %s", cfg.UUID, len(state.Checkpoints), timeline.FormatUTC(state.End()))
+	}
+	if err := state.Save(dir); err != nil {
+		exit(cfg, err)
+	}
+
+	cfg.Clock = timeline.NewClock()
+	stderr := log.Writer()
+	for {
+		cp, due, resume, ok := state.Next(time.Now())
+		if !ok {
+			break
+		}
+		if wait := time.Until(due); wait > 0 {
+			log.Printf("Checkpoint %d starts at %s", cp.Index, timeline.FormatUTC(due))
+			time.Sleep(wait)
+		}
+
+		logFile, err := soak.OpenLog(dir, cp.Index, *keepLogs)
+		if err != nil {
+			log.Printf("Warning: checkpoint %d only logs to the console: %v", cp.Index, err)
+		} else {
+			log.SetOutput(io.MultiWriter(stderr, logFile))
+		}
+
+		// An interrupted checkpoint adopts the resources it left behind, jobs that completed
+		// are not rerun; new checkpoints replace whatever the previous one left
+		policy := kubernetes.CollisionReplace
+		if resume {
+			policy = kubernetes.CollisionAdopt
+			log.Printf("Resuming interrupted checkpoint %d as run %s", cp.Index, cp.UUID)
+		} else {
+			log.Printf("Starting checkpoint %d as run %s", cp.Index, cp.UUID)
+		}
+		state.Started(cp, time.Now())
+		if err := state.Save(dir); err != nil {
+			exit(cfg, err)
+		}
+
+		var metrics map[string]float64
+		err = waitForCredentials(*kubeconfig, *kubeContext, *authWait)
+		if err == nil {
+			metrics, err = runArm(cfg, cp.UUID, cfg.Workload.Args, policy, *kubeconfig, *kubeContext, false)
+		}
+		if err != nil {
+			log.Printf("Warning: checkpoint %d failed: %v", cp.Index, err)
+		} else {
+			log.Printf("Checkpoint %d completed", cp.Index)
+		}
+		state.Finished(metrics, err, time.Now())
+		if serr := state.Save(dir); serr != nil {
+			exit(cfg, serr)
+		}
+
+		log.SetOutput(stderr)
+		if logFile != nil {
+			logFile.Close()
+		}
+
+		if _, consecutive := state.Failures(); *maxFailures > 0 && consecutive >= *maxFailures {
+			log.Printf("Stopping soak test %s after %d failed checkpoints in a row", cfg.UUID, consecutive)
+			break
+		}
+	}
+
+	fmt.Printf("\n=== Soak Test %s ===\n", cfg.UUID)
+	state.PrintSummary(os.Stdout, mode)
+
+	total, _ := state.Failures()
+	if total > 0 {
+		exit(cfg, fmt.Errorf("%d of %d checkpoints of soak test %s failed", total, len(state.Checkpoints), cfg.UUID))
+	}
+	exit(cfg, nil)
+}
+
+// waitForCredentials checks that the API server accepts the credentials of the kubeconfig
+// before a checkpoint. Every check loads the kubeconfig again, so short-lived tokens that are
+// renewed outside of the soak test, by a credential plugin or a CronJob rewriting the
+// kubeconfig, are picked up. Rejected credentials are retried until they are renewed or wait
+// runs out.
+func waitForCredentials(kubeconfig, kubeContext string, wait time.Duration) error {
+	deadline := time.Now().Add(wait)
+	for {
+		k8sClient, err := kubernetes.NewClientForContext(kubeconfig, kubeContext)
+		if err != nil {
+			return status.Errorf(status.ReasonPreflight, "failed to create Kubernetes client: %w", err)
+		}
+		ok, err := k8sClient.Authenticated(context.Background())
+		if err != nil {
+			// Only rejected credentials are waited for, the checkpoint reports other failures
+			log.Printf("Warning: failed to check the credentials: %v", err)
+			return nil
+		}
+		if ok {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return status.Errorf(status.ReasonPreflight, "the API server rejected the credentials for %s", wait)
+		}
+		log.Printf("Warning: the API server rejects the credentials, checking again in %s", soakAuthRetry)
+		time.Sleep(soakAuthRetry)
+	}
+}
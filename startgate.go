@@ -0,0 +1,129 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jtaleric/k8s-io/pkg/config"
+	"github.com/jtaleric/k8s-io/pkg/kubernetes"
+	"github.com/jtaleric/k8s-io/pkg/preflight"
+	"github.com/jtaleric/k8s-io/pkg/prometheus"
+	"github.com/jtaleric/k8s-io/pkg/status"
+)
+
+// waitForStartGate checks the start_gate conditions and, depending on the action, waits until
+// they hold, fails the run or only warns about them
+func waitForStartGate(ctx context.Context, k8sClient *kubernetes.Client, cfg *config.Config) error {
+	gate := cfg.StartGate
+	interval, _ := time.ParseDuration(gate.Interval)
+	timeout, _ := time.ParseDuration(gate.Timeout)
+	start := time.Now()
+	deadline := start.Add(timeout)
+	waited := false
+
+	var prom *prometheus.Client
+	if gate.MaxNodeCPUPercent > 0 || gate.CephHealthOK {
+		promInfo, err := k8sClient.DiscoverPrometheusWithConfig(ctx, cfg.Prometheus)
+		if err == nil && promInfo.Found {
+			prom = prometheus.NewClient(promInfo.URL, promInfo.Token)
+		}
+	}
+
+	for {
+		violations := checkStartGate(ctx, k8sClient, prom, gate)
+		if len(violations) == 0 {
+			if waited {
+				log.Printf("Start gate conditions hold after waiting %s", time.Since(start).Round(time.Second))
+			} else {
+				log.Printf("Start gate conditions hold")
+			}
+			return nil
+		}
+
+		for _, v := range violations {
+			log.Printf("Start gate: %s", v)
+		}
+		switch gate.Action {
+		case "warn":
+			for _, v := range violations {
+				warnings = append(warnings, "start gate: "+v)
+			}
+			return nil
+		case "abort":
+			return status.Errorf(status.ReasonPreflight, "start gate conditions do not hold: %s", strings.Join(violations, "; "))
+		}
+
+		if time.Now().Add(interval).After(deadline) {
+			return status.Errorf(status.ReasonPreflight, "start gate conditions still do not hold after %s: %s", gate.Timeout, strings.Join(violations, "; "))
+		}
+		log.Printf("Waiting %s for the start gate conditions", interval)
+		time.Sleep(interval)
+		waited = true
+	}
+}
+
+// checkStartGate returns the start_gate conditions that do not hold. A condition that cannot
+// be checked is logged as a warning and does not hold up the run.
+func checkStartGate(ctx context.Context, k8sClient *kubernetes.Client, prom *prometheus.Client, gate *config.StartGateConfig) []string {
+	var violations []string
+
+	if gate.MaxNodeCPUPercent > 0 {
+		if prom == nil {
+			log.Printf("Warning: skipping the start gate node CPU check, Prometheus not found")
+		} else if found, err := preflight.CheckNodeCPU(ctx, prom, gate.MaxNodeCPUPercent); err != nil {
+			log.Printf("Warning: start gate node CPU check failed: %v", err)
+		} else {
+			violations = append(violations, found...)
+		}
+	}
+
+	if gate.NoUpgrades {
+		if found, err := k8sClient.UpgradesInProgress(ctx); err != nil {
+			log.Printf("Warning: start gate upgrade check failed: %v", err)
+		} else {
+			violations = append(violations, found...)
+		}
+	}
+
+	if gate.CephHealthOK {
+		violations = append(violations, checkCephHealth(ctx, k8sClient, prom)...)
+	}
+
+	return violations
+}
+
+// checkCephHealth checks the Ceph manager metrics, or the Rook CephCluster status when
+// Prometheus has none
+func checkCephHealth(ctx context.Context, k8sClient *kubernetes.Client, prom *prometheus.Client) []string {
+	if prom != nil {
+		found, ok, err := preflight.CheckCephHealth(ctx, prom)
+		if err != nil {
+			log.Printf("Warning: start gate Ceph health query failed: %v", err)
+		} else if ok {
+			return found
+		}
+	}
+
+	health, err := k8sClient.CephHealth(ctx)
+	if err != nil {
+		log.Printf("Warning: start gate Ceph health check failed: %v", err)
+		return nil
+	}
+	if len(health) == 0 {
+		log.Printf("Warning: skipping the start gate Ceph health check, no Ceph cluster found")
+		return nil
+	}
+
+	var violations []string
+	for cluster, status := range health {
+		if status != "HEALTH_OK" {
+			violations = append(violations, fmt.Sprintf("Ceph cluster %s is %s", cluster, status))
+		}
+	}
+	sort.Strings(violations)
+	return violations
+}
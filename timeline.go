@@ -0,0 +1,89 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/jtaleric/k8s-io/pkg/config"
+	"github.com/jtaleric/k8s-io/pkg/timeline"
+)
+
+// runTimelineCommand prints the event log of a run from its artifacts directory
+func runTimelineCommand(args []string) {
+	fs := flag.NewFlagSet("timeline", flag.ExitOnError)
+	uuid := fs.String("uuid", "", "UUID of the run")
+	configFile := fs.String("config", "", "Take the artifacts directory from this configuration file")
+	artifactsDir := fs.String("artifacts", "", "Directory with the artifacts of every run (defaults to artifacts_dir from -config)")
+	kinds := fs.String("kind", "", "Only print events of these comma-separated kinds, e.g. retry,failure")
+	asJSON := fs.Bool("json", false, "Print the events as JSON")
+	fs.Parse(args)
+
+	if *uuid == "" {
+		log.Fatalf("The run must be given with -uuid")
+	}
+	if *configFile != "" {
+		cfg, err := config.LoadConfig(*configFile)
+		if err != nil {
+			log.Fatalf("Failed to load configuration: %v", err)
+		}
+		if *artifactsDir == "" {
+			*artifactsDir = cfg.ArtifactsDir
+		}
+	}
+	if *artifactsDir == "" {
+		*artifactsDir = "artifacts"
+	}
+
+	events, err := timeline.ReadEvents(filepath.Join(*artifactsDir, *uuid))
+	if err != nil {
+		log.Fatalf("No event log of run %s: %v", *uuid, err)
+	}
+	if *kinds != "" {
+		wanted := make(map[string]bool)
+		for _, kind := range strings.Split(*kinds, ",") {
+			wanted[strings.TrimSpace(kind)] = true
+		}
+		filtered := events[:0]
+		for _, event := range events {
+			if wanted[event.Kind] {
+				filtered = append(filtered, event)
+			}
+		}
+		events = filtered
+	}
+
+	if *asJSON {
+		data, err := json.MarshalIndent(events, "", "  ")
+		if err != nil {
+			log.Fatalf("Failed to encode the events: %v", err)
+		}
+		fmt.Println(string(data))
+		return
+	}
+	if len(events) == 0 {
+		fmt.Printf("No events in the log of run %s\n", *uuid)
+		return
+	}
+
+	// Offsets are from the first event, so runs can be compared side by side
+	start := events[0].Time
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "Offset\tTime (UTC)\tKind\tSubject\tMessage\n")
+	for _, event := range events {
+		fmt.Fprintf(w, "+%s\t%s\t%s\t%s\t%s\n",
+			event.Time.Sub(start).Round(time.Second),
+			timeline.FormatUTC(event.Time),
+			event.Kind,
+			event.Subject,
+			event.Message,
+		)
+	}
+	w.Flush()
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/jtaleric/k8s-io/pkg/config"
+	"github.com/jtaleric/k8s-io/pkg/kubernetes"
+	"github.com/jtaleric/k8s-io/pkg/status"
+	"github.com/jtaleric/k8s-io/pkg/timeline"
+	"github.com/jtaleric/k8s-io/pkg/units"
+	"github.com/jtaleric/k8s-io/pkg/workloads/fio"
+)
+
+// runVMOverheadCommand runs the FIO profile of a configuration as pods and then as VMs with
+// the same cores, memory and storage, and reports the virtualization overhead
+func runVMOverheadCommand(args []string) {
+	fs := flag.NewFlagSet("vm-overhead", flag.ExitOnError)
+	configFile := fs.String("config", "config.yaml", "Path to a FIO configuration file")
+	namespace := fs.String("namespace", "", "Namespace of the benchmark (overrides the configuration)")
+	kubeconfig := fs.String("kubeconfig", "", "Path to the kubeconfig file")
+	kubeContext := fs.String("context", "", "Kubeconfig context to use")
+	unitsMode := fs.String("units", "", "Units of the comparison: raw or human (overrides the configuration)")
+	follow := fs.Bool("follow", false, "Stream the logs of all benchmark pods, prefixed with the pod name")
+	fs.Parse(args)
+
+	cfg, err := config.LoadConfig(*configFile)
+	if err != nil {
+		exit(nil, status.Errorf(status.ReasonConfig, "failed to load configuration: %w", err))
+	}
+	if *namespace != "" {
+		cfg.Namespace = *namespace
+	}
+	if *unitsMode != "" {
+		cfg.Units = *unitsMode
+	}
+	mode, err := units.ParseMode(cfg.Units)
+	if err != nil {
+		exit(cfg, status.Errorf(status.ReasonConfig, "%w", err))
+	}
+	units.SetFormat(cfg.NumberFormat())
+	if cfg.Workload.Name != "fio" {
+		exit(cfg, status.Errorf(status.ReasonConfig, "vm-overhead compares FIO runs, the configuration runs %s", cfg.Workload.Name))
+	}
+
+	podArgs, vmArgs, err := fio.OverheadArgs(cfg.Workload.Args)
+	if err != nil {
+		exit(cfg, status.Errorf(status.ReasonConfig, "invalid VM overhead comparison: %w", err))
+	}
+
+	cfg.Clock = timeline.NewClock()
+	log.Printf("Comparing pods and VMs as run %s", cfg.UUID)
+
+	report := fio.OverheadReport{
+		PodUUID: cfg.UUID + "-pod",
+		VMUUID:  cfg.UUID + "-vm",
+		CPU:     fmt.Sprint(podArgs["server_cpu"]),
+		Memory:  fmt.Sprint(podArgs["server_memory"]),
+	}
+	pod := runComparisonArm(cfg, "pod", report.PodUUID, podArgs, *kubeconfig, *kubeContext, *follow)
+	vm := runComparisonArm(cfg, "vm", report.VMUUID, vmArgs, *kubeconfig, *kubeContext, *follow)
+	report.Overheads = fio.VirtualizationOverhead(pod, vm)
+
+	fmt.Printf("\n=== Virtualization Overhead (%s CPU, %s memory) ===\n", report.CPU, report.Memory)
+	fio.PrintOverheadTable(os.Stdout, report.Overheads, mode)
+	fmt.Println("\nOverhead is the throughput lost or latency added by the VM, relative to the pod.")
+
+	if err := report.Write(cfg.RunArtifactsDir()); err != nil {
+		log.Printf("Warning: %v", err)
+		warnings = append(warnings, err.Error())
+	}
+	exit(cfg, nil)
+}
+
+// runComparisonArm runs one side of a comparison as its own run with its own UUID and
+// artifacts, removes its resources so the next side starts from scratch, and returns its
+// summarized metrics. The runs share the truncated UUID and therefore their resource names,
+// so every run replaces anything the previous one left behind.
+func runComparisonArm(base *config.Config, name, uuid string, args map[string]interface{}, kubeconfig, kubeContext string, follow bool) map[string]float64 {
+	metrics, err := runArm(base, uuid, args, kubernetes.CollisionReplace, kubeconfig, kubeContext, follow)
+	if err != nil {
+		exit(base, fmt.Errorf("%s run %s failed: %w", name, uuid, err))
+	}
+	return metrics
+}
+
+// runArm runs the workload of base as a run of its own with the UUID, arguments and
+// collision policy, uploads it and removes its resources, and returns its summarized metrics
+func runArm(base *config.Config, uuid string, args interface{}, collisionPolicy, kubeconfig, kubeContext string, follow bool) (map[string]float64, error) {
+	cfg := *base
+	cfg.UUID = uuid
+	cfg.Workload.Args = args
+	cfg.Metadata = nil
+	cfg.Clock = nil
+	cfg.CollisionPolicy = collisionPolicy
+
+	k8sClient, workload := createWorkload(&cfg, kubeconfig, kubeContext)
+	err := executeWorkload(&cfg, k8sClient, workload, follow)
+
+	cfg.Clock.Stop()
+	if werr := cfg.Clock.Write(cfg.RunArtifactsDir()); werr != nil {
+		log.Printf("Warning: %v", werr)
+	}
+	if cerr := workload.Cleanup(context.Background()); cerr != nil {
+		log.Printf("Warning: %v", cerr)
+	}
+	uploadResults(&cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	run, err := workload.CollectResults(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	return run.Metrics, nil
+}